@@ -4,6 +4,8 @@ interface GetItemsArgs {
   page: number;
   artist_id?: number;
   album_id?: number;
+  exclude_featured?: boolean;
+  by_release_group?: boolean;
   track_id?: number;
 }
 
@@ -97,6 +99,9 @@ async function getTopTracks(
   if (args.album_id !== undefined) {
     params.set("album_id", String(args.album_id));
   }
+  if (args.exclude_featured) {
+    params.set("exclude_featured", "true");
+  }
 
   const r = await fetch(`/apis/web/v1/top-tracks?${params.toString()}`);
   return handleJson<PaginatedResponse<Ranked<Track>>>(r);
@@ -114,6 +119,9 @@ async function getTopAlbums(
   if (args.artist_id !== undefined) {
     params.set("artist_id", String(args.artist_id));
   }
+  if (args.by_release_group) {
+    params.set("by_release_group", "true");
+  }
 
   const r = await fetch(`/apis/web/v1/top-albums?${params.toString()}`);
   return handleJson<PaginatedResponse<Ranked<Album>>>(r);
@@ -128,6 +136,10 @@ async function getTopArtists(
     page: String(args.page),
   });
 
+  if (args.exclude_featured) {
+    params.set("exclude_featured", "true");
+  }
+
   const r = await fetch(`/apis/web/v1/top-artists?${params.toString()}`);
   return handleJson<PaginatedResponse<Ranked<Artist>>>(r);
 }
@@ -222,6 +234,24 @@ function mergeArtists(
   );
 }
 
+function unmergeTracks(from: number): Promise<Response> {
+  return fetch(`/apis/web/v1/unmerge/tracks?from_id=${from}`, {
+    method: "POST",
+  });
+}
+
+function unmergeAlbums(from: number): Promise<Response> {
+  return fetch(`/apis/web/v1/unmerge/albums?from_id=${from}`, {
+    method: "POST",
+  });
+}
+
+function unmergeArtists(from: number): Promise<Response> {
+  return fetch(`/apis/web/v1/unmerge/artists?from_id=${from}`, {
+    method: "POST",
+  });
+}
+
 function login(
   username: string,
   password: string,
@@ -237,6 +267,61 @@ function login(
   });
 }
 
+function loginTOTP(loginToken: string, code: string): Promise<Response> {
+  const form = new URLSearchParams();
+  form.append("login_token", loginToken);
+  form.append("code", code);
+  return fetch(`/apis/web/v1/login/totp`, {
+    method: "POST",
+    body: form,
+  });
+}
+
+async function setupTOTP(): Promise<TOTPSetup> {
+  const r = await fetch(`/apis/web/v1/user/totp`, {
+    method: "POST",
+  });
+  return handleJson<TOTPSetup>(r);
+}
+
+async function enableTOTP(code: string): Promise<string[]> {
+  const form = new URLSearchParams();
+  form.append("code", code);
+  const r = await fetch(`/apis/web/v1/user/totp/verify`, {
+    method: "POST",
+    body: form,
+  });
+  const data = await handleJson<{ recovery_codes: string[] }>(r);
+  return data.recovery_codes;
+}
+
+function disableTOTP(code: string): Promise<Response> {
+  const form = new URLSearchParams();
+  form.append("code", code);
+  return fetch(`/apis/web/v1/user/totp`, {
+    method: "DELETE",
+    body: form,
+  });
+}
+
+function getSessions(): Promise<Session[]> {
+  return fetch(`/apis/web/v1/user/sessions`).then((r) =>
+    handleJson<Session[]>(r)
+  );
+}
+
+function revokeSession(id: string): Promise<Response> {
+  return fetch(`/apis/web/v1/user/sessions?id=${id}`, {
+    method: "DELETE",
+  });
+}
+
+function revokeOtherSessions(): Promise<Response> {
+  return fetch(`/apis/web/v1/user/sessions?others=true`, {
+    method: "DELETE",
+  });
+}
+
 function logout(): Promise<Response> {
   return fetch(`/apis/web/v1/logout`, {
     method: "POST",
@@ -269,9 +354,15 @@ function getApiKeys(): Promise<ApiKey[]> {
   return fetch(`/apis/web/v1/user/apikeys`).then((r) => handleJson<ApiKey[]>(r));
 }
 
-const createApiKey = async (label: string): Promise<ApiKey> => {
+const createApiKey = async (
+  label: string,
+  legacy: boolean = false
+): Promise<ApiKey> => {
   const form = new URLSearchParams();
   form.append("label", label);
+  if (legacy) {
+    form.append("legacy", "true");
+  }
   const r = await fetch(`/apis/web/v1/user/apikeys`, {
     method: "POST",
     body: form,
@@ -362,6 +453,21 @@ function setPrimaryAlias(
   });
 }
 
+function setArtistRole(
+  trackId: number,
+  artistId: number,
+  role: ArtistRole
+): Promise<Response> {
+  const form = new URLSearchParams();
+  form.append("track_id", String(trackId));
+  form.append("artist_id", String(artistId));
+  form.append("role", role);
+  return fetch(`/apis/web/v1/artists/role`, {
+    method: "POST",
+    body: form,
+  });
+}
+
 function updateMbzId(
   type: string,
   id: number,
@@ -380,6 +486,28 @@ function getAlbum(id: number): Promise<Album> {
   return fetch(`/apis/web/v1/album?id=${id}`).then((r) => handleJson<Album>(r));
 }
 
+function getReleaseGroup(albumId: number): Promise<ReleaseGroup> {
+  return fetch(`/apis/web/v1/release-group?album_id=${albumId}`).then((r) =>
+    handleJson<ReleaseGroup>(r)
+  );
+}
+
+function groupAlbums(albumId: number, withAlbumId: number): Promise<Response> {
+  const form = new URLSearchParams();
+  form.append("album_id", String(albumId));
+  form.append("with_album_id", String(withAlbumId));
+  return fetch(`/apis/web/v1/album/release-group`, {
+    method: "POST",
+    body: form,
+  });
+}
+
+function ungroupAlbum(albumId: number): Promise<Response> {
+  return fetch(`/apis/web/v1/album/release-group?album_id=${albumId}`, {
+    method: "DELETE",
+  });
+}
+
 function deleteListen(listen: Listen): Promise<Response> {
   const ms = new Date(listen.time).getTime();
   const unix = Math.floor(ms / 1000);
@@ -450,9 +578,19 @@ export {
   mergeTracks,
   mergeAlbums,
   mergeArtists,
+  unmergeTracks,
+  unmergeAlbums,
+  unmergeArtists,
   imageUrl,
   getImageTier,
   login,
+  loginTOTP,
+  setupTOTP,
+  enableTOTP,
+  disableTOTP,
+  getSessions,
+  revokeSession,
+  revokeOtherSessions,
   logout,
   getCfg,
   getGenreStats,
@@ -462,6 +600,10 @@ export {
   createAlias,
   deleteAlias,
   setPrimaryAlias,
+  setArtistRole,
+  getReleaseGroup,
+  groupAlbums,
+  ungroupAlbum,
   updateMbzId,
   getApiKeys,
   createApiKey,
@@ -500,9 +642,12 @@ type Artist = {
   time_listened: number;
   first_listen: number;
   is_primary: boolean;
+  role?: ArtistRole;
   all_time_rank: number;
 };
 
+type ArtistRole = "main" | "featured" | "remixer";
+
 type Album = {
   id: number;
   title: string;
@@ -514,6 +659,17 @@ type Album = {
   time_listened: number;
   first_listen: number;
   all_time_rank: number;
+  release_group_id?: number;
+  edition_count?: number;
+};
+
+type ReleaseGroup = {
+  id: number;
+  musicbrainz_id: string;
+  title: string;
+  listen_count: number;
+  time_listened: number;
+  editions: Album[];
 };
 
 type Alias = {
@@ -558,6 +714,7 @@ type InterestBucket = {
 type SimpleArtists = {
   name: string;
   id: number;
+  role?: ArtistRole;
 };
 
 type Stats = {
@@ -578,13 +735,39 @@ type User = {
   id: number;
   username: string;
   role: "user" | "admin";
+  totp_enabled?: boolean;
+};
+
+type TOTPSetup = {
+  secret: string;
+  uri: string;
+};
+
+type Session = {
+  // a hash of the session, which is what it is revoked by
+  id: string;
+  created_at: string;
+  expires_at: string;
+  persistent: boolean;
+  user_agent: string;
+  ip: string;
+  last_seen_at: string;
+  current: boolean;
 };
 
 type ApiKey = {
   id: number;
-  key: string;
+  // only returned when the key is created
+  key?: string;
+  prefix: string;
+  // whether the key can be used with Audioscrobbler 1.2 clients
+  legacy: boolean;
   label: string;
+  scope: "submit" | "read" | "full";
   created_at: Date;
+  expires_at?: Date;
+  last_used_at?: Date;
+  last_used_ip?: string;
 };
 
 type ApiError = {
@@ -593,6 +776,7 @@ type ApiError = {
 
 type Config = {
   default_theme: string;
+  oidc_enabled: boolean;
 };
 
 type NowPlaying = {
@@ -706,7 +890,9 @@ export type {
   GetRewindStatsArgs,
   Track,
   Artist,
+  ArtistRole,
   Album,
+  ReleaseGroup,
   Listen,
   SearchResponse,
   PaginatedResponse,
@@ -715,6 +901,8 @@ export type {
   ListenActivityItem,
   InterestBucket,
   User,
+  TOTPSetup,
+  Session,
   Alias,
   ApiKey,
   ApiError,
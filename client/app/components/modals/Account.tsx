@@ -2,6 +2,8 @@ import { logout, updateUser } from "api/api";
 import { useState } from "react";
 import { AsyncButton } from "../AsyncButton";
 import { useAppContext } from "~/providers/AppProvider";
+import TwoFactor from "./TwoFactor";
+import Sessions from "./Sessions";
 
 export default function Account() {
   const [username, setUsername] = useState("");
@@ -118,6 +120,8 @@ export default function Account() {
         </form>
         {success != "" && <p className="success">{success}</p>}
         {error != "" && <p className="error">{error}</p>}
+        <TwoFactor />
+        <Sessions />
       </div>
     </>
   );
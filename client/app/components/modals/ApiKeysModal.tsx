@@ -12,17 +12,18 @@ type CopiedState = {
 
 export default function ApiKeysModal() {
   const [input, setInput] = useState("");
+  const [legacy, setLegacy] = useState(false);
   const [loading, setLoading] = useState(false);
   const [err, setError] = useState<string>();
   const [displayData, setDisplayData] = useState<ApiKey[]>([]);
   const [copied, setCopied] = useState<CopiedState | null>(null);
-  const [expandedKey, setExpandedKey] = useState<string | null>(null);
-  const textRefs = useRef<Record<string, HTMLDivElement | null>>({});
+  const [expandedKey, setExpandedKey] = useState<number | null>(null);
+  const textRefs = useRef<Record<number, HTMLDivElement | null>>({});
 
-  const handleRevealAndSelect = (key: string) => {
-    setExpandedKey(key);
+  const handleRevealAndSelect = (id: number) => {
+    setExpandedKey(id);
     setTimeout(() => {
-      const el = textRefs.current[key];
+      const el = textRefs.current[id];
       if (el) {
         const range = document.createRange();
         range.selectNodeContents(el);
@@ -96,10 +97,11 @@ export default function ApiKeysModal() {
       return;
     }
     setLoading(true);
-    createApiKey(input)
+    createApiKey(input, legacy)
       .then((r) => {
         setDisplayData([r, ...displayData]);
         setInput("");
+        setLegacy(false);
       })
       .catch((err) => setError(err.message));
     setLoading(false);
@@ -122,39 +124,66 @@ export default function ApiKeysModal() {
     <div className="">
       <h3>API Keys</h3>
       <div className="flex flex-col gap-4 relative">
-        {displayData.map((v) => (
-          <div className="flex gap-2">
-            <div
-              key={v.key}
-              ref={(el) => {
-                textRefs.current[v.key] = el;
-              }}
-              onClick={() => handleRevealAndSelect(v.key)}
-              className={`bg p-3 rounded-md flex-grow cursor-pointer select-text ${
-                expandedKey === v.key ? "" : "truncate"
-              }`}
-              style={{ whiteSpace: "nowrap" }}
-              title={v.key} // optional tooltip
-            >
-              {expandedKey === v.key
-                ? v.key
-                : `${v.key.slice(0, 8)}... ${v.label}`}
+        {displayData.map((v) =>
+          v.key ? (
+            <div className="flex flex-col gap-1" key={v.id}>
+              <div className="flex gap-2">
+                <div
+                  ref={(el) => {
+                    textRefs.current[v.id] = el;
+                  }}
+                  onClick={() => handleRevealAndSelect(v.id)}
+                  className={`bg p-3 rounded-md flex-grow cursor-pointer select-text ${
+                    expandedKey === v.id ? "" : "truncate"
+                  }`}
+                  style={{ whiteSpace: "nowrap" }}
+                  title={v.key}
+                >
+                  {expandedKey === v.id
+                    ? v.key
+                    : `${v.prefix}... ${v.label}`}
+                </div>
+                <button
+                  onClick={(e) => handleCopy(e, v.key!)}
+                  className="large-button px-5 rounded-md"
+                >
+                  <Copy size={16} />
+                </button>
+                <AsyncButton
+                  loading={loading}
+                  onClick={() => handleDeleteApiKey(v.id)}
+                  confirm
+                >
+                  <Trash size={16} />
+                </AsyncButton>
+              </div>
+              <p className="text-sm color-fg-secondary">
+                Copy this key now. It won't be shown again.
+              </p>
             </div>
-            <button
-              onClick={(e) => handleCopy(e, v.key)}
-              className="large-button px-5 rounded-md"
-            >
-              <Copy size={16} />
-            </button>
-            <AsyncButton
-              loading={loading}
-              onClick={() => handleDeleteApiKey(v.id)}
-              confirm
-            >
-              <Trash size={16} />
-            </AsyncButton>
-          </div>
-        ))}
+          ) : (
+            <div className="flex gap-2" key={v.id}>
+              <div
+                className="bg p-3 rounded-md flex-grow truncate"
+                style={{ whiteSpace: "nowrap" }}
+                title={
+                  v.last_used_at
+                    ? `Last used ${new Date(v.last_used_at).toLocaleString()}`
+                    : "Never used"
+                }
+              >
+                {`${v.prefix}... ${v.label}${v.legacy ? " (1.2)" : ""}`}
+              </div>
+              <AsyncButton
+                loading={loading}
+                onClick={() => handleDeleteApiKey(v.id)}
+                confirm
+              >
+                <Trash size={16} />
+              </AsyncButton>
+            </div>
+          )
+        )}
         <div className="flex gap-2 w-3/5">
           <input
             type="text"
@@ -167,6 +196,18 @@ export default function ApiKeysModal() {
             Create
           </AsyncButton>
         </div>
+        <div className="flex gap-2 items-center">
+          <input
+            type="checkbox"
+            name="legacy-api-key"
+            id="legacy-api-key"
+            checked={legacy}
+            onChange={() => setLegacy(!legacy)}
+          />
+          <label htmlFor="legacy-api-key">
+            Allow use with Audioscrobbler 1.2 players
+          </label>
+        </div>
         {err && <p className="error">{err}</p>}
         {copied?.visible && (
           <div
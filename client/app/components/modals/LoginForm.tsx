@@ -1,4 +1,4 @@
-import { login } from "api/api";
+import { getCfg, login, loginTOTP } from "api/api";
 import { useEffect, useState } from "react";
 import { AsyncButton } from "../AsyncButton";
 
@@ -8,13 +8,28 @@ export default function LoginForm() {
   const [username, setUsername] = useState("");
   const [password, setPassword] = useState("");
   const [remember, setRemember] = useState(false);
+  const [oidcEnabled, setOidcEnabled] = useState(false);
+  const [loginToken, setLoginToken] = useState("");
+  const [code, setCode] = useState("");
+
+  useEffect(() => {
+    getCfg()
+      .then((cfg) => setOidcEnabled(cfg.oidc_enabled === true))
+      .catch(() => setOidcEnabled(false));
+  }, []);
 
   const loginHandler = () => {
     if (username && password) {
       setLoading(true);
       login(username, password, remember)
         .then((r) => {
-          if (r.status >= 200 && r.status < 300) {
+          if (r.status === 200) {
+            // two-factor authentication is enabled, so a code is needed as well
+            r.json().then((r) => {
+              setError("");
+              setLoginToken(r.login_token);
+            });
+          } else if (r.status >= 200 && r.status < 300) {
             window.location.reload();
           } else {
             r.json().then((r) => setError(r.error));
@@ -27,6 +42,61 @@ export default function LoginForm() {
     }
   };
 
+  const totpHandler = () => {
+    if (!code) {
+      setError("enter the code from your authenticator app or a recovery code");
+      return;
+    }
+    setLoading(true);
+    loginTOTP(loginToken, code)
+      .then((r) => {
+        if (r.ok) {
+          window.location.reload();
+        } else {
+          r.json().then((r) => setError(r.error));
+          if (r.status === 401) {
+            setCode("");
+          }
+        }
+      })
+      .catch((err) => setError(err));
+    setLoading(false);
+  };
+
+  if (loginToken) {
+    return (
+      <>
+        <h3>Two-Factor Authentication</h3>
+        <div className="flex flex-col items-center gap-4 w-full">
+          <p>
+            Enter the code from your authenticator app, or one of your recovery
+            codes.
+          </p>
+          <form
+            action="#"
+            className="flex flex-col items-center gap-4 w-3/4"
+            onSubmit={(e) => e.preventDefault()}
+          >
+            <input
+              name="koito-totp-code"
+              type="text"
+              inputMode="numeric"
+              autoComplete="one-time-code"
+              placeholder="Code"
+              className="w-full mx-auto fg bg rounded p-2"
+              value={code}
+              onChange={(e) => setCode(e.target.value)}
+            />
+            <AsyncButton loading={loading} onClick={totpHandler}>
+              Verify
+            </AsyncButton>
+          </form>
+          <p className="error">{error}</p>
+        </div>
+      </>
+    );
+  }
+
   return (
     <>
       <h3>Log In</h3>
@@ -67,6 +137,11 @@ export default function LoginForm() {
             Login
           </AsyncButton>
         </form>
+        {oidcEnabled && (
+          <a href="/apis/web/v1/oidc/login" className="link-underline">
+            Log in with SSO
+          </a>
+        )}
         <p className="error">{error}</p>
       </div>
     </>
@@ -0,0 +1,101 @@
+import { useQuery } from "@tanstack/react-query";
+import {
+  getSessions,
+  revokeOtherSessions,
+  revokeSession,
+  type Session,
+} from "api/api";
+import { useEffect, useState } from "react";
+import { Trash } from "lucide-react";
+import { AsyncButton } from "../AsyncButton";
+
+export default function Sessions() {
+  const [loading, setLoading] = useState(false);
+  const [err, setError] = useState("");
+  const [displayData, setDisplayData] = useState<Session[]>([]);
+
+  const { isPending, isError, data, error } = useQuery({
+    queryKey: ["sessions"],
+    queryFn: () => getSessions(),
+  });
+
+  useEffect(() => {
+    if (data) {
+      setDisplayData(data);
+    }
+  }, [data]);
+
+  if (isError) {
+    return <p className="error">Error: {error.message}</p>;
+  }
+  if (isPending) {
+    return <p>Loading...</p>;
+  }
+
+  const handleRevoke = (id: string) => {
+    setError("");
+    setLoading(true);
+    revokeSession(id)
+      .then((r) => {
+        if (r.ok) {
+          setDisplayData(displayData.filter((v) => v.id != id));
+        } else {
+          r.json().then((r) => setError(r.error));
+        }
+      })
+      .finally(() => setLoading(false));
+  };
+
+  const handleRevokeOthers = () => {
+    setError("");
+    setLoading(true);
+    revokeOtherSessions()
+      .then((r) => {
+        if (r.ok) {
+          setDisplayData(displayData.filter((v) => v.current));
+        } else {
+          r.json().then((r) => setError(r.error));
+        }
+      })
+      .finally(() => setLoading(false));
+  };
+
+  return (
+    <>
+      <h3>Sessions</h3>
+      <div className="flex flex-col gap-4">
+        {displayData.map((v) => (
+          <div className="flex gap-2" key={v.id}>
+            <div
+              className="bg p-3 rounded-md flex-grow truncate"
+              style={{ whiteSpace: "nowrap" }}
+              title={`Logged in ${new Date(v.created_at).toLocaleString()}`}
+            >
+              {v.current && <strong>This device · </strong>}
+              {`${v.user_agent || "Unknown client"} · ${v.ip} · last seen ${new Date(
+                v.last_seen_at
+              ).toLocaleString()}`}
+            </div>
+            {!v.current && (
+              <AsyncButton
+                loading={loading}
+                onClick={() => handleRevoke(v.id)}
+                confirm
+              >
+                <Trash size={16} />
+              </AsyncButton>
+            )}
+          </div>
+        ))}
+        {displayData.length > 1 && (
+          <div className="w-sm">
+            <AsyncButton loading={loading} onClick={handleRevokeOthers} confirm>
+              Log out other sessions
+            </AsyncButton>
+          </div>
+        )}
+        {err != "" && <p className="error">{err}</p>}
+      </div>
+    </>
+  );
+}
@@ -0,0 +1,143 @@
+import { disableTOTP, enableTOTP, setupTOTP, type TOTPSetup } from "api/api";
+import { useState } from "react";
+import { AsyncButton } from "../AsyncButton";
+import { useAppContext } from "~/providers/AppProvider";
+
+export default function TwoFactor() {
+  const { user } = useAppContext();
+  const [enabled, setEnabled] = useState(user?.totp_enabled === true);
+  const [setup, setSetup] = useState<TOTPSetup | null>(null);
+  const [recoveryCodes, setRecoveryCodes] = useState<string[]>([]);
+  const [code, setCode] = useState("");
+  const [loading, setLoading] = useState(false);
+  const [error, setError] = useState("");
+
+  const setupHandler = () => {
+    setError("");
+    setLoading(true);
+    setupTOTP()
+      .then((s) => setSetup(s))
+      .catch((err) => setError(err.message))
+      .finally(() => setLoading(false));
+  };
+
+  const enableHandler = () => {
+    setError("");
+    setLoading(true);
+    enableTOTP(code)
+      .then((codes) => {
+        setRecoveryCodes(codes);
+        setSetup(null);
+        setEnabled(true);
+        setCode("");
+      })
+      .catch((err) => setError(err.message))
+      .finally(() => setLoading(false));
+  };
+
+  const disableHandler = () => {
+    setError("");
+    setLoading(true);
+    disableTOTP(code)
+      .then((r) => {
+        if (r.ok) {
+          setEnabled(false);
+          setRecoveryCodes([]);
+          setCode("");
+        } else {
+          r.json().then((r) => setError(r.error));
+        }
+      })
+      .catch((err) => setError(err))
+      .finally(() => setLoading(false));
+  };
+
+  return (
+    <>
+      <h3>Two-Factor Authentication</h3>
+      <div className="flex flex-col gap-4">
+        {recoveryCodes.length > 0 && (
+          <div className="flex flex-col gap-2">
+            <p>
+              Save these recovery codes somewhere safe. Each one can be used
+              once to log in if you lose access to your authenticator app. They
+              won't be shown again.
+            </p>
+            <pre className="bg rounded p-2">{recoveryCodes.join("\n")}</pre>
+          </div>
+        )}
+        {!enabled && !setup && (
+          <div className="flex flex-col gap-2">
+            <p>
+              Require a code from an authenticator app in addition to your
+              password when logging in. API keys are not affected.
+            </p>
+            <div className="w-sm">
+              <AsyncButton loading={loading} onClick={setupHandler}>
+                Set up
+              </AsyncButton>
+            </div>
+          </div>
+        )}
+        {!enabled && setup && (
+          <form
+            action="#"
+            onSubmit={(e) => e.preventDefault()}
+            className="flex flex-col gap-2"
+          >
+            <p>
+              Add Koito to your authenticator app by opening{" "}
+              <a href={setup.uri} className="link-underline">
+                this link
+              </a>{" "}
+              on your phone or entering the key below, then enter the code it
+              shows.
+            </p>
+            <code className="bg rounded p-2 break-all">{setup.secret}</code>
+            <input
+              name="koito-totp-code"
+              type="text"
+              inputMode="numeric"
+              autoComplete="one-time-code"
+              placeholder="Code"
+              className="w-full mx-auto fg bg rounded p-2"
+              value={code}
+              onChange={(e) => setCode(e.target.value)}
+            />
+            <div className="w-sm">
+              <AsyncButton loading={loading} onClick={enableHandler}>
+                Enable
+              </AsyncButton>
+            </div>
+          </form>
+        )}
+        {enabled && (
+          <form
+            action="#"
+            onSubmit={(e) => e.preventDefault()}
+            className="flex flex-col gap-2"
+          >
+            <p>
+              Two-factor authentication is enabled. Enter a code or a recovery
+              code to turn it off.
+            </p>
+            <input
+              name="koito-totp-disable-code"
+              type="text"
+              placeholder="Code"
+              className="w-full mx-auto fg bg rounded p-2"
+              value={code}
+              onChange={(e) => setCode(e.target.value)}
+            />
+            <div className="w-sm">
+              <AsyncButton loading={loading} onClick={disableHandler}>
+                Disable
+              </AsyncButton>
+            </div>
+          </form>
+        )}
+        {error != "" && <p className="error">{error}</p>}
+      </div>
+    </>
+  );
+}
@@ -0,0 +1,37 @@
+-- +goose Up
+-- +goose StatementBegin
+
+CREATE TABLE relay_outbox (
+    id integer NOT NULL GENERATED ALWAYS AS IDENTITY (
+        SEQUENCE NAME relay_outbox_id_seq
+        START WITH 1
+        INCREMENT BY 1
+        NO MINVALUE
+        NO MAXVALUE
+        CACHE 1
+    ),
+    user_id integer NOT NULL,
+    payload jsonb NOT NULL,
+    status text DEFAULT 'pending' NOT NULL,
+    attempts integer DEFAULT 0 NOT NULL,
+    next_attempt_at timestamptz DEFAULT now() NOT NULL,
+    last_error text,
+    created_at timestamptz DEFAULT now() NOT NULL,
+    updated_at timestamptz DEFAULT now() NOT NULL,
+    CONSTRAINT relay_outbox_pkey PRIMARY KEY (id),
+    CONSTRAINT relay_outbox_status_check CHECK (status IN ('pending', 'dead'))
+);
+
+ALTER TABLE ONLY relay_outbox
+    ADD CONSTRAINT relay_outbox_user_id_fkey FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE;
+
+CREATE INDEX idx_relay_outbox_status_next_attempt ON relay_outbox USING btree (status, next_attempt_at);
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+DROP TABLE IF EXISTS relay_outbox CASCADE;
+
+-- +goose StatementEnd
@@ -0,0 +1,49 @@
+-- +goose Up
+-- +goose StatementBegin
+
+CREATE TABLE relay_targets (
+    id integer NOT NULL GENERATED ALWAYS AS IDENTITY (
+        SEQUENCE NAME relay_targets_id_seq
+        START WITH 1
+        INCREMENT BY 1
+        NO MINVALUE
+        NO MAXVALUE
+        CACHE 1
+    ),
+    user_id integer NOT NULL,
+    kind text NOT NULL,
+    label text DEFAULT '' NOT NULL,
+    url text DEFAULT '' NOT NULL,
+    token text DEFAULT '' NOT NULL,
+    api_key text DEFAULT '' NOT NULL,
+    api_secret text DEFAULT '' NOT NULL,
+    enabled boolean DEFAULT true NOT NULL,
+    relay_imports boolean DEFAULT true NOT NULL,
+    relay_playing_now boolean DEFAULT true NOT NULL,
+    include_clients text[] DEFAULT '{}' NOT NULL,
+    exclude_clients text[] DEFAULT '{}' NOT NULL,
+    created_at timestamptz DEFAULT now() NOT NULL,
+    CONSTRAINT relay_targets_pkey PRIMARY KEY (id),
+    CONSTRAINT relay_targets_kind_check CHECK (kind IN ('listenbrainz', 'lastfm', 'maloja'))
+);
+
+ALTER TABLE ONLY relay_targets
+    ADD CONSTRAINT relay_targets_user_id_fkey FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE;
+
+CREATE INDEX idx_relay_targets_user_id ON relay_targets USING btree (user_id);
+
+-- entries without a target are sent to the relay configured with KOITO_LBZ_RELAY_URL
+ALTER TABLE relay_outbox ADD COLUMN target_id integer;
+
+ALTER TABLE ONLY relay_outbox
+    ADD CONSTRAINT relay_outbox_target_id_fkey FOREIGN KEY (target_id) REFERENCES relay_targets(id) ON DELETE CASCADE;
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+ALTER TABLE relay_outbox DROP COLUMN IF EXISTS target_id;
+DROP TABLE IF EXISTS relay_targets CASCADE;
+
+-- +goose StatementEnd
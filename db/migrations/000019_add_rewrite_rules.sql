@@ -0,0 +1,42 @@
+-- +goose Up
+-- +goose StatementBegin
+
+CREATE TABLE rewrite_rules (
+    id integer NOT NULL GENERATED ALWAYS AS IDENTITY (
+        SEQUENCE NAME rewrite_rules_id_seq
+        START WITH 1
+        INCREMENT BY 1
+        NO MINVALUE
+        NO MAXVALUE
+        CACHE 1
+    ),
+    user_id integer NOT NULL,
+    position integer NOT NULL,
+    enabled boolean DEFAULT true NOT NULL,
+    field text NOT NULL,
+    match_type text NOT NULL,
+    pattern text NOT NULL,
+    action text NOT NULL,
+    target_field text DEFAULT '' NOT NULL,
+    replacement text DEFAULT '' NOT NULL,
+    created_at timestamptz DEFAULT now() NOT NULL,
+    CONSTRAINT rewrite_rules_pkey PRIMARY KEY (id),
+    CONSTRAINT rewrite_rules_field_check CHECK (field IN ('artist', 'track', 'release', 'client')),
+    CONSTRAINT rewrite_rules_target_field_check CHECK (target_field IN ('', 'artist', 'track', 'release', 'client')),
+    CONSTRAINT rewrite_rules_match_type_check CHECK (match_type IN ('exact', 'regex')),
+    CONSTRAINT rewrite_rules_action_check CHECK (action IN ('rewrite', 'strip_suffix', 'split_artists', 'drop'))
+);
+
+ALTER TABLE ONLY rewrite_rules
+    ADD CONSTRAINT rewrite_rules_user_id_fkey FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE;
+
+CREATE INDEX idx_rewrite_rules_user_id_position ON rewrite_rules USING btree (user_id, position);
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+DROP TABLE IF EXISTS rewrite_rules CASCADE;
+
+-- +goose StatementEnd
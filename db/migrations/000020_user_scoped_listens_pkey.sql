@@ -0,0 +1,30 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- Listens are unique per user, so that two users playing the same track at the same
+-- time both keep their listen. Ordering the key by time lets per-user history and
+-- export pages be read straight from the index.
+ALTER TABLE listens DROP CONSTRAINT listens_pkey;
+ALTER TABLE listens ADD CONSTRAINT listens_pkey PRIMARY KEY (user_id, listened_at, track_id);
+
+-- covered by the primary key
+DROP INDEX IF EXISTS idx_listens_user_id;
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+-- only one listen per track and time can be kept once the key is no longer per user
+DELETE FROM listens l
+USING listens x
+WHERE l.track_id = x.track_id
+  AND l.listened_at = x.listened_at
+  AND l.user_id > x.user_id;
+
+ALTER TABLE listens DROP CONSTRAINT listens_pkey;
+ALTER TABLE listens ADD CONSTRAINT listens_pkey PRIMARY KEY (track_id, listened_at);
+
+CREATE INDEX IF NOT EXISTS idx_listens_user_id ON listens (user_id);
+
+-- +goose StatementEnd
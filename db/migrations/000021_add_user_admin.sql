@@ -0,0 +1,44 @@
+-- +goose Up
+-- +goose StatementBegin
+
+ALTER TABLE users
+    ADD COLUMN disabled boolean DEFAULT false NOT NULL,
+    ADD COLUMN created_at timestamptz DEFAULT now() NOT NULL;
+
+CREATE TABLE user_invites (
+    id integer NOT NULL GENERATED ALWAYS AS IDENTITY (
+        SEQUENCE NAME user_invites_id_seq
+        START WITH 1
+        INCREMENT BY 1
+        NO MINVALUE
+        NO MAXVALUE
+        CACHE 1
+    ),
+    token text NOT NULL,
+    role role DEFAULT 'user'::role NOT NULL,
+    created_by integer,
+    created_at timestamptz DEFAULT now() NOT NULL,
+    expires_at timestamptz NOT NULL,
+    used_by integer,
+    used_at timestamptz,
+    CONSTRAINT user_invites_pkey PRIMARY KEY (id),
+    CONSTRAINT user_invites_token_key UNIQUE (token)
+);
+
+ALTER TABLE ONLY user_invites
+    ADD CONSTRAINT user_invites_created_by_fkey FOREIGN KEY (created_by) REFERENCES users(id) ON DELETE SET NULL;
+ALTER TABLE ONLY user_invites
+    ADD CONSTRAINT user_invites_used_by_fkey FOREIGN KEY (used_by) REFERENCES users(id) ON DELETE SET NULL;
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+DROP TABLE IF EXISTS user_invites CASCADE;
+
+ALTER TABLE users
+    DROP COLUMN IF EXISTS created_at,
+    DROP COLUMN IF EXISTS disabled;
+
+-- +goose StatementEnd
@@ -0,0 +1,17 @@
+-- +goose Up
+-- +goose StatementBegin
+
+ALTER TABLE users
+    ADD COLUMN visibility text DEFAULT 'private' NOT NULL,
+    ADD CONSTRAINT users_visibility_check CHECK (visibility IN ('public', 'unlisted', 'private'));
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+ALTER TABLE users
+    DROP CONSTRAINT IF EXISTS users_visibility_check,
+    DROP COLUMN IF EXISTS visibility;
+
+-- +goose StatementEnd
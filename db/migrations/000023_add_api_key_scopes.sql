@@ -0,0 +1,24 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- existing keys keep the access they had before scopes were introduced
+ALTER TABLE api_keys
+    ADD COLUMN scope text DEFAULT 'full' NOT NULL,
+    ADD COLUMN expires_at timestamptz,
+    ADD COLUMN last_used_at timestamptz,
+    ADD COLUMN last_used_ip text,
+    ADD CONSTRAINT api_keys_scope_check CHECK (scope IN ('submit', 'read', 'full'));
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+ALTER TABLE api_keys
+    DROP CONSTRAINT IF EXISTS api_keys_scope_check,
+    DROP COLUMN IF EXISTS last_used_ip,
+    DROP COLUMN IF EXISTS last_used_at,
+    DROP COLUMN IF EXISTS expires_at,
+    DROP COLUMN IF EXISTS scope;
+
+-- +goose StatementEnd
@@ -0,0 +1,32 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- keys are stored as the hex encoded SHA-256 of the key, with the first few characters kept so
+-- that users can tell their keys apart. The Audioscrobbler 1.2 handshake needs md5(key), so that
+-- is kept as well.
+ALTER TABLE api_keys
+    ADD COLUMN prefix text,
+    ADD COLUMN legacy_digest text;
+
+UPDATE api_keys SET
+    prefix = left(key, 8),
+    legacy_digest = md5(key),
+    key = encode(sha256(convert_to(key, 'UTF8')), 'hex');
+
+ALTER TABLE api_keys ALTER COLUMN prefix SET NOT NULL;
+ALTER TABLE api_keys RENAME COLUMN key TO key_hash;
+ALTER TABLE api_keys RENAME CONSTRAINT api_keys_key_key TO api_keys_key_hash_key;
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+-- hashed keys can't be recovered, so keys created before this migration stop working
+ALTER TABLE api_keys RENAME CONSTRAINT api_keys_key_hash_key TO api_keys_key_key;
+ALTER TABLE api_keys RENAME COLUMN key_hash TO key;
+ALTER TABLE api_keys
+    DROP COLUMN IF EXISTS legacy_digest,
+    DROP COLUMN IF EXISTS prefix;
+
+-- +goose StatementEnd
@@ -0,0 +1,17 @@
+-- +goose Up
+-- +goose StatementBegin
+
+ALTER TABLE users
+    ADD COLUMN oidc_subject text,
+    ADD CONSTRAINT users_oidc_subject_key UNIQUE (oidc_subject);
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+ALTER TABLE users
+    DROP CONSTRAINT IF EXISTS users_oidc_subject_key,
+    DROP COLUMN IF EXISTS oidc_subject;
+
+-- +goose StatementEnd
@@ -0,0 +1,23 @@
+-- +goose Up
+-- +goose StatementBegin
+
+ALTER TABLE users
+    ADD COLUMN totp_secret text,
+    ADD COLUMN totp_enabled boolean NOT NULL DEFAULT false,
+    -- the time step of the last accepted code, so that a code can't be used twice
+    ADD COLUMN totp_last_step bigint,
+    -- sha256 hex digests of the unused recovery codes
+    ADD COLUMN totp_recovery_codes text[] NOT NULL DEFAULT '{}';
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+ALTER TABLE users
+    DROP COLUMN IF EXISTS totp_recovery_codes,
+    DROP COLUMN IF EXISTS totp_last_step,
+    DROP COLUMN IF EXISTS totp_enabled,
+    DROP COLUMN IF EXISTS totp_secret;
+
+-- +goose StatementEnd
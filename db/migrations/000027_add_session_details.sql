@@ -0,0 +1,25 @@
+-- +goose Up
+-- +goose StatementBegin
+
+ALTER TABLE sessions
+    ADD COLUMN user_agent text NOT NULL DEFAULT '',
+    ADD COLUMN ip text NOT NULL DEFAULT '',
+    ADD COLUMN last_seen_at timestamptz DEFAULT now() NOT NULL;
+
+UPDATE sessions SET last_seen_at = created_at;
+
+CREATE INDEX IF NOT EXISTS idx_sessions_expires_at ON sessions (expires_at);
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+DROP INDEX IF EXISTS idx_sessions_expires_at;
+
+ALTER TABLE sessions
+    DROP COLUMN IF EXISTS last_seen_at,
+    DROP COLUMN IF EXISTS ip,
+    DROP COLUMN IF EXISTS user_agent;
+
+-- +goose StatementEnd
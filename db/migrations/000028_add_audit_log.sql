@@ -0,0 +1,46 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- user_id is deliberately not a foreign key, so that entries outlive the users who made them
+CREATE TABLE audit_log (
+    id bigint NOT NULL GENERATED ALWAYS AS IDENTITY (
+        SEQUENCE NAME audit_log_id_seq
+        START WITH 1
+        INCREMENT BY 1
+        NO MINVALUE
+        NO MAXVALUE
+        CACHE 1
+    ),
+    user_id integer,
+    username text DEFAULT '' NOT NULL,
+    action text NOT NULL,
+    target_type text NOT NULL,
+    target_ids integer[] DEFAULT '{}' NOT NULL,
+    before jsonb,
+    after jsonb,
+    created_at timestamptz DEFAULT now() NOT NULL,
+    CONSTRAINT audit_log_pkey PRIMARY KEY (id)
+);
+
+CREATE INDEX idx_audit_log_target_ids ON audit_log USING gin (target_ids);
+
+CREATE FUNCTION audit_log_append_only()
+RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_log is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER audit_log_append_only
+BEFORE UPDATE OR DELETE ON audit_log
+FOR EACH ROW EXECUTE FUNCTION audit_log_append_only();
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+DROP TABLE IF EXISTS audit_log CASCADE;
+DROP FUNCTION IF EXISTS audit_log_append_only();
+
+-- +goose StatementEnd
@@ -0,0 +1,34 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- Everything needed to undo a merge: the rows of the merged away item, and what the merge
+-- changed on the item it was merged into
+CREATE TABLE merge_snapshots (
+    id integer NOT NULL GENERATED ALWAYS AS IDENTITY (
+        SEQUENCE NAME merge_snapshots_id_seq
+        START WITH 1
+        INCREMENT BY 1
+        NO MINVALUE
+        NO MAXVALUE
+        CACHE 1
+    ),
+    kind text NOT NULL,
+    from_id integer NOT NULL,
+    to_id integer NOT NULL,
+    snapshot jsonb NOT NULL,
+    created_at timestamptz DEFAULT now() NOT NULL,
+    undone_at timestamptz,
+    CONSTRAINT merge_snapshots_pkey PRIMARY KEY (id),
+    CONSTRAINT merge_snapshots_kind_check CHECK (kind IN ('artist', 'album', 'track'))
+);
+
+CREATE INDEX idx_merge_snapshots_kind_from_id ON merge_snapshots USING btree (kind, from_id);
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+DROP TABLE IF EXISTS merge_snapshots CASCADE;
+
+-- +goose StatementEnd
@@ -0,0 +1,34 @@
+-- +goose Up
+ALTER TABLE artist_tracks
+ADD COLUMN role text NOT NULL DEFAULT 'main'
+    CONSTRAINT artist_tracks_role_check CHECK (role IN ('main', 'featured', 'remixer'));
+
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION get_artists_for_track(track_id INTEGER)
+RETURNS JSONB AS $$
+    SELECT json_agg(
+        jsonb_build_object('id', a.id, 'name', a.name, 'role', at.role)
+        ORDER BY at.is_primary DESC, at.role = 'main' DESC, a.name
+    )
+    FROM artist_tracks at
+    JOIN artists_with_name a ON a.id = at.artist_id
+    WHERE at.track_id = $1;
+$$ LANGUAGE sql STABLE;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION get_artists_for_track(track_id INTEGER)
+RETURNS JSONB AS $$
+    SELECT json_agg(
+        jsonb_build_object('id', a.id, 'name', a.name)
+        ORDER BY at.is_primary DESC, a.name
+    )
+    FROM artist_tracks at
+    JOIN artists_with_name a ON a.id = at.artist_id
+    WHERE at.track_id = $1;
+$$ LANGUAGE sql STABLE;
+-- +goose StatementEnd
+
+ALTER TABLE artist_tracks
+DROP COLUMN role;
@@ -0,0 +1,60 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- Groups the editions of an album (e.g. the original, a deluxe edition and a remaster), like
+-- release groups on MusicBrainz
+CREATE TABLE release_groups (
+    id integer NOT NULL GENERATED ALWAYS AS IDENTITY (
+        SEQUENCE NAME release_groups_id_seq
+        START WITH 1
+        INCREMENT BY 1
+        NO MINVALUE
+        NO MAXVALUE
+        CACHE 1
+    ),
+    musicbrainz_id UUID UNIQUE,
+    title text NOT NULL,
+    CONSTRAINT release_groups_pkey PRIMARY KEY (id)
+);
+
+ALTER TABLE releases
+    ADD COLUMN release_group_id integer REFERENCES release_groups(id) ON DELETE SET NULL;
+
+CREATE INDEX idx_releases_release_group_id ON releases USING btree (release_group_id);
+
+DROP VIEW IF EXISTS releases_with_title;
+CREATE VIEW releases_with_title AS
+SELECT r.id,
+   r.musicbrainz_id,
+   r.image,
+   r.various_artists,
+   r.image_source,
+   r.musicbrainz_searched_at,
+   r.release_group_id,
+   ra.alias AS title
+FROM releases r
+JOIN release_aliases ra ON ra.release_id = r.id
+WHERE ra.is_primary = true;
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+DROP VIEW IF EXISTS releases_with_title;
+CREATE VIEW releases_with_title AS
+SELECT r.id,
+   r.musicbrainz_id,
+   r.image,
+   r.various_artists,
+   r.image_source,
+   r.musicbrainz_searched_at,
+   ra.alias AS title
+FROM releases r
+JOIN release_aliases ra ON ra.release_id = r.id
+WHERE ra.is_primary = true;
+
+ALTER TABLE releases DROP COLUMN IF EXISTS release_group_id;
+DROP TABLE IF EXISTS release_groups CASCADE;
+
+-- +goose StatementEnd
@@ -0,0 +1,22 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- 1.2 sessions are random tokens handed out by the handshake, stored as their SHA-256
+CREATE TABLE audioscrobbler_sessions (
+    token_hash text NOT NULL,
+    api_key_id integer NOT NULL,
+    created_at timestamptz DEFAULT now() NOT NULL,
+    CONSTRAINT audioscrobbler_sessions_pkey PRIMARY KEY (token_hash),
+    CONSTRAINT audioscrobbler_sessions_api_key_id_fkey FOREIGN KEY (api_key_id) REFERENCES api_keys(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_audioscrobbler_sessions_api_key_id ON audioscrobbler_sessions (api_key_id);
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+DROP TABLE IF EXISTS audioscrobbler_sessions;
+
+-- +goose StatementEnd
@@ -15,11 +15,12 @@ GROUP BY a.id, a.musicbrainz_id, a.image, a.image_source, a.name;
 -- name: GetTrackArtists :many
 SELECT
   a.*,
-  at.is_primary as is_primary
+  at.is_primary as is_primary,
+  at.role as role
 FROM artists_with_name a
 LEFT JOIN artist_tracks at ON a.id = at.artist_id
 WHERE at.track_id = $1
-GROUP BY a.id, a.musicbrainz_id, a.image, a.image_source, a.name, at.is_primary;
+GROUP BY a.id, a.musicbrainz_id, a.image, a.image_source, a.name, at.is_primary, at.role;
 
 -- name: GetArtistByImage :one
 SELECT * FROM artists WHERE image = $1 LIMIT 1;
@@ -85,6 +86,8 @@ FROM (
   JOIN artist_tracks at ON at.track_id = t.id
   JOIN artists_with_name a ON a.id = at.artist_id
   WHERE l.listened_at BETWEEN $1 AND $2
+    AND l.user_id = $5
+    AND at.role <> $6
   GROUP BY a.id, a.name, a.musicbrainz_id, a.image
 ) x
 ORDER BY x.listen_count DESC, x.id
@@ -105,6 +108,7 @@ FROM (
         FROM listens l
         JOIN tracks t ON l.track_id = t.id
         JOIN artist_tracks at ON t.id = at.track_id
+        WHERE l.user_id = $2
         GROUP BY at.artist_id
         ) x
     )
@@ -114,7 +118,9 @@ WHERE artist_id = $1;
 SELECT COUNT(DISTINCT at.artist_id) AS total_count
 FROM listens l
 JOIN artist_tracks at ON l.track_id = at.track_id
-WHERE l.listened_at BETWEEN $1 AND $2;
+WHERE l.listened_at BETWEEN $1 AND $2
+  AND l.user_id = $3
+  AND at.role <> $4;
 
 -- name: CountNewArtists :one
 SELECT COUNT(*) AS total_count
@@ -123,6 +129,7 @@ FROM (
   FROM listens l
   JOIN tracks t ON l.track_id = t.id
   JOIN artist_tracks at ON t.id = at.track_id
+  WHERE l.user_id = $3
   GROUP BY at.artist_id
   HAVING MIN(l.listened_at) BETWEEN $1 AND $2
 ) first_appearances;
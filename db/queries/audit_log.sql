@@ -0,0 +1,20 @@
+-- name: InsertAuditEntry :one
+INSERT INTO audit_log (user_id, username, action, target_type, target_ids, before, after)
+VALUES ($1, $2, $3, $4, $5, $6, $7)
+RETURNING *;
+
+-- name: GetAuditEntriesPaginated :many
+SELECT * FROM audit_log
+WHERE (@action::text = '' OR action = @action::text)
+  AND (@target_type::text = '' OR target_type = @target_type::text)
+  AND (@target_id::int = 0 OR target_ids @> ARRAY[@target_id::int])
+  AND (@user_id::int = 0 OR user_id = @user_id::int)
+ORDER BY id DESC
+LIMIT @limit_count::int OFFSET @offset_count::int;
+
+-- name: CountAuditEntries :one
+SELECT COUNT(*) FROM audit_log
+WHERE (@action::text = '' OR action = @action::text)
+  AND (@target_type::text = '' OR target_type = @target_type::text)
+  AND (@target_id::int = 0 OR target_ids @> ARRAY[@target_id::int])
+  AND (@user_id::int = 0 OR user_id = @user_id::int);
@@ -70,6 +70,7 @@ JOIN releases r ON t.release_id = r.id
 JOIN release_genres rg ON r.id = rg.release_id
 JOIN genres g ON rg.genre_id = g.id
 WHERE l.listened_at BETWEEN $1 AND $2
+  AND l.user_id = $3
 GROUP BY g.name
 ORDER BY listen_count DESC;
 
@@ -83,5 +84,6 @@ JOIN releases r ON t.release_id = r.id
 JOIN release_genres rg ON r.id = rg.release_id
 JOIN genres g ON rg.genre_id = g.id
 WHERE l.listened_at BETWEEN $1 AND $2
+  AND l.user_id = $3
 GROUP BY g.name
 ORDER BY seconds_listened DESC;
@@ -7,6 +7,7 @@ WITH bounds AS (
     JOIN tracks t ON t.id = l.track_id
     JOIN artist_tracks at ON at.track_id = t.id
     WHERE at.artist_id = $1
+      AND l.user_id = sqlc.arg(user_id)::int
 ),
 stats AS (
     SELECT
@@ -33,6 +34,7 @@ listen_indices AS (
     JOIN artist_tracks at ON at.track_id = t.id
     CROSS JOIN stats s
     WHERE at.artist_id = $1
+      AND l.user_id = sqlc.arg(user_id)::int
       AND s.start_time IS NOT NULL
 )
 SELECT
@@ -54,6 +56,7 @@ WITH bounds AS (
     FROM listens l
     JOIN tracks t ON t.id = l.track_id
     WHERE t.release_id = $1
+      AND l.user_id = sqlc.arg(user_id)::int
 ),
 stats AS (
     SELECT
@@ -79,6 +82,7 @@ listen_indices AS (
     JOIN tracks t ON t.id = l.track_id
     CROSS JOIN stats s
     WHERE t.release_id = $1
+      AND l.user_id = sqlc.arg(user_id)::int
       AND s.start_time IS NOT NULL
 )
 SELECT
@@ -100,6 +104,7 @@ WITH bounds AS (
     FROM listens l
     JOIN tracks t ON t.id = l.track_id
     WHERE t.id = $1
+      AND l.user_id = sqlc.arg(user_id)::int
 ),
 stats AS (
     SELECT
@@ -125,6 +130,7 @@ listen_indices AS (
     JOIN tracks t ON t.id = l.track_id
     CROSS JOIN stats s
     WHERE t.id = $1
+      AND l.user_id = sqlc.arg(user_id)::int
       AND s.start_time IS NOT NULL
 )
 SELECT
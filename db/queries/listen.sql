@@ -21,9 +21,61 @@ CROSS JOIN LATERAL (
     WHERE at.track_id = t.id
 ) artists
 WHERE l.listened_at BETWEEN $1 AND $2
+  AND l.user_id = $5
 ORDER BY l.listened_at DESC
 LIMIT $3 OFFSET $4;
 
+-- name: GetUserListensBefore :many
+-- The newest listens of a user before a time, for paging backwards through their listens.
+SELECT
+  l.*,
+  t.title AS track_title,
+  t.release_id AS release_id,
+  artists.artists
+FROM listens l
+JOIN tracks_with_title t ON l.track_id = t.id
+CROSS JOIN LATERAL (
+    SELECT json_agg(
+        jsonb_build_object('id', a.id, 'name', a.name)
+        ORDER BY at.is_primary DESC, a.name
+    ) AS artists
+    FROM artist_tracks at
+    JOIN artists_with_name a ON a.id = at.artist_id
+    WHERE at.track_id = t.id
+) artists
+WHERE l.user_id = @user_id
+  AND l.listened_at < @max_ts
+ORDER BY l.listened_at DESC
+LIMIT @limit_count;
+
+-- name: GetUserListensAfter :many
+-- The oldest listens of a user after a time, for paging forwards through their listens.
+SELECT
+  l.*,
+  t.title AS track_title,
+  t.release_id AS release_id,
+  artists.artists
+FROM listens l
+JOIN tracks_with_title t ON l.track_id = t.id
+CROSS JOIN LATERAL (
+    SELECT json_agg(
+        jsonb_build_object('id', a.id, 'name', a.name)
+        ORDER BY at.is_primary DESC, a.name
+    ) AS artists
+    FROM artist_tracks at
+    JOIN artists_with_name a ON a.id = at.artist_id
+    WHERE at.track_id = t.id
+) artists
+WHERE l.user_id = @user_id
+  AND l.listened_at > @min_ts
+ORDER BY l.listened_at ASC
+LIMIT @limit_count;
+
+-- name: CountUserListens :one
+SELECT COUNT(*) AS total_count
+FROM listens
+WHERE user_id = $1;
+
 -- name: GetLastListensFromArtistPaginated :many
 SELECT
   l.*,
@@ -35,6 +87,7 @@ JOIN tracks_with_title t ON l.track_id = t.id
 JOIN artist_tracks at ON t.id = at.track_id
 WHERE at.artist_id = $5
   AND l.listened_at BETWEEN $1 AND $2
+  AND l.user_id = $6
 ORDER BY l.listened_at DESC
 LIMIT $3 OFFSET $4;
 
@@ -45,6 +98,7 @@ FROM listens l
 JOIN tracks_with_title t ON l.track_id = t.id
 JOIN artist_tracks at ON t.id = at.track_id
 WHERE at.artist_id = $1
+  AND l.user_id = $2
 ORDER BY l.listened_at ASC
 LIMIT 1;
 
@@ -58,6 +112,7 @@ FROM listens l
 JOIN tracks_with_title t ON l.track_id = t.id
 WHERE l.listened_at BETWEEN $1 AND $2
   AND t.release_id = $5
+  AND l.user_id = $6
 ORDER BY l.listened_at DESC
 LIMIT $3 OFFSET $4;
 
@@ -67,6 +122,7 @@ SELECT
 FROM listens l
 JOIN tracks t ON l.track_id = t.id
 WHERE t.release_id = $1
+  AND l.user_id = $2
 ORDER BY l.listened_at ASC
 LIMIT 1;
 
@@ -89,6 +145,7 @@ CROSS JOIN LATERAL (
 ) artists
 WHERE l.listened_at BETWEEN $1 AND $2
   AND t.id = $5
+  AND l.user_id = $6
 ORDER BY l.listened_at DESC
 LIMIT $3 OFFSET $4;
 
@@ -98,6 +155,7 @@ SELECT
 FROM listens l
 JOIN tracks t ON l.track_id = t.id
 WHERE t.id = $1
+  AND l.user_id = $2
 ORDER BY l.listened_at ASC
 LIMIT 1;
 
@@ -105,39 +163,45 @@ LIMIT 1;
 SELECT
   *
 FROM listens
+WHERE user_id = $1
 ORDER BY listened_at ASC
 LIMIT 1;
 
 -- name: CountListens :one
 SELECT COUNT(*) AS total_count
 FROM listens l
-WHERE l.listened_at BETWEEN $1 AND $2;
+WHERE l.listened_at BETWEEN $1 AND $2
+  AND l.user_id = $3;
 
 -- name: CountListensFromTrack :one
 SELECT COUNT(*) AS total_count
 FROM listens l
 WHERE l.listened_at BETWEEN $1 AND $2
-  AND l.track_id = $3;
+  AND l.track_id = $3
+  AND l.user_id = $4;
 
 -- name: CountListensFromArtist :one
 SELECT COUNT(*) AS total_count
 FROM listens l
 JOIN artist_tracks at ON l.track_id = at.track_id
 WHERE l.listened_at BETWEEN $1 AND $2
-  AND at.artist_id = $3;
+  AND at.artist_id = $3
+  AND l.user_id = $4;
 
 -- name: CountListensFromRelease :one
 SELECT COUNT(*) AS total_count
 FROM listens l
 JOIN tracks t ON l.track_id = t.id
 WHERE l.listened_at BETWEEN $1 AND $2
-  AND t.release_id = $3;
+  AND t.release_id = $3
+  AND l.user_id = $4;
 
 -- name: CountTimeListened :one
 SELECT COALESCE(SUM(t.duration), 0)::BIGINT AS seconds_listened
 FROM listens l
 JOIN tracks t ON l.track_id = t.id
-WHERE l.listened_at BETWEEN $1 AND $2;
+WHERE l.listened_at BETWEEN $1 AND $2
+  AND l.user_id = $3;
 
 -- name: CountTimeListenedToArtist :one
 SELECT COALESCE(SUM(t.duration), 0)::BIGINT AS seconds_listened
@@ -145,21 +209,24 @@ FROM listens l
 JOIN tracks t ON l.track_id = t.id
 JOIN artist_tracks at ON t.id = at.track_id
 WHERE l.listened_at BETWEEN $1 AND $2
-  AND at.artist_id = $3;
+  AND at.artist_id = $3
+  AND l.user_id = $4;
 
 -- name: CountTimeListenedToRelease :one
 SELECT COALESCE(SUM(t.duration), 0)::BIGINT AS seconds_listened
 FROM listens l
 JOIN tracks t ON l.track_id = t.id
 WHERE l.listened_at BETWEEN $1 AND $2
-  AND t.release_id = $3;
+  AND t.release_id = $3
+  AND l.user_id = $4;
 
 -- name: CountTimeListenedToTrack :one
 SELECT COALESCE(SUM(t.duration), 0)::BIGINT AS seconds_listened
 FROM listens l
 JOIN tracks t ON l.track_id = t.id
 WHERE l.listened_at BETWEEN $1 AND $2
-  AND t.id = $3;
+  AND t.id = $3
+  AND l.user_id = $4;
 
 -- name: ListenActivity :many
 SELECT
@@ -168,6 +235,7 @@ SELECT
 FROM listens
 WHERE listened_at >= $2
 AND listened_at < $3
+AND user_id = $4
 GROUP BY day
 ORDER BY day;
 
@@ -181,6 +249,7 @@ JOIN artist_tracks at ON t.id = at.track_id
 WHERE l.listened_at >= $2
 AND l.listened_at < $3
 AND at.artist_id = $4
+AND l.user_id = $5
 GROUP BY day
 ORDER BY day;
 
@@ -193,6 +262,7 @@ JOIN tracks t ON l.track_id = t.id
 WHERE l.listened_at >= $2
 AND l.listened_at < $3
 AND t.release_id = $4
+AND l.user_id = $5
 GROUP BY day
 ORDER BY day;
 
@@ -205,15 +275,24 @@ JOIN tracks t ON l.track_id = t.id
 WHERE l.listened_at >= $2
 AND l.listened_at < $3
 AND t.id = $4
+AND l.user_id = $5
 GROUP BY day
 ORDER BY day;
 
--- name: UpdateTrackIdForListens :exec
-UPDATE listens SET track_id = $2
-WHERE track_id = $1;
+-- name: CopyListensToTrack :execrows
+-- copies the listens of a track onto another, skipping those the user already has of the
+-- other track at the same time
+INSERT INTO listens (track_id, listened_at, user_id, client)
+SELECT @to_id::int, l.listened_at, l.user_id, l.client
+FROM listens l
+WHERE l.track_id = @from_id::int
+ON CONFLICT DO NOTHING;
+
+-- name: DeleteListensForTrack :execrows
+DELETE FROM listens WHERE track_id = $1;
 
 -- name: DeleteListen :exec
-DELETE FROM listens WHERE track_id = $1 AND listened_at = $2;
+DELETE FROM listens WHERE user_id = $1 AND track_id = $2 AND listened_at = $3;
 
 -- name: GetListensExportPage :many
 SELECT
@@ -258,6 +337,8 @@ SELECT
             'musicbrainz_id', a.musicbrainz_id,
             'image', a.image,
             'image_source', a.image_source,
+            'is_primary', at.is_primary,
+            'role', at.role,
             'aliases', (
                 SELECT json_agg(json_build_object(
                     'alias', aa.alias,
@@ -281,3 +362,42 @@ WHERE l.user_id = @user_id::int
   AND (l.listened_at, l.track_id) > (@listened_at::timestamptz, @track_id::int)
 ORDER BY l.listened_at, l.track_id
 LIMIT $1;
+
+-- name: GetUserListensToTrackInRange :many
+SELECT track_id, listened_at, user_id, client FROM listens
+WHERE user_id = $1
+  AND track_id = $2
+  AND listened_at BETWEEN $3 AND $4
+ORDER BY listened_at;
+
+-- name: LockUserTrackListens :exec
+-- Serializes submissions of listens to a track by a user until the end of the transaction.
+SELECT pg_advisory_xact_lock(@user_id::int, @track_id::int);
+
+-- name: GetDuplicateListenCandidates :many
+SELECT
+    x.user_id,
+    x.track_id,
+    x.title,
+    x.listened_at,
+    x.client,
+    x.window_seconds
+FROM (
+    SELECT
+        l.user_id,
+        l.track_id,
+        t.title,
+        l.listened_at,
+        l.client,
+        (CASE WHEN sqlc.arg(by_duration)::boolean AND t.duration > 0
+            THEN t.duration / 2.0
+            ELSE sqlc.arg(window_seconds)::float8
+        END)::float8 AS window_seconds,
+        LAG(l.listened_at) OVER (PARTITION BY l.user_id, l.track_id ORDER BY l.listened_at) AS prev_at,
+        LEAD(l.listened_at) OVER (PARTITION BY l.user_id, l.track_id ORDER BY l.listened_at) AS next_at
+    FROM listens l
+    JOIN tracks_with_title t ON t.id = l.track_id
+) x
+WHERE EXTRACT(EPOCH FROM x.listened_at - x.prev_at) < x.window_seconds
+   OR EXTRACT(EPOCH FROM x.next_at - x.listened_at) < x.window_seconds
+ORDER BY x.user_id, x.track_id, x.listened_at;
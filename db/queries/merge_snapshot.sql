@@ -0,0 +1,181 @@
+-- name: InsertMergeSnapshot :exec
+INSERT INTO merge_snapshots (kind, from_id, to_id, snapshot)
+VALUES ($1, $2, $3, $4);
+
+-- name: GetLatestMergeSnapshot :one
+SELECT * FROM merge_snapshots
+WHERE kind = $1 AND from_id = $2 AND undone_at IS NULL
+ORDER BY id DESC
+LIMIT 1;
+
+-- name: MarkMergeSnapshotUndone :exec
+UPDATE merge_snapshots SET undone_at = NOW() WHERE id = $1;
+
+-- name: SnapshotArtists :one
+SELECT COALESCE(jsonb_agg(a), '[]')::jsonb FROM artists a WHERE a.id = ANY(@ids::int[]);
+
+-- name: SnapshotArtistAliases :one
+SELECT COALESCE(jsonb_agg(a), '[]')::jsonb FROM artist_aliases a WHERE a.artist_id = ANY(@ids::int[]);
+
+-- name: SnapshotArtistGenres :one
+SELECT COALESCE(jsonb_agg(g), '[]')::jsonb FROM artist_genres g WHERE g.artist_id = ANY(@ids::int[]);
+
+-- name: SnapshotReleases :one
+SELECT COALESCE(jsonb_agg(r), '[]')::jsonb FROM releases r WHERE r.id = ANY(@ids::int[]);
+
+-- name: SnapshotReleaseAliases :one
+SELECT COALESCE(jsonb_agg(a), '[]')::jsonb FROM release_aliases a WHERE a.release_id = ANY(@ids::int[]);
+
+-- name: SnapshotReleaseGenres :one
+SELECT COALESCE(jsonb_agg(g), '[]')::jsonb FROM release_genres g WHERE g.release_id = ANY(@ids::int[]);
+
+-- name: SnapshotTracks :one
+SELECT COALESCE(jsonb_agg(t), '[]')::jsonb FROM tracks t WHERE t.id = ANY(@ids::int[]);
+
+-- name: SnapshotTrackAliases :one
+SELECT COALESCE(jsonb_agg(a), '[]')::jsonb FROM track_aliases a WHERE a.track_id = ANY(@ids::int[]);
+
+-- name: SnapshotArtistTracks :one
+SELECT COALESCE(jsonb_agg(at), '[]')::jsonb FROM artist_tracks at
+WHERE at.artist_id = ANY(@artist_ids::int[]) OR at.track_id = ANY(@track_ids::int[]);
+
+-- name: SnapshotArtistReleases :one
+SELECT COALESCE(jsonb_agg(ar), '[]')::jsonb FROM artist_releases ar
+WHERE ar.artist_id = ANY(@artist_ids::int[]) OR ar.release_id = ANY(@release_ids::int[]);
+
+-- name: SnapshotDroppedListens :one
+-- the listens of a track that MergeTracks will drop, as the user already has a listen of the
+-- target track at the same time
+SELECT COALESCE(jsonb_agg(l), '[]')::jsonb FROM listens l
+WHERE l.track_id = @from_id::int
+  AND EXISTS (
+    SELECT 1 FROM listens x
+    WHERE x.user_id = l.user_id
+      AND x.listened_at = l.listened_at
+      AND x.track_id = @to_id::int
+  );
+
+-- name: SnapshotMovableListens :one
+-- the listens of a track that MergeTracks will move, rather than drop as duplicates
+SELECT COALESCE(jsonb_agg(l), '[]')::jsonb FROM listens l
+WHERE l.track_id = @from_id::int
+  AND NOT EXISTS (
+    SELECT 1 FROM listens x
+    WHERE x.user_id = l.user_id
+      AND x.listened_at = l.listened_at
+      AND x.track_id = @to_id::int
+  );
+
+-- name: SnapshotMovableArtistTracks :one
+-- the tracks of an artist that MergeArtists will move, rather than drop as duplicates
+SELECT COALESCE(jsonb_agg(at), '[]')::jsonb FROM artist_tracks at
+WHERE at.artist_id = @from_id::int
+  AND NOT EXISTS (
+    SELECT 1 FROM artist_tracks x WHERE x.artist_id = @to_id::int AND x.track_id = at.track_id
+  );
+
+-- name: SnapshotMovableArtistReleases :one
+SELECT COALESCE(jsonb_agg(ar), '[]')::jsonb FROM artist_releases ar
+WHERE ar.artist_id = @from_id::int
+  AND NOT EXISTS (
+    SELECT 1 FROM artist_releases x WHERE x.artist_id = @to_id::int AND x.release_id = ar.release_id
+  );
+
+-- name: RestoreArtists :many
+INSERT INTO artists OVERRIDING SYSTEM VALUE
+SELECT (r).* FROM jsonb_populate_recordset(NULL::artists, @data::jsonb) r
+ON CONFLICT DO NOTHING
+RETURNING id;
+
+-- name: RestoreArtistAliases :exec
+INSERT INTO artist_aliases
+SELECT (r).* FROM jsonb_populate_recordset(NULL::artist_aliases, @data::jsonb) r
+WHERE r.artist_id = ANY(@ids::int[])
+ON CONFLICT DO NOTHING;
+
+-- name: RestoreArtistGenres :exec
+INSERT INTO artist_genres
+SELECT (r).* FROM jsonb_populate_recordset(NULL::artist_genres, @data::jsonb) r
+WHERE r.artist_id = ANY(@ids::int[])
+  AND r.genre_id IN (SELECT id FROM genres)
+ON CONFLICT DO NOTHING;
+
+-- name: RestoreReleases :many
+INSERT INTO releases OVERRIDING SYSTEM VALUE
+SELECT (r).* FROM jsonb_populate_recordset(NULL::releases, @data::jsonb) r
+ON CONFLICT DO NOTHING
+RETURNING id;
+
+-- name: RestoreReleaseAliases :exec
+INSERT INTO release_aliases
+SELECT (r).* FROM jsonb_populate_recordset(NULL::release_aliases, @data::jsonb) r
+WHERE r.release_id = ANY(@ids::int[])
+ON CONFLICT DO NOTHING;
+
+-- name: RestoreReleaseGenres :exec
+INSERT INTO release_genres
+SELECT (r).* FROM jsonb_populate_recordset(NULL::release_genres, @data::jsonb) r
+WHERE r.release_id = ANY(@ids::int[])
+  AND r.genre_id IN (SELECT id FROM genres)
+ON CONFLICT DO NOTHING;
+
+-- name: RestoreTracks :many
+INSERT INTO tracks OVERRIDING SYSTEM VALUE
+SELECT (r).* FROM jsonb_populate_recordset(NULL::tracks, @data::jsonb) r
+WHERE r.release_id IN (SELECT id FROM releases)
+ON CONFLICT DO NOTHING
+RETURNING id;
+
+-- name: RestoreTrackAliases :exec
+INSERT INTO track_aliases
+SELECT (r).* FROM jsonb_populate_recordset(NULL::track_aliases, @data::jsonb) r
+WHERE r.track_id = ANY(@ids::int[])
+ON CONFLICT DO NOTHING;
+
+-- name: RestoreArtistTracks :exec
+INSERT INTO artist_tracks
+SELECT (r).* FROM jsonb_populate_recordset(NULL::artist_tracks, @data::jsonb) r
+WHERE r.artist_id IN (SELECT id FROM artists)
+  AND r.track_id IN (SELECT id FROM tracks)
+ON CONFLICT DO NOTHING;
+
+-- name: RestoreArtistReleases :exec
+INSERT INTO artist_releases
+SELECT (r).* FROM jsonb_populate_recordset(NULL::artist_releases, @data::jsonb) r
+WHERE r.artist_id IN (SELECT id FROM artists)
+  AND r.release_id IN (SELECT id FROM releases)
+ON CONFLICT DO NOTHING;
+
+-- name: RestoreListens :exec
+INSERT INTO listens
+SELECT (r).* FROM jsonb_populate_recordset(NULL::listens, @data::jsonb) r
+WHERE r.track_id IN (SELECT id FROM tracks)
+  AND r.user_id IN (SELECT id FROM users)
+ON CONFLICT DO NOTHING;
+
+-- name: UnmoveListens :exec
+-- removes listens that a merge moved onto to_id, so that they can be restored to their track
+DELETE FROM listens l
+USING jsonb_populate_recordset(NULL::listens, @data::jsonb) r
+WHERE l.track_id = @to_id::int
+  AND l.user_id = r.user_id
+  AND l.listened_at = r.listened_at;
+
+-- name: UnmoveArtistTracks :exec
+DELETE FROM artist_tracks at
+USING jsonb_populate_recordset(NULL::artist_tracks, @data::jsonb) r
+WHERE at.artist_id = @to_id::int
+  AND at.track_id = r.track_id;
+
+-- name: UnmoveArtistReleases :exec
+DELETE FROM artist_releases ar
+USING jsonb_populate_recordset(NULL::artist_releases, @data::jsonb) r
+WHERE ar.artist_id = @to_id::int
+  AND ar.release_id = r.release_id;
+
+-- name: UnmoveTracks :exec
+UPDATE tracks SET release_id = @from_id::int
+WHERE id = ANY(@ids::int[]) AND release_id = @to_id::int;
+
+-- name: GetTrackIDsForRelease :many
+SELECT id FROM tracks WHERE release_id = $1 ORDER BY id;
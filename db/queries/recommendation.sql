@@ -6,6 +6,7 @@ WITH past_stats AS (
         MAX(listened_at) AS last_listened_at
     FROM listens l
     WHERE l.listened_at BETWEEN $1 AND $2
+      AND l.user_id = $4
     GROUP BY track_id
     HAVING COUNT(*) >= 5
 ),
@@ -13,6 +14,7 @@ recent_listens AS (
     SELECT DISTINCT track_id
     FROM listens
     WHERE listened_at > $2
+      AND user_id = $4
 )
 SELECT 
     t.id AS track_id,
@@ -0,0 +1,77 @@
+-- name: InsertRelayEntry :one
+INSERT INTO relay_outbox (user_id, target_id, payload)
+VALUES ($1, $2, $3)
+RETURNING *;
+
+-- name: GetRelayEntry :one
+SELECT * FROM relay_outbox WHERE id = $1;
+
+-- name: GetDueRelayEntries :many
+SELECT * FROM relay_outbox
+WHERE status = 'pending' AND next_attempt_at <= NOW()
+ORDER BY id
+LIMIT $1;
+
+-- name: GetRelayEntriesPaginated :many
+SELECT * FROM relay_outbox
+WHERE (@status::text = '' OR status = @status::text)
+ORDER BY id DESC
+LIMIT @limit_count::int OFFSET @offset_count::int;
+
+-- name: CountRelayEntries :one
+SELECT COUNT(*) FROM relay_outbox
+WHERE (@status::text = '' OR status = @status::text);
+
+-- name: UpdateRelayEntryAttempt :exec
+UPDATE relay_outbox
+SET status = $2, attempts = $3, next_attempt_at = $4, last_error = $5, updated_at = NOW()
+WHERE id = $1;
+
+-- name: RetryRelayEntry :execrows
+UPDATE relay_outbox
+SET status = 'pending', attempts = 0, next_attempt_at = NOW(), updated_at = NOW()
+WHERE id = $1;
+
+-- name: RetryDeadRelayEntries :execrows
+UPDATE relay_outbox
+SET status = 'pending', attempts = 0, next_attempt_at = NOW(), updated_at = NOW()
+WHERE status = 'dead';
+
+-- name: DeleteRelayEntry :execrows
+DELETE FROM relay_outbox WHERE id = $1;
+
+-- name: DeleteRelayEntriesByStatus :execrows
+DELETE FROM relay_outbox WHERE status = $1;
+
+-- name: InsertRelayTarget :one
+INSERT INTO relay_targets (
+    user_id, kind, label, url, token, api_key, api_secret,
+    enabled, relay_imports, relay_playing_now, include_clients, exclude_clients
+)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+RETURNING *;
+
+-- name: GetRelayTarget :one
+SELECT * FROM relay_targets WHERE id = $1;
+
+-- name: GetRelayTargetsByUserID :many
+SELECT * FROM relay_targets
+WHERE user_id = $1
+ORDER BY id;
+
+-- name: UpdateRelayTarget :execrows
+UPDATE relay_targets SET
+    label = $3,
+    url = $4,
+    token = $5,
+    api_key = $6,
+    api_secret = $7,
+    enabled = $8,
+    relay_imports = $9,
+    relay_playing_now = $10,
+    include_clients = $11,
+    exclude_clients = $12
+WHERE id = $1 AND user_id = $2;
+
+-- name: DeleteRelayTarget :execrows
+DELETE FROM relay_targets WHERE id = $1 AND user_id = $2;
@@ -60,6 +60,7 @@ FROM (
     JOIN artist_releases ar ON r.id = ar.release_id
     WHERE ar.artist_id = $5
     AND l.listened_at BETWEEN $1 AND $2
+    AND l.user_id = $6
     GROUP BY r.id, r.title, r.musicbrainz_id, r.various_artists, r.image, r.image_source
 ) x
 ORDER BY listen_count DESC, x.id
@@ -78,6 +79,7 @@ FROM (
     JOIN tracks t ON l.track_id = t.id
     JOIN releases_with_title r ON t.release_id = r.id
     WHERE l.listened_at BETWEEN $1 AND $2
+    AND l.user_id = $5
     GROUP BY r.id, r.title, r.musicbrainz_id, r.various_artists, r.image, r.image_source
 ) x
 ORDER BY listen_count DESC, x.id
@@ -97,6 +99,7 @@ FROM (
             COUNT(*) AS listen_count
         FROM listens l
         JOIN tracks t ON l.track_id = t.id
+        WHERE l.user_id = $2
         GROUP BY t.release_id
         ) x
     )
@@ -107,7 +110,8 @@ SELECT COUNT(DISTINCT r.id) AS total_count
 FROM listens l
 JOIN tracks t ON l.track_id = t.id
 JOIN releases r ON t.release_id = r.id
-WHERE l.listened_at BETWEEN $1 AND $2;
+WHERE l.listened_at BETWEEN $1 AND $2
+  AND l.user_id = $3;
 
 -- name: CountReleasesFromArtist :one
 SELECT COUNT(*)
@@ -121,6 +125,7 @@ FROM (
   SELECT t.release_id
   FROM listens l
   JOIN tracks t ON l.track_id = t.id
+  WHERE l.user_id = $3
   GROUP BY t.release_id
   HAVING MIN(l.listened_at) BETWEEN $1 AND $2
 ) first_appearances;
@@ -164,3 +169,114 @@ DELETE FROM releases r
 USING artist_releases ar
 WHERE ar.release_id = r.id
   AND ar.artist_id = $1;
+
+-- name: GetReleasesWithoutMbzID :many
+SELECT r.id, r.title, get_artists_for_release(r.id) AS artists
+FROM releases_with_title r
+WHERE r.musicbrainz_id IS NULL
+  AND r.musicbrainz_searched_at IS NULL
+  AND r.id > $2
+ORDER BY r.id ASC
+LIMIT $1;
+
+-- name: MarkMbzSearched :exec
+UPDATE releases SET musicbrainz_searched_at = NOW() WHERE id = $1;
+
+-- name: InsertReleaseGroup :one
+INSERT INTO release_groups (musicbrainz_id, title)
+VALUES ($1, $2)
+ON CONFLICT (musicbrainz_id) DO UPDATE SET musicbrainz_id = EXCLUDED.musicbrainz_id
+RETURNING *;
+
+-- name: GetReleaseGroup :one
+SELECT * FROM release_groups
+WHERE id = $1 LIMIT 1;
+
+-- name: GetReleaseGroupByMbzID :one
+SELECT * FROM release_groups
+WHERE musicbrainz_id = $1 LIMIT 1;
+
+-- name: GetReleaseGroupEditions :many
+SELECT
+  r.id,
+  r.musicbrainz_id,
+  r.title,
+  r.image,
+  r.various_artists,
+  r.release_group_id,
+  get_artists_for_release(r.id) AS artists,
+  (
+    SELECT COUNT(*)
+    FROM listens l
+    JOIN tracks t ON l.track_id = t.id
+    WHERE t.release_id = r.id
+      AND l.user_id = @user_id
+  ) AS listen_count
+FROM releases_with_title r
+WHERE r.release_group_id = @release_group_id::int
+ORDER BY listen_count DESC, r.id;
+
+-- name: UpdateReleaseReleaseGroup :exec
+UPDATE releases SET release_group_id = $2
+WHERE id = $1;
+
+-- name: UpdateReleaseReleaseGroupIfUnset :exec
+UPDATE releases SET release_group_id = $2
+WHERE id = $1 AND release_group_id IS NULL;
+
+-- name: GetTopReleaseGroupsPaginated :many
+-- Releases that are not part of a release group are their own group. Each group is
+-- represented by its most listened to edition.
+WITH editions AS (
+  SELECT
+    r.id,
+    r.release_group_id,
+    COUNT(*) AS listen_count
+  FROM listens l
+  JOIN tracks t ON l.track_id = t.id
+  JOIN releases r ON t.release_id = r.id
+  WHERE l.listened_at BETWEEN @listened_at_from AND @listened_at_to
+    AND l.user_id = @user_id
+    AND (@artist_id::int = 0 OR EXISTS (
+      SELECT 1 FROM artist_releases ar
+      WHERE ar.release_id = r.id AND ar.artist_id = @artist_id::int
+    ))
+  GROUP BY r.id
+), groups AS (
+  SELECT
+    (ARRAY_AGG(e.id ORDER BY e.listen_count DESC, e.id))[1]::int AS release_id,
+    SUM(e.listen_count)::bigint AS listen_count
+  FROM editions e
+  GROUP BY COALESCE(e.release_group_id, -e.id)
+)
+SELECT
+  r.id,
+  r.musicbrainz_id,
+  COALESCE(rg.title, r.title)::text AS title,
+  r.image,
+  r.various_artists,
+  r.release_group_id,
+  g.listen_count,
+  (
+    SELECT COUNT(*) FROM releases e
+    WHERE e.release_group_id = r.release_group_id
+  ) AS edition_count,
+  get_artists_for_release(r.id) AS artists,
+  RANK() OVER (ORDER BY g.listen_count DESC) AS rank
+FROM groups g
+JOIN releases_with_title r ON r.id = g.release_id
+LEFT JOIN release_groups rg ON rg.id = r.release_group_id
+ORDER BY g.listen_count DESC, r.id
+LIMIT @limit_count OFFSET @offset_count;
+
+-- name: CountTopReleaseGroups :one
+SELECT COUNT(DISTINCT COALESCE(r.release_group_id, -r.id)) AS total_count
+FROM listens l
+JOIN tracks t ON l.track_id = t.id
+JOIN releases r ON t.release_id = r.id
+WHERE l.listened_at BETWEEN @listened_at_from AND @listened_at_to
+  AND l.user_id = @user_id
+  AND (@artist_id::int = 0 OR EXISTS (
+    SELECT 1 FROM artist_releases ar
+    WHERE ar.release_id = r.id AND ar.artist_id = @artist_id::int
+  ));
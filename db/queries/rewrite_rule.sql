@@ -0,0 +1,31 @@
+-- name: InsertRewriteRule :one
+INSERT INTO rewrite_rules (user_id, position, enabled, field, match_type, pattern, action, target_field, replacement)
+VALUES (
+    $1,
+    (SELECT COALESCE(MAX(position), 0) + 1 FROM rewrite_rules WHERE user_id = $1),
+    $2, $3, $4, $5, $6, $7, $8
+)
+RETURNING *;
+
+-- name: GetRewriteRule :one
+SELECT * FROM rewrite_rules WHERE id = $1;
+
+-- name: GetRewriteRulesByUserID :many
+SELECT * FROM rewrite_rules
+WHERE user_id = $1
+ORDER BY position, id;
+
+-- name: UpdateRewriteRule :execrows
+UPDATE rewrite_rules SET
+    position = $3,
+    enabled = $4,
+    field = $5,
+    match_type = $6,
+    pattern = $7,
+    action = $8,
+    target_field = $9,
+    replacement = $10
+WHERE id = $1 AND user_id = $2;
+
+-- name: DeleteRewriteRule :execrows
+DELETE FROM rewrite_rules WHERE id = $1 AND user_id = $2;
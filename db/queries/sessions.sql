@@ -1,19 +1,56 @@
 -- name: InsertSession :one 
-INSERT INTO sessions (id, user_id, expires_at, persistent)
-VALUES ($1, $2, $3, $4)
+INSERT INTO sessions (id, user_id, expires_at, persistent, user_agent, ip)
+VALUES ($1, $2, $3, $4, $5, $6)
 RETURNING *;
 
 -- name: GetSession :one
 SELECT * FROM sessions WHERE id = $1 AND expires_at > NOW();
 
+-- name: GetSessionsByUserID :many
+SELECT * FROM sessions
+WHERE user_id = $1 AND expires_at > NOW()
+ORDER BY last_seen_at DESC;
+
 -- name: UpdateSessionExpiry :exec 
-UPDATE sessions SET expires_at = $2 WHERE id = $1;
+UPDATE sessions
+SET expires_at = $2, last_seen_at = NOW(), user_agent = $3, ip = $4
+WHERE id = $1;
 
 -- name: DeleteSession :exec
 DELETE FROM sessions WHERE id = $1;
 
+-- name: DeleteSessionForUser :execrows
+DELETE FROM sessions WHERE id = $1 AND user_id = $2;
+
+-- name: DeleteOtherSessionsForUser :execrows
+DELETE FROM sessions WHERE user_id = $1 AND id <> $2;
+
+-- name: DeleteExpiredSessions :execrows
+DELETE FROM sessions WHERE expires_at <= NOW();
+
 -- name: GetUserBySession :one
 SELECT * 
 FROM users u
 JOIN sessions s ON u.id = s.user_id 
-WHERE s.id = $1;
+WHERE s.id = $1 AND s.expires_at > NOW() AND NOT u.disabled;
+
+-- name: DeleteSessionsForUser :exec
+DELETE FROM sessions WHERE user_id = $1;
+
+-- name: InsertAudioscrobblerSession :exec
+INSERT INTO audioscrobbler_sessions (token_hash, api_key_id)
+VALUES ($1, $2);
+
+-- name: DeleteAudioscrobblerSessionsBefore :exec
+DELETE FROM audioscrobbler_sessions WHERE created_at <= @before::timestamptz;
+
+-- name: GetUserByAudioscrobblerSession :one
+SELECT u.*
+FROM users u
+JOIN api_keys ak ON u.id = ak.user_id
+JOIN audioscrobbler_sessions s ON ak.id = s.api_key_id
+WHERE s.token_hash = @token_hash::text
+  AND s.created_at > @not_before::timestamptz
+  AND NOT u.disabled
+  AND ak.scope <> 'read'
+  AND (ak.expires_at IS NULL OR ak.expires_at > NOW());
@@ -4,8 +4,8 @@ VALUES ($1, $2, $3)
 RETURNING *;
 
 -- name: AssociateArtistToTrack :exec
-INSERT INTO artist_tracks (artist_id, track_id, is_primary)
-VALUES ($1, $2, $3)
+INSERT INTO artist_tracks (artist_id, track_id, is_primary, role)
+VALUES ($1, $2, $3, $4)
 ON CONFLICT DO NOTHING;
 
 -- name: GetTrack :one
@@ -33,6 +33,7 @@ FROM tracks_with_title t
 JOIN artist_tracks at ON at.track_id = t.id
 WHERE t.title = $1
   AND at.artist_id = ANY($3::int[])
+  AND at.role = 'main'
   AND t.release_id = $2
 GROUP BY t.id, t.title, t.musicbrainz_id, t.duration, t.release_id
 HAVING COUNT(DISTINCT at.artist_id) = cardinality($3::int[]);
@@ -54,6 +55,7 @@ FROM (
         RANK() OVER (ORDER BY COUNT(*) DESC) as rank
     FROM listens
     WHERE listened_at BETWEEN $1 AND $2
+        AND user_id = $5
     GROUP BY track_id
     ORDER BY listen_count DESC
     LIMIT $3 OFFSET $4
@@ -81,6 +83,8 @@ FROM (
     JOIN artist_tracks at ON l.track_id = at.track_id
     WHERE l.listened_at BETWEEN $1 AND $2
         AND at.artist_id = $5
+        AND l.user_id = $6
+        AND at.role <> $7
     GROUP BY l.track_id
     ORDER BY listen_count DESC
     LIMIT $3 OFFSET $4
@@ -108,6 +112,7 @@ FROM (
     JOIN tracks t ON l.track_id = t.id
     WHERE l.listened_at BETWEEN $1 AND $2
         AND t.release_id = $5
+        AND l.user_id = $6
     GROUP BY l.track_id
     ORDER BY listen_count DESC
     LIMIT $3 OFFSET $4
@@ -130,6 +135,7 @@ FROM (
             COUNT(*) AS listen_count
         FROM listens l
         JOIN tracks_with_title t ON l.track_id = t.id
+        WHERE l.user_id = $2
         GROUP BY t.id) x
     ) y
 WHERE id = $1;
@@ -137,27 +143,32 @@ WHERE id = $1;
 -- name: CountTopTracks :one
 SELECT COUNT(DISTINCT l.track_id) AS total_count
 FROM listens l
-WHERE l.listened_at BETWEEN $1 AND $2;
+WHERE l.listened_at BETWEEN $1 AND $2
+  AND l.user_id = $3;
 
 -- name: CountTopTracksByArtist :one
 SELECT COUNT(DISTINCT l.track_id) AS total_count
 FROM listens l
 JOIN artist_tracks at ON l.track_id = at.track_id
 WHERE l.listened_at BETWEEN $1 AND $2
-AND at.artist_id = $3;
+AND at.artist_id = $3
+AND l.user_id = $4
+AND at.role <> $5;
 
 -- name: CountTopTracksByRelease :one
 SELECT COUNT(DISTINCT l.track_id) AS total_count
 FROM listens l
 JOIN tracks t ON l.track_id = t.id
 WHERE l.listened_at BETWEEN $1 AND $2
-AND t.release_id = $3;
+AND t.release_id = $3
+AND l.user_id = $4;
 
 -- name: CountNewTracks :one
 SELECT COUNT(*) AS total_count
 FROM (
   SELECT track_id
   FROM listens
+  WHERE user_id = $3
   GROUP BY track_id
   HAVING MIN(listened_at) BETWEEN $1 AND $2
 ) first_appearances;
@@ -174,6 +185,10 @@ WHERE id = $1;
 UPDATE tracks SET release_id = $2
 WHERE release_id = $1;
 
+-- name: UpdateTrackArtistRole :execrows
+UPDATE artist_tracks SET role = $3
+WHERE artist_id = $1 AND track_id = $2;
+
 -- name: UpdateTrackPrimaryArtist :exec
 UPDATE artist_tracks SET is_primary = $3
 WHERE artist_id = $1 AND track_id = $2;
@@ -190,3 +205,13 @@ WHERE duration = 0
   AND id > $2
 ORDER BY id ASC
 LIMIT $1;
+
+-- name: TracksWithoutDuration :many
+-- Get tracks that have a musicbrainz_id but no duration (or duration = 0)
+SELECT id, musicbrainz_id
+FROM tracks
+WHERE musicbrainz_id IS NOT NULL 
+  AND (duration IS NULL OR duration = 0)
+  AND id > $1
+ORDER BY id
+LIMIT 50;
@@ -9,12 +9,26 @@ DELETE FROM users WHERE id = $1;
 -- name: GetUserByUsername :one
 SELECT * FROM users WHERE username = $1;
 
+-- name: GetUserByID :one
+SELECT * FROM users WHERE id = $1;
+
+-- name: GetUserByOIDCSubject :one
+SELECT * FROM users WHERE oidc_subject = $1;
+
+-- name: GetUsers :many
+SELECT * FROM users ORDER BY id;
+
+-- name: GetPublicUsers :many
+SELECT * FROM users
+WHERE visibility = 'public' AND NOT disabled
+ORDER BY username;
+
 -- name: CountUsers :one
 SELECT COUNT(*) FROM users;
 
 -- name: InsertApiKey :one
-INSERT INTO api_keys (user_id, key, label)
-VALUES ($1, $2, $3)
+INSERT INTO api_keys (user_id, key_hash, prefix, legacy_digest, label, scope, expires_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7)
 RETURNING *;
 
 -- name: DeleteApiKey :exec
@@ -27,7 +41,15 @@ SELECT COUNT(*) FROM api_keys WHERE user_id = $1;
 SELECT u.* 
 FROM users u
 JOIN api_keys ak ON u.id = ak.user_id 
-WHERE ak.key = $1;
+WHERE ak.key_hash = $1 AND NOT u.disabled
+  AND (ak.expires_at IS NULL OR ak.expires_at > NOW());
+
+-- name: GetApiKeyByHash :one
+SELECT ak.*
+FROM api_keys ak
+JOIN users u ON ak.user_id = u.id
+WHERE ak.key_hash = $1 AND NOT u.disabled
+  AND (ak.expires_at IS NULL OR ak.expires_at > NOW());
 
 -- name: GetAllApiKeysByUserID :many
 SELECT ak.*
@@ -41,5 +63,62 @@ UPDATE users SET username = $2 WHERE id = $1;
 -- name: UpdateUserPassword :exec
 UPDATE users SET password = $2 WHERE id = $1;
 
+-- name: UpdateUserOIDCSubject :exec
+UPDATE users SET oidc_subject = $2 WHERE id = $1;
+
+-- name: SetUserTOTPSecret :exec
+UPDATE users
+SET totp_secret = $2, totp_enabled = false, totp_last_step = NULL, totp_recovery_codes = '{}'
+WHERE id = $1;
+
+-- name: EnableUserTOTP :exec
+UPDATE users
+SET totp_enabled = true, totp_recovery_codes = @recovery_codes::text[]
+WHERE id = $1 AND totp_secret IS NOT NULL;
+
+-- name: DisableUserTOTP :exec
+UPDATE users
+SET totp_secret = NULL, totp_enabled = false, totp_last_step = NULL, totp_recovery_codes = '{}'
+WHERE id = $1;
+
+-- name: UpdateUserTOTPStep :execrows
+UPDATE users SET totp_last_step = @step::bigint
+WHERE id = $1 AND (totp_last_step IS NULL OR totp_last_step < @step::bigint);
+
+-- name: UseTOTPRecoveryCode :execrows
+UPDATE users SET totp_recovery_codes = array_remove(totp_recovery_codes, @code_hash::text)
+WHERE id = $1 AND @code_hash::text = ANY(totp_recovery_codes);
+
+-- name: UpdateUserRole :exec
+UPDATE users SET role = $2 WHERE id = $1;
+
+-- name: UpdateUserDisabled :exec
+UPDATE users SET disabled = $2 WHERE id = $1;
+
+-- name: UpdateUserVisibility :exec
+UPDATE users SET visibility = $2 WHERE id = $1;
+
+-- name: InsertUserInvite :one
+INSERT INTO user_invites (token, role, created_by, expires_at)
+VALUES ($1, $2, $3, $4)
+RETURNING *;
+
+-- name: GetUserInvites :many
+SELECT * FROM user_invites ORDER BY created_at DESC;
+
+-- name: ClaimUserInvite :one
+UPDATE user_invites SET used_at = NOW()
+WHERE token = $1 AND used_at IS NULL AND expires_at > NOW()
+RETURNING *;
+
+-- name: UpdateUserInviteUsedBy :exec
+UPDATE user_invites SET used_by = $2 WHERE id = $1;
+
+-- name: DeleteUserInvite :exec
+DELETE FROM user_invites WHERE id = $1;
+
 -- name: UpdateApiKeyLabel :exec
 UPDATE api_keys SET label = $3 WHERE id = $1 AND user_id = $2;
+
+-- name: UpdateApiKeyLastUsed :exec
+UPDATE api_keys SET last_used_at = NOW(), last_used_ip = $2 WHERE id = $1;
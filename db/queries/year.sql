@@ -208,7 +208,8 @@ SELECT
     c.gap_days
 FROM ranked c
 JOIN artists_with_name awn ON awn.id = c.artist_id
-WHERE r = 1;
+WHERE r = 1
+  AND c.user_id = @user_id::int;
 
 -- name: GetFirstListenInYear :one
 SELECT 
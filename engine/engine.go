@@ -26,6 +26,9 @@ import (
 	"github.com/gabehf/koito/internal/logger"
 	mbz "github.com/gabehf/koito/internal/mbz"
 	"github.com/gabehf/koito/internal/models"
+	"github.com/gabehf/koito/internal/nowplaying"
+	"github.com/gabehf/koito/internal/oidc"
+	"github.com/gabehf/koito/internal/relay"
 	"github.com/gabehf/koito/internal/utils"
 
 	"github.com/go-chi/chi/v5"
@@ -35,6 +38,8 @@ import (
 	"github.com/gabehf/koito/engine/handlers"
 )
 
+const sessionCleanupInterval = time.Hour
+
 func Run(
 	getenv func(string) string,
 	w io.Writer,
@@ -134,6 +139,17 @@ func Run(
 		l.Warn().Msg("Engine: Last.fm client disabled")
 	}
 
+	var oidcP *oidc.Provider
+	if cfg.OIDCEnabled() {
+		oidcP = oidc.NewProvider(oidc.ProviderOpts{
+			Issuer:       cfg.OIDCIssuer(),
+			ClientID:     cfg.OIDCClientID(),
+			ClientSecret: cfg.OIDCClientSecret(),
+			Scopes:       cfg.OIDCScopes(),
+		})
+		l.Info().Msgf("Engine: OpenID Connect login enabled with issuer '%s'", cfg.OIDCIssuer())
+	}
+
 	l.Debug().Msg("Engine: Initializing image sources")
 	images.Initialize(images.ImageSourceOpts{
 		UserAgent:      cfg.UserAgent(),
@@ -175,6 +191,8 @@ func Run(
 			return fmt.Errorf("failed to save default API key: %w", err)
 		}
 		l.Info().Msgf("Engine: Default user created. Login: %s : %s", cfg.DefaultUsername(), cfg.DefaultPassword())
+		// only the hash of the key is stored, so this is the only time it can be shown
+		l.Info().Msgf("Engine: Default API key created: %s", apikey)
 	}
 
 	l.Debug().Msg("Engine: Checking allowed hosts configuration")
@@ -202,15 +220,18 @@ func Run(
 	mux.Use(middleware.WithRequestID)
 	mux.Use(middleware.Logger(l))
 	mux.Use(chimiddleware.Recoverer)
+	mux.Use(middleware.WithPeerAddr)
 	mux.Use(chimiddleware.RealIP)
 	mux.Use(middleware.AllowedHosts)
 	backfillController := handlers.NewBackfillController(ctx)
-	bindRoutes(mux, &ready, store, mbzC, discogsC, lastfmC, images.GetSpotifyClient(), backfillController)
+	bindRoutes(mux, &ready, store, mbzC, discogsC, lastfmC, images.GetSpotifyClient(), oidcP, backfillController)
 
 	httpServer := &http.Server{
 		Addr:    cfg.ListenAddr(),
 		Handler: mux,
 	}
+	// now playing streams stay open until their subscription ends
+	httpServer.RegisterOnShutdown(nowplaying.CloseSubscribers)
 
 	var goroutineWG sync.WaitGroup
 	runTrackedGoroutine := func(fn func()) {
@@ -274,6 +295,20 @@ func Run(
 		catalog.BackfillImages(logger.NewContext(l), store)
 	})
 
+	relayCtx, cancelRelay := context.WithCancel(logger.NewContext(l))
+	defer cancelRelay()
+	l.Info().Msg("Engine: Starting relay worker")
+	runTrackedGoroutine(func() {
+		relay.Run(relayCtx, store)
+	})
+
+	cleanupCtx, cancelCleanup := context.WithCancel(logger.NewContext(l))
+	defer cancelCleanup()
+	l.Info().Msg("Engine: Starting expired session cleanup")
+	runTrackedGoroutine(func() {
+		runSessionCleanup(cleanupCtx, store)
+	})
+
 	l.Info().Msg("Engine: Initialization finished")
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, os.Interrupt, syscall.SIGINT, syscall.SIGTERM)
@@ -284,6 +319,8 @@ func Run(
 	defer cancel()
 	l.Info().Msg("Engine: Waiting for all processes to finish")
 	backfillController.Cancel()
+	cancelRelay()
+	cancelCleanup()
 	mbzC.Shutdown()
 	if discogsC != nil {
 		discogsC.Shutdown()
@@ -356,3 +393,25 @@ func RunImporter(l *zerolog.Logger, store db.DB, mbzc mbz.MusicBrainzCaller) {
 		}
 	}
 }
+
+// runSessionCleanup periodically deletes sessions that have expired, until ctx is cancelled.
+func runSessionCleanup(ctx context.Context, store db.DB) {
+	l := logger.FromContext(ctx)
+	ticker := time.NewTicker(sessionCleanupInterval)
+	defer ticker.Stop()
+
+	for {
+		n, err := store.DeleteExpiredSessions(ctx)
+		if err != nil && ctx.Err() == nil {
+			l.Err(err).Msg("Engine: Failed to delete expired sessions")
+		} else if n > 0 {
+			l.Info().Msgf("Engine: Deleted %d expired sessions", n)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -14,11 +14,13 @@ import (
 	"github.com/gabehf/koito/engine"
 	"github.com/gabehf/koito/internal/cfg"
 	"github.com/gabehf/koito/internal/db/psql"
+	"github.com/gabehf/koito/internal/oidc/oidctest"
 	"github.com/gabehf/koito/internal/utils"
 	"github.com/ory/dockertest/v3"
 )
 
 var store *psql.Psql
+var issuer *oidctest.Issuer
 
 func getTestGetenv(resource *dockertest.Resource) func(string) string {
 	dir, err := utils.GenerateRandomString(8)
@@ -54,6 +56,20 @@ func getTestGetenv(resource *dockertest.Resource) func(string) string {
 			return "*"
 		case cfg.DISABLE_DEEZER_ENV, cfg.DISABLE_COVER_ART_ARCHIVE_ENV, cfg.DISABLE_MUSICBRAINZ_ENV, cfg.SKIP_IMPORT_ENV:
 			return "true"
+		case cfg.OIDC_ISSUER_ENV:
+			return issuer.URL
+		case cfg.OIDC_CLIENT_ID_ENV:
+			return issuer.ClientID
+		case cfg.OIDC_CLIENT_SECRET_ENV:
+			return issuer.ClientSecret
+		case cfg.OIDC_ADMIN_GROUP_ENV:
+			return "koito-admins"
+		case cfg.OIDC_AUTO_PROVISION_ENV:
+			return "true"
+		case cfg.TRUSTED_PROXY_HEADER_ENV:
+			return "Remote-User"
+		case cfg.TRUSTED_PROXIES_ENV:
+			return "127.0.0.1/8, ::1"
 		default:
 			return ""
 		}
@@ -84,6 +100,8 @@ func TestMain(m *testing.M) {
 		log.Fatalf("Could not start resource: %s", err)
 	}
 
+	issuer = oidctest.NewIssuer("koito", "oidc-secret")
+
 	getenv := getTestGetenv(resource)
 	err = cfg.Load(getenv, "test")
 	if err != nil {
@@ -129,12 +147,19 @@ func TestMain(m *testing.M) {
 		time.Sleep(1 * time.Second)
 	}
 
+	// most tests read the default user's statistics without logging in, which is only
+	// allowed when their profile is not private
+	if err := store.Exec(context.Background(), `UPDATE users SET visibility = 'public'`); err != nil {
+		log.Fatalf("Could not make the default user's profile public: %v", err)
+	}
+
 	code := m.Run()
 
 	// You can't defer this because os.Exit doesn't care for defer
 	if err := pool.Purge(resource); err != nil {
 		log.Fatalf("Could not purge resource: %s", err)
 	}
+	issuer.Close()
 
 	err = os.RemoveAll(cfg.ConfigDir())
 	if err != nil {
@@ -120,12 +120,14 @@ func DeleteAliasHandler(store db.DB) http.HandlerFunc {
 				utils.WriteError(w, "invalid artist_id", http.StatusBadRequest)
 				return
 			}
+			before := auditAliases(ctx, store, models.AuditTargetArtist, int32(artistID))
 			err = store.DeleteArtistAlias(ctx, int32(artistID), alias)
 			if err != nil {
 				l.Error().Err(err).Msg("DeleteAliasHandler: Failed to delete artist alias")
 				utils.WriteError(w, "failed to delete alias", http.StatusInternalServerError)
 				return
 			}
+			recordAliasAudit(ctx, store, models.AuditActionDeleteAlias, models.AuditTargetArtist, int32(artistID), before)
 		} else if albumIDStr != "" {
 			var albumID int
 			albumID, err = strconv.Atoi(albumIDStr)
@@ -134,12 +136,14 @@ func DeleteAliasHandler(store db.DB) http.HandlerFunc {
 				utils.WriteError(w, "invalid album_id", http.StatusBadRequest)
 				return
 			}
+			before := auditAliases(ctx, store, models.AuditTargetAlbum, int32(albumID))
 			err = store.DeleteAlbumAlias(ctx, int32(albumID), alias)
 			if err != nil {
 				l.Error().Err(err).Msg("DeleteAliasHandler: Failed to delete album alias")
 				utils.WriteError(w, "failed to delete alias", http.StatusInternalServerError)
 				return
 			}
+			recordAliasAudit(ctx, store, models.AuditActionDeleteAlias, models.AuditTargetAlbum, int32(albumID), before)
 		} else if trackIDStr != "" {
 			var trackID int
 			trackID, err = strconv.Atoi(trackIDStr)
@@ -148,12 +152,14 @@ func DeleteAliasHandler(store db.DB) http.HandlerFunc {
 				utils.WriteError(w, "invalid track_id", http.StatusBadRequest)
 				return
 			}
+			before := auditAliases(ctx, store, models.AuditTargetTrack, int32(trackID))
 			err = store.DeleteTrackAlias(ctx, int32(trackID), alias)
 			if err != nil {
 				l.Error().Err(err).Msg("DeleteAliasHandler: Failed to delete track alias")
 				utils.WriteError(w, "failed to delete alias", http.StatusInternalServerError)
 				return
 			}
+			recordAliasAudit(ctx, store, models.AuditActionDeleteAlias, models.AuditTargetTrack, int32(trackID), before)
 		}
 
 		w.WriteHeader(http.StatusNoContent)
@@ -205,12 +211,14 @@ func CreateAliasHandler(store db.DB) http.HandlerFunc {
 				utils.WriteError(w, "invalid artist_id", http.StatusBadRequest)
 				return
 			}
+			before := auditAliases(ctx, store, models.AuditTargetArtist, int32(id))
 			err = store.SaveArtistAliases(ctx, int32(id), []string{alias}, "Manual")
 			if err != nil {
 				l.Error().Err(err).Msg("CreateAliasHandler: Failed to save artist alias")
 				utils.WriteError(w, "failed to save alias", http.StatusInternalServerError)
 				return
 			}
+			recordAliasAudit(ctx, store, models.AuditActionCreateAlias, models.AuditTargetArtist, int32(id), before)
 		} else if albumIDStr != "" {
 			id, err = strconv.Atoi(albumIDStr)
 			if err != nil {
@@ -218,12 +226,14 @@ func CreateAliasHandler(store db.DB) http.HandlerFunc {
 				utils.WriteError(w, "invalid album_id", http.StatusBadRequest)
 				return
 			}
+			before := auditAliases(ctx, store, models.AuditTargetAlbum, int32(id))
 			err = store.SaveAlbumAliases(ctx, int32(id), []string{alias}, "Manual")
 			if err != nil {
 				l.Error().Err(err).Msg("CreateAliasHandler: Failed to save album alias")
 				utils.WriteError(w, "failed to save alias", http.StatusInternalServerError)
 				return
 			}
+			recordAliasAudit(ctx, store, models.AuditActionCreateAlias, models.AuditTargetAlbum, int32(id), before)
 		} else if trackIDStr != "" {
 			id, err = strconv.Atoi(trackIDStr)
 			if err != nil {
@@ -231,12 +241,14 @@ func CreateAliasHandler(store db.DB) http.HandlerFunc {
 				utils.WriteError(w, "invalid track_id", http.StatusBadRequest)
 				return
 			}
+			before := auditAliases(ctx, store, models.AuditTargetTrack, int32(id))
 			err = store.SaveTrackAliases(ctx, int32(id), []string{alias}, "Manual")
 			if err != nil {
 				l.Error().Err(err).Msg("CreateAliasHandler: Failed to save track alias")
 				utils.WriteError(w, "failed to save alias", http.StatusInternalServerError)
 				return
 			}
+			recordAliasAudit(ctx, store, models.AuditActionCreateAlias, models.AuditTargetTrack, int32(id), before)
 		}
 
 		w.WriteHeader(http.StatusCreated)
@@ -290,12 +302,14 @@ func SetPrimaryAliasHandler(store db.DB) http.HandlerFunc {
 				utils.WriteError(w, "invalid artist_id", http.StatusBadRequest)
 				return
 			}
+			before := auditAliases(ctx, store, models.AuditTargetArtist, int32(id))
 			err = store.SetPrimaryArtistAlias(ctx, int32(id), alias)
 			if err != nil {
 				l.Error().Err(err).Msg("SetPrimaryAliasHandler: Failed to set artist primary alias")
 				utils.WriteError(w, "failed to set primary alias", http.StatusInternalServerError)
 				return
 			}
+			recordAliasAudit(ctx, store, models.AuditActionSetPrimaryAlias, models.AuditTargetArtist, int32(id), before)
 		} else if albumIDStr != "" {
 			id, err = strconv.Atoi(albumIDStr)
 			if err != nil {
@@ -303,12 +317,14 @@ func SetPrimaryAliasHandler(store db.DB) http.HandlerFunc {
 				utils.WriteError(w, "invalid album_id", http.StatusBadRequest)
 				return
 			}
+			before := auditAliases(ctx, store, models.AuditTargetAlbum, int32(id))
 			err = store.SetPrimaryAlbumAlias(ctx, int32(id), alias)
 			if err != nil {
 				l.Error().Err(err).Msg("SetPrimaryAliasHandler: Failed to set album primary alias")
 				utils.WriteError(w, "failed to set primary alias", http.StatusInternalServerError)
 				return
 			}
+			recordAliasAudit(ctx, store, models.AuditActionSetPrimaryAlias, models.AuditTargetAlbum, int32(id), before)
 		} else if trackIDStr != "" {
 			id, err = strconv.Atoi(trackIDStr)
 			if err != nil {
@@ -316,12 +332,14 @@ func SetPrimaryAliasHandler(store db.DB) http.HandlerFunc {
 				utils.WriteError(w, "invalid track_id", http.StatusBadRequest)
 				return
 			}
+			before := auditAliases(ctx, store, models.AuditTargetTrack, int32(id))
 			err = store.SetPrimaryTrackAlias(ctx, int32(id), alias)
 			if err != nil {
 				l.Error().Err(err).Msg("SetPrimaryAliasHandler: Failed to set track primary alias")
 				utils.WriteError(w, "failed to set primary alias", http.StatusInternalServerError)
 				return
 			}
+			recordAliasAudit(ctx, store, models.AuditActionSetPrimaryAlias, models.AuditTargetTrack, int32(id), before)
 		}
 
 		w.WriteHeader(http.StatusNoContent)
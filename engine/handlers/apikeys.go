@@ -3,13 +3,20 @@ package handlers
 import (
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gabehf/koito/engine/middleware"
 	"github.com/gabehf/koito/internal/db"
 	"github.com/gabehf/koito/internal/logger"
+	"github.com/gabehf/koito/internal/models"
 	"github.com/gabehf/koito/internal/utils"
 )
 
+// GenerateApiKeyHandler creates an API key for the current user. The optional scope parameter
+// is one of 'submit', 'read' or 'full' (the default), and the optional expires_in parameter is
+// a duration such as '720h'. Keys without expires_in never expire.
+// Setting legacy to 'true' allows the key to be used with Audioscrobbler 1.2 clients.
 func GenerateApiKeyHandler(store db.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		ctx := r.Context()
@@ -37,6 +44,29 @@ func GenerateApiKeyHandler(store db.DB) http.HandlerFunc {
 			return
 		}
 
+		scope := models.ApiKeyScopeFull
+		if s := r.FormValue("scope"); s != "" {
+			switch models.ApiKeyScope(s) {
+			case models.ApiKeyScopeSubmit, models.ApiKeyScopeRead, models.ApiKeyScopeFull:
+				scope = models.ApiKeyScope(s)
+			default:
+				l.Debug().Msgf("GenerateApiKeyHandler: Invalid scope '%s'", s)
+				utils.WriteError(w, "scope must be 'submit', 'read' or 'full'", http.StatusBadRequest)
+				return
+			}
+		}
+
+		var expiresAt time.Time
+		if expiresIn := r.FormValue("expires_in"); expiresIn != "" {
+			d, err := time.ParseDuration(expiresIn)
+			if err != nil || d <= 0 {
+				l.Debug().Msgf("GenerateApiKeyHandler: Invalid expiry '%s'", expiresIn)
+				utils.WriteError(w, "expires_in must be a positive duration, e.g. '720h'", http.StatusBadRequest)
+				return
+			}
+			expiresAt = time.Now().Add(d)
+		}
+
 		apiKey, err := utils.GenerateRandomString(48)
 		if err != nil {
 			l.Error().Err(err).Msg("GenerateApiKeyHandler: Failed to generate API key")
@@ -45,9 +75,12 @@ func GenerateApiKeyHandler(store db.DB) http.HandlerFunc {
 		}
 
 		key, err := store.SaveApiKey(ctx, db.SaveApiKeyOpts{
-			UserID: user.ID,
-			Key:    apiKey,
-			Label:  label,
+			UserID:    user.ID,
+			Key:       apiKey,
+			Label:     label,
+			Scope:     scope,
+			ExpiresAt: expiresAt,
+			Legacy:    strings.ToLower(r.FormValue("legacy")) == "true",
 		})
 		if err != nil {
 			l.Error().Err(err).Msg("GenerateApiKeyHandler: Failed to save API key")
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 
@@ -8,6 +9,7 @@ import (
 	"github.com/gabehf/koito/internal/logger"
 	"github.com/gabehf/koito/internal/models"
 	"github.com/gabehf/koito/internal/utils"
+	"github.com/jackc/pgx/v5"
 )
 
 func SetPrimaryArtistHandler(store db.DB) http.HandlerFunc {
@@ -73,12 +75,14 @@ func SetPrimaryArtistHandler(store db.DB) http.HandlerFunc {
 				utils.WriteError(w, "invalid album_id", http.StatusBadRequest)
 				return
 			}
+			before := auditArtists(ctx, store, models.AuditTargetAlbum, int32(id))
 			err = store.SetPrimaryAlbumArtist(ctx, int32(id), int32(artistId), primary)
 			if err != nil {
 				l.Error().Err(err).Msg("SetPrimaryArtistHandler: Failed to set album primary alias")
 				utils.WriteError(w, "failed to set primary alias", http.StatusInternalServerError)
 				return
 			}
+			recordArtistsAudit(ctx, store, models.AuditActionSetPrimaryArtist, models.AuditTargetAlbum, int32(id), int32(artistId), before)
 		} else if trackIDStr != "" {
 			id, err := strconv.Atoi(trackIDStr)
 			if err != nil {
@@ -86,17 +90,75 @@ func SetPrimaryArtistHandler(store db.DB) http.HandlerFunc {
 				utils.WriteError(w, "invalid track_id", http.StatusBadRequest)
 				return
 			}
+			before := auditArtists(ctx, store, models.AuditTargetTrack, int32(id))
 			err = store.SetPrimaryTrackArtist(ctx, int32(id), int32(artistId), primary)
 			if err != nil {
 				l.Error().Err(err).Msg("SetPrimaryArtistHandler: Failed to set track primary alias")
 				utils.WriteError(w, "failed to set primary alias", http.StatusInternalServerError)
 				return
 			}
+			recordArtistsAudit(ctx, store, models.AuditActionSetPrimaryArtist, models.AuditTargetTrack, int32(id), int32(artistId), before)
 		}
 
 		w.WriteHeader(http.StatusNoContent)
 	}
 }
+
+// SetArtistRoleHandler sets how an artist is credited on a track: as a main artist, a featured
+// artist, or a remixer.
+func SetArtistRoleHandler(store db.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		l := logger.FromContext(ctx)
+
+		l.Debug().Msg("SetArtistRoleHandler: Got request")
+
+		r.ParseForm()
+
+		artistIDStr := r.FormValue("artist_id")
+		trackIDStr := r.FormValue("track_id")
+		role := models.ArtistRole(r.FormValue("role"))
+
+		if artistIDStr == "" || trackIDStr == "" {
+			l.Debug().Msg("SetArtistRoleHandler: artist_id and track_id must be provided")
+			utils.WriteError(w, "artist_id and track_id must be provided", http.StatusBadRequest)
+			return
+		}
+		if !role.Valid() {
+			l.Debug().Msgf("SetArtistRoleHandler: Invalid role '%s'", role)
+			utils.WriteError(w, "role must be one of main, featured or remixer", http.StatusBadRequest)
+			return
+		}
+		artistId, err := strconv.Atoi(artistIDStr)
+		if err != nil {
+			l.Debug().Msg("SetArtistRoleHandler: artist_id is invalid")
+			utils.WriteError(w, "artist_id is invalid", http.StatusBadRequest)
+			return
+		}
+		trackId, err := strconv.Atoi(trackIDStr)
+		if err != nil {
+			l.Debug().Msg("SetArtistRoleHandler: track_id is invalid")
+			utils.WriteError(w, "track_id is invalid", http.StatusBadRequest)
+			return
+		}
+
+		before := auditArtists(ctx, store, models.AuditTargetTrack, int32(trackId))
+		err = store.SetTrackArtistRole(ctx, int32(trackId), int32(artistId), role)
+		if errors.Is(err, pgx.ErrNoRows) {
+			l.Debug().Msg("SetArtistRoleHandler: Artist is not credited on track")
+			utils.WriteError(w, "artist is not credited on track", http.StatusNotFound)
+			return
+		} else if err != nil {
+			l.Error().Err(err).Msg("SetArtistRoleHandler: Failed to set artist role")
+			utils.WriteError(w, "failed to set artist role", http.StatusInternalServerError)
+			return
+		}
+		recordArtistsAudit(ctx, store, models.AuditActionSetArtistRole, models.AuditTargetTrack, int32(trackId), int32(artistId), before)
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
 func GetArtistsForItemHandler(store db.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		ctx := r.Context()
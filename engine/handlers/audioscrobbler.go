@@ -0,0 +1,560 @@
+package handlers
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gabehf/koito/internal/catalog"
+	"github.com/gabehf/koito/internal/db"
+	"github.com/gabehf/koito/internal/logger"
+	"github.com/gabehf/koito/internal/mbz"
+	"github.com/gabehf/koito/internal/models"
+	"github.com/gabehf/koito/internal/utils"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Error codes as documented at https://www.last.fm/api/errorcodes
+const (
+	asErrInvalidMethod     = 3
+	asErrAuthFailed        = 4
+	asErrInvalidParameters = 6
+	asErrInvalidSessionKey = 9
+	asErrInvalidApiKey     = 10
+	asErrInvalidSignature  = 13
+	asErrTemporary         = 16
+)
+
+// Ignored message codes as documented at https://www.last.fm/api/show/track.scrobble
+const (
+	asIgnoredArtist       = 1
+	asIgnoredTrack        = 2
+	asIgnoredTimestampOld = 3
+	asIgnoredTimestampNew = 4
+)
+
+const maxScrobblesPerRequest = 50
+
+var asIndexedParamRegex = regexp.MustCompile(`^(\w+)\[(\d+)\]$`)
+
+type asResponse struct {
+	XMLName    xml.Name          `xml:"lfm" json:"-"`
+	Status     string            `xml:"status,attr" json:"-"`
+	Session    *asSession        `xml:"session,omitempty" json:"session,omitempty"`
+	Scrobbles  *asScrobbles      `xml:"scrobbles,omitempty" json:"scrobbles,omitempty"`
+	NowPlaying *asScrobbleResult `xml:"nowplaying,omitempty" json:"nowplaying,omitempty"`
+}
+
+type asErrorResponse struct {
+	XMLName xml.Name `xml:"lfm" json:"-"`
+	Status  string   `xml:"status,attr" json:"-"`
+	Error   asError  `xml:"error" json:"-"`
+	Code    int      `xml:"-" json:"error"`
+	Message string   `xml:"-" json:"message"`
+}
+
+type asError struct {
+	Code    int    `xml:"code,attr"`
+	Message string `xml:",chardata"`
+}
+
+type asSession struct {
+	Name       string `xml:"name" json:"name"`
+	Key        string `xml:"key" json:"key"`
+	Subscriber int    `xml:"subscriber" json:"subscriber"`
+}
+
+type asScrobbles struct {
+	Accepted  int                `xml:"accepted,attr" json:"-"`
+	Ignored   int                `xml:"ignored,attr" json:"-"`
+	Attr      asScrobblesAttr    `xml:"-" json:"@attr"`
+	Scrobbles []asScrobbleResult `xml:"scrobble" json:"scrobble"`
+}
+
+type asScrobblesAttr struct {
+	Accepted int `json:"accepted"`
+	Ignored  int `json:"ignored"`
+}
+
+type asScrobbleResult struct {
+	Track          asCorrectable `xml:"track" json:"track"`
+	Artist         asCorrectable `xml:"artist" json:"artist"`
+	Album          asCorrectable `xml:"album" json:"album"`
+	AlbumArtist    asCorrectable `xml:"albumArtist" json:"albumArtist"`
+	Timestamp      string        `xml:"timestamp,omitempty" json:"timestamp,omitempty"`
+	IgnoredMessage asIgnored     `xml:"ignoredMessage" json:"ignoredMessage"`
+}
+
+type asCorrectable struct {
+	Corrected int    `xml:"corrected,attr" json:"corrected,string"`
+	Text      string `xml:",chardata" json:"#text"`
+}
+
+type asIgnored struct {
+	Code int    `xml:"code,attr" json:"code,string"`
+	Text string `xml:",chardata" json:"#text"`
+}
+
+// asScrobble is a single (possibly batched) scrobble parsed from the request parameters.
+type asScrobble struct {
+	Artist      string
+	Track       string
+	Album       string
+	AlbumArtist string
+	Timestamp   string
+	Duration    string
+	Mbid        string
+}
+
+// AudioscrobblerHandler implements the parts of the Last.fm 2.0 API that scrobbling clients
+// use. Clients authenticate with a Koito API key, which acts as both the API key and the
+// shared secret used to sign requests.
+func AudioscrobblerHandler(store db.DB, mbzc mbz.MusicBrainzCaller) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		l := logger.FromContext(ctx)
+
+		l.Debug().Msg("AudioscrobblerHandler: Received request")
+
+		r.Body = http.MaxBytesReader(w, r.Body, maxRequestBodyBytes)
+		if err := r.ParseForm(); err != nil {
+			l.Debug().AnErr("error", err).Msg("AudioscrobblerHandler: Failed to parse form")
+			writeAsError(w, r, http.StatusBadRequest, asErrInvalidParameters, "Invalid parameters - Failed to parse request")
+			return
+		}
+
+		method := strings.ToLower(r.Form.Get("method"))
+		switch method {
+		case "auth.getmobilesession":
+			asGetMobileSession(ctx, store, w, r)
+		case "track.scrobble":
+			asScrobbleTracks(ctx, store, mbzc, w, r)
+		case "track.updatenowplaying":
+			asUpdateNowPlaying(ctx, store, mbzc, w, r)
+		default:
+			l.Debug().Msgf("AudioscrobblerHandler: Unsupported method '%s'", method)
+			writeAsError(w, r, http.StatusBadRequest, asErrInvalidMethod, "Invalid Method - No method with that name in this package")
+		}
+	}
+}
+
+func asGetMobileSession(ctx context.Context, store db.DB, w http.ResponseWriter, r *http.Request) {
+	l := logger.FromContext(ctx)
+
+	keyOwner, ok := asVerifyApiKey(ctx, store, w, r)
+	if !ok {
+		return
+	}
+
+	username := r.Form.Get("username")
+	password := r.Form.Get("password")
+	if username == "" || password == "" {
+		l.Debug().Msg("AudioscrobblerHandler: Missing credentials for auth.getMobileSession")
+		writeAsError(w, r, http.StatusBadRequest, asErrInvalidParameters, "Invalid parameters - username and password are required")
+		return
+	}
+
+	user, err := store.GetUserByUsername(ctx, username)
+	if err != nil {
+		l.Err(err).Msg("AudioscrobblerHandler: Failed to get user by username")
+		writeAsError(w, r, http.StatusInternalServerError, asErrTemporary, "There was a temporary error processing your request. Please try again")
+		return
+	}
+	if user == nil || user.ID != keyOwner.ID {
+		l.Debug().Msg("AudioscrobblerHandler: User not found or API key belongs to another user")
+		writeAsError(w, r, http.StatusForbidden, asErrAuthFailed, "Authentication Failed - Invalid username or password")
+		return
+	}
+
+	// the API key itself is accepted in place of the password, since that is what most
+	// clients will have been configured with
+	passwordIsKey := subtle.ConstantTimeCompare([]byte(password), []byte(r.Form.Get("api_key"))) == 1
+	if !passwordIsKey && bcrypt.CompareHashAndPassword(user.Password, []byte(password)) != nil {
+		l.Debug().Msg("AudioscrobblerHandler: Invalid password")
+		writeAsError(w, r, http.StatusForbidden, asErrAuthFailed, "Authentication Failed - Invalid username or password")
+		return
+	}
+
+	l.Debug().Msgf("AudioscrobblerHandler: Created mobile session for user '%s'", user.Username)
+	writeAs(w, r, http.StatusOK, asResponse{
+		Status: "ok",
+		Session: &asSession{
+			Name: user.Username,
+			Key:  r.Form.Get("api_key"),
+		},
+	})
+}
+
+func asScrobbleTracks(ctx context.Context, store db.DB, mbzc mbz.MusicBrainzCaller, w http.ResponseWriter, r *http.Request) {
+	l := logger.FromContext(ctx)
+
+	u, ok := asVerifySession(ctx, store, w, r)
+	if !ok {
+		return
+	}
+
+	scrobbles := parseAsScrobbles(r.Form)
+	if len(scrobbles) < 1 {
+		l.Debug().Msg("AudioscrobblerHandler: No scrobbles in request")
+		writeAsError(w, r, http.StatusBadRequest, asErrInvalidParameters, "Invalid parameters - No scrobbles were provided")
+		return
+	}
+	if len(scrobbles) > maxScrobblesPerRequest {
+		l.Debug().Msgf("AudioscrobblerHandler: Too many scrobbles in request (%d > %d)", len(scrobbles), maxScrobblesPerRequest)
+		writeAsError(w, r, http.StatusBadRequest, asErrInvalidParameters, fmt.Sprintf("Invalid parameters - A maximum of %d scrobbles can be sent per request", maxScrobblesPerRequest))
+		return
+	}
+
+	result := &asScrobbles{}
+	var accepted []LbzSubmitListenPayload
+	var batch []catalog.SubmitListenOpts
+	var keys []string
+	for _, s := range scrobbles {
+		res := s.result()
+
+		listenedAt, ignoredCode := s.listenTime()
+		if s.Artist == "" {
+			ignoredCode = asIgnoredArtist
+		} else if s.Track == "" {
+			ignoredCode = asIgnoredTrack
+		}
+		if ignoredCode != 0 {
+			l.Debug().Msgf("AudioscrobblerHandler: Ignoring scrobble with code %d", ignoredCode)
+			res.IgnoredMessage.Code = ignoredCode
+			result.Ignored++
+			result.Scrobbles = append(result.Scrobbles, res)
+			continue
+		}
+
+		opts := s.submitListenOpts(mbzc, u.ID)
+		opts.Time = listenedAt
+		batch = append(batch, opts)
+		keys = append(keys, s.coalescingKey(u.ID, "single"))
+
+		result.Accepted++
+		result.Scrobbles = append(result.Scrobbles, res)
+		accepted = append(accepted, s.lbzPayload(listenedAt))
+	}
+
+	// the batch is saved atomically, so a client resubmitting it after a failure does not
+	// create duplicates of the scrobbles that were saved the first time
+	if len(batch) > 0 {
+		_, err, shared := sfGroup.Do(strings.Join(keys, "\n"), func() (interface{}, error) {
+			submitCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), time.Duration(len(batch))*30*time.Second)
+			defer cancel()
+
+			return 0, catalog.SubmitListens(submitCtx, store, batch)
+		})
+		if shared {
+			l.Info().Msg("AudioscrobblerHandler: Duplicate requests detected; results were coalesced")
+		}
+		if err != nil {
+			l.Err(err).Msg("AudioscrobblerHandler: Failed to submit listens")
+			writeAsError(w, r, http.StatusInternalServerError, asErrTemporary, "There was a temporary error processing your request. Please try again")
+			return
+		}
+	}
+
+	result.Attr = asScrobblesAttr{Accepted: result.Accepted, Ignored: result.Ignored}
+	// ListenBrainz only accepts one listen per 'single' submission
+	for _, p := range accepted {
+		relayListens(ctx, store, u.ID, ListenTypeSingle, []LbzSubmitListenPayload{p})
+	}
+
+	l.Debug().Msgf("AudioscrobblerHandler: Accepted %d scrobbles, ignored %d", result.Accepted, result.Ignored)
+	writeAs(w, r, http.StatusOK, asResponse{Status: "ok", Scrobbles: result})
+}
+
+func asUpdateNowPlaying(ctx context.Context, store db.DB, mbzc mbz.MusicBrainzCaller, w http.ResponseWriter, r *http.Request) {
+	l := logger.FromContext(ctx)
+
+	u, ok := asVerifySession(ctx, store, w, r)
+	if !ok {
+		return
+	}
+
+	s := asScrobble{
+		Artist:      r.Form.Get("artist"),
+		Track:       r.Form.Get("track"),
+		Album:       r.Form.Get("album"),
+		AlbumArtist: r.Form.Get("albumArtist"),
+		Duration:    r.Form.Get("duration"),
+		Mbid:        r.Form.Get("mbid"),
+	}
+	if s.Artist == "" || s.Track == "" {
+		l.Debug().Msg("AudioscrobblerHandler: Artist name or track name are missing")
+		writeAsError(w, r, http.StatusBadRequest, asErrInvalidParameters, "Invalid parameters - artist and track are required")
+		return
+	}
+
+	opts := s.submitListenOpts(mbzc, u.ID)
+	opts.Time = time.Now()
+	opts.IsNowPlaying = true
+	opts.SkipSaveListen = true
+
+	_, err, _ := sfGroup.Do(s.coalescingKey(u.ID, "playing_now"), func() (interface{}, error) {
+		submitCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 30*time.Second)
+		defer cancel()
+
+		return 0, catalog.SubmitListen(submitCtx, store, opts)
+	})
+	if err != nil {
+		l.Err(err).Msg("AudioscrobblerHandler: Failed to update now playing")
+		writeAsError(w, r, http.StatusInternalServerError, asErrTemporary, "There was a temporary error processing your request. Please try again")
+		return
+	}
+
+	relayListens(ctx, store, u.ID, ListenTypePlayingNow, []LbzSubmitListenPayload{s.lbzPayload(time.Time{})})
+
+	res := s.result()
+	l.Debug().Msg("AudioscrobblerHandler: Successfully updated now playing")
+	writeAs(w, r, http.StatusOK, asResponse{Status: "ok", NowPlaying: &res})
+}
+
+// asVerifyApiKey ensures the api_key parameter is a valid API key and that the request
+// was signed using it as the shared secret. Writes an error response and returns false if not.
+func asVerifyApiKey(ctx context.Context, store db.DB, w http.ResponseWriter, r *http.Request) (*models.User, bool) {
+	l := logger.FromContext(ctx)
+
+	apiKey := r.Form.Get("api_key")
+	if apiKey == "" {
+		l.Debug().Msg("AudioscrobblerHandler: Missing api_key parameter")
+		writeAsError(w, r, http.StatusForbidden, asErrInvalidApiKey, "Invalid API key - You must be granted a valid key by last.fm")
+		return nil, false
+	}
+
+	u, err := userForSubmitKey(ctx, store, r, apiKey)
+	if err != nil {
+		l.Err(err).Msg("AudioscrobblerHandler: Failed to get user by api key")
+		writeAsError(w, r, http.StatusInternalServerError, asErrTemporary, "There was a temporary error processing your request. Please try again")
+		return nil, false
+	}
+	if u == nil {
+		l.Debug().Msg("AudioscrobblerHandler: API key does not exist or can't submit listens")
+		writeAsError(w, r, http.StatusForbidden, asErrInvalidApiKey, "Invalid API key - You must be granted a valid key by last.fm")
+		return nil, false
+	}
+
+	expected := audioscrobblerSignature(r.Form, apiKey)
+	if subtle.ConstantTimeCompare([]byte(strings.ToLower(r.Form.Get("api_sig"))), []byte(expected)) != 1 {
+		l.Debug().Msg("AudioscrobblerHandler: Invalid method signature")
+		writeAsError(w, r, http.StatusForbidden, asErrInvalidSignature, "Invalid method signature supplied")
+		return nil, false
+	}
+
+	return u, true
+}
+
+// asVerifySession verifies the request signature and resolves the user from the session key.
+// Writes an error response and returns false if either is invalid.
+func asVerifySession(ctx context.Context, store db.DB, w http.ResponseWriter, r *http.Request) (*models.User, bool) {
+	l := logger.FromContext(ctx)
+
+	keyOwner, ok := asVerifyApiKey(ctx, store, w, r)
+	if !ok {
+		return nil, false
+	}
+
+	sk := r.Form.Get("sk")
+	if sk == "" {
+		l.Debug().Msg("AudioscrobblerHandler: Missing sk parameter")
+		writeAsError(w, r, http.StatusForbidden, asErrInvalidSessionKey, "Invalid session key - Please re-authenticate")
+		return nil, false
+	}
+
+	u, err := userForSubmitKey(ctx, store, r, sk)
+	if err != nil {
+		l.Err(err).Msg("AudioscrobblerHandler: Failed to get user by session key")
+		writeAsError(w, r, http.StatusInternalServerError, asErrTemporary, "There was a temporary error processing your request. Please try again")
+		return nil, false
+	}
+	if u == nil || u.ID != keyOwner.ID {
+		l.Debug().Msg("AudioscrobblerHandler: Session key is invalid or belongs to another user")
+		writeAsError(w, r, http.StatusForbidden, asErrInvalidSessionKey, "Invalid session key - Please re-authenticate")
+		return nil, false
+	}
+
+	return u, true
+}
+
+// audioscrobblerSignature builds a Last.fm method signature: the md5 of every parameter
+// (except format, callback and api_sig) concatenated as <name><value> in alphabetical
+// order, followed by the shared secret.
+func audioscrobblerSignature(params url.Values, secret string) string {
+	keys := make([]string, 0, len(params))
+	for k := range params {
+		if k == "format" || k == "callback" || k == "api_sig" {
+			continue
+		}
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	for _, k := range keys {
+		b.WriteString(k)
+		b.WriteString(params.Get(k))
+	}
+	b.WriteString(secret)
+
+	sum := md5.Sum([]byte(b.String()))
+	return hex.EncodeToString(sum[:])
+}
+
+// parseAsScrobbles reads both batched (artist[0], track[0], ...) and single
+// (artist, track, ...) scrobble parameters, ordered by index.
+func parseAsScrobbles(form url.Values) []asScrobble {
+	byIndex := make(map[int]*asScrobble)
+	get := func(i int) *asScrobble {
+		if s, ok := byIndex[i]; ok {
+			return s
+		}
+		s := &asScrobble{}
+		byIndex[i] = s
+		return s
+	}
+
+	for key := range form {
+		name := key
+		idx := -1
+		if m := asIndexedParamRegex.FindStringSubmatch(key); m != nil {
+			i, err := strconv.Atoi(m[2])
+			if err != nil || i >= maxScrobblesPerRequest*2 {
+				continue
+			}
+			name = m[1]
+			idx = i
+		}
+		val := form.Get(key)
+		switch name {
+		case "artist":
+			get(idx).Artist = val
+		case "track":
+			get(idx).Track = val
+		case "album":
+			get(idx).Album = val
+		case "albumArtist":
+			get(idx).AlbumArtist = val
+		case "timestamp":
+			get(idx).Timestamp = val
+		case "duration":
+			get(idx).Duration = val
+		case "mbid":
+			get(idx).Mbid = val
+		}
+	}
+
+	indexes := make([]int, 0, len(byIndex))
+	for i := range byIndex {
+		indexes = append(indexes, i)
+	}
+	sort.Ints(indexes)
+
+	scrobbles := make([]asScrobble, 0, len(indexes))
+	for _, i := range indexes {
+		scrobbles = append(scrobbles, *byIndex[i])
+	}
+	return scrobbles
+}
+
+// listenTime parses the scrobble timestamp, returning a non-zero ignored
+// message code if it is missing or out of range.
+func (s asScrobble) listenTime() (time.Time, int) {
+	unix, err := strconv.ParseInt(s.Timestamp, 10, 64)
+	if err != nil || unix <= 0 {
+		return time.Time{}, asIgnoredTimestampOld
+	}
+	t := time.Unix(unix, 0)
+	if t.After(time.Now().Add(24 * time.Hour)) {
+		return time.Time{}, asIgnoredTimestampNew
+	}
+	return t, 0
+}
+
+func (s asScrobble) submitListenOpts(mbzc mbz.MusicBrainzCaller, userID int32) catalog.SubmitListenOpts {
+	recordingMbzID, err := uuid.Parse(s.Mbid)
+	if err != nil {
+		recordingMbzID = uuid.Nil
+	}
+	duration, _ := strconv.Atoi(s.Duration)
+
+	return catalog.SubmitListenOpts{
+		MbzCaller:      mbzc,
+		Artist:         s.Artist,
+		TrackTitle:     s.Track,
+		RecordingMbzID: recordingMbzID,
+		ReleaseTitle:   s.Album,
+		AlbumArtist:    s.AlbumArtist,
+		Duration:       int32(duration),
+		UserID:         userID,
+	}
+}
+
+// lbzPayload converts the scrobble to a ListenBrainz listen, for relaying. A zero listenedAt
+// leaves the listen time unset.
+func (s asScrobble) lbzPayload(listenedAt time.Time) LbzSubmitListenPayload {
+	duration, _ := strconv.Atoi(s.Duration)
+	p := LbzSubmitListenPayload{
+		TrackMeta: LbzTrackMeta{
+			ArtistName:  s.Artist,
+			TrackName:   s.Track,
+			ReleaseName: s.Album,
+			AdditionalInfo: LbzAdditionalInfo{
+				RecordingMBID: s.Mbid,
+				Duration:      int32(duration),
+				AlbumArtist:   s.AlbumArtist,
+			},
+		},
+	}
+	if !listenedAt.IsZero() {
+		p.ListenedAt = listenedAt.Unix()
+	}
+	return p
+}
+
+func (s asScrobble) result() asScrobbleResult {
+	return asScrobbleResult{
+		Track:       asCorrectable{Text: s.Track},
+		Artist:      asCorrectable{Text: s.Artist},
+		Album:       asCorrectable{Text: s.Album},
+		AlbumArtist: asCorrectable{Text: s.AlbumArtist},
+		Timestamp:   s.Timestamp,
+	}
+}
+
+func (s asScrobble) coalescingKey(userID int32, listenType string) string {
+	return fmt.Sprintf("%d:as:%s:%s:%s:%s:%s", userID, listenType, s.Artist, s.Track, s.Album, s.Timestamp)
+}
+
+// writeAs writes the response as JSON when format=json is requested, and as
+// Last.fm-style XML otherwise.
+func writeAs(w http.ResponseWriter, r *http.Request, status int, v any) {
+	if r.Form.Get("format") == "json" {
+		utils.WriteJSON(w, status, v)
+		return
+	}
+	w.Header().Set("Content-Type", "text/xml; charset=utf-8")
+	w.WriteHeader(status)
+	_, _ = w.Write([]byte(xml.Header))
+	_ = xml.NewEncoder(w).Encode(v)
+}
+
+func writeAsError(w http.ResponseWriter, r *http.Request, status int, code int, message string) {
+	writeAs(w, r, status, asErrorResponse{
+		Status:  "failed",
+		Error:   asError{Code: code, Message: message},
+		Code:    code,
+		Message: message,
+	})
+}
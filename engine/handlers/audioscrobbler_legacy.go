@@ -0,0 +1,332 @@
+package handlers
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gabehf/koito/internal/catalog"
+	"github.com/gabehf/koito/internal/db"
+	"github.com/gabehf/koito/internal/logger"
+	"github.com/gabehf/koito/internal/mbz"
+	"github.com/gabehf/koito/internal/models"
+	"github.com/gabehf/koito/internal/utils"
+)
+
+// maximum difference between the handshake timestamp and the server clock
+const asLegacyMaxClockSkew = time.Hour
+
+// AudioscrobblerLegacyHandshakeHandler implements the Audioscrobbler 1.2 handshake. The handshake
+// token is md5(md5(api key) + timestamp), so users configure their Koito API key as the password.
+// Only keys created with legacy support can be used, since the handshake needs the MD5 of the key.
+// Every handshake starts a new session with a random session ID, which is only stored hashed.
+func AudioscrobblerLegacyHandshakeHandler(store db.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		l := logger.FromContext(ctx)
+
+		l.Debug().Msg("AudioscrobblerLegacyHandshakeHandler: Received request")
+
+		q := r.URL.Query()
+		if q.Get("hs") != "true" {
+			writeAsLegacy(w, "FAILED Handshake parameter 'hs' must be 'true'")
+			return
+		}
+		if !strings.HasPrefix(q.Get("p"), "1.2") {
+			writeAsLegacy(w, "FAILED Unsupported protocol version")
+			return
+		}
+
+		username := q.Get("u")
+		token := strings.ToLower(q.Get("a"))
+		if username == "" || token == "" || q.Get("c") == "" {
+			l.Debug().Msg("AudioscrobblerLegacyHandshakeHandler: Missing required parameters")
+			writeAsLegacy(w, "FAILED Missing required parameters")
+			return
+		}
+
+		timestamp, err := strconv.ParseInt(q.Get("t"), 10, 64)
+		if err != nil {
+			l.Debug().AnErr("error", err).Msg("AudioscrobblerLegacyHandshakeHandler: Invalid timestamp")
+			writeAsLegacy(w, "BADTIME")
+			return
+		}
+		if skew := time.Since(time.Unix(timestamp, 0)); skew > asLegacyMaxClockSkew || skew < -asLegacyMaxClockSkew {
+			l.Debug().Msgf("AudioscrobblerLegacyHandshakeHandler: Timestamp is off by %s", skew)
+			writeAsLegacy(w, "BADTIME")
+			return
+		}
+
+		user, err := store.GetUserByUsername(ctx, username)
+		if err != nil {
+			l.Err(err).Msg("AudioscrobblerLegacyHandshakeHandler: Failed to get user by username")
+			writeAsLegacy(w, "FAILED Internal server error")
+			return
+		}
+		if user == nil || user.Disabled {
+			l.Debug().Msg("AudioscrobblerLegacyHandshakeHandler: User not found or disabled")
+			writeAsLegacy(w, "BADAUTH")
+			return
+		}
+
+		keys, err := store.GetApiKeysByUserID(ctx, user.ID)
+		if err != nil {
+			l.Err(err).Msg("AudioscrobblerLegacyHandshakeHandler: Failed to get api keys for user")
+			writeAsLegacy(w, "FAILED Internal server error")
+			return
+		}
+
+		var apiKey *models.ApiKey
+		for _, key := range keys {
+			if !key.Scope.Allows(models.ApiKeyScopeSubmit) || (key.ExpiresAt != nil && key.ExpiresAt.Before(time.Now())) {
+				continue
+			}
+			if key.LegacyDigest == "" {
+				continue
+			}
+			if subtle.ConstantTimeCompare([]byte(token), []byte(audioscrobblerLegacyToken(key.LegacyDigest, q.Get("t")))) == 1 {
+				apiKey = &key
+				break
+			}
+		}
+		if apiKey == nil {
+			l.Debug().Msg("AudioscrobblerLegacyHandshakeHandler: Authentication token did not match any api key")
+			writeAsLegacy(w, "BADAUTH")
+			return
+		}
+		if err := store.UpdateApiKeyLastUsed(ctx, apiKey.ID, utils.ClientIP(r)); err != nil {
+			l.Err(err).Msg("AudioscrobblerLegacyHandshakeHandler: Failed to record api key usage")
+		}
+
+		sessionID, err := newAsLegacySessionID()
+		if err != nil {
+			l.Err(err).Msg("AudioscrobblerLegacyHandshakeHandler: Failed to generate session ID")
+			writeAsLegacy(w, "FAILED Internal server error")
+			return
+		}
+		if err := store.SaveAudioscrobblerSession(ctx, apiKey.ID, sessionID); err != nil {
+			l.Err(err).Msg("AudioscrobblerLegacyHandshakeHandler: Failed to save session")
+			writeAsLegacy(w, "FAILED Internal server error")
+			return
+		}
+
+		base := requestBaseURL(r) + strings.TrimSuffix(r.URL.Path, "/")
+
+		l.Debug().Msgf("AudioscrobblerLegacyHandshakeHandler: Handshake succeeded for user '%s' with client '%s'", user.Username, q.Get("c"))
+		writeAsLegacy(w, "OK", sessionID, base+"/nowplaying", base+"/submissions")
+	}
+}
+
+// AudioscrobblerLegacySubmissionsHandler accepts batched Audioscrobbler 1.2 submissions.
+func AudioscrobblerLegacySubmissionsHandler(store db.DB, mbzc mbz.MusicBrainzCaller) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		l := logger.FromContext(ctx)
+
+		l.Debug().Msg("AudioscrobblerLegacySubmissionsHandler: Received request")
+
+		r.Body = http.MaxBytesReader(w, r.Body, maxRequestBodyBytes)
+		if err := r.ParseForm(); err != nil {
+			l.Debug().AnErr("error", err).Msg("AudioscrobblerLegacySubmissionsHandler: Failed to parse form")
+			writeAsLegacy(w, "FAILED Failed to parse request")
+			return
+		}
+
+		u, ok := asLegacyUserFromSession(ctx, store, w, r)
+		if !ok {
+			return
+		}
+
+		scrobbles := parseAsLegacySubmissions(r.Form)
+		if len(scrobbles) > maxScrobblesPerRequest {
+			l.Debug().Msgf("AudioscrobblerLegacySubmissionsHandler: Too many submissions in request (%d > %d)", len(scrobbles), maxScrobblesPerRequest)
+			writeAsLegacy(w, fmt.Sprintf("FAILED A maximum of %d submissions can be sent per request", maxScrobblesPerRequest))
+			return
+		}
+
+		var accepted []LbzSubmitListenPayload
+		var batch []catalog.SubmitListenOpts
+		var keys []string
+		for _, s := range scrobbles {
+			listenedAt, ignoredCode := s.listenTime()
+			if s.Artist == "" || s.Track == "" || ignoredCode != 0 {
+				l.Debug().Msg("AudioscrobblerLegacySubmissionsHandler: Skipping invalid submission")
+				continue
+			}
+
+			opts := s.submitListenOpts(mbzc, u.ID)
+			opts.Time = listenedAt
+			batch = append(batch, opts)
+			keys = append(keys, s.coalescingKey(u.ID, "single"))
+			accepted = append(accepted, s.lbzPayload(listenedAt))
+		}
+
+		// the batch is saved atomically, so a client resubmitting it after a failure does not
+		// create duplicates of the submissions that were saved the first time
+		if len(batch) > 0 {
+			_, err, shared := sfGroup.Do(strings.Join(keys, "\n"), func() (interface{}, error) {
+				submitCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), time.Duration(len(batch))*30*time.Second)
+				defer cancel()
+
+				return 0, catalog.SubmitListens(submitCtx, store, batch)
+			})
+			if shared {
+				l.Info().Msg("AudioscrobblerLegacySubmissionsHandler: Duplicate requests detected; results were coalesced")
+			}
+			if err != nil {
+				l.Err(err).Msg("AudioscrobblerLegacySubmissionsHandler: Failed to submit listens")
+				writeAsLegacy(w, "FAILED Internal server error")
+				return
+			}
+		}
+
+		// ListenBrainz only accepts one listen per 'single' submission
+		for _, p := range accepted {
+			relayListens(ctx, store, u.ID, ListenTypeSingle, []LbzSubmitListenPayload{p})
+		}
+
+		l.Debug().Msgf("AudioscrobblerLegacySubmissionsHandler: Processed %d submissions", len(scrobbles))
+		writeAsLegacy(w, "OK")
+	}
+}
+
+// AudioscrobblerLegacyNowPlayingHandler accepts Audioscrobbler 1.2 now playing notifications.
+func AudioscrobblerLegacyNowPlayingHandler(store db.DB, mbzc mbz.MusicBrainzCaller) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		l := logger.FromContext(ctx)
+
+		l.Debug().Msg("AudioscrobblerLegacyNowPlayingHandler: Received request")
+
+		r.Body = http.MaxBytesReader(w, r.Body, maxRequestBodyBytes)
+		if err := r.ParseForm(); err != nil {
+			l.Debug().AnErr("error", err).Msg("AudioscrobblerLegacyNowPlayingHandler: Failed to parse form")
+			writeAsLegacy(w, "FAILED Failed to parse request")
+			return
+		}
+
+		u, ok := asLegacyUserFromSession(ctx, store, w, r)
+		if !ok {
+			return
+		}
+
+		s := asScrobble{
+			Artist:   r.Form.Get("a"),
+			Track:    r.Form.Get("t"),
+			Album:    r.Form.Get("b"),
+			Duration: r.Form.Get("l"),
+			Mbid:     r.Form.Get("m"),
+		}
+		if s.Artist == "" || s.Track == "" {
+			l.Debug().Msg("AudioscrobblerLegacyNowPlayingHandler: Artist name or track name are missing")
+			writeAsLegacy(w, "FAILED Artist name or track name are missing")
+			return
+		}
+
+		opts := s.submitListenOpts(mbzc, u.ID)
+		opts.Time = time.Now()
+		opts.IsNowPlaying = true
+		opts.SkipSaveListen = true
+
+		_, err, _ := sfGroup.Do(s.coalescingKey(u.ID, "playing_now"), func() (interface{}, error) {
+			submitCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 30*time.Second)
+			defer cancel()
+
+			return 0, catalog.SubmitListen(submitCtx, store, opts)
+		})
+		if err != nil {
+			l.Err(err).Msg("AudioscrobblerLegacyNowPlayingHandler: Failed to update now playing")
+			writeAsLegacy(w, "FAILED Internal server error")
+			return
+		}
+
+		relayListens(ctx, store, u.ID, ListenTypePlayingNow, []LbzSubmitListenPayload{s.lbzPayload(time.Time{})})
+
+		l.Debug().Msg("AudioscrobblerLegacyNowPlayingHandler: Successfully updated now playing")
+		writeAsLegacy(w, "OK")
+	}
+}
+
+// asLegacyUserFromSession resolves the user from the 's' parameter. Writes BADSESSION and
+// returns false when the session ID is not valid.
+func asLegacyUserFromSession(ctx context.Context, store db.DB, w http.ResponseWriter, r *http.Request) (*models.User, bool) {
+	l := logger.FromContext(ctx)
+
+	sessionID := strings.ToLower(r.Form.Get("s"))
+	if sessionID == "" {
+		l.Debug().Msg("AudioscrobblerLegacy: Missing session ID")
+		writeAsLegacy(w, "BADSESSION")
+		return nil, false
+	}
+
+	u, err := store.GetUserByAudioscrobblerSession(ctx, sessionID)
+	if err != nil {
+		l.Err(err).Msg("AudioscrobblerLegacy: Failed to get user by session ID")
+		writeAsLegacy(w, "FAILED Internal server error")
+		return nil, false
+	}
+	if u == nil {
+		l.Debug().Msg("AudioscrobblerLegacy: Session ID does not match any session")
+		writeAsLegacy(w, "BADSESSION")
+		return nil, false
+	}
+
+	return u, true
+}
+
+// parseAsLegacySubmissions reads the indexed a[i], t[i], i[i], ... submission parameters.
+// Submissions that were skipped or banned by the user are left out.
+func parseAsLegacySubmissions(form url.Values) []asScrobble {
+	var scrobbles []asScrobble
+	for i := 0; i < maxScrobblesPerRequest+1; i++ {
+		get := func(name string) string {
+			return form.Get(fmt.Sprintf("%s[%d]", name, i))
+		}
+		if get("a") == "" && get("t") == "" && get("i") == "" {
+			break
+		}
+		if rating := get("r"); rating == "S" || rating == "B" {
+			continue
+		}
+		scrobbles = append(scrobbles, asScrobble{
+			Artist:    get("a"),
+			Track:     get("t"),
+			Album:     get("b"),
+			Timestamp: get("i"),
+			Duration:  get("l"),
+			Mbid:      get("m"),
+		})
+	}
+	return scrobbles
+}
+
+// audioscrobblerLegacyToken computes the handshake authentication token md5(md5(password) + timestamp)
+// from the hex encoded md5 of the password.
+func audioscrobblerLegacyToken(passwordDigest, timestamp string) string {
+	sum := md5.Sum([]byte(passwordDigest + timestamp))
+	return hex.EncodeToString(sum[:])
+}
+
+// newAsLegacySessionID returns a random session ID, in the 32 character hex format that 1.2
+// clients expect.
+func newAsLegacySessionID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("newAsLegacySessionID: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func writeAsLegacy(w http.ResponseWriter, lines ...string) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte(strings.Join(lines, "\n") + "\n"))
+}
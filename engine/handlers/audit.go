@@ -0,0 +1,217 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gabehf/koito/engine/middleware"
+	"github.com/gabehf/koito/internal/db"
+	"github.com/gabehf/koito/internal/logger"
+	"github.com/gabehf/koito/internal/models"
+	"github.com/gabehf/koito/internal/utils"
+)
+
+// recordAudit appends a catalog change made by the requesting user to the audit log. The change
+// has already been made by the time this is called, so failing to record it is only logged.
+func recordAudit(ctx context.Context, store db.DB, action models.AuditAction, target models.AuditTarget, ids []int32, before, after any) {
+	l := logger.FromContext(ctx)
+
+	opts := db.SaveAuditEntryOpts{
+		Action:     action,
+		TargetType: target,
+		TargetIDs:  ids,
+	}
+	if user := middleware.GetUserFromContext(ctx); user != nil {
+		opts.UserID = user.ID
+		opts.Username = user.Username
+	}
+	var err error
+	if before != nil {
+		if opts.Before, err = json.Marshal(before); err != nil {
+			l.Warn().Err(err).Msg("recordAudit: Failed to encode previous value")
+		}
+	}
+	if after != nil {
+		if opts.After, err = json.Marshal(after); err != nil {
+			l.Warn().Err(err).Msg("recordAudit: Failed to encode new value")
+		}
+	}
+
+	if _, err := store.SaveAuditEntry(ctx, opts); err != nil {
+		l.Err(err).Msgf("recordAudit: Failed to record %s of %s %v", action, target, ids)
+	}
+}
+
+// auditItem returns the artist, album or track as it currently is, to be recorded alongside a
+// change to it. nil is returned if it can't be found.
+func auditItem(ctx context.Context, store db.DB, target models.AuditTarget, id int32) any {
+	var item any
+	var err error
+	switch target {
+	case models.AuditTargetArtist:
+		var a *models.Artist
+		if a, err = store.GetArtist(ctx, db.GetArtistOpts{ID: id}); a != nil {
+			item = a
+		}
+	case models.AuditTargetAlbum:
+		var a *models.Album
+		if a, err = store.GetAlbum(ctx, db.GetAlbumOpts{ID: id}); a != nil {
+			item = a
+		}
+	case models.AuditTargetTrack:
+		var t *models.Track
+		if t, err = store.GetTrack(ctx, db.GetTrackOpts{ID: id}); t != nil {
+			item = t
+		}
+	}
+	if err != nil {
+		logger.FromContext(ctx).Debug().Err(err).Msgf("auditItem: Failed to get %s %d", target, id)
+		return nil
+	}
+	return item
+}
+
+// recordItemAudit records a change to an artist, album or track, given the item as it was
+// before the change.
+func recordItemAudit(ctx context.Context, store db.DB, action models.AuditAction, target models.AuditTarget, id int32, before any) {
+	recordAudit(ctx, store, action, target, []int32{id}, before, auditItem(ctx, store, target, id))
+}
+
+// auditAliases returns the current aliases of an artist, album or track, to be recorded
+// alongside a change to them.
+func auditAliases(ctx context.Context, store db.DB, target models.AuditTarget, id int32) any {
+	var aliases []models.Alias
+	var err error
+	switch target {
+	case models.AuditTargetArtist:
+		aliases, err = store.GetAllArtistAliases(ctx, id)
+	case models.AuditTargetAlbum:
+		aliases, err = store.GetAllAlbumAliases(ctx, id)
+	case models.AuditTargetTrack:
+		aliases, err = store.GetAllTrackAliases(ctx, id)
+	}
+	if err != nil {
+		logger.FromContext(ctx).Debug().Err(err).Msgf("auditAliases: Failed to get aliases of %s %d", target, id)
+		return nil
+	}
+	return aliases
+}
+
+// recordAliasAudit records a change to the aliases of an artist, album or track, given the
+// aliases it had before the change.
+func recordAliasAudit(ctx context.Context, store db.DB, action models.AuditAction, target models.AuditTarget, id int32, before any) {
+	recordAudit(ctx, store, action, target, []int32{id}, before, auditAliases(ctx, store, target, id))
+}
+
+// auditArtists returns the current artists of an album or track, to be recorded alongside a
+// change to them.
+func auditArtists(ctx context.Context, store db.DB, target models.AuditTarget, id int32) any {
+	var artists []*models.Artist
+	var err error
+	switch target {
+	case models.AuditTargetAlbum:
+		artists, err = store.GetArtistsForAlbum(ctx, id)
+	case models.AuditTargetTrack:
+		artists, err = store.GetArtistsForTrack(ctx, id)
+	}
+	if err != nil {
+		logger.FromContext(ctx).Debug().Err(err).Msgf("auditArtists: Failed to get artists of %s %d", target, id)
+		return nil
+	}
+	return artists
+}
+
+// recordArtistsAudit records a change to how an artist is credited on an album or track, given
+// the artists it had before the change.
+func recordArtistsAudit(ctx context.Context, store db.DB, action models.AuditAction, target models.AuditTarget, id, artistID int32, before any) {
+	recordAudit(ctx, store, action, target, []int32{id, artistID}, before, auditArtists(ctx, store, target, id))
+}
+
+// auditedMerge records a merge in the audit log, along with both items as they were before it
+// and the target as it is afterwards.
+func auditedMerge(
+	store db.DB,
+	target models.AuditTarget,
+	mergeFn func(ctx context.Context, fromId, toId int32, replaceImage bool) error,
+) func(ctx context.Context, fromId, toId int32, replaceImage bool) error {
+	return func(ctx context.Context, fromId, toId int32, replaceImage bool) error {
+		before := map[string]any{
+			"from": auditItem(ctx, store, target, fromId),
+			"to":   auditItem(ctx, store, target, toId),
+		}
+		if err := mergeFn(ctx, fromId, toId, replaceImage); err != nil {
+			return err
+		}
+		recordAudit(ctx, store, models.AuditActionMerge, target, []int32{fromId, toId}, before, auditItem(ctx, store, target, toId))
+		return nil
+	}
+}
+
+// auditedDelete records a deletion in the audit log, along with the item as it was before it.
+func auditedDelete(
+	store db.DB,
+	target models.AuditTarget,
+	deleteFn func(ctx context.Context, id int32) error,
+) func(ctx context.Context, id int32) error {
+	return func(ctx context.Context, id int32) error {
+		before := auditItem(ctx, store, target, id)
+		if err := deleteFn(ctx, id); err != nil {
+			return err
+		}
+		recordAudit(ctx, store, models.AuditActionDelete, target, []int32{id}, before, nil)
+		return nil
+	}
+}
+
+// GetAuditLogHandler returns a page of the audit log, newest first, optionally filtered by
+// action, target_type, target_id and user_id.
+func GetAuditLogHandler(store db.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		l := logger.FromContext(ctx)
+
+		l.Debug().Msg("GetAuditLogHandler: Received request")
+
+		q := r.URL.Query()
+		opts := db.GetAuditEntriesOpts{
+			Action:     models.AuditAction(strings.TrimSpace(q.Get("action"))),
+			TargetType: models.AuditTarget(strings.TrimSpace(q.Get("target_type"))),
+		}
+		for key, dst := range map[string]*int32{"target_id": &opts.TargetID, "user_id": &opts.UserID} {
+			v := strings.TrimSpace(q.Get(key))
+			if v == "" {
+				continue
+			}
+			id, err := strconv.Atoi(v)
+			if err != nil || id < 1 {
+				l.Debug().Msgf("GetAuditLogHandler: Invalid %s '%s'", key, v)
+				utils.WriteError(w, key+" is invalid", http.StatusBadRequest)
+				return
+			}
+			*dst = int32(id)
+		}
+
+		limit, err := strconv.Atoi(strings.TrimSpace(q.Get("limit")))
+		if err != nil || limit < 1 {
+			limit = defaultLimitSize
+		}
+		opts.Limit = min(limit, maximumLimit)
+		opts.Page, _ = strconv.Atoi(strings.TrimSpace(q.Get("page")))
+		if opts.Page < 1 {
+			opts.Page = 1
+		}
+
+		entries, err := store.GetAuditEntriesPaginated(ctx, opts)
+		if err != nil {
+			l.Err(err).Msg("GetAuditLogHandler: Failed to get audit log")
+			utils.WriteError(w, "failed to get audit log", http.StatusInternalServerError)
+			return
+		}
+
+		l.Debug().Msgf("GetAuditLogHandler: Returning %d audit log entries", len(entries.Items))
+		utils.WriteJSON(w, http.StatusOK, entries)
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"github.com/gabehf/koito/engine/middleware"
 	"github.com/gabehf/koito/internal/db"
 	"github.com/gabehf/koito/internal/logger"
+	"github.com/gabehf/koito/internal/models"
 	"github.com/gabehf/koito/internal/cfg"
 	"github.com/gabehf/koito/internal/utils"
 	"github.com/google/uuid"
@@ -53,32 +54,67 @@ func LoginHandler(store db.DB) http.HandlerFunc {
 			return
 		}
 
-		expiresAt := time.Now().Add(24 * time.Hour)
-		if strings.ToLower(r.FormValue("remember_me")) == "true" {
-			expiresAt = time.Now().Add(30 * 24 * time.Hour)
+		if user.Disabled {
+			l.Debug().Msgf("LoginHandler: User %d is disabled", user.ID)
+			utils.WriteError(w, "account is disabled", http.StatusForbidden)
+			return
 		}
 
-		session, err := store.SaveSession(ctx, user.ID, expiresAt, r.FormValue("remember_me") == "true")
-		if err != nil {
+		remember := strings.ToLower(r.FormValue("remember_me")) == "true"
+
+		if user.TOTPEnabled {
+			if err := startTOTPLogin(w, user, remember); err != nil {
+				l.Error().Err(err).Msg("LoginHandler: Failed to start two-factor login")
+				utils.WriteError(w, "authentication failed", http.StatusInternalServerError)
+				return
+			}
+			l.Debug().Msgf("LoginHandler: User %d must enter a one-time code", user.ID)
+			return
+		}
+
+		if err := startSession(w, r, store, user.ID, remember); err != nil {
 			l.Error().Err(err).Msg("LoginHandler: Failed to create session")
 			utils.WriteError(w, "authentication failed", http.StatusInternalServerError)
 			return
 		}
 
-		http.SetCookie(w, &http.Cookie{
-			Name:     "koito_session",
-			Value:    session.ID.String(),
-			Expires:  expiresAt,
-			Path:     "/",
-			HttpOnly: true,
-			Secure:   cfg.SecureCookiesEnabled(),
-		})
-
 		l.Debug().Msgf("LoginHandler: User %d authenticated", user.ID)
 		w.WriteHeader(http.StatusNoContent)
 	}
 }
 
+// startSession creates a session for the user and sets the session cookie. Persistent sessions
+// last 30 days instead of one.
+func startSession(w http.ResponseWriter, r *http.Request, store db.DB, userID int32, persistent bool) error {
+	expiresAt := time.Now().Add(24 * time.Hour)
+	if persistent {
+		expiresAt = time.Now().Add(30 * 24 * time.Hour)
+	}
+	session, err := store.SaveSession(r.Context(), db.SaveSessionOpts{
+		UserID:     userID,
+		ExpiresAt:  expiresAt,
+		Persistent: persistent,
+		UserAgent:  r.UserAgent(),
+		IP:         utils.ClientIP(r),
+	})
+	if err != nil {
+		return err
+	}
+	setSessionCookie(w, session.ID, expiresAt)
+	return nil
+}
+
+func setSessionCookie(w http.ResponseWriter, sessionID uuid.UUID, expiresAt time.Time) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     "koito_session",
+		Value:    sessionID.String(),
+		Expires:  expiresAt,
+		Path:     "/",
+		HttpOnly: true,
+		Secure:   cfg.SecureCookiesEnabled(),
+	})
+}
+
 func LogoutHandler(store db.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		ctx := r.Context()
@@ -156,8 +192,18 @@ func UpdateUserHandler(store db.DB) http.HandlerFunc {
 		if password := r.FormValue("password"); password != "" {
 			opts.Password = password
 		}
+		if visibility := r.FormValue("visibility"); visibility != "" {
+			switch v := models.ProfileVisibility(visibility); v {
+			case models.ProfileVisibilityPublic, models.ProfileVisibilityUnlisted, models.ProfileVisibilityPrivate:
+				opts.Visibility = v
+			default:
+				l.Debug().Msgf("UpdateUserHandler: Invalid visibility '%s'", visibility)
+				utils.WriteError(w, "visibility must be 'public', 'unlisted' or 'private'", http.StatusBadRequest)
+				return
+			}
+		}
 
-		if opts.Username == "" && opts.Password == "" {
+		if opts.Username == "" && opts.Password == "" && opts.Visibility == "" {
 			l.Debug().Msg("UpdateUserHandler: No update parameters provided")
 			utils.WriteError(w, "no changes specified", http.StatusBadRequest)
 			return
@@ -6,8 +6,10 @@ import (
 	"strconv"
 	"time"
 
+	"github.com/gabehf/koito/engine/middleware"
 	"github.com/gabehf/koito/internal/db"
 	"github.com/gabehf/koito/internal/logger"
+	"github.com/gabehf/koito/internal/models"
 	"github.com/gabehf/koito/internal/utils"
 )
 
@@ -55,15 +57,15 @@ func DeleteHandler(
 }
 
 func DeleteTrackHandler(store db.DB) http.HandlerFunc {
-	return DeleteHandler("DeleteTrackHandler", store.DeleteTrack)
+	return DeleteHandler("DeleteTrackHandler", auditedDelete(store, models.AuditTargetTrack, store.DeleteTrack))
 }
 
 func DeleteArtistHandler(store db.DB) http.HandlerFunc {
-	return DeleteHandler("DeleteArtistHandler", store.DeleteArtist)
+	return DeleteHandler("DeleteArtistHandler", auditedDelete(store, models.AuditTargetArtist, store.DeleteArtist))
 }
 
 func DeleteAlbumHandler(store db.DB) http.HandlerFunc {
-	return DeleteHandler("DeleteAlbumHandler", store.DeleteAlbum)
+	return DeleteHandler("DeleteAlbumHandler", auditedDelete(store, models.AuditTargetAlbum, store.DeleteAlbum))
 }
 
 func DeleteListenHandler(store db.DB) http.HandlerFunc {
@@ -73,6 +75,13 @@ func DeleteListenHandler(store db.DB) http.HandlerFunc {
 
 		l.Debug().Msg("DeleteListenHandler: Received request to delete listen record")
 
+		user := middleware.GetUserFromContext(ctx)
+		if user == nil {
+			l.Debug().Msg("DeleteListenHandler: Invalid user context")
+			utils.WriteError(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
 		trackIDStr := r.URL.Query().Get("track_id")
 		if trackIDStr == "" {
 			l.Debug().Msg("DeleteListenHandler: Missing track ID in request")
@@ -103,7 +112,7 @@ func DeleteListenHandler(store db.DB) http.HandlerFunc {
 
 		l.Debug().Msgf("DeleteListenHandler: Deleting listen record for track ID %d at timestamp %d", trackID, unix)
 
-		err = store.DeleteListen(ctx, int32(trackID), time.Unix(unix, 0))
+		err = store.DeleteListen(ctx, user.ID, int32(trackID), time.Unix(unix, 0))
 		if err != nil {
 			l.Err(err).Msg("DeleteListenHandler: Failed to delete listen record")
 			utils.WriteError(w, "failed to delete listen", http.StatusInternalServerError)
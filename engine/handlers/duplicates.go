@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gabehf/koito/internal/catalog"
+	"github.com/gabehf/koito/internal/cfg"
+	"github.com/gabehf/koito/internal/db"
+	"github.com/gabehf/koito/internal/logger"
+	"github.com/gabehf/koito/internal/utils"
+)
+
+type DuplicateListensResponse struct {
+	Groups    []catalog.DuplicateListenGroup `json:"groups"`
+	Removable int                            `json:"removable"`
+}
+
+type RemoveDuplicateListensResponse struct {
+	Removed int `json:"removed"`
+}
+
+// dedupePolicyFromRequest uses the optional window parameter when present, falling back
+// to the configured policy.
+func dedupePolicyFromRequest(r *http.Request) (catalog.DedupePolicy, bool) {
+	window := r.FormValue("window")
+	if window == "" {
+		policy := catalog.ConfiguredDedupePolicy()
+		return policy, policy.Enabled()
+	}
+	byDuration, fixed, err := cfg.ParseDedupeWindow(window)
+	if err != nil {
+		return catalog.DedupePolicy{}, false
+	}
+	policy := catalog.NewDedupePolicy(byDuration, fixed)
+	return policy, policy.Enabled()
+}
+
+// GetDuplicateListensHandler scans listening history for duplicate listens without
+// removing anything.
+func GetDuplicateListensHandler(store db.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		l := logger.FromContext(ctx)
+
+		l.Debug().Msg("GetDuplicateListensHandler: Received request")
+
+		policy, ok := dedupePolicyFromRequest(r)
+		if !ok {
+			l.Debug().Msg("GetDuplicateListensHandler: No valid duplicate window")
+			utils.WriteError(w, "window must be 'duration' or a number of seconds", http.StatusBadRequest)
+			return
+		}
+
+		groups, err := catalog.FindDuplicateListens(ctx, store, policy)
+		if err != nil {
+			l.Err(err).Msg("GetDuplicateListensHandler: Failed to scan for duplicate listens")
+			utils.WriteError(w, "failed to scan for duplicate listens", http.StatusInternalServerError)
+			return
+		}
+
+		resp := DuplicateListensResponse{Groups: groups}
+		for _, g := range groups {
+			resp.Removable += len(g.Remove)
+		}
+
+		l.Debug().Msgf("GetDuplicateListensHandler: Found %d duplicate groups", len(groups))
+		utils.WriteJSON(w, http.StatusOK, resp)
+	}
+}
+
+// DeleteDuplicateListensHandler removes every duplicate listen that
+// GetDuplicateListensHandler reports for the same window.
+func DeleteDuplicateListensHandler(store db.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		l := logger.FromContext(ctx)
+
+		l.Debug().Msg("DeleteDuplicateListensHandler: Received request")
+
+		policy, ok := dedupePolicyFromRequest(r)
+		if !ok {
+			l.Debug().Msg("DeleteDuplicateListensHandler: No valid duplicate window")
+			utils.WriteError(w, "window must be 'duration' or a number of seconds", http.StatusBadRequest)
+			return
+		}
+
+		removed, err := catalog.RemoveDuplicateListens(ctx, store, policy)
+		if err != nil {
+			l.Err(err).Msg("DeleteDuplicateListensHandler: Failed to remove duplicate listens")
+			utils.WriteError(w, "failed to remove duplicate listens", http.StatusInternalServerError)
+			return
+		}
+
+		l.Debug().Msgf("DeleteDuplicateListensHandler: Removed %d duplicate listens", removed)
+		utils.WriteJSON(w, http.StatusOK, RemoveDuplicateListensResponse{Removed: removed})
+	}
+}
@@ -22,6 +22,17 @@ func GenreStatsHandler(store db.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		l := logger.FromContext(r.Context())
 
+		userID, err := userIDFromRequest(r.Context(), store, r)
+		if err != nil {
+			l.Err(err).Msg("GenreStatsHandler: Failed to get user")
+			utils.WriteError(w, "failed to get user", http.StatusInternalServerError)
+			return
+		}
+		if userID == 0 {
+			utils.WriteError(w, "user not found", http.StatusNotFound)
+			return
+		}
+
 		var period db.Period
 		switch strings.ToLower(r.URL.Query().Get("period")) {
 		case "day":
@@ -43,12 +54,11 @@ func GenreStatsHandler(store db.DB) http.HandlerFunc {
 		metric := strings.ToLower(r.URL.Query().Get("metric"))
 
 		var stats []db.GenreStat
-		var err error
 
 		if metric == "time" {
-			stats, err = store.GetGenreStatsByTimeListened(r.Context(), timeframe)
+			stats, err = store.GetGenreStatsByTimeListened(r.Context(), userID, timeframe)
 		} else {
-			stats, err = store.GetGenreStatsByListenCount(r.Context(), timeframe)
+			stats, err = store.GetGenreStatsByListenCount(r.Context(), userID, timeframe)
 		}
 
 		if err != nil {
@@ -30,9 +30,20 @@ func GetAlbumHandler(store db.DB) func(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
+		userID, err := userIDFromRequest(ctx, store, r)
+		if err != nil {
+			l.Err(err).Msg("GetAlbumHandler: Failed to get user")
+			utils.WriteError(w, "failed to get user", http.StatusInternalServerError)
+			return
+		}
+		if userID == 0 {
+			utils.WriteError(w, "user not found", http.StatusNotFound)
+			return
+		}
+
 		l.Debug().Msgf("GetAlbumHandler: Retrieving album with ID %d", id)
 
-		album, err := store.GetAlbum(ctx, db.GetAlbumOpts{ID: int32(id)})
+		album, err := store.GetAlbum(ctx, db.GetAlbumOpts{ID: int32(id), UserID: userID})
 		if err != nil {
 			l.Err(err).Msgf("GetAlbumHandler: Failed to retrieve album with ID %d", id)
 			utils.WriteError(w, "album with specified id could not be found", http.StatusNotFound)
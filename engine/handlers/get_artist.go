@@ -30,9 +30,20 @@ func GetArtistHandler(store db.DB) func(w http.ResponseWriter, r *http.Request)
 			return
 		}
 
+		userID, err := userIDFromRequest(ctx, store, r)
+		if err != nil {
+			l.Err(err).Msg("GetArtistHandler: Failed to get user")
+			utils.WriteError(w, "failed to get user", http.StatusInternalServerError)
+			return
+		}
+		if userID == 0 {
+			utils.WriteError(w, "user not found", http.StatusNotFound)
+			return
+		}
+
 		l.Debug().Msgf("GetArtistHandler: Retrieving artist with ID %d", id)
 
-		artist, err := store.GetArtist(ctx, db.GetArtistOpts{ID: int32(id)})
+		artist, err := store.GetArtist(ctx, db.GetArtistOpts{ID: int32(id), UserID: userID})
 		if err != nil {
 			l.Err(err).Msgf("GetArtistHandler: Failed to retrieve artist with ID %d", id)
 			utils.WriteError(w, "artist with specified id could not be found", http.StatusNotFound)
@@ -79,7 +79,19 @@ func GetListenActivityHandler(store db.DB) func(w http.ResponseWriter, r *http.R
 			step = db.StepDay
 		}
 
+		userID, err := userIDFromRequest(ctx, store, r)
+		if err != nil {
+			l.Err(err).Msg("GetListenActivityHandler: Failed to get user")
+			utils.WriteError(w, "failed to get user", http.StatusInternalServerError)
+			return
+		}
+		if userID == 0 {
+			utils.WriteError(w, "user not found", http.StatusNotFound)
+			return
+		}
+
 		opts := db.ListenActivityOpts{
+			UserID:   userID,
 			Step:     step,
 			Range:    _range,
 			Month:    month,
@@ -162,6 +174,9 @@ func normalizeToStep(t time.Time, step db.StepInterval) time.Time {
 	case db.StepMonth:
 		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
 
+	case db.StepYear:
+		return time.Date(t.Year(), 1, 1, 0, 0, 0, 0, t.Location())
+
 	default:
 		return t
 	}
@@ -175,6 +190,8 @@ func addStep(t time.Time, step db.StepInterval) time.Time {
 		return t.AddDate(0, 0, 7)
 	case db.StepMonth:
 		return t.AddDate(0, 1, 0)
+	case db.StepYear:
+		return t.AddDate(1, 0, 0)
 	default:
 		return t.AddDate(0, 0, 1)
 	}
@@ -22,6 +22,19 @@ func GetListensHandler(store db.DB) func(w http.ResponseWriter, r *http.Request)
 			return
 		}
 
+		userID, err := userIDFromRequest(ctx, store, r)
+		if err != nil {
+			l.Err(err).Msg("GetListensHandler: Failed to get user")
+			utils.WriteError(w, "failed to get user", http.StatusInternalServerError)
+			return
+		}
+		if userID == 0 {
+			utils.WriteError(w, "user not found", http.StatusNotFound)
+			return
+		}
+
+		opts.UserID = userID
+
 		l.Debug().Msgf("GetListensHandler: Retrieving listens with options: %+v", opts)
 
 		listens, err := store.GetListensPaginated(ctx, opts)
@@ -3,7 +3,6 @@ package handlers
 import (
 	"net/http"
 
-	"github.com/gabehf/koito/engine/middleware"
 	"github.com/gabehf/koito/internal/db"
 	"github.com/gabehf/koito/internal/logger"
 	"github.com/gabehf/koito/internal/summary"
@@ -17,9 +16,15 @@ func SummaryHandler(store db.DB) http.HandlerFunc {
 
 		l.Debug().Msg("SummaryHandler: Received request to retrieve summary")
 
-		userID := int32(1)
-		if u := middleware.GetUserFromContext(ctx); u != nil {
-			userID = u.ID
+		userID, err := userIDFromRequest(ctx, store, r)
+		if err != nil {
+			l.Err(err).Msg("SummaryHandler: Failed to get user")
+			utils.WriteError(w, "failed to get user", http.StatusInternalServerError)
+			return
+		}
+		if userID == 0 {
+			utils.WriteError(w, "user not found", http.StatusNotFound)
+			return
 		}
 
 		timeframe := TimeframeFromRequest(r)
@@ -22,6 +22,19 @@ func GetTopArtistsHandler(store db.DB) func(w http.ResponseWriter, r *http.Reque
 			return
 		}
 
+		userID, err := userIDFromRequest(ctx, store, r)
+		if err != nil {
+			l.Err(err).Msg("GetTopArtistsHandler: Failed to get user")
+			utils.WriteError(w, "failed to get user", http.StatusInternalServerError)
+			return
+		}
+		if userID == 0 {
+			utils.WriteError(w, "user not found", http.StatusNotFound)
+			return
+		}
+
+		opts.UserID = userID
+
 		l.Debug().Msgf("GetTopArtistsHandler: Retrieving top artists with options: %+v", opts)
 
 		artists, err := store.GetTopArtistsPaginated(ctx, opts)
@@ -22,6 +22,19 @@ func GetTopTracksHandler(store db.DB) func(w http.ResponseWriter, r *http.Reques
 			return
 		}
 
+		userID, err := userIDFromRequest(ctx, store, r)
+		if err != nil {
+			l.Err(err).Msg("GetTopTracksHandler: Failed to get user")
+			utils.WriteError(w, "failed to get user", http.StatusInternalServerError)
+			return
+		}
+		if userID == 0 {
+			utils.WriteError(w, "user not found", http.StatusNotFound)
+			return
+		}
+
+		opts.UserID = userID
+
 		l.Debug().Msgf("GetTopTracksHandler: Retrieving top tracks with options: %+v", opts)
 
 		tracks, err := store.GetTopTracksPaginated(ctx, opts)
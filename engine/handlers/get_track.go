@@ -30,9 +30,20 @@ func GetTrackHandler(store db.DB) func(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
+		userID, err := userIDFromRequest(ctx, store, r)
+		if err != nil {
+			l.Err(err).Msg("GetTrackHandler: Failed to get user")
+			utils.WriteError(w, "failed to get user", http.StatusInternalServerError)
+			return
+		}
+		if userID == 0 {
+			utils.WriteError(w, "user not found", http.StatusNotFound)
+			return
+		}
+
 		l.Debug().Msgf("GetTrackHandler: Retrieving track with ID %d", id)
 
-		track, err := store.GetTrack(ctx, db.GetTrackOpts{ID: int32(id)})
+		track, err := store.GetTrack(ctx, db.GetTrackOpts{ID: int32(id), UserID: userID})
 		if err != nil {
 			l.Err(err).Msgf("GetTrackHandler: Failed to retrieve track with ID %d", id)
 			utils.WriteError(w, "track with specified id could not be found", http.StatusNotFound)
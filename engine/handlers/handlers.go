@@ -2,6 +2,7 @@
 package handlers
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"net/http"
@@ -10,9 +11,11 @@ import (
 	"time"
 	_ "time/tzdata"
 
+	"github.com/gabehf/koito/engine/middleware"
 	"github.com/gabehf/koito/internal/cfg"
 	"github.com/gabehf/koito/internal/db"
 	"github.com/gabehf/koito/internal/logger"
+	"github.com/gabehf/koito/internal/models"
 	"github.com/gabehf/koito/internal/utils"
 )
 
@@ -110,6 +113,24 @@ func OptsFromRequest(r *http.Request) (db.GetItemsOpts, error) {
 		return db.GetItemsOpts{}, fmt.Errorf("from must be less than or equal to to")
 	}
 
+	excludeFeatured := false
+	if v := strings.TrimSpace(r.URL.Query().Get("exclude_featured")); v != "" {
+		parsed, ok := utils.ParseBool(v)
+		if !ok {
+			return db.GetItemsOpts{}, fmt.Errorf("invalid exclude_featured parameter")
+		}
+		excludeFeatured = parsed
+	}
+
+	byReleaseGroup := false
+	if v := strings.TrimSpace(r.URL.Query().Get("by_release_group")); v != "" {
+		parsed, ok := utils.ParseBool(v)
+		if !ok {
+			return db.GetItemsOpts{}, fmt.Errorf("invalid by_release_group parameter")
+		}
+		byReleaseGroup = parsed
+	}
+
 	tf := TimeframeFromRequest(r)
 	if week != 0 {
 		tf.Week = week
@@ -131,20 +152,90 @@ func OptsFromRequest(r *http.Request) (db.GetItemsOpts, error) {
 		limit, page, tf.Week, tf.Month, tf.Year, tf.FromUnix, tf.ToUnix, artistId, albumId, trackId, tf.Period)
 
 	return db.GetItemsOpts{
-		Limit:     limit,
-		Timeframe: tf,
-		Page:      page,
-		Week:      week,
-		Month:     month,
-		Year:      year,
-		From:      from,
-		To:        to,
-		ArtistID:  artistId,
-		AlbumID:   albumId,
-		TrackID:   trackId,
+		Limit:           limit,
+		Timeframe:       tf,
+		Page:            page,
+		Week:            week,
+		Month:           month,
+		Year:            year,
+		From:            from,
+		To:              to,
+		ArtistID:        artistId,
+		AlbumID:         albumId,
+		TrackID:         trackId,
+		ExcludeFeatured: excludeFeatured,
+		ByReleaseGroup:  byReleaseGroup,
 	}, nil
 }
 
+// requestBaseURL returns the scheme and host the client used to reach Koito, taking a TLS
+// terminating reverse proxy into account.
+func requestBaseURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil || strings.EqualFold(r.Header.Get("X-Forwarded-Proto"), "https") {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host
+}
+
+// userForSubmitKey resolves the owner of an API key passed to one of the scrobbling APIs, and
+// records that the key was used. Returns nil, nil when the key does not exist, has expired, or
+// is not allowed to submit listens.
+func userForSubmitKey(ctx context.Context, store db.DB, r *http.Request, token string) (*models.User, error) {
+	l := logger.FromContext(ctx)
+
+	key, err := store.GetApiKey(ctx, token)
+	if err != nil {
+		return nil, fmt.Errorf("userForSubmitKey: %w", err)
+	}
+	if key == nil {
+		return nil, nil
+	}
+	if err := store.UpdateApiKeyLastUsed(ctx, key.ID, utils.ClientIP(r)); err != nil {
+		l.Err(err).Msg("userForSubmitKey: Failed to record api key usage")
+	}
+	if !key.Scope.Allows(models.ApiKeyScopeSubmit) {
+		l.Debug().Msgf("userForSubmitKey: API key %d has scope '%s' and can't submit listens", key.ID, key.Scope)
+		return nil, nil
+	}
+	u, err := store.GetUserByApiKey(ctx, token)
+	if err != nil {
+		return nil, fmt.Errorf("userForSubmitKey: %w", err)
+	}
+	return u, nil
+}
+
+// userIDFromRequest picks whose listening data is requested: the profile being viewed,
+// then the user named by the username parameter, then the authenticated user, then the
+// default user. It returns 0 when the user does not exist or their profile can't be viewed.
+func userIDFromRequest(ctx context.Context, store db.DB, r *http.Request) (int32, error) {
+	if profile := middleware.GetProfileFromContext(ctx); profile != nil {
+		return profile.ID, nil
+	}
+	if username := r.URL.Query().Get("username"); username != "" {
+		user, err := store.GetUserByUsername(ctx, username)
+		if err != nil {
+			return 0, fmt.Errorf("userIDFromRequest: %w", err)
+		}
+		if !middleware.CanViewProfile(middleware.GetUserFromContext(ctx), user) {
+			return 0, nil
+		}
+		return user.ID, nil
+	}
+	if user := middleware.GetUserFromContext(ctx); user != nil {
+		return user.ID, nil
+	}
+	// without anyone to go by, the first user's statistics are shown, as long as anyone may see them
+	owner, err := store.GetUserByID(ctx, 1)
+	if err != nil {
+		return 0, fmt.Errorf("userIDFromRequest: %w", err)
+	}
+	if !middleware.CanViewProfile(nil, owner) {
+		return 0, nil
+	}
+	return owner.ID, nil
+}
+
 func isDateRangeValidationError(err error) bool {
 	var dateRangeErr *utils.DateRangeValidationError
 	return errors.As(err, &dateRangeErr)
@@ -28,7 +28,19 @@ func GetInterestHandler(store db.DB) func(w http.ResponseWriter, r *http.Request
 			return
 		}
 
+		userID, err := userIDFromRequest(ctx, store, r)
+		if err != nil {
+			l.Err(err).Msg("GetInterestHandler: Failed to get user")
+			utils.WriteError(w, "failed to get user", http.StatusInternalServerError)
+			return
+		}
+		if userID == 0 {
+			utils.WriteError(w, "user not found", http.StatusNotFound)
+			return
+		}
+
 		opts := db.GetInterestOpts{
+			UserID:   userID,
 			Buckets:  buckets,
 			AlbumID:  int32(parsed.AlbumID),
 			ArtistID: int32(parsed.ArtistID),
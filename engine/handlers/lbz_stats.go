@@ -0,0 +1,356 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gabehf/koito/internal/db"
+	"github.com/gabehf/koito/internal/logger"
+	"github.com/gabehf/koito/internal/models"
+	"github.com/gabehf/koito/internal/utils"
+)
+
+const (
+	lbzDefaultStatsCount = 25
+	lbzMaxStatsCount     = 100
+)
+
+type LbzStatsPayload struct {
+	UserID      string `json:"user_id"`
+	Range       string `json:"range"`
+	FromTs      int64  `json:"from_ts"`
+	ToTs        int64  `json:"to_ts"`
+	LastUpdated int64  `json:"last_updated"`
+	Count       int    `json:"count,omitempty"`
+	Offset      int    `json:"offset"`
+
+	TotalArtistCount    int64 `json:"total_artist_count,omitempty"`
+	TotalReleaseCount   int64 `json:"total_release_count,omitempty"`
+	TotalRecordingCount int64 `json:"total_recording_count,omitempty"`
+
+	Artists           []LbzStatsArtist    `json:"artists,omitempty"`
+	Releases          []LbzStatsRelease   `json:"releases,omitempty"`
+	Recordings        []LbzStatsRecording `json:"recordings,omitempty"`
+	ListeningActivity []LbzStatsActivity  `json:"listening_activity,omitempty"`
+}
+
+type LbzStatsResponse struct {
+	Payload LbzStatsPayload `json:"payload"`
+}
+
+type LbzStatsArtist struct {
+	ArtistMBID  string `json:"artist_mbid,omitempty"`
+	ArtistName  string `json:"artist_name"`
+	ListenCount int64  `json:"listen_count"`
+}
+
+type LbzStatsRelease struct {
+	ArtistMBIDs []string `json:"artist_mbids"`
+	ArtistName  string   `json:"artist_name"`
+	ReleaseMBID string   `json:"release_mbid,omitempty"`
+	ReleaseName string   `json:"release_name"`
+	ListenCount int64    `json:"listen_count"`
+}
+
+type LbzStatsRecording struct {
+	ArtistMBIDs   []string `json:"artist_mbids"`
+	ArtistName    string   `json:"artist_name"`
+	RecordingMBID string   `json:"recording_mbid,omitempty"`
+	ReleaseName   string   `json:"release_name,omitempty"`
+	TrackName     string   `json:"track_name"`
+	ListenCount   int64    `json:"listen_count"`
+}
+
+type LbzStatsActivity struct {
+	FromTs      int64  `json:"from_ts"`
+	ToTs        int64  `json:"to_ts"`
+	TimeRange   string `json:"time_range"`
+	ListenCount int64  `json:"listen_count"`
+}
+
+// lbzStatsRequest holds the parameters shared by all of the ListenBrainz stats endpoints.
+type lbzStatsRequest struct {
+	User   *models.User
+	Range  string
+	Period db.Period
+	Count  int
+	Offset int
+}
+
+// itemsOpts returns options that fetch everything up to offset + count, since
+// ListenBrainz paginates by offset rather than by page.
+func (s lbzStatsRequest) itemsOpts() db.GetItemsOpts {
+	return db.GetItemsOpts{
+		UserID:    s.User.ID,
+		Limit:     s.Offset + s.Count,
+		Page:      1,
+		Timeframe: db.PeriodToTimeframe(s.Period),
+	}
+}
+
+func (s lbzStatsRequest) payload() LbzStatsPayload {
+	from, to := db.TimeframeToTimeRange(db.PeriodToTimeframe(s.Period))
+	p := LbzStatsPayload{
+		UserID:      s.User.Username,
+		Range:       s.Range,
+		ToTs:        to.Unix(),
+		LastUpdated: time.Now().Unix(),
+		Offset:      s.Offset,
+	}
+	if !from.IsZero() {
+		p.FromTs = from.Unix()
+	}
+	return p
+}
+
+// LbzStatsArtistsHandler serves GET /1/stats/user/{user}/artists.
+func LbzStatsArtistsHandler(store db.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		l := logger.FromContext(ctx)
+
+		l.Debug().Msg("LbzStatsArtistsHandler: Received request")
+
+		req, ok := lbzStatsRequestFromHttp(ctx, store, w, r)
+		if !ok {
+			return
+		}
+
+		artists, err := store.GetTopArtistsPaginated(ctx, req.itemsOpts())
+		if err != nil {
+			l.Err(err).Msg("LbzStatsArtistsHandler: Failed to get top artists")
+			utils.WriteError(w, "failed to get top artists", http.StatusInternalServerError)
+			return
+		}
+
+		payload := req.payload()
+		payload.TotalArtistCount = artists.TotalCount
+		payload.Artists = []LbzStatsArtist{}
+		for _, item := range lbzOffset(artists.Items, req.Offset) {
+			a := LbzStatsArtist{
+				ArtistName:  item.Item.Name,
+				ListenCount: item.ListenCount,
+			}
+			if item.Item.MbzID != nil {
+				a.ArtistMBID = item.Item.MbzID.String()
+			}
+			payload.Artists = append(payload.Artists, a)
+		}
+		payload.Count = len(payload.Artists)
+
+		utils.WriteJSON(w, http.StatusOK, LbzStatsResponse{Payload: payload})
+	}
+}
+
+// LbzStatsReleasesHandler serves GET /1/stats/user/{user}/releases.
+func LbzStatsReleasesHandler(store db.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		l := logger.FromContext(ctx)
+
+		l.Debug().Msg("LbzStatsReleasesHandler: Received request")
+
+		req, ok := lbzStatsRequestFromHttp(ctx, store, w, r)
+		if !ok {
+			return
+		}
+
+		albums, err := store.GetTopAlbumsPaginated(ctx, req.itemsOpts())
+		if err != nil {
+			l.Err(err).Msg("LbzStatsReleasesHandler: Failed to get top albums")
+			utils.WriteError(w, "failed to get top releases", http.StatusInternalServerError)
+			return
+		}
+
+		payload := req.payload()
+		payload.TotalReleaseCount = albums.TotalCount
+		payload.Releases = []LbzStatsRelease{}
+		for _, item := range lbzOffset(albums.Items, req.Offset) {
+			rel := LbzStatsRelease{
+				ArtistMBIDs: []string{},
+				ArtistName:  strings.Join(utils.FlattenSimpleArtistNames(item.Item.Artists), ", "),
+				ReleaseName: item.Item.Title,
+				ListenCount: item.ListenCount,
+			}
+			if item.Item.MbzID != nil {
+				rel.ReleaseMBID = item.Item.MbzID.String()
+			}
+			payload.Releases = append(payload.Releases, rel)
+		}
+		payload.Count = len(payload.Releases)
+
+		utils.WriteJSON(w, http.StatusOK, LbzStatsResponse{Payload: payload})
+	}
+}
+
+// LbzStatsRecordingsHandler serves GET /1/stats/user/{user}/recordings.
+func LbzStatsRecordingsHandler(store db.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		l := logger.FromContext(ctx)
+
+		l.Debug().Msg("LbzStatsRecordingsHandler: Received request")
+
+		req, ok := lbzStatsRequestFromHttp(ctx, store, w, r)
+		if !ok {
+			return
+		}
+
+		tracks, err := store.GetTopTracksPaginated(ctx, req.itemsOpts())
+		if err != nil {
+			l.Err(err).Msg("LbzStatsRecordingsHandler: Failed to get top tracks")
+			utils.WriteError(w, "failed to get top recordings", http.StatusInternalServerError)
+			return
+		}
+
+		payload := req.payload()
+		payload.TotalRecordingCount = tracks.TotalCount
+		payload.Recordings = []LbzStatsRecording{}
+		albumTitles := make(map[int32]string)
+		for _, item := range lbzOffset(tracks.Items, req.Offset) {
+			meta := lbzTrackMetaFromTrack(ctx, store, *item.Item, albumTitles)
+			payload.Recordings = append(payload.Recordings, LbzStatsRecording{
+				ArtistMBIDs:   []string{},
+				ArtistName:    meta.ArtistName,
+				RecordingMBID: meta.AdditionalInfo.RecordingMBID,
+				ReleaseName:   meta.ReleaseName,
+				TrackName:     meta.TrackName,
+				ListenCount:   item.ListenCount,
+			})
+		}
+		payload.Count = len(payload.Recordings)
+
+		utils.WriteJSON(w, http.StatusOK, LbzStatsResponse{Payload: payload})
+	}
+}
+
+// LbzStatsListeningActivityHandler serves GET /1/stats/user/{user}/listening-activity.
+// Weeks and months are split into days, years into months, and all time into years.
+func LbzStatsListeningActivityHandler(store db.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		l := logger.FromContext(ctx)
+
+		l.Debug().Msg("LbzStatsListeningActivityHandler: Received request")
+
+		req, ok := lbzStatsRequestFromHttp(ctx, store, w, r)
+		if !ok {
+			return
+		}
+
+		opts := db.ListenActivityOpts{UserID: req.User.ID, Timezone: time.UTC}
+		var labelFormat string
+		switch req.Period {
+		case db.PeriodWeek:
+			opts.Step, opts.Range, labelFormat = db.StepDay, 7, "Monday 02 January 2006"
+		case db.PeriodMonth:
+			opts.Step, opts.Range, labelFormat = db.StepDay, 30, "02 January 2006"
+		case db.PeriodYear:
+			opts.Step, opts.Range, labelFormat = db.StepMonth, 12, "January 2006"
+		default:
+			// find the oldest listen to know how many years to go back
+			listens, err := store.GetListensPaginated(ctx, db.GetItemsOpts{UserID: req.User.ID, Limit: 1, Timeframe: db.PeriodToTimeframe(db.PeriodAllTime)})
+			if err != nil {
+				l.Err(err).Msg("LbzStatsListeningActivityHandler: Failed to get listens")
+				utils.WriteError(w, "failed to get listening activity", http.StatusInternalServerError)
+				return
+			}
+			oldest := time.Now()
+			if listens.TotalCount > 0 {
+				listens, err = store.GetListensPaginated(ctx, db.GetItemsOpts{UserID: req.User.ID, Limit: 1, Page: int(listens.TotalCount), Timeframe: db.PeriodToTimeframe(db.PeriodAllTime)})
+				if err != nil {
+					l.Err(err).Msg("LbzStatsListeningActivityHandler: Failed to get oldest listen")
+					utils.WriteError(w, "failed to get listening activity", http.StatusInternalServerError)
+					return
+				}
+				if len(listens.Items) > 0 {
+					oldest = listens.Items[0].Time
+				}
+			}
+			opts.Step, opts.Range, labelFormat = db.StepYear, time.Now().Year()-oldest.Year()+1, "2006"
+		}
+
+		activity, err := store.GetListenActivity(ctx, opts)
+		if err != nil {
+			l.Err(err).Msg("LbzStatsListeningActivityHandler: Failed to get listen activity")
+			utils.WriteError(w, "failed to get listening activity", http.StatusInternalServerError)
+			return
+		}
+		activity = processActivity(activity, opts)
+
+		payload := req.payload()
+		payload.Offset = 0
+		payload.ListeningActivity = []LbzStatsActivity{}
+		for _, item := range activity {
+			end := addStep(item.Start, opts.Step)
+			payload.ListeningActivity = append(payload.ListeningActivity, LbzStatsActivity{
+				FromTs:      item.Start.Unix(),
+				ToTs:        end.Unix() - 1,
+				TimeRange:   item.Start.Format(labelFormat),
+				ListenCount: item.Listens,
+			})
+		}
+		if len(activity) > 0 {
+			payload.FromTs = activity[0].Start.Unix()
+		}
+
+		utils.WriteJSON(w, http.StatusOK, LbzStatsResponse{Payload: payload})
+	}
+}
+
+// lbzStatsRequestFromHttp parses the user, range, count and offset of a stats request,
+// writing an error response and returning false if any of them are invalid.
+func lbzStatsRequestFromHttp(ctx context.Context, store db.DB, w http.ResponseWriter, r *http.Request) (lbzStatsRequest, bool) {
+	l := logger.FromContext(ctx)
+
+	user, ok := lbzUserFromPath(ctx, store, w, r)
+	if !ok {
+		return lbzStatsRequest{}, false
+	}
+	req := lbzStatsRequest{User: user, Count: lbzDefaultStatsCount}
+
+	q := r.URL.Query()
+	req.Range = strings.ToLower(q.Get("range"))
+	switch req.Range {
+	case "":
+		req.Range = string(db.PeriodAllTime)
+		req.Period = db.PeriodAllTime
+	case "week", "month", "year", "all_time":
+		req.Period = db.Period(req.Range)
+	default:
+		l.Debug().Msgf("Invalid range '%s'", req.Range)
+		utils.WriteError(w, "Invalid range: "+req.Range, http.StatusBadRequest)
+		return lbzStatsRequest{}, false
+	}
+
+	if c := q.Get("count"); c != "" {
+		n, err := strconv.Atoi(c)
+		if err != nil || n < 1 {
+			l.Debug().Msg("Invalid count parameter")
+			utils.WriteError(w, "count must be a positive integer", http.StatusBadRequest)
+			return lbzStatsRequest{}, false
+		}
+		req.Count = min(n, lbzMaxStatsCount)
+	}
+	if o := q.Get("offset"); o != "" {
+		n, err := strconv.Atoi(o)
+		if err != nil || n < 0 {
+			l.Debug().Msg("Invalid offset parameter")
+			utils.WriteError(w, "offset must be a non-negative integer", http.StatusBadRequest)
+			return lbzStatsRequest{}, false
+		}
+		req.Offset = n
+	}
+
+	return req, true
+}
+
+func lbzOffset[T any](items []T, offset int) []T {
+	if offset >= len(items) {
+		return nil
+	}
+	return items[offset:]
+}
@@ -11,13 +11,13 @@ import (
 
 	"github.com/gabehf/koito/engine/middleware"
 	"github.com/gabehf/koito/internal/catalog"
-	"github.com/gabehf/koito/internal/cfg"
 	"github.com/gabehf/koito/internal/db"
 	"github.com/gabehf/koito/internal/logger"
 	"github.com/gabehf/koito/internal/mbz"
+	"github.com/gabehf/koito/internal/relay"
+	"github.com/gabehf/koito/internal/rules"
 	"github.com/gabehf/koito/internal/utils"
 	"github.com/google/uuid"
-	"github.com/rs/zerolog"
 	"golang.org/x/sync/singleflight"
 )
 
@@ -100,10 +100,6 @@ func LbzSubmitListenHandler(store db.DB, mbzc mbz.MusicBrainzCaller) func(w http
 		}
 
 		decoder := json.NewDecoder(bytes.NewBuffer(requestBytes))
-		if cfg.LbzRelayEnabled() {
-			go doLbzRelay(requestBytes, l)
-		}
-
 		if err := decoder.Decode(&req); err != nil {
 			l.Err(err).Msg("LbzSubmitListenHandler: Failed to decode request")
 			utils.WriteError(w, "failed to decode request", http.StatusBadRequest)
@@ -137,18 +133,60 @@ func LbzSubmitListenHandler(store db.DB, mbzc mbz.MusicBrainzCaller) func(w http
 			return
 		}
 
+		if req.ListenType != ListenTypePlayingNow && req.ListenType != ListenTypeSingle && req.ListenType != ListenTypeImport {
+			l.Debug().Msg("LbzSubmitListenHandler: No listen type provided, assuming 'single'")
+			req.ListenType = "single"
+		}
+
+		rewriteRules, err := rewriteRulesForUser(r.Context(), store, u.ID)
+		if err != nil {
+			// submit the listens as they are rather than losing them
+			l.Err(err).Msg("LbzSubmitListenHandler: Failed to load rewrite rules")
+		}
+
+		// rewrite and validate every listen before anything is saved or relayed
+		listens := make([]LbzSubmitListenPayload, 0, len(req.Payload))
+		rewritten := false
 		for _, payload := range req.Payload {
+			if res := applyRewriteRules(rewriteRules, &payload.TrackMeta); res.Dropped {
+				l.Debug().Msgf("LbzSubmitListenHandler: Listen dropped by rewrite rule %d", res.Applied[len(res.Applied)-1])
+				rewritten = true
+				continue
+			} else if len(res.Applied) > 0 {
+				l.Debug().Msgf("LbzSubmitListenHandler: Applied rewrite rules %v", res.Applied)
+				rewritten = true
+			}
+
 			if payload.TrackMeta.ArtistName == "" || payload.TrackMeta.TrackName == "" {
 				l.Debug().Msg("LbzSubmitListenHandler: Artist name or track name are missing")
 				utils.WriteError(w, "Artist name or track name are missing", http.StatusBadRequest)
 				return
 			}
+			listens = append(listens, payload)
+		}
 
-			if req.ListenType != ListenTypePlayingNow && req.ListenType != ListenTypeSingle && req.ListenType != ListenTypeImport {
-				l.Debug().Msg("LbzSubmitListenHandler: No listen type provided, assuming 'single'")
-				req.ListenType = "single"
+		if len(listens) > 0 {
+			// relay the request as it was sent unless rewrite rules changed it, so that fields
+			// Koito does not know about are passed on
+			relayBytes := requestBytes
+			if rewritten {
+				b, err := json.Marshal(LbzSubmitListenRequest{ListenType: req.ListenType, Payload: listens})
+				if err != nil {
+					l.Err(err).Msg("LbzSubmitListenHandler: Failed to encode listens for relay")
+				}
+				relayBytes = b
+			}
+			if relayBytes != nil {
+				if err := relay.EnqueueDefault(r.Context(), store, u.ID, relayBytes); err != nil {
+					l.Err(err).Msg("LbzSubmitListenHandler: Failed to enqueue ListenBrainz relay request")
+				}
+				if err := relay.Enqueue(r.Context(), store, u.ID, relayBytes); err != nil {
+					l.Err(err).Msg("LbzSubmitListenHandler: Failed to enqueue listens for relay targets")
+				}
 			}
+		}
 
+		for _, payload := range listens {
 			artistMbzIDs, err := utils.ParseUUIDSlice(payload.TrackMeta.AdditionalInfo.ArtistMBIDs)
 			if err != nil {
 				l.Debug().AnErr("error", err).Msg("LbzSubmitListenHandler: Failed to parse one or more UUIDs")
@@ -221,6 +259,8 @@ func LbzSubmitListenHandler(store db.DB, mbzc mbz.MusicBrainzCaller) func(w http
 				ReleaseMbzID:       releaseMbzID,
 				ReleaseGroupMbzID:  rgMbzID,
 				ArtistMbidMappings: artistMbidMap,
+				AlbumArtist:        payload.TrackMeta.AdditionalInfo.AlbumArtist,
+				Tags:               payload.TrackMeta.AdditionalInfo.Tags,
 				Duration:           duration,
 				Time:               listenedAt,
 				UserID:             u.ID,
@@ -254,73 +294,60 @@ func LbzSubmitListenHandler(store db.DB, mbzc mbz.MusicBrainzCaller) func(w http
 	}
 }
 
-func doLbzRelay(requestBytes []byte, l *zerolog.Logger) {
-	defer func() {
-		if r := recover(); r != nil {
-			l.Error().Interface("recover", r).Msg("doLbzRelay: Panic occurred")
-		}
-	}()
-	const (
-		maxRetryDuration = 3 * time.Minute
-		initialBackoff   = 5 * time.Second
-		maxBackoff       = 40 * time.Second
-	)
-	l.Debug().Msg("doLbzRelay: Building ListenBrainz relay request")
-	endpoint := cfg.LbzRelayUrl() + "/submit-listens"
-
-	client := &http.Client{
-		Timeout: 30 * time.Second,
+// rewriteRulesForUser loads and compiles the user's enabled rewrite rules.
+func rewriteRulesForUser(ctx context.Context, store db.DB, userID int32) (rules.Set, error) {
+	rr, err := store.GetRewriteRulesByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
 	}
+	return rules.Compile(rr)
+}
 
-	var resp *http.Response
-	var body []byte
-	start := time.Now()
-	backoff := initialBackoff
-
-	for {
-		req, err := http.NewRequest("POST", endpoint, bytes.NewReader(requestBytes))
-		if err != nil {
-			l.Err(err).Msg("doLbzRelay: Failed to build ListenBrainz relay request")
-			return
-		}
-		req.Header.Add("Authorization", "Token "+cfg.LbzRelayToken())
-		req.Header.Add("Content-Type", "application/json")
-
-		l.Debug().Msg("doLbzRelay: Sending ListenBrainz relay request")
-		resp, err = client.Do(req)
-		if err != nil {
-			l.Err(err).Msg("doLbzRelay: Failed to send ListenBrainz relay request")
-			return
-		}
+// applyRewriteRules runs the rules against the track metadata, changing it in place. A
+// rewritten client replaces the media player.
+func applyRewriteRules(set rules.Set, meta *LbzTrackMeta) rules.Result {
+	li := rules.Listen{
+		Artist:      meta.ArtistName,
+		ArtistNames: meta.AdditionalInfo.ArtistNames,
+		Track:       meta.TrackName,
+		Release:     meta.ReleaseName,
+		Client:      meta.AdditionalInfo.MediaPlayer,
+	}
+	if li.Client == "" {
+		li.Client = meta.AdditionalInfo.SubmissionClient
+	}
+	client := li.Client
 
-		if resp.StatusCode >= 200 && resp.StatusCode < 300 {
-			resp.Body.Close()
-			l.Info().Msg("doLbzRelay: Successfully relayed ListenBrainz submission")
-			return
-		}
+	res := set.Apply(&li)
+	if res.Dropped || len(res.Applied) == 0 {
+		return res
+	}
 
-		body, _ = io.ReadAll(resp.Body)
-		resp.Body.Close()
-
-		if resp.StatusCode >= 500 && time.Since(start)+backoff <= maxRetryDuration {
-			l.Warn().
-				Int("status", resp.StatusCode).
-				Str("response", string(body)).
-				Msg("doLbzRelay: Retryable server error from ListenBrainz relay, retrying...")
-			time.Sleep(backoff)
-			backoff *= 2
-			if backoff > maxBackoff {
-				backoff = maxBackoff
-			}
-			continue
-		}
+	meta.ArtistName = li.Artist
+	meta.AdditionalInfo.ArtistNames = li.ArtistNames
+	meta.TrackName = li.Track
+	meta.ReleaseName = li.Release
+	if li.Client != client {
+		meta.AdditionalInfo.MediaPlayer = li.Client
+	}
+	return res
+}
 
-		l.Warn().
-			Int("status", resp.StatusCode).
-			Str("response", string(body)).
-			Msg("doLbzRelay: Non-2XX response from ListenBrainz relay")
+// relayListens queues listens received through one of the other scrobbling APIs for the
+// user's relay targets. Failures are logged, as they should not fail the submission itself.
+func relayListens(ctx context.Context, store db.DB, userID int32, listenType LbzListenType, listens []LbzSubmitListenPayload) {
+	l := logger.FromContext(ctx)
+	if len(listens) == 0 {
 		return
 	}
+	body, err := json.Marshal(LbzSubmitListenRequest{ListenType: listenType, Payload: listens})
+	if err != nil {
+		l.Err(err).Msg("Failed to encode listens for relay targets")
+		return
+	}
+	if err := relay.Enqueue(ctx, store, userID, body); err != nil {
+		l.Err(err).Msg("Failed to enqueue listens for relay targets")
+	}
 }
 
 func buildCoalescingKey(userID int32, listenType LbzListenType, p LbzSubmitListenPayload) string {
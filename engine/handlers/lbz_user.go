@@ -0,0 +1,258 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gabehf/koito/engine/middleware"
+	"github.com/gabehf/koito/internal/db"
+	"github.com/gabehf/koito/internal/logger"
+	"github.com/gabehf/koito/internal/models"
+	"github.com/gabehf/koito/internal/nowplaying"
+	"github.com/gabehf/koito/internal/utils"
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	lbzDefaultListenCount = 25
+	lbzMaxListenCount     = 1000
+)
+
+type LbzListensResponse struct {
+	Payload LbzListensPayload `json:"payload"`
+}
+
+type LbzListensPayload struct {
+	Count      int         `json:"count"`
+	UserID     string      `json:"user_id"`
+	PlayingNow bool        `json:"playing_now,omitempty"`
+	Listens    []LbzListen `json:"listens"`
+}
+
+type LbzListen struct {
+	ListenedAt int64            `json:"listened_at,omitempty"`
+	PlayingNow bool             `json:"playing_now,omitempty"`
+	UserName   string           `json:"user_name"`
+	TrackMeta  LbzListenMetaOut `json:"track_metadata"`
+}
+
+type LbzListenMetaOut struct {
+	ArtistName     string            `json:"artist_name"`
+	TrackName      string            `json:"track_name"`
+	ReleaseName    string            `json:"release_name,omitempty"`
+	AdditionalInfo LbzAdditionalInfo `json:"additional_info"`
+}
+
+type LbzListenCountResponse struct {
+	Payload struct {
+		Count int64 `json:"count"`
+	} `json:"payload"`
+}
+
+// LbzGetListensHandler serves GET /1/user/{user}/listens, using the same min_ts, max_ts
+// and count parameters as ListenBrainz. Both timestamps are exclusive, and listens are
+// always returned newest first.
+func LbzGetListensHandler(store db.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		l := logger.FromContext(ctx)
+
+		l.Debug().Msg("LbzGetListensHandler: Received request")
+
+		user, ok := lbzUserFromPath(ctx, store, w, r)
+		if !ok {
+			return
+		}
+
+		q := r.URL.Query()
+		count := lbzDefaultListenCount
+		if c := q.Get("count"); c != "" {
+			n, err := strconv.Atoi(c)
+			if err != nil || n < 0 {
+				l.Debug().Msg("LbzGetListensHandler: Invalid count parameter")
+				utils.WriteError(w, "count must be a positive integer", http.StatusBadRequest)
+				return
+			}
+			count = min(n, lbzMaxListenCount)
+		}
+
+		var minTs, maxTs int64
+		var err error
+		if v := q.Get("min_ts"); v != "" {
+			minTs, err = strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				l.Debug().Msg("LbzGetListensHandler: Invalid min_ts parameter")
+				utils.WriteError(w, "min_ts must be a unix timestamp", http.StatusBadRequest)
+				return
+			}
+		}
+		if v := q.Get("max_ts"); v != "" {
+			maxTs, err = strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				l.Debug().Msg("LbzGetListensHandler: Invalid max_ts parameter")
+				utils.WriteError(w, "max_ts must be a unix timestamp", http.StatusBadRequest)
+				return
+			}
+		}
+		if minTs != 0 && maxTs != 0 {
+			l.Debug().Msg("LbzGetListensHandler: Both min_ts and max_ts were provided")
+			utils.WriteError(w, "you may only specify max_ts or min_ts, not both", http.StatusBadRequest)
+			return
+		}
+
+		opts := db.GetUserListensOpts{
+			UserID: user.ID,
+			Limit:  count,
+		}
+		if minTs != 0 {
+			opts.MinTime = time.Unix(minTs, 0)
+		}
+		if maxTs != 0 {
+			opts.MaxTime = time.Unix(maxTs, 0)
+		}
+
+		resp := LbzListensResponse{Payload: LbzListensPayload{UserID: user.Username, Listens: []LbzListen{}}}
+		if count > 0 {
+			listens, err := store.GetUserListens(ctx, opts)
+			if err != nil {
+				l.Err(err).Msg("LbzGetListensHandler: Failed to get listens")
+				utils.WriteError(w, "failed to get listens: "+err.Error(), http.StatusInternalServerError)
+				return
+			}
+
+			albumTitles := make(map[int32]string)
+			for _, listen := range listens {
+				resp.Payload.Listens = append(resp.Payload.Listens, LbzListen{
+					ListenedAt: listen.Time.Unix(),
+					UserName:   user.Username,
+					TrackMeta:  lbzTrackMetaFromTrack(ctx, store, listen.Track, albumTitles),
+				})
+			}
+		}
+		resp.Payload.Count = len(resp.Payload.Listens)
+
+		l.Debug().Msgf("LbzGetListensHandler: Returning %d listens for user '%s'", resp.Payload.Count, user.Username)
+		utils.WriteJSON(w, http.StatusOK, resp)
+	}
+}
+
+// LbzPlayingNowHandler serves GET /1/user/{user}/playing-now.
+func LbzPlayingNowHandler(store db.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		l := logger.FromContext(ctx)
+
+		l.Debug().Msg("LbzPlayingNowHandler: Received request")
+
+		user, ok := lbzUserFromPath(ctx, store, w, r)
+		if !ok {
+			return
+		}
+
+		resp := LbzListensResponse{Payload: LbzListensPayload{UserID: user.Username, PlayingNow: true, Listens: []LbzListen{}}}
+
+		if entry, ok := nowplaying.Get(user.ID); ok {
+			track, err := store.GetTrack(ctx, db.GetTrackOpts{ID: entry.TrackID})
+			if err != nil {
+				l.Err(err).Msg("LbzPlayingNowHandler: Failed to get track from database")
+				utils.WriteError(w, "failed to fetch currently playing track from database", http.StatusInternalServerError)
+				return
+			}
+			resp.Payload.Listens = append(resp.Payload.Listens, LbzListen{
+				PlayingNow: true,
+				UserName:   user.Username,
+				TrackMeta:  lbzTrackMetaFromTrack(ctx, store, *track, make(map[int32]string)),
+			})
+		}
+		resp.Payload.Count = len(resp.Payload.Listens)
+
+		utils.WriteJSON(w, http.StatusOK, resp)
+	}
+}
+
+// LbzListenCountHandler serves GET /1/user/{user}/listen-count.
+func LbzListenCountHandler(store db.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		l := logger.FromContext(ctx)
+
+		l.Debug().Msg("LbzListenCountHandler: Received request")
+
+		user, ok := lbzUserFromPath(ctx, store, w, r)
+		if !ok {
+			return
+		}
+
+		count, err := store.CountUserListens(ctx, user.ID)
+		if err != nil {
+			l.Err(err).Msg("LbzListenCountHandler: Failed to count listens")
+			utils.WriteError(w, "failed to count listens", http.StatusInternalServerError)
+			return
+		}
+
+		var resp LbzListenCountResponse
+		resp.Payload.Count = count
+
+		l.Debug().Msgf("LbzListenCountHandler: User '%s' has %d listens", user.Username, count)
+		utils.WriteJSON(w, http.StatusOK, resp)
+	}
+}
+
+// lbzUserFromPath looks up the user named in the {user} URL parameter. Writes a
+// 404 and returns false if the user does not exist.
+func lbzUserFromPath(ctx context.Context, store db.DB, w http.ResponseWriter, r *http.Request) (*models.User, bool) {
+	l := logger.FromContext(ctx)
+
+	username := chi.URLParam(r, "user")
+	user, err := store.GetUserByUsername(ctx, username)
+	if err != nil {
+		l.Err(err).Msg("Failed to get user by username")
+		utils.WriteError(w, "internal server error", http.StatusInternalServerError)
+		return nil, false
+	}
+	// users whose profile the requester may not see are reported as missing, like on the web
+	if !middleware.CanViewProfile(middleware.GetUserFromContext(ctx), user) {
+		l.Debug().Msgf("User '%s' does not exist or cannot be viewed", username)
+		utils.WriteError(w, "Cannot find user: "+username, http.StatusNotFound)
+		return nil, false
+	}
+	return user, true
+}
+
+// lbzTrackMetaFromTrack builds ListenBrainz track metadata for a track. Album titles are
+// cached in albumTitles so that listens to the same album only look it up once.
+func lbzTrackMetaFromTrack(ctx context.Context, store db.DB, track models.Track, albumTitles map[int32]string) LbzListenMetaOut {
+	l := logger.FromContext(ctx)
+
+	artistNames := utils.FlattenSimpleArtistNames(track.Artists)
+	meta := LbzListenMetaOut{
+		ArtistName: strings.Join(artistNames, ", "),
+		TrackName:  track.Title,
+		AdditionalInfo: LbzAdditionalInfo{
+			ArtistNames: artistNames,
+			Duration:    track.Duration,
+		},
+	}
+	if track.MbzID != nil {
+		meta.AdditionalInfo.RecordingMBID = track.MbzID.String()
+	}
+
+	if track.AlbumID != 0 {
+		title, ok := albumTitles[track.AlbumID]
+		if !ok {
+			album, err := store.GetAlbum(ctx, db.GetAlbumOpts{ID: track.AlbumID})
+			if err != nil {
+				l.Debug().AnErr("error", err).Msgf("Failed to get album with id %d", track.AlbumID)
+			} else {
+				title = album.Title
+			}
+			albumTitles[track.AlbumID] = title
+		}
+		meta.ReleaseName = title
+	}
+
+	return meta
+}
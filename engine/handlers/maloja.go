@@ -0,0 +1,260 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gabehf/koito/internal/catalog"
+	"github.com/gabehf/koito/internal/db"
+	"github.com/gabehf/koito/internal/logger"
+	"github.com/gabehf/koito/internal/mbz"
+	"github.com/gabehf/koito/internal/models"
+	"github.com/gabehf/koito/internal/utils"
+)
+
+type MalojaScrobbleRequest struct {
+	Artists      []string `json:"artists"`
+	Artist       string   `json:"artist"`
+	Title        string   `json:"title"`
+	Album        string   `json:"album"`
+	AlbumArtists []string `json:"albumartists"`
+	Duration     int32    `json:"duration"`
+	Length       int32    `json:"length"`
+	Time         int64    `json:"time"`
+	Key          string   `json:"key"`
+}
+
+type MalojaResponse struct {
+	Status string             `json:"status"`
+	Track  *MalojaTrackOut    `json:"track,omitempty"`
+	Desc   string             `json:"desc,omitempty"`
+	Error  *MalojaErrorDetail `json:"error,omitempty"`
+}
+
+type MalojaTrackOut struct {
+	Artists []string `json:"artists"`
+	Title   string   `json:"title"`
+}
+
+type MalojaErrorDetail struct {
+	Type  string   `json:"type"`
+	Value []string `json:"value,omitempty"`
+	Desc  string   `json:"desc"`
+}
+
+// MalojaScrobbleHandler implements Maloja's /apis/mlj_1/newscrobble endpoint. Requests
+// may be sent as JSON or as form data, with the API key in the 'key' parameter.
+func MalojaScrobbleHandler(store db.DB, mbzc mbz.MusicBrainzCaller) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		l := logger.FromContext(ctx)
+
+		l.Debug().Msg("MalojaScrobbleHandler: Received request")
+
+		req, err := parseMalojaScrobbleRequest(w, r)
+		if err != nil {
+			l.Debug().AnErr("error", err).Msg("MalojaScrobbleHandler: Failed to parse request")
+			utils.WriteJSON(w, http.StatusBadRequest, MalojaResponse{
+				Status: "error",
+				Error:  &MalojaErrorDetail{Type: "malformed_request", Desc: "Failed to parse request"},
+			})
+			return
+		}
+
+		u, ok := malojaUserFromKey(ctx, store, w, r, req.Key)
+		if !ok {
+			return
+		}
+
+		artists := req.Artists
+		if len(artists) == 0 && req.Artist != "" {
+			artists = []string{req.Artist}
+		}
+		var missing []string
+		if len(artists) == 0 {
+			missing = append(missing, "artists")
+		}
+		if req.Title == "" {
+			missing = append(missing, "title")
+		}
+		if len(missing) > 0 {
+			l.Debug().Msgf("MalojaScrobbleHandler: Missing scrobble data %v", missing)
+			utils.WriteJSON(w, http.StatusBadRequest, MalojaResponse{
+				Status: "error",
+				Error: &MalojaErrorDetail{
+					Type:  "missing_scrobble_data",
+					Value: missing,
+					Desc:  "The scrobble is missing needed information: " + strings.Join(missing, ", "),
+				},
+			})
+			return
+		}
+
+		listenedAt := time.Now()
+		if req.Time > 0 {
+			listenedAt = time.Unix(req.Time, 0)
+		}
+
+		opts := catalog.SubmitListenOpts{
+			MbzCaller:    mbzc,
+			Artist:       artists[0],
+			ArtistNames:  artists,
+			TrackTitle:   req.Title,
+			ReleaseTitle: req.Album,
+			Duration:     req.Length,
+			Time:         listenedAt,
+			UserID:       u.ID,
+		}
+
+		coalescingKey := fmt.Sprintf("%d:mlj:%s:%s:%s:%d", u.ID, strings.Join(artists, ","), req.Title, req.Album, listenedAt.Unix())
+		_, err, shared := sfGroup.Do(coalescingKey, func() (interface{}, error) {
+			submitCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 30*time.Second)
+			defer cancel()
+
+			return 0, catalog.SubmitListen(submitCtx, store, opts)
+		})
+		if shared {
+			l.Info().Msg("MalojaScrobbleHandler: Duplicate requests detected; results were coalesced")
+		}
+		if err != nil {
+			l.Err(err).Msg("MalojaScrobbleHandler: Failed to submit listen")
+			utils.WriteJSON(w, http.StatusInternalServerError, MalojaResponse{
+				Status: "error",
+				Error:  &MalojaErrorDetail{Type: "internal_error", Desc: "Failed to submit scrobble"},
+			})
+			return
+		}
+
+		relayListens(ctx, store, u.ID, ListenTypeSingle, []LbzSubmitListenPayload{{
+			ListenedAt: listenedAt.Unix(),
+			TrackMeta: LbzTrackMeta{
+				ArtistName:  strings.Join(artists, ", "),
+				TrackName:   req.Title,
+				ReleaseName: req.Album,
+				AdditionalInfo: LbzAdditionalInfo{
+					ArtistNames: artists,
+					Duration:    req.Length,
+				},
+			},
+		}})
+
+		l.Debug().Msg("MalojaScrobbleHandler: Successfully submitted listen")
+		utils.WriteJSON(w, http.StatusOK, MalojaResponse{
+			Status: "success",
+			Track:  &MalojaTrackOut{Artists: artists, Title: req.Title},
+			Desc:   fmt.Sprintf("Scrobbled %s by %s", req.Title, strings.Join(artists, ", ")),
+		})
+	}
+}
+
+// MalojaTestHandler implements Maloja's /apis/mlj_1/test endpoint, which clients
+// use to check that their API key is valid.
+func MalojaTestHandler(store db.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		l := logger.FromContext(ctx)
+
+		l.Debug().Msg("MalojaTestHandler: Received request")
+
+		req, err := parseMalojaScrobbleRequest(w, r)
+		if err != nil {
+			l.Debug().AnErr("error", err).Msg("MalojaTestHandler: Failed to parse request")
+		}
+
+		if _, ok := malojaUserFromKey(ctx, store, w, r, req.Key); !ok {
+			return
+		}
+
+		utils.WriteJSON(w, http.StatusOK, MalojaResponse{Status: "ok"})
+	}
+}
+
+func malojaUserFromKey(ctx context.Context, store db.DB, w http.ResponseWriter, r *http.Request, key string) (*models.User, bool) {
+	l := logger.FromContext(ctx)
+
+	if key == "" {
+		l.Debug().Msg("Maloja: Missing API key")
+		writeMalojaAuthError(w)
+		return nil, false
+	}
+
+	u, err := userForSubmitKey(ctx, store, r, key)
+	if err != nil {
+		l.Err(err).Msg("Maloja: Failed to get user by api key")
+		utils.WriteJSON(w, http.StatusInternalServerError, MalojaResponse{
+			Status: "error",
+			Error:  &MalojaErrorDetail{Type: "internal_error", Desc: "Failed to validate API key"},
+		})
+		return nil, false
+	}
+	if u == nil {
+		l.Debug().Msg("Maloja: API key does not exist or can't submit listens")
+		writeMalojaAuthError(w)
+		return nil, false
+	}
+
+	return u, true
+}
+
+func writeMalojaAuthError(w http.ResponseWriter) {
+	utils.WriteJSON(w, http.StatusForbidden, MalojaResponse{
+		Status: "failure",
+		Error:  &MalojaErrorDetail{Type: "authentication_fail", Desc: "Invalid or missing API key"},
+	})
+}
+
+// parseMalojaScrobbleRequest reads a scrobble from a JSON body, or from form and query
+// parameters otherwise. A key given in the query string is used if the body has none.
+func parseMalojaScrobbleRequest(w http.ResponseWriter, r *http.Request) (MalojaScrobbleRequest, error) {
+	var req MalojaScrobbleRequest
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestBodyBytes)
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType == "application/json" {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			return req, err
+		}
+	} else {
+		if err := r.ParseForm(); err != nil {
+			return req, err
+		}
+		req.Artists = r.Form["artists"]
+		req.Artist = r.Form.Get("artist")
+		req.Title = r.Form.Get("title")
+		req.Album = r.Form.Get("album")
+		req.AlbumArtists = r.Form["albumartists"]
+		req.Key = r.Form.Get("key")
+		if v := r.Form.Get("duration"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return req, fmt.Errorf("invalid duration: %w", err)
+			}
+			req.Duration = int32(n)
+		}
+		if v := r.Form.Get("length"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return req, fmt.Errorf("invalid length: %w", err)
+			}
+			req.Length = int32(n)
+		}
+		if v := r.Form.Get("time"); v != "" {
+			n, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				return req, fmt.Errorf("invalid time: %w", err)
+			}
+			req.Time = n
+		}
+	}
+
+	if req.Key == "" {
+		req.Key = r.URL.Query().Get("key")
+	}
+	return req, nil
+}
@@ -6,6 +6,7 @@ import (
 
 	"github.com/gabehf/koito/internal/db"
 	"github.com/gabehf/koito/internal/logger"
+	"github.com/gabehf/koito/internal/models"
 	"github.com/gabehf/koito/internal/utils"
 	"github.com/google/uuid"
 )
@@ -55,6 +56,7 @@ func UpdateMbzIdHandler(store db.DB) func(w http.ResponseWriter, r *http.Request
 				utils.WriteError(w, "invalid artist_id", http.StatusBadRequest)
 				return
 			}
+			before := auditItem(ctx, store, models.AuditTargetArtist, int32(artistID))
 			err = store.UpdateArtist(ctx, db.UpdateArtistOpts{
 				ID:            int32(artistID),
 				MusicBrainzID: mbzid,
@@ -64,6 +66,7 @@ func UpdateMbzIdHandler(store db.DB) func(w http.ResponseWriter, r *http.Request
 				utils.WriteError(w, "failed to update musicbrainz id", http.StatusInternalServerError)
 				return
 			}
+			recordItemAudit(ctx, store, models.AuditActionUpdateMbzID, models.AuditTargetArtist, int32(artistID), before)
 		} else if albumIDStr != "" {
 			var albumID int
 			albumID, err = strconv.Atoi(albumIDStr)
@@ -72,6 +75,7 @@ func UpdateMbzIdHandler(store db.DB) func(w http.ResponseWriter, r *http.Request
 				utils.WriteError(w, "invalid artist_id", http.StatusBadRequest)
 				return
 			}
+			before := auditItem(ctx, store, models.AuditTargetAlbum, int32(albumID))
 			err = store.UpdateAlbum(ctx, db.UpdateAlbumOpts{
 				ID:            int32(albumID),
 				MusicBrainzID: mbzid,
@@ -81,6 +85,7 @@ func UpdateMbzIdHandler(store db.DB) func(w http.ResponseWriter, r *http.Request
 				utils.WriteError(w, "failed to update musicbrainz id", http.StatusInternalServerError)
 				return
 			}
+			recordItemAudit(ctx, store, models.AuditActionUpdateMbzID, models.AuditTargetAlbum, int32(albumID), before)
 		} else if trackIDStr != "" {
 			var trackID int
 			trackID, err = strconv.Atoi(trackIDStr)
@@ -89,6 +94,7 @@ func UpdateMbzIdHandler(store db.DB) func(w http.ResponseWriter, r *http.Request
 				utils.WriteError(w, "invalid artist_id", http.StatusBadRequest)
 				return
 			}
+			before := auditItem(ctx, store, models.AuditTargetTrack, int32(trackID))
 			err = store.UpdateTrack(ctx, db.UpdateTrackOpts{
 				ID:            int32(trackID),
 				MusicBrainzID: mbzid,
@@ -98,6 +104,7 @@ func UpdateMbzIdHandler(store db.DB) func(w http.ResponseWriter, r *http.Request
 				utils.WriteError(w, "failed to update musicbrainz id", http.StatusInternalServerError)
 				return
 			}
+			recordItemAudit(ctx, store, models.AuditActionUpdateMbzID, models.AuditTargetTrack, int32(trackID), before)
 		}
 
 		w.WriteHeader(http.StatusNoContent)
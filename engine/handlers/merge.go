@@ -2,13 +2,16 @@ package handlers
 
 import (
 	"context"
+	"errors"
 	"net/http"
 	"strconv"
 	"strings"
 
 	"github.com/gabehf/koito/internal/db"
 	"github.com/gabehf/koito/internal/logger"
+	"github.com/gabehf/koito/internal/models"
 	"github.com/gabehf/koito/internal/utils"
+	"github.com/jackc/pgx/v5"
 )
 
 // MergeHandler creates a handler for merge operations.
@@ -72,17 +75,80 @@ func MergeHandler(
 func MergeTracksHandler(store db.DB) http.HandlerFunc {
 	// Adapter to make MergeTracks compatible with the factory signature
 	adapter := func(ctx context.Context, fromId, toId int32, replaceImage bool) error {
-		return store.MergeTracks(ctx, fromId, toId)
+		discarded, err := store.MergeTracks(ctx, fromId, toId)
+		if err != nil {
+			return err
+		}
+		if discarded > 0 {
+			logger.FromContext(ctx).Info().Msgf("MergeTracksHandler: Discarded %d listens of track %d that were already listened to as track %d", discarded, fromId, toId)
+		}
+		return nil
 	}
-	return MergeHandler("MergeTracksHandler", adapter, false)
+	return MergeHandler("MergeTracksHandler", auditedMerge(store, models.AuditTargetTrack, adapter), false)
 }
 
 func MergeReleaseGroupsHandler(store db.DB) http.HandlerFunc {
-	return MergeHandler("MergeReleaseGroupsHandler", store.MergeAlbums, true)
+	return MergeHandler("MergeReleaseGroupsHandler", auditedMerge(store, models.AuditTargetAlbum, store.MergeAlbums), true)
 }
 
 func MergeArtistsHandler(store db.DB) http.HandlerFunc {
-	return MergeHandler("MergeArtistsHandler", store.MergeArtists, true)
+	return MergeHandler("MergeArtistsHandler", auditedMerge(store, models.AuditTargetArtist, store.MergeArtists), true)
+}
+
+// UnmergeHandler creates a handler that undoes the latest merge of the item given by from_id,
+// recreating it and moving back what the merge took from it.
+func UnmergeHandler(
+	name string,
+	store db.DB,
+	target models.AuditTarget,
+	unmergeFn func(ctx context.Context, fromId int32) error,
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		l := logger.FromContext(ctx)
+
+		l.Debug().Msgf("%s: Received request", name)
+
+		fromidStr := r.URL.Query().Get("from_id")
+		fromId, err := strconv.Atoi(fromidStr)
+		if err != nil {
+			l.Debug().AnErr("error", err).Msgf("%s: Invalid from_id parameter", name)
+			utils.WriteError(w, "from_id is invalid", http.StatusBadRequest)
+			return
+		}
+
+		err = unmergeFn(ctx, int32(fromId))
+		if errors.Is(err, pgx.ErrNoRows) {
+			l.Debug().Msgf("%s: No merge of ID %d to undo", name, fromId)
+			utils.WriteError(w, "no merge to undo", http.StatusNotFound)
+			return
+		} else if errors.Is(err, db.ErrCannotUnmerge) {
+			l.Debug().Msgf("%s: Merge of ID %d can no longer be undone", name, fromId)
+			utils.WriteError(w, err.Error(), http.StatusConflict)
+			return
+		} else if err != nil {
+			l.Err(err).Msgf("%s: Failed to unmerge", name)
+			utils.WriteError(w, name+" failed: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		recordItemAudit(ctx, store, models.AuditActionUnmerge, target, int32(fromId), nil)
+
+		l.Debug().Msgf("%s: Successfully unmerged ID %d", name, fromId)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func UnmergeTracksHandler(store db.DB) http.HandlerFunc {
+	return UnmergeHandler("UnmergeTracksHandler", store, models.AuditTargetTrack, store.UnmergeTracks)
+}
+
+func UnmergeAlbumsHandler(store db.DB) http.HandlerFunc {
+	return UnmergeHandler("UnmergeAlbumsHandler", store, models.AuditTargetAlbum, store.UnmergeAlbums)
+}
+
+func UnmergeArtistsHandler(store db.DB) http.HandlerFunc {
+	return UnmergeHandler("UnmergeArtistsHandler", store, models.AuditTargetArtist, store.UnmergeArtists)
 }
 
 func UpdateAlbumHandler(store db.DB) http.HandlerFunc {
@@ -111,6 +177,7 @@ func UpdateAlbumHandler(store db.DB) http.HandlerFunc {
 			return
 		}
 
+		before := auditItem(ctx, store, models.AuditTargetAlbum, int32(id))
 		err = store.UpdateAlbum(ctx, db.UpdateAlbumOpts{
 			ID:                   int32(id),
 			VariousArtistsUpdate: updateVariousArtists,
@@ -122,6 +189,8 @@ func UpdateAlbumHandler(store db.DB) http.HandlerFunc {
 			return
 		}
 
+		recordItemAudit(ctx, store, models.AuditActionUpdate, models.AuditTargetAlbum, int32(id), before)
+
 		l.Debug().Msg("UpdateAlbumHandler: Successfully updated album")
 
 		w.WriteHeader(http.StatusNoContent)
@@ -1,18 +1,48 @@
 package handlers
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/gabehf/koito/internal/db"
 	"github.com/gabehf/koito/internal/logger"
-	"github.com/gabehf/koito/internal/memkv"
 	"github.com/gabehf/koito/internal/models"
+	"github.com/gabehf/koito/internal/nowplaying"
 	"github.com/gabehf/koito/internal/utils"
 )
 
+// how often a comment is written to idle now playing streams so proxies don't close them
+const nowPlayingKeepAlive = 30 * time.Second
+
 type NowPlayingResponse struct {
 	CurrentlyPlaying bool         `json:"currently_playing"`
 	Track            models.Track `json:"track"`
+	Client           string       `json:"client,omitempty"`
+	StartedAt        *time.Time   `json:"started_at,omitempty"`
+	ExpiresAt        *time.Time   `json:"expires_at,omitempty"`
+	// Seconds since the track started playing
+	Progress int64 `json:"progress"`
+}
+
+func buildNowPlayingResponse(ctx context.Context, store db.DB, entry *nowplaying.Entry) (NowPlayingResponse, error) {
+	if entry == nil {
+		return NowPlayingResponse{CurrentlyPlaying: false}, nil
+	}
+	track, err := store.GetTrack(ctx, db.GetTrackOpts{ID: entry.TrackID})
+	if err != nil {
+		return NowPlayingResponse{}, fmt.Errorf("buildNowPlayingResponse: %w", err)
+	}
+	return NowPlayingResponse{
+		CurrentlyPlaying: true,
+		Track:            *track,
+		Client:           entry.Client,
+		StartedAt:        &entry.StartedAt,
+		ExpiresAt:        &entry.ExpiresAt,
+		Progress:         int64(entry.Progress(time.Now()).Seconds()),
+	}, nil
 }
 
 func NowPlayingHandler(store db.DB) http.HandlerFunc {
@@ -22,19 +52,114 @@ func NowPlayingHandler(store db.DB) http.HandlerFunc {
 
 		l.Debug().Msg("NowPlayingHandler: Got request")
 
-		// Hardcoded user id as 1. Not great but it works until (if) multi-user is supported.
-		if trackIdI, ok := memkv.Store.Get("1"); !ok {
-			utils.WriteJSON(w, http.StatusOK, NowPlayingResponse{CurrentlyPlaying: false})
-		} else if trackId, ok := trackIdI.(int32); !ok {
-			l.Debug().Msg("NowPlayingHandler: Failed type assertion for trackIdI")
-			utils.WriteError(w, "internal server error", http.StatusInternalServerError)
-		} else {
-			track, err := store.GetTrack(ctx, db.GetTrackOpts{ID: trackId})
+		userID, err := userIDFromRequest(ctx, store, r)
+		if err != nil {
+			l.Err(err).Msg("NowPlayingHandler: Failed to get user")
+			utils.WriteError(w, "failed to get user", http.StatusInternalServerError)
+			return
+		}
+		if userID == 0 {
+			utils.WriteError(w, "user not found", http.StatusNotFound)
+			return
+		}
+
+		var entry *nowplaying.Entry
+		if e, ok := nowplaying.Get(userID); ok {
+			entry = &e
+		}
+		resp, err := buildNowPlayingResponse(ctx, store, entry)
+		if err != nil {
+			l.Error().Err(err).Msg("NowPlayingHandler: Failed to get track from database")
+			utils.WriteError(w, "failed to fetch currently playing track from database", http.StatusInternalServerError)
+			return
+		}
+		utils.WriteJSON(w, http.StatusOK, resp)
+	}
+}
+
+// NowPlayingStreamHandler streams now playing changes as server-sent events. The current
+// status is sent as soon as the stream opens, followed by a 'now-playing' event every time
+// it changes.
+func NowPlayingStreamHandler(store db.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		l := logger.FromContext(ctx)
+
+		l.Debug().Msg("NowPlayingStreamHandler: Got request")
+
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			l.Error().Msg("NowPlayingStreamHandler: Response writer does not support flushing")
+			utils.WriteError(w, "streaming is not supported", http.StatusInternalServerError)
+			return
+		}
+
+		userID, err := userIDFromRequest(ctx, store, r)
+		if err != nil {
+			l.Err(err).Msg("NowPlayingStreamHandler: Failed to get user")
+			utils.WriteError(w, "failed to get user", http.StatusInternalServerError)
+			return
+		}
+		if userID == 0 {
+			utils.WriteError(w, "user not found", http.StatusNotFound)
+			return
+		}
+
+		// subscribe before reading the current status so no change is missed in between
+		events, unsubscribe := nowplaying.Subscribe(userID)
+		defer unsubscribe()
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+		w.Header().Set("X-Accel-Buffering", "no")
+		w.WriteHeader(http.StatusOK)
+
+		send := func(entry *nowplaying.Entry) bool {
+			resp, err := buildNowPlayingResponse(ctx, store, entry)
 			if err != nil {
-				l.Error().Err(err).Msg("NowPlayingHandler: Failed to get track from database")
-				utils.WriteError(w, "failed to fetch currently playing track from database", http.StatusInternalServerError)
-			} else {
-				utils.WriteJSON(w, http.StatusOK, NowPlayingResponse{CurrentlyPlaying: true, Track: *track})
+				l.Err(err).Msg("NowPlayingStreamHandler: Failed to get track from database")
+				return false
+			}
+			data, err := json.Marshal(resp)
+			if err != nil {
+				l.Err(err).Msg("NowPlayingStreamHandler: Failed to marshal event")
+				return false
+			}
+			if _, err := fmt.Fprintf(w, "event: now-playing\ndata: %s\n\n", data); err != nil {
+				return false
+			}
+			flusher.Flush()
+			return true
+		}
+
+		var current *nowplaying.Entry
+		if e, ok := nowplaying.Get(userID); ok {
+			current = &e
+		}
+		if !send(current) {
+			return
+		}
+
+		keepAlive := time.NewTicker(nowPlayingKeepAlive)
+		defer keepAlive.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				l.Debug().Msg("NowPlayingStreamHandler: Client disconnected")
+				return
+			case ev, ok := <-events:
+				if !ok {
+					return
+				}
+				if !send(ev.Entry) {
+					return
+				}
+			case <-keepAlive.C:
+				if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+					return
+				}
+				flusher.Flush()
 			}
 		}
 	}
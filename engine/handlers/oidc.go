@@ -0,0 +1,249 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/gabehf/koito/engine/middleware"
+	"github.com/gabehf/koito/internal/cfg"
+	"github.com/gabehf/koito/internal/db"
+	"github.com/gabehf/koito/internal/logger"
+	"github.com/gabehf/koito/internal/memkv"
+	"github.com/gabehf/koito/internal/models"
+	"github.com/gabehf/koito/internal/oidc"
+	"github.com/gabehf/koito/internal/utils"
+)
+
+const (
+	oidcStateCookie = "koito_oidc_state"
+	oidcStatePrefix = "oidc_state:"
+	oidcStateTTL    = 10 * time.Minute
+	oidcCallbackURL = "/apis/web/v1/oidc/callback"
+)
+
+// oidcLoginState is kept between sending the user to the provider and their return.
+type oidcLoginState struct {
+	Nonce       string
+	Verifier    string
+	RedirectURL string
+	// When set, the identity is linked to this user instead of being used to sign in
+	LinkUserID int32
+}
+
+var (
+	errOIDCIdentityTaken = errors.New("this identity is already linked to another account")
+	errOIDCNoAccount     = errors.New("no account is linked to this identity")
+	errOIDCUsernameTaken = errors.New("an account with this username already exists; sign in to it and link this identity from your account settings")
+)
+
+// OIDCLoginHandler sends the user to the OpenID Connect provider to sign in. When the request
+// is authenticated, the identity the user signs in with is linked to their account instead.
+func OIDCLoginHandler(provider *oidc.Provider) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		l := logger.FromContext(ctx)
+
+		l.Debug().Msg("OIDCLoginHandler: Received request")
+
+		state, err1 := utils.GenerateRandomString(32)
+		nonce, err2 := utils.GenerateRandomString(32)
+		verifier, err3 := utils.GenerateRandomString(64)
+		if err := errors.Join(err1, err2, err3); err != nil {
+			l.Err(err).Msg("OIDCLoginHandler: Failed to generate login state")
+			utils.WriteError(w, "failed to start login", http.StatusInternalServerError)
+			return
+		}
+
+		redirectURL := cfg.OIDCRedirectURL()
+		if redirectURL == "" {
+			redirectURL = requestBaseURL(r) + oidcCallbackURL
+		}
+
+		loginState := oidcLoginState{
+			Nonce:       nonce,
+			Verifier:    verifier,
+			RedirectURL: redirectURL,
+		}
+		if user := middleware.GetUserFromContext(ctx); user != nil {
+			loginState.LinkUserID = user.ID
+		}
+
+		authURL, err := provider.AuthCodeURL(ctx, redirectURL, state, nonce, verifier)
+		if err != nil {
+			l.Err(err).Msg("OIDCLoginHandler: Failed to build authorization URL")
+			utils.WriteError(w, "identity provider is unavailable", http.StatusBadGateway)
+			return
+		}
+
+		memkv.Store.Set(oidcStatePrefix+state, loginState, oidcStateTTL)
+		// the state is tied to this browser, so that a login started elsewhere can't be completed here
+		http.SetCookie(w, &http.Cookie{
+			Name:     oidcStateCookie,
+			Value:    state,
+			Path:     "/apis/web/v1/oidc",
+			MaxAge:   int(oidcStateTTL.Seconds()),
+			HttpOnly: true,
+			Secure:   cfg.SecureCookiesEnabled(),
+			SameSite: http.SameSiteLaxMode,
+		})
+
+		http.Redirect(w, r, authURL, http.StatusFound)
+	}
+}
+
+// OIDCCallbackHandler completes a login started by OIDCLoginHandler and creates a session
+// for the user.
+func OIDCCallbackHandler(store db.DB, provider *oidc.Provider) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		l := logger.FromContext(ctx)
+
+		l.Debug().Msg("OIDCCallbackHandler: Received request")
+
+		q := r.URL.Query()
+		if e := q.Get("error"); e != "" {
+			l.Debug().Msgf("OIDCCallbackHandler: Provider returned error '%s': %s", e, q.Get("error_description"))
+			utils.WriteError(w, "login was not completed", http.StatusUnauthorized)
+			return
+		}
+
+		cookie, err := r.Cookie(oidcStateCookie)
+		if err != nil || q.Get("state") == "" || cookie.Value != q.Get("state") {
+			l.Debug().Msg("OIDCCallbackHandler: State does not match")
+			utils.WriteError(w, "login state is invalid", http.StatusBadRequest)
+			return
+		}
+		http.SetCookie(w, &http.Cookie{
+			Name:   oidcStateCookie,
+			Path:   "/apis/web/v1/oidc",
+			MaxAge: -1,
+		})
+		v, ok := memkv.Store.Get(oidcStatePrefix + cookie.Value)
+		memkv.Store.Delete(oidcStatePrefix + cookie.Value)
+		loginState, _ := v.(oidcLoginState)
+		if !ok || loginState.Verifier == "" {
+			l.Debug().Msg("OIDCCallbackHandler: Login state has expired")
+			utils.WriteError(w, "login has expired, please try again", http.StatusBadRequest)
+			return
+		}
+
+		claims, err := provider.Exchange(ctx, loginState.RedirectURL, q.Get("code"), loginState.Verifier, loginState.Nonce)
+		if err != nil {
+			l.Err(err).Msg("OIDCCallbackHandler: Failed to complete login with provider")
+			utils.WriteError(w, "login could not be verified", http.StatusUnauthorized)
+			return
+		}
+
+		user, err := oidcUserFromClaims(ctx, store, claims, loginState.LinkUserID)
+		if errors.Is(err, errOIDCIdentityTaken) || errors.Is(err, errOIDCNoAccount) || errors.Is(err, errOIDCUsernameTaken) {
+			l.Debug().AnErr("error", err).Msgf("OIDCCallbackHandler: Subject '%s' could not be signed in", claims.Subject())
+			utils.WriteError(w, err.Error(), http.StatusForbidden)
+			return
+		} else if err != nil {
+			l.Err(err).Msg("OIDCCallbackHandler: Failed to resolve user")
+			utils.WriteError(w, "failed to sign in", http.StatusInternalServerError)
+			return
+		}
+		if user.Disabled {
+			l.Debug().Msgf("OIDCCallbackHandler: User %d is disabled", user.ID)
+			utils.WriteError(w, "account is disabled", http.StatusForbidden)
+			return
+		}
+
+		if loginState.LinkUserID == 0 {
+			if err := startSession(w, r, store, user.ID, false); err != nil {
+				l.Err(err).Msg("OIDCCallbackHandler: Failed to create session")
+				utils.WriteError(w, "failed to sign in", http.StatusInternalServerError)
+				return
+			}
+		}
+
+		l.Debug().Msgf("OIDCCallbackHandler: User %d signed in with subject '%s'", user.ID, claims.Subject())
+		http.Redirect(w, r, "/", http.StatusFound)
+	}
+}
+
+// oidcUserFromClaims finds the user the claims belong to. Users are only ever matched by
+// subject. When linkUserID is set, the identity is linked to that user instead, which is the
+// only way an identity is tied to an existing account. Otherwise, an unknown subject gets a
+// new account named after the configured username claim when auto provisioning is enabled,
+// as long as the username is not taken. The role of the user follows the configured admin group.
+func oidcUserFromClaims(ctx context.Context, store db.DB, claims oidc.Claims, linkUserID int32) (*models.User, error) {
+	subject := claims.Subject()
+
+	user, err := store.GetUserByOIDCSubject(ctx, subject)
+	if err != nil {
+		return nil, err
+	}
+
+	if linkUserID != 0 {
+		if user != nil && user.ID != linkUserID {
+			return nil, errOIDCIdentityTaken
+		}
+		user, err = store.GetUserByID(ctx, linkUserID)
+		if err != nil {
+			return nil, err
+		}
+		if user == nil {
+			return nil, errOIDCNoAccount
+		}
+	}
+
+	if user == nil {
+		username := claims.String(cfg.OIDCUsernameClaim())
+		if !cfg.OIDCAutoProvision() || username == "" {
+			return nil, errOIDCNoAccount
+		}
+		existing, err := store.GetUserByUsername(ctx, username)
+		if err != nil {
+			return nil, err
+		}
+		if existing != nil {
+			return nil, errOIDCUsernameTaken
+		}
+		// the user can only sign in through the provider, so nobody needs to know this
+		password, err := utils.GenerateRandomString(48)
+		if err != nil {
+			return nil, err
+		}
+		user, err = store.SaveUser(ctx, db.SaveUserOpts{
+			Username: username,
+			Password: password,
+			Role:     oidcRole(claims, models.UserRoleUser),
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	opts := db.UpdateUserOpts{ID: user.ID}
+	if user.OIDCSubject != subject {
+		opts.OIDCSubject = subject
+	}
+	if role := oidcRole(claims, user.Role); role != user.Role {
+		opts.Role = role
+		user.Role = role
+	}
+	if opts.OIDCSubject != "" || opts.Role != "" {
+		if err := store.UpdateUser(ctx, opts); err != nil {
+			return nil, err
+		}
+		user.OIDCSubject = subject
+	}
+	return user, nil
+}
+
+// oidcRole maps the groups claim to a role. Returns current when no admin group is configured.
+func oidcRole(claims oidc.Claims, current models.UserRole) models.UserRole {
+	adminGroup := cfg.OIDCAdminGroup()
+	if adminGroup == "" {
+		return current
+	}
+	if slices.Contains(claims.Strings(cfg.OIDCGroupsClaim()), adminGroup) {
+		return models.UserRoleAdmin
+	}
+	return models.UserRoleUser
+}
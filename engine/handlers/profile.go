@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gabehf/koito/engine/middleware"
+	"github.com/gabehf/koito/internal/db"
+	"github.com/gabehf/koito/internal/logger"
+	"github.com/gabehf/koito/internal/models"
+	"github.com/gabehf/koito/internal/utils"
+)
+
+type ProfileResponse struct {
+	Username   string                   `json:"username"`
+	Visibility models.ProfileVisibility `json:"visibility"`
+	CreatedAt  time.Time                `json:"created_at"`
+}
+
+func profileResponseFrom(u *models.User) ProfileResponse {
+	return ProfileResponse{
+		Username:   u.Username,
+		Visibility: u.Visibility,
+		CreatedAt:  u.CreatedAt,
+	}
+}
+
+// ProfileHandler serves the profile resolved by middleware.ResolveProfile.
+func ProfileHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		l := logger.FromContext(ctx)
+
+		l.Debug().Msg("ProfileHandler: Received request")
+
+		profile := middleware.GetProfileFromContext(ctx)
+		if profile == nil {
+			utils.WriteError(w, "user not found", http.StatusNotFound)
+			return
+		}
+
+		utils.WriteJSON(w, http.StatusOK, profileResponseFrom(profile))
+	}
+}
+
+// PublicProfilesHandler lists the users whose profiles are public. Unlisted profiles are
+// left out.
+func PublicProfilesHandler(store db.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		l := logger.FromContext(ctx)
+
+		l.Debug().Msg("PublicProfilesHandler: Received request")
+
+		users, err := store.GetPublicUsers(ctx)
+		if err != nil {
+			l.Err(err).Msg("PublicProfilesHandler: Failed to get public users")
+			utils.WriteError(w, "failed to get profiles", http.StatusInternalServerError)
+			return
+		}
+
+		profiles := make([]ProfileResponse, len(users))
+		for i, u := range users {
+			profiles[i] = profileResponseFrom(u)
+		}
+		utils.WriteJSON(w, http.StatusOK, profiles)
+	}
+}
@@ -32,8 +32,20 @@ func RecommendationsHandler(store db.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		l := logger.FromContext(r.Context())
 
+		userID, err := userIDFromRequest(r.Context(), store, r)
+		if err != nil {
+			l.Err(err).Msg("RecommendationsHandler: Failed to get user")
+			utils.WriteError(w, "failed to get user", http.StatusInternalServerError)
+			return
+		}
+		if userID == 0 {
+			utils.WriteError(w, "user not found", http.StatusNotFound)
+			return
+		}
+
 		now := time.Now()
 		opts := db.GetRecommendationsOpts{
+			UserID:          userID,
 			PastWindowStart: now.AddDate(0, 0, -90),
 			PastWindowEnd:   now.AddDate(0, 0, -30),
 			MinPastListens:  5,
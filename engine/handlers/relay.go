@@ -0,0 +1,182 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gabehf/koito/internal/db"
+	"github.com/gabehf/koito/internal/logger"
+	"github.com/gabehf/koito/internal/models"
+	"github.com/gabehf/koito/internal/relay"
+	"github.com/gabehf/koito/internal/utils"
+	"github.com/jackc/pgx/v5"
+)
+
+type relayCountResponse struct {
+	Count int64 `json:"count"`
+}
+
+// GetRelayEntriesHandler lists relay outbox entries, newest first. The optional status
+// parameter filters by 'pending' or 'dead'.
+func GetRelayEntriesHandler(store db.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		l := logger.FromContext(ctx)
+
+		l.Debug().Msg("GetRelayEntriesHandler: Received request")
+
+		q := r.URL.Query()
+		status, ok := relayStatusFromString(q.Get("status"))
+		if !ok {
+			l.Debug().Msgf("GetRelayEntriesHandler: Invalid status '%s'", q.Get("status"))
+			utils.WriteError(w, "status must be 'pending' or 'dead'", http.StatusBadRequest)
+			return
+		}
+
+		limit, err := strconv.Atoi(strings.TrimSpace(q.Get("limit")))
+		if err != nil || limit < 1 {
+			limit = defaultLimitSize
+		}
+		limit = min(limit, maximumLimit)
+		page, _ := strconv.Atoi(strings.TrimSpace(q.Get("page")))
+		if page < 1 {
+			page = 1
+		}
+
+		entries, err := store.GetRelayEntriesPaginated(ctx, db.GetRelayEntriesOpts{
+			Status: status,
+			Page:   page,
+			Limit:  limit,
+		})
+		if err != nil {
+			l.Err(err).Msg("GetRelayEntriesHandler: Failed to get relay entries")
+			utils.WriteError(w, "failed to get relay entries", http.StatusInternalServerError)
+			return
+		}
+
+		l.Debug().Msgf("GetRelayEntriesHandler: Returning %d relay entries", len(entries.Items))
+		utils.WriteJSON(w, http.StatusOK, entries)
+	}
+}
+
+// RetryRelayEntriesHandler schedules a relay entry for immediate delivery. Without an id,
+// every dead entry is retried.
+func RetryRelayEntriesHandler(store db.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		l := logger.FromContext(ctx)
+
+		l.Debug().Msg("RetryRelayEntriesHandler: Received request")
+
+		if err := r.ParseForm(); err != nil {
+			l.Debug().Msg("RetryRelayEntriesHandler: Failed to parse form")
+			utils.WriteError(w, "form is invalid", http.StatusBadRequest)
+			return
+		}
+
+		idStr := r.FormValue("id")
+		if idStr == "" {
+			count, err := store.RetryDeadRelayEntries(ctx)
+			if err != nil {
+				l.Err(err).Msg("RetryRelayEntriesHandler: Failed to retry dead relay entries")
+				utils.WriteError(w, "failed to retry relay entries", http.StatusInternalServerError)
+				return
+			}
+			relay.Wake()
+			l.Info().Msgf("RetryRelayEntriesHandler: Retrying %d dead relay entries", count)
+			utils.WriteJSON(w, http.StatusOK, relayCountResponse{Count: count})
+			return
+		}
+
+		id, err := strconv.Atoi(idStr)
+		if err != nil {
+			l.Debug().AnErr("error", err).Msg("RetryRelayEntriesHandler: Invalid id")
+			utils.WriteError(w, "invalid id", http.StatusBadRequest)
+			return
+		}
+
+		err = store.RetryRelayEntry(ctx, int32(id))
+		if errors.Is(err, pgx.ErrNoRows) {
+			l.Debug().Msgf("RetryRelayEntriesHandler: Relay entry %d does not exist", id)
+			utils.WriteError(w, "relay entry not found", http.StatusNotFound)
+			return
+		} else if err != nil {
+			l.Err(err).Msg("RetryRelayEntriesHandler: Failed to retry relay entry")
+			utils.WriteError(w, "failed to retry relay entry", http.StatusInternalServerError)
+			return
+		}
+		relay.Wake()
+
+		l.Info().Msgf("RetryRelayEntriesHandler: Retrying relay entry %d", id)
+		utils.WriteJSON(w, http.StatusOK, relayCountResponse{Count: 1})
+	}
+}
+
+// DeleteRelayEntriesHandler deletes a single relay entry by id, or purges every entry
+// with the given status.
+func DeleteRelayEntriesHandler(store db.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		l := logger.FromContext(ctx)
+
+		l.Debug().Msg("DeleteRelayEntriesHandler: Received request")
+
+		q := r.URL.Query()
+		if idStr := q.Get("id"); idStr != "" {
+			id, err := strconv.Atoi(idStr)
+			if err != nil {
+				l.Debug().AnErr("error", err).Msg("DeleteRelayEntriesHandler: Invalid id")
+				utils.WriteError(w, "invalid id", http.StatusBadRequest)
+				return
+			}
+
+			err = store.DeleteRelayEntry(ctx, int32(id))
+			if errors.Is(err, pgx.ErrNoRows) {
+				l.Debug().Msgf("DeleteRelayEntriesHandler: Relay entry %d does not exist", id)
+				utils.WriteError(w, "relay entry not found", http.StatusNotFound)
+				return
+			} else if err != nil {
+				l.Err(err).Msg("DeleteRelayEntriesHandler: Failed to delete relay entry")
+				utils.WriteError(w, "failed to delete relay entry", http.StatusInternalServerError)
+				return
+			}
+
+			l.Info().Msgf("DeleteRelayEntriesHandler: Deleted relay entry %d", id)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		status, ok := relayStatusFromString(q.Get("status"))
+		if !ok || status == "" {
+			l.Debug().Msg("DeleteRelayEntriesHandler: Missing id or status")
+			utils.WriteError(w, "id or status ('pending' or 'dead') is required", http.StatusBadRequest)
+			return
+		}
+
+		count, err := store.PurgeRelayEntries(ctx, status)
+		if err != nil {
+			l.Err(err).Msg("DeleteRelayEntriesHandler: Failed to purge relay entries")
+			utils.WriteError(w, "failed to purge relay entries", http.StatusInternalServerError)
+			return
+		}
+
+		l.Info().Msgf("DeleteRelayEntriesHandler: Purged %d %s relay entries", count, status)
+		utils.WriteJSON(w, http.StatusOK, relayCountResponse{Count: count})
+	}
+}
+
+// relayStatusFromString parses an optional relay status. An empty string is valid and
+// matches every status.
+func relayStatusFromString(s string) (models.RelayStatus, bool) {
+	switch models.RelayStatus(strings.ToLower(s)) {
+	case "":
+		return "", true
+	case models.RelayStatusPending:
+		return models.RelayStatusPending, true
+	case models.RelayStatusDead:
+		return models.RelayStatusDead, true
+	}
+	return "", false
+}
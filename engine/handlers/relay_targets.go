@@ -0,0 +1,320 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/gabehf/koito/engine/middleware"
+	"github.com/gabehf/koito/internal/db"
+	"github.com/gabehf/koito/internal/logger"
+	"github.com/gabehf/koito/internal/models"
+	"github.com/gabehf/koito/internal/relay"
+	"github.com/gabehf/koito/internal/utils"
+	"github.com/jackc/pgx/v5"
+)
+
+func GetRelayTargetsHandler(store db.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		l := logger.FromContext(ctx)
+
+		l.Debug().Msg("GetRelayTargetsHandler: Received request")
+
+		user := middleware.GetUserFromContext(ctx)
+		if user == nil {
+			l.Debug().Msg("GetRelayTargetsHandler: Invalid user context")
+			utils.WriteError(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		targets, err := store.GetRelayTargetsByUserID(ctx, user.ID)
+		if err != nil {
+			l.Err(err).Msg("GetRelayTargetsHandler: Failed to get relay targets")
+			utils.WriteError(w, "failed to get relay targets", http.StatusInternalServerError)
+			return
+		}
+
+		l.Debug().Msgf("GetRelayTargetsHandler: Retrieved %d relay targets", len(targets))
+		utils.WriteJSON(w, http.StatusOK, targets)
+	}
+}
+
+// CreateRelayTargetHandler adds a relay target for the current user. Last.fm targets may be given
+// a username and password instead of a session key, which are exchanged for a session key and
+// then discarded.
+func CreateRelayTargetHandler(store db.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		l := logger.FromContext(ctx)
+
+		l.Debug().Msg("CreateRelayTargetHandler: Received request")
+
+		user := middleware.GetUserFromContext(ctx)
+		if user == nil {
+			l.Debug().Msg("CreateRelayTargetHandler: Invalid user context")
+			utils.WriteError(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		if err := r.ParseForm(); err != nil {
+			l.Debug().AnErr("error", err).Msg("CreateRelayTargetHandler: Failed to parse form")
+			utils.WriteError(w, "form is invalid", http.StatusBadRequest)
+			return
+		}
+
+		target := &models.RelayTarget{
+			UserID:          user.ID,
+			Kind:            models.RelayTargetKind(r.FormValue("kind")),
+			Enabled:         true,
+			RelayImports:    true,
+			RelayPlayingNow: true,
+		}
+		if err := applyRelayTargetForm(target, r.Form); err != nil {
+			l.Debug().AnErr("error", err).Msg("CreateRelayTargetHandler: Invalid relay target")
+			utils.WriteError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if target.Kind == models.RelayTargetLastFm && target.Token == "" && r.FormValue("username") != "" {
+			sk, err := relay.GetLastFmSession(ctx, target.URL, target.ApiKey, target.ApiSecret, r.FormValue("username"), r.FormValue("password"))
+			if err != nil {
+				l.Debug().AnErr("error", err).Msg("CreateRelayTargetHandler: Failed to get Last.fm session")
+				utils.WriteError(w, "failed to authenticate with Last.fm: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+			target.Token = sk
+		}
+
+		if err := validateRelayTarget(target); err != nil {
+			l.Debug().AnErr("error", err).Msg("CreateRelayTargetHandler: Invalid relay target")
+			utils.WriteError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		saved, err := store.SaveRelayTarget(ctx, db.SaveRelayTargetOpts{
+			UserID:          target.UserID,
+			Kind:            target.Kind,
+			Label:           target.Label,
+			URL:             target.URL,
+			Token:           target.Token,
+			ApiKey:          target.ApiKey,
+			ApiSecret:       target.ApiSecret,
+			Enabled:         target.Enabled,
+			RelayImports:    target.RelayImports,
+			RelayPlayingNow: target.RelayPlayingNow,
+			IncludeClients:  target.IncludeClients,
+			ExcludeClients:  target.ExcludeClients,
+		})
+		if err != nil {
+			l.Err(err).Msg("CreateRelayTargetHandler: Failed to save relay target")
+			utils.WriteError(w, "failed to save relay target", http.StatusInternalServerError)
+			return
+		}
+
+		l.Debug().Msgf("CreateRelayTargetHandler: Created relay target %d", saved.ID)
+		utils.WriteJSON(w, http.StatusCreated, saved)
+	}
+}
+
+// UpdateRelayTargetHandler updates the fields given in the form, leaving the rest unchanged.
+// The kind of a target cannot be changed.
+func UpdateRelayTargetHandler(store db.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		l := logger.FromContext(ctx)
+
+		l.Debug().Msg("UpdateRelayTargetHandler: Received request")
+
+		user := middleware.GetUserFromContext(ctx)
+		if user == nil {
+			l.Debug().Msg("UpdateRelayTargetHandler: Invalid user context")
+			utils.WriteError(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		if err := r.ParseForm(); err != nil {
+			l.Debug().AnErr("error", err).Msg("UpdateRelayTargetHandler: Failed to parse form")
+			utils.WriteError(w, "form is invalid", http.StatusBadRequest)
+			return
+		}
+
+		id, err := strconv.Atoi(r.FormValue("id"))
+		if err != nil {
+			l.Debug().AnErr("error", err).Msg("UpdateRelayTargetHandler: Invalid id")
+			utils.WriteError(w, "invalid id", http.StatusBadRequest)
+			return
+		}
+
+		target, err := store.GetRelayTarget(ctx, int32(id))
+		if err != nil {
+			l.Err(err).Msg("UpdateRelayTargetHandler: Failed to get relay target")
+			utils.WriteError(w, "failed to get relay target", http.StatusInternalServerError)
+			return
+		}
+		if target == nil || target.UserID != user.ID {
+			l.Debug().Msgf("UpdateRelayTargetHandler: Relay target %d not found", id)
+			utils.WriteError(w, "relay target not found", http.StatusNotFound)
+			return
+		}
+
+		if err := applyRelayTargetForm(target, r.Form); err != nil {
+			l.Debug().AnErr("error", err).Msg("UpdateRelayTargetHandler: Invalid relay target")
+			utils.WriteError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := validateRelayTarget(target); err != nil {
+			l.Debug().AnErr("error", err).Msg("UpdateRelayTargetHandler: Invalid relay target")
+			utils.WriteError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		err = store.UpdateRelayTarget(ctx, db.UpdateRelayTargetOpts{
+			ID:              target.ID,
+			UserID:          user.ID,
+			Label:           target.Label,
+			URL:             target.URL,
+			Token:           target.Token,
+			ApiKey:          target.ApiKey,
+			ApiSecret:       target.ApiSecret,
+			Enabled:         target.Enabled,
+			RelayImports:    target.RelayImports,
+			RelayPlayingNow: target.RelayPlayingNow,
+			IncludeClients:  target.IncludeClients,
+			ExcludeClients:  target.ExcludeClients,
+		})
+		if err != nil {
+			l.Err(err).Msg("UpdateRelayTargetHandler: Failed to update relay target")
+			utils.WriteError(w, "failed to update relay target", http.StatusInternalServerError)
+			return
+		}
+
+		l.Debug().Msgf("UpdateRelayTargetHandler: Updated relay target %d", target.ID)
+		utils.WriteJSON(w, http.StatusOK, target)
+	}
+}
+
+func DeleteRelayTargetHandler(store db.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		l := logger.FromContext(ctx)
+
+		l.Debug().Msg("DeleteRelayTargetHandler: Received request")
+
+		user := middleware.GetUserFromContext(ctx)
+		if user == nil {
+			l.Debug().Msg("DeleteRelayTargetHandler: Invalid user context")
+			utils.WriteError(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		idStr := r.URL.Query().Get("id")
+		if idStr == "" {
+			l.Debug().Msg("DeleteRelayTargetHandler: Missing id parameter")
+			utils.WriteError(w, "id is required", http.StatusBadRequest)
+			return
+		}
+		id, err := strconv.Atoi(idStr)
+		if err != nil {
+			l.Debug().AnErr("error", err).Msg("DeleteRelayTargetHandler: Invalid id")
+			utils.WriteError(w, "invalid id", http.StatusBadRequest)
+			return
+		}
+
+		err = store.DeleteRelayTarget(ctx, int32(id), user.ID)
+		if errors.Is(err, pgx.ErrNoRows) {
+			l.Debug().Msgf("DeleteRelayTargetHandler: Relay target %d not found", id)
+			utils.WriteError(w, "relay target not found", http.StatusNotFound)
+			return
+		} else if err != nil {
+			l.Err(err).Msg("DeleteRelayTargetHandler: Failed to delete relay target")
+			utils.WriteError(w, "failed to delete relay target", http.StatusInternalServerError)
+			return
+		}
+
+		l.Debug().Msgf("DeleteRelayTargetHandler: Deleted relay target %d", id)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// applyRelayTargetForm copies the fields present in the form onto the target. Client lists
+// may be given as repeated values, comma separated, or both.
+func applyRelayTargetForm(target *models.RelayTarget, form url.Values) error {
+	setString := func(key string, dst *string) {
+		if form.Has(key) {
+			*dst = strings.TrimSpace(form.Get(key))
+		}
+	}
+	setBool := func(key string, dst *bool) error {
+		if !form.Has(key) {
+			return nil
+		}
+		v, err := strconv.ParseBool(form.Get(key))
+		if err != nil {
+			return errors.New(key + " must be true or false")
+		}
+		*dst = v
+		return nil
+	}
+	setClients := func(key string, dst *[]string) {
+		if !form.Has(key) {
+			return
+		}
+		clients := []string{}
+		for _, v := range form[key] {
+			for _, c := range strings.Split(v, ",") {
+				if c = strings.TrimSpace(c); c != "" {
+					clients = append(clients, c)
+				}
+			}
+		}
+		*dst = clients
+	}
+
+	setString("label", &target.Label)
+	setString("url", &target.URL)
+	setString("token", &target.Token)
+	setString("api_key", &target.ApiKey)
+	setString("api_secret", &target.ApiSecret)
+	if err := setBool("enabled", &target.Enabled); err != nil {
+		return err
+	}
+	if err := setBool("relay_imports", &target.RelayImports); err != nil {
+		return err
+	}
+	if err := setBool("relay_playing_now", &target.RelayPlayingNow); err != nil {
+		return err
+	}
+	setClients("include_clients", &target.IncludeClients)
+	setClients("exclude_clients", &target.ExcludeClients)
+	return nil
+}
+
+// validateRelayTarget checks that the target has everything its kind needs to deliver listens.
+func validateRelayTarget(target *models.RelayTarget) error {
+	switch target.Kind {
+	case models.RelayTargetListenBrainz:
+		if target.Token == "" {
+			return errors.New("token is required for ListenBrainz targets")
+		}
+	case models.RelayTargetLastFm:
+		if target.ApiKey == "" || target.ApiSecret == "" {
+			return errors.New("api_key and api_secret are required for Last.fm targets")
+		}
+		if target.Token == "" {
+			return errors.New("a session key (token) or username and password are required for Last.fm targets")
+		}
+	case models.RelayTargetMaloja:
+		if target.URL == "" || target.Token == "" {
+			return errors.New("url and token are required for Maloja targets")
+		}
+	default:
+		return errors.New("kind must be one of 'listenbrainz', 'lastfm' or 'maloja'")
+	}
+	if target.URL != "" {
+		return relay.CheckTargetURL(target.URL)
+	}
+	return nil
+}
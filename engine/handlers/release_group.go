@@ -0,0 +1,209 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gabehf/koito/internal/db"
+	"github.com/gabehf/koito/internal/logger"
+	"github.com/gabehf/koito/internal/models"
+	"github.com/gabehf/koito/internal/utils"
+	"github.com/jackc/pgx/v5"
+)
+
+// GetReleaseGroupHandler returns a release group along with all of its editions. The group
+// can be requested by its own id or by the id of one of its albums.
+func GetReleaseGroupHandler(store db.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		l := logger.FromContext(ctx)
+
+		l.Debug().Msg("GetReleaseGroupHandler: Received request to retrieve release group")
+
+		idStr := r.URL.Query().Get("id")
+		albumIDStr := r.URL.Query().Get("album_id")
+		if idStr == "" && albumIDStr == "" {
+			l.Debug().Msg("GetReleaseGroupHandler: Missing release group or album ID in request")
+			utils.WriteError(w, "id or album_id must be provided", http.StatusBadRequest)
+			return
+		}
+		if utils.MoreThanOneString(idStr, albumIDStr) {
+			l.Debug().Msg("GetReleaseGroupHandler: Multiple ID parameters provided")
+			utils.WriteError(w, "only one of id or album_id can be provided", http.StatusBadRequest)
+			return
+		}
+
+		opts := db.GetReleaseGroupOpts{}
+		if idStr != "" {
+			id, err := strconv.Atoi(idStr)
+			if err != nil {
+				l.Debug().AnErr("error", err).Msg("GetReleaseGroupHandler: Invalid release group ID")
+				utils.WriteError(w, "id is invalid", http.StatusBadRequest)
+				return
+			}
+			opts.ID = int32(id)
+		} else {
+			albumId, err := strconv.Atoi(albumIDStr)
+			if err != nil {
+				l.Debug().AnErr("error", err).Msg("GetReleaseGroupHandler: Invalid album ID")
+				utils.WriteError(w, "album_id is invalid", http.StatusBadRequest)
+				return
+			}
+			opts.AlbumID = int32(albumId)
+		}
+
+		userID, err := userIDFromRequest(ctx, store, r)
+		if err != nil {
+			l.Err(err).Msg("GetReleaseGroupHandler: Failed to get user")
+			utils.WriteError(w, "failed to get user", http.StatusInternalServerError)
+			return
+		}
+		if userID == 0 {
+			utils.WriteError(w, "user not found", http.StatusNotFound)
+			return
+		}
+		opts.UserID = userID
+
+		rg, err := store.GetReleaseGroup(ctx, opts)
+		if errors.Is(err, pgx.ErrNoRows) {
+			l.Debug().Msg("GetReleaseGroupHandler: Release group not found")
+			utils.WriteError(w, "release group could not be found", http.StatusNotFound)
+			return
+		} else if err != nil {
+			l.Err(err).Msg("GetReleaseGroupHandler: Failed to retrieve release group")
+			utils.WriteError(w, "failed to retrieve release group", http.StatusInternalServerError)
+			return
+		}
+
+		utils.WriteJSON(w, http.StatusOK, rg)
+	}
+}
+
+// GroupAlbumsHandler adds an album to the release group of another album, so that both are
+// counted as editions of the same album. If neither album is part of a release group yet, a
+// new one is created, named after the album being grouped with.
+func GroupAlbumsHandler(store db.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		l := logger.FromContext(ctx)
+
+		l.Debug().Msg("GroupAlbumsHandler: Got request")
+
+		r.ParseForm()
+
+		albumIDStr := r.FormValue("album_id")
+		withAlbumIDStr := r.FormValue("with_album_id")
+		if albumIDStr == "" || withAlbumIDStr == "" {
+			l.Debug().Msg("GroupAlbumsHandler: album_id and with_album_id must be provided")
+			utils.WriteError(w, "album_id and with_album_id must be provided", http.StatusBadRequest)
+			return
+		}
+		albumId, err := strconv.Atoi(albumIDStr)
+		if err != nil {
+			l.Debug().Msg("GroupAlbumsHandler: album_id is invalid")
+			utils.WriteError(w, "album_id is invalid", http.StatusBadRequest)
+			return
+		}
+		withAlbumId, err := strconv.Atoi(withAlbumIDStr)
+		if err != nil {
+			l.Debug().Msg("GroupAlbumsHandler: with_album_id is invalid")
+			utils.WriteError(w, "with_album_id is invalid", http.StatusBadRequest)
+			return
+		}
+		if albumId == withAlbumId {
+			utils.WriteError(w, "album_id and with_album_id must be different", http.StatusBadRequest)
+			return
+		}
+
+		album, err := store.GetAlbum(ctx, db.GetAlbumOpts{ID: int32(albumId)})
+		if err != nil {
+			l.Debug().Err(err).Msg("GroupAlbumsHandler: Failed to get album")
+			utils.WriteError(w, "album with specified id could not be found", http.StatusNotFound)
+			return
+		}
+		target, err := store.GetAlbum(ctx, db.GetAlbumOpts{ID: int32(withAlbumId)})
+		if err != nil {
+			l.Debug().Err(err).Msg("GroupAlbumsHandler: Failed to get album")
+			utils.WriteError(w, "album with specified with_album_id could not be found", http.StatusNotFound)
+			return
+		}
+
+		var rgId int32
+		if target.ReleaseGroupID != nil {
+			rgId = *target.ReleaseGroupID
+		} else if album.ReleaseGroupID != nil {
+			rgId = *album.ReleaseGroupID
+		} else {
+			rg, err := store.SaveReleaseGroup(ctx, db.SaveReleaseGroupOpts{Title: target.Title})
+			if err != nil {
+				l.Error().Err(err).Msg("GroupAlbumsHandler: Failed to create release group")
+				utils.WriteError(w, "failed to create release group", http.StatusInternalServerError)
+				return
+			}
+			rgId = rg.ID
+		}
+
+		for _, a := range []*models.Album{album, target} {
+			if a.ReleaseGroupID != nil && *a.ReleaseGroupID == rgId {
+				continue
+			}
+			err = store.SetAlbumReleaseGroup(ctx, db.SetAlbumReleaseGroupOpts{
+				AlbumID:        a.ID,
+				ReleaseGroupID: rgId,
+			})
+			if err != nil {
+				l.Error().Err(err).Msg("GroupAlbumsHandler: Failed to set release group")
+				utils.WriteError(w, "failed to set release group", http.StatusInternalServerError)
+				return
+			}
+			recordItemAudit(ctx, store, models.AuditActionSetReleaseGroup, models.AuditTargetAlbum, a.ID, a)
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// UngroupAlbumHandler removes an album from its release group.
+func UngroupAlbumHandler(store db.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		l := logger.FromContext(ctx)
+
+		l.Debug().Msg("UngroupAlbumHandler: Got request")
+
+		albumIDStr := r.URL.Query().Get("album_id")
+		if albumIDStr == "" {
+			l.Debug().Msg("UngroupAlbumHandler: album_id must be provided")
+			utils.WriteError(w, "album_id must be provided", http.StatusBadRequest)
+			return
+		}
+		albumId, err := strconv.Atoi(albumIDStr)
+		if err != nil {
+			l.Debug().Msg("UngroupAlbumHandler: album_id is invalid")
+			utils.WriteError(w, "album_id is invalid", http.StatusBadRequest)
+			return
+		}
+
+		album, err := store.GetAlbum(ctx, db.GetAlbumOpts{ID: int32(albumId)})
+		if err != nil {
+			l.Debug().Err(err).Msg("UngroupAlbumHandler: Failed to get album")
+			utils.WriteError(w, "album with specified id could not be found", http.StatusNotFound)
+			return
+		}
+		if album.ReleaseGroupID == nil {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		err = store.SetAlbumReleaseGroup(ctx, db.SetAlbumReleaseGroupOpts{AlbumID: album.ID})
+		if err != nil {
+			l.Error().Err(err).Msg("UngroupAlbumHandler: Failed to remove release group")
+			utils.WriteError(w, "failed to remove album from release group", http.StatusInternalServerError)
+			return
+		}
+		recordItemAudit(ctx, store, models.AuditActionSetReleaseGroup, models.AuditTargetAlbum, album.ID, album)
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
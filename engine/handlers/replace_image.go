@@ -12,6 +12,7 @@ import (
 	"github.com/gabehf/koito/internal/db"
 	"github.com/gabehf/koito/internal/images"
 	"github.com/gabehf/koito/internal/logger"
+	"github.com/gabehf/koito/internal/models"
 	"github.com/gabehf/koito/internal/utils"
 	"github.com/google/uuid"
 )
@@ -68,6 +69,7 @@ func ReplaceImageHandler(store db.DB) http.HandlerFunc {
 		}
 
 		var oldImage *uuid.UUID
+		var before any
 		if artistId != 0 {
 			l.Debug().Msgf("ReplaceImageHandler: Fetching artist with ID %d", artistId)
 			a, err := store.GetArtist(ctx, db.GetArtistOpts{
@@ -79,6 +81,7 @@ func ReplaceImageHandler(store db.DB) http.HandlerFunc {
 				return
 			}
 			oldImage = a.Image
+			before = a
 		} else if albumId != 0 {
 			l.Debug().Msgf("ReplaceImageHandler: Fetching album with ID %d", albumId)
 			a, err := store.GetAlbum(ctx, db.GetAlbumOpts{
@@ -90,6 +93,7 @@ func ReplaceImageHandler(store db.DB) http.HandlerFunc {
 				return
 			}
 			oldImage = a.Image
+			before = a
 		}
 
 		l.Debug().Msg("ReplaceImageHandler: Getting image from request")
@@ -190,6 +194,7 @@ func ReplaceImageHandler(store db.DB) http.HandlerFunc {
 				utils.WriteError(w, "Artist image could not be updated", http.StatusInternalServerError)
 				return
 			}
+			recordItemAudit(ctx, store, models.AuditActionReplaceImage, models.AuditTargetArtist, int32(artistId), before)
 		} else if albumId != 0 {
 			l.Debug().Msgf("ReplaceImageHandler: Updating album with ID %d", albumId)
 			err := store.UpdateAlbum(ctx, db.UpdateAlbumOpts{
@@ -202,6 +207,7 @@ func ReplaceImageHandler(store db.DB) http.HandlerFunc {
 				utils.WriteError(w, "Album image could not be updated", http.StatusInternalServerError)
 				return
 			}
+			recordItemAudit(ctx, store, models.AuditActionReplaceImage, models.AuditTargetAlbum, int32(albumId), before)
 		}
 
 		if oldImage != nil {
@@ -0,0 +1,282 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/gabehf/koito/engine/middleware"
+	"github.com/gabehf/koito/internal/db"
+	"github.com/gabehf/koito/internal/logger"
+	"github.com/gabehf/koito/internal/models"
+	"github.com/gabehf/koito/internal/rules"
+	"github.com/gabehf/koito/internal/utils"
+	"github.com/jackc/pgx/v5"
+)
+
+type TestRewriteRulesResponse struct {
+	rules.Result
+	TrackMeta LbzTrackMeta `json:"track_metadata"`
+}
+
+func GetRewriteRulesHandler(store db.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		l := logger.FromContext(ctx)
+
+		l.Debug().Msg("GetRewriteRulesHandler: Received request")
+
+		user := middleware.GetUserFromContext(ctx)
+		if user == nil {
+			l.Debug().Msg("GetRewriteRulesHandler: Invalid user context")
+			utils.WriteError(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		rr, err := store.GetRewriteRulesByUserID(ctx, user.ID)
+		if err != nil {
+			l.Err(err).Msg("GetRewriteRulesHandler: Failed to get rewrite rules")
+			utils.WriteError(w, "failed to get rewrite rules", http.StatusInternalServerError)
+			return
+		}
+
+		l.Debug().Msgf("GetRewriteRulesHandler: Retrieved %d rewrite rules", len(rr))
+		utils.WriteJSON(w, http.StatusOK, rr)
+	}
+}
+
+// CreateRewriteRuleHandler adds a rule after the user's existing rules.
+func CreateRewriteRuleHandler(store db.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		l := logger.FromContext(ctx)
+
+		l.Debug().Msg("CreateRewriteRuleHandler: Received request")
+
+		user := middleware.GetUserFromContext(ctx)
+		if user == nil {
+			l.Debug().Msg("CreateRewriteRuleHandler: Invalid user context")
+			utils.WriteError(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		if err := r.ParseForm(); err != nil {
+			l.Debug().AnErr("error", err).Msg("CreateRewriteRuleHandler: Failed to parse form")
+			utils.WriteError(w, "form is invalid", http.StatusBadRequest)
+			return
+		}
+
+		rule := &models.RewriteRule{UserID: user.ID, Enabled: true}
+		if err := applyRewriteRuleForm(rule, r.Form); err != nil {
+			l.Debug().AnErr("error", err).Msg("CreateRewriteRuleHandler: Invalid rewrite rule")
+			utils.WriteError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		saved, err := store.SaveRewriteRule(ctx, db.SaveRewriteRuleOpts{
+			UserID:      user.ID,
+			Enabled:     rule.Enabled,
+			Field:       rule.Field,
+			MatchType:   rule.MatchType,
+			Pattern:     rule.Pattern,
+			Action:      rule.Action,
+			TargetField: rule.TargetField,
+			Replacement: rule.Replacement,
+		})
+		if err != nil {
+			l.Err(err).Msg("CreateRewriteRuleHandler: Failed to save rewrite rule")
+			utils.WriteError(w, "failed to save rewrite rule", http.StatusInternalServerError)
+			return
+		}
+
+		l.Debug().Msgf("CreateRewriteRuleHandler: Created rewrite rule %d", saved.ID)
+		utils.WriteJSON(w, http.StatusCreated, saved)
+	}
+}
+
+// UpdateRewriteRuleHandler updates the fields given in the form, leaving the rest unchanged.
+func UpdateRewriteRuleHandler(store db.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		l := logger.FromContext(ctx)
+
+		l.Debug().Msg("UpdateRewriteRuleHandler: Received request")
+
+		user := middleware.GetUserFromContext(ctx)
+		if user == nil {
+			l.Debug().Msg("UpdateRewriteRuleHandler: Invalid user context")
+			utils.WriteError(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		if err := r.ParseForm(); err != nil {
+			l.Debug().AnErr("error", err).Msg("UpdateRewriteRuleHandler: Failed to parse form")
+			utils.WriteError(w, "form is invalid", http.StatusBadRequest)
+			return
+		}
+
+		id, err := strconv.Atoi(r.FormValue("id"))
+		if err != nil {
+			l.Debug().AnErr("error", err).Msg("UpdateRewriteRuleHandler: Invalid id")
+			utils.WriteError(w, "invalid id", http.StatusBadRequest)
+			return
+		}
+
+		rule, err := store.GetRewriteRule(ctx, int32(id))
+		if err != nil {
+			l.Err(err).Msg("UpdateRewriteRuleHandler: Failed to get rewrite rule")
+			utils.WriteError(w, "failed to get rewrite rule", http.StatusInternalServerError)
+			return
+		}
+		if rule == nil || rule.UserID != user.ID {
+			l.Debug().Msgf("UpdateRewriteRuleHandler: Rewrite rule %d not found", id)
+			utils.WriteError(w, "rewrite rule not found", http.StatusNotFound)
+			return
+		}
+
+		if err := applyRewriteRuleForm(rule, r.Form); err != nil {
+			l.Debug().AnErr("error", err).Msg("UpdateRewriteRuleHandler: Invalid rewrite rule")
+			utils.WriteError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		err = store.UpdateRewriteRule(ctx, db.UpdateRewriteRuleOpts{
+			ID:          rule.ID,
+			UserID:      user.ID,
+			Position:    rule.Position,
+			Enabled:     rule.Enabled,
+			Field:       rule.Field,
+			MatchType:   rule.MatchType,
+			Pattern:     rule.Pattern,
+			Action:      rule.Action,
+			TargetField: rule.TargetField,
+			Replacement: rule.Replacement,
+		})
+		if err != nil {
+			l.Err(err).Msg("UpdateRewriteRuleHandler: Failed to update rewrite rule")
+			utils.WriteError(w, "failed to update rewrite rule", http.StatusInternalServerError)
+			return
+		}
+
+		l.Debug().Msgf("UpdateRewriteRuleHandler: Updated rewrite rule %d", rule.ID)
+		utils.WriteJSON(w, http.StatusOK, rule)
+	}
+}
+
+func DeleteRewriteRuleHandler(store db.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		l := logger.FromContext(ctx)
+
+		l.Debug().Msg("DeleteRewriteRuleHandler: Received request")
+
+		user := middleware.GetUserFromContext(ctx)
+		if user == nil {
+			l.Debug().Msg("DeleteRewriteRuleHandler: Invalid user context")
+			utils.WriteError(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		idStr := r.URL.Query().Get("id")
+		if idStr == "" {
+			l.Debug().Msg("DeleteRewriteRuleHandler: Missing id parameter")
+			utils.WriteError(w, "id is required", http.StatusBadRequest)
+			return
+		}
+		id, err := strconv.Atoi(idStr)
+		if err != nil {
+			l.Debug().AnErr("error", err).Msg("DeleteRewriteRuleHandler: Invalid id")
+			utils.WriteError(w, "invalid id", http.StatusBadRequest)
+			return
+		}
+
+		err = store.DeleteRewriteRule(ctx, int32(id), user.ID)
+		if errors.Is(err, pgx.ErrNoRows) {
+			l.Debug().Msgf("DeleteRewriteRuleHandler: Rewrite rule %d not found", id)
+			utils.WriteError(w, "rewrite rule not found", http.StatusNotFound)
+			return
+		} else if err != nil {
+			l.Err(err).Msg("DeleteRewriteRuleHandler: Failed to delete rewrite rule")
+			utils.WriteError(w, "failed to delete rewrite rule", http.StatusInternalServerError)
+			return
+		}
+
+		l.Debug().Msgf("DeleteRewriteRuleHandler: Deleted rewrite rule %d", id)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// TestRewriteRulesHandler runs the user's enabled rules against a ListenBrainz track_metadata
+// object without submitting anything, and returns the result.
+func TestRewriteRulesHandler(store db.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		l := logger.FromContext(ctx)
+
+		l.Debug().Msg("TestRewriteRulesHandler: Received request")
+
+		user := middleware.GetUserFromContext(ctx)
+		if user == nil {
+			l.Debug().Msg("TestRewriteRulesHandler: Invalid user context")
+			utils.WriteError(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		var meta LbzTrackMeta
+		r.Body = http.MaxBytesReader(w, r.Body, maxRequestBodyBytes)
+		if err := json.NewDecoder(r.Body).Decode(&meta); err != nil {
+			l.Debug().AnErr("error", err).Msg("TestRewriteRulesHandler: Failed to decode request")
+			utils.WriteError(w, "failed to decode request", http.StatusBadRequest)
+			return
+		}
+
+		set, err := rewriteRulesForUser(ctx, store, user.ID)
+		if err != nil {
+			l.Err(err).Msg("TestRewriteRulesHandler: Failed to load rewrite rules")
+			utils.WriteError(w, "failed to load rewrite rules", http.StatusInternalServerError)
+			return
+		}
+
+		res := applyRewriteRules(set, &meta)
+		utils.WriteJSON(w, http.StatusOK, TestRewriteRulesResponse{Result: res, TrackMeta: meta})
+	}
+}
+
+// applyRewriteRuleForm copies the fields present in the form onto the rule and validates the result.
+func applyRewriteRuleForm(rule *models.RewriteRule, form url.Values) error {
+	if form.Has("field") {
+		rule.Field = models.RewriteField(form.Get("field"))
+	}
+	if form.Has("match_type") {
+		rule.MatchType = models.RewriteMatchType(form.Get("match_type"))
+	}
+	if form.Has("pattern") {
+		rule.Pattern = form.Get("pattern")
+	}
+	if form.Has("action") {
+		rule.Action = models.RewriteAction(form.Get("action"))
+	}
+	if form.Has("target_field") {
+		rule.TargetField = models.RewriteField(form.Get("target_field"))
+	}
+	if form.Has("replacement") {
+		rule.Replacement = form.Get("replacement")
+	}
+	if form.Has("enabled") {
+		v, err := strconv.ParseBool(form.Get("enabled"))
+		if err != nil {
+			return errors.New("enabled must be true or false")
+		}
+		rule.Enabled = v
+	}
+	if form.Has("position") {
+		v, err := strconv.Atoi(form.Get("position"))
+		if err != nil {
+			return errors.New("position must be an integer")
+		}
+		rule.Position = int32(v)
+	}
+	return rules.Validate(rule)
+}
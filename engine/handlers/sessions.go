@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+
+	"github.com/gabehf/koito/engine/middleware"
+	"github.com/gabehf/koito/internal/db"
+	"github.com/gabehf/koito/internal/logger"
+	"github.com/gabehf/koito/internal/models"
+	"github.com/gabehf/koito/internal/utils"
+	"github.com/google/uuid"
+)
+
+type SessionResponse struct {
+	*models.Session
+	// Identifies the session without revealing it, since the session ID is what the
+	// session cookie holds
+	ID string `json:"id"`
+	// Whether this is the session the request was made with
+	Current bool `json:"current"`
+}
+
+// sessionHandle returns the identifier sessions are listed and revoked by, which is the hex
+// encoded SHA-256 of the session ID.
+func sessionHandle(id uuid.UUID) string {
+	sum := sha256.Sum256([]byte(id.String()))
+	return hex.EncodeToString(sum[:])
+}
+
+// currentSessionID returns the ID of the session the request was made with, or uuid.Nil when
+// it was not made with a session.
+func currentSessionID(r *http.Request) uuid.UUID {
+	cookie, err := r.Cookie("koito_session")
+	if err != nil {
+		return uuid.Nil
+	}
+	id, err := uuid.Parse(cookie.Value)
+	if err != nil {
+		return uuid.Nil
+	}
+	return id
+}
+
+// GetSessionsHandler lists the active sessions of the requesting user.
+func GetSessionsHandler(store db.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		l := logger.FromContext(ctx)
+
+		l.Debug().Msg("GetSessionsHandler: Received request")
+
+		user := middleware.GetUserFromContext(ctx)
+		if user == nil {
+			utils.WriteError(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		sessions, err := store.GetSessionsByUserID(ctx, user.ID)
+		if err != nil {
+			l.Err(err).Msg("GetSessionsHandler: Failed to get sessions")
+			utils.WriteError(w, "failed to get sessions", http.StatusInternalServerError)
+			return
+		}
+
+		current := currentSessionID(r)
+		resp := make([]SessionResponse, len(sessions))
+		for i, s := range sessions {
+			resp[i] = SessionResponse{Session: s, ID: sessionHandle(s.ID), Current: s.ID == current}
+		}
+		utils.WriteJSON(w, http.StatusOK, resp)
+	}
+}
+
+// DeleteSessionsHandler revokes the session given by id, as listed by GetSessionsHandler, or
+// every session except the current one when others is true.
+func DeleteSessionsHandler(store db.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		l := logger.FromContext(ctx)
+
+		l.Debug().Msg("DeleteSessionsHandler: Received request")
+
+		user := middleware.GetUserFromContext(ctx)
+		if user == nil {
+			utils.WriteError(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		idStr := r.URL.Query().Get("id")
+		others := r.URL.Query().Get("others") == "true"
+		if idStr == "" && !others {
+			utils.WriteError(w, "id or others=true is required", http.StatusBadRequest)
+			return
+		}
+
+		if others {
+			n, err := store.DeleteOtherSessionsForUser(ctx, user.ID, currentSessionID(r))
+			if err != nil {
+				l.Err(err).Msg("DeleteSessionsHandler: Failed to revoke sessions")
+				utils.WriteError(w, "failed to revoke sessions", http.StatusInternalServerError)
+				return
+			}
+			l.Info().Msgf("DeleteSessionsHandler: Revoked %d other sessions for user '%s'", n, user.Username)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		sessions, err := store.GetSessionsByUserID(ctx, user.ID)
+		if err != nil {
+			l.Err(err).Msg("DeleteSessionsHandler: Failed to get sessions")
+			utils.WriteError(w, "failed to revoke session", http.StatusInternalServerError)
+			return
+		}
+		id := uuid.Nil
+		for _, s := range sessions {
+			if sessionHandle(s.ID) == idStr {
+				id = s.ID
+				break
+			}
+		}
+		if id == uuid.Nil {
+			utils.WriteError(w, "session not found", http.StatusNotFound)
+			return
+		}
+		ok, err := store.DeleteSessionForUser(ctx, user.ID, id)
+		if err != nil {
+			l.Err(err).Msg("DeleteSessionsHandler: Failed to revoke session")
+			utils.WriteError(w, "failed to revoke session", http.StatusInternalServerError)
+			return
+		}
+		if !ok {
+			utils.WriteError(w, "session not found", http.StatusNotFound)
+			return
+		}
+
+		l.Info().Msgf("DeleteSessionsHandler: Revoked a session for user '%s'", user.Username)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
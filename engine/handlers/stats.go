@@ -22,39 +22,50 @@ func StatsHandler(store db.DB) http.HandlerFunc {
 
 		l.Debug().Msg("StatsHandler: Received request to retrieve statistics")
 
+		userID, err := userIDFromRequest(r.Context(), store, r)
+		if err != nil {
+			l.Err(err).Msg("StatsHandler: Failed to get user")
+			utils.WriteError(w, "failed to get user", http.StatusInternalServerError)
+			return
+		}
+		if userID == 0 {
+			utils.WriteError(w, "user not found", http.StatusNotFound)
+			return
+		}
+
 		tf := TimeframeFromRequest(r)
 
 		l.Debug().Msg("StatsHandler: Fetching statistics")
 
-		listens, err := store.CountListens(r.Context(), tf)
+		listens, err := store.CountListens(r.Context(), userID, tf)
 		if err != nil {
 			l.Err(err).Msg("StatsHandler: Failed to fetch listen count")
 			utils.WriteError(w, "failed to get listens: "+err.Error(), http.StatusInternalServerError)
 			return
 		}
 
-		tracks, err := store.CountTracks(r.Context(), tf)
+		tracks, err := store.CountTracks(r.Context(), userID, tf)
 		if err != nil {
 			l.Err(err).Msg("StatsHandler: Failed to fetch track count")
 			utils.WriteError(w, "failed to get tracks: "+err.Error(), http.StatusInternalServerError)
 			return
 		}
 
-		albums, err := store.CountAlbums(r.Context(), tf)
+		albums, err := store.CountAlbums(r.Context(), userID, tf)
 		if err != nil {
 			l.Err(err).Msg("StatsHandler: Failed to fetch album count")
 			utils.WriteError(w, "failed to get albums: "+err.Error(), http.StatusInternalServerError)
 			return
 		}
 
-		artists, err := store.CountArtists(r.Context(), tf)
+		artists, err := store.CountArtists(r.Context(), userID, tf)
 		if err != nil {
 			l.Err(err).Msg("StatsHandler: Failed to fetch artist count")
 			utils.WriteError(w, "failed to get artists: "+err.Error(), http.StatusInternalServerError)
 			return
 		}
 
-		timeListenedS, err := store.CountTimeListened(r.Context(), tf)
+		timeListenedS, err := store.CountTimeListened(r.Context(), userID, tf)
 		if err != nil {
 			l.Err(err).Msg("StatsHandler: Failed to fetch time listened")
 			utils.WriteError(w, "failed to get time listened: "+err.Error(), http.StatusInternalServerError)
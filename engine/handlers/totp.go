@@ -0,0 +1,305 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/gabehf/koito/engine/middleware"
+	"github.com/gabehf/koito/internal/db"
+	"github.com/gabehf/koito/internal/logger"
+	"github.com/gabehf/koito/internal/memkv"
+	"github.com/gabehf/koito/internal/models"
+	"github.com/gabehf/koito/internal/totp"
+	"github.com/gabehf/koito/internal/utils"
+)
+
+const (
+	totpIssuer            = "Koito"
+	totpRecoveryCodeCount = 10
+	totpLoginPrefix       = "totp_login:"
+	totpLoginTTL          = 5 * time.Minute
+	// after this many wrong codes the password has to be entered again
+	totpMaxAttempts = 5
+)
+
+// pendingTOTPLogin is a login that has passed the password check and is waiting for a code.
+type pendingTOTPLogin struct {
+	UserID   int32
+	Remember bool
+	attempts atomic.Int32
+}
+
+type TOTPSetupResponse struct {
+	Secret string `json:"secret"`
+	// The otpauth:// URI to show as a QR code
+	URI string `json:"uri"`
+}
+
+type TOTPRecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+type TOTPRequiredResponse struct {
+	TOTPRequired bool `json:"totp_required"`
+	// Passed to the second login step along with the code
+	LoginToken string `json:"login_token"`
+}
+
+// startTOTPLogin holds on to a login that passed the password check until a code is entered.
+func startTOTPLogin(w http.ResponseWriter, user *models.User, remember bool) error {
+	token, err := utils.GenerateRandomString(32)
+	if err != nil {
+		return err
+	}
+	memkv.Store.Set(totpLoginPrefix+token, &pendingTOTPLogin{UserID: user.ID, Remember: remember}, totpLoginTTL)
+	utils.WriteJSON(w, http.StatusOK, TOTPRequiredResponse{TOTPRequired: true, LoginToken: token})
+	return nil
+}
+
+// checkSecondFactor accepts either a current code from the user's authenticator, which can
+// only be used once, or one of their unused recovery codes.
+func checkSecondFactor(ctx context.Context, store db.DB, user *models.User, code string) (bool, error) {
+	code = strings.TrimSpace(code)
+	if code == "" || user.TOTPSecret == "" {
+		return false, nil
+	}
+	if step, ok := totp.Validate(user.TOTPSecret, code, time.Now()); ok {
+		return store.UseTOTPStep(ctx, user.ID, step)
+	}
+	return store.UseTOTPRecoveryCode(ctx, user.ID, code)
+}
+
+// LoginTOTPHandler completes a login for a user with two-factor authentication enabled.
+func LoginTOTPHandler(store db.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		l := logger.FromContext(ctx)
+
+		l.Debug().Msg("LoginTOTPHandler: Received request")
+
+		if err := r.ParseForm(); err != nil {
+			l.Debug().AnErr("error", err).Msg("LoginTOTPHandler: Failed to parse form")
+			utils.WriteError(w, "invalid request format", http.StatusBadRequest)
+			return
+		}
+
+		token := r.FormValue("login_token")
+		code := r.FormValue("code")
+		if token == "" || code == "" {
+			l.Debug().Msg("LoginTOTPHandler: Missing login token or code")
+			utils.WriteError(w, "login_token and code are required", http.StatusBadRequest)
+			return
+		}
+
+		v, ok := memkv.Store.Get(totpLoginPrefix + token)
+		pending, _ := v.(*pendingTOTPLogin)
+		if !ok || pending == nil {
+			l.Debug().Msg("LoginTOTPHandler: Login token is invalid or has expired")
+			utils.WriteError(w, "login has expired, please try again", http.StatusUnauthorized)
+			return
+		}
+
+		user, err := store.GetUserByID(ctx, pending.UserID)
+		if err != nil {
+			l.Error().Err(err).Msg("LoginTOTPHandler: Database error fetching user")
+			utils.WriteError(w, "authentication failed", http.StatusInternalServerError)
+			return
+		}
+		if user == nil || user.Disabled {
+			memkv.Store.Delete(totpLoginPrefix + token)
+			utils.WriteError(w, "invalid credentials", http.StatusUnauthorized)
+			return
+		}
+
+		valid, err := checkSecondFactor(ctx, store, user, code)
+		if err != nil {
+			l.Error().Err(err).Msg("LoginTOTPHandler: Failed to check code")
+			utils.WriteError(w, "authentication failed", http.StatusInternalServerError)
+			return
+		}
+		// two-factor authentication may have been reset since the password was checked
+		if !valid && user.TOTPEnabled {
+			if pending.attempts.Add(1) >= totpMaxAttempts {
+				memkv.Store.Delete(totpLoginPrefix + token)
+			}
+			l.Debug().Msgf("LoginTOTPHandler: Invalid code for user %d", user.ID)
+			utils.WriteError(w, "invalid code", http.StatusUnauthorized)
+			return
+		}
+		memkv.Store.Delete(totpLoginPrefix + token)
+
+		if err := startSession(w, r, store, user.ID, pending.Remember); err != nil {
+			l.Error().Err(err).Msg("LoginTOTPHandler: Failed to create session")
+			utils.WriteError(w, "authentication failed", http.StatusInternalServerError)
+			return
+		}
+
+		l.Debug().Msgf("LoginTOTPHandler: User %d authenticated", user.ID)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// TOTPSetupHandler generates a new secret for the user to add to their authenticator. Two-factor
+// authentication is not enabled until a code from it has been verified with TOTPEnableHandler.
+func TOTPSetupHandler(store db.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		l := logger.FromContext(ctx)
+
+		l.Debug().Msg("TOTPSetupHandler: Received request")
+
+		user := middleware.GetUserFromContext(ctx)
+		if user == nil {
+			utils.WriteError(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if user.TOTPEnabled {
+			l.Debug().Msgf("TOTPSetupHandler: User %d already has two-factor authentication enabled", user.ID)
+			utils.WriteError(w, "two-factor authentication is already enabled", http.StatusConflict)
+			return
+		}
+
+		secret, err := totp.GenerateSecret()
+		if err != nil {
+			l.Err(err).Msg("TOTPSetupHandler: Failed to generate secret")
+			utils.WriteError(w, "failed to set up two-factor authentication", http.StatusInternalServerError)
+			return
+		}
+		if err := store.SetUserTOTPSecret(ctx, user.ID, secret); err != nil {
+			l.Err(err).Msg("TOTPSetupHandler: Failed to save secret")
+			utils.WriteError(w, "failed to set up two-factor authentication", http.StatusInternalServerError)
+			return
+		}
+
+		utils.WriteJSON(w, http.StatusOK, TOTPSetupResponse{
+			Secret: secret,
+			URI:    totp.ProvisioningURI(totpIssuer, user.Username, secret),
+		})
+	}
+}
+
+// TOTPEnableHandler enables two-factor authentication once the user has entered a code from
+// their authenticator, and returns their recovery codes. The codes are only shown this once.
+func TOTPEnableHandler(store db.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		l := logger.FromContext(ctx)
+
+		l.Debug().Msg("TOTPEnableHandler: Received request")
+
+		u := middleware.GetUserFromContext(ctx)
+		if u == nil {
+			utils.WriteError(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if err := r.ParseForm(); err != nil {
+			utils.WriteError(w, "invalid request format", http.StatusBadRequest)
+			return
+		}
+
+		// the user in the context does not carry the secret
+		user, err := store.GetUserByID(ctx, u.ID)
+		if err != nil || user == nil {
+			l.Err(err).Msg("TOTPEnableHandler: Failed to get user")
+			utils.WriteError(w, "failed to enable two-factor authentication", http.StatusInternalServerError)
+			return
+		}
+		if user.TOTPEnabled {
+			utils.WriteError(w, "two-factor authentication is already enabled", http.StatusConflict)
+			return
+		}
+		if user.TOTPSecret == "" {
+			utils.WriteError(w, "two-factor authentication has not been set up", http.StatusBadRequest)
+			return
+		}
+
+		step, ok := totp.Validate(user.TOTPSecret, r.FormValue("code"), time.Now())
+		if !ok {
+			l.Debug().Msgf("TOTPEnableHandler: Invalid code for user %d", user.ID)
+			utils.WriteError(w, "invalid code", http.StatusBadRequest)
+			return
+		}
+		if _, err := store.UseTOTPStep(ctx, user.ID, step); err != nil {
+			l.Err(err).Msg("TOTPEnableHandler: Failed to record code")
+			utils.WriteError(w, "failed to enable two-factor authentication", http.StatusInternalServerError)
+			return
+		}
+
+		codes, err := totp.GenerateRecoveryCodes(totpRecoveryCodeCount)
+		if err != nil {
+			l.Err(err).Msg("TOTPEnableHandler: Failed to generate recovery codes")
+			utils.WriteError(w, "failed to enable two-factor authentication", http.StatusInternalServerError)
+			return
+		}
+		if err := store.EnableUserTOTP(ctx, user.ID, codes); err != nil {
+			l.Err(err).Msg("TOTPEnableHandler: Failed to enable two-factor authentication")
+			utils.WriteError(w, "failed to enable two-factor authentication", http.StatusInternalServerError)
+			return
+		}
+
+		l.Info().Msgf("TOTPEnableHandler: Enabled two-factor authentication for user '%s'", user.Username)
+		utils.WriteJSON(w, http.StatusOK, TOTPRecoveryCodesResponse{RecoveryCodes: codes})
+	}
+}
+
+// TOTPDisableHandler turns off two-factor authentication. A current code or a recovery code
+// is required, so that a stolen session alone can't be used to remove it.
+func TOTPDisableHandler(store db.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		l := logger.FromContext(ctx)
+
+		l.Debug().Msg("TOTPDisableHandler: Received request")
+
+		u := middleware.GetUserFromContext(ctx)
+		if u == nil {
+			utils.WriteError(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if err := r.ParseForm(); err != nil {
+			utils.WriteError(w, "invalid request format", http.StatusBadRequest)
+			return
+		}
+
+		user, err := store.GetUserByID(ctx, u.ID)
+		if err != nil || user == nil {
+			l.Err(err).Msg("TOTPDisableHandler: Failed to get user")
+			utils.WriteError(w, "failed to disable two-factor authentication", http.StatusInternalServerError)
+			return
+		}
+		if !user.TOTPEnabled {
+			// a setup that was never completed can be abandoned without a code
+			if err := store.DisableUserTOTP(ctx, user.ID); err != nil {
+				l.Err(err).Msg("TOTPDisableHandler: Failed to disable two-factor authentication")
+				utils.WriteError(w, "failed to disable two-factor authentication", http.StatusInternalServerError)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		valid, err := checkSecondFactor(ctx, store, user, r.FormValue("code"))
+		if err != nil {
+			l.Err(err).Msg("TOTPDisableHandler: Failed to check code")
+			utils.WriteError(w, "failed to disable two-factor authentication", http.StatusInternalServerError)
+			return
+		}
+		if !valid {
+			l.Debug().Msgf("TOTPDisableHandler: Invalid code for user %d", user.ID)
+			utils.WriteError(w, "invalid code", http.StatusBadRequest)
+			return
+		}
+
+		if err := store.DisableUserTOTP(ctx, user.ID); err != nil {
+			l.Err(err).Msg("TOTPDisableHandler: Failed to disable two-factor authentication")
+			utils.WriteError(w, "failed to disable two-factor authentication", http.StatusInternalServerError)
+			return
+		}
+
+		l.Info().Msgf("TOTPDisableHandler: Disabled two-factor authentication for user '%s'", user.Username)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,405 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gabehf/koito/engine/middleware"
+	"github.com/gabehf/koito/internal/db"
+	"github.com/gabehf/koito/internal/logger"
+	"github.com/gabehf/koito/internal/models"
+	"github.com/gabehf/koito/internal/utils"
+)
+
+// how long an invite can be used for when no expiry is given
+const defaultInviteExpiry = 7 * 24 * time.Hour
+
+func parseUserRole(s string) (models.UserRole, bool) {
+	switch models.UserRole(s) {
+	case models.UserRoleAdmin, models.UserRoleUser:
+		return models.UserRole(s), true
+	}
+	return "", false
+}
+
+func GetUsersHandler(store db.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		l := logger.FromContext(ctx)
+
+		l.Debug().Msg("GetUsersHandler: Received request")
+
+		users, err := store.GetUsers(ctx)
+		if err != nil {
+			l.Err(err).Msg("GetUsersHandler: Failed to get users")
+			utils.WriteError(w, "failed to get users", http.StatusInternalServerError)
+			return
+		}
+
+		l.Debug().Msgf("GetUsersHandler: Retrieved %d users", len(users))
+		utils.WriteJSON(w, http.StatusOK, users)
+	}
+}
+
+func CreateUserHandler(store db.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		l := logger.FromContext(ctx)
+
+		l.Debug().Msg("CreateUserHandler: Received request")
+
+		if err := r.ParseForm(); err != nil {
+			l.Debug().AnErr("error", err).Msg("CreateUserHandler: Failed to parse form")
+			utils.WriteError(w, "form is invalid", http.StatusBadRequest)
+			return
+		}
+
+		username := r.FormValue("username")
+		password := r.FormValue("password")
+		if username == "" || password == "" {
+			l.Debug().Msg("CreateUserHandler: Missing username or password")
+			utils.WriteError(w, "username and password are required", http.StatusBadRequest)
+			return
+		}
+
+		role := models.UserRoleUser
+		if roleStr := r.FormValue("role"); roleStr != "" {
+			var ok bool
+			if role, ok = parseUserRole(roleStr); !ok {
+				l.Debug().Msgf("CreateUserHandler: Invalid role '%s'", roleStr)
+				utils.WriteError(w, "role must be 'admin' or 'user'", http.StatusBadRequest)
+				return
+			}
+		}
+
+		existing, err := store.GetUserByUsername(ctx, username)
+		if err != nil {
+			l.Err(err).Msg("CreateUserHandler: Failed to check for existing user")
+			utils.WriteError(w, "failed to create user", http.StatusInternalServerError)
+			return
+		}
+		if existing != nil {
+			l.Debug().Msgf("CreateUserHandler: Username '%s' is taken", username)
+			utils.WriteError(w, "username is taken", http.StatusConflict)
+			return
+		}
+
+		user, err := store.SaveUser(ctx, db.SaveUserOpts{
+			Username: username,
+			Password: password,
+			Role:     role,
+		})
+		if err != nil {
+			l.Debug().AnErr("error", err).Msg("CreateUserHandler: Failed to save user")
+			utils.WriteError(w, "failed to create user", http.StatusBadRequest)
+			return
+		}
+
+		l.Info().Msgf("CreateUserHandler: Created user '%s' with role '%s'", user.Username, user.Role)
+		utils.WriteJSON(w, http.StatusCreated, user)
+	}
+}
+
+// AdminUpdateUserHandler changes the role of a user, or disables or enables them. Admins
+// cannot change their own account here, so that there is always an admin left.
+func AdminUpdateUserHandler(store db.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		l := logger.FromContext(ctx)
+
+		l.Debug().Msg("AdminUpdateUserHandler: Received request")
+
+		admin := middleware.GetUserFromContext(ctx)
+		if admin == nil {
+			l.Debug().Msg("AdminUpdateUserHandler: Invalid user context")
+			utils.WriteError(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		if err := r.ParseForm(); err != nil {
+			l.Debug().AnErr("error", err).Msg("AdminUpdateUserHandler: Failed to parse form")
+			utils.WriteError(w, "form is invalid", http.StatusBadRequest)
+			return
+		}
+
+		id, err := strconv.Atoi(r.FormValue("id"))
+		if err != nil {
+			l.Debug().AnErr("error", err).Msg("AdminUpdateUserHandler: Invalid user ID")
+			utils.WriteError(w, "id is invalid", http.StatusBadRequest)
+			return
+		}
+		if int32(id) == admin.ID {
+			l.Debug().Msg("AdminUpdateUserHandler: Attempted to change own account")
+			utils.WriteError(w, "you cannot change your own role or disable yourself", http.StatusBadRequest)
+			return
+		}
+
+		opts := db.UpdateUserOpts{ID: int32(id)}
+		if roleStr := r.FormValue("role"); roleStr != "" {
+			role, ok := parseUserRole(roleStr)
+			if !ok {
+				l.Debug().Msgf("AdminUpdateUserHandler: Invalid role '%s'", roleStr)
+				utils.WriteError(w, "role must be 'admin' or 'user'", http.StatusBadRequest)
+				return
+			}
+			opts.Role = role
+		}
+		if disabledStr := r.FormValue("disabled"); disabledStr != "" {
+			disabled, err := strconv.ParseBool(disabledStr)
+			if err != nil {
+				l.Debug().AnErr("error", err).Msg("AdminUpdateUserHandler: Invalid disabled value")
+				utils.WriteError(w, "disabled must be true or false", http.StatusBadRequest)
+				return
+			}
+			opts.Disabled = &disabled
+		}
+		// lets users who have lost their authenticator and recovery codes log in with just their password
+		resetTOTP := false
+		if resetStr := r.FormValue("reset_totp"); resetStr != "" {
+			resetTOTP, err = strconv.ParseBool(resetStr)
+			if err != nil {
+				l.Debug().AnErr("error", err).Msg("AdminUpdateUserHandler: Invalid reset_totp value")
+				utils.WriteError(w, "reset_totp must be true or false", http.StatusBadRequest)
+				return
+			}
+		}
+		if opts.Role == "" && opts.Disabled == nil && !resetTOTP {
+			l.Debug().Msg("AdminUpdateUserHandler: No update parameters provided")
+			utils.WriteError(w, "no changes specified", http.StatusBadRequest)
+			return
+		}
+
+		user, err := store.GetUserByID(ctx, opts.ID)
+		if err != nil {
+			l.Err(err).Msg("AdminUpdateUserHandler: Failed to get user")
+			utils.WriteError(w, "failed to update user", http.StatusInternalServerError)
+			return
+		}
+		if user == nil {
+			utils.WriteError(w, "user not found", http.StatusNotFound)
+			return
+		}
+
+		if err := store.UpdateUser(ctx, opts); err != nil {
+			l.Err(err).Msg("AdminUpdateUserHandler: Failed to update user")
+			utils.WriteError(w, "failed to update user", http.StatusInternalServerError)
+			return
+		}
+		if resetTOTP {
+			if err := store.DisableUserTOTP(ctx, user.ID); err != nil {
+				l.Err(err).Msg("AdminUpdateUserHandler: Failed to reset two-factor authentication")
+				utils.WriteError(w, "failed to update user", http.StatusInternalServerError)
+				return
+			}
+		}
+
+		l.Info().Msgf("AdminUpdateUserHandler: Updated user '%s'", user.Username)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// DeleteUserHandler deletes a user along with all of their listens, API keys and sessions.
+func DeleteUserHandler(store db.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		l := logger.FromContext(ctx)
+
+		l.Debug().Msg("DeleteUserHandler: Received request")
+
+		admin := middleware.GetUserFromContext(ctx)
+		if admin == nil {
+			l.Debug().Msg("DeleteUserHandler: Invalid user context")
+			utils.WriteError(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		id, err := strconv.Atoi(r.URL.Query().Get("id"))
+		if err != nil {
+			l.Debug().AnErr("error", err).Msg("DeleteUserHandler: Invalid user ID")
+			utils.WriteError(w, "id is invalid", http.StatusBadRequest)
+			return
+		}
+		if int32(id) == admin.ID {
+			l.Debug().Msg("DeleteUserHandler: Attempted to delete own account")
+			utils.WriteError(w, "you cannot delete yourself", http.StatusBadRequest)
+			return
+		}
+
+		if err := store.DeleteUser(ctx, int32(id)); err != nil {
+			l.Err(err).Msg("DeleteUserHandler: Failed to delete user")
+			utils.WriteError(w, "failed to delete user", http.StatusInternalServerError)
+			return
+		}
+
+		l.Info().Msgf("DeleteUserHandler: Deleted user %d", id)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func GetUserInvitesHandler(store db.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		l := logger.FromContext(ctx)
+
+		l.Debug().Msg("GetUserInvitesHandler: Received request")
+
+		invites, err := store.GetUserInvites(ctx)
+		if err != nil {
+			l.Err(err).Msg("GetUserInvitesHandler: Failed to get invites")
+			utils.WriteError(w, "failed to get invites", http.StatusInternalServerError)
+			return
+		}
+
+		l.Debug().Msgf("GetUserInvitesHandler: Retrieved %d invites", len(invites))
+		utils.WriteJSON(w, http.StatusOK, invites)
+	}
+}
+
+// CreateUserInviteHandler issues a one-time token that can be used to register an account.
+// The optional expires_in parameter is a duration such as '48h', defaulting to one week.
+func CreateUserInviteHandler(store db.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		l := logger.FromContext(ctx)
+
+		l.Debug().Msg("CreateUserInviteHandler: Received request")
+
+		admin := middleware.GetUserFromContext(ctx)
+		if admin == nil {
+			l.Debug().Msg("CreateUserInviteHandler: Invalid user context")
+			utils.WriteError(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		if err := r.ParseForm(); err != nil {
+			l.Debug().AnErr("error", err).Msg("CreateUserInviteHandler: Failed to parse form")
+			utils.WriteError(w, "form is invalid", http.StatusBadRequest)
+			return
+		}
+
+		role := models.UserRoleUser
+		if roleStr := r.FormValue("role"); roleStr != "" {
+			var ok bool
+			if role, ok = parseUserRole(roleStr); !ok {
+				l.Debug().Msgf("CreateUserInviteHandler: Invalid role '%s'", roleStr)
+				utils.WriteError(w, "role must be 'admin' or 'user'", http.StatusBadRequest)
+				return
+			}
+		}
+
+		expiry := defaultInviteExpiry
+		if expiresIn := r.FormValue("expires_in"); expiresIn != "" {
+			d, err := time.ParseDuration(expiresIn)
+			if err != nil || d <= 0 {
+				l.Debug().Msgf("CreateUserInviteHandler: Invalid expiry '%s'", expiresIn)
+				utils.WriteError(w, "expires_in must be a positive duration, e.g. '48h'", http.StatusBadRequest)
+				return
+			}
+			expiry = d
+		}
+
+		token, err := utils.GenerateRandomString(32)
+		if err != nil {
+			l.Err(err).Msg("CreateUserInviteHandler: Failed to generate token")
+			utils.WriteError(w, "failed to create invite", http.StatusInternalServerError)
+			return
+		}
+
+		invite, err := store.SaveUserInvite(ctx, db.SaveUserInviteOpts{
+			Token:     token,
+			Role:      role,
+			CreatedBy: admin.ID,
+			ExpiresAt: time.Now().Add(expiry),
+		})
+		if err != nil {
+			l.Err(err).Msg("CreateUserInviteHandler: Failed to save invite")
+			utils.WriteError(w, "failed to create invite", http.StatusInternalServerError)
+			return
+		}
+
+		l.Debug().Msgf("CreateUserInviteHandler: Created invite %d", invite.ID)
+		utils.WriteJSON(w, http.StatusCreated, invite)
+	}
+}
+
+func DeleteUserInviteHandler(store db.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		l := logger.FromContext(ctx)
+
+		l.Debug().Msg("DeleteUserInviteHandler: Received request")
+
+		id, err := strconv.Atoi(r.URL.Query().Get("id"))
+		if err != nil {
+			l.Debug().AnErr("error", err).Msg("DeleteUserInviteHandler: Invalid invite ID")
+			utils.WriteError(w, "id is invalid", http.StatusBadRequest)
+			return
+		}
+
+		if err := store.DeleteUserInvite(ctx, int32(id)); err != nil {
+			l.Err(err).Msg("DeleteUserInviteHandler: Failed to delete invite")
+			utils.WriteError(w, "failed to delete invite", http.StatusInternalServerError)
+			return
+		}
+
+		l.Debug().Msgf("DeleteUserInviteHandler: Deleted invite %d", id)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// RegisterHandler creates an account using an invite token.
+func RegisterHandler(store db.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		l := logger.FromContext(ctx)
+
+		l.Debug().Msg("RegisterHandler: Received request")
+
+		if err := r.ParseForm(); err != nil {
+			l.Debug().AnErr("error", err).Msg("RegisterHandler: Failed to parse form")
+			utils.WriteError(w, "form is invalid", http.StatusBadRequest)
+			return
+		}
+
+		token := r.FormValue("token")
+		username := r.FormValue("username")
+		password := r.FormValue("password")
+		if token == "" || username == "" || password == "" {
+			l.Debug().Msg("RegisterHandler: Missing parameters")
+			utils.WriteError(w, "token, username and password are required", http.StatusBadRequest)
+			return
+		}
+
+		existing, err := store.GetUserByUsername(ctx, username)
+		if err != nil {
+			l.Err(err).Msg("RegisterHandler: Failed to check for existing user")
+			utils.WriteError(w, "failed to register", http.StatusInternalServerError)
+			return
+		}
+		if existing != nil {
+			l.Debug().Msgf("RegisterHandler: Username '%s' is taken", username)
+			utils.WriteError(w, "username is taken", http.StatusConflict)
+			return
+		}
+
+		user, err := store.RedeemUserInvite(ctx, db.RedeemUserInviteOpts{
+			Token:    token,
+			Username: username,
+			Password: password,
+		})
+		if err != nil {
+			l.Debug().AnErr("error", err).Msg("RegisterHandler: Failed to redeem invite")
+			utils.WriteError(w, "failed to register", http.StatusBadRequest)
+			return
+		}
+		if user == nil {
+			l.Debug().Msg("RegisterHandler: Invite is invalid, used or expired")
+			utils.WriteError(w, "invite is invalid or has expired", http.StatusForbidden)
+			return
+		}
+
+		l.Info().Msgf("RegisterHandler: Registered user '%s'", user.Username)
+		utils.WriteJSON(w, http.StatusCreated, user)
+	}
+}
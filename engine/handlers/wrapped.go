@@ -5,7 +5,6 @@ import (
 	"strconv"
 	"time"
 
-	"github.com/gabehf/koito/engine/middleware"
 	"github.com/gabehf/koito/internal/db"
 	"github.com/gabehf/koito/internal/logger"
 	"github.com/gabehf/koito/internal/models"
@@ -63,9 +62,15 @@ func WrappedHandler(store db.DB) http.HandlerFunc {
 
 		l.Debug().Msgf("WrappedHandler: Fetching wrapped stats for year '%d'", year)
 
-		userID := int32(1)
-		if u := middleware.GetUserFromContext(r.Context()); u != nil {
-			userID = u.ID
+		userID, err := userIDFromRequest(r.Context(), store, r)
+		if err != nil {
+			l.Err(err).Msg("WrappedHandler: Failed to get user")
+			utils.WriteError(w, "failed to get user", http.StatusInternalServerError)
+			return
+		}
+		if userID == 0 {
+			utils.WriteError(w, "user not found", http.StatusNotFound)
+			return
 		}
 
 		stats, err := store.GetWrappedStats(r.Context(), year, userID)
@@ -35,7 +35,7 @@ func TestImportMaloja(t *testing.T) {
 	engine.RunImporter(logger.Get(), store, &mbz.MbzErrorCaller{})
 
 	// maloja test import is 38 Magnify Tokyo streams
-	a, err := store.GetArtist(context.Background(), db.GetArtistOpts{Name: "Magnify Tokyo"})
+	a, err := store.GetArtist(context.Background(), db.GetArtistOpts{UserID: 1, Name: "Magnify Tokyo"})
 	require.NoError(t, err)
 	t.Log(a)
 	assert.Equal(t, "Magnify Tokyo", a.Name)
@@ -59,11 +59,11 @@ func TestImportSpotify(t *testing.T) {
 
 	engine.RunImporter(logger.Get(), store, &mbz.MbzErrorCaller{})
 
-	a, err := store.GetArtist(context.Background(), db.GetArtistOpts{Name: "The Story So Far"})
+	a, err := store.GetArtist(context.Background(), db.GetArtistOpts{UserID: 1, Name: "The Story So Far"})
 	require.NoError(t, err)
-	r, err := store.GetAlbum(context.Background(), db.GetAlbumOpts{ArtistID: a.ID, Title: "The Story So Far / Stick To Your Guns Split"})
+	r, err := store.GetAlbum(context.Background(), db.GetAlbumOpts{UserID: 1, ArtistID: a.ID, Title: "The Story So Far / Stick To Your Guns Split"})
 	require.NoError(t, err)
-	track, err := store.GetTrack(context.Background(), db.GetTrackOpts{Title: "Clairvoyant", ReleaseID: r.ID, ArtistIDs: []int32{a.ID}})
+	track, err := store.GetTrack(context.Background(), db.GetTrackOpts{UserID: 1, Title: "Clairvoyant", ReleaseID: r.ID, ArtistIDs: []int32{a.ID}})
 	require.NoError(t, err)
 	t.Log(track)
 	assert.Equal(t, "Clairvoyant", track.Title)
@@ -103,18 +103,18 @@ func TestImportLastFM(t *testing.T) {
 
 	engine.RunImporter(logger.Get(), store, mbzcMock)
 
-	album, err := store.GetAlbum(context.Background(), db.GetAlbumOpts{MusicBrainzID: uuid.MustParse("e9e78802-0bf8-4ca3-9655-1d943d2d2fa0")})
+	album, err := store.GetAlbum(context.Background(), db.GetAlbumOpts{UserID: 1, MusicBrainzID: uuid.MustParse("e9e78802-0bf8-4ca3-9655-1d943d2d2fa0")})
 	require.NoError(t, err)
 	assert.Equal(t, "ZOO!!", album.Title)
-	artist, err := store.GetArtist(context.Background(), db.GetArtistOpts{MusicBrainzID: uuid.MustParse("4b00640f-3be6-43f8-9b34-ff81bd89320a")})
+	artist, err := store.GetArtist(context.Background(), db.GetArtistOpts{UserID: 1, MusicBrainzID: uuid.MustParse("4b00640f-3be6-43f8-9b34-ff81bd89320a")})
 	require.NoError(t, err)
 	assert.Equal(t, "OurR", artist.Name)
-	artist, err = store.GetArtist(context.Background(), db.GetArtistOpts{Name: "Necry Talkie"})
+	artist, err = store.GetArtist(context.Background(), db.GetArtistOpts{UserID: 1, Name: "Necry Talkie"})
 	require.NoError(t, err)
-	track, err := store.GetTrack(context.Background(), db.GetTrackOpts{Title: "放課後の記憶", ReleaseID: album.ID, ArtistIDs: []int32{artist.ID}})
+	track, err := store.GetTrack(context.Background(), db.GetTrackOpts{UserID: 1, Title: "放課後の記憶", ReleaseID: album.ID, ArtistIDs: []int32{artist.ID}})
 	require.NoError(t, err)
 	t.Log(track)
-	listens, err := store.GetListensPaginated(context.Background(), db.GetItemsOpts{TrackID: int(track.ID), Timeframe: db.Timeframe{Period: db.PeriodAllTime}})
+	listens, err := store.GetListensPaginated(context.Background(), db.GetItemsOpts{UserID: 1, TrackID: int(track.ID), Timeframe: db.Timeframe{Period: db.PeriodAllTime}})
 	require.NoError(t, err)
 	require.Len(t, listens.Items, 1)
 	assert.WithinDuration(t, time.Unix(1749774900, 0), listens.Items[0].Time, 1*time.Second)
@@ -137,18 +137,18 @@ func TestImportLastFM_MbzDisabled(t *testing.T) {
 
 	engine.RunImporter(logger.Get(), store, &mbz.MbzErrorCaller{})
 
-	album, err := store.GetAlbum(context.Background(), db.GetAlbumOpts{MusicBrainzID: uuid.MustParse("e9e78802-0bf8-4ca3-9655-1d943d2d2fa0")})
+	album, err := store.GetAlbum(context.Background(), db.GetAlbumOpts{UserID: 1, MusicBrainzID: uuid.MustParse("e9e78802-0bf8-4ca3-9655-1d943d2d2fa0")})
 	require.NoError(t, err)
 	assert.Equal(t, "ZOO!!", album.Title)
-	artist, err := store.GetArtist(context.Background(), db.GetArtistOpts{MusicBrainzID: uuid.MustParse("4b00640f-3be6-43f8-9b34-ff81bd89320a")})
+	artist, err := store.GetArtist(context.Background(), db.GetArtistOpts{UserID: 1, MusicBrainzID: uuid.MustParse("4b00640f-3be6-43f8-9b34-ff81bd89320a")})
 	require.NoError(t, err)
 	assert.Equal(t, "OurR", artist.Name)
-	artist, err = store.GetArtist(context.Background(), db.GetArtistOpts{Name: "Necry Talkie"})
+	artist, err = store.GetArtist(context.Background(), db.GetArtistOpts{UserID: 1, Name: "Necry Talkie"})
 	require.NoError(t, err)
-	track, err := store.GetTrack(context.Background(), db.GetTrackOpts{Title: "放課後の記憶", ReleaseID: album.ID, ArtistIDs: []int32{artist.ID}})
+	track, err := store.GetTrack(context.Background(), db.GetTrackOpts{UserID: 1, Title: "放課後の記憶", ReleaseID: album.ID, ArtistIDs: []int32{artist.ID}})
 	require.NoError(t, err)
 	t.Log(track)
-	listens, err := store.GetListensPaginated(context.Background(), db.GetItemsOpts{TrackID: int(track.ID), Timeframe: db.Timeframe{Period: db.PeriodAllTime}})
+	listens, err := store.GetListensPaginated(context.Background(), db.GetItemsOpts{UserID: 1, TrackID: int(track.ID), Timeframe: db.Timeframe{Period: db.PeriodAllTime}})
 	require.NoError(t, err)
 	require.Len(t, listens.Items, 1)
 	assert.WithinDuration(t, time.Unix(1749774900, 0), listens.Items[0].Time, 1*time.Second)
@@ -203,22 +203,22 @@ func TestImportListenBrainz(t *testing.T) {
 
 	engine.RunImporter(logger.Get(), store, mbzcMock)
 
-	album, err := store.GetAlbum(context.Background(), db.GetAlbumOpts{MusicBrainzID: uuid.MustParse("ce330d67-9c46-4a3b-9d62-08406370f234")})
+	album, err := store.GetAlbum(context.Background(), db.GetAlbumOpts{UserID: 1, MusicBrainzID: uuid.MustParse("ce330d67-9c46-4a3b-9d62-08406370f234")})
 	require.NoError(t, err)
 	assert.Equal(t, "酸欠少女", album.Title)
-	artist, err := store.GetArtist(context.Background(), db.GetArtistOpts{MusicBrainzID: uuid.MustParse("4b00640f-3be6-43f8-9b34-ff81bd89320a")})
+	artist, err := store.GetArtist(context.Background(), db.GetArtistOpts{UserID: 1, MusicBrainzID: uuid.MustParse("4b00640f-3be6-43f8-9b34-ff81bd89320a")})
 	require.NoError(t, err)
 	assert.Equal(t, "OurR", artist.Name)
-	artist, err = store.GetArtist(context.Background(), db.GetArtistOpts{MusicBrainzID: uuid.MustParse("09887aa7-226e-4ecc-9a0c-02d2ae5777e1")})
+	artist, err = store.GetArtist(context.Background(), db.GetArtistOpts{UserID: 1, MusicBrainzID: uuid.MustParse("09887aa7-226e-4ecc-9a0c-02d2ae5777e1")})
 	require.NoError(t, err)
 	assert.Equal(t, "Carly Rae Jepsen", artist.Name)
-	artist, err = store.GetArtist(context.Background(), db.GetArtistOpts{MusicBrainzID: uuid.MustParse("78e46ae5-9bfd-433b-be3f-19e993d67ecc")})
+	artist, err = store.GetArtist(context.Background(), db.GetArtistOpts{UserID: 1, MusicBrainzID: uuid.MustParse("78e46ae5-9bfd-433b-be3f-19e993d67ecc")})
 	require.NoError(t, err)
 	assert.Equal(t, "Rufus Wainwright", artist.Name)
-	track, err := store.GetTrack(context.Background(), db.GetTrackOpts{MusicBrainzID: uuid.MustParse("08e8f55b-f1a4-46b8-b2d1-fab4c592165c")})
+	track, err := store.GetTrack(context.Background(), db.GetTrackOpts{UserID: 1, MusicBrainzID: uuid.MustParse("08e8f55b-f1a4-46b8-b2d1-fab4c592165c")})
 	require.NoError(t, err)
 	assert.Equal(t, "Desert", track.Title)
-	listens, err := store.GetListensPaginated(context.Background(), db.GetItemsOpts{TrackID: int(track.ID), Timeframe: db.Timeframe{Period: db.PeriodAllTime}})
+	listens, err := store.GetListensPaginated(context.Background(), db.GetItemsOpts{UserID: 1, TrackID: int(track.ID), Timeframe: db.Timeframe{Period: db.PeriodAllTime}})
 	require.NoError(t, err)
 	assert.Len(t, listens.Items, 1)
 	assert.WithinDuration(t, time.Unix(1749780612, 0), listens.Items[0].Time, 1*time.Second)
@@ -241,22 +241,22 @@ func TestImportListenBrainz_MbzDisabled(t *testing.T) {
 
 	engine.RunImporter(logger.Get(), store, &mbz.MbzErrorCaller{})
 
-	album, err := store.GetAlbum(context.Background(), db.GetAlbumOpts{MusicBrainzID: uuid.MustParse("ce330d67-9c46-4a3b-9d62-08406370f234")})
+	album, err := store.GetAlbum(context.Background(), db.GetAlbumOpts{UserID: 1, MusicBrainzID: uuid.MustParse("ce330d67-9c46-4a3b-9d62-08406370f234")})
 	require.NoError(t, err)
 	assert.Equal(t, "酸欠少女", album.Title)
-	artist, err := store.GetArtist(context.Background(), db.GetArtistOpts{MusicBrainzID: uuid.MustParse("4b00640f-3be6-43f8-9b34-ff81bd89320a")})
+	artist, err := store.GetArtist(context.Background(), db.GetArtistOpts{UserID: 1, MusicBrainzID: uuid.MustParse("4b00640f-3be6-43f8-9b34-ff81bd89320a")})
 	require.NoError(t, err)
 	assert.Equal(t, "OurR", artist.Name)
-	artist, err = store.GetArtist(context.Background(), db.GetArtistOpts{MusicBrainzID: uuid.MustParse("09887aa7-226e-4ecc-9a0c-02d2ae5777e1")})
+	artist, err = store.GetArtist(context.Background(), db.GetArtistOpts{UserID: 1, MusicBrainzID: uuid.MustParse("09887aa7-226e-4ecc-9a0c-02d2ae5777e1")})
 	require.NoError(t, err)
 	assert.Equal(t, "Carly Rae Jepsen", artist.Name)
-	artist, err = store.GetArtist(context.Background(), db.GetArtistOpts{MusicBrainzID: uuid.MustParse("78e46ae5-9bfd-433b-be3f-19e993d67ecc")})
+	artist, err = store.GetArtist(context.Background(), db.GetArtistOpts{UserID: 1, MusicBrainzID: uuid.MustParse("78e46ae5-9bfd-433b-be3f-19e993d67ecc")})
 	require.NoError(t, err)
 	assert.Equal(t, "Rufus Wainwright", artist.Name)
-	track, err := store.GetTrack(context.Background(), db.GetTrackOpts{MusicBrainzID: uuid.MustParse("08e8f55b-f1a4-46b8-b2d1-fab4c592165c")})
+	track, err := store.GetTrack(context.Background(), db.GetTrackOpts{UserID: 1, MusicBrainzID: uuid.MustParse("08e8f55b-f1a4-46b8-b2d1-fab4c592165c")})
 	require.NoError(t, err)
 	assert.Equal(t, "Desert", track.Title)
-	listens, err := store.GetListensPaginated(context.Background(), db.GetItemsOpts{TrackID: int(track.ID), Timeframe: db.Timeframe{Period: db.PeriodAllTime}})
+	listens, err := store.GetListensPaginated(context.Background(), db.GetItemsOpts{UserID: 1, TrackID: int(track.ID), Timeframe: db.Timeframe{Period: db.PeriodAllTime}})
 	require.NoError(t, err)
 	assert.Len(t, listens.Items, 1)
 	assert.WithinDuration(t, time.Unix(1749780612, 0), listens.Items[0].Time, 1*time.Second)
@@ -279,13 +279,13 @@ func TestImportListenBrainz_MBIDMapping(t *testing.T) {
 
 	engine.RunImporter(logger.Get(), store, &mbz.MbzErrorCaller{})
 
-	album, err := store.GetAlbum(context.Background(), db.GetAlbumOpts{MusicBrainzID: uuid.MustParse("177ebc28-0115-3897-8eb3-ebf74ce23790")})
+	album, err := store.GetAlbum(context.Background(), db.GetAlbumOpts{UserID: 1, MusicBrainzID: uuid.MustParse("177ebc28-0115-3897-8eb3-ebf74ce23790")})
 	require.NoError(t, err)
 	assert.Equal(t, "Zombie", album.Title)
-	artist, err := store.GetArtist(context.Background(), db.GetArtistOpts{MusicBrainzID: uuid.MustParse("c98d40fd-f6cf-4b26-883e-eaa515ee2851")})
+	artist, err := store.GetArtist(context.Background(), db.GetArtistOpts{UserID: 1, MusicBrainzID: uuid.MustParse("c98d40fd-f6cf-4b26-883e-eaa515ee2851")})
 	require.NoError(t, err)
 	assert.Equal(t, "The Cranberries", artist.Name)
-	track, err := store.GetTrack(context.Background(), db.GetTrackOpts{MusicBrainzID: uuid.MustParse("3bbeb4e3-ab6d-460d-bfc5-de49e4251061")})
+	track, err := store.GetTrack(context.Background(), db.GetTrackOpts{UserID: 1, MusicBrainzID: uuid.MustParse("3bbeb4e3-ab6d-460d-bfc5-de49e4251061")})
 	require.NoError(t, err)
 	assert.Equal(t, "Zombie", track.Title)
 
@@ -314,42 +314,42 @@ func TestImportKoito(t *testing.T) {
 	engine.RunImporter(logger.Get(), store, &mbz.MbzErrorCaller{})
 
 	// ensure all artists are saved
-	_, err = store.GetArtist(ctx, db.GetArtistOpts{Name: "American Football"})
+	_, err = store.GetArtist(ctx, db.GetArtistOpts{UserID: 1, Name: "American Football"})
 	assert.NoError(t, err)
-	_, err = store.GetArtist(ctx, db.GetArtistOpts{Name: "Rachel Goswell"})
+	_, err = store.GetArtist(ctx, db.GetArtistOpts{UserID: 1, Name: "Rachel Goswell"})
 	assert.NoError(t, err)
-	_, err = store.GetArtist(ctx, db.GetArtistOpts{Name: "Elizabeth Powell"})
+	_, err = store.GetArtist(ctx, db.GetArtistOpts{UserID: 1, Name: "Elizabeth Powell"})
 	assert.NoError(t, err)
 
 	// ensure artist aliases are saved
-	artist, err := store.GetArtist(ctx, db.GetArtistOpts{MusicBrainzID: suzukiMBID})
+	artist, err := store.GetArtist(ctx, db.GetArtistOpts{UserID: 1, MusicBrainzID: suzukiMBID})
 	require.NoError(t, err)
 	assert.Equal(t, "鈴木雅之", artist.Name)
 	assert.Contains(t, artist.Aliases, "Masayuki Suzuki")
-	_, err = store.GetArtist(ctx, db.GetArtistOpts{Name: "すぅ"})
+	_, err = store.GetArtist(ctx, db.GetArtistOpts{UserID: 1, Name: "すぅ"})
 	require.NoError(t, err)
 
 	// ensure albums are saved
-	album, err := store.GetAlbum(ctx, db.GetAlbumOpts{MusicBrainzID: giriReleaseMBID})
+	album, err := store.GetAlbum(ctx, db.GetAlbumOpts{UserID: 1, MusicBrainzID: giriReleaseMBID})
 	require.NoError(t, err)
 	assert.Equal(t, "GIRI GIRI", album.Title)
 	// ensure album aliases are saved
-	artist, err = store.GetArtist(ctx, db.GetArtistOpts{Name: "NELKE"})
+	artist, err = store.GetArtist(ctx, db.GetArtistOpts{UserID: 1, Name: "NELKE"})
 	require.NoError(t, err)
-	album, err = store.GetAlbum(ctx, db.GetAlbumOpts{Title: "虹の色よ鮮やかであれ (NELKE ver.)", ArtistID: artist.ID})
+	album, err = store.GetAlbum(ctx, db.GetAlbumOpts{UserID: 1, Title: "虹の色よ鮮やかであれ (NELKE ver.)", ArtistID: artist.ID})
 	require.NoError(t, err)
 	aliases, err := store.GetAllAlbumAliases(ctx, album.ID)
 	require.NoError(t, err)
 	assert.Contains(t, utils.FlattenAliases(aliases), "Nijinoiroyo Azayakadeare (NELKE ver.)")
 	// ensure album associations are saved
-	album, err = store.GetAlbum(ctx, db.GetAlbumOpts{MusicBrainzID: lp3MBID})
+	album, err = store.GetAlbum(ctx, db.GetAlbumOpts{UserID: 1, MusicBrainzID: lp3MBID})
 	require.NoError(t, err)
 	assert.Contains(t, utils.FlattenSimpleArtistNames(album.Artists), "Elizabeth Powell")
 	assert.Contains(t, utils.FlattenSimpleArtistNames(album.Artists), "Rachel Goswell")
 	assert.Contains(t, utils.FlattenSimpleArtistNames(album.Artists), "American Football")
 
 	// ensure all tracks are saved
-	track, err := store.GetTrack(ctx, db.GetTrackOpts{MusicBrainzID: nijinoTrackMBID})
+	track, err := store.GetTrack(ctx, db.GetTrackOpts{UserID: 1, MusicBrainzID: nijinoTrackMBID})
 	require.NoError(t, err)
 	assert.Equal(t, "虹の色よ鮮やかであれ (NELKE ver.)", track.Title)
 	aliases, err = store.GetAllTrackAliases(ctx, track.ID)
@@ -358,20 +358,20 @@ func TestImportKoito(t *testing.T) {
 	// ensure track duration is saved
 	assert.EqualValues(t, 218, track.Duration)
 
-	artist, err = store.GetArtist(ctx, db.GetArtistOpts{MusicBrainzID: suzukiMBID})
+	artist, err = store.GetArtist(ctx, db.GetArtistOpts{UserID: 1, MusicBrainzID: suzukiMBID})
 	require.NoError(t, err)
-	album, err = store.GetAlbum(ctx, db.GetAlbumOpts{ArtistID: artist.ID, Title: "GIRI GIRI"})
+	album, err = store.GetAlbum(ctx, db.GetAlbumOpts{UserID: 1, ArtistID: artist.ID, Title: "GIRI GIRI"})
 	require.NoError(t, err)
-	_, err = store.GetTrack(ctx, db.GetTrackOpts{Title: "GIRI GIRI", ReleaseID: album.ID, ArtistIDs: []int32{artist.ID}})
+	_, err = store.GetTrack(ctx, db.GetTrackOpts{UserID: 1, Title: "GIRI GIRI", ReleaseID: album.ID, ArtistIDs: []int32{artist.ID}})
 	require.NoError(t, err)
 
-	count, err := store.CountTracks(ctx, db.Timeframe{Period: db.PeriodAllTime})
+	count, err := store.CountTracks(ctx, 1, db.Timeframe{Period: db.PeriodAllTime})
 	require.NoError(t, err)
 	assert.EqualValues(t, 4, count)
-	count, err = store.CountAlbums(ctx, db.Timeframe{Period: db.PeriodAllTime})
+	count, err = store.CountAlbums(ctx, 1, db.Timeframe{Period: db.PeriodAllTime})
 	require.NoError(t, err)
 	assert.EqualValues(t, 3, count)
-	count, err = store.CountArtists(ctx, db.Timeframe{Period: db.PeriodAllTime})
+	count, err = store.CountArtists(ctx, 1, db.Timeframe{Period: db.PeriodAllTime})
 	require.NoError(t, err)
 	assert.EqualValues(t, 6, count)
 
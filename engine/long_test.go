@@ -1,16 +1,23 @@
 package engine_test
 
 import (
+	"bufio"
 	"bytes"
 	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
 	"mime/multipart"
+	"net"
 	"net/http"
+	"net/http/cookiejar"
 	"net/url"
 	"os"
 	"path"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -21,6 +28,7 @@ import (
 	"github.com/gabehf/koito/internal/cfg"
 	"github.com/gabehf/koito/internal/db"
 	"github.com/gabehf/koito/internal/models"
+	"github.com/gabehf/koito/internal/totp"
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -62,13 +70,15 @@ func makeAuthRequest(t *testing.T, session, method, endpoint string, body io.Rea
 // Expects a valid session
 func getApiKey(t *testing.T, session string) {
 	apikeyOnce.Do(func() {
-		resp, err := makeAuthRequest(t, session, "GET", "/apis/web/v1/user/apikeys", nil)
+		// keys are only readable when they are created
+		resp, err := makeAuthRequest(t, session, "POST", "/apis/web/v1/user/apikeys", strings.NewReader("label=Tests"))
 		require.NoError(t, err)
-		var keys []models.ApiKey
-		err = json.NewDecoder(resp.Body).Decode(&keys)
+		require.Equal(t, http.StatusCreated, resp.StatusCode)
+		var key models.ApiKey
+		err = json.NewDecoder(resp.Body).Decode(&key)
 		require.NoError(t, err)
-		require.GreaterOrEqual(t, len(keys), 1)
-		apikey = keys[0].Key
+		require.NotEmpty(t, key.Key)
+		apikey = key.Key
 	})
 }
 
@@ -82,7 +92,9 @@ func truncateTestData(t *testing.T) {
 		releases,
 		artist_releases,
 		release_aliases,
-		listens
+		listens,
+		merge_snapshots,
+		release_groups
 		RESTART IDENTITY CASCADE`)
 	require.NoError(t, err)
 }
@@ -292,6 +304,41 @@ func TestMerge(t *testing.T) {
 	truncateTestData(t)
 }
 
+func TestUnmerge(t *testing.T) {
+
+	t.Run("Submit Listens", doSubmitListens)
+
+	resp, err := makeAuthRequest(t, session, "POST", "/apis/web/v1/merge/tracks?from_id=1&to_id=2", nil)
+	require.NoError(t, err)
+	require.Equal(t, 204, resp.StatusCode)
+
+	resp, err = makeAuthRequest(t, session, "POST", "/apis/web/v1/unmerge/tracks?from_id=1", nil)
+	require.NoError(t, err)
+	require.Equal(t, 204, resp.StatusCode)
+
+	resp, err = http.DefaultClient.Get(host() + "/apis/web/v1/track?id=1")
+	require.NoError(t, err)
+	require.Equal(t, 200, resp.StatusCode)
+	var track models.Track
+	err = json.NewDecoder(resp.Body).Decode(&track)
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, track.ListenCount)
+
+	resp, err = http.DefaultClient.Get(host() + "/apis/web/v1/track?id=2")
+	require.NoError(t, err)
+	track = models.Track{}
+	err = json.NewDecoder(resp.Body).Decode(&track)
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, track.ListenCount)
+
+	// already undone
+	resp, err = makeAuthRequest(t, session, "POST", "/apis/web/v1/unmerge/tracks?from_id=1", nil)
+	require.NoError(t, err)
+	assert.Equal(t, 404, resp.StatusCode)
+
+	truncateTestData(t)
+}
+
 func TestValidateToken(t *testing.T) {
 	login(t)
 	getApiKey(t, session)
@@ -583,6 +630,7 @@ func TestAuth(t *testing.T) {
 	require.NoError(t, err)
 	require.Equal(t, 201, resp.StatusCode)
 	var response struct {
+		ID  int32  `json:"id"`
 		Key string `json:"key"`
 	}
 	require.NoError(t, json.NewDecoder(resp.Body).Decode(&response))
@@ -605,7 +653,7 @@ func TestAuth(t *testing.T) {
 
 	// changes api key label
 	login(t) // i dont care about using the new session anymore
-	resp, err = makeAuthRequest(t, s, "PATCH", "/apis/web/v1/user/apikeys?id=2&label=well+tested", nil)
+	resp, err = makeAuthRequest(t, s, "PATCH", fmt.Sprintf("/apis/web/v1/user/apikeys?id=%d&label=well+tested", response.ID), nil)
 	require.NoError(t, err)
 	assert.Equal(t, 200, resp.StatusCode)
 	resp, err = makeAuthRequest(t, s, "GET", "/apis/web/v1/user/apikeys", nil)
@@ -613,9 +661,16 @@ func TestAuth(t *testing.T) {
 	var keys []models.ApiKey
 	err = json.NewDecoder(resp.Body).Decode(&keys)
 	require.NoError(t, err)
-	require.GreaterOrEqual(t, len(keys), 2)
-	require.NotNil(t, keys[1].Label)
-	assert.Equal(t, "well tested", keys[1].Label)
+	var updated *models.ApiKey
+	for i := range keys {
+		if keys[i].ID == response.ID {
+			updated = &keys[i]
+		}
+	}
+	require.NotNil(t, updated)
+	assert.Equal(t, "well tested", updated.Label)
+	assert.Equal(t, response.Key[:8], updated.Prefix)
+	assert.Empty(t, updated.Key, "keys should only be shown when they are created")
 
 	// logs out
 	req, err = http.NewRequest("POST", host()+"/apis/web/v1/logout", nil)
@@ -736,7 +791,7 @@ func TestArtistReplaceImage(t *testing.T) {
 	newid, err := uuid.Parse(response.Image)
 	require.NoError(t, err)
 
-	a, err := store.GetArtist(context.Background(), db.GetArtistOpts{ID: 1})
+	a, err := store.GetArtist(context.Background(), db.GetArtistOpts{UserID: 1, ID: 1})
 	require.NoError(t, err)
 	assert.NotNil(t, a.Image)
 	assert.Equal(t, newid, *a.Image)
@@ -774,7 +829,7 @@ func TestAlbumReplaceImage(t *testing.T) {
 	newid, err := uuid.Parse(response.Image)
 	require.NoError(t, err)
 
-	a, err := store.GetAlbum(context.Background(), db.GetAlbumOpts{ID: 1})
+	a, err := store.GetAlbum(context.Background(), db.GetAlbumOpts{UserID: 1, ID: 1})
 	require.NoError(t, err)
 	assert.NotNil(t, a.Image)
 	assert.Equal(t, newid, *a.Image)
@@ -937,6 +992,191 @@ func TestSetPrimaryArtist(t *testing.T) {
 	assert.EqualValues(t, 1, count, "expected only one primary artist for track")
 }
 
+func TestSetArtistRole(t *testing.T) {
+
+	t.Run("Submit Listens", doSubmitListens)
+
+	ctx := context.Background()
+
+	formdata := url.Values{}
+	formdata.Set("artist_id", "1")
+	formdata.Set("track_id", "1")
+	formdata.Set("role", "featured")
+	body := formdata.Encode()
+	resp, err := makeAuthRequest(t, session, "POST", "/apis/web/v1/artists/role", strings.NewReader(body))
+	require.NoError(t, err)
+	require.Equal(t, 204, resp.StatusCode)
+
+	exists, err := store.RowExists(ctx, `
+    SELECT EXISTS (
+      SELECT 1 FROM artist_tracks
+      WHERE track_id = $1 AND artist_id = $2 AND role = $3
+    )`, 1, 1, "featured")
+	require.NoError(t, err)
+	assert.True(t, exists, "expected artist to be credited as featured")
+
+	// featured artist is left out of top artists when asked to
+	resp, err = http.DefaultClient.Get(host() + "/apis/web/v1/top-artists?period=all_time&exclude_featured=true")
+	require.NoError(t, err)
+	require.Equal(t, 200, resp.StatusCode)
+	var artists db.PaginatedResponse[db.RankedItem[*models.Artist]]
+	err = json.NewDecoder(resp.Body).Decode(&artists)
+	require.NoError(t, err)
+	for _, a := range artists.Items {
+		assert.NotEqualValues(t, 1, a.Item.ID, "expected featured artist to be left out")
+	}
+
+	// invalid role
+	formdata.Set("role", "producer")
+	body = formdata.Encode()
+	resp, err = makeAuthRequest(t, session, "POST", "/apis/web/v1/artists/role", strings.NewReader(body))
+	require.NoError(t, err)
+	assert.Equal(t, 400, resp.StatusCode)
+
+	// artist not credited on track
+	formdata.Set("artist_id", "999")
+	formdata.Set("role", "main")
+	body = formdata.Encode()
+	resp, err = makeAuthRequest(t, session, "POST", "/apis/web/v1/artists/role", strings.NewReader(body))
+	require.NoError(t, err)
+	assert.Equal(t, 404, resp.StatusCode)
+}
+
+func TestSubmitListenAlbumArtistAndTags(t *testing.T) {
+
+	t.Run("Submit Listens", doSubmitListens)
+
+	ctx := context.Background()
+
+	listenBody := `{
+		"listen_type": "single",
+		"payload": [
+			{
+				"listened_at": 1749475719,
+				"track_metadata": {
+					"additional_info": {
+						"albumartist": "Various Artists",
+						"tags": ["City Pop", "j-pop"],
+						"submission_client": "navidrome"
+					},
+					"artist_name": "Mariya Takeuchi",
+					"release_name": "Pacific Breeze",
+					"track_name": "Plastic Love"
+				}
+			}
+		]
+	}`
+
+	req, err := http.NewRequest("POST", host()+"/apis/listenbrainz/1/submit-listens", strings.NewReader(listenBody))
+	require.NoError(t, err)
+	req.Header.Add("Authorization", fmt.Sprintf("Token %s", apikey))
+	req.Header.Add("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	require.Equal(t, 200, resp.StatusCode)
+
+	exists, err := store.RowExists(ctx, `
+    SELECT EXISTS (
+      SELECT 1 FROM releases_with_title
+      WHERE title = $1 AND various_artists = $2
+    )`, "Pacific Breeze", true)
+	require.NoError(t, err)
+	assert.True(t, exists, "expected release to be marked as various artists")
+
+	count, err := store.Count(ctx, `
+	SELECT COUNT(*) FROM release_genres rg
+	JOIN genres g ON g.id = rg.genre_id
+	JOIN releases_with_title r ON r.id = rg.release_id
+	WHERE r.title = $1 AND g.name IN ('city pop', 'j-pop')
+	`, "Pacific Breeze")
+	require.NoError(t, err)
+	assert.Equal(t, 2, count, "expected tags to be saved as genres")
+}
+
+func TestReleaseGroups(t *testing.T) {
+
+	t.Run("Submit Listens", doSubmitListens)
+
+	ctx := context.Background()
+
+	getTopAlbums := func() db.PaginatedResponse[db.RankedItem[*models.Album]] {
+		resp, err := http.DefaultClient.Get(host() + "/apis/web/v1/top-albums?period=all_time&by_release_group=true")
+		require.NoError(t, err)
+		require.Equal(t, 200, resp.StatusCode)
+		var albums db.PaginatedResponse[db.RankedItem[*models.Album]]
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&albums))
+		return albums
+	}
+	before := getTopAlbums()
+
+	formdata := url.Values{}
+	formdata.Set("album_id", "1")
+	formdata.Set("with_album_id", "2")
+	body := formdata.Encode()
+	resp, err := makeAuthRequest(t, session, "POST", "/apis/web/v1/album/release-group", strings.NewReader(body))
+	require.NoError(t, err)
+	require.Equal(t, 204, resp.StatusCode)
+
+	exists, err := store.RowExists(ctx, `
+    SELECT EXISTS (
+      SELECT 1 FROM releases a
+      JOIN releases b ON a.release_group_id = b.release_group_id
+      WHERE a.id = $1 AND b.id = $2
+    )`, 1, 2)
+	require.NoError(t, err)
+	assert.True(t, exists, "expected albums to share a release group")
+
+	// editions are counted as one album
+	after := getTopAlbums()
+	assert.Equal(t, before.TotalCount-1, after.TotalCount)
+
+	resp, err = http.DefaultClient.Get(host() + "/apis/web/v1/release-group?album_id=1")
+	require.NoError(t, err)
+	require.Equal(t, 200, resp.StatusCode)
+	var rg models.ReleaseGroup
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&rg))
+	assert.Len(t, rg.Editions, 2)
+
+	exists, err = store.RowExists(ctx, `
+    SELECT EXISTS (
+      SELECT 1 FROM audit_log
+      WHERE action = $1 AND target_type = $2 AND $3 = ANY(target_ids)
+    )`, "set_release_group", "album", 1)
+	require.NoError(t, err)
+	assert.True(t, exists, "expected grouping to be audited")
+
+	// ungroup
+	resp, err = makeAuthRequest(t, session, "DELETE", "/apis/web/v1/album/release-group?album_id=1", nil)
+	require.NoError(t, err)
+	require.Equal(t, 204, resp.StatusCode)
+
+	exists, err = store.RowExists(ctx, `
+    SELECT EXISTS (
+      SELECT 1 FROM releases
+      WHERE id = $1 AND release_group_id IS NULL
+    )`, 1)
+	require.NoError(t, err)
+	assert.True(t, exists, "expected album to be removed from release group")
+	assert.Equal(t, before.TotalCount, getTopAlbums().TotalCount)
+
+	resp, err = http.DefaultClient.Get(host() + "/apis/web/v1/release-group?album_id=1")
+	require.NoError(t, err)
+	assert.Equal(t, 404, resp.StatusCode)
+
+	// invalid requests
+	formdata.Set("with_album_id", "1")
+	body = formdata.Encode()
+	resp, err = makeAuthRequest(t, session, "POST", "/apis/web/v1/album/release-group", strings.NewReader(body))
+	require.NoError(t, err)
+	assert.Equal(t, 400, resp.StatusCode)
+
+	formdata.Set("with_album_id", "999")
+	body = formdata.Encode()
+	resp, err = makeAuthRequest(t, session, "POST", "/apis/web/v1/album/release-group", strings.NewReader(body))
+	require.NoError(t, err)
+	assert.Equal(t, 404, resp.StatusCode)
+}
+
 func TestManualListen(t *testing.T) {
 
 	t.Run("Submit Listens", doSubmitListens)
@@ -1018,4 +1258,1388 @@ func TestNowPlaying(t *testing.T) {
 	require.NoError(t, json.NewDecoder(resp.Body).Decode(&result))
 	require.True(t, result.CurrentlyPlaying)
 	require.Equal(t, "花の塔", result.Track.Title)
+	assert.Equal(t, "navidrome", result.Client)
+	require.NotNil(t, result.StartedAt)
+	require.NotNil(t, result.ExpiresAt)
+	assert.Equal(t, 275*time.Second, result.ExpiresAt.Sub(*result.StartedAt).Truncate(time.Second))
+
+	resp, err = http.DefaultClient.Get(host() + "/apis/web/v1/now-playing?username=nobody")
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	// the stream starts with the current status
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, err = http.NewRequestWithContext(ctx, "GET", host()+"/apis/web/v1/now-playing/stream", nil)
+	require.NoError(t, err)
+	resp, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+	scanner := bufio.NewScanner(resp.Body)
+	require.True(t, scanner.Scan())
+	assert.Equal(t, "event: now-playing", scanner.Text())
+	require.True(t, scanner.Scan())
+	data, ok := strings.CutPrefix(scanner.Text(), "data: ")
+	require.True(t, ok)
+	result = handlers.NowPlayingResponse{}
+	require.NoError(t, json.Unmarshal([]byte(data), &result))
+	assert.True(t, result.CurrentlyPlaying)
+	assert.Equal(t, "花の塔", result.Track.Title)
+}
+
+func signAudioscrobbler(params url.Values, secret string) {
+	keys := make([]string, 0, len(params))
+	for k := range params {
+		if k == "format" || k == "callback" {
+			continue
+		}
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	for _, k := range keys {
+		b.WriteString(k + params.Get(k))
+	}
+	b.WriteString(secret)
+	sum := md5.Sum([]byte(b.String()))
+	params.Set("api_sig", hex.EncodeToString(sum[:]))
+}
+
+func TestAudioscrobbler(t *testing.T) {
+
+	login(t)
+	getApiKey(t, session)
+	truncateTestData(t)
+
+	ctx := context.Background()
+	endpoint := host() + "/apis/audioscrobbler/2.0/"
+
+	// bad signature
+	formdata := url.Values{}
+	formdata.Set("method", "auth.getMobileSession")
+	formdata.Set("username", "test")
+	formdata.Set("password", "testuser123")
+	formdata.Set("api_key", apikey)
+	formdata.Set("api_sig", "notavalidsignature")
+	formdata.Set("format", "json")
+	resp, err := http.DefaultClient.PostForm(endpoint, formdata)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	var errResp struct {
+		Error   int    `json:"error"`
+		Message string `json:"message"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&errResp))
+	assert.Equal(t, 13, errResp.Error)
+
+	// bad password
+	formdata.Del("api_sig")
+	formdata.Set("password", "wrongpassword")
+	signAudioscrobbler(formdata, apikey)
+	resp, err = http.DefaultClient.PostForm(endpoint, formdata)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+	// get session
+	formdata.Del("api_sig")
+	formdata.Set("password", "testuser123")
+	signAudioscrobbler(formdata, apikey)
+	resp, err = http.DefaultClient.PostForm(endpoint, formdata)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var sessResp struct {
+		Session struct {
+			Name string `json:"name"`
+			Key  string `json:"key"`
+		} `json:"session"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&sessResp))
+	assert.Equal(t, "test", sessResp.Session.Name)
+	sk := sessResp.Session.Key
+	require.NotEmpty(t, sk)
+
+	// batched scrobble, xml response
+	formdata = url.Values{}
+	formdata.Set("method", "track.scrobble")
+	formdata.Set("api_key", apikey)
+	formdata.Set("sk", sk)
+	formdata.Set("artist[0]", "さユり")
+	formdata.Set("track[0]", "花の塔")
+	formdata.Set("album[0]", "酸欠少女")
+	formdata.Set("timestamp[0]", strconv.FormatInt(time.Now().Add(-10*time.Minute).Unix(), 10))
+	formdata.Set("artist[1]", "キタニタツヤ")
+	formdata.Set("track[1]", "Where Our Blue Is")
+	formdata.Set("album[1]", "Where Our Blue Is")
+	formdata.Set("albumArtist[1]", "Various Artists")
+	formdata.Set("timestamp[1]", strconv.FormatInt(time.Now().Add(-5*time.Minute).Unix(), 10))
+	formdata.Set("track[2]", "Missing Artist")
+	formdata.Set("timestamp[2]", strconv.FormatInt(time.Now().Unix(), 10))
+	signAudioscrobbler(formdata, apikey)
+	resp, err = http.DefaultClient.PostForm(endpoint, formdata)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	respBytes, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Contains(t, string(respBytes), `<lfm status="ok">`)
+	assert.Contains(t, string(respBytes), `<scrobbles accepted="2" ignored="1">`)
+
+	count, _ := store.Count(ctx, `SELECT COUNT(*) FROM listens`)
+	assert.Equal(t, 2, count)
+
+	exists, err := store.RowExists(ctx, `
+    SELECT EXISTS (
+      SELECT 1 FROM releases_with_title
+      WHERE title = $1 AND various_artists = $2
+    )`, "Where Our Blue Is", true)
+	require.NoError(t, err)
+	assert.True(t, exists, "expected album artist to be used")
+
+	// invalid session key
+	formdata.Del("api_sig")
+	formdata.Set("sk", "thisisnotavalidsessionkey")
+	signAudioscrobbler(formdata, apikey)
+	resp, err = http.DefaultClient.PostForm(endpoint, formdata)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+	// now playing
+	formdata = url.Values{}
+	formdata.Set("method", "track.updateNowPlaying")
+	formdata.Set("api_key", apikey)
+	formdata.Set("sk", sk)
+	formdata.Set("artist", "さユり")
+	formdata.Set("track", "花の塔")
+	formdata.Set("format", "json")
+	signAudioscrobbler(formdata, apikey)
+	resp, err = http.DefaultClient.PostForm(endpoint, formdata)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	resp, err = http.DefaultClient.Get(host() + "/apis/web/v1/now-playing")
+	require.NoError(t, err)
+	var result handlers.NowPlayingResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&result))
+	assert.True(t, result.CurrentlyPlaying)
+	assert.Equal(t, "花の塔", result.Track.Title)
+
+	truncateTestData(t)
+}
+
+func TestAudioscrobblerLegacy(t *testing.T) {
+
+	login(t)
+	getApiKey(t, session)
+	truncateTestData(t)
+
+	ctx := context.Background()
+
+	// only keys created for legacy clients can be used with the handshake
+	resp, err := makeAuthRequest(t, session, "POST", "/apis/web/v1/user/apikeys", strings.NewReader("label=Legacy&scope=submit&legacy=true"))
+	require.NoError(t, err)
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+	var legacyKey models.ApiKey
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&legacyKey))
+	assert.True(t, legacyKey.Legacy)
+
+	ts := strconv.FormatInt(time.Now().Unix(), 10)
+	handshake := func(key string) []string {
+		inner := md5.Sum([]byte(key))
+		outer := md5.Sum([]byte(hex.EncodeToString(inner[:]) + ts))
+		resp, err := http.DefaultClient.Get(host() + "/apis/audioscrobbler/1.2/?hs=true&p=1.2.1&c=tst&v=1.0&u=test&t=" + ts + "&a=" + hex.EncodeToString(outer[:]))
+		require.NoError(t, err)
+		respBytes, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return strings.Split(strings.TrimSpace(string(respBytes)), "\n")
+	}
+
+	// bad auth
+	resp, err = http.DefaultClient.Get(host() + "/apis/audioscrobbler/1.2/?hs=true&p=1.2.1&c=tst&v=1.0&u=test&t=" + ts + "&a=notavalidtoken")
+	require.NoError(t, err)
+	respBytes, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "BADAUTH\n", string(respBytes))
+	assert.Equal(t, []string{"BADAUTH"}, handshake(apikey))
+
+	// handshake
+	lines := handshake(legacyKey.Key)
+	require.Len(t, lines, 4)
+	require.Equal(t, "OK", lines[0])
+	sid := lines[1]
+	assert.Len(t, sid, 32)
+	assert.NotEqual(t, sid, handshake(legacyKey.Key)[1], "every handshake should start a new session")
+	assert.True(t, strings.HasSuffix(lines[2], "/apis/audioscrobbler/1.2/nowplaying"))
+	assert.True(t, strings.HasSuffix(lines[3], "/apis/audioscrobbler/1.2/submissions"))
+
+	// submissions
+	formdata := url.Values{}
+	formdata.Set("s", sid)
+	formdata.Set("a[0]", "さユり")
+	formdata.Set("t[0]", "花の塔")
+	formdata.Set("b[0]", "酸欠少女")
+	formdata.Set("i[0]", strconv.FormatInt(time.Now().Add(-10*time.Minute).Unix(), 10))
+	formdata.Set("o[0]", "P")
+	formdata.Set("l[0]", "275")
+	formdata.Set("a[1]", "キタニタツヤ")
+	formdata.Set("t[1]", "Where Our Blue Is")
+	formdata.Set("i[1]", strconv.FormatInt(time.Now().Add(-5*time.Minute).Unix(), 10))
+	formdata.Set("o[1]", "P")
+	resp, err = http.DefaultClient.PostForm(lines[3], formdata)
+	require.NoError(t, err)
+	respBytes, err = io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "OK\n", string(respBytes))
+
+	count, _ := store.Count(ctx, `SELECT COUNT(*) FROM listens`)
+	assert.Equal(t, 2, count)
+
+	// bad session, and the stored hash of the key is not a session either
+	keyHash := sha256.Sum256([]byte(legacyKey.Key))
+	for _, badSession := range []string{"notavalidsession", hex.EncodeToString(keyHash[:])} {
+		formdata.Set("s", badSession)
+		resp, err = http.DefaultClient.PostForm(lines[3], formdata)
+		require.NoError(t, err)
+		respBytes, err = io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		assert.Equal(t, "BADSESSION\n", string(respBytes))
+	}
+
+	// now playing
+	formdata = url.Values{}
+	formdata.Set("s", sid)
+	formdata.Set("a", "キタニタツヤ")
+	formdata.Set("t", "Where Our Blue Is")
+	resp, err = http.DefaultClient.PostForm(lines[2], formdata)
+	require.NoError(t, err)
+	respBytes, err = io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "OK\n", string(respBytes))
+
+	truncateTestData(t)
+}
+
+func TestLbzReadApi(t *testing.T) {
+
+	t.Run("Submit Listens", doSubmitListens)
+
+	resp, err := http.DefaultClient.Get(host() + "/apis/listenbrainz/1/user/test/listens")
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var listens handlers.LbzListensResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&listens))
+	assert.Equal(t, "test", listens.Payload.UserID)
+	require.Equal(t, 3, listens.Payload.Count)
+	assert.Equal(t, "Where Our Blue Is", listens.Payload.Listens[0].TrackMeta.TrackName)
+	assert.Equal(t, "キタニタツヤ", listens.Payload.Listens[0].TrackMeta.ArtistName)
+	assert.Equal(t, "Where Our Blue Is", listens.Payload.Listens[0].TrackMeta.ReleaseName)
+
+	// count and max_ts
+	maxTs := listens.Payload.Listens[0].ListenedAt
+	resp, err = http.DefaultClient.Get(host() + fmt.Sprintf("/apis/listenbrainz/1/user/test/listens?count=1&max_ts=%d", maxTs))
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&listens))
+	require.Equal(t, 1, listens.Payload.Count)
+	assert.Equal(t, "こんがらがった！", listens.Payload.Listens[0].TrackMeta.TrackName)
+
+	// min_ts
+	resp, err = http.DefaultClient.Get(host() + fmt.Sprintf("/apis/listenbrainz/1/user/test/listens?min_ts=%d", maxTs-1))
+	require.NoError(t, err)
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&listens))
+	assert.Equal(t, 1, listens.Payload.Count)
+
+	// paging forwards with min_ts returns the listens right after it, newest first
+	resp, err = http.DefaultClient.Get(host() + "/apis/listenbrainz/1/user/test/listens")
+	require.NoError(t, err)
+	var all handlers.LbzListensResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&all))
+	require.Len(t, all.Payload.Listens, 3)
+	resp, err = http.DefaultClient.Get(host() + fmt.Sprintf("/apis/listenbrainz/1/user/test/listens?count=1&min_ts=%d", all.Payload.Listens[2].ListenedAt))
+	require.NoError(t, err)
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&listens))
+	require.Equal(t, 1, listens.Payload.Count)
+	assert.Equal(t, all.Payload.Listens[1].ListenedAt, listens.Payload.Listens[0].ListenedAt)
+
+	// listen count
+	resp, err = http.DefaultClient.Get(host() + "/apis/listenbrainz/1/user/test/listen-count")
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var count handlers.LbzListenCountResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&count))
+	assert.EqualValues(t, 3, count.Payload.Count)
+
+	// playing now
+	resp, err = http.DefaultClient.Get(host() + "/apis/listenbrainz/1/user/test/playing-now")
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&listens))
+	assert.True(t, listens.Payload.PlayingNow)
+
+	// unknown user
+	resp, err = http.DefaultClient.Get(host() + "/apis/listenbrainz/1/user/nobody/listens")
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	truncateTestData(t)
+}
+
+func TestLbzStatsApi(t *testing.T) {
+
+	t.Run("Submit Listens", doSubmitListens)
+
+	var stats handlers.LbzStatsResponse
+
+	resp, err := http.DefaultClient.Get(host() + "/apis/listenbrainz/1/stats/user/test/artists?range=week")
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&stats))
+	assert.Equal(t, "week", stats.Payload.Range)
+	assert.EqualValues(t, 3, stats.Payload.TotalArtistCount)
+	require.Len(t, stats.Payload.Artists, 3)
+	assert.EqualValues(t, 1, stats.Payload.Artists[0].ListenCount)
+
+	resp, err = http.DefaultClient.Get(host() + "/apis/listenbrainz/1/stats/user/test/releases?count=1&offset=1")
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&stats))
+	assert.EqualValues(t, 3, stats.Payload.TotalReleaseCount)
+	assert.Len(t, stats.Payload.Releases, 1)
+	assert.Equal(t, 1, stats.Payload.Offset)
+
+	resp, err = http.DefaultClient.Get(host() + "/apis/listenbrainz/1/stats/user/test/recordings?range=all_time")
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&stats))
+	assert.EqualValues(t, 3, stats.Payload.TotalRecordingCount)
+	require.Len(t, stats.Payload.Recordings, 3)
+
+	resp, err = http.DefaultClient.Get(host() + "/apis/listenbrainz/1/stats/user/test/listening-activity?range=week")
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&stats))
+	require.Len(t, stats.Payload.ListeningActivity, 7)
+	var total int64
+	for _, a := range stats.Payload.ListeningActivity {
+		total += a.ListenCount
+	}
+	assert.EqualValues(t, 3, total)
+
+	resp, err = http.DefaultClient.Get(host() + "/apis/listenbrainz/1/stats/user/test/artists?range=fortnight")
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	truncateTestData(t)
+}
+
+func TestMalojaScrobble(t *testing.T) {
+
+	login(t)
+	getApiKey(t, session)
+	truncateTestData(t)
+
+	ctx := context.Background()
+
+	// test endpoint
+	resp, err := http.DefaultClient.Get(host() + "/apis/mlj_1/test?key=" + url.QueryEscape(apikey))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	resp, err = http.DefaultClient.Get(host() + "/apis/mlj_1/test?key=notavalidkey")
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+	// json
+	body := fmt.Sprintf(`{
+		"artists": ["さユり"],
+		"title": "花の塔",
+		"album": "酸欠少女",
+		"time": %d,
+		"key": "%s"
+	}`, time.Now().Add(-10*time.Minute).Unix(), apikey)
+	resp, err = http.DefaultClient.Post(host()+"/apis/mlj_1/newscrobble", "application/json", strings.NewReader(body))
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var result handlers.MalojaResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&result))
+	assert.Equal(t, "success", result.Status)
+
+	// form
+	formdata := url.Values{}
+	formdata.Set("artist", "キタニタツヤ")
+	formdata.Set("title", "Where Our Blue Is")
+	formdata.Set("key", apikey)
+	resp, err = http.DefaultClient.PostForm(host()+"/apis/mlj_1/newscrobble", formdata)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	count, _ := store.Count(ctx, `SELECT COUNT(*) FROM listens`)
+	assert.Equal(t, 2, count)
+
+	// missing title
+	formdata.Del("title")
+	resp, err = http.DefaultClient.PostForm(host()+"/apis/mlj_1/newscrobble", formdata)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	// bad key
+	formdata.Set("title", "Where Our Blue Is")
+	formdata.Set("key", "notavalidkey")
+	resp, err = http.DefaultClient.PostForm(host()+"/apis/mlj_1/newscrobble", formdata)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+	truncateTestData(t)
+}
+
+func TestRelayAdminApi(t *testing.T) {
+
+	login(t)
+	truncateTestData(t)
+
+	ctx := context.Background()
+	err := store.Exec(ctx, `TRUNCATE relay_outbox RESTART IDENTITY CASCADE`)
+	require.NoError(t, err)
+	err = store.Exec(ctx,
+		`INSERT INTO relay_outbox (user_id, payload, status, attempts, last_error)
+		VALUES (1, '{"listen_type":"single"}', 'pending', 0, NULL),
+		       (1, '{"listen_type":"single"}', 'dead', 11, '400 Bad Request'),
+		       (1, '{"listen_type":"single"}', 'dead', 11, '400 Bad Request')`)
+	require.NoError(t, err)
+
+	resp, err := makeAuthRequest(t, session, "GET", "/apis/web/v1/admin/relay?status=dead", nil)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var entries db.PaginatedResponse[models.RelayEntry]
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&entries))
+	assert.EqualValues(t, 2, entries.TotalCount)
+	require.Len(t, entries.Items, 2)
+	assert.Equal(t, models.RelayStatusDead, entries.Items[0].Status)
+	assert.Equal(t, "400 Bad Request", entries.Items[0].LastError)
+
+	resp, err = makeAuthRequest(t, session, "GET", "/apis/web/v1/admin/relay?status=bogus", nil)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	// retry a single entry
+	resp, err = makeAuthRequest(t, session, "POST", "/apis/web/v1/admin/relay/retry?id=2", nil)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	count, _ := store.Count(ctx, `SELECT COUNT(*) FROM relay_outbox WHERE status = 'pending'`)
+	assert.Equal(t, 2, count)
+
+	resp, err = makeAuthRequest(t, session, "POST", "/apis/web/v1/admin/relay/retry?id=9999", nil)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	// purge dead entries
+	resp, err = makeAuthRequest(t, session, "DELETE", "/apis/web/v1/admin/relay?status=dead", nil)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	count, _ = store.Count(ctx, `SELECT COUNT(*) FROM relay_outbox`)
+	assert.Equal(t, 2, count)
+
+	resp, err = makeAuthRequest(t, session, "DELETE", "/apis/web/v1/admin/relay?id=1", nil)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+	count, _ = store.Count(ctx, `SELECT COUNT(*) FROM relay_outbox`)
+	assert.Equal(t, 1, count)
+
+	// unauthenticated
+	resp, err = http.DefaultClient.Get(host() + "/apis/web/v1/admin/relay")
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	err = store.Exec(ctx, `TRUNCATE relay_outbox RESTART IDENTITY CASCADE`)
+	require.NoError(t, err)
+}
+
+func TestRelayTargetsApi(t *testing.T) {
+
+	login(t)
+
+	ctx := context.Background()
+	err := store.Exec(ctx, `TRUNCATE relay_targets, relay_outbox RESTART IDENTITY CASCADE`)
+	require.NoError(t, err)
+
+	// invalid kind
+	formdata := url.Values{}
+	formdata.Set("kind", "spotify")
+	resp, err := makeAuthRequest(t, session, "POST", "/apis/web/v1/user/relay-targets", strings.NewReader(formdata.Encode()))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	// targets on the local network are not allowed
+	formdata = url.Values{}
+	formdata.Set("kind", "maloja")
+	formdata.Set("url", "http://127.0.0.1:1/apis/mlj_1")
+	formdata.Set("token", "malojakey")
+	resp, err = makeAuthRequest(t, session, "POST", "/apis/web/v1/user/relay-targets", strings.NewReader(formdata.Encode()))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	// maloja target that does not relay listens from navidrome
+	formdata = url.Values{}
+	formdata.Set("kind", "maloja")
+	formdata.Set("label", "Maloja")
+	formdata.Set("url", "http://maloja.invalid/apis/mlj_1")
+	formdata.Set("token", "malojakey")
+	formdata.Set("exclude_clients", "navidrome")
+	resp, err = makeAuthRequest(t, session, "POST", "/apis/web/v1/user/relay-targets", strings.NewReader(formdata.Encode()))
+	require.NoError(t, err)
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+	var maloja models.RelayTarget
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&maloja))
+	assert.Equal(t, models.RelayTargetMaloja, maloja.Kind)
+	assert.Equal(t, []string{"navidrome"}, maloja.ExcludeClients)
+
+	// listenbrainz target that only relays listens from navidrome
+	formdata = url.Values{}
+	formdata.Set("kind", "listenbrainz")
+	formdata.Set("url", "http://listenbrainz.invalid/1")
+	formdata.Set("token", "lbztoken")
+	formdata.Set("include_clients", "Navidrome")
+	resp, err = makeAuthRequest(t, session, "POST", "/apis/web/v1/user/relay-targets", strings.NewReader(formdata.Encode()))
+	require.NoError(t, err)
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+	var lbz models.RelayTarget
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&lbz))
+
+	resp, err = makeAuthRequest(t, session, "GET", "/apis/web/v1/user/relay-targets", nil)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var targets []models.RelayTarget
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&targets))
+	assert.Len(t, targets, 2)
+
+	doSubmitListens(t)
+
+	count, _ := store.Count(ctx, `SELECT COUNT(*) FROM relay_outbox WHERE target_id = $1`, lbz.ID)
+	assert.Equal(t, 3, count)
+	count, _ = store.Count(ctx, `SELECT COUNT(*) FROM relay_outbox WHERE target_id = $1`, maloja.ID)
+	assert.Equal(t, 0, count)
+
+	// playing now is sent right away and never queued
+	req, err := http.NewRequest("POST", host()+"/apis/listenbrainz/1/submit-listens", strings.NewReader(`{
+		"listen_type": "playing_now",
+		"payload": [{
+			"track_metadata": {
+				"artist_name": "さユり",
+				"track_name": "花の塔",
+				"additional_info": {"media_player": "Navidrome"}
+			}
+		}]
+	}`))
+	require.NoError(t, err)
+	req.Header.Add("Authorization", fmt.Sprintf("Token %s", apikey))
+	req.Header.Add("Content-Type", "application/json")
+	resp, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	count, _ = store.Count(ctx, `SELECT COUNT(*) FROM relay_outbox WHERE target_id = $1`, lbz.ID)
+	assert.Equal(t, 3, count)
+
+	// disable the listenbrainz target
+	formdata = url.Values{}
+	formdata.Set("id", strconv.Itoa(int(lbz.ID)))
+	formdata.Set("enabled", "false")
+	resp, err = makeAuthRequest(t, session, "PATCH", "/apis/web/v1/user/relay-targets", strings.NewReader(formdata.Encode()))
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&lbz))
+	assert.False(t, lbz.Enabled)
+	assert.Equal(t, []string{"Navidrome"}, lbz.IncludeClients)
+
+	// deleting a target removes its queued entries
+	resp, err = makeAuthRequest(t, session, "DELETE", fmt.Sprintf("/apis/web/v1/user/relay-targets?id=%d", lbz.ID), nil)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+	count, _ = store.Count(ctx, `SELECT COUNT(*) FROM relay_outbox`)
+	assert.Equal(t, 0, count)
+
+	resp, err = makeAuthRequest(t, session, "DELETE", fmt.Sprintf("/apis/web/v1/user/relay-targets?id=%d", lbz.ID), nil)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	err = store.Exec(ctx, `TRUNCATE relay_targets, relay_outbox RESTART IDENTITY CASCADE`)
+	require.NoError(t, err)
+	truncateTestData(t)
+}
+
+func TestRewriteRulesApi(t *testing.T) {
+
+	login(t)
+	getApiKey(t, session)
+	truncateTestData(t)
+
+	ctx := context.Background()
+	err := store.Exec(ctx, `TRUNCATE rewrite_rules RESTART IDENTITY CASCADE`)
+	require.NoError(t, err)
+
+	createRule := func(form url.Values) models.RewriteRule {
+		resp, err := makeAuthRequest(t, session, "POST", "/apis/web/v1/user/rewrite-rules", strings.NewReader(form.Encode()))
+		require.NoError(t, err)
+		require.Equal(t, http.StatusCreated, resp.StatusCode)
+		var rule models.RewriteRule
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&rule))
+		return rule
+	}
+
+	strip := createRule(url.Values{
+		"field":      {"track"},
+		"match_type": {"regex"},
+		"pattern":    {` - \d{4} Remaster`},
+		"action":     {"strip_suffix"},
+	})
+	drop := createRule(url.Values{
+		"field":      {"client"},
+		"match_type": {"exact"},
+		"pattern":    {"podcasts"},
+		"action":     {"drop"},
+	})
+	assert.EqualValues(t, 2, drop.Position)
+
+	// invalid regex
+	formdata := url.Values{"field": {"track"}, "match_type": {"regex"}, "pattern": {"(unclosed"}, "action": {"drop"}}
+	resp, err := makeAuthRequest(t, session, "POST", "/apis/web/v1/user/rewrite-rules", strings.NewReader(formdata.Encode()))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	// dry run
+	body := `{"artist_name": "さユり", "track_name": "花の塔 - 2022 Remaster", "additional_info": {"media_player": "Navidrome"}}`
+	resp, err = makeAuthRequest(t, session, "POST", "/apis/web/v1/user/rewrite-rules/test", strings.NewReader(body))
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var result handlers.TestRewriteRulesResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&result))
+	assert.False(t, result.Dropped)
+	assert.Equal(t, []int32{strip.ID}, result.Applied)
+	assert.Equal(t, "花の塔", result.TrackMeta.TrackName)
+
+	body = `{"artist_name": "さユり", "track_name": "花の塔", "additional_info": {"media_player": "Podcasts"}}`
+	resp, err = makeAuthRequest(t, session, "POST", "/apis/web/v1/user/rewrite-rules/test", strings.NewReader(body))
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&result))
+	assert.True(t, result.Dropped)
+
+	err = store.Exec(ctx, `TRUNCATE relay_targets, relay_outbox RESTART IDENTITY CASCADE`)
+	require.NoError(t, err)
+	err = store.Exec(ctx, `INSERT INTO relay_targets (user_id, kind, url, token) VALUES (1, 'listenbrainz', 'http://listenbrainz.invalid/1', 'lbztoken')`)
+	require.NoError(t, err)
+
+	// rules are applied to submitted listens
+	submit := func(track, client string) {
+		body := fmt.Sprintf(`{
+			"listen_type": "single",
+			"payload": [{
+				"listened_at": %d,
+				"track_metadata": {
+					"artist_name": "さユり",
+					"track_name": "%s",
+					"release_name": "酸欠少女",
+					"additional_info": {"media_player": "%s"}
+				}
+			}]
+		}`, time.Now().Unix(), track, client)
+		req, err := http.NewRequest("POST", host()+"/apis/listenbrainz/1/submit-listens", strings.NewReader(body))
+		require.NoError(t, err)
+		req.Header.Add("Authorization", fmt.Sprintf("Token %s", apikey))
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+	}
+	submit("花の塔 - 2022 Remaster", "Navidrome")
+	submit("Podcast Episode", "Podcasts")
+
+	count, _ := store.Count(ctx, `SELECT COUNT(*) FROM listens`)
+	assert.Equal(t, 1, count)
+	exists, err := store.RowExists(ctx, `SELECT EXISTS (SELECT 1 FROM tracks_with_title WHERE title = $1)`, "花の塔")
+	require.NoError(t, err)
+	assert.True(t, exists)
+
+	// only the rewritten listen is relayed
+	count, _ = store.Count(ctx, `SELECT COUNT(*) FROM relay_outbox`)
+	assert.Equal(t, 1, count)
+	exists, err = store.RowExists(ctx, `SELECT EXISTS (SELECT 1 FROM relay_outbox WHERE payload::text LIKE '%Remaster%')`)
+	require.NoError(t, err)
+	assert.False(t, exists)
+
+	// invalid listens are not relayed
+	req, err := http.NewRequest("POST", host()+"/apis/listenbrainz/1/submit-listens", strings.NewReader(`{
+		"listen_type": "single",
+		"payload": [{"track_metadata": {"artist_name": "さユり", "track_name": ""}}]
+	}`))
+	require.NoError(t, err)
+	req.Header.Add("Authorization", fmt.Sprintf("Token %s", apikey))
+	resp, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	count, _ = store.Count(ctx, `SELECT COUNT(*) FROM relay_outbox`)
+	assert.Equal(t, 1, count)
+
+	// disabling a rule
+	formdata = url.Values{"id": {strconv.Itoa(int(drop.ID))}, "enabled": {"false"}}
+	resp, err = makeAuthRequest(t, session, "PATCH", "/apis/web/v1/user/rewrite-rules", strings.NewReader(formdata.Encode()))
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	submit("Podcast Episode", "Podcasts")
+	count, _ = store.Count(ctx, `SELECT COUNT(*) FROM listens`)
+	assert.Equal(t, 2, count)
+
+	resp, err = makeAuthRequest(t, session, "DELETE", fmt.Sprintf("/apis/web/v1/user/rewrite-rules?id=%d", strip.ID), nil)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+	resp, err = makeAuthRequest(t, session, "GET", "/apis/web/v1/user/rewrite-rules", nil)
+	require.NoError(t, err)
+	var rules []models.RewriteRule
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&rules))
+	assert.Len(t, rules, 1)
+
+	err = store.Exec(ctx, `TRUNCATE rewrite_rules, relay_targets, relay_outbox RESTART IDENTITY CASCADE`)
+	require.NoError(t, err)
+	truncateTestData(t)
+}
+
+func TestDuplicateListensAdminApi(t *testing.T) {
+
+	doSubmitListens(t)
+
+	ctx := context.Background()
+	// the same listens, submitted by a client that didn't identify itself
+	err := store.Exec(ctx,
+		`INSERT INTO listens (user_id, track_id, listened_at)
+		SELECT user_id, track_id, listened_at + interval '5 seconds' FROM listens`)
+	require.NoError(t, err)
+
+	// dedupe is not configured, so a window is required
+	resp, err := makeAuthRequest(t, session, "GET", "/apis/web/v1/admin/duplicates", nil)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	resp, err = makeAuthRequest(t, session, "GET", "/apis/web/v1/admin/duplicates?window=60", nil)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var result handlers.DuplicateListensResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&result))
+	require.Len(t, result.Groups, 3)
+	assert.Equal(t, 3, result.Removable)
+	assert.Equal(t, "navidrome", result.Groups[0].Keep.Client)
+	count, _ := store.Count(ctx, `SELECT COUNT(*) FROM listens`)
+	assert.Equal(t, 6, count, "scanning should not remove anything")
+
+	resp, err = makeAuthRequest(t, session, "DELETE", "/apis/web/v1/admin/duplicates?window=duration", nil)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var removed handlers.RemoveDuplicateListensResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&removed))
+	assert.Equal(t, 3, removed.Removed)
+	count, _ = store.Count(ctx, `SELECT COUNT(*) FROM listens WHERE client = 'navidrome'`)
+	assert.Equal(t, 3, count)
+	count, _ = store.Count(ctx, `SELECT COUNT(*) FROM listens`)
+	assert.Equal(t, 3, count)
+}
+
+func TestUserAdministration(t *testing.T) {
+	login(t)
+	ctx := context.Background()
+	t.Cleanup(func() {
+		store.Exec(ctx, `DELETE FROM users WHERE id <> 1`)
+		store.Exec(ctx, `TRUNCATE user_invites RESTART IDENTITY CASCADE`)
+	})
+
+	loginAs := func(username, password string) *http.Response {
+		formdata := url.Values{}
+		formdata.Set("username", username)
+		formdata.Set("password", password)
+		resp, err := http.DefaultClient.Post(host()+"/apis/web/v1/login", "application/x-www-form-urlencoded", strings.NewReader(formdata.Encode()))
+		require.NoError(t, err)
+		return resp
+	}
+
+	formdata := url.Values{}
+	formdata.Set("username", "family_member")
+	formdata.Set("password", "family_password")
+	resp, err := makeAuthRequest(t, session, "POST", "/apis/web/v1/admin/users", strings.NewReader(formdata.Encode()))
+	require.NoError(t, err)
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+	var user models.User
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&user))
+	assert.Equal(t, models.UserRoleUser, user.Role)
+
+	// usernames are unique
+	resp, err = makeAuthRequest(t, session, "POST", "/apis/web/v1/admin/users", strings.NewReader(formdata.Encode()))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusConflict, resp.StatusCode)
+
+	resp = loginAs("family_member", "family_password")
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+	memberSession := resp.Cookies()[0].Value
+
+	// regular users can't manage users
+	resp, err = makeAuthRequest(t, memberSession, "GET", "/apis/web/v1/admin/users", nil)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+	resp, err = makeAuthRequest(t, session, "GET", "/apis/web/v1/admin/users", nil)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var users []models.User
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&users))
+	assert.Len(t, users, 2)
+
+	// admins can't lock themselves out
+	resp, err = makeAuthRequest(t, session, "PATCH", "/apis/web/v1/admin/users", strings.NewReader("id=1&disabled=true"))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	resp, err = makeAuthRequest(t, session, "PATCH", "/apis/web/v1/admin/users", strings.NewReader(fmt.Sprintf("id=%d&disabled=true", user.ID)))
+	require.NoError(t, err)
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+
+	resp, err = makeAuthRequest(t, memberSession, "GET", "/apis/web/v1/user/me", nil)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode, "disabled users should be logged out")
+	resp = loginAs("family_member", "family_password")
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+	resp, err = makeAuthRequest(t, session, "DELETE", fmt.Sprintf("/apis/web/v1/admin/users?id=%d", user.ID), nil)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+	count, _ := store.Count(ctx, `SELECT COUNT(*) FROM users`)
+	assert.Equal(t, 1, count)
+
+	// invites
+	resp, err = makeAuthRequest(t, session, "POST", "/apis/web/v1/admin/invites", strings.NewReader("expires_in=1h"))
+	require.NoError(t, err)
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+	var invite models.UserInvite
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&invite))
+	require.NotEmpty(t, invite.Token)
+
+	register := func(token, username string) *http.Response {
+		formdata := url.Values{}
+		formdata.Set("token", token)
+		formdata.Set("username", username)
+		formdata.Set("password", "invited_password")
+		resp, err := http.DefaultClient.Post(host()+"/apis/web/v1/register", "application/x-www-form-urlencoded", strings.NewReader(formdata.Encode()))
+		require.NoError(t, err)
+		return resp
+	}
+
+	resp = register("not_a_token", "invited")
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	resp = register(invite.Token, "invited")
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+	resp = register(invite.Token, "invited_again")
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode, "invites can only be used once")
+
+	resp = loginAs("invited", "invited_password")
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+}
+
+func TestProfileVisibility(t *testing.T) {
+	t.Run("Submit Listens", doSubmitListens)
+	ctx := context.Background()
+	require.NoError(t, store.Exec(ctx, `UPDATE users SET visibility = 'private'`))
+	t.Cleanup(func() {
+		store.Exec(ctx, `UPDATE users SET visibility = 'public'`)
+		truncateTestData(t)
+	})
+
+	username := strings.ToLower(cfg.DefaultUsername())
+	get := func(endpoint string) *http.Response {
+		resp, err := http.DefaultClient.Get(host() + endpoint)
+		require.NoError(t, err)
+		return resp
+	}
+
+	// private profiles are hidden from anonymous viewers, but not from their owner
+	resp := get("/apis/web/v1/u/" + username + "/top-tracks")
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	resp = get("/apis/web/v1/u/not_a_user")
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	resp, err := makeAuthRequest(t, session, "GET", "/apis/web/v1/u/"+username+"/top-tracks", nil)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// the same goes for the ListenBrainz API, and for statistics that are not asked for by user
+	resp = get("/apis/listenbrainz/1/user/" + username + "/listen-count")
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	resp = get("/apis/listenbrainz/1/stats/user/" + username + "/artists")
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	req, err := http.NewRequest("GET", host()+"/apis/listenbrainz/1/user/"+username+"/listen-count", nil)
+	require.NoError(t, err)
+	req.Header.Add("Authorization", fmt.Sprintf("Token %s", apikey))
+	resp, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	var listens db.PaginatedResponse[models.Listen]
+	resp = get("/apis/web/v1/listens?period=all_time")
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&listens))
+	assert.Empty(t, listens.Items)
+	resp, err = makeAuthRequest(t, session, "GET", "/apis/web/v1/listens?period=all_time", nil)
+	require.NoError(t, err)
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&listens))
+	assert.Len(t, listens.Items, 3)
+
+	resp, err = makeAuthRequest(t, session, "PATCH", "/apis/web/v1/user", strings.NewReader("visibility=everyone"))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	resp, err = makeAuthRequest(t, session, "PATCH", "/apis/web/v1/user", strings.NewReader("visibility=unlisted"))
+	require.NoError(t, err)
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+
+	for _, endpoint := range []string{"", "/top-tracks", "/top-albums", "/top-artists", "/listens", "/listen-activity", "/wrapped"} {
+		resp = get("/apis/web/v1/u/" + username + endpoint)
+		assert.Equal(t, http.StatusOK, resp.StatusCode, endpoint)
+	}
+	var profiles []handlers.ProfileResponse
+	resp = get("/apis/web/v1/u")
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&profiles))
+	assert.Empty(t, profiles, "unlisted profiles should not be listed")
+
+	resp, err = makeAuthRequest(t, session, "PATCH", "/apis/web/v1/user", strings.NewReader("visibility=public"))
+	require.NoError(t, err)
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+	resp = get("/apis/web/v1/u")
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&profiles))
+	require.Len(t, profiles, 1)
+	assert.Equal(t, username, profiles[0].Username)
+}
+
+func TestApiKeyScopes(t *testing.T) {
+	login(t)
+	ctx := context.Background()
+	t.Cleanup(func() {
+		store.Exec(ctx, `DELETE FROM api_keys WHERE label LIKE 'scoped %'`)
+	})
+
+	createKey := func(form string) models.ApiKey {
+		resp, err := makeAuthRequest(t, session, "POST", "/apis/web/v1/user/apikeys", strings.NewReader(form))
+		require.NoError(t, err)
+		require.Equal(t, http.StatusCreated, resp.StatusCode)
+		var key models.ApiKey
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&key))
+		return key
+	}
+	withKey := func(key, method, endpoint string, body io.Reader) *http.Response {
+		req, err := http.NewRequest(method, host()+endpoint, body)
+		require.NoError(t, err)
+		req.Header.Add("Authorization", fmt.Sprintf("Token %s", key))
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		return resp
+	}
+
+	resp, err := makeAuthRequest(t, session, "POST", "/apis/web/v1/user/apikeys", strings.NewReader("label=scoped+bad&scope=admin"))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	submitKey := createKey("label=scoped+submit&scope=submit")
+	assert.Equal(t, models.ApiKeyScopeSubmit, submitKey.Scope)
+	readKey := createKey("label=scoped+read&scope=read")
+	expiringKey := createKey("label=scoped+expiring&expires_in=1h")
+	assert.Equal(t, models.ApiKeyScopeFull, expiringKey.Scope)
+	require.NotNil(t, expiringKey.ExpiresAt)
+
+	// submit keys can scrobble, but not touch the catalog or read statistics
+	resp = withKey(submitKey.Key, "GET", "/apis/listenbrainz/1/validate-token", nil)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	resp = withKey(submitKey.Key, "DELETE", "/apis/web/v1/track?id=1", nil)
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	resp = withKey(submitKey.Key, "GET", "/apis/web/v1/user/apikeys", nil)
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+	// read keys can't submit or modify anything
+	resp = withKey(readKey.Key, "GET", "/apis/listenbrainz/1/validate-token", nil)
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	resp = withKey(readKey.Key, "POST", "/apis/web/v1/merge/tracks?from_id=1&to_id=2", nil)
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+	resp = withKey(expiringKey.Key, "GET", "/apis/web/v1/user/apikeys", nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var keys []models.ApiKey
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&keys))
+	for _, k := range keys {
+		if k.ID == submitKey.ID {
+			assert.NotNil(t, k.LastUsedAt, "expected key usage to be recorded")
+			assert.NotEmpty(t, k.LastUsedIP)
+		}
+	}
+
+	require.NoError(t, store.Exec(ctx, `UPDATE api_keys SET expires_at = NOW() - INTERVAL '1 minute' WHERE id = $1`, expiringKey.ID))
+	resp = withKey(expiringKey.Key, "GET", "/apis/web/v1/user/apikeys", nil)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+}
+
+func TestOIDCLogin(t *testing.T) {
+	ctx := context.Background()
+	t.Cleanup(func() {
+		store.Exec(ctx, `DELETE FROM users WHERE username IN ('sso_user', 'link_user')`)
+	})
+
+	// cookies are not stored for a blank host, so the server is addressed through the loopback interface
+	_, port, err := net.SplitHostPort(cfg.ListenAddr())
+	require.NoError(t, err)
+	base := "http://127.0.0.1:" + port
+
+	// follows the flow through the provider and stops at the redirect back into the app
+	ssoFlow := func(endpoint, session string, claims map[string]any) (*http.Response, string) {
+		issuer.SetUser(claims)
+		jar, err := cookiejar.New(nil)
+		require.NoError(t, err)
+		u, _ := url.Parse(base)
+		if session != "" {
+			jar.SetCookies(u, []*http.Cookie{{Name: "koito_session", Value: session}})
+		}
+		client := &http.Client{
+			Jar: jar,
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				if req.URL.Path == "/" {
+					return http.ErrUseLastResponse
+				}
+				return nil
+			},
+		}
+		resp, err := client.Get(base + endpoint)
+		require.NoError(t, err)
+		for _, c := range jar.Cookies(u) {
+			if c.Name == "koito_session" && c.Value != session {
+				return resp, c.Value
+			}
+		}
+		return resp, ""
+	}
+	ssoLogin := func(claims map[string]any) (*http.Response, string) {
+		return ssoFlow("/apis/web/v1/oidc/login", "", claims)
+	}
+
+	resp, sso := ssoLogin(map[string]any{"sub": "sso-1", "preferred_username": "sso_user", "groups": []string{"koito-admins"}})
+	require.Equal(t, http.StatusFound, resp.StatusCode)
+	require.NotEmpty(t, sso)
+
+	resp, err = makeAuthRequest(t, sso, "GET", "/apis/web/v1/user/me", nil)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var me models.User
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&me))
+	assert.Equal(t, "sso_user", me.Username)
+	assert.Equal(t, models.UserRoleAdmin, me.Role)
+
+	// the role follows the admin group on every login
+	resp, sso = ssoLogin(map[string]any{"sub": "sso-1", "preferred_username": "sso_user"})
+	require.Equal(t, http.StatusFound, resp.StatusCode)
+	require.NotEmpty(t, sso)
+	user, err := store.GetUserByOIDCSubject(ctx, "sso-1")
+	require.NoError(t, err)
+	require.NotNil(t, user)
+	assert.Equal(t, models.UserRoleUser, user.Role)
+
+	// another identity can't take over an account by claiming its username, and neither
+	// the account nor its role are touched
+	resp, sso = ssoLogin(map[string]any{"sub": "sso-2", "preferred_username": "sso_user"})
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	assert.Empty(t, sso)
+	resp, sso = ssoLogin(map[string]any{"sub": "sso-2", "preferred_username": cfg.DefaultUsername(), "groups": []string{}})
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	assert.Empty(t, sso)
+	owner, err := store.GetUserByUsername(ctx, cfg.DefaultUsername())
+	require.NoError(t, err)
+	require.NotNil(t, owner)
+	assert.Equal(t, models.UserRoleAdmin, owner.Role)
+	assert.Empty(t, owner.OIDCSubject)
+
+	// existing users link an identity while signed in, after which they can sign in with it
+	_, err = store.SaveUser(ctx, db.SaveUserOpts{Username: "link_user", Password: "link_password"})
+	require.NoError(t, err)
+	form := url.Values{}
+	form.Set("username", "link_user")
+	form.Set("password", "link_password")
+	resp, err = http.DefaultClient.Post(host()+"/apis/web/v1/login", "application/x-www-form-urlencoded", strings.NewReader(form.Encode()))
+	require.NoError(t, err)
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+	require.Len(t, resp.Cookies(), 1)
+	resp, _ = ssoFlow("/apis/web/v1/oidc/link", resp.Cookies()[0].Value, map[string]any{"sub": "sso-3", "preferred_username": "someone_else"})
+	require.Equal(t, http.StatusFound, resp.StatusCode)
+	resp, sso = ssoLogin(map[string]any{"sub": "sso-3", "preferred_username": "someone_else"})
+	require.Equal(t, http.StatusFound, resp.StatusCode)
+	require.NotEmpty(t, sso)
+	resp, err = makeAuthRequest(t, sso, "GET", "/apis/web/v1/user/me", nil)
+	require.NoError(t, err)
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&me))
+	assert.Equal(t, "link_user", me.Username)
+
+	// the callback can't be completed without the state cookie set at the start of the login
+	resp, err = http.Get(base + "/apis/web/v1/oidc/callback?code=code-1&state=abc")
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestTrustedProxyHeader(t *testing.T) {
+	withHeader := func(username, endpoint string) *http.Response {
+		req, err := http.NewRequest("GET", host()+endpoint, nil)
+		require.NoError(t, err)
+		req.Header.Set("Remote-User", username)
+		// the proxy check uses the connection's address, not the forwarded one
+		req.Header.Set("X-Forwarded-For", "203.0.113.7")
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		return resp
+	}
+
+	resp := withHeader(cfg.DefaultUsername(), "/apis/web/v1/user/me")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var me models.User
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&me))
+	assert.Equal(t, cfg.DefaultUsername(), me.Username)
+
+	resp = withHeader("nobody_by_this_name", "/apis/web/v1/user/me")
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	// users signed in by the proxy can see their own private profile
+	ctx := context.Background()
+	require.NoError(t, store.Exec(ctx, `UPDATE users SET visibility = 'private'`))
+	t.Cleanup(func() {
+		store.Exec(ctx, `UPDATE users SET visibility = 'public'`)
+	})
+	resp = withHeader(cfg.DefaultUsername(), "/apis/web/v1/u/"+strings.ToLower(cfg.DefaultUsername())+"/top-tracks")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// the header never replaces an api key on the scrobbling apis
+	resp = withHeader(cfg.DefaultUsername(), "/apis/listenbrainz/1/validate-token")
+	assert.NotEqual(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestTOTPLogin(t *testing.T) {
+	ctx := context.Background()
+	t.Cleanup(func() {
+		store.Exec(ctx, `DELETE FROM users WHERE username = 'totp_user'`)
+	})
+	user, err := store.SaveUser(ctx, db.SaveUserOpts{Username: "totp_user", Password: "totp_password"})
+	require.NoError(t, err)
+
+	passwordLogin := func() *http.Response {
+		form := url.Values{}
+		form.Set("username", "totp_user")
+		form.Set("password", "totp_password")
+		resp, err := http.DefaultClient.Post(host()+"/apis/web/v1/login", "application/x-www-form-urlencoded", strings.NewReader(form.Encode()))
+		require.NoError(t, err)
+		return resp
+	}
+	codeLogin := func(token, code string) *http.Response {
+		form := url.Values{}
+		form.Set("login_token", token)
+		form.Set("code", code)
+		resp, err := http.DefaultClient.Post(host()+"/apis/web/v1/login/totp", "application/x-www-form-urlencoded", strings.NewReader(form.Encode()))
+		require.NoError(t, err)
+		return resp
+	}
+
+	resp := passwordLogin()
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+	require.Len(t, resp.Cookies(), 1)
+	userSession := resp.Cookies()[0].Value
+
+	resp, err = makeAuthRequest(t, userSession, "POST", "/apis/web/v1/user/totp", nil)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var setup handlers.TOTPSetupResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&setup))
+	assert.Contains(t, setup.URI, "secret="+setup.Secret)
+
+	resp, err = makeAuthRequest(t, userSession, "POST", "/apis/web/v1/user/totp/verify", strings.NewReader("code=000000x"))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	code, err := totp.Code(setup.Secret, totp.Step(time.Now()))
+	require.NoError(t, err)
+	resp, err = makeAuthRequest(t, userSession, "POST", "/apis/web/v1/user/totp/verify", strings.NewReader("code="+code))
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var recovery handlers.TOTPRecoveryCodesResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&recovery))
+	require.Len(t, recovery.RecoveryCodes, 10)
+
+	// the password alone is no longer enough
+	resp = passwordLogin()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Empty(t, resp.Cookies())
+	var required handlers.TOTPRequiredResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&required))
+	assert.True(t, required.TOTPRequired)
+	require.NotEmpty(t, required.LoginToken)
+
+	resp = codeLogin(required.LoginToken, "000000")
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	// the code used to enable two-factor authentication can't be used again
+	resp = codeLogin(required.LoginToken, code)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	next, err := totp.Code(setup.Secret, totp.Step(time.Now())+1)
+	require.NoError(t, err)
+	resp = codeLogin(required.LoginToken, next)
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+	require.Len(t, resp.Cookies(), 1)
+
+	// login tokens are single use
+	resp = codeLogin(required.LoginToken, recovery.RecoveryCodes[0])
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	resp = passwordLogin()
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&required))
+	resp = codeLogin(required.LoginToken, strings.ToUpper(recovery.RecoveryCodes[0]))
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+	resp = passwordLogin()
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&required))
+	resp = codeLogin(required.LoginToken, recovery.RecoveryCodes[0])
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	// api keys keep working without a second factor
+	key, err := store.SaveApiKey(ctx, db.SaveApiKeyOpts{Key: "totp_user_api_key", UserID: user.ID, Label: "totp"})
+	require.NoError(t, err)
+	req, err := http.NewRequest("GET", host()+"/apis/listenbrainz/1/validate-token", nil)
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Token "+key.Key)
+	resp, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	resp, err = makeAuthRequest(t, userSession, "DELETE", "/apis/web/v1/user/totp", strings.NewReader("code=000000"))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	resp, err = makeAuthRequest(t, userSession, "DELETE", "/apis/web/v1/user/totp", strings.NewReader("code="+recovery.RecoveryCodes[1]))
+	require.NoError(t, err)
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+	resp = passwordLogin()
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+}
+
+func TestSessions(t *testing.T) {
+	login(t)
+	ctx := context.Background()
+	t.Cleanup(func() {
+		store.Exec(ctx, `DELETE FROM users WHERE username = 'sessions_user'`)
+	})
+	_, err := store.SaveUser(ctx, db.SaveUserOpts{Username: "sessions_user", Password: "sessions_password"})
+	require.NoError(t, err)
+
+	newSession := func(userAgent string) string {
+		form := url.Values{}
+		form.Set("username", "sessions_user")
+		form.Set("password", "sessions_password")
+		req, err := http.NewRequest("POST", host()+"/apis/web/v1/login", strings.NewReader(form.Encode()))
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("User-Agent", userAgent)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusNoContent, resp.StatusCode)
+		require.Len(t, resp.Cookies(), 1)
+		return resp.Cookies()[0].Value
+	}
+	sessionWorks := func(s string) bool {
+		resp, err := makeAuthRequest(t, s, "GET", "/apis/web/v1/user/me", nil)
+		require.NoError(t, err)
+		return resp.StatusCode == http.StatusOK
+	}
+
+	first := newSession("first-browser")
+	second := newSession("second-browser")
+	third := newSession("third-browser")
+
+	resp, err := makeAuthRequest(t, first, "GET", "/apis/web/v1/user/sessions", nil)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var sessions []handlers.SessionResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&sessions))
+	require.Len(t, sessions, 3)
+	// sessions are listed by a hash of their ID, so that the list does not hold session cookies
+	handle := func(sessionID string) string {
+		sum := sha256.Sum256([]byte(sessionID))
+		return hex.EncodeToString(sum[:])
+	}
+	for _, s := range sessions {
+		assert.NotContains(t, []string{first, second, third}, s.ID)
+		assert.Equal(t, s.ID == handle(first), s.Current)
+		assert.NotEmpty(t, s.IP)
+		if s.ID == handle(first) {
+			assert.Equal(t, "first-browser", s.UserAgent)
+		}
+	}
+
+	// sessions of other users can't be revoked, and neither can sessions by their cookie
+	resp, err = makeAuthRequest(t, first, "DELETE", "/apis/web/v1/user/sessions?id="+handle(session), nil)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	resp, err = makeAuthRequest(t, first, "DELETE", "/apis/web/v1/user/sessions?id="+second, nil)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	resp, err = makeAuthRequest(t, first, "DELETE", "/apis/web/v1/user/sessions", nil)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	resp, err = makeAuthRequest(t, first, "DELETE", "/apis/web/v1/user/sessions?id="+handle(second), nil)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+	assert.False(t, sessionWorks(second))
+	assert.True(t, sessionWorks(third))
+
+	resp, err = makeAuthRequest(t, first, "DELETE", "/apis/web/v1/user/sessions?others=true", nil)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+	assert.False(t, sessionWorks(third))
+	assert.True(t, sessionWorks(first))
+}
+
+func TestAuditLog(t *testing.T) {
+	t.Run("Submit Listens", doSubmitListens)
+	require.NoError(t, store.Exec(context.Background(), `TRUNCATE audit_log RESTART IDENTITY`))
+
+	resp, err := makeAuthRequest(t, session, "POST", "/apis/web/v1/aliases", strings.NewReader("artist_id=2&alias=Audit+Alias"))
+	require.NoError(t, err)
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+	resp, err = makeAuthRequest(t, session, "POST", "/apis/web/v1/merge/artists?from_id=1&to_id=2", nil)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+	resp, err = makeAuthRequest(t, session, "DELETE", "/apis/web/v1/track?id=1", nil)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+
+	resp, err = makeAuthRequest(t, session, "GET", "/apis/web/v1/admin/audit-log", nil)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var entries db.PaginatedResponse[models.AuditEntry]
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&entries))
+	require.Len(t, entries.Items, 3)
+
+	deletion := entries.Items[0]
+	assert.Equal(t, models.AuditActionDelete, deletion.Action)
+	assert.Equal(t, models.AuditTargetTrack, deletion.TargetType)
+	assert.Equal(t, []int32{1}, deletion.TargetIDs)
+	assert.Equal(t, cfg.DefaultUsername(), deletion.Username)
+	var track models.Track
+	require.NoError(t, json.Unmarshal(deletion.Before, &track))
+	assert.EqualValues(t, 1, track.ID)
+	assert.Equal(t, "null", string(deletion.After))
+
+	merge := entries.Items[1]
+	assert.Equal(t, models.AuditActionMerge, merge.Action)
+	assert.Equal(t, []int32{1, 2}, merge.TargetIDs)
+	var before map[string]models.Artist
+	require.NoError(t, json.Unmarshal(merge.Before, &before))
+	assert.EqualValues(t, 1, before["from"].ID)
+	assert.EqualValues(t, 2, before["to"].ID)
+
+	alias := entries.Items[2]
+	assert.Equal(t, models.AuditActionCreateAlias, alias.Action)
+	assert.Contains(t, string(alias.After), "Audit Alias")
+	assert.NotContains(t, string(alias.Before), "Audit Alias")
+
+	resp, err = makeAuthRequest(t, session, "GET", "/apis/web/v1/admin/audit-log?target_type=artist&target_id=1", nil)
+	require.NoError(t, err)
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&entries))
+	require.Len(t, entries.Items, 1)
+	assert.Equal(t, models.AuditActionMerge, entries.Items[0].Action)
+
+	resp, err = makeAuthRequest(t, session, "GET", "/apis/web/v1/admin/audit-log?target_id=abc", nil)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
 }
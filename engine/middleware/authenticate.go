@@ -30,9 +30,16 @@ const (
 	AuthModeAPIKey
 	AuthModeSessionOrAPIKey
 	AuthModeLoginGate
+	// Only accepts the user named in the trusted proxy header. The session modes accept
+	// the header as well when header authentication is enabled.
+	AuthModeTrustedHeader
 )
 
-func Authenticate(store db.DB, mode AuthMode) func(http.Handler) http.Handler {
+var errApiKeyScope = errors.New("api key does not have the required scope")
+
+// Authenticate attaches the requesting user to the request context. API keys must have the
+// given scope to be accepted, while sessions can be used for anything.
+func Authenticate(store db.DB, mode AuthMode, scope models.ApiKeyScope) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			ctx := r.Context()
@@ -43,29 +50,46 @@ func Authenticate(store db.DB, mode AuthMode) func(http.Handler) http.Handler {
 
 			switch mode {
 			case AuthModeSessionCookie:
-				user, err = validateSession(ctx, store, r)
+				user, err = validateSessionOrTrustedHeader(ctx, store, r)
 
 			case AuthModeAPIKey:
-				user, err = validateAPIKey(ctx, store, r)
+				user, err = validateAPIKey(ctx, store, r, scope)
 
 			case AuthModeSessionOrAPIKey:
-				user, err = validateSession(ctx, store, r)
+				user, err = validateSessionOrTrustedHeader(ctx, store, r)
 				if err != nil || user == nil {
-					user, err = validateAPIKey(ctx, store, r)
+					user, err = validateAPIKey(ctx, store, r, scope)
 				}
 
 			case AuthModeLoginGate:
 				if cfg.LoginGate() {
-					user, err = validateSession(ctx, store, r)
+					user, err = validateSessionOrTrustedHeader(ctx, store, r)
 					if err != nil || user == nil {
-						user, err = validateAPIKey(ctx, store, r)
+						user, err = validateAPIKey(ctx, store, r, scope)
 					}
 				} else {
+					// the request is allowed either way, but a logged in user still
+					// decides whose statistics are shown and which private profiles
+					// can be seen
+					user, err := validateSessionOrTrustedHeader(ctx, store, r)
+					if (err != nil || user == nil) && r.Header.Get("Authorization") != "" {
+						user, err = validateAPIKey(ctx, store, r, scope)
+					}
+					if err == nil && user != nil {
+						r = r.WithContext(context.WithValue(ctx, UserContextKey, user))
+					}
 					next.ServeHTTP(w, r)
 					return
 				}
+
+			case AuthModeTrustedHeader:
+				user, err = validateTrustedHeader(ctx, store, r)
 			}
 
+			if errors.Is(err, errApiKeyScope) {
+				utils.WriteError(w, err.Error(), http.StatusForbidden)
+				return
+			}
 			if err != nil {
 				l.Err(err).Msg("authentication failed")
 				utils.WriteError(w, "unauthorized", http.StatusUnauthorized)
@@ -85,6 +109,24 @@ func Authenticate(store db.DB, mode AuthMode) func(http.Handler) http.Handler {
 	}
 }
 
+// RequireAdmin rejects requests from users without the admin role. It must be used after
+// Authenticate so that the user is present in the request context.
+func RequireAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user := GetUserFromContext(r.Context())
+		if user == nil {
+			utils.WriteError(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if user.Role != models.UserRoleAdmin {
+			logger.FromContext(r.Context()).Debug().Msgf("RequireAdmin: User '%s' is not an admin", user.Username)
+			utils.WriteError(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
 func validateSession(ctx context.Context, store db.DB, r *http.Request) (*models.User, error) {
 	l := logger.FromContext(r.Context())
 
@@ -120,14 +162,19 @@ func validateSession(ctx context.Context, store db.DB, r *http.Request) (*models
 
 	l.Debug().Msgf("ValidateSession: Refreshing session for user '%s'", u.Username)
 
-	store.RefreshSession(r.Context(), sid, time.Now().Add(30*24*time.Hour))
+	store.RefreshSession(r.Context(), db.RefreshSessionOpts{
+		ID:        sid,
+		ExpiresAt: time.Now().Add(30 * 24 * time.Hour),
+		UserAgent: r.UserAgent(),
+		IP:        utils.ClientIP(r),
+	})
 
 	l.Debug().Msgf("ValidateSession: Refreshed session for user '%s'", u.Username)
 
 	return u, nil
 }
 
-func validateAPIKey(ctx context.Context, store db.DB, r *http.Request) (*models.User, error) {
+func validateAPIKey(ctx context.Context, store db.DB, r *http.Request, scope models.ApiKeyScope) (*models.User, error) {
 	l := logger.FromContext(ctx)
 
 	l.Debug().Msg("ValidateApiKey: Checking if user is already authenticated")
@@ -141,6 +188,16 @@ func validateAPIKey(ctx context.Context, store db.DB, r *http.Request) (*models.
 		return nil, errors.New("authorization header is invalid")
 	}
 
+	key, err := store.GetApiKey(ctx, token)
+	if err != nil {
+		l.Err(err).Msg("ValidateApiKey: Failed to get api key from database")
+		return nil, errors.New("internal server error")
+	}
+	if key == nil {
+		l.Debug().Msg("ValidateApiKey: API key does not exist or has expired")
+		return nil, errors.New("authorization token is invalid")
+	}
+
 	u, err := store.GetUserByApiKey(ctx, token)
 	if err != nil {
 		l.Err(err).Msg("ValidateApiKey: Failed to get user from database using api key")
@@ -151,8 +208,14 @@ func validateAPIKey(ctx context.Context, store db.DB, r *http.Request) (*models.
 		return nil, errors.New("authorization token is invalid")
 	}
 
-	ctx = context.WithValue(r.Context(), UserContextKey, u)
-	r = r.WithContext(ctx)
+	if err := store.UpdateApiKeyLastUsed(ctx, key.ID, utils.ClientIP(r)); err != nil {
+		l.Err(err).Msg("ValidateApiKey: Failed to record api key usage")
+	}
+
+	if !key.Scope.Allows(scope) {
+		l.Debug().Msgf("ValidateApiKey: API key %d has scope '%s', but '%s' is required", key.ID, key.Scope, scope)
+		return nil, errApiKeyScope
+	}
 
 	return u, nil
 }
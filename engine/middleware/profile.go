@@ -0,0 +1,69 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gabehf/koito/internal/db"
+	"github.com/gabehf/koito/internal/logger"
+	"github.com/gabehf/koito/internal/models"
+	"github.com/gabehf/koito/internal/utils"
+	"github.com/go-chi/chi/v5"
+)
+
+const ProfileContextKey MiddlwareContextKey = "profile"
+
+// CanViewProfile reports whether viewer, which is nil for anonymous requests, may see the
+// listening history of profile.
+func CanViewProfile(viewer, profile *models.User) bool {
+	if profile == nil || profile.Disabled {
+		return false
+	}
+	if profile.Visibility != models.ProfileVisibilityPrivate {
+		return true
+	}
+	return viewer != nil && (viewer.ID == profile.ID || viewer.Role == models.UserRoleAdmin)
+}
+
+// ResolveProfile serves the profile of the user named by the {username} URL parameter. The
+// login gate does not apply, since the profile's visibility decides who can see it. Profiles
+// the viewer may not see are reported as not found, so that private usernames are not revealed.
+func ResolveProfile(store db.DB) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := r.Context()
+			l := logger.FromContext(ctx)
+
+			// a viewer is optional here, so failing to authenticate is not an error
+			viewer, err := validateSessionOrTrustedHeader(ctx, store, r)
+			if (err != nil || viewer == nil) && r.Header.Get("Authorization") != "" {
+				viewer, _ = validateAPIKey(ctx, store, r, models.ApiKeyScopeRead)
+			}
+			if viewer != nil {
+				ctx = context.WithValue(ctx, UserContextKey, viewer)
+			}
+
+			profile, err := store.GetUserByUsername(ctx, chi.URLParam(r, "username"))
+			if err != nil {
+				l.Err(err).Msg("ResolveProfile: Failed to get user")
+				utils.WriteError(w, "failed to get user", http.StatusInternalServerError)
+				return
+			}
+			if !CanViewProfile(viewer, profile) {
+				utils.WriteError(w, "user not found", http.StatusNotFound)
+				return
+			}
+
+			ctx = context.WithValue(ctx, ProfileContextKey, profile)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+func GetProfileFromContext(ctx context.Context) *models.User {
+	profile, ok := ctx.Value(ProfileContextKey).(*models.User)
+	if !ok {
+		return nil
+	}
+	return profile
+}
@@ -0,0 +1,88 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+
+	"github.com/gabehf/koito/internal/cfg"
+	"github.com/gabehf/koito/internal/db"
+	"github.com/gabehf/koito/internal/logger"
+	"github.com/gabehf/koito/internal/models"
+)
+
+const peerAddrContextKey MiddlwareContextKey = "peerAddr"
+
+var errNoTrustedHeader = errors.New("request does not come from a trusted proxy")
+
+// WithPeerAddr remembers the address of the connection a request came in on. It must be used
+// before RealIP, which replaces the remote address with one taken from the request headers.
+func WithPeerAddr(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := context.WithValue(r.Context(), peerAddrContextKey, r.RemoteAddr)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// fromTrustedProxy reports whether the connection the request came in on belongs to one of
+// the configured trusted proxies.
+func fromTrustedProxy(r *http.Request) bool {
+	remote, ok := r.Context().Value(peerAddrContextKey).(string)
+	if !ok {
+		remote = r.RemoteAddr
+	}
+	host, _, err := net.SplitHostPort(remote)
+	if err != nil {
+		host = remote
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return false
+	}
+	return cfg.IsTrustedProxy(addr)
+}
+
+// validateTrustedHeader authenticates the user named in the trusted proxy header. The header is
+// ignored unless header authentication is enabled and the request comes from a trusted proxy,
+// in which case errNoTrustedHeader is returned.
+func validateTrustedHeader(ctx context.Context, store db.DB, r *http.Request) (*models.User, error) {
+	l := logger.FromContext(ctx)
+
+	header := cfg.TrustedProxyHeader()
+	if header == "" {
+		return nil, errNoTrustedHeader
+	}
+	username := strings.TrimSpace(r.Header.Get(header))
+	if username == "" {
+		return nil, errNoTrustedHeader
+	}
+	if !fromTrustedProxy(r) {
+		l.Warn().Msgf("ValidateTrustedHeader: Ignoring %s header from a request that did not come from a trusted proxy", header)
+		return nil, errNoTrustedHeader
+	}
+
+	u, err := store.GetUserByUsername(ctx, username)
+	if err != nil {
+		l.Err(err).Msg("ValidateTrustedHeader: Failed to get user from database")
+		return nil, errors.New("internal server error")
+	}
+	if u == nil || u.Disabled {
+		l.Debug().Msgf("ValidateTrustedHeader: No active user '%s' found for %s header", username, header)
+		return nil, errors.New("user from proxy header does not exist")
+	}
+
+	return u, nil
+}
+
+// validateSessionOrTrustedHeader accepts the trusted proxy header in place of a session cookie,
+// so that users already signed in at the proxy don't have to log in again.
+func validateSessionOrTrustedHeader(ctx context.Context, store db.DB, r *http.Request) (*models.User, error) {
+	u, err := validateTrustedHeader(ctx, store, r)
+	if errors.Is(err, errNoTrustedHeader) {
+		return validateSession(ctx, store, r)
+	}
+	return u, err
+}
@@ -13,7 +13,9 @@ import (
 	"github.com/gabehf/koito/internal/catalog"
 	"github.com/gabehf/koito/internal/cfg"
 	"github.com/gabehf/koito/internal/db"
+	"github.com/gabehf/koito/internal/models"
 	mbz "github.com/gabehf/koito/internal/mbz"
+	"github.com/gabehf/koito/internal/oidc"
 	"github.com/go-chi/chi/v5"
 	chimiddleware "github.com/go-chi/chi/v5/middleware"
 	"github.com/go-chi/cors"
@@ -28,6 +30,7 @@ func bindRoutes(
 	discogsC catalog.DiscogsCaller,
 	lastfmC catalog.LastFmCaller,
 	spotifyC catalog.SpotifyCaller,
+	oidcP *oidc.Provider,
 	controller *handlers.BackfillController,
 ) {
 	if !(len(cfg.AllowedOrigins()) == 0) && !(cfg.AllowedOrigins()[0] == "") {
@@ -43,10 +46,11 @@ func bindRoutes(
 		r.Get("/config", handlers.GetCfgHandler())
 
 		r.Group(func(r chi.Router) {
-			r.Use(middleware.Authenticate(db, middleware.AuthModeLoginGate))
+			r.Use(middleware.Authenticate(db, middleware.AuthModeLoginGate, models.ApiKeyScopeRead))
 			r.Get("/artist", handlers.GetArtistHandler(db))
 			r.Get("/artists", handlers.GetArtistsForItemHandler(db))
 			r.Get("/album", handlers.GetAlbumHandler(db))
+			r.Get("/release-group", handlers.GetReleaseGroupHandler(db))
 			r.Get("/track", handlers.GetTrackHandler(db))
 			r.Get("/top-tracks", handlers.GetTopTracksHandler(db))
 			r.Get("/top-albums", handlers.GetTopAlbumsHandler(db))
@@ -54,6 +58,7 @@ func bindRoutes(
 			r.Get("/listens", handlers.GetListensHandler(db))
 			r.Get("/listen-activity", handlers.GetListenActivityHandler(db))
 			r.Get("/now-playing", handlers.NowPlayingHandler(db))
+			r.Get("/now-playing/stream", handlers.NowPlayingStreamHandler(db))
 			r.Get("/stats", handlers.StatsHandler(db))
 			r.Get("/wrapped", handlers.WrappedHandler(db))
 			r.Get("/search", handlers.SearchHandler(db))
@@ -62,6 +67,18 @@ func bindRoutes(
 			r.Get("/summary", handlers.SummaryHandler(db))
 			r.Get("/interest", handlers.GetInterestHandler(db))
 		})
+		r.Get("/u", handlers.PublicProfilesHandler(db))
+		r.Route("/u/{username}", func(r chi.Router) {
+			r.Use(middleware.ResolveProfile(db))
+			r.Get("/", handlers.ProfileHandler())
+			r.Get("/top-tracks", handlers.GetTopTracksHandler(db))
+			r.Get("/top-albums", handlers.GetTopAlbumsHandler(db))
+			r.Get("/top-artists", handlers.GetTopArtistsHandler(db))
+			r.Get("/listens", handlers.GetListensHandler(db))
+			r.Get("/listen-activity", handlers.GetListenActivityHandler(db))
+			r.Get("/stats", handlers.StatsHandler(db))
+			r.Get("/wrapped", handlers.WrappedHandler(db))
+		})
 		r.Post("/logout", handlers.LogoutHandler(db))
 		if !cfg.RateLimitDisabled() {
 			r.With(httprate.Limit(
@@ -74,6 +91,35 @@ func bindRoutes(
 		} else {
 			r.Post("/login", handlers.LoginHandler(db))
 		}
+		if !cfg.RateLimitDisabled() {
+			r.With(httprate.Limit(
+				10,
+				time.Minute,
+				httprate.WithLimitHandler(func(w http.ResponseWriter, r *http.Request) {
+					http.Error(w, `{"error":"too many requests"}`, http.StatusTooManyRequests)
+				}),
+			)).Post("/login/totp", handlers.LoginTOTPHandler(db))
+		} else {
+			r.Post("/login/totp", handlers.LoginTOTPHandler(db))
+		}
+		if !cfg.RateLimitDisabled() {
+			r.With(httprate.Limit(
+				10,
+				time.Minute,
+				httprate.WithLimitHandler(func(w http.ResponseWriter, r *http.Request) {
+					http.Error(w, `{"error":"too many requests"}`, http.StatusTooManyRequests)
+				}),
+			)).Post("/register", handlers.RegisterHandler(db))
+		} else {
+			r.Post("/register", handlers.RegisterHandler(db))
+		}
+
+		if oidcP != nil {
+			r.Get("/oidc/login", handlers.OIDCLoginHandler(oidcP))
+			r.Get("/oidc/callback", handlers.OIDCCallbackHandler(db, oidcP))
+			r.With(middleware.Authenticate(db, middleware.AuthModeSessionCookie, models.ApiKeyScopeFull)).
+				Get("/oidc/link", handlers.OIDCLoginHandler(oidcP))
+		}
 
 		r.Get("/health", func(w http.ResponseWriter, r *http.Request) {
 			if !ready.Load() {
@@ -84,15 +130,21 @@ func bindRoutes(
 		})
 
 		r.Group(func(r chi.Router) {
-			r.Use(middleware.Authenticate(db, middleware.AuthModeSessionOrAPIKey))
+			r.Use(middleware.Authenticate(db, middleware.AuthModeSessionOrAPIKey, models.ApiKeyScopeFull))
 			r.Get("/export", handlers.ExportHandler(db))
 			r.Post("/replace-image", handlers.ReplaceImageHandler(db))
 			r.Patch("/album", handlers.UpdateAlbumHandler(db))
 			r.Post("/merge/tracks", handlers.MergeTracksHandler(db))
 			r.Post("/merge/albums", handlers.MergeReleaseGroupsHandler(db))
 			r.Post("/merge/artists", handlers.MergeArtistsHandler(db))
+			r.Post("/unmerge/tracks", handlers.UnmergeTracksHandler(db))
+			r.Post("/unmerge/albums", handlers.UnmergeAlbumsHandler(db))
+			r.Post("/unmerge/artists", handlers.UnmergeArtistsHandler(db))
 			r.Delete("/artist", handlers.DeleteArtistHandler(db))
 			r.Post("/artists/primary", handlers.SetPrimaryArtistHandler(db))
+			r.Post("/artists/role", handlers.SetArtistRoleHandler(db))
+			r.Post("/album/release-group", handlers.GroupAlbumsHandler(db))
+			r.Delete("/album/release-group", handlers.UngroupAlbumHandler(db))
 			r.Delete("/album", handlers.DeleteAlbumHandler(db))
 			r.Delete("/track", handlers.DeleteTrackHandler(db))
 			r.Post("/listen", handlers.SubmitListenWithIDHandler(db))
@@ -105,13 +157,45 @@ func bindRoutes(
 			r.Post("/user/apikeys", handlers.GenerateApiKeyHandler(db))
 			r.Patch("/user/apikeys", handlers.UpdateApiKeyLabelHandler(db))
 			r.Delete("/user/apikeys", handlers.DeleteApiKeyHandler(db))
+			r.Get("/user/relay-targets", handlers.GetRelayTargetsHandler(db))
+			r.Post("/user/relay-targets", handlers.CreateRelayTargetHandler(db))
+			r.Patch("/user/relay-targets", handlers.UpdateRelayTargetHandler(db))
+			r.Delete("/user/relay-targets", handlers.DeleteRelayTargetHandler(db))
+			r.Get("/user/rewrite-rules", handlers.GetRewriteRulesHandler(db))
+			r.Post("/user/rewrite-rules", handlers.CreateRewriteRuleHandler(db))
+			r.Patch("/user/rewrite-rules", handlers.UpdateRewriteRuleHandler(db))
+			r.Delete("/user/rewrite-rules", handlers.DeleteRewriteRuleHandler(db))
+			r.Post("/user/rewrite-rules/test", handlers.TestRewriteRulesHandler(db))
 			r.Get("/user/me", handlers.MeHandler(db))
 			r.Patch("/user", handlers.UpdateUserHandler(db))
 		})
 
 		r.Group(func(r chi.Router) {
-			r.Use(middleware.Authenticate(db, middleware.AuthModeSessionCookie))
+			r.Use(middleware.Authenticate(db, middleware.AuthModeSessionCookie, models.ApiKeyScopeFull))
 			r.Post("/admin/backfill-genres", handlers.BackfillGenresHandler(db, mbz, discogsC, lastfmC, spotifyC, controller))
+			r.Post("/user/totp", handlers.TOTPSetupHandler(db))
+			r.Post("/user/totp/verify", handlers.TOTPEnableHandler(db))
+			r.Delete("/user/totp", handlers.TOTPDisableHandler(db))
+			r.Get("/user/sessions", handlers.GetSessionsHandler(db))
+			r.Delete("/user/sessions", handlers.DeleteSessionsHandler(db))
+		})
+
+		r.Group(func(r chi.Router) {
+			r.Use(middleware.Authenticate(db, middleware.AuthModeSessionOrAPIKey, models.ApiKeyScopeFull))
+			r.Use(middleware.RequireAdmin)
+			r.Get("/admin/relay", handlers.GetRelayEntriesHandler(db))
+			r.Post("/admin/relay/retry", handlers.RetryRelayEntriesHandler(db))
+			r.Delete("/admin/relay", handlers.DeleteRelayEntriesHandler(db))
+			r.Get("/admin/duplicates", handlers.GetDuplicateListensHandler(db))
+			r.Get("/admin/audit-log", handlers.GetAuditLogHandler(db))
+			r.Delete("/admin/duplicates", handlers.DeleteDuplicateListensHandler(db))
+			r.Get("/admin/users", handlers.GetUsersHandler(db))
+			r.Post("/admin/users", handlers.CreateUserHandler(db))
+			r.Patch("/admin/users", handlers.AdminUpdateUserHandler(db))
+			r.Delete("/admin/users", handlers.DeleteUserHandler(db))
+			r.Get("/admin/invites", handlers.GetUserInvitesHandler(db))
+			r.Post("/admin/invites", handlers.CreateUserInviteHandler(db))
+			r.Delete("/admin/invites", handlers.DeleteUserInviteHandler(db))
 		})
 	})
 
@@ -121,10 +205,49 @@ func bindRoutes(
 			AllowedHeaders: []string{"Content-Type", "Authorization"},
 		}))
 
-		r.With(middleware.Authenticate(db, middleware.AuthModeAPIKey)).
+		r.With(middleware.Authenticate(db, middleware.AuthModeAPIKey, models.ApiKeyScopeSubmit)).
 			Post("/submit-listens", handlers.LbzSubmitListenHandler(db, mbz))
-		r.With(middleware.Authenticate(db, middleware.AuthModeAPIKey)).
+		r.With(middleware.Authenticate(db, middleware.AuthModeAPIKey, models.ApiKeyScopeSubmit)).
 			Get("/validate-token", handlers.LbzValidateTokenHandler(db))
+
+		r.Group(func(r chi.Router) {
+			r.Use(middleware.Authenticate(db, middleware.AuthModeLoginGate, models.ApiKeyScopeRead))
+			r.Get("/user/{user}/listens", handlers.LbzGetListensHandler(db))
+			r.Get("/user/{user}/playing-now", handlers.LbzPlayingNowHandler(db))
+			r.Get("/user/{user}/listen-count", handlers.LbzListenCountHandler(db))
+			r.Get("/stats/user/{user}/artists", handlers.LbzStatsArtistsHandler(db))
+			r.Get("/stats/user/{user}/releases", handlers.LbzStatsReleasesHandler(db))
+			r.Get("/stats/user/{user}/recordings", handlers.LbzStatsRecordingsHandler(db))
+			r.Get("/stats/user/{user}/listening-activity", handlers.LbzStatsListeningActivityHandler(db))
+		})
+	})
+
+	r.Route("/apis/audioscrobbler/2.0", func(r chi.Router) {
+		r.Use(cors.Handler(cors.Options{
+			AllowedOrigins: []string{"*"},
+			AllowedMethods: []string{"GET", "POST", "OPTIONS"},
+			AllowedHeaders: []string{"Content-Type"},
+		}))
+
+		r.Get("/", handlers.AudioscrobblerHandler(db, mbz))
+		r.Post("/", handlers.AudioscrobblerHandler(db, mbz))
+	})
+
+	r.Route("/apis/mlj_1", func(r chi.Router) {
+		r.Use(cors.Handler(cors.Options{
+			AllowedOrigins: []string{"*"},
+			AllowedMethods: []string{"GET", "POST", "OPTIONS"},
+			AllowedHeaders: []string{"Content-Type"},
+		}))
+
+		r.Post("/newscrobble", handlers.MalojaScrobbleHandler(db, mbz))
+		r.Get("/test", handlers.MalojaTestHandler(db))
+	})
+
+	r.Route("/apis/audioscrobbler/1.2", func(r chi.Router) {
+		r.Get("/", handlers.AudioscrobblerLegacyHandshakeHandler(db))
+		r.Post("/submissions", handlers.AudioscrobblerLegacySubmissionsHandler(db, mbz))
+		r.Post("/nowplaying", handlers.AudioscrobblerLegacyNowPlayingHandler(db, mbz))
 	})
 
 	// serve react client
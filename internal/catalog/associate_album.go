@@ -24,7 +24,9 @@ type AssociateAlbumOpts struct {
 	ReleaseName       string
 	TrackName         string // required
 	Mbzc              mbz.MusicBrainzCaller
-	SkipCacheImage    bool
+	// Marks releases created for the listen as Various Artists releases
+	VariousArtists bool
+	SkipCacheImage bool
 }
 
 type AlbumWithoutImages struct {
@@ -132,7 +134,7 @@ func createOrUpdateAlbumWithMbzReleaseID(ctx context.Context, d db.DB, opts Asso
 	} else {
 		l.Debug().Msgf("Album %s could not be found. Creating...", release.Title)
 
-		var variousArtists bool
+		variousArtists := opts.VariousArtists
 		for _, artistCredit := range release.ArtistCredit {
 			if artistCredit.Name == "Various Artists" {
 				l.Debug().Msgf("MusicBrainz release group '%s' detected as being a Various Artists compilation release", release.Title)
@@ -273,11 +275,12 @@ func matchAlbumByTitle(ctx context.Context, d db.DB, opts AssociateAlbumOpts) (*
 		}
 
 		a, err = d.SaveAlbum(ctx, db.SaveAlbumOpts{
-			Title:         releaseName,
-			ArtistIDs:     utils.FlattenArtistIDs(opts.Artists),
-			Image:         imgid,
-			MusicBrainzID: opts.ReleaseMbzID,
-			ImageSrc:      imgUrl,
+			Title:          releaseName,
+			ArtistIDs:      utils.FlattenArtistIDs(opts.Artists),
+			VariousArtists: opts.VariousArtists,
+			Image:          imgid,
+			MusicBrainzID:  opts.ReleaseMbzID,
+			ImageSrc:       imgUrl,
 		})
 		if err != nil {
 			return nil, fmt.Errorf("matchAlbumByTitle: %w", err)
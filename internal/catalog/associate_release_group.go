@@ -0,0 +1,63 @@
+package catalog
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/gabehf/koito/internal/db"
+	"github.com/gabehf/koito/internal/logger"
+	"github.com/gabehf/koito/internal/mbz"
+	"github.com/gabehf/koito/internal/models"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+type AssociateReleaseGroupOpts struct {
+	AlbumID           int32     // required
+	ReleaseGroupMbzID uuid.UUID // required
+	// Used as the title of a new release group when MusicBrainz can't be reached
+	ReleaseTitle string
+	Mbzc         mbz.MusicBrainzCaller
+}
+
+// AssociateReleaseGroup adds the album to the release group with the given MusicBrainz ID,
+// creating the group if it does not exist yet. Albums that are already part of a release
+// group are left alone, so that groupings made by hand are not undone by new listens.
+func AssociateReleaseGroup(ctx context.Context, d db.DB, opts AssociateReleaseGroupOpts) (*models.ReleaseGroup, error) {
+	l := logger.FromContext(ctx)
+	if opts.AlbumID == 0 || opts.ReleaseGroupMbzID == uuid.Nil {
+		return nil, errors.New("AssociateReleaseGroup: album id and release group mbid are required")
+	}
+
+	rg, err := d.GetReleaseGroup(ctx, db.GetReleaseGroupOpts{MusicBrainzID: opts.ReleaseGroupMbzID})
+	if errors.Is(err, pgx.ErrNoRows) {
+		title := opts.ReleaseTitle
+		mbzRG, err := opts.Mbzc.GetReleaseGroup(ctx, opts.ReleaseGroupMbzID)
+		if err == nil && mbzRG.Title != "" {
+			title = mbzRG.Title
+		} else if err != nil {
+			l.Info().AnErr("err", err).Msg("AssociateReleaseGroup: failed to get release group from MusicBrainz")
+		}
+		l.Debug().Msgf("Creating release group '%s' with MusicBrainz ID %s", title, opts.ReleaseGroupMbzID)
+		rg, err = d.SaveReleaseGroup(ctx, db.SaveReleaseGroupOpts{
+			Title:         title,
+			MusicBrainzID: opts.ReleaseGroupMbzID,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("AssociateReleaseGroup: %w", err)
+		}
+	} else if err != nil {
+		return nil, fmt.Errorf("AssociateReleaseGroup: %w", err)
+	}
+
+	err = d.SetAlbumReleaseGroup(ctx, db.SetAlbumReleaseGroupOpts{
+		AlbumID:        opts.AlbumID,
+		ReleaseGroupID: rg.ID,
+		KeepExisting:   true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("AssociateReleaseGroup: %w", err)
+	}
+	return rg, nil
+}
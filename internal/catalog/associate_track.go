@@ -14,12 +14,32 @@ import (
 )
 
 type AssociateTrackOpts struct {
-	ArtistIDs  []int32
-	AlbumID    int32
-	TrackMbzID uuid.UUID
-	TrackName  string
-	Duration   int32
-	Mbzc       mbz.MusicBrainzCaller
+	ArtistIDs []int32
+	// How each of ArtistIDs is credited, main when not present. Tracks are matched by their
+	// main artists only.
+	ArtistRoles map[int32]models.ArtistRole
+	AlbumID     int32
+	TrackMbzID  uuid.UUID
+	TrackName   string
+	// The title as submitted, when TrackName has had featured artists removed from it. It is
+	// kept as an alias of the track.
+	OriginalTrackName string
+	Duration          int32
+	Mbzc              mbz.MusicBrainzCaller
+}
+
+// mainArtistIDs returns the artists credited as main artists.
+func (opts AssociateTrackOpts) mainArtistIDs() []int32 {
+	var ids []int32
+	for _, id := range opts.ArtistIDs {
+		if role, ok := opts.ArtistRoles[id]; !ok || role == models.ArtistRoleMain {
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 {
+		return opts.ArtistIDs
+	}
+	return ids
 }
 
 func AssociateTrack(ctx context.Context, d db.DB, opts AssociateTrackOpts) (*models.Track, error) {
@@ -33,14 +53,59 @@ func AssociateTrack(ctx context.Context, d db.DB, opts AssociateTrackOpts) (*mod
 	if opts.AlbumID == 0 {
 		return nil, errors.New("AssociateTrack: release group id must be specified")
 	}
+	var track *models.Track
+	var err error
 	// first, try to match track Mbz ID
 	if opts.TrackMbzID != uuid.Nil {
 		l.Debug().Msgf("Associating track '%s' by MusicBrainz recording ID", opts.TrackName)
-		return matchTrackByMbzID(ctx, d, opts)
+		track, err = matchTrackByMbzID(ctx, d, opts)
 	} else {
 		l.Debug().Msgf("Associating track '%s' by title and artist", opts.TrackName)
-		return matchTrackByTrackInfo(ctx, d, opts)
+		track, err = matchTrackByTrackInfo(ctx, d, opts)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := completeTrackCredits(ctx, d, track, opts); err != nil {
+		return nil, fmt.Errorf("AssociateTrack: %w", err)
+	}
+	return track, nil
+}
+
+// completeTrackCredits credits any artists of the listen that the matched track doesn't have
+// yet, and keeps the title the listen was submitted with as an alias when featured artists
+// were removed from it.
+func completeTrackCredits(ctx context.Context, d db.DB, track *models.Track, opts AssociateTrackOpts) error {
+	l := logger.FromContext(ctx)
+	credited := make(map[int32]bool, len(track.Artists))
+	for _, a := range track.Artists {
+		credited[a.ID] = true
 	}
+	var missing []int32
+	for _, id := range opts.ArtistIDs {
+		if !credited[id] {
+			missing = append(missing, id)
+		}
+	}
+	// a newly saved track is returned without its artists, which were all just credited
+	if len(missing) > 0 && len(track.Artists) > 0 {
+		l.Debug().Msgf("Crediting artist(s) %v on track '%s'", missing, track.Title)
+		err := d.AddArtistsToTrack(ctx, db.AddArtistsToTrackOpts{
+			TrackID:     track.ID,
+			ArtistIDs:   missing,
+			ArtistRoles: opts.ArtistRoles,
+		})
+		if err != nil {
+			return fmt.Errorf("completeTrackCredits: %w", err)
+		}
+	}
+	if opts.OriginalTrackName != "" && opts.OriginalTrackName != opts.TrackName {
+		err := d.SaveTrackAliases(ctx, track.ID, []string{opts.OriginalTrackName}, "Original")
+		if err != nil {
+			return fmt.Errorf("completeTrackCredits: %w", err)
+		}
+	}
+	return nil
 }
 
 // If no match is found, will call matchTrackByTitleAndArtist and associate the Mbz ID with the result
@@ -85,8 +150,16 @@ func matchTrackByTrackInfo(ctx context.Context, d db.DB, opts AssociateTrackOpts
 	track, err := d.GetTrack(ctx, db.GetTrackOpts{
 		Title:     opts.TrackName,
 		ReleaseID: opts.AlbumID,
-		ArtistIDs: opts.ArtistIDs,
+		ArtistIDs: opts.mainArtistIDs(),
 	})
+	if errors.Is(err, pgx.ErrNoRows) && opts.OriginalTrackName != "" && opts.OriginalTrackName != opts.TrackName {
+		// tracks saved before featured artists were removed from titles
+		track, err = d.GetTrack(ctx, db.GetTrackOpts{
+			Title:     opts.OriginalTrackName,
+			ReleaseID: opts.AlbumID,
+			ArtistIDs: opts.mainArtistIDs(),
+		})
+	}
 	if err == nil {
 		l.Debug().Msgf("Track '%s' found by title, release and artist match", track.Title)
 		return track, nil
@@ -99,7 +172,7 @@ func matchTrackByTrackInfo(ctx context.Context, d db.DB, opts AssociateTrackOpts
 				track, err := d.GetTrack(ctx, db.GetTrackOpts{
 					Title:     mbzTrack.Title,
 					ReleaseID: opts.AlbumID,
-					ArtistIDs: opts.ArtistIDs,
+					ArtistIDs: opts.mainArtistIDs(),
 				})
 				if err == nil {
 					l.Debug().Msgf("Track '%s' found by MusicBrainz title, release and artist match", opts.TrackName)
@@ -113,6 +186,7 @@ func matchTrackByTrackInfo(ctx context.Context, d db.DB, opts AssociateTrackOpts
 			AlbumID:        opts.AlbumID,
 			Title:          opts.TrackName,
 			ArtistIDs:      opts.ArtistIDs,
+			ArtistRoles:    opts.ArtistRoles,
 			Duration:       opts.Duration,
 		})
 		if err != nil {
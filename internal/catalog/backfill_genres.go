@@ -334,14 +334,5 @@ func BackfillGenres(ctx context.Context, store db.DB, fetcher *HybridGenreFetche
 		artistsUpdated = artistCount
 	}
 
-	totalGenres := countTotalGenres(ctx, store)
-	l.Info().Msgf("BackfillGenres: Completed. Backfilled %d albums, %d artists, total %d genres", albumsUpdated, artistsUpdated, totalGenres)
-}
-
-func countTotalGenres(ctx context.Context, store db.DB) int {
-	stats, err := store.GetGenreStatsByListenCount(ctx, db.PeriodToTimeframe(db.PeriodAllTime))
-	if err != nil {
-		return 0
-	}
-	return len(stats)
+	l.Info().Msgf("BackfillGenres: Completed. Backfilled %d albums, %d artists", albumsUpdated, artistsUpdated)
 }
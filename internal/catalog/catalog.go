@@ -8,15 +8,17 @@ import (
 	"errors"
 	"fmt"
 	"regexp"
-	"strconv"
+	"slices"
 	"strings"
 	"time"
 
+	"github.com/gabehf/koito/internal/cfg"
 	"github.com/gabehf/koito/internal/db"
 	"github.com/gabehf/koito/internal/logger"
 	"github.com/gabehf/koito/internal/mbz"
-	"github.com/gabehf/koito/internal/memkv"
 	"github.com/gabehf/koito/internal/models"
+	"github.com/gabehf/koito/internal/nowplaying"
+	"github.com/gabehf/koito/internal/utils"
 	"github.com/google/uuid"
 )
 
@@ -58,6 +60,12 @@ type SubmitListenOpts struct {
 	ReleaseGroupMbzID  uuid.UUID
 	Time               time.Time
 
+	// The artist credited on the release, when it differs from the track artist, e.g. for
+	// compilations
+	AlbumArtist string
+	// Tags of the listen, stored as genres of its release
+	Tags []string
+
 	UserID       int32
 	Client       string
 	IsNowPlaying bool
@@ -89,6 +97,35 @@ func SubmitListen(ctx context.Context, store db.DB, opts SubmitListenOpts) error
 	})
 }
 
+// SubmitListens submits a batch of listens in a single transaction, so either all of them
+// are saved or none are.
+func SubmitListens(ctx context.Context, store db.DB, batch []SubmitListenOpts) error {
+	listens := make([]SubmitListenOpts, len(batch))
+	for i, opts := range batch {
+		if opts.Artist == "" || opts.TrackTitle == "" {
+			return errors.New("track name and artist are required")
+		}
+		opts.Time = opts.Time.Truncate(time.Second)
+		listens[i] = opts
+	}
+
+	submit := func(store db.DB) error {
+		for _, opts := range listens {
+			if err := submitListen(ctx, store, opts); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	txRunner, ok := store.(submitListenTxRunner)
+	if !ok {
+		return submit(store)
+	}
+
+	return txRunner.RunInTransaction(ctx, submit)
+}
+
 func submitListen(ctx context.Context, store db.DB, opts SubmitListenOpts) error {
 	l := logger.FromContext(ctx)
 
@@ -117,13 +154,19 @@ func submitListen(ctx context.Context, store db.DB, opts SubmitListenOpts) error
 		artistIDs[i] = artist.ID
 		l.Debug().Any("artist", artist).Msg("Matched listen to artist")
 	}
+	albumArtists, variousArtists, err := associateAlbumArtists(ctx, store, opts, artists)
+	if err != nil {
+		l.Err(err).Msg("Failed to associate album artists to listen")
+		return fmt.Errorf("SubmitListen: %w", err)
+	}
 	rg, err := AssociateAlbum(ctx, store, AssociateAlbumOpts{
 		ReleaseMbzID:      opts.ReleaseMbzID,
 		ReleaseGroupMbzID: opts.ReleaseGroupMbzID,
 		ReleaseName:       opts.ReleaseTitle,
 		TrackName:         opts.TrackTitle,
 		Mbzc:              opts.MbzCaller,
-		Artists:           artists,
+		Artists:           albumArtists,
+		VariousArtists:    variousArtists,
 		SkipCacheImage:    opts.SkipCacheImage,
 	})
 	if err != nil {
@@ -134,20 +177,43 @@ func submitListen(ctx context.Context, store db.DB, opts SubmitListenOpts) error
 
 	// ensure artists are associated with release group
 	if err := store.AddArtistsToAlbum(ctx, db.AddArtistsToAlbumOpts{
-		ArtistIDs: artistIDs,
+		ArtistIDs: utils.FlattenArtistIDs(albumArtists),
 		AlbumID:   rg.ID,
 	}); err != nil {
 		l.Error().Err(err).Msg("Failed to associate artists with release")
 		return fmt.Errorf("SubmitListen: add artists to album: %w", err)
 	}
 
+	if opts.ReleaseGroupMbzID != uuid.Nil {
+		_, err = AssociateReleaseGroup(ctx, store, AssociateReleaseGroupOpts{
+			AlbumID:           rg.ID,
+			ReleaseGroupMbzID: opts.ReleaseGroupMbzID,
+			ReleaseTitle:      rg.Title,
+			Mbzc:              opts.MbzCaller,
+		})
+		if err != nil {
+			l.Error().Err(err).Msg("Failed to associate release with release group")
+			return fmt.Errorf("SubmitListen: %w", err)
+		}
+	}
+
+	if genres := tagsToGenres(opts.Tags); len(genres) > 0 {
+		l.Debug().Msgf("Saving genres %v for album '%s'", genres, rg.Title)
+		if err := store.SaveAlbumGenres(ctx, rg.ID, genres); err != nil {
+			l.Warn().Err(err).Msgf("Failed to save genres for album '%s'", rg.Title)
+		}
+	}
+
+	credits := ParseArtistCredits(opts.Artist, opts.TrackTitle, ConfiguredCreditPatterns())
 	track, err := AssociateTrack(ctx, store, AssociateTrackOpts{
-		ArtistIDs:  artistIDs,
-		AlbumID:    rg.ID,
-		TrackMbzID: opts.RecordingMbzID,
-		TrackName:  opts.TrackTitle,
-		Duration:   opts.Duration,
-		Mbzc:       opts.MbzCaller,
+		ArtistIDs:         artistIDs,
+		ArtistRoles:       artistRoles(artists, credits),
+		AlbumID:           rg.ID,
+		TrackMbzID:        opts.RecordingMbzID,
+		TrackName:         CanonicalTrackTitle(opts.TrackTitle, cfg.FeatPatterns()),
+		OriginalTrackName: opts.TrackTitle,
+		Duration:          opts.Duration,
+		Mbzc:              opts.MbzCaller,
 	})
 	if err != nil {
 		l.Error().Err(err).Msg("Failed to associate track to listen")
@@ -187,11 +253,11 @@ func submitListen(ctx context.Context, store db.DB, opts SubmitListenOpts) error
 	}
 
 	if opts.IsNowPlaying {
-		if track.Duration == 0 {
-			memkv.Store.Set(strconv.Itoa(int(opts.UserID)), track.ID)
-		} else {
-			memkv.Store.Set(strconv.Itoa(int(opts.UserID)), track.ID, time.Duration(track.Duration)*time.Second)
+		duration := track.Duration
+		if duration == 0 {
+			duration = opts.Duration
 		}
+		nowplaying.Set(opts.UserID, track.ID, opts.Client, opts.Time, time.Duration(duration)*time.Second)
 	}
 
 	if opts.SkipSaveListen {
@@ -200,6 +266,26 @@ func submitListen(ctx context.Context, store db.DB, opts SubmitListenOpts) error
 
 	l.Info().Msgf("Received listen: '%s' by %s, from release '%s'", track.Title, buildArtistStr(artists), rg.Title)
 
+	if policy := ConfiguredDedupePolicy(); policy.Enabled() {
+		duration := track.Duration
+		if duration == 0 {
+			duration = opts.Duration
+		}
+		save, err := dedupeListen(ctx, store, policy, db.ListenRecord{
+			UserID:     opts.UserID,
+			TrackID:    track.ID,
+			ListenedAt: opts.Time,
+			Client:     opts.Client,
+		}, duration)
+		if err != nil {
+			l.Error().Err(err).Msg("Failed to check listen for duplicates")
+			return fmt.Errorf("SubmitListen: %w", err)
+		}
+		if !save {
+			return nil
+		}
+	}
+
 	return store.SaveListen(ctx, db.SaveListenOpts{
 		TrackID: track.ID,
 		Time:    opts.Time,
@@ -208,6 +294,44 @@ func submitListen(ctx context.Context, store db.DB, opts SubmitListenOpts) error
 	})
 }
 
+// associateAlbumArtists returns the artists to credit on the release of the listen: the
+// submitted album artist when there is one, and the track artists otherwise. Releases by
+// Various Artists are credited to the track artists and marked as such.
+func associateAlbumArtists(ctx context.Context, store db.DB, opts SubmitListenOpts, trackArtists []*models.Artist) ([]*models.Artist, bool, error) {
+	albumArtist := strings.TrimSpace(opts.AlbumArtist)
+	if albumArtist == "" || strings.EqualFold(albumArtist, strings.TrimSpace(opts.Artist)) {
+		return trackArtists, false, nil
+	}
+	if strings.EqualFold(albumArtist, "Various Artists") {
+		return trackArtists, true, nil
+	}
+	albumArtists, err := AssociateArtists(ctx, store, AssociateArtistsOpts{
+		ArtistName:     albumArtist,
+		Mbzc:           opts.MbzCaller,
+		SkipCacheImage: opts.SkipCacheImage,
+	})
+	if err != nil {
+		return nil, false, fmt.Errorf("associateAlbumArtists: %w", err)
+	}
+	if len(albumArtists) == 0 {
+		return trackArtists, false, nil
+	}
+	return albumArtists, false, nil
+}
+
+// tagsToGenres normalizes the tags of a listen into genre names.
+func tagsToGenres(tags []string) []string {
+	genres := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		name := strings.ToLower(strings.TrimSpace(tag))
+		if name == "" || slices.Contains(genres, name) {
+			continue
+		}
+		genres = append(genres, name)
+	}
+	return genres
+}
+
 func buildArtistStr(artists []*models.Artist) string {
 	artistNames := make([]string, len(artists))
 	for i, artist := range artists {
@@ -216,80 +340,150 @@ func buildArtistStr(artists []*models.Artist) string {
 	return strings.Join(artistNames, " & ")
 }
 
-var (
-	// Bracketed feat patterns
-	bracketFeatPatterns = []*regexp.Regexp{
-		regexp.MustCompile(`(?i)\([fF]eat\. ([^)]*)\)`),
-		regexp.MustCompile(`(?i)\[[fF]eat\. ([^\]]*)\]`),
+// artistRoles returns how each of the artists is credited, based on the credits parsed from
+// the listen. Artists that can't be found in the credits are main artists, and the first
+// artist is credited as a main artist when none of the others are.
+func artistRoles(artists []*models.Artist, credits []ArtistCredit) map[int32]models.ArtistRole {
+	roles := make(map[int32]models.ArtistRole, len(artists))
+	hasMain := false
+	for _, a := range artists {
+		role := models.ArtistRoleMain
+		for _, c := range credits {
+			if strings.EqualFold(c.Name, a.Name) || slices.ContainsFunc(a.Aliases, func(alias string) bool {
+				return strings.EqualFold(c.Name, alias)
+			}) {
+				role = c.Role
+				break
+			}
+		}
+		roles[a.ID] = role
+		hasMain = hasMain || role == models.ArtistRoleMain
+	}
+	if !hasMain && len(artists) > 0 {
+		roles[artists[0].ID] = models.ArtistRoleMain
 	}
-	// Inline feat (not in brackets)
-	inlineFeatPattern = regexp.MustCompile(`(?i)[fF]eat\. ([^()\[\]]+)$`)
+	return roles
+}
 
-	// Delimiters only used inside feat. sections
-	featSplitDelimiters = regexp.MustCompile(`(?i)\s*(?:,|&|and|·)\s*`)
-)
+// Delimiters only used inside feat. sections
+var featSplitDelimiters = regexp.MustCompile(`(?i)\s*(?:,|&|and|·)\s*`)
+
+// ArtistCredit is an artist name found in an artist string or track title, and how the artist
+// is credited on the track.
+type ArtistCredit struct {
+	Name string
+	Role models.ArtistRole
+}
+
+// CreditPatterns are the regular expressions used to find artists in artist strings and track
+// titles. Feat and remix patterns capture the names they find in their first group.
+type CreditPatterns struct {
+	Separators []*regexp.Regexp
+	Feat       []*regexp.Regexp
+	Remix      []*regexp.Regexp
+}
+
+// ConfiguredCreditPatterns returns the credit patterns set in the configuration.
+func ConfiguredCreditPatterns() CreditPatterns {
+	return CreditPatterns{
+		Separators: cfg.ArtistSeparators(),
+		Feat:       cfg.FeatPatterns(),
+		Remix:      cfg.RemixPatterns(),
+	}
+}
 
 // ParseArtists extracts all contributing artist names from the artist and title strings
 func ParseArtists(artist string, title string, addlSeparators []*regexp.Regexp) []string {
-	seen := make(map[string]struct{})
-	var out []string
+	credits := ParseArtistCredits(artist, title, CreditPatterns{
+		Separators: addlSeparators,
+		Feat:       cfg.FeatPatterns(),
+	})
+	out := make([]string, len(credits))
+	for i, c := range credits {
+		out[i] = c.Name
+	}
+	return out
+}
+
+// ParseArtistCredits extracts all contributing artists from the artist and title strings, along
+// with whether they are main, featured or remixing artists. Remix patterns only change the role
+// of artists found otherwise.
+func ParseArtistCredits(artist string, title string, patterns CreditPatterns) []ArtistCredit {
+	seen := make(map[string]int)
+	var out []ArtistCredit
 
-	add := func(name string) {
+	add := func(name string, role models.ArtistRole) {
 		name = strings.TrimSpace(name)
 		if name == "" {
 			return
 		}
 		if _, exists := seen[name]; !exists {
-			seen[name] = struct{}{}
-			out = append(out, name)
+			seen[name] = len(out)
+			out = append(out, ArtistCredit{Name: name, Role: role})
 		}
 	}
 
-	// Extract bracketed features from artist
-	for _, re := range bracketFeatPatterns {
+	// Extract features from artist
+	for _, re := range patterns.Feat {
 		if matches := re.FindStringSubmatch(artist); matches != nil {
 			artist = strings.Replace(artist, matches[0], "", 1)
 			for _, name := range featSplitDelimiters.Split(matches[1], -1) {
-				add(name)
+				add(name, models.ArtistRoleFeatured)
 			}
 		}
 	}
-	// Extract inline feat. from artist
-	if matches := inlineFeatPattern.FindStringSubmatch(artist); matches != nil {
-		artist = strings.Replace(artist, matches[0], "", 1)
-		for _, name := range featSplitDelimiters.Split(matches[1], -1) {
-			add(name)
-		}
-	}
 
 	// Add base artist(s)
 	l1 := len(out)
-	for _, re := range addlSeparators {
+	for _, re := range patterns.Separators {
 		for _, name := range re.Split(artist, -1) {
 			if name == artist {
 				continue
 			}
-			add(name)
+			add(name, models.ArtistRoleMain)
 		}
 	}
 	// Only add the full artist string if no splitters were matched
 	if l1 == len(out) {
-		add(artist)
+		add(artist, models.ArtistRoleMain)
 	}
 
 	// Extract features from title
-	for _, re := range bracketFeatPatterns {
+	for _, re := range patterns.Feat {
 		if matches := re.FindStringSubmatch(title); matches != nil {
 			for _, name := range featSplitDelimiters.Split(matches[1], -1) {
-				add(name)
+				add(name, models.ArtistRoleFeatured)
 			}
 		}
 	}
-	if matches := inlineFeatPattern.FindStringSubmatch(title); matches != nil {
-		for _, name := range featSplitDelimiters.Split(matches[1], -1) {
-			add(name)
+
+	for _, re := range patterns.Remix {
+		if matches := re.FindStringSubmatch(title); matches != nil {
+			for _, name := range featSplitDelimiters.Split(matches[1], -1) {
+				if i, exists := seen[strings.TrimSpace(name)]; exists {
+					out[i].Role = models.ArtistRoleRemixer
+				}
+			}
 		}
 	}
 
 	return out
 }
+
+var repeatedSpaces = regexp.MustCompile(`\s{2,}`)
+
+// CanonicalTrackTitle removes the featured artists from a track title, so that versions of the
+// title with and without them are matched to the same track.
+func CanonicalTrackTitle(title string, featPatterns []*regexp.Regexp) string {
+	canonical := title
+	for _, re := range featPatterns {
+		if loc := re.FindStringIndex(canonical); loc != nil {
+			canonical = canonical[:loc[0]] + " " + canonical[loc[1]:]
+		}
+	}
+	canonical = strings.TrimSpace(repeatedSpaces.ReplaceAllString(canonical, " "))
+	if canonical == "" {
+		return title
+	}
+	return canonical
+}
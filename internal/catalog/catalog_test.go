@@ -13,6 +13,7 @@ import (
 	"github.com/gabehf/koito/internal/cfg"
 	"github.com/gabehf/koito/internal/db/psql"
 	"github.com/gabehf/koito/internal/mbz"
+	"github.com/gabehf/koito/internal/models"
 	"github.com/gabehf/koito/internal/utils"
 	_ "github.com/gabehf/koito/testing_init"
 	"github.com/google/uuid"
@@ -176,7 +177,8 @@ func truncateTestData(t *testing.T) {
 		releases,
 		artist_releases,
 		release_aliases,
-		listens
+		listens,
+		release_groups
 		RESTART IDENTITY CASCADE`)
 	require.NoError(t, err)
 }
@@ -377,3 +379,56 @@ func TestArtistStringParse(t *testing.T) {
 		assert.ElementsMatch(t, out, artists)
 	}
 }
+
+func TestParseArtistCredits(t *testing.T) {
+	type input struct {
+		Name  string
+		Title string
+	}
+	mainRole := models.ArtistRoleMain
+	featuredRole := models.ArtistRoleFeatured
+	remixerRole := models.ArtistRoleRemixer
+	cases := map[input][]catalog.ArtistCredit{
+		{"NELKE", ""}: {{"NELKE", mainRole}},
+		{"Carly Rae Jepsen feat. Rufus Wainwright", ""}: {
+			{"Carly Rae Jepsen", mainRole}, {"Rufus Wainwright", featuredRole},
+		},
+		{"Tyler, The Creator", "CA (feat. Alice Smith, Leon Ware & Clem Creevy)"}: {
+			{"Tyler, The Creator", mainRole}, {"Alice Smith", featuredRole}, {"Leon Ware", featuredRole}, {"Clem Creevy", featuredRole},
+		},
+		{"Daft Punk feat. Julian Casablancas", "Instant Crush (feat. Julian Casablancas)"}: {
+			{"Daft Punk", mainRole}, {"Julian Casablancas", featuredRole},
+		},
+		{"Magnify Tokyo · Kanade Ishihara", ""}: {
+			{"Magnify Tokyo", mainRole}, {"Kanade Ishihara", mainRole},
+		},
+		// remix patterns only change the role of artists found otherwise
+		{"Porter Robinson · Madeon", "Shelter (Madeon Remix)"}: {
+			{"Porter Robinson", mainRole}, {"Madeon", remixerRole},
+		},
+		{"Porter Robinson", "Shelter (Madeon Remix)"}: {{"Porter Robinson", mainRole}},
+	}
+
+	patterns := catalog.ConfiguredCreditPatterns()
+	patterns.Separators = []*regexp.Regexp{regexp.MustCompile(`\s+·\s+`)}
+	for in, out := range cases {
+		credits := catalog.ParseArtistCredits(in.Name, in.Title, patterns)
+		assert.ElementsMatch(t, out, credits, "artist %q, title %q", in.Name, in.Title)
+	}
+}
+
+func TestCanonicalTrackTitle(t *testing.T) {
+	cases := map[string]string{
+		"Instant Crush": "Instant Crush",
+		"Instant Crush (feat. Julian Casablancas)": "Instant Crush",
+		"In My Car feat. Madeline Kenney":          "In My Car",
+		"오해 금지 [Feat. BIG Naughty]":                "오해 금지",
+		"Song (feat. Someone) (Live)":              "Song (Live)",
+		"Shelter (Madeon Remix)":                   "Shelter (Madeon Remix)",
+		"(feat. Nobody)":                           "(feat. Nobody)",
+	}
+
+	for in, out := range cases {
+		assert.Equal(t, out, catalog.CanonicalTrackTitle(in, cfg.FeatPatterns()), in)
+	}
+}
@@ -0,0 +1,173 @@
+package catalog
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/gabehf/koito/internal/cfg"
+	"github.com/gabehf/koito/internal/db"
+	"github.com/gabehf/koito/internal/logger"
+)
+
+// used in place of half of the track duration when the duration is unknown
+const defaultDedupeWindow = 30 * time.Second
+
+// DedupePolicy decides when two listens of the same track by the same user are
+// considered to be the same listen, e.g. when two clients scrobble the same play.
+type DedupePolicy struct {
+	// When true, the window is half of the track duration, since two real plays
+	// of a track cannot be closer together than that
+	ByDuration bool
+	// The window when ByDuration is false. Otherwise, the window for tracks with
+	// no known duration.
+	Window time.Duration
+}
+
+func NewDedupePolicy(byDuration bool, window time.Duration) DedupePolicy {
+	p := DedupePolicy{
+		ByDuration: byDuration,
+		Window:     window,
+	}
+	if p.ByDuration && p.Window == 0 {
+		p.Window = defaultDedupeWindow
+	}
+	return p
+}
+
+func ConfiguredDedupePolicy() DedupePolicy {
+	return NewDedupePolicy(cfg.DedupeByDuration(), cfg.DedupeWindow())
+}
+
+func (p DedupePolicy) Enabled() bool {
+	return p.ByDuration || p.Window > 0
+}
+
+func (p DedupePolicy) windowFor(duration int32) time.Duration {
+	if p.ByDuration && duration > 0 {
+		return time.Duration(duration) * time.Second / 2
+	}
+	return p.Window
+}
+
+// betterAttributed reports whether a should be kept over b. A listen that knows which
+// client submitted it is preferred; otherwise, the earlier listen is kept.
+func betterAttributed(a, b db.ListenRecord) bool {
+	if (a.Client != "") != (b.Client != "") {
+		return a.Client != ""
+	}
+	return a.ListenedAt.Before(b.ListenedAt)
+}
+
+// dedupeListen looks for an existing listen that duplicates the one being submitted. It
+// reports whether the submitted listen should be saved, removing the existing listen
+// when the submitted one is better attributed. It must run in the same transaction that
+// saves the listen, as it locks the user's listens to the track until the transaction ends
+// so that two clients submitting the same listen at once cannot both save it.
+func dedupeListen(ctx context.Context, store db.DB, policy DedupePolicy, listen db.ListenRecord, duration int32) (bool, error) {
+	l := logger.FromContext(ctx)
+
+	if err := store.LockUserTrackListens(ctx, listen.UserID, listen.TrackID); err != nil {
+		return false, fmt.Errorf("dedupeListen: %w", err)
+	}
+
+	window := policy.windowFor(duration)
+	existing, err := store.GetUserListensToTrackInRange(ctx, db.ListensInRangeOpts{
+		UserID:  listen.UserID,
+		TrackID: listen.TrackID,
+		From:    listen.ListenedAt.Add(-window + time.Second),
+		To:      listen.ListenedAt.Add(window - time.Second),
+	})
+	if err != nil {
+		return false, fmt.Errorf("dedupeListen: %w", err)
+	}
+	for _, dup := range existing {
+		// the new listen is only better if it beats every listen it duplicates
+		if listen.Client != "" && dup.Client == "" {
+			continue
+		}
+		l.Info().Msgf("Listen to track %d at %v duplicates existing listen at %v, skipping", listen.TrackID, listen.ListenedAt, dup.ListenedAt)
+		return false, nil
+	}
+	for _, dup := range existing {
+		l.Info().Msgf("Replacing listen to track %d at %v with better attributed duplicate from client '%s'", dup.TrackID, dup.ListenedAt, listen.Client)
+		if err := store.DeleteListen(ctx, dup.UserID, dup.TrackID, dup.ListenedAt); err != nil {
+			return false, fmt.Errorf("dedupeListen: DeleteListen: %w", err)
+		}
+	}
+	return true, nil
+}
+
+type DuplicateListenGroup struct {
+	Keep   db.ListenRecord   `json:"keep"`
+	Remove []db.ListenRecord `json:"remove"`
+}
+
+// FindDuplicateListens scans listening history for listens that duplicate each other
+// under the given policy. Each group holds the listen that would be kept along with
+// the listens that would be removed.
+func FindDuplicateListens(ctx context.Context, store db.DB, policy DedupePolicy) ([]DuplicateListenGroup, error) {
+	candidates, err := store.GetDuplicateListenCandidates(ctx, db.DuplicateListenOpts{
+		ByDuration: policy.ByDuration,
+		Window:     policy.Window,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("FindDuplicateListens: %w", err)
+	}
+
+	groups := make([]DuplicateListenGroup, 0)
+	var cluster []db.ListenRecord
+	flush := func() {
+		if len(cluster) > 1 {
+			keep := 0
+			for i := range cluster {
+				if betterAttributed(cluster[i], cluster[keep]) {
+					keep = i
+				}
+			}
+			group := DuplicateListenGroup{Keep: cluster[keep]}
+			for i := range cluster {
+				if i != keep {
+					group.Remove = append(group.Remove, cluster[i])
+				}
+			}
+			groups = append(groups, group)
+		}
+		cluster = nil
+	}
+	// candidates are ordered by user, track, and time, so each cluster is every listen
+	// within the window of the first listen in the cluster
+	for _, c := range candidates {
+		if len(cluster) > 0 {
+			first := cluster[0]
+			if first.UserID != c.UserID || first.TrackID != c.TrackID || c.ListenedAt.Sub(first.ListenedAt) >= c.Window {
+				flush()
+			}
+		}
+		cluster = append(cluster, c.ListenRecord)
+	}
+	flush()
+
+	return groups, nil
+}
+
+// RemoveDuplicateListens deletes every listen that FindDuplicateListens would remove,
+// returning the number of listens deleted.
+func RemoveDuplicateListens(ctx context.Context, store db.DB, policy DedupePolicy) (int, error) {
+	l := logger.FromContext(ctx)
+	groups, err := FindDuplicateListens(ctx, store, policy)
+	if err != nil {
+		return 0, fmt.Errorf("RemoveDuplicateListens: %w", err)
+	}
+	removed := 0
+	for _, group := range groups {
+		for _, listen := range group.Remove {
+			if err := store.DeleteListen(ctx, listen.UserID, listen.TrackID, listen.ListenedAt); err != nil {
+				return removed, fmt.Errorf("RemoveDuplicateListens: DeleteListen: %w", err)
+			}
+			removed++
+		}
+	}
+	l.Info().Msgf("Removed %d duplicate listens", removed)
+	return removed, nil
+}
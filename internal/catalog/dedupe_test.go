@@ -0,0 +1,116 @@
+package catalog_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gabehf/koito/internal/catalog"
+	"github.com/gabehf/koito/internal/cfg"
+	"github.com/gabehf/koito/internal/mbz"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func dedupeListenOpts(t time.Time, client string) catalog.SubmitListenOpts {
+	return catalog.SubmitListenOpts{
+		MbzCaller:    &mbz.MbzMockCaller{},
+		ArtistNames:  []string{"ATARASHII GAKKO!"},
+		Artist:       "ATARASHII GAKKO!",
+		TrackTitle:   "Tokyo Calling",
+		ReleaseTitle: "AG! Calling",
+		Duration:     200,
+		Time:         t,
+		Client:       client,
+		UserID:       1,
+	}
+}
+
+func TestSubmitListen_Dedupe(t *testing.T) {
+	truncateTestData(t)
+	cfg.SetDedupeWindow(true, 0)
+	defer cfg.SetDedupeWindow(false, 0)
+
+	ctx := context.Background()
+	start := time.Unix(1749464100, 0)
+
+	// first listen is saved
+	require.NoError(t, catalog.SubmitListen(ctx, store, dedupeListenOpts(start, "")))
+	// a better attributed duplicate replaces it
+	require.NoError(t, catalog.SubmitListen(ctx, store, dedupeListenOpts(start.Add(20*time.Second), "navidrome")))
+	// a worse attributed duplicate is dropped
+	require.NoError(t, catalog.SubmitListen(ctx, store, dedupeListenOpts(start.Add(40*time.Second), "")))
+	// a listen more than half the track length later is not a duplicate
+	require.NoError(t, catalog.SubmitListen(ctx, store, dedupeListenOpts(start.Add(200*time.Second), "")))
+
+	count, err := store.Count(ctx, `SELECT COUNT(*) FROM listens`)
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+
+	exists, err := store.RowExists(ctx, `
+    SELECT EXISTS (
+      SELECT 1 FROM listens
+      WHERE listened_at = $1 AND client = 'navidrome'
+    )`, start.Add(20*time.Second))
+	require.NoError(t, err)
+	assert.True(t, exists, "expected better attributed listen to be kept")
+}
+
+func TestSubmitListen_DedupeConcurrent(t *testing.T) {
+	truncateTestData(t)
+	cfg.SetDedupeWindow(true, 0)
+	defer cfg.SetDedupeWindow(false, 0)
+
+	ctx := context.Background()
+	start := time.Unix(1749464100, 0)
+
+	// create the track up front, so only the listens are submitted concurrently
+	require.NoError(t, catalog.SubmitListen(ctx, store, dedupeListenOpts(start.Add(-time.Hour), "")))
+
+	// several clients submitting the same play at once only save it once
+	var wg sync.WaitGroup
+	errs := make([]error, 5)
+	for i := range errs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = catalog.SubmitListen(ctx, store, dedupeListenOpts(start.Add(time.Duration(i)*time.Second), ""))
+		}()
+	}
+	wg.Wait()
+	for _, err := range errs {
+		require.NoError(t, err)
+	}
+
+	count, err := store.Count(ctx, `SELECT COUNT(*) FROM listens`)
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+}
+
+func TestFindAndRemoveDuplicateListens(t *testing.T) {
+	truncateTestData(t)
+	ctx := context.Background()
+	start := time.Unix(1749464100, 0)
+
+	// dedupe is disabled, so every listen is saved
+	require.NoError(t, catalog.SubmitListen(ctx, store, dedupeListenOpts(start, "")))
+	require.NoError(t, catalog.SubmitListen(ctx, store, dedupeListenOpts(start.Add(10*time.Second), "navidrome")))
+	require.NoError(t, catalog.SubmitListen(ctx, store, dedupeListenOpts(start.Add(30*time.Second), "")))
+	require.NoError(t, catalog.SubmitListen(ctx, store, dedupeListenOpts(start.Add(300*time.Second), "")))
+
+	policy := catalog.NewDedupePolicy(true, 0)
+	groups, err := catalog.FindDuplicateListens(ctx, store, policy)
+	require.NoError(t, err)
+	require.Len(t, groups, 1)
+	assert.Equal(t, "navidrome", groups[0].Keep.Client)
+	assert.Len(t, groups[0].Remove, 2)
+
+	removed, err := catalog.RemoveDuplicateListens(ctx, store, policy)
+	require.NoError(t, err)
+	assert.Equal(t, 2, removed)
+
+	count, err := store.Count(ctx, `SELECT COUNT(*) FROM listens`)
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+}
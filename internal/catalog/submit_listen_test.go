@@ -2,6 +2,7 @@ package catalog_test
 
 import (
 	"context"
+	"fmt"
 	"testing"
 	"time"
 
@@ -63,7 +64,7 @@ func TestSubmitListen_CreateAllMbzIDs(t *testing.T) {
 	assert.True(t, exists, "expected listen row to exist")
 
 	// Verify that listen time is correct
-	p, err := store.GetListensPaginated(ctx, db.GetItemsOpts{Limit: 1, Page: 1, Timeframe: db.Timeframe{Period: db.PeriodAllTime}})
+	p, err := store.GetListensPaginated(ctx, db.GetItemsOpts{UserID: 1, Limit: 1, Page: 1, Timeframe: db.Timeframe{Period: db.PeriodAllTime}})
 	require.NoError(t, err)
 	require.Len(t, p.Items, 1)
 	l := p.Items[0]
@@ -602,6 +603,191 @@ func TestSubmitListen_MatchNoMbzIDs(t *testing.T) {
 	assert.Equal(t, 1, count, "duplicate track created or has been associated with fake musicbrainz id")
 }
 
+func TestSubmitListen_MatchFeaturedTrackTitle(t *testing.T) {
+	setupTestDataSansMbzIDs(t)
+
+	// track title with featured artists gets matched to the existing track without them
+
+	ctx := context.Background()
+	mbzc := &mbz.MbzMockCaller{}
+	opts := catalog.SubmitListenOpts{
+		MbzCaller:    mbzc,
+		Artist:       "ATARASHII GAKKO!",
+		TrackTitle:   "Tokyo Calling (feat. Fake Artist)",
+		ReleaseTitle: "AG! Calling",
+		Time:         time.Now(),
+		UserID:       1,
+	}
+
+	err := catalog.SubmitListen(ctx, store, opts)
+	require.NoError(t, err)
+
+	count, err := store.Count(ctx, `SELECT COUNT(*) FROM tracks`)
+	require.NoError(t, err)
+	assert.Equal(t, 1, count, "duplicate track created")
+	exists, err := store.RowExists(ctx, `
+    SELECT EXISTS (
+      SELECT 1 FROM listens
+      WHERE track_id = $1
+    )`, 1)
+	require.NoError(t, err)
+	assert.True(t, exists, "expected listen to be saved to existing track")
+
+	// verify that the featured artist is credited as such, and the title is kept as an alias
+	exists, err = store.RowExists(ctx, `
+    SELECT EXISTS (
+      SELECT 1 FROM artist_tracks at
+      JOIN artists_with_name a ON a.id = at.artist_id
+      WHERE at.track_id = $1 AND a.name = $2 AND at.role = $3
+    )`, 1, "Fake Artist", "featured")
+	require.NoError(t, err)
+	assert.True(t, exists, "expected featured artist to be credited on track")
+	exists, err = store.RowExists(ctx, `
+    SELECT EXISTS (
+      SELECT 1 FROM artist_tracks
+      WHERE track_id = $1 AND artist_id = $2 AND role = $3
+    )`, 1, 1, "main")
+	require.NoError(t, err)
+	assert.True(t, exists, "expected main artist to stay credited as main artist")
+	exists, err = store.RowExists(ctx, `
+    SELECT EXISTS (
+      SELECT 1 FROM track_aliases
+      WHERE track_id = $1 AND alias = $2
+    )`, 1, opts.TrackTitle)
+	require.NoError(t, err)
+	assert.True(t, exists, "expected submitted title to be saved as alias")
+
+	// submitting again doesn't create duplicates
+	err = catalog.SubmitListen(ctx, store, opts)
+	require.NoError(t, err)
+	count, err = store.Count(ctx, `SELECT COUNT(*) FROM artist_tracks WHERE track_id = $1`, 1)
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+}
+
+func TestSubmitListen_AlbumArtist(t *testing.T) {
+	truncateTestData(t)
+
+	// tracks of a compilation by different artists get the same release, credited to the album artist
+
+	ctx := context.Background()
+	mbzc := &mbz.MbzMockCaller{}
+	for _, artist := range []string{"Track Artist One", "Track Artist Two"} {
+		err := catalog.SubmitListen(ctx, store, catalog.SubmitListenOpts{
+			MbzCaller:    mbzc,
+			Artist:       artist,
+			TrackTitle:   "Song by " + artist,
+			ReleaseTitle: "The Compilation",
+			AlbumArtist:  "Compiler",
+			Tags:         []string{"Synthpop", " synthpop", "Electronic"},
+			Time:         time.Now(),
+			UserID:       1,
+		})
+		require.NoError(t, err)
+	}
+
+	count, err := store.Count(ctx, `SELECT COUNT(*) FROM releases`)
+	require.NoError(t, err)
+	assert.Equal(t, 1, count, "expected tracks to share one release")
+	exists, err := store.RowExists(ctx, `
+    SELECT EXISTS (
+      SELECT 1 FROM artist_releases ar
+      JOIN artists_with_name a ON a.id = ar.artist_id
+      WHERE ar.release_id = $1 AND a.name = $2
+    )`, 1, "Compiler")
+	require.NoError(t, err)
+	assert.True(t, exists, "expected release to be credited to album artist")
+	count, err = store.Count(ctx, `SELECT COUNT(*) FROM artist_releases WHERE release_id = $1`, 1)
+	require.NoError(t, err)
+	assert.Equal(t, 1, count, "expected track artists not to be credited on release")
+	exists, err = store.RowExists(ctx, `
+    SELECT EXISTS (
+      SELECT 1 FROM artist_tracks at
+      JOIN artists_with_name a ON a.id = at.artist_id
+      WHERE a.name = $1
+    )`, "Compiler")
+	require.NoError(t, err)
+	assert.False(t, exists, "expected album artist not to be credited on tracks")
+
+	// tags are saved as genres of the release
+	count, err = store.Count(ctx, `
+	SELECT COUNT(*) FROM release_genres rg
+	JOIN genres g ON g.id = rg.genre_id
+	WHERE rg.release_id = $1 AND g.name IN ('synthpop', 'electronic')
+	`, 1)
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+
+	// various artists are not created as an artist
+	truncateTestData(t)
+	err = catalog.SubmitListen(ctx, store, catalog.SubmitListenOpts{
+		MbzCaller:    mbzc,
+		Artist:       "Track Artist One",
+		TrackTitle:   "Song",
+		ReleaseTitle: "The Compilation",
+		AlbumArtist:  "Various Artists",
+		Time:         time.Now(),
+		UserID:       1,
+	})
+	require.NoError(t, err)
+	exists, err = store.RowExists(ctx, `
+    SELECT EXISTS (
+      SELECT 1 FROM artists_with_name
+      WHERE name = $1
+    )`, "Various Artists")
+	require.NoError(t, err)
+	assert.False(t, exists, "expected no artist to be created for various artists")
+	exists, err = store.RowExists(ctx, `
+    SELECT EXISTS (
+      SELECT 1 FROM releases
+      WHERE id = $1 AND various_artists = $2
+    )`, 1, true)
+	require.NoError(t, err)
+	assert.True(t, exists, "expected release to be marked as various artists")
+}
+
+func TestSubmitListen_ReleaseGroup(t *testing.T) {
+	truncateTestData(t)
+
+	// two editions of the same album end up in one release group
+
+	ctx := context.Background()
+	releaseGroupMbzID := uuid.MustParse("00000000-0000-0000-0000-000000000077")
+	mbzc := &mbz.MbzMockCaller{
+		ReleaseGroups: map[uuid.UUID]*mbz.MusicBrainzReleaseGroup{
+			releaseGroupMbzID: {ID: releaseGroupMbzID.String(), Title: "The Album"},
+		},
+	}
+	for i, title := range []string{"The Album", "The Album (Deluxe Edition)"} {
+		err := catalog.SubmitListen(ctx, store, catalog.SubmitListenOpts{
+			MbzCaller:         mbzc,
+			Artist:            "Some Artist",
+			TrackTitle:        "Some Song",
+			ReleaseTitle:      title,
+			ReleaseMbzID:      uuid.MustParse(fmt.Sprintf("00000000-0000-0000-0000-00000000070%d", i)),
+			ReleaseGroupMbzID: releaseGroupMbzID,
+			Time:              time.Now().Add(time.Duration(i) * time.Minute),
+			UserID:            1,
+		})
+		require.NoError(t, err)
+	}
+
+	count, err := store.Count(ctx, `SELECT COUNT(*) FROM releases`)
+	require.NoError(t, err)
+	assert.Equal(t, 2, count, "expected editions to be separate releases")
+	count, err = store.Count(ctx, `SELECT COUNT(*) FROM release_groups WHERE title = $1`, "The Album")
+	require.NoError(t, err)
+	assert.Equal(t, 1, count, "expected one release group titled after MusicBrainz")
+	count, err = store.Count(ctx, `SELECT COUNT(*) FROM releases WHERE release_group_id = $1`, 1)
+	require.NoError(t, err)
+	assert.Equal(t, 2, count, "expected both editions to be part of the release group")
+
+	rg, err := store.GetReleaseGroup(ctx, db.GetReleaseGroupOpts{UserID: 1, MusicBrainzID: releaseGroupMbzID})
+	require.NoError(t, err)
+	assert.Len(t, rg.Editions, 2)
+	assert.EqualValues(t, 2, rg.ListenCount)
+}
+
 func TestSubmitListen_UpdateTrackDuration(t *testing.T) {
 	setupTestDataSansMbzIDs(t)
 
@@ -1037,3 +1223,49 @@ func TestSubmitListen_MusicBrainzUnreachableMBIDMappings(t *testing.T) {
 	require.NoError(t, err)
 	assert.True(t, exists, "expected artist to have correct musicbrainz id")
 }
+
+func TestSubmitListens_Atomic(t *testing.T) {
+	truncateTestData(t)
+
+	// no listen of the batch is saved when one of them fails
+
+	ctx := context.Background()
+	mbzc := &mbz.MbzErrorCaller{}
+	batch := []catalog.SubmitListenOpts{
+		{
+			MbzCaller:    mbzc,
+			Artist:       "ATARASHII GAKKO!",
+			TrackTitle:   "Tokyo Calling",
+			ReleaseTitle: "AG! Calling",
+			Time:         time.Now().Add(-1 * time.Hour),
+			UserID:       1,
+		},
+		{
+			MbzCaller:    mbzc,
+			Artist:       "ATARASHII GAKKO!",
+			TrackTitle:   "Drive",
+			ReleaseTitle: "AG! Calling",
+			Time:         time.Now(),
+			// user does not exist
+			UserID: 999,
+		},
+	}
+
+	err := catalog.SubmitListens(ctx, store, batch)
+	require.Error(t, err)
+
+	count, err := store.Count(ctx, `SELECT COUNT(*) FROM listens`)
+	require.NoError(t, err)
+	assert.Equal(t, 0, count)
+	count, err = store.Count(ctx, `SELECT COUNT(*) FROM artists`)
+	require.NoError(t, err)
+	assert.Equal(t, 0, count)
+
+	batch[1].UserID = 1
+	err = catalog.SubmitListens(ctx, store, batch)
+	require.NoError(t, err)
+
+	count, err = store.Count(ctx, `SELECT COUNT(*) FROM listens`)
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+}
@@ -3,7 +3,9 @@ package cfg
 import (
 	"errors"
 	"fmt"
+	"net/netip"
 	"regexp"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
@@ -27,6 +29,7 @@ const (
 	ENABLE_LBZ_RELAY_ENV           = "KOITO_ENABLE_LBZ_RELAY"
 	LBZ_RELAY_URL_ENV              = "KOITO_LBZ_RELAY_URL"
 	LBZ_RELAY_TOKEN_ENV            = "KOITO_LBZ_RELAY_TOKEN"
+	RELAY_PRIVATE_TARGETS_ENV      = "KOITO_RELAY_ALLOW_PRIVATE_TARGETS"
 	CONFIG_DIR_ENV                 = "KOITO_CONFIG_DIR"
 	DEFAULT_USERNAME_ENV           = "KOITO_DEFAULT_USERNAME"
 	DEFAULT_PASSWORD_ENV           = "KOITO_DEFAULT_PASSWORD"
@@ -46,6 +49,8 @@ const (
 	IMPORT_AFTER_UNIX_ENV          = "KOITO_IMPORT_AFTER_UNIX"
 	FETCH_IMAGES_DURING_IMPORT_ENV = "KOITO_FETCH_IMAGES_DURING_IMPORT"
 	ARTIST_SEPARATORS_ENV          = "KOITO_ARTIST_SEPARATORS_REGEX"
+	FEAT_PATTERNS_ENV              = "KOITO_FEAT_PATTERNS_REGEX"
+	REMIX_PATTERNS_ENV             = "KOITO_REMIX_PATTERNS_REGEX"
 	LOGIN_GATE_ENV                 = "KOITO_LOGIN_GATE"
 	SPOTIFY_CLIENT_ID_ENV          = "KOITO_SPOTIFY_CLIENT_ID"
 	SPOTIFY_CLIENT_SECRET_ENV      = "KOITO_SPOTIFY_CLIENT_SECRET"
@@ -53,6 +58,18 @@ const (
 	DISCOGS_CONSUMER_KEY_ENV       = "KOITO_DISCOGS_CONSUMER_KEY"
 	DISCOGS_CONSUMER_SECRET_ENV    = "KOITO_DISCOGS_CONSUMER_SECRET"
 	FORCE_TZ                       = "KOITO_FORCE_TZ"
+	DEDUPE_WINDOW_ENV              = "KOITO_DEDUPE_WINDOW"
+	OIDC_ISSUER_ENV                = "KOITO_OIDC_ISSUER"
+	OIDC_CLIENT_ID_ENV             = "KOITO_OIDC_CLIENT_ID"
+	OIDC_CLIENT_SECRET_ENV         = "KOITO_OIDC_CLIENT_SECRET"
+	OIDC_REDIRECT_URL_ENV          = "KOITO_OIDC_REDIRECT_URL"
+	OIDC_SCOPES_ENV                = "KOITO_OIDC_SCOPES"
+	OIDC_USERNAME_CLAIM_ENV        = "KOITO_OIDC_USERNAME_CLAIM"
+	OIDC_GROUPS_CLAIM_ENV          = "KOITO_OIDC_GROUPS_CLAIM"
+	OIDC_ADMIN_GROUP_ENV           = "KOITO_OIDC_ADMIN_GROUP"
+	OIDC_AUTO_PROVISION_ENV        = "KOITO_OIDC_AUTO_PROVISION"
+	TRUSTED_PROXY_HEADER_ENV       = "KOITO_TRUSTED_PROXY_HEADER"
+	TRUSTED_PROXIES_ENV            = "KOITO_TRUSTED_PROXIES"
 )
 
 type config struct {
@@ -68,6 +85,7 @@ type config struct {
 	lbzRelayEnabled       bool
 	lbzRelayUrl           string
 	lbzRelayToken         string
+	relayAllowPrivate     bool
 	defaultPw             string
 	defaultUsername       string
 	defaultTheme          string
@@ -89,6 +107,8 @@ type config struct {
 	importBefore          time.Time
 	importAfter           time.Time
 	artistSeparators      []*regexp.Regexp
+	featPatterns          []*regexp.Regexp
+	remixPatterns         []*regexp.Regexp
 	loginGate             bool
 	spotifyClientID       string
 	spotifyClientSecret   string
@@ -99,6 +119,20 @@ type config struct {
 	discogsEnabled        bool
 	lastfmEnabled         bool
 	forceTZ               *time.Location
+	dedupeByDuration      bool
+	dedupeWindow          time.Duration
+	oidcEnabled           bool
+	oidcIssuer            string
+	oidcClientID          string
+	oidcClientSecret      string
+	oidcRedirectURL       string
+	oidcScopes            []string
+	oidcUsernameClaim     string
+	oidcGroupsClaim       string
+	oidcAdminGroup        string
+	oidcAutoProvision     bool
+	trustedProxyHeader    string
+	trustedProxies        []netip.Prefix
 }
 
 var (
@@ -152,6 +186,7 @@ func loadConfig(getenv func(string) string, version string) (*config, error) {
 		cfg.lbzRelayToken = getenv(LBZ_RELAY_TOKEN_ENV)
 		cfg.lbzRelayUrl = getenv(LBZ_RELAY_URL_ENV)
 	}
+	cfg.relayAllowPrivate = parseBool(getenv(RELAY_PRIVATE_TARGETS_ENV))
 
 	beforeutx, _ := strconv.ParseInt(getenv(IMPORT_BEFORE_UNIX_ENV), 10, 64)
 	afterutx, _ := strconv.ParseInt(getenv(IMPORT_AFTER_UNIX_ENV), 10, 64)
@@ -170,6 +205,11 @@ func loadConfig(getenv func(string) string, version string) (*config, error) {
 		}
 	}
 
+	cfg.dedupeByDuration, cfg.dedupeWindow, err = ParseDedupeWindow(getenv(DEDUPE_WINDOW_ENV))
+	if err != nil {
+		return nil, fmt.Errorf("loadConfig: invalid %s value: %w", DEDUPE_WINDOW_ENV, err)
+	}
+
 	cfg.disableRateLimit = parseBool(getenv(DISABLE_RATE_LIMIT_ENV))
 	cfg.structuredLogging = parseBool(getenv(ENABLE_STRUCTURED_LOGGING_ENV))
 	cfg.fetchImageDuringImport = parseBool(getenv(FETCH_IMAGES_DURING_IMPORT_ENV))
@@ -228,6 +268,24 @@ func loadConfig(getenv func(string) string, version string) (*config, error) {
 		cfg.artistSeparators = []*regexp.Regexp{regexp.MustCompile(`\s+·\s+`)}
 	}
 
+	if cfg.featPatterns, err = parseRegexList(getenv(FEAT_PATTERNS_ENV)); err != nil {
+		return nil, err
+	} else if cfg.featPatterns == nil {
+		cfg.featPatterns = []*regexp.Regexp{
+			regexp.MustCompile(`(?i)\(feat\. ([^)]*)\)`),
+			regexp.MustCompile(`(?i)\[feat\. ([^\]]*)\]`),
+			regexp.MustCompile(`(?i)\bfeat\. ([^()\[\]]+)$`),
+		}
+	}
+
+	if cfg.remixPatterns, err = parseRegexList(getenv(REMIX_PATTERNS_ENV)); err != nil {
+		return nil, err
+	} else if cfg.remixPatterns == nil {
+		cfg.remixPatterns = []*regexp.Regexp{
+			regexp.MustCompile(`(?i)[(\[]([^()\[\]]+?)\s+remix[)\]]`),
+		}
+	}
+
 	if strings.ToLower(getenv(LOGIN_GATE_ENV)) == "true" {
 		cfg.loginGate = true
 	}
@@ -256,6 +314,45 @@ func loadConfig(getenv func(string) string, version string) (*config, error) {
 
 	cfg.secureCookies = parseBool(getenv(SECURE_COOKIES_ENV))
 
+	cfg.oidcIssuer = strings.TrimSuffix(getenv(OIDC_ISSUER_ENV), "/")
+	cfg.oidcClientID = getenv(OIDC_CLIENT_ID_ENV)
+	cfg.oidcClientSecret = getenv(OIDC_CLIENT_SECRET_ENV)
+	if cfg.oidcIssuer != "" || cfg.oidcClientID != "" || cfg.oidcClientSecret != "" {
+		if cfg.oidcIssuer == "" || cfg.oidcClientID == "" || cfg.oidcClientSecret == "" {
+			return nil, fmt.Errorf("loadConfig: %s, %s and %s must all be set to use OpenID Connect", OIDC_ISSUER_ENV, OIDC_CLIENT_ID_ENV, OIDC_CLIENT_SECRET_ENV)
+		}
+		cfg.oidcEnabled = true
+	}
+	cfg.oidcRedirectURL = getenv(OIDC_REDIRECT_URL_ENV)
+	cfg.oidcScopes = strings.Fields(getenv(OIDC_SCOPES_ENV))
+	if len(cfg.oidcScopes) == 0 {
+		cfg.oidcScopes = []string{"openid", "profile", "email"}
+	} else if !slices.Contains(cfg.oidcScopes, "openid") {
+		cfg.oidcScopes = append([]string{"openid"}, cfg.oidcScopes...)
+	}
+	cfg.oidcUsernameClaim = getenv(OIDC_USERNAME_CLAIM_ENV)
+	if cfg.oidcUsernameClaim == "" {
+		cfg.oidcUsernameClaim = "preferred_username"
+	}
+	cfg.oidcGroupsClaim = getenv(OIDC_GROUPS_CLAIM_ENV)
+	if cfg.oidcGroupsClaim == "" {
+		cfg.oidcGroupsClaim = "groups"
+	}
+	cfg.oidcAdminGroup = getenv(OIDC_ADMIN_GROUP_ENV)
+	cfg.oidcAutoProvision = parseBool(getenv(OIDC_AUTO_PROVISION_ENV))
+
+	cfg.trustedProxyHeader = strings.TrimSpace(getenv(TRUSTED_PROXY_HEADER_ENV))
+	for _, raw := range parseCSVList(getenv(TRUSTED_PROXIES_ENV)) {
+		prefix, err := parseProxyPrefix(raw)
+		if err != nil {
+			return nil, fmt.Errorf("loadConfig: %s contains an invalid address or CIDR '%s'", TRUSTED_PROXIES_ENV, raw)
+		}
+		cfg.trustedProxies = append(cfg.trustedProxies, prefix)
+	}
+	if cfg.trustedProxyHeader != "" && len(cfg.trustedProxies) == 0 {
+		return nil, fmt.Errorf("loadConfig: %s must be set to use %s", TRUSTED_PROXIES_ENV, TRUSTED_PROXY_HEADER_ENV)
+	}
+
 	if getenv(FORCE_TZ) != "" {
 		cfg.forceTZ, err = time.LoadLocation(getenv(FORCE_TZ))
 		if err != nil {
@@ -279,10 +376,40 @@ func loadConfig(getenv func(string) string, version string) (*config, error) {
 	return cfg, nil
 }
 
+// ParseDedupeWindow parses a duplicate listen window, which is either 'off', 'duration',
+// or a number of seconds.
+func ParseDedupeWindow(s string) (byDuration bool, window time.Duration, err error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	switch s {
+	case "", "off", "false":
+		return false, 0, nil
+	case "duration":
+		return true, 0, nil
+	}
+	secs, err := strconv.Atoi(s)
+	if err != nil || secs < 0 {
+		return false, 0, fmt.Errorf("%q is not 'off', 'duration', or a number of seconds", s)
+	}
+	return false, time.Duration(secs) * time.Second, nil
+}
+
 func parseBool(s string) bool {
 	return strings.ToLower(s) == "true"
 }
 
+// parseProxyPrefix accepts either a CIDR or a single address.
+func parseProxyPrefix(s string) (netip.Prefix, error) {
+	if strings.Contains(s, "/") {
+		prefix, err := netip.ParsePrefix(s)
+		return prefix.Masked(), err
+	}
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
+
 func parseCSVList(s string) []string {
 	parts := strings.Split(s, ",")
 	result := make([]string, 0, len(parts))
@@ -296,6 +423,26 @@ func parseCSVList(s string) []string {
 	return result
 }
 
+// parseRegexList compiles a list of patterns separated by two semicolons, each of which must
+// capture the artist names it finds in its first group. Returns nil for an empty list.
+func parseRegexList(s string) ([]*regexp.Regexp, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, nil
+	}
+	var result []*regexp.Regexp
+	for pattern := range strings.SplitSeq(s, ";;") {
+		regex, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compile regex pattern %s", pattern)
+		}
+		if regex.NumSubexp() < 1 {
+			return nil, fmt.Errorf("regex pattern %s must capture the artist names in a group", pattern)
+		}
+		result = append(result, regex)
+	}
+	return result, nil
+}
+
 func SpotifyEnabled() bool {
 	lock.RLock()
 	defer lock.RUnlock()
@@ -2,6 +2,7 @@ package cfg
 
 import (
 	"fmt"
+	"net/netip"
 	"regexp"
 	"time"
 )
@@ -72,6 +73,12 @@ func LbzRelayToken() string {
 	return globalConfig.lbzRelayToken
 }
 
+func RelayAllowPrivateTargets() bool {
+	lock.RLock()
+	defer lock.RUnlock()
+	return globalConfig.relayAllowPrivate
+}
+
 func DefaultPassword() string {
 	lock.RLock()
 	defer lock.RUnlock()
@@ -193,6 +200,18 @@ func ArtistSeparators() []*regexp.Regexp {
 	return globalConfig.artistSeparators
 }
 
+func FeatPatterns() []*regexp.Regexp {
+	lock.RLock()
+	defer lock.RUnlock()
+	return globalConfig.featPatterns
+}
+
+func RemixPatterns() []*regexp.Regexp {
+	lock.RLock()
+	defer lock.RUnlock()
+	return globalConfig.remixPatterns
+}
+
 func LoginGate() bool {
 	lock.RLock()
 	defer lock.RUnlock()
@@ -204,3 +223,113 @@ func ForceTZ() *time.Location {
 	defer lock.RUnlock()
 	return globalConfig.forceTZ
 }
+
+// DedupeEnabled reports whether listens of the same track close together
+// in time should be treated as duplicates.
+func DedupeEnabled() bool {
+	lock.RLock()
+	defer lock.RUnlock()
+	return globalConfig.dedupeByDuration || globalConfig.dedupeWindow > 0
+}
+
+// DedupeByDuration reports whether the duplicate window is derived from
+// the duration of the track instead of a fixed number of seconds.
+func DedupeByDuration() bool {
+	lock.RLock()
+	defer lock.RUnlock()
+	return globalConfig.dedupeByDuration
+}
+
+func DedupeWindow() time.Duration {
+	lock.RLock()
+	defer lock.RUnlock()
+	return globalConfig.dedupeWindow
+}
+
+// OIDCEnabled reports whether single sign-on through an OpenID Connect provider is configured.
+func OIDCEnabled() bool {
+	lock.RLock()
+	defer lock.RUnlock()
+	return globalConfig.oidcEnabled
+}
+
+func OIDCIssuer() string {
+	lock.RLock()
+	defer lock.RUnlock()
+	return globalConfig.oidcIssuer
+}
+
+func OIDCClientID() string {
+	lock.RLock()
+	defer lock.RUnlock()
+	return globalConfig.oidcClientID
+}
+
+func OIDCClientSecret() string {
+	lock.RLock()
+	defer lock.RUnlock()
+	return globalConfig.oidcClientSecret
+}
+
+// OIDCRedirectURL is the callback URL registered with the provider. When empty, it is
+// derived from the request that starts the login.
+func OIDCRedirectURL() string {
+	lock.RLock()
+	defer lock.RUnlock()
+	return globalConfig.oidcRedirectURL
+}
+
+func OIDCScopes() []string {
+	lock.RLock()
+	defer lock.RUnlock()
+	return globalConfig.oidcScopes
+}
+
+func OIDCUsernameClaim() string {
+	lock.RLock()
+	defer lock.RUnlock()
+	return globalConfig.oidcUsernameClaim
+}
+
+func OIDCGroupsClaim() string {
+	lock.RLock()
+	defer lock.RUnlock()
+	return globalConfig.oidcGroupsClaim
+}
+
+// OIDCAdminGroup is the group whose members are made admins when they sign in. When empty,
+// roles are not managed by the provider.
+func OIDCAdminGroup() string {
+	lock.RLock()
+	defer lock.RUnlock()
+	return globalConfig.oidcAdminGroup
+}
+
+// OIDCAutoProvision reports whether users who sign in through the provider without a
+// matching account should have one created for them.
+func OIDCAutoProvision() bool {
+	lock.RLock()
+	defer lock.RUnlock()
+	return globalConfig.oidcAutoProvision
+}
+
+// TrustedProxyHeader returns the header that trusted proxies pass the authenticated username
+// in. Returns an empty string when header authentication is disabled.
+func TrustedProxyHeader() string {
+	lock.RLock()
+	defer lock.RUnlock()
+	return globalConfig.trustedProxyHeader
+}
+
+// IsTrustedProxy reports whether addr belongs to one of the configured trusted proxies.
+func IsTrustedProxy(addr netip.Addr) bool {
+	lock.RLock()
+	defer lock.RUnlock()
+	addr = addr.Unmap()
+	for _, prefix := range globalConfig.trustedProxies {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
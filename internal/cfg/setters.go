@@ -1,7 +1,16 @@
 package cfg
 
+import "time"
+
 func SetLoginGate(val bool) {
 	lock.Lock()
 	defer lock.Unlock()
 	globalConfig.loginGate = val
 }
+
+func SetDedupeWindow(byDuration bool, window time.Duration) {
+	lock.Lock()
+	defer lock.Unlock()
+	globalConfig.dedupeByDuration = byDuration
+	globalConfig.dedupeWindow = window
+}
@@ -15,6 +15,7 @@ type DB interface {
 	GetArtist(ctx context.Context, opts GetArtistOpts) (*models.Artist, error)
 	GetAlbum(ctx context.Context, opts GetAlbumOpts) (*models.Album, error)
 	GetAlbumWithNoMbzIDByTitles(ctx context.Context, artistId int32, titles []string) (*models.Album, error)
+	GetReleaseGroup(ctx context.Context, opts GetReleaseGroupOpts) (*models.ReleaseGroup, error)
 	GetTrack(ctx context.Context, opts GetTrackOpts) (*models.Track, error)
 	GetTracksWithNoDurationButHaveMbzID(ctx context.Context, from int32) ([]*models.Track, error)
 	GetArtistsForAlbum(ctx context.Context, id int32) ([]*models.Artist, error)
@@ -23,15 +24,37 @@ type DB interface {
 	GetTopArtistsPaginated(ctx context.Context, opts GetItemsOpts) (*PaginatedResponse[RankedItem[*models.Artist]], error)
 	GetTopAlbumsPaginated(ctx context.Context, opts GetItemsOpts) (*PaginatedResponse[RankedItem[*models.Album]], error)
 	GetListensPaginated(ctx context.Context, opts GetItemsOpts) (*PaginatedResponse[*models.Listen], error)
+	GetUserListens(ctx context.Context, opts GetUserListensOpts) ([]*models.Listen, error)
 	GetListenActivity(ctx context.Context, opts ListenActivityOpts) ([]ListenActivityItem, error)
 	GetAllArtistAliases(ctx context.Context, id int32) ([]models.Alias, error)
 	GetAllAlbumAliases(ctx context.Context, id int32) ([]models.Alias, error)
 	GetAllTrackAliases(ctx context.Context, id int32) ([]models.Alias, error)
 	GetApiKeysByUserID(ctx context.Context, id int32) ([]models.ApiKey, error)
 	GetUserBySession(ctx context.Context, sessionId uuid.UUID) (*models.User, error)
+	GetSessionsByUserID(ctx context.Context, userID int32) ([]*models.Session, error)
 	GetUserByUsername(ctx context.Context, username string) (*models.User, error)
 	GetUserByApiKey(ctx context.Context, key string) (*models.User, error)
+	GetUserByAudioscrobblerSession(ctx context.Context, token string) (*models.User, error)
+	GetApiKey(ctx context.Context, key string) (*models.ApiKey, error)
+	GetUserByOIDCSubject(ctx context.Context, subject string) (*models.User, error)
+	GetUserByID(ctx context.Context, id int32) (*models.User, error)
+	GetUsers(ctx context.Context) ([]*models.User, error)
+	GetPublicUsers(ctx context.Context) ([]*models.User, error)
+	GetUserInvites(ctx context.Context) ([]*models.UserInvite, error)
 	GetInterest(ctx context.Context, opts GetInterestOpts) ([]InterestBucket, error)
+	GetAuditEntriesPaginated(ctx context.Context, opts GetAuditEntriesOpts) (*PaginatedResponse[*models.AuditEntry], error)
+	GetRelayEntry(ctx context.Context, id int32) (*models.RelayEntry, error)
+	GetDueRelayEntries(ctx context.Context, limit int32) ([]*models.RelayEntry, error)
+	GetRelayEntriesPaginated(ctx context.Context, opts GetRelayEntriesOpts) (*PaginatedResponse[*models.RelayEntry], error)
+	GetRelayTarget(ctx context.Context, id int32) (*models.RelayTarget, error)
+	GetRelayTargetsByUserID(ctx context.Context, userID int32) ([]*models.RelayTarget, error)
+	GetRewriteRule(ctx context.Context, id int32) (*models.RewriteRule, error)
+	GetRewriteRulesByUserID(ctx context.Context, userID int32) ([]*models.RewriteRule, error)
+	GetUserListensToTrackInRange(ctx context.Context, opts ListensInRangeOpts) ([]ListenRecord, error)
+	GetDuplicateListenCandidates(ctx context.Context, opts DuplicateListenOpts) ([]DuplicateCandidate, error)
+	// LockUserTrackListens blocks other transactions from saving listens to the track by the
+	// user until the current transaction ends. Has no effect outside of a transaction.
+	LockUserTrackListens(ctx context.Context, userID, trackID int32) error
 
 	// Save
 
@@ -41,12 +64,20 @@ type DB interface {
 	SaveAlbum(ctx context.Context, opts SaveAlbumOpts) (*models.Album, error)
 	SaveAlbumAliases(ctx context.Context, id int32, aliases []string, source string) error
 	SaveAlbumGenres(ctx context.Context, albumID int32, genres []string) error
+	SaveReleaseGroup(ctx context.Context, opts SaveReleaseGroupOpts) (*models.ReleaseGroup, error)
 	SaveTrack(ctx context.Context, opts SaveTrackOpts) (*models.Track, error)
 	SaveTrackAliases(ctx context.Context, id int32, aliases []string, source string) error
 	SaveListen(ctx context.Context, opts SaveListenOpts) error
 	SaveUser(ctx context.Context, opts SaveUserOpts) (*models.User, error)
+	SaveUserInvite(ctx context.Context, opts SaveUserInviteOpts) (*models.UserInvite, error)
+	RedeemUserInvite(ctx context.Context, opts RedeemUserInviteOpts) (*models.User, error)
 	SaveApiKey(ctx context.Context, opts SaveApiKeyOpts) (*models.ApiKey, error)
-	SaveSession(ctx context.Context, userId int32, expiresAt time.Time, persistent bool) (*models.Session, error)
+	SaveSession(ctx context.Context, opts SaveSessionOpts) (*models.Session, error)
+	SaveAudioscrobblerSession(ctx context.Context, apiKeyID int32, token string) error
+	SaveAuditEntry(ctx context.Context, opts SaveAuditEntryOpts) (*models.AuditEntry, error)
+	SaveRelayEntry(ctx context.Context, opts SaveRelayEntryOpts) (*models.RelayEntry, error)
+	SaveRelayTarget(ctx context.Context, opts SaveRelayTargetOpts) (*models.RelayTarget, error)
+	SaveRewriteRule(ctx context.Context, opts SaveRewriteRuleOpts) (*models.RewriteRule, error)
 
 	// Update
 
@@ -54,46 +85,70 @@ type DB interface {
 	UpdateTrack(ctx context.Context, opts UpdateTrackOpts) error
 	UpdateAlbum(ctx context.Context, opts UpdateAlbumOpts) error
 	AddArtistsToAlbum(ctx context.Context, opts AddArtistsToAlbumOpts) error
+	SetAlbumReleaseGroup(ctx context.Context, opts SetAlbumReleaseGroupOpts) error
+	AddArtistsToTrack(ctx context.Context, opts AddArtistsToTrackOpts) error
 	UpdateUser(ctx context.Context, opts UpdateUserOpts) error
 	UpdateApiKeyLabel(ctx context.Context, opts UpdateApiKeyLabelOpts) error
-	RefreshSession(ctx context.Context, sessionId uuid.UUID, expiresAt time.Time) error
+	UpdateApiKeyLastUsed(ctx context.Context, id int32, ip string) error
+	SetUserTOTPSecret(ctx context.Context, userID int32, secret string) error
+	EnableUserTOTP(ctx context.Context, userID int32, recoveryCodes []string) error
+	DisableUserTOTP(ctx context.Context, userID int32) error
+	UseTOTPStep(ctx context.Context, userID int32, step int64) (bool, error)
+	UseTOTPRecoveryCode(ctx context.Context, userID int32, code string) (bool, error)
+	RefreshSession(ctx context.Context, opts RefreshSessionOpts) error
 	SetPrimaryArtistAlias(ctx context.Context, id int32, alias string) error
 	SetPrimaryAlbumAlias(ctx context.Context, id int32, alias string) error
 	SetPrimaryTrackAlias(ctx context.Context, id int32, alias string) error
 	SetPrimaryAlbumArtist(ctx context.Context, id int32, artistId int32, value bool) error
 	SetPrimaryTrackArtist(ctx context.Context, id int32, artistId int32, value bool) error
+	SetTrackArtistRole(ctx context.Context, id int32, artistId int32, role models.ArtistRole) error
+	UpdateRelayEntryAttempt(ctx context.Context, opts UpdateRelayEntryAttemptOpts) error
+	RetryRelayEntry(ctx context.Context, id int32) error
+	RetryDeadRelayEntries(ctx context.Context) (int64, error)
+	UpdateRelayTarget(ctx context.Context, opts UpdateRelayTargetOpts) error
+	UpdateRewriteRule(ctx context.Context, opts UpdateRewriteRuleOpts) error
 
 	// Delete
 
 	DeleteArtist(ctx context.Context, id int32) error
 	DeleteAlbum(ctx context.Context, id int32) error
 	DeleteTrack(ctx context.Context, id int32) error
-	DeleteListen(ctx context.Context, trackId int32, listenedAt time.Time) error
+	DeleteListen(ctx context.Context, userId int32, trackId int32, listenedAt time.Time) error
 	DeleteArtistAlias(ctx context.Context, id int32, alias string) error
 	DeleteAlbumAlias(ctx context.Context, id int32, alias string) error
 	DeleteTrackAlias(ctx context.Context, id int32, alias string) error
 	DeleteSession(ctx context.Context, sessionId uuid.UUID) error
+	DeleteSessionForUser(ctx context.Context, userID int32, sessionId uuid.UUID) (bool, error)
+	DeleteOtherSessionsForUser(ctx context.Context, userID int32, keep uuid.UUID) (int64, error)
+	DeleteExpiredSessions(ctx context.Context) (int64, error)
+	DeleteUser(ctx context.Context, id int32) error
+	DeleteUserInvite(ctx context.Context, id int32) error
 	DeleteApiKey(ctx context.Context, id int32) error
+	DeleteRelayEntry(ctx context.Context, id int32) error
+	PurgeRelayEntries(ctx context.Context, status models.RelayStatus) (int64, error)
+	DeleteRelayTarget(ctx context.Context, id int32, userID int32) error
+	DeleteRewriteRule(ctx context.Context, id int32, userID int32) error
 
 	// Count
 
-	CountListens(ctx context.Context, timeframe Timeframe) (int64, error)
+	CountListens(ctx context.Context, userID int32, timeframe Timeframe) (int64, error)
+	CountUserListens(ctx context.Context, userID int32) (int64, error)
 	CountListensToItem(ctx context.Context, opts TimeListenedOpts) (int64, error)
-	CountTracks(ctx context.Context, timeframe Timeframe) (int64, error)
-	CountAlbums(ctx context.Context, timeframe Timeframe) (int64, error)
-	CountArtists(ctx context.Context, timeframe Timeframe) (int64, error)
-	CountNewTracks(ctx context.Context, timeframe Timeframe) (int64, error)
-	CountNewAlbums(ctx context.Context, timeframe Timeframe) (int64, error)
-	CountNewArtists(ctx context.Context, timeframe Timeframe) (int64, error)
+	CountTracks(ctx context.Context, userID int32, timeframe Timeframe) (int64, error)
+	CountAlbums(ctx context.Context, userID int32, timeframe Timeframe) (int64, error)
+	CountArtists(ctx context.Context, userID int32, timeframe Timeframe) (int64, error)
+	CountNewTracks(ctx context.Context, userID int32, timeframe Timeframe) (int64, error)
+	CountNewAlbums(ctx context.Context, userID int32, timeframe Timeframe) (int64, error)
+	CountNewArtists(ctx context.Context, userID int32, timeframe Timeframe) (int64, error)
 	// in seconds
-	CountTimeListened(ctx context.Context, timeframe Timeframe) (int64, error)
+	CountTimeListened(ctx context.Context, userID int32, timeframe Timeframe) (int64, error)
 	// in seconds
 	CountTimeListenedToItem(ctx context.Context, opts TimeListenedOpts) (int64, error)
 	CountUsers(ctx context.Context) (int64, error)
 
 	// Genre Stats
-	GetGenreStatsByListenCount(ctx context.Context, timeframe Timeframe) ([]GenreStat, error)
-	GetGenreStatsByTimeListened(ctx context.Context, timeframe Timeframe) ([]GenreStat, error)
+	GetGenreStatsByListenCount(ctx context.Context, userID int32, timeframe Timeframe) ([]GenreStat, error)
+	GetGenreStatsByTimeListened(ctx context.Context, userID int32, timeframe Timeframe) ([]GenreStat, error)
 	// Wrapped
 	GetWrappedStats(ctx context.Context, year int, userID int32) (*WrappedStats, error)
 	// Recommendation
@@ -107,9 +162,13 @@ type DB interface {
 
 	// Merge
 
-	MergeTracks(ctx context.Context, fromId, toId int32) error
+	// returns the number of listens discarded as duplicates of listens of the target track
+	MergeTracks(ctx context.Context, fromId, toId int32) (int64, error)
 	MergeAlbums(ctx context.Context, fromId, toId int32, replaceImage bool) error
 	MergeArtists(ctx context.Context, fromId, toId int32, replaceImage bool) error
+	UnmergeTracks(ctx context.Context, fromId int32) error
+	UnmergeAlbums(ctx context.Context, fromId int32) error
+	UnmergeArtists(ctx context.Context, fromId int32) error
 
 	// Etc
 
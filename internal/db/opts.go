@@ -8,6 +8,8 @@ import (
 )
 
 type GetAlbumOpts struct {
+	// Scopes listen statistics to a user
+	UserID        int32
 	ID            int32
 	MusicBrainzID uuid.UUID
 	ArtistID      int32
@@ -17,6 +19,8 @@ type GetAlbumOpts struct {
 }
 
 type GetArtistOpts struct {
+	// Scopes listen statistics to a user
+	UserID        int32
 	ID            int32
 	MusicBrainzID uuid.UUID
 	Name          string
@@ -24,6 +28,8 @@ type GetArtistOpts struct {
 }
 
 type GetTrackOpts struct {
+	// Scopes listen statistics to a user
+	UserID        int32
 	ID            int32
 	MusicBrainzID uuid.UUID
 	Title         string
@@ -37,6 +43,8 @@ type SaveTrackOpts struct {
 	ArtistIDs      []int32
 	RecordingMbzID uuid.UUID
 	Duration       int32
+	// How each of ArtistIDs is credited, main when not present
+	ArtistRoles map[int32]models.ArtistRole
 }
 
 type SaveAlbumOpts struct {
@@ -70,10 +78,145 @@ type SaveUserOpts struct {
 	Role     models.UserRole
 }
 
+type SaveSessionOpts struct {
+	UserID     int32
+	ExpiresAt  time.Time
+	Persistent bool
+	UserAgent  string
+	IP         string
+}
+
+// RefreshSessionOpts extends a session and records the client it was last used from.
+type RefreshSessionOpts struct {
+	ID        uuid.UUID
+	ExpiresAt time.Time
+	UserAgent string
+	IP        string
+}
+
 type SaveApiKeyOpts struct {
 	Key    string
 	UserID int32
 	Label  string
+	// Defaults to models.ApiKeyScopeFull
+	Scope models.ApiKeyScope
+	// When zero, the key never expires
+	ExpiresAt time.Time
+	// Whether the key can be used with the Audioscrobbler 1.2 handshake, which requires
+	// keeping an unsalted MD5 digest of the key
+	Legacy bool
+}
+
+type SaveRelayEntryOpts struct {
+	UserID int32
+	// When 0, the entry is sent to the globally configured relay
+	TargetID int32
+	Payload  []byte
+}
+
+type SaveRelayTargetOpts struct {
+	UserID          int32
+	Kind            models.RelayTargetKind
+	Label           string
+	URL             string
+	Token           string
+	ApiKey          string
+	ApiSecret       string
+	Enabled         bool
+	RelayImports    bool
+	RelayPlayingNow bool
+	IncludeClients  []string
+	ExcludeClients  []string
+}
+
+type UpdateRelayTargetOpts struct {
+	ID              int32
+	UserID          int32
+	Label           string
+	URL             string
+	Token           string
+	ApiKey          string
+	ApiSecret       string
+	Enabled         bool
+	RelayImports    bool
+	RelayPlayingNow bool
+	IncludeClients  []string
+	ExcludeClients  []string
+}
+
+type UpdateRelayEntryAttemptOpts struct {
+	ID            int32
+	Status        models.RelayStatus
+	Attempts      int32
+	NextAttemptAt time.Time
+	LastError     string
+}
+
+type ListensInRangeOpts struct {
+	UserID  int32
+	TrackID int32
+	From    time.Time
+	To      time.Time
+}
+
+type DuplicateListenOpts struct {
+	// When true, the window of a track with a known duration is half of its duration
+	ByDuration bool
+	// Used for every track when ByDuration is false, and for tracks with no duration otherwise
+	Window time.Duration
+}
+
+type SaveAuditEntryOpts struct {
+	// 0 when the change was not made by a user
+	UserID     int32
+	Username   string
+	Action     models.AuditAction
+	TargetType models.AuditTarget
+	TargetIDs  []int32
+	// JSON encoded; nil is stored as null
+	Before []byte
+	After  []byte
+}
+
+type GetAuditEntriesOpts struct {
+	// Empty or zero values match every entry
+	Action     models.AuditAction
+	TargetType models.AuditTarget
+	TargetID   int32
+	UserID     int32
+	Page       int
+	Limit      int
+}
+
+type GetRelayEntriesOpts struct {
+	// When empty, entries of any status are returned
+	Status models.RelayStatus
+	Page   int
+	Limit  int
+}
+
+type SaveRewriteRuleOpts struct {
+	UserID      int32
+	Enabled     bool
+	Field       models.RewriteField
+	MatchType   models.RewriteMatchType
+	Pattern     string
+	Action      models.RewriteAction
+	TargetField models.RewriteField
+	Replacement string
+}
+
+type UpdateRewriteRuleOpts struct {
+	ID          int32
+	UserID      int32
+	Position    int32
+	Enabled     bool
+	Field       models.RewriteField
+	MatchType   models.RewriteMatchType
+	Pattern     string
+	Action      models.RewriteAction
+	TargetField models.RewriteField
+	Replacement string
 }
 
 type SaveListenOpts struct {
@@ -106,7 +249,25 @@ type UpdateAlbumOpts struct {
 }
 
 type UpdateUserOpts struct {
-	ID       int32
+	ID         int32
+	Username   string
+	Password   string
+	Role       models.UserRole
+	Visibility models.ProfileVisibility
+	Disabled   *bool
+	// Links the user to an OpenID Connect identity
+	OIDCSubject string
+}
+
+type SaveUserInviteOpts struct {
+	Token     string
+	Role      models.UserRole
+	CreatedBy int32
+	ExpiresAt time.Time
+}
+
+type RedeemUserInviteOpts struct {
+	Token    string
 	Username string
 	Password string
 }
@@ -116,7 +277,47 @@ type AddArtistsToAlbumOpts struct {
 	ArtistIDs []int32
 }
 
+type GetReleaseGroupOpts struct {
+	// Scopes listen statistics to a user
+	UserID        int32
+	ID            int32
+	MusicBrainzID uuid.UUID
+	// Gets the release group of the album
+	AlbumID int32
+}
+
+type SaveReleaseGroupOpts struct {
+	Title         string
+	MusicBrainzID uuid.UUID
+}
+
+type SetAlbumReleaseGroupOpts struct {
+	AlbumID int32
+	// Removes the album from its release group when 0
+	ReleaseGroupID int32
+	// Leaves albums that are already part of a release group as they are
+	KeepExisting bool
+}
+
+type AddArtistsToTrackOpts struct {
+	TrackID   int32
+	ArtistIDs []int32
+	// How each of ArtistIDs is credited, main when not present
+	ArtistRoles map[int32]models.ArtistRole
+}
+
+// GetUserListensOpts pages through the listens of a user by time. Both times are
+// exclusive. When MinTime is set, the listens right after it are returned, otherwise the
+// listens right before MaxTime (or now). Listens are always ordered newest first.
+type GetUserListensOpts struct {
+	UserID  int32
+	MinTime time.Time
+	MaxTime time.Time
+	Limit   int
+}
+
 type GetItemsOpts struct {
+	UserID    int32
 	Limit     int
 	Timeframe Timeframe
 	Page      int
@@ -130,11 +331,19 @@ type GetItemsOpts struct {
 	ArtistID int
 	AlbumID  int
 
+	// Leaves out listens of tracks an artist is only featured on. Used for getting top
+	// artists and the top tracks of an artist
+	ExcludeFeatured bool
+
+	// Counts the editions of an album together. Used for getting top albums
+	ByReleaseGroup bool
+
 	// Used for getting listens
 	TrackID int
 }
 
 type ListenActivityOpts struct {
+	UserID   int32
 	Step     StepInterval
 	Range    int
 	Month    int
@@ -146,6 +355,7 @@ type ListenActivityOpts struct {
 }
 
 type TimeListenedOpts struct {
+	UserID    int32
 	Timeframe Timeframe
 	AlbumID   int32
 	ArtistID  int32
@@ -160,6 +370,7 @@ type GetExportPageOpts struct {
 }
 
 type GetInterestOpts struct {
+	UserID   int32
 	Buckets  int
 	AlbumID  int32
 	ArtistID int32
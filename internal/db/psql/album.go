@@ -66,6 +66,7 @@ func (d *Psql) GetAlbum(ctx context.Context, opts db.GetAlbumOpts) (*models.Albu
 	}
 
 	count, err := d.q.CountListensFromRelease(ctx, repository.CountListensFromReleaseParams{
+		UserID:       opts.UserID,
 		ListenedAt:   time.Unix(0, 0),
 		ListenedAt_2: time.Now(),
 		ReleaseID:    opts.ID,
@@ -75,6 +76,7 @@ func (d *Psql) GetAlbum(ctx context.Context, opts db.GetAlbumOpts) (*models.Albu
 	}
 
 	seconds, err := d.CountTimeListenedToItem(ctx, db.TimeListenedOpts{
+		UserID:    opts.UserID,
 		Timeframe: db.PeriodToTimeframe(db.PeriodAllTime),
 		AlbumID:   opts.ID,
 	})
@@ -82,12 +84,12 @@ func (d *Psql) GetAlbum(ctx context.Context, opts db.GetAlbumOpts) (*models.Albu
 		return nil, fmt.Errorf("GetAlbum: CountTimeListenedToItem: %w", err)
 	}
 
-	firstListen, err := d.q.GetFirstListenFromRelease(ctx, opts.ID)
+	firstListen, err := d.q.GetFirstListenFromRelease(ctx, repository.GetFirstListenFromReleaseParams{ReleaseID: opts.ID, UserID: opts.UserID})
 	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
 		return nil, fmt.Errorf("GetAlbum: GetFirstListenFromRelease: %w", err)
 	}
 
-	rank, err := d.q.GetReleaseAllTimeRank(ctx, opts.ID)
+	rank, err := d.q.GetReleaseAllTimeRank(ctx, repository.GetReleaseAllTimeRankParams{ReleaseID: opts.ID, UserID: opts.UserID})
 	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
 		return nil, fmt.Errorf("GetAlbum: GetReleaseAllTimeRank: %w", err)
 	}
@@ -97,6 +99,7 @@ func (d *Psql) GetAlbum(ctx context.Context, opts db.GetAlbumOpts) (*models.Albu
 	ret.Title = row.Title
 	ret.Image = row.Image
 	ret.VariousArtists = row.VariousArtists
+	ret.ReleaseGroupID = releaseGroupID(row.ReleaseGroupID)
 	err = json.Unmarshal(row.Artists, &ret.Artists)
 	if err != nil {
 		return nil, fmt.Errorf("GetAlbum: json.Unmarshal: %w", err)
@@ -130,32 +133,6 @@ func (d *Psql) GetAlbumWithNoMbzIDByTitles(ctx context.Context, artistId int32,
 	} else {
 		return nil, errors.New("GetAlbumWithNoMbzIDByTitles: insufficient information to get album")
 	}
-	count, err := d.q.CountListensFromRelease(ctx, repository.CountListensFromReleaseParams{
-		ListenedAt:   time.Unix(0, 0),
-		ListenedAt_2: time.Now(),
-		ReleaseID:    ret.ID,
-	})
-	if err != nil {
-		return nil, fmt.Errorf("GetAlbumWithNoMbzIDByTitles: CountListensFromRelease: %w", err)
-	}
-
-	seconds, err := d.CountTimeListenedToItem(ctx, db.TimeListenedOpts{
-		Timeframe: db.PeriodToTimeframe(db.PeriodAllTime),
-		AlbumID:   ret.ID,
-	})
-	if err != nil {
-		return nil, fmt.Errorf("GetAlbumWithNoMbzIDByTitles: CountTimeListenedToItem: %w", err)
-	}
-
-	firstListen, err := d.q.GetFirstListenFromRelease(ctx, ret.ID)
-	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
-		return nil, fmt.Errorf("GetAlbumWithNoMbzIDByTitles: GetFirstListenFromRelease: %w", err)
-	}
-
-	ret.ListenCount = count
-	ret.TimeListened = seconds
-	ret.FirstListen = firstListen.ListenedAt.Unix()
-
 	genres, err := d.getGenresForRelease(ctx, ret.ID)
 	if err != nil {
 		l.Warn().Err(err).Msgf("GetAlbum: failed to get genres for album %d", ret.ID)
@@ -27,6 +27,7 @@ func (d *Psql) GetArtist(ctx context.Context, opts db.GetArtistOpts) (*models.Ar
 			return nil, fmt.Errorf("GetArtist: GetArtist by ID: %w", err)
 		}
 		count, err := d.q.CountListensFromArtist(ctx, repository.CountListensFromArtistParams{
+			UserID:       opts.UserID,
 			ListenedAt:   time.Unix(0, 0),
 			ListenedAt_2: time.Now(),
 			ArtistID:     row.ID,
@@ -35,13 +36,14 @@ func (d *Psql) GetArtist(ctx context.Context, opts db.GetArtistOpts) (*models.Ar
 			return nil, fmt.Errorf("GetArtist: CountListensFromArtist: %w", err)
 		}
 		seconds, err := d.CountTimeListenedToItem(ctx, db.TimeListenedOpts{
+			UserID:    opts.UserID,
 			Timeframe: db.PeriodToTimeframe(db.PeriodAllTime),
 			ArtistID:  row.ID,
 		})
 		if err != nil {
 			return nil, fmt.Errorf("GetArtist: CountTimeListenedToItem: %w", err)
 		}
-		firstListen, err := d.q.GetFirstListenFromArtist(ctx, row.ID)
+		firstListen, err := d.q.GetFirstListenFromArtist(ctx, repository.GetFirstListenFromArtistParams{ArtistID: row.ID, UserID: opts.UserID})
 		if err != nil && !errors.Is(err, pgx.ErrNoRows) {
 			return nil, fmt.Errorf("GetAlbum: GetFirstListenFromArtist: %w", err)
 		}
@@ -67,6 +69,7 @@ func (d *Psql) GetArtist(ctx context.Context, opts db.GetArtistOpts) (*models.Ar
 			return nil, fmt.Errorf("GetArtist: GetArtistByMbzID: %w", err)
 		}
 		count, err := d.q.CountListensFromArtist(ctx, repository.CountListensFromArtistParams{
+			UserID:       opts.UserID,
 			ListenedAt:   time.Unix(0, 0),
 			ListenedAt_2: time.Now(),
 			ArtistID:     row.ID,
@@ -75,13 +78,14 @@ func (d *Psql) GetArtist(ctx context.Context, opts db.GetArtistOpts) (*models.Ar
 			return nil, fmt.Errorf("GetArtist: CountListensFromArtist: %w", err)
 		}
 		seconds, err := d.CountTimeListenedToItem(ctx, db.TimeListenedOpts{
+			UserID:    opts.UserID,
 			Timeframe: db.PeriodToTimeframe(db.PeriodAllTime),
 			ArtistID:  row.ID,
 		})
 		if err != nil {
 			return nil, fmt.Errorf("GetArtist: CountTimeListenedToItem: %w", err)
 		}
-		firstListen, err := d.q.GetFirstListenFromArtist(ctx, row.ID)
+		firstListen, err := d.q.GetFirstListenFromArtist(ctx, repository.GetFirstListenFromArtistParams{ArtistID: row.ID, UserID: opts.UserID})
 		if err != nil && !errors.Is(err, pgx.ErrNoRows) {
 			return nil, fmt.Errorf("GetAlbum: GetFirstListenFromArtist: %w", err)
 		}
@@ -114,6 +118,7 @@ func (d *Psql) GetArtist(ctx context.Context, opts db.GetArtistOpts) (*models.Ar
 			return nil, fmt.Errorf("GetArtist: GetArtistByName: %w", err)
 		}
 		count, err := d.q.CountListensFromArtist(ctx, repository.CountListensFromArtistParams{
+			UserID:       opts.UserID,
 			ListenedAt:   time.Unix(0, 0),
 			ListenedAt_2: time.Now(),
 			ArtistID:     row.ID,
@@ -122,13 +127,14 @@ func (d *Psql) GetArtist(ctx context.Context, opts db.GetArtistOpts) (*models.Ar
 			return nil, fmt.Errorf("GetArtist: CountListensFromArtist: %w", err)
 		}
 		seconds, err := d.CountTimeListenedToItem(ctx, db.TimeListenedOpts{
+			UserID:    opts.UserID,
 			Timeframe: db.PeriodToTimeframe(db.PeriodAllTime),
 			ArtistID:  row.ID,
 		})
 		if err != nil {
 			return nil, fmt.Errorf("GetArtist: CountTimeListenedToItem: %w", err)
 		}
-		firstListen, err := d.q.GetFirstListenFromArtist(ctx, row.ID)
+		firstListen, err := d.q.GetFirstListenFromArtist(ctx, repository.GetFirstListenFromArtistParams{ArtistID: row.ID, UserID: opts.UserID})
 		if err != nil && !errors.Is(err, pgx.ErrNoRows) {
 			return nil, fmt.Errorf("GetAlbum: GetFirstListenFromArtist: %w", err)
 		}
@@ -156,6 +162,7 @@ func (d *Psql) GetArtist(ctx context.Context, opts db.GetArtistOpts) (*models.Ar
 		return nil, fmt.Errorf("GetArtist: GetArtist by ID: %w", err)
 	}
 	count, err := d.q.CountListensFromArtist(ctx, repository.CountListensFromArtistParams{
+		UserID:       opts.UserID,
 		ListenedAt:   time.Unix(0, 0),
 		ListenedAt_2: time.Now(),
 		ArtistID:     row.ID,
@@ -164,17 +171,18 @@ func (d *Psql) GetArtist(ctx context.Context, opts db.GetArtistOpts) (*models.Ar
 		return nil, fmt.Errorf("GetArtist: CountListensFromArtist: %w", err)
 	}
 	seconds, err := d.CountTimeListenedToItem(ctx, db.TimeListenedOpts{
+		UserID:    opts.UserID,
 		Timeframe: db.PeriodToTimeframe(db.PeriodAllTime),
 		ArtistID:  row.ID,
 	})
 	if err != nil {
 		return nil, fmt.Errorf("GetArtist: CountTimeListenedToItem: %w", err)
 	}
-	firstListen, err := d.q.GetFirstListenFromArtist(ctx, row.ID)
+	firstListen, err := d.q.GetFirstListenFromArtist(ctx, repository.GetFirstListenFromArtistParams{ArtistID: row.ID, UserID: opts.UserID})
 	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
 		return nil, fmt.Errorf("GetAlbum: GetFirstListenFromArtist: %w", err)
 	}
-	rank, err := d.q.GetArtistAllTimeRank(ctx, opts.ID)
+	rank, err := d.q.GetArtistAllTimeRank(ctx, repository.GetArtistAllTimeRankParams{ArtistID: opts.ID, UserID: opts.UserID})
 	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
 		return nil, fmt.Errorf("GetArtist: GetArtistAllTimeRank: %w", err)
 	}
@@ -478,6 +486,7 @@ func (d *Psql) GetArtistsForTrack(ctx context.Context, id int32) ([]*models.Arti
 			MbzID:     row.MusicBrainzID,
 			Image:     row.Image,
 			IsPrimary: row.IsPrimary.Valid && row.IsPrimary.Bool,
+			Role:      models.ArtistRole(row.Role.String),
 		}
 	}
 
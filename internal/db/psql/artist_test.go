@@ -18,7 +18,7 @@ func TestGetArtist(t *testing.T) {
 	mbzId := uuid.MustParse("00000000-0000-0000-0000-000000000001")
 
 	// Test GetArtist by ID
-	result, err := store.GetArtist(ctx, db.GetArtistOpts{ID: 1})
+	result, err := store.GetArtist(ctx, db.GetArtistOpts{UserID: 1, ID: 1})
 	require.NoError(t, err)
 	assert.EqualValues(t, 1, result.ID)
 	assert.Equal(t, "Artist One", result.Name)
@@ -26,7 +26,7 @@ func TestGetArtist(t *testing.T) {
 	assert.EqualValues(t, 400, result.TimeListened)
 
 	// Test GetArtist by Name
-	result, err = store.GetArtist(ctx, db.GetArtistOpts{Name: "Artist One"})
+	result, err = store.GetArtist(ctx, db.GetArtistOpts{UserID: 1, Name: "Artist One"})
 	require.NoError(t, err)
 	assert.EqualValues(t, 1, result.ID)
 	assert.Equal(t, "Artist One", result.Name)
@@ -34,7 +34,7 @@ func TestGetArtist(t *testing.T) {
 	assert.EqualValues(t, 400, result.TimeListened)
 
 	// Test GetArtist by MusicBrainzID
-	result, err = store.GetArtist(ctx, db.GetArtistOpts{MusicBrainzID: mbzId})
+	result, err = store.GetArtist(ctx, db.GetArtistOpts{UserID: 1, MusicBrainzID: mbzId})
 	require.NoError(t, err)
 	assert.EqualValues(t, 1, result.ID)
 	assert.Equal(t, "Artist One", result.Name)
@@ -42,7 +42,7 @@ func TestGetArtist(t *testing.T) {
 	assert.EqualValues(t, 400, result.TimeListened)
 
 	// Test GetArtist with insufficient information
-	_, err = store.GetArtist(ctx, db.GetArtistOpts{})
+	_, err = store.GetArtist(ctx, db.GetArtistOpts{UserID: 1})
 	assert.Error(t, err)
 
 	truncateTestData(t)
@@ -75,7 +75,7 @@ func TestSaveAliases(t *testing.T) {
 
 	err = store.SetPrimaryArtistAlias(ctx, 1, "Alias1")
 	require.NoError(t, err)
-	artist, err = store.GetArtist(ctx, db.GetArtistOpts{ID: artist.ID})
+	artist, err = store.GetArtist(ctx, db.GetArtistOpts{UserID: 1, ID: artist.ID})
 	require.NoError(t, err)
 	assert.Equal(t, "Alias1", artist.Name)
 
@@ -130,7 +130,7 @@ func TestUpdateArtist(t *testing.T) {
 	})
 	require.NoError(t, err)
 
-	result, err := store.GetArtist(ctx, db.GetArtistOpts{ID: artist.ID})
+	result, err := store.GetArtist(ctx, db.GetArtistOpts{UserID: 1, ID: artist.ID})
 	require.NoError(t, err)
 	assert.Equal(t, imgid, *result.Image)
 
@@ -201,7 +201,7 @@ func TestDeleteArtistAlias(t *testing.T) {
 	// Ensure primary alias cannot be deleted
 	err = store.DeleteArtistAlias(ctx, artist.ID, "Alias Artist")
 	require.NoError(t, err) // shouldn't error when nothing is deleted
-	artist, err = store.GetArtist(ctx, db.GetArtistOpts{ID: 1})
+	artist, err = store.GetArtist(ctx, db.GetArtistOpts{UserID: 1, ID: 1})
 	require.NoError(t, err)
 	assert.Equal(t, "Alias Artist", artist.Name)
 
@@ -0,0 +1,108 @@
+package psql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/gabehf/koito/internal/db"
+	"github.com/gabehf/koito/internal/models"
+	"github.com/gabehf/koito/internal/repository"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+func auditEntryFromRow(row repository.AuditLog) *models.AuditEntry {
+	var userID *int32
+	if row.UserID.Valid {
+		userID = &row.UserID.Int32
+	}
+	targetIDs := row.TargetIds
+	if targetIDs == nil {
+		targetIDs = []int32{}
+	}
+	return &models.AuditEntry{
+		ID:         row.ID,
+		UserID:     userID,
+		Username:   row.Username,
+		Action:     models.AuditAction(row.Action),
+		TargetType: models.AuditTarget(row.TargetType),
+		TargetIDs:  targetIDs,
+		Before:     row.Before,
+		After:      row.After,
+		CreatedAt:  row.CreatedAt,
+	}
+}
+
+// SaveAuditEntry appends an entry to the audit log. Entries can't be changed or removed afterwards.
+func (d *Psql) SaveAuditEntry(ctx context.Context, opts db.SaveAuditEntryOpts) (*models.AuditEntry, error) {
+	if opts.Action == "" || opts.TargetType == "" {
+		return nil, errors.New("SaveAuditEntry: required parameter Action or TargetType missing")
+	}
+	targetIDs := opts.TargetIDs
+	if targetIDs == nil {
+		targetIDs = []int32{}
+	}
+	row, err := d.q.InsertAuditEntry(ctx, repository.InsertAuditEntryParams{
+		UserID:     pgtype.Int4{Int32: opts.UserID, Valid: opts.UserID != 0},
+		Username:   opts.Username,
+		Action:     string(opts.Action),
+		TargetType: string(opts.TargetType),
+		TargetIds:  targetIDs,
+		Before:     opts.Before,
+		After:      opts.After,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("SaveAuditEntry: InsertAuditEntry: %w", err)
+	}
+	return auditEntryFromRow(row), nil
+}
+
+// GetAuditEntriesPaginated returns audit log entries matching opts, newest first.
+func (d *Psql) GetAuditEntriesPaginated(ctx context.Context, opts db.GetAuditEntriesOpts) (*db.PaginatedResponse[*models.AuditEntry], error) {
+	if opts.Limit < 0 {
+		return nil, errors.New("GetAuditEntriesPaginated: limit must be greater than or equal to 0")
+	}
+	if opts.Page < 0 {
+		return nil, errors.New("GetAuditEntriesPaginated: page must be greater than or equal to 0")
+	}
+	if opts.Limit == 0 {
+		opts.Limit = DefaultItemsPerPage
+	}
+	if opts.Page == 0 {
+		opts.Page = 1
+	}
+	offset := (opts.Page - 1) * opts.Limit
+
+	rows, err := d.q.GetAuditEntriesPaginated(ctx, repository.GetAuditEntriesPaginatedParams{
+		Action:      string(opts.Action),
+		TargetType:  string(opts.TargetType),
+		TargetID:    opts.TargetID,
+		UserID:      opts.UserID,
+		OffsetCount: int32(offset),
+		LimitCount:  int32(opts.Limit),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("GetAuditEntriesPaginated: GetAuditEntriesPaginated: %w", err)
+	}
+	count, err := d.q.CountAuditEntries(ctx, repository.CountAuditEntriesParams{
+		Action:     string(opts.Action),
+		TargetType: string(opts.TargetType),
+		TargetID:   opts.TargetID,
+		UserID:     opts.UserID,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("GetAuditEntriesPaginated: CountAuditEntries: %w", err)
+	}
+
+	entries := make([]*models.AuditEntry, len(rows))
+	for i, row := range rows {
+		entries[i] = auditEntryFromRow(row)
+	}
+	return &db.PaginatedResponse[*models.AuditEntry]{
+		Items:        entries,
+		TotalCount:   count,
+		ItemsPerPage: int32(opts.Limit),
+		HasNextPage:  int64(offset+len(entries)) < count,
+		CurrentPage:  int32(opts.Page),
+	}, nil
+}
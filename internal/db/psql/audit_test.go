@@ -0,0 +1,86 @@
+package psql_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/gabehf/koito/internal/db"
+	"github.com/gabehf/koito/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func truncateTestDataForAudit(t *testing.T) {
+	err := store.Exec(context.Background(),
+		`TRUNCATE
+			audit_log
+			RESTART IDENTITY CASCADE`,
+	)
+	require.NoError(t, err)
+}
+
+func TestAuditLog(t *testing.T) {
+	ctx := context.Background()
+	truncateTestDataForAudit(t)
+
+	merge, err := store.SaveAuditEntry(ctx, db.SaveAuditEntryOpts{
+		UserID:     1,
+		Username:   "test",
+		Action:     models.AuditActionMerge,
+		TargetType: models.AuditTargetArtist,
+		TargetIDs:  []int32{2, 3},
+		Before:     []byte(`{"from":{"id":2},"to":{"id":3}}`),
+		After:      []byte(`{"id":3}`),
+	})
+	require.NoError(t, err)
+	require.NotNil(t, merge.UserID)
+	assert.EqualValues(t, 1, *merge.UserID)
+	assert.Equal(t, []int32{2, 3}, merge.TargetIDs)
+	assert.JSONEq(t, `{"id":3}`, string(merge.After))
+
+	_, err = store.SaveAuditEntry(ctx, db.SaveAuditEntryOpts{
+		Action:     models.AuditActionDelete,
+		TargetType: models.AuditTargetAlbum,
+		TargetIDs:  []int32{3},
+		Before:     []byte(`{"id":3}`),
+	})
+	require.NoError(t, err)
+
+	_, err = store.SaveAuditEntry(ctx, db.SaveAuditEntryOpts{TargetType: models.AuditTargetAlbum})
+	assert.Error(t, err)
+
+	page, err := store.GetAuditEntriesPaginated(ctx, db.GetAuditEntriesOpts{})
+	require.NoError(t, err)
+	require.Len(t, page.Items, 2)
+	assert.EqualValues(t, 2, page.TotalCount)
+	// newest first
+	assert.Equal(t, models.AuditActionDelete, page.Items[0].Action)
+	assert.Nil(t, page.Items[0].UserID)
+	assert.Nil(t, page.Items[0].After)
+
+	page, err = store.GetAuditEntriesPaginated(ctx, db.GetAuditEntriesOpts{TargetID: 3})
+	require.NoError(t, err)
+	assert.Len(t, page.Items, 2)
+	page, err = store.GetAuditEntriesPaginated(ctx, db.GetAuditEntriesOpts{TargetID: 2})
+	require.NoError(t, err)
+	assert.Len(t, page.Items, 1)
+	page, err = store.GetAuditEntriesPaginated(ctx, db.GetAuditEntriesOpts{TargetType: models.AuditTargetAlbum, TargetID: 3})
+	require.NoError(t, err)
+	require.Len(t, page.Items, 1)
+	assert.Equal(t, models.AuditActionDelete, page.Items[0].Action)
+	page, err = store.GetAuditEntriesPaginated(ctx, db.GetAuditEntriesOpts{UserID: 1, Limit: 1})
+	require.NoError(t, err)
+	assert.Len(t, page.Items, 1)
+	assert.False(t, page.HasNextPage)
+	page, err = store.GetAuditEntriesPaginated(ctx, db.GetAuditEntriesOpts{Limit: 1})
+	require.NoError(t, err)
+	assert.True(t, page.HasNextPage)
+
+	// entries can't be changed once written
+	err = store.Exec(ctx, `UPDATE audit_log SET username = 'someone else'`)
+	assert.Error(t, err)
+	err = store.Exec(ctx, `DELETE FROM audit_log`)
+	assert.Error(t, err)
+
+	truncateTestDataForAudit(t)
+}
@@ -9,11 +9,12 @@ import (
 	"github.com/gabehf/koito/internal/repository"
 )
 
-func (p *Psql) CountListens(ctx context.Context, timeframe db.Timeframe) (int64, error) {
+func (p *Psql) CountListens(ctx context.Context, userID int32, timeframe db.Timeframe) (int64, error) {
 	t1, t2 := db.TimeframeToTimeRange(timeframe)
 	count, err := p.q.CountListens(ctx, repository.CountListensParams{
 		ListenedAt:   t1,
 		ListenedAt_2: t2,
+		UserID:       userID,
 	})
 	if err != nil {
 		return 0, fmt.Errorf("CountListens: %w", err)
@@ -21,11 +22,20 @@ func (p *Psql) CountListens(ctx context.Context, timeframe db.Timeframe) (int64,
 	return count, nil
 }
 
-func (p *Psql) CountTracks(ctx context.Context, timeframe db.Timeframe) (int64, error) {
+func (p *Psql) CountUserListens(ctx context.Context, userID int32) (int64, error) {
+	count, err := p.q.CountUserListens(ctx, userID)
+	if err != nil {
+		return 0, fmt.Errorf("CountUserListens: %w", err)
+	}
+	return count, nil
+}
+
+func (p *Psql) CountTracks(ctx context.Context, userID int32, timeframe db.Timeframe) (int64, error) {
 	t1, t2 := db.TimeframeToTimeRange(timeframe)
 	count, err := p.q.CountTopTracks(ctx, repository.CountTopTracksParams{
 		ListenedAt:   t1,
 		ListenedAt_2: t2,
+		UserID:       userID,
 	})
 	if err != nil {
 		return 0, fmt.Errorf("CountTracks: %w", err)
@@ -33,11 +43,12 @@ func (p *Psql) CountTracks(ctx context.Context, timeframe db.Timeframe) (int64,
 	return count, nil
 }
 
-func (p *Psql) CountAlbums(ctx context.Context, timeframe db.Timeframe) (int64, error) {
+func (p *Psql) CountAlbums(ctx context.Context, userID int32, timeframe db.Timeframe) (int64, error) {
 	t1, t2 := db.TimeframeToTimeRange(timeframe)
 	count, err := p.q.CountTopReleases(ctx, repository.CountTopReleasesParams{
 		ListenedAt:   t1,
 		ListenedAt_2: t2,
+		UserID:       userID,
 	})
 	if err != nil {
 		return 0, fmt.Errorf("CountAlbums: %w", err)
@@ -45,11 +56,12 @@ func (p *Psql) CountAlbums(ctx context.Context, timeframe db.Timeframe) (int64,
 	return count, nil
 }
 
-func (p *Psql) CountArtists(ctx context.Context, timeframe db.Timeframe) (int64, error) {
+func (p *Psql) CountArtists(ctx context.Context, userID int32, timeframe db.Timeframe) (int64, error) {
 	t1, t2 := db.TimeframeToTimeRange(timeframe)
 	count, err := p.q.CountTopArtists(ctx, repository.CountTopArtistsParams{
 		ListenedAt:   t1,
 		ListenedAt_2: t2,
+		UserID:       userID,
 	})
 	if err != nil {
 		return 0, fmt.Errorf("CountArtists: %w", err)
@@ -58,11 +70,12 @@ func (p *Psql) CountArtists(ctx context.Context, timeframe db.Timeframe) (int64,
 }
 
 // in seconds
-func (p *Psql) CountTimeListened(ctx context.Context, timeframe db.Timeframe) (int64, error) {
+func (p *Psql) CountTimeListened(ctx context.Context, userID int32, timeframe db.Timeframe) (int64, error) {
 	t1, t2 := db.TimeframeToTimeRange(timeframe)
 	count, err := p.q.CountTimeListened(ctx, repository.CountTimeListenedParams{
 		ListenedAt:   t1,
 		ListenedAt_2: t2,
+		UserID:       userID,
 	})
 	if err != nil {
 		return 0, fmt.Errorf("CountTimeListened: %w", err)
@@ -78,6 +91,7 @@ func (p *Psql) CountTimeListenedToItem(ctx context.Context, opts db.TimeListened
 		count, err := p.q.CountTimeListenedToArtist(ctx, repository.CountTimeListenedToArtistParams{
 			ListenedAt:   t1,
 			ListenedAt_2: t2,
+			UserID:       opts.UserID,
 			ArtistID:     opts.ArtistID,
 		})
 		if err != nil {
@@ -88,6 +102,7 @@ func (p *Psql) CountTimeListenedToItem(ctx context.Context, opts db.TimeListened
 		count, err := p.q.CountTimeListenedToRelease(ctx, repository.CountTimeListenedToReleaseParams{
 			ListenedAt:   t1,
 			ListenedAt_2: t2,
+			UserID:       opts.UserID,
 			ReleaseID:    opts.AlbumID,
 		})
 		if err != nil {
@@ -98,6 +113,7 @@ func (p *Psql) CountTimeListenedToItem(ctx context.Context, opts db.TimeListened
 		count, err := p.q.CountTimeListenedToTrack(ctx, repository.CountTimeListenedToTrackParams{
 			ListenedAt:   t1,
 			ListenedAt_2: t2,
+			UserID:       opts.UserID,
 			ID:           opts.TrackID,
 		})
 		if err != nil {
@@ -115,6 +131,7 @@ func (p *Psql) CountListensToItem(ctx context.Context, opts db.TimeListenedOpts)
 		count, err := p.q.CountListensFromArtist(ctx, repository.CountListensFromArtistParams{
 			ListenedAt:   t1,
 			ListenedAt_2: t2,
+			UserID:       opts.UserID,
 			ArtistID:     opts.ArtistID,
 		})
 		if err != nil {
@@ -125,6 +142,7 @@ func (p *Psql) CountListensToItem(ctx context.Context, opts db.TimeListenedOpts)
 		count, err := p.q.CountListensFromRelease(ctx, repository.CountListensFromReleaseParams{
 			ListenedAt:   t1,
 			ListenedAt_2: t2,
+			UserID:       opts.UserID,
 			ReleaseID:    opts.AlbumID,
 		})
 		if err != nil {
@@ -135,6 +153,7 @@ func (p *Psql) CountListensToItem(ctx context.Context, opts db.TimeListenedOpts)
 		count, err := p.q.CountListensFromTrack(ctx, repository.CountListensFromTrackParams{
 			ListenedAt:   t1,
 			ListenedAt_2: t2,
+			UserID:       opts.UserID,
 			TrackID:      opts.TrackID,
 		})
 		if err != nil {
@@ -145,11 +164,12 @@ func (p *Psql) CountListensToItem(ctx context.Context, opts db.TimeListenedOpts)
 	return 0, errors.New("CountListensToItem: an id must be provided")
 }
 
-func (p *Psql) CountNewTracks(ctx context.Context, timeframe db.Timeframe) (int64, error) {
+func (p *Psql) CountNewTracks(ctx context.Context, userID int32, timeframe db.Timeframe) (int64, error) {
 	t1, t2 := db.TimeframeToTimeRange(timeframe)
 	count, err := p.q.CountNewTracks(ctx, repository.CountNewTracksParams{
 		ListenedAt:   t1,
 		ListenedAt_2: t2,
+		UserID:       userID,
 	})
 	if err != nil {
 		return 0, fmt.Errorf("CountNewTracks: %w", err)
@@ -157,11 +177,12 @@ func (p *Psql) CountNewTracks(ctx context.Context, timeframe db.Timeframe) (int6
 	return count, nil
 }
 
-func (p *Psql) CountNewAlbums(ctx context.Context, timeframe db.Timeframe) (int64, error) {
+func (p *Psql) CountNewAlbums(ctx context.Context, userID int32, timeframe db.Timeframe) (int64, error) {
 	t1, t2 := db.TimeframeToTimeRange(timeframe)
 	count, err := p.q.CountNewReleases(ctx, repository.CountNewReleasesParams{
 		ListenedAt:   t1,
 		ListenedAt_2: t2,
+		UserID:       userID,
 	})
 	if err != nil {
 		return 0, fmt.Errorf("CountNewAlbums: %w", err)
@@ -169,11 +190,12 @@ func (p *Psql) CountNewAlbums(ctx context.Context, timeframe db.Timeframe) (int6
 	return count, nil
 }
 
-func (p *Psql) CountNewArtists(ctx context.Context, timeframe db.Timeframe) (int64, error) {
+func (p *Psql) CountNewArtists(ctx context.Context, userID int32, timeframe db.Timeframe) (int64, error) {
 	t1, t2 := db.TimeframeToTimeRange(timeframe)
 	count, err := p.q.CountNewArtists(ctx, repository.CountNewArtistsParams{
 		ListenedAt:   t1,
 		ListenedAt_2: t2,
+		UserID:       userID,
 	})
 	if err != nil {
 		return 0, fmt.Errorf("CountNewArtists: %w", err)
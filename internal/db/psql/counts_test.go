@@ -16,7 +16,7 @@ func TestCountListens(t *testing.T) {
 
 	// Test CountListens
 	timeframe := db.PeriodToTimeframe(db.PeriodWeek)
-	count, err := store.CountListens(ctx, timeframe)
+	count, err := store.CountListens(ctx, 1, timeframe)
 	require.NoError(t, err)
 	assert.Equal(t, int64(1), count, "expected listens count to match inserted data")
 
@@ -29,7 +29,7 @@ func TestCountTracks(t *testing.T) {
 
 	// Test CountTracks
 	timeframe := db.PeriodToTimeframe(db.PeriodMonth)
-	count, err := store.CountTracks(ctx, timeframe)
+	count, err := store.CountTracks(ctx, 1, timeframe)
 	require.NoError(t, err)
 	assert.Equal(t, int64(2), count, "expected tracks count to match inserted data")
 
@@ -46,7 +46,7 @@ func TestCountNewTracks(t *testing.T) {
 	t1u := t1.Unix()
 	t2, _ := time.Parse(time.DateOnly, "2025-12-31")
 	t2u := t2.Unix()
-	count, err := store.CountNewTracks(ctx, db.Timeframe{FromUnix: t1u, ToUnix: t2u})
+	count, err := store.CountNewTracks(ctx, 1, db.Timeframe{FromUnix: t1u, ToUnix: t2u})
 	require.NoError(t, err)
 	assert.Equal(t, int64(1), count, "expected tracks count to match inserted data")
 
@@ -59,7 +59,7 @@ func TestCountAlbums(t *testing.T) {
 
 	// Test CountAlbums
 	timeframe := db.PeriodToTimeframe(db.PeriodYear)
-	count, err := store.CountAlbums(ctx, timeframe)
+	count, err := store.CountAlbums(ctx, 1, timeframe)
 	require.NoError(t, err)
 	assert.Equal(t, int64(3), count, "expected albums count to match inserted data")
 
@@ -76,7 +76,7 @@ func TestCountNewAlbums(t *testing.T) {
 	t1u := t1.Unix()
 	t2, _ := time.Parse(time.DateOnly, "2025-12-31")
 	t2u := t2.Unix()
-	count, err := store.CountNewAlbums(ctx, db.Timeframe{FromUnix: t1u, ToUnix: t2u})
+	count, err := store.CountNewAlbums(ctx, 1, db.Timeframe{FromUnix: t1u, ToUnix: t2u})
 	require.NoError(t, err)
 	assert.Equal(t, int64(1), count, "expected albums count to match inserted data")
 
@@ -89,7 +89,7 @@ func TestCountArtists(t *testing.T) {
 
 	// Test CountArtists
 	timeframe := db.PeriodToTimeframe(db.PeriodAllTime)
-	count, err := store.CountArtists(ctx, timeframe)
+	count, err := store.CountArtists(ctx, 1, timeframe)
 	require.NoError(t, err)
 	assert.Equal(t, int64(4), count, "expected artists count to match inserted data")
 
@@ -106,7 +106,7 @@ func TestCountNewArtists(t *testing.T) {
 	t1u := t1.Unix()
 	t2, _ := time.Parse(time.DateOnly, "2025-12-31")
 	t2u := t2.Unix()
-	count, err := store.CountNewArtists(ctx, db.Timeframe{FromUnix: t1u, ToUnix: t2u})
+	count, err := store.CountNewArtists(ctx, 1, db.Timeframe{FromUnix: t1u, ToUnix: t2u})
 	require.NoError(t, err)
 	assert.Equal(t, int64(1), count, "expected artists count to match inserted data")
 
@@ -119,7 +119,7 @@ func TestCountTimeListened(t *testing.T) {
 
 	// Test CountTimeListened
 	timeframe := db.PeriodToTimeframe(db.PeriodMonth)
-	count, err := store.CountTimeListened(ctx, timeframe)
+	count, err := store.CountTimeListened(ctx, 1, timeframe)
 	require.NoError(t, err)
 	// 3 listens in past month, each 100 seconds
 	assert.Equal(t, int64(300), count, "expected total time listened to match inserted data")
@@ -131,7 +131,7 @@ func TestCountTimeListenedToArtist(t *testing.T) {
 	ctx := context.Background()
 	testDataForTopItems(t)
 	timeframe := db.PeriodToTimeframe(db.PeriodAllTime)
-	count, err := store.CountTimeListenedToItem(ctx, db.TimeListenedOpts{Timeframe: timeframe, ArtistID: 1})
+	count, err := store.CountTimeListenedToItem(ctx, db.TimeListenedOpts{UserID: 1, Timeframe: timeframe, ArtistID: 1})
 	require.NoError(t, err)
 	assert.EqualValues(t, 400, count)
 	truncateTestData(t)
@@ -141,7 +141,7 @@ func TestCountTimeListenedToAlbum(t *testing.T) {
 	ctx := context.Background()
 	testDataForTopItems(t)
 	timeframe := db.PeriodToTimeframe(db.PeriodAllTime)
-	count, err := store.CountTimeListenedToItem(ctx, db.TimeListenedOpts{Timeframe: timeframe, AlbumID: 2})
+	count, err := store.CountTimeListenedToItem(ctx, db.TimeListenedOpts{UserID: 1, Timeframe: timeframe, AlbumID: 2})
 	require.NoError(t, err)
 	assert.EqualValues(t, 300, count)
 	truncateTestData(t)
@@ -151,7 +151,7 @@ func TestCountTimeListenedToTrack(t *testing.T) {
 	ctx := context.Background()
 	testDataForTopItems(t)
 	timeframe := db.PeriodToTimeframe(db.PeriodAllTime)
-	count, err := store.CountTimeListenedToItem(ctx, db.TimeListenedOpts{Timeframe: timeframe, TrackID: 3})
+	count, err := store.CountTimeListenedToItem(ctx, db.TimeListenedOpts{UserID: 1, Timeframe: timeframe, TrackID: 3})
 	require.NoError(t, err)
 	assert.EqualValues(t, 200, count)
 	truncateTestData(t)
@@ -161,7 +161,7 @@ func TestListensToArtist(t *testing.T) {
 	ctx := context.Background()
 	testDataForTopItems(t)
 	period := db.PeriodAllTime
-	count, err := store.CountListensToItem(ctx, db.TimeListenedOpts{Timeframe: db.Timeframe{Period: period}, ArtistID: 1})
+	count, err := store.CountListensToItem(ctx, db.TimeListenedOpts{UserID: 1, Timeframe: db.Timeframe{Period: period}, ArtistID: 1})
 	require.NoError(t, err)
 	assert.EqualValues(t, 4, count)
 	truncateTestData(t)
@@ -171,7 +171,7 @@ func TestListensToAlbum(t *testing.T) {
 	ctx := context.Background()
 	testDataForTopItems(t)
 	period := db.PeriodAllTime
-	count, err := store.CountListensToItem(ctx, db.TimeListenedOpts{Timeframe: db.Timeframe{Period: period}, AlbumID: 2})
+	count, err := store.CountListensToItem(ctx, db.TimeListenedOpts{UserID: 1, Timeframe: db.Timeframe{Period: period}, AlbumID: 2})
 	require.NoError(t, err)
 	assert.EqualValues(t, 3, count)
 	truncateTestData(t)
@@ -181,8 +181,42 @@ func TestListensToTrack(t *testing.T) {
 	ctx := context.Background()
 	testDataForTopItems(t)
 	period := db.PeriodAllTime
-	count, err := store.CountListensToItem(ctx, db.TimeListenedOpts{Timeframe: db.Timeframe{Period: period}, TrackID: 3})
+	count, err := store.CountListensToItem(ctx, db.TimeListenedOpts{UserID: 1, Timeframe: db.Timeframe{Period: period}, TrackID: 3})
 	require.NoError(t, err)
 	assert.EqualValues(t, 2, count)
 	truncateTestData(t)
 }
+
+func TestCountsAreScopedToUser(t *testing.T) {
+	ctx := context.Background()
+	testDataForTopItems(t)
+	setupTestDataForUsers(t)
+
+	err := store.Exec(ctx,
+		`INSERT INTO listens (user_id, track_id, listened_at)
+			VALUES (2, 4, NOW() - INTERVAL '1 day'),
+				   (2, 4, NOW() - INTERVAL '3 days')`)
+	require.NoError(t, err)
+
+	timeframe := db.PeriodToTimeframe(db.PeriodAllTime)
+	count, err := store.CountListens(ctx, 1, timeframe)
+	require.NoError(t, err)
+	assert.EqualValues(t, 10, count, "listens of other users must not be counted")
+
+	count, err = store.CountListens(ctx, 2, timeframe)
+	require.NoError(t, err)
+	assert.EqualValues(t, 2, count)
+
+	count, err = store.CountArtists(ctx, 2, timeframe)
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, count)
+
+	artists, err := store.GetTopArtistsPaginated(ctx, db.GetItemsOpts{UserID: 2, Limit: 10, Page: 1, Timeframe: timeframe})
+	require.NoError(t, err)
+	require.Len(t, artists.Items, 1)
+	assert.EqualValues(t, 4, artists.Items[0].Item.ID)
+	assert.EqualValues(t, 2, artists.Items[0].ListenCount)
+
+	truncateTestData(t)
+	truncateTestDataForUsers(t)
+}
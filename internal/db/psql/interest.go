@@ -18,6 +18,7 @@ func (d *Psql) GetInterest(ctx context.Context, opts db.GetInterestOpts) ([]db.I
 
 	if opts.ArtistID != 0 {
 		resp, err := d.q.GetGroupedListensFromArtist(ctx, repository.GetGroupedListensFromArtistParams{
+			UserID:      opts.UserID,
 			ArtistID:    opts.ArtistID,
 			BucketCount: int32(opts.Buckets),
 		})
@@ -34,6 +35,7 @@ func (d *Psql) GetInterest(ctx context.Context, opts db.GetInterestOpts) ([]db.I
 		return ret, nil
 	} else if opts.AlbumID != 0 {
 		resp, err := d.q.GetGroupedListensFromRelease(ctx, repository.GetGroupedListensFromReleaseParams{
+			UserID:      opts.UserID,
 			ReleaseID:   opts.AlbumID,
 			BucketCount: int32(opts.Buckets),
 		})
@@ -50,6 +52,7 @@ func (d *Psql) GetInterest(ctx context.Context, opts db.GetInterestOpts) ([]db.I
 		return ret, nil
 	} else if opts.TrackID != 0 {
 		resp, err := d.q.GetGroupedListensFromTrack(ctx, repository.GetGroupedListensFromTrackParams{
+			UserID:      opts.UserID,
 			ID:          opts.TrackID,
 			BucketCount: int32(opts.Buckets),
 		})
@@ -63,12 +63,12 @@ func TestGetInterest(t *testing.T) {
 
 	t.Run("Validation", func(t *testing.T) {
 		// Error: Missing Buckets
-		_, err := store.GetInterest(ctx, db.GetInterestOpts{ArtistID: 1})
+		_, err := store.GetInterest(ctx, db.GetInterestOpts{UserID: 1, ArtistID: 1})
 		assert.Error(t, err)
 		assert.Contains(t, err.Error(), "bucket count must be provided")
 
 		// Error: Missing ID
-		_, err = store.GetInterest(ctx, db.GetInterestOpts{Buckets: 10})
+		_, err = store.GetInterest(ctx, db.GetInterestOpts{UserID: 1, Buckets: 10})
 		assert.Error(t, err)
 		assert.Contains(t, err.Error(), "must be provided")
 	})
@@ -80,6 +80,7 @@ func TestGetInterest(t *testing.T) {
 	t.Run("Artist Interest", func(t *testing.T) {
 		// Artist 1 should have 3 listens (from Track 1)
 		buckets, err := store.GetInterest(ctx, db.GetInterestOpts{
+			UserID:   1,
 			ArtistID: 1,
 			Buckets:  1,
 		})
@@ -91,6 +92,7 @@ func TestGetInterest(t *testing.T) {
 	t.Run("Album Interest", func(t *testing.T) {
 		// Album 1 contains Track 1 (3 listens) and Track 2 (2 listens) = 5 Total
 		buckets, err := store.GetInterest(ctx, db.GetInterestOpts{
+			UserID:  1,
 			AlbumID: 1,
 			Buckets: 1,
 		})
@@ -102,6 +104,7 @@ func TestGetInterest(t *testing.T) {
 	t.Run("Track Interest", func(t *testing.T) {
 		// Track 2 should have 2 listens
 		buckets, err := store.GetInterest(ctx, db.GetInterestOpts{
+			UserID:  1,
 			TrackID: 2,
 			Buckets: 1,
 		})
@@ -31,6 +31,7 @@ func (d *Psql) GetListensPaginated(ctx context.Context, opts db.GetItemsOpts) (*
 		l.Debug().Msgf("Fetching %d listens on page %d from range %v to %v",
 			opts.Limit, opts.Page, t1.Format("Jan 02, 2006"), t2.Format("Jan 02, 2006"))
 		rows, err := d.q.GetLastListensFromTrackPaginated(ctx, repository.GetLastListensFromTrackPaginatedParams{
+			UserID:       opts.UserID,
 			ListenedAt:   t1,
 			ListenedAt_2: t2,
 			Limit:        int32(opts.Limit),
@@ -44,8 +45,9 @@ func (d *Psql) GetListensPaginated(ctx context.Context, opts db.GetItemsOpts) (*
 		for i, row := range rows {
 			t := &models.Listen{
 				Track: models.Track{
-					Title: row.TrackTitle,
-					ID:    row.TrackID,
+					Title:   row.TrackTitle,
+					ID:      row.TrackID,
+					AlbumID: row.ReleaseID,
 				},
 				Time: row.ListenedAt,
 			}
@@ -56,6 +58,7 @@ func (d *Psql) GetListensPaginated(ctx context.Context, opts db.GetItemsOpts) (*
 			listens[i] = t
 		}
 		count, err = d.q.CountListensFromTrack(ctx, repository.CountListensFromTrackParams{
+			UserID:       opts.UserID,
 			ListenedAt:   t1,
 			ListenedAt_2: t2,
 			TrackID:      int32(opts.TrackID),
@@ -67,6 +70,7 @@ func (d *Psql) GetListensPaginated(ctx context.Context, opts db.GetItemsOpts) (*
 		l.Debug().Msgf("Fetching %d listens on page %d from range %v to %v",
 			opts.Limit, opts.Page, t1.Format("Jan 02, 2006"), t2.Format("Jan 02, 2006"))
 		rows, err := d.q.GetLastListensFromReleasePaginated(ctx, repository.GetLastListensFromReleasePaginatedParams{
+			UserID:       opts.UserID,
 			ListenedAt:   t1,
 			ListenedAt_2: t2,
 			Limit:        int32(opts.Limit),
@@ -80,8 +84,9 @@ func (d *Psql) GetListensPaginated(ctx context.Context, opts db.GetItemsOpts) (*
 		for i, row := range rows {
 			t := &models.Listen{
 				Track: models.Track{
-					Title: row.TrackTitle,
-					ID:    row.TrackID,
+					Title:   row.TrackTitle,
+					ID:      row.TrackID,
+					AlbumID: row.ReleaseID,
 				},
 				Time: row.ListenedAt,
 			}
@@ -92,6 +97,7 @@ func (d *Psql) GetListensPaginated(ctx context.Context, opts db.GetItemsOpts) (*
 			listens[i] = t
 		}
 		count, err = d.q.CountListensFromRelease(ctx, repository.CountListensFromReleaseParams{
+			UserID:       opts.UserID,
 			ListenedAt:   t1,
 			ListenedAt_2: t2,
 			ReleaseID:    int32(opts.AlbumID),
@@ -103,6 +109,7 @@ func (d *Psql) GetListensPaginated(ctx context.Context, opts db.GetItemsOpts) (*
 		l.Debug().Msgf("Fetching %d listens on page %d from range %v to %v",
 			opts.Limit, opts.Page, t1.Format("Jan 02, 2006"), t2.Format("Jan 02, 2006"))
 		rows, err := d.q.GetLastListensFromArtistPaginated(ctx, repository.GetLastListensFromArtistPaginatedParams{
+			UserID:       opts.UserID,
 			ListenedAt:   t1,
 			ListenedAt_2: t2,
 			Limit:        int32(opts.Limit),
@@ -116,8 +123,9 @@ func (d *Psql) GetListensPaginated(ctx context.Context, opts db.GetItemsOpts) (*
 		for i, row := range rows {
 			t := &models.Listen{
 				Track: models.Track{
-					Title: row.TrackTitle,
-					ID:    row.TrackID,
+					Title:   row.TrackTitle,
+					ID:      row.TrackID,
+					AlbumID: row.ReleaseID,
 				},
 				Time: row.ListenedAt,
 			}
@@ -128,6 +136,7 @@ func (d *Psql) GetListensPaginated(ctx context.Context, opts db.GetItemsOpts) (*
 			listens[i] = t
 		}
 		count, err = d.q.CountListensFromArtist(ctx, repository.CountListensFromArtistParams{
+			UserID:       opts.UserID,
 			ListenedAt:   t1,
 			ListenedAt_2: t2,
 			ArtistID:     int32(opts.ArtistID),
@@ -139,6 +148,7 @@ func (d *Psql) GetListensPaginated(ctx context.Context, opts db.GetItemsOpts) (*
 		l.Debug().Msgf("Fetching %d listens on page %d from range %v to %v",
 			opts.Limit, opts.Page, t1.Format("Jan 02, 2006"), t2.Format("Jan 02, 2006"))
 		rows, err := d.q.GetLastListensPaginated(ctx, repository.GetLastListensPaginatedParams{
+			UserID:       opts.UserID,
 			ListenedAt:   t1,
 			ListenedAt_2: t2,
 			Limit:        int32(opts.Limit),
@@ -151,8 +161,9 @@ func (d *Psql) GetListensPaginated(ctx context.Context, opts db.GetItemsOpts) (*
 		for i, row := range rows {
 			t := &models.Listen{
 				Track: models.Track{
-					Title: row.TrackTitle,
-					ID:    row.TrackID,
+					Title:   row.TrackTitle,
+					ID:      row.TrackID,
+					AlbumID: row.ReleaseID,
 				},
 				Time: row.ListenedAt,
 			}
@@ -163,6 +174,7 @@ func (d *Psql) GetListensPaginated(ctx context.Context, opts db.GetItemsOpts) (*
 			listens[i] = t
 		}
 		count, err = d.q.CountListens(ctx, repository.CountListensParams{
+			UserID:       opts.UserID,
 			ListenedAt:   t1,
 			ListenedAt_2: t2,
 		})
@@ -181,6 +193,65 @@ func (d *Psql) GetListensPaginated(ctx context.Context, opts db.GetItemsOpts) (*
 	}, nil
 }
 
+func (d *Psql) GetUserListens(ctx context.Context, opts db.GetUserListensOpts) ([]*models.Listen, error) {
+	l := logger.FromContext(ctx)
+	if opts.UserID == 0 {
+		return nil, errors.New("GetUserListens: user id not specified")
+	}
+	if opts.Limit <= 0 {
+		opts.Limit = DefaultItemsPerPage
+	}
+
+	var rows []repository.GetUserListensBeforeRow
+	if !opts.MinTime.IsZero() {
+		l.Debug().Msgf("Fetching %d listens of user %d after %v", opts.Limit, opts.UserID, opts.MinTime)
+		after, err := d.q.GetUserListensAfter(ctx, repository.GetUserListensAfterParams{
+			UserID:     opts.UserID,
+			MinTs:      opts.MinTime,
+			LimitCount: int32(opts.Limit),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("GetUserListens: GetUserListensAfter: %w", err)
+		}
+		// listens after the min time are fetched oldest first, so flip them to newest first
+		rows = make([]repository.GetUserListensBeforeRow, len(after))
+		for i, row := range after {
+			rows[len(after)-1-i] = repository.GetUserListensBeforeRow(row)
+		}
+	} else {
+		if opts.MaxTime.IsZero() {
+			opts.MaxTime = time.Now()
+		}
+		l.Debug().Msgf("Fetching %d listens of user %d before %v", opts.Limit, opts.UserID, opts.MaxTime)
+		var err error
+		rows, err = d.q.GetUserListensBefore(ctx, repository.GetUserListensBeforeParams{
+			UserID:     opts.UserID,
+			MaxTs:      opts.MaxTime,
+			LimitCount: int32(opts.Limit),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("GetUserListens: GetUserListensBefore: %w", err)
+		}
+	}
+
+	listens := make([]*models.Listen, len(rows))
+	for i, row := range rows {
+		t := &models.Listen{
+			Track: models.Track{
+				Title:   row.TrackTitle,
+				ID:      row.TrackID,
+				AlbumID: row.ReleaseID,
+			},
+			Time: row.ListenedAt,
+		}
+		if err := json.Unmarshal(row.Artists, &t.Track.Artists); err != nil {
+			return nil, fmt.Errorf("GetUserListens: Unmarshal: %w", err)
+		}
+		listens[i] = t
+	}
+	return listens, nil
+}
+
 func (d *Psql) SaveListen(ctx context.Context, opts db.SaveListenOpts) error {
 	l := logger.FromContext(ctx)
 	if opts.TrackID == 0 {
@@ -202,14 +273,86 @@ func (d *Psql) SaveListen(ctx context.Context, opts db.SaveListenOpts) error {
 	})
 }
 
-func (d *Psql) DeleteListen(ctx context.Context, trackId int32, listenedAt time.Time) error {
+func (d *Psql) DeleteListen(ctx context.Context, userId int32, trackId int32, listenedAt time.Time) error {
 	l := logger.FromContext(ctx)
+	if userId == 0 {
+		return errors.New("required parameter 'userId' missing")
+	}
 	if trackId == 0 {
 		return errors.New("required parameter 'trackId' missing")
 	}
-	l.Debug().Msgf("Deleting listen from track %d at time %s from DB", trackId, listenedAt)
+	l.Debug().Msgf("Deleting listen from track %d at time %s for user %d from DB", trackId, listenedAt, userId)
 	return d.q.DeleteListen(ctx, repository.DeleteListenParams{
+		UserID:     userId,
 		TrackID:    trackId,
 		ListenedAt: listenedAt,
 	})
 }
+
+func (d *Psql) GetUserListensToTrackInRange(ctx context.Context, opts db.ListensInRangeOpts) ([]db.ListenRecord, error) {
+	l := logger.FromContext(ctx)
+	if opts.TrackID == 0 || opts.UserID == 0 {
+		return nil, errors.New("GetUserListensToTrackInRange: required parameters TrackID and UserID missing")
+	}
+	l.Debug().Msgf("Fetching listens to track %d by user %d between %v and %v", opts.TrackID, opts.UserID, opts.From, opts.To)
+	rows, err := d.q.GetUserListensToTrackInRange(ctx, repository.GetUserListensToTrackInRangeParams{
+		UserID:       opts.UserID,
+		TrackID:      opts.TrackID,
+		ListenedAt:   opts.From,
+		ListenedAt_2: opts.To,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("GetUserListensToTrackInRange: %w", err)
+	}
+	listens := make([]db.ListenRecord, len(rows))
+	for i, row := range rows {
+		listens[i] = db.ListenRecord{
+			UserID:     row.UserID,
+			TrackID:    row.TrackID,
+			ListenedAt: row.ListenedAt,
+		}
+		if row.Client != nil {
+			listens[i].Client = *row.Client
+		}
+	}
+	return listens, nil
+}
+
+func (d *Psql) LockUserTrackListens(ctx context.Context, userID, trackID int32) error {
+	err := d.q.LockUserTrackListens(ctx, repository.LockUserTrackListensParams{
+		UserID:  userID,
+		TrackID: trackID,
+	})
+	if err != nil {
+		return fmt.Errorf("LockUserTrackListens: %w", err)
+	}
+	return nil
+}
+
+func (d *Psql) GetDuplicateListenCandidates(ctx context.Context, opts db.DuplicateListenOpts) ([]db.DuplicateCandidate, error) {
+	l := logger.FromContext(ctx)
+	l.Debug().Msgf("Scanning listens for duplicates (by duration: %v, window: %v)", opts.ByDuration, opts.Window)
+	rows, err := d.q.GetDuplicateListenCandidates(ctx, repository.GetDuplicateListenCandidatesParams{
+		ByDuration:    opts.ByDuration,
+		WindowSeconds: opts.Window.Seconds(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("GetDuplicateListenCandidates: %w", err)
+	}
+	candidates := make([]db.DuplicateCandidate, len(rows))
+	for i, row := range rows {
+		candidates[i] = db.DuplicateCandidate{
+			ListenRecord: db.ListenRecord{
+				UserID:     row.UserID,
+				TrackID:    row.TrackID,
+				TrackTitle: row.Title,
+				ListenedAt: row.ListenedAt,
+			},
+			Window: time.Duration(row.WindowSeconds * float64(time.Second)),
+		}
+		if row.Client != nil {
+			candidates[i].Client = *row.Client
+		}
+	}
+	return candidates, nil
+}
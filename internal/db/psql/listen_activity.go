@@ -25,6 +25,7 @@ func (d *Psql) GetListenActivity(ctx context.Context, opts db.ListenActivityOpts
 		l.Debug().Msgf("Fetching listen activity for %d %s(s) from %v to %v for release group %d",
 			opts.Range, opts.Step, t1.Format("Jan 02, 2006 15:04:05 MST"), t2.Format("Jan 02, 2006 15:04:05 MST"), opts.AlbumID)
 		rows, err := d.q.ListenActivityForRelease(ctx, repository.ListenActivityForReleaseParams{
+			UserID:       opts.UserID,
 			Column1:      opts.Timezone.String(),
 			ListenedAt:   t1,
 			ListenedAt_2: t2,
@@ -46,6 +47,7 @@ func (d *Psql) GetListenActivity(ctx context.Context, opts db.ListenActivityOpts
 		l.Debug().Msgf("Fetching listen activity for %d %s(s) from %v to %v for artist %d",
 			opts.Range, opts.Step, t1.Format("Jan 02, 2006 15:04:05 MST"), t2.Format("Jan 02, 2006 15:04:05 MST"), opts.ArtistID)
 		rows, err := d.q.ListenActivityForArtist(ctx, repository.ListenActivityForArtistParams{
+			UserID:       opts.UserID,
 			Column1:      opts.Timezone.String(),
 			ListenedAt:   t1,
 			ListenedAt_2: t2,
@@ -67,6 +69,7 @@ func (d *Psql) GetListenActivity(ctx context.Context, opts db.ListenActivityOpts
 		l.Debug().Msgf("Fetching listen activity for %d %s(s) from %v to %v for track %d",
 			opts.Range, opts.Step, t1.Format("Jan 02, 2006 15:04:05 MST"), t2.Format("Jan 02, 2006 15:04:05 MST"), opts.TrackID)
 		rows, err := d.q.ListenActivityForTrack(ctx, repository.ListenActivityForTrackParams{
+			UserID:       opts.UserID,
 			Column1:      opts.Timezone.String(),
 			ListenedAt:   t1,
 			ListenedAt_2: t2,
@@ -88,6 +91,7 @@ func (d *Psql) GetListenActivity(ctx context.Context, opts db.ListenActivityOpts
 		l.Debug().Msgf("Fetching listen activity for %d %s(s) from %v to %v",
 			opts.Range, opts.Step, t1.Format("Jan 02, 2006 15:04:05 MST"), t2.Format("Jan 02, 2006 15:04:05 MST"))
 		rows, err := d.q.ListenActivity(ctx, repository.ListenActivityParams{
+			UserID:       opts.UserID,
 			Column1:      opts.Timezone.String(),
 			ListenedAt:   t1,
 			ListenedAt_2: t2,
@@ -86,7 +86,7 @@ func TestListenActivity(t *testing.T) {
 	ctx := context.Background()
 
 	// Test for opts.Step = db.StepDay
-	activity, err := store.GetListenActivity(ctx, db.ListenActivityOpts{Step: db.StepDay})
+	activity, err := store.GetListenActivity(ctx, db.ListenActivityOpts{UserID: 1, Step: db.StepDay})
 	require.NoError(t, err)
 	require.Len(t, activity, 3)
 	assert.Equal(t, []int64{2, 2, 2}, flattenListenCounts(activity))
@@ -111,7 +111,7 @@ func TestListenActivity(t *testing.T) {
 				   (1, 2, NOW() - INTERVAL '2 months 1 day')`)
 	require.NoError(t, err)
 
-	activity, err = store.GetListenActivity(ctx, db.ListenActivityOpts{Step: db.StepMonth, Range: 8})
+	activity, err = store.GetListenActivity(ctx, db.ListenActivityOpts{UserID: 1, Step: db.StepMonth, Range: 8})
 	require.NoError(t, err)
 	require.GreaterOrEqual(t, len(activity), 8)
 
@@ -127,7 +127,7 @@ func TestListenActivity(t *testing.T) {
 				   (1, 2, NOW() - INTERVAL '3 years')`)
 	require.NoError(t, err)
 
-	activity, err = store.GetListenActivity(ctx, db.ListenActivityOpts{Step: db.StepYear})
+	activity, err = store.GetListenActivity(ctx, db.ListenActivityOpts{UserID: 1, Step: db.StepYear})
 	require.NoError(t, err)
 	require.Len(t, activity, 3)
 	assert.Equal(t, []int64{1, 1, 2}, flattenListenCounts(activity))
@@ -142,9 +142,10 @@ func TestListenActivity(t *testing.T) {
 	require.NoError(t, err)
 
 	activity, err = store.GetListenActivity(ctx, db.ListenActivityOpts{
-		Step:  db.StepDay,
-		Month: 3,
-		Year:  2024,
+		UserID: 1,
+		Step:   db.StepDay,
+		Month:  3,
+		Year:   2024,
 	})
 	require.NoError(t, err)
 	require.Len(t, activity, 2) // number of days in march
@@ -163,6 +164,7 @@ func TestListenActivity(t *testing.T) {
 	require.NoError(t, err)
 
 	activity, err = store.GetListenActivity(ctx, db.ListenActivityOpts{
+		UserID:  1,
 		Step:    db.StepDay,
 		AlbumID: 1, // Track 1 only
 	})
@@ -171,6 +173,7 @@ func TestListenActivity(t *testing.T) {
 	assert.Equal(t, []int64{1, 1}, flattenListenCounts(activity))
 
 	activity, err = store.GetListenActivity(ctx, db.ListenActivityOpts{
+		UserID:  1,
 		Step:    db.StepDay,
 		TrackID: 1, // Track 1 only
 	})
@@ -179,6 +182,7 @@ func TestListenActivity(t *testing.T) {
 	assert.Equal(t, []int64{1, 1}, flattenListenCounts(activity))
 
 	activity, err = store.GetListenActivity(ctx, db.ListenActivityOpts{
+		UserID:   1,
 		Step:     db.StepDay,
 		ArtistID: 2, // Should only include listens to Track 2
 	})
@@ -188,8 +192,9 @@ func TestListenActivity(t *testing.T) {
 
 	// month without year is disallowed
 	_, err = store.GetListenActivity(ctx, db.ListenActivityOpts{
-		Step:  db.StepDay,
-		Month: 5,
+		UserID: 1,
+		Step:   db.StepDay,
+		Month:  5,
 	})
 	assert.Error(t, err)
 }
@@ -67,7 +67,7 @@ func TestGetListens(t *testing.T) {
 	ctx := context.Background()
 
 	// Test valid
-	resp, err := store.GetListensPaginated(ctx, db.GetItemsOpts{Timeframe: db.PeriodToTimeframe(db.PeriodAllTime)})
+	resp, err := store.GetListensPaginated(ctx, db.GetItemsOpts{UserID: 1, Timeframe: db.PeriodToTimeframe(db.PeriodAllTime)})
 	require.NoError(t, err)
 	require.Len(t, resp.Items, 10)
 	assert.Equal(t, int64(10), resp.TotalCount)
@@ -78,7 +78,7 @@ func TestGetListens(t *testing.T) {
 	assert.Equal(t, "Artist Three", resp.Items[1].Track.Artists[0].Name)
 
 	// Test pagination
-	resp, err = store.GetListensPaginated(ctx, db.GetItemsOpts{Limit: 1, Page: 2, Timeframe: db.PeriodToTimeframe(db.PeriodAllTime)})
+	resp, err = store.GetListensPaginated(ctx, db.GetItemsOpts{UserID: 1, Limit: 1, Page: 2, Timeframe: db.PeriodToTimeframe(db.PeriodAllTime)})
 	require.NoError(t, err)
 	require.Len(t, resp.Items, 1)
 	require.Len(t, resp.Items[0].Track.Artists, 1)
@@ -89,61 +89,61 @@ func TestGetListens(t *testing.T) {
 	assert.Equal(t, "Artist Three", resp.Items[0].Track.Artists[0].Name)
 
 	// Test page out of range
-	resp, err = store.GetListensPaginated(ctx, db.GetItemsOpts{Limit: 10, Page: 10, Timeframe: db.PeriodToTimeframe(db.PeriodAllTime)})
+	resp, err = store.GetListensPaginated(ctx, db.GetItemsOpts{UserID: 1, Limit: 10, Page: 10, Timeframe: db.PeriodToTimeframe(db.PeriodAllTime)})
 	require.NoError(t, err)
 	assert.Empty(t, resp.Items)
 	assert.False(t, resp.HasNextPage)
 
 	// Test invalid inputs
-	_, err = store.GetListensPaginated(ctx, db.GetItemsOpts{Limit: -1, Page: 0})
+	_, err = store.GetListensPaginated(ctx, db.GetItemsOpts{UserID: 1, Limit: -1, Page: 0})
 	assert.Error(t, err)
 
-	_, err = store.GetListensPaginated(ctx, db.GetItemsOpts{Limit: 1, Page: -1})
+	_, err = store.GetListensPaginated(ctx, db.GetItemsOpts{UserID: 1, Limit: 1, Page: -1})
 	assert.Error(t, err)
 
 	// Test specify period
-	resp, err = store.GetListensPaginated(ctx, db.GetItemsOpts{Timeframe: db.PeriodToTimeframe(db.PeriodDay)})
+	resp, err = store.GetListensPaginated(ctx, db.GetItemsOpts{UserID: 1, Timeframe: db.PeriodToTimeframe(db.PeriodDay)})
 	require.NoError(t, err)
 	require.Len(t, resp.Items, 0) // empty
 	assert.Equal(t, int64(0), resp.TotalCount)
 	// should default to PeriodDay
-	resp, err = store.GetListensPaginated(ctx, db.GetItemsOpts{})
+	resp, err = store.GetListensPaginated(ctx, db.GetItemsOpts{UserID: 1})
 	require.NoError(t, err)
 	require.Len(t, resp.Items, 0) // empty
 	assert.Equal(t, int64(0), resp.TotalCount)
 
-	resp, err = store.GetListensPaginated(ctx, db.GetItemsOpts{Timeframe: db.PeriodToTimeframe(db.PeriodWeek)})
+	resp, err = store.GetListensPaginated(ctx, db.GetItemsOpts{UserID: 1, Timeframe: db.PeriodToTimeframe(db.PeriodWeek)})
 	require.NoError(t, err)
 	require.Len(t, resp.Items, 1)
 	assert.Equal(t, int64(1), resp.TotalCount)
 
-	resp, err = store.GetListensPaginated(ctx, db.GetItemsOpts{Timeframe: db.PeriodToTimeframe(db.PeriodMonth)})
+	resp, err = store.GetListensPaginated(ctx, db.GetItemsOpts{UserID: 1, Timeframe: db.PeriodToTimeframe(db.PeriodMonth)})
 	require.NoError(t, err)
 	require.Len(t, resp.Items, 3)
 	assert.Equal(t, int64(3), resp.TotalCount)
 
-	resp, err = store.GetListensPaginated(ctx, db.GetItemsOpts{Timeframe: db.PeriodToTimeframe(db.PeriodYear)})
+	resp, err = store.GetListensPaginated(ctx, db.GetItemsOpts{UserID: 1, Timeframe: db.PeriodToTimeframe(db.PeriodYear)})
 	require.NoError(t, err)
 	require.Len(t, resp.Items, 6)
 	assert.Equal(t, int64(6), resp.TotalCount)
 
 	// Test filter by artists, releases, and tracks
-	resp, err = store.GetListensPaginated(ctx, db.GetItemsOpts{Timeframe: db.PeriodToTimeframe(db.PeriodAllTime), ArtistID: 1})
+	resp, err = store.GetListensPaginated(ctx, db.GetItemsOpts{UserID: 1, Timeframe: db.PeriodToTimeframe(db.PeriodAllTime), ArtistID: 1})
 	require.NoError(t, err)
 	require.Len(t, resp.Items, 4)
 	assert.Equal(t, int64(4), resp.TotalCount)
 
-	resp, err = store.GetListensPaginated(ctx, db.GetItemsOpts{Timeframe: db.PeriodToTimeframe(db.PeriodAllTime), AlbumID: 2})
+	resp, err = store.GetListensPaginated(ctx, db.GetItemsOpts{UserID: 1, Timeframe: db.PeriodToTimeframe(db.PeriodAllTime), AlbumID: 2})
 	require.NoError(t, err)
 	require.Len(t, resp.Items, 3)
 	assert.Equal(t, int64(3), resp.TotalCount)
 
-	resp, err = store.GetListensPaginated(ctx, db.GetItemsOpts{Timeframe: db.PeriodToTimeframe(db.PeriodAllTime), TrackID: 3})
+	resp, err = store.GetListensPaginated(ctx, db.GetItemsOpts{UserID: 1, Timeframe: db.PeriodToTimeframe(db.PeriodAllTime), TrackID: 3})
 	require.NoError(t, err)
 	require.Len(t, resp.Items, 2)
 	assert.Equal(t, int64(2), resp.TotalCount)
 	// when both artistID and albumID are specified, artist id is ignored
-	resp, err = store.GetListensPaginated(ctx, db.GetItemsOpts{Timeframe: db.PeriodToTimeframe(db.PeriodAllTime), AlbumID: 2, ArtistID: 1})
+	resp, err = store.GetListensPaginated(ctx, db.GetItemsOpts{UserID: 1, Timeframe: db.PeriodToTimeframe(db.PeriodAllTime), AlbumID: 2, ArtistID: 1})
 	require.NoError(t, err)
 	require.Len(t, resp.Items, 3)
 	assert.Equal(t, int64(3), resp.TotalCount)
@@ -152,18 +152,59 @@ func TestGetListens(t *testing.T) {
 
 	testDataAbsoluteListenTimes(t)
 
-	resp, err = store.GetListensPaginated(ctx, db.GetItemsOpts{Timeframe: db.Timeframe{Year: 2023}})
+	resp, err = store.GetListensPaginated(ctx, db.GetItemsOpts{UserID: 1, Timeframe: db.Timeframe{Year: 2023}})
 	require.NoError(t, err)
 	require.Len(t, resp.Items, 4)
 	assert.Equal(t, int64(4), resp.TotalCount)
 
-	resp, err = store.GetListensPaginated(ctx, db.GetItemsOpts{Timeframe: db.Timeframe{Month: 6, Year: 2024}})
+	resp, err = store.GetListensPaginated(ctx, db.GetItemsOpts{UserID: 1, Timeframe: db.Timeframe{Month: 6, Year: 2024}})
 	require.NoError(t, err)
 	require.Len(t, resp.Items, 3)
 	assert.Equal(t, int64(3), resp.TotalCount)
 
 }
 
+func TestGetUserListens(t *testing.T) {
+	testDataForTopItems(t)
+	setupTestDataForUsers(t)
+	ctx := context.Background()
+
+	err := store.Exec(ctx,
+		`INSERT INTO listens (user_id, track_id, listened_at)
+			VALUES (2, 4, NOW() - INTERVAL '1 day')`)
+	require.NoError(t, err)
+
+	// listens of other users are left out
+	all, err := store.GetUserListens(ctx, db.GetUserListensOpts{UserID: 1, Limit: 100})
+	require.NoError(t, err)
+	require.Len(t, all, 10)
+	for i := 1; i < len(all); i++ {
+		assert.False(t, all[i].Time.After(all[i-1].Time), "expected listens to be newest first")
+	}
+	count, err := store.CountUserListens(ctx, 1)
+	require.NoError(t, err)
+	assert.EqualValues(t, 10, count)
+	count, err = store.CountUserListens(ctx, 2)
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, count)
+
+	// max time is exclusive
+	listens, err := store.GetUserListens(ctx, db.GetUserListensOpts{UserID: 1, MaxTime: all[0].Time, Limit: 1})
+	require.NoError(t, err)
+	require.Len(t, listens, 1)
+	assert.Equal(t, all[1].Time, listens[0].Time)
+
+	// min time is exclusive and returns the listens right after it, newest first
+	listens, err = store.GetUserListens(ctx, db.GetUserListensOpts{UserID: 1, MinTime: all[9].Time, Limit: 2})
+	require.NoError(t, err)
+	require.Len(t, listens, 2)
+	assert.Equal(t, all[7].Time, listens[0].Time)
+	assert.Equal(t, all[8].Time, listens[1].Time)
+
+	truncateTestData(t)
+	truncateTestDataForUsers(t)
+}
+
 func TestSaveListen(t *testing.T) {
 	testDataForListens(t)
 	ctx := context.Background()
@@ -197,19 +238,97 @@ func TestDeleteListen(t *testing.T) {
 	testDataForListens(t)
 	ctx := context.Background()
 
+	setupTestDataForUsers(t)
+
+	// two users listening to the same track at the same time
 	err := store.Exec(ctx, `
 		INSERT INTO listens (user_id, track_id, listened_at)
-		VALUES (1, 1, to_timestamp(1749464138.0))`)
+		VALUES (1, 1, to_timestamp(1749464138.0)),
+			   (2, 1, to_timestamp(1749464138.0))`)
 	require.NoError(t, err)
 
-	err = store.DeleteListen(ctx, 1, time.Unix(1749464138, 0))
+	err = store.DeleteListen(ctx, 1, 1, time.Unix(1749464138, 0))
 	require.NoError(t, err)
 
 	exists, err := store.RowExists(ctx, `
 		SELECT EXISTS (
 			SELECT 1 FROM listens
-			WHERE track_id = $1
-		)`, 1)
+			WHERE track_id = $1 AND user_id = $2
+		)`, 1, 1)
 	require.NoError(t, err)
 	assert.False(t, exists, "expected listen to be deleted")
+
+	exists, err = store.RowExists(ctx, `
+		SELECT EXISTS (
+			SELECT 1 FROM listens
+			WHERE track_id = $1 AND user_id = $2
+		)`, 1, 2)
+	require.NoError(t, err)
+	assert.True(t, exists, "expected listen of another user to be kept")
+
+	err = store.DeleteListen(ctx, 0, 1, time.Unix(1749464138, 0))
+	assert.Error(t, err)
+
+	truncateTestDataForUsers(t)
+}
+
+func TestGetUserListensToTrackInRange(t *testing.T) {
+	testDataForListens(t)
+	ctx := context.Background()
+
+	err := store.Exec(ctx, `
+		INSERT INTO listens (user_id, track_id, listened_at, client)
+		VALUES (1, 1, to_timestamp(1749464100.0), 'navidrome'),
+			   (1, 1, to_timestamp(1749464130.0), NULL),
+			   (1, 1, to_timestamp(1749465000.0), NULL),
+			   (1, 2, to_timestamp(1749464110.0), NULL)`)
+	require.NoError(t, err)
+
+	listens, err := store.GetUserListensToTrackInRange(ctx, db.ListensInRangeOpts{
+		UserID:  1,
+		TrackID: 1,
+		From:    time.Unix(1749464000, 0),
+		To:      time.Unix(1749464200, 0),
+	})
+	require.NoError(t, err)
+	require.Len(t, listens, 2)
+	assert.Equal(t, "navidrome", listens[0].Client)
+	assert.Equal(t, "", listens[1].Client)
+	assert.True(t, listens[1].ListenedAt.Equal(time.Unix(1749464130, 0)))
+
+	_, err = store.GetUserListensToTrackInRange(ctx, db.ListensInRangeOpts{UserID: 1})
+	assert.Error(t, err)
+}
+
+func TestGetDuplicateListenCandidates(t *testing.T) {
+	testDataForListens(t)
+	ctx := context.Background()
+
+	err := store.Exec(ctx, `UPDATE tracks SET duration = 200 WHERE id = 1`)
+	require.NoError(t, err)
+	err = store.Exec(ctx, `
+		INSERT INTO listens (user_id, track_id, listened_at, client)
+		VALUES (1, 1, to_timestamp(1749464100.0), 'navidrome'),
+			   (1, 1, to_timestamp(1749464150.0), NULL),
+			   (1, 1, to_timestamp(1749465000.0), NULL),
+			   (1, 2, to_timestamp(1749464100.0), NULL),
+			   (1, 2, to_timestamp(1749464120.0), NULL)`)
+	require.NoError(t, err)
+
+	// fixed window: only track 2 listens are within 30 seconds of each other
+	candidates, err := store.GetDuplicateListenCandidates(ctx, db.DuplicateListenOpts{Window: 30 * time.Second})
+	require.NoError(t, err)
+	require.Len(t, candidates, 2)
+	assert.EqualValues(t, 2, candidates[0].TrackID)
+	assert.Equal(t, "Track Two", candidates[0].TrackTitle)
+	assert.Equal(t, 30*time.Second, candidates[0].Window)
+
+	// by duration: track 1 uses half of its duration, track 2 falls back to the window
+	candidates, err = store.GetDuplicateListenCandidates(ctx, db.DuplicateListenOpts{ByDuration: true, Window: 30 * time.Second})
+	require.NoError(t, err)
+	require.Len(t, candidates, 4)
+	assert.EqualValues(t, 1, candidates[0].TrackID)
+	assert.Equal(t, "navidrome", candidates[0].Client)
+	assert.Equal(t, 100*time.Second, candidates[0].Window)
+	assert.EqualValues(t, 2, candidates[3].TrackID)
 }
@@ -9,36 +9,50 @@ import (
 	"github.com/jackc/pgx/v5"
 )
 
-func (d *Psql) MergeTracks(ctx context.Context, fromId, toId int32) error {
+// MergeTracks moves the listens of one track onto another and removes it. Listens that the
+// user already has of the target track at the same time are discarded, and their number is
+// returned. The merge snapshot keeps them, so they come back if the merge is undone.
+func (d *Psql) MergeTracks(ctx context.Context, fromId, toId int32) (int64, error) {
 	l := logger.FromContext(ctx)
 	l.Info().Msgf("Merging track %d into track %d", fromId, toId)
 	tx, err := d.conn.BeginTx(ctx, pgx.TxOptions{})
 	if err != nil {
 		l.Err(err).Msg("Failed to begin transaction")
-		return fmt.Errorf("MergeTracks: %w", err)
+		return 0, fmt.Errorf("MergeTracks: %w", err)
 	}
 	defer tx.Rollback(ctx)
 	qtx := d.q.WithTx(tx)
 	from, err := qtx.GetTrack(ctx, fromId)
 	if err != nil {
-		return fmt.Errorf("MergeTracks: GetTrack: %w", err)
+		return 0, fmt.Errorf("MergeTracks: GetTrack: %w", err)
 	}
 	to, err := qtx.GetTrack(ctx, toId)
 	if err != nil {
-		return fmt.Errorf("MergeTracks: GetTrack: %w", err)
+		return 0, fmt.Errorf("MergeTracks: GetTrack: %w", err)
 	}
-	err = qtx.UpdateTrackIdForListens(ctx, repository.UpdateTrackIdForListensParams{
-		TrackID:   fromId,
-		TrackID_2: toId,
+	if err := snapshotTrackMerge(ctx, qtx, fromId, toId, from.ReleaseID); err != nil {
+		return 0, fmt.Errorf("MergeTracks: %w", err)
+	}
+	moved, err := qtx.CopyListensToTrack(ctx, repository.CopyListensToTrackParams{
+		FromID: fromId,
+		ToID:   toId,
 	})
 	if err != nil {
-		return fmt.Errorf("MergeTracks: UpdateTrackIdForListens: %w", err)
+		return 0, fmt.Errorf("MergeTracks: CopyListensToTrack: %w", err)
+	}
+	total, err := qtx.DeleteListensForTrack(ctx, fromId)
+	if err != nil {
+		return 0, fmt.Errorf("MergeTracks: DeleteListensForTrack: %w", err)
+	}
+	discarded := total - moved
+	if discarded > 0 {
+		l.Info().Msgf("Discarded %d listens of track %d that duplicate listens of track %d", discarded, fromId, toId)
 	}
 	if from.ReleaseID != to.ReleaseID {
 		// tracks are from different releases, track artist should be associated with to.release
 		artists, err := qtx.GetTrackArtists(ctx, fromId)
 		if err != nil {
-			return fmt.Errorf("MergeTracks: GetTrackArtists: %w", err)
+			return 0, fmt.Errorf("MergeTracks: GetTrackArtists: %w", err)
 		}
 		for _, artist := range artists {
 			err = qtx.AssociateArtistToRelease(ctx, repository.AssociateArtistToReleaseParams{
@@ -46,16 +60,19 @@ func (d *Psql) MergeTracks(ctx context.Context, fromId, toId int32) error {
 				ReleaseID: to.ReleaseID,
 			})
 			if err != nil {
-				return fmt.Errorf("MergeTracks: AssociateArtistToRelease: %w", err)
+				return 0, fmt.Errorf("MergeTracks: AssociateArtistToRelease: %w", err)
 			}
 		}
 	}
 	err = qtx.CleanOrphanedEntries(ctx)
 	if err != nil {
 		l.Err(err).Msg("MergeTracks: Failed to clean orphaned entries")
-		return err
+		return 0, err
 	}
-	return tx.Commit(ctx)
+	if err := tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("MergeTracks: %w", err)
+	}
+	return discarded, nil
 }
 
 func (d *Psql) MergeAlbums(ctx context.Context, fromId, toId int32, replaceImage bool) error {
@@ -73,6 +90,9 @@ func (d *Psql) MergeAlbums(ctx context.Context, fromId, toId int32, replaceImage
 	if err != nil {
 		return fmt.Errorf("MergeAlbums: GetReleaseArtists: %w", err)
 	}
+	if err := snapshotAlbumMerge(ctx, qtx, fromId, toId, replaceImage); err != nil {
+		return fmt.Errorf("MergeAlbums: %w", err)
+	}
 
 	err = qtx.UpdateReleaseForAll(ctx, repository.UpdateReleaseForAllParams{
 		ReleaseID:   fromId,
@@ -124,6 +144,9 @@ func (d *Psql) MergeArtists(ctx context.Context, fromId, toId int32, replaceImag
 	}
 	defer tx.Rollback(ctx)
 	qtx := d.q.WithTx(tx)
+	if err := snapshotArtistMerge(ctx, qtx, fromId, toId, replaceImage); err != nil {
+		return fmt.Errorf("MergeArtists: %w", err)
+	}
 	err = qtx.DeleteConflictingArtistTracks(ctx, repository.DeleteConflictingArtistTracksParams{
 		ArtistID:   fromId,
 		ArtistID_2: toId,
@@ -0,0 +1,447 @@
+package psql
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/gabehf/koito/internal/db"
+	"github.com/gabehf/koito/internal/logger"
+	"github.com/gabehf/koito/internal/repository"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const (
+	mergeKindArtist = "artist"
+	mergeKindAlbum  = "album"
+	mergeKindTrack  = "track"
+)
+
+// mergeSnapshot holds the rows a merge removed or moved, as JSON arrays of table rows, so that
+// the merge can be undone. Rows are only restored when whatever they belong to was recreated,
+// so the snapshot can include rows that the merge may or may not have removed.
+type mergeSnapshot struct {
+	Artists        json.RawMessage `json:"artists,omitempty"`
+	ArtistAliases  json.RawMessage `json:"artist_aliases,omitempty"`
+	ArtistGenres   json.RawMessage `json:"artist_genres,omitempty"`
+	Releases       json.RawMessage `json:"releases,omitempty"`
+	ReleaseAliases json.RawMessage `json:"release_aliases,omitempty"`
+	ReleaseGenres  json.RawMessage `json:"release_genres,omitempty"`
+	Tracks         json.RawMessage `json:"tracks,omitempty"`
+	TrackAliases   json.RawMessage `json:"track_aliases,omitempty"`
+	ArtistTracks   json.RawMessage `json:"artist_tracks,omitempty"`
+	ArtistReleases json.RawMessage `json:"artist_releases,omitempty"`
+	// for track merges, only the listens that were dropped as duplicates of the target's own
+	Listens json.RawMessage `json:"listens,omitempty"`
+
+	// rows that were moved onto the target, rather than dropped as duplicates of its own
+	MovedListens        json.RawMessage `json:"moved_listens,omitempty"`
+	MovedArtistTracks   json.RawMessage `json:"moved_artist_tracks,omitempty"`
+	MovedArtistReleases json.RawMessage `json:"moved_artist_releases,omitempty"`
+	MovedTracks         []int32         `json:"moved_tracks,omitempty"`
+
+	// the target's own image, when it was replaced by the source's
+	ReplacedImage     bool        `json:"replaced_image,omitempty"`
+	TargetImage       *uuid.UUID  `json:"target_image,omitempty"`
+	TargetImageSource pgtype.Text `json:"target_image_source"`
+}
+
+// snapshotQuery stores the result of a snapshot query in dst.
+type snapshotQuery struct {
+	dst *json.RawMessage
+	fn  func() ([]byte, error)
+}
+
+func runSnapshotQueries(queries ...snapshotQuery) error {
+	for _, q := range queries {
+		rows, err := q.fn()
+		if err != nil {
+			return err
+		}
+		*q.dst = rows
+	}
+	return nil
+}
+
+func saveMergeSnapshot(ctx context.Context, qtx *repository.Queries, kind string, fromId, toId int32, snapshot *mergeSnapshot) error {
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return fmt.Errorf("saveMergeSnapshot: %w", err)
+	}
+	err = qtx.InsertMergeSnapshot(ctx, repository.InsertMergeSnapshotParams{
+		Kind:     kind,
+		FromID:   fromId,
+		ToID:     toId,
+		Snapshot: data,
+	})
+	if err != nil {
+		return fmt.Errorf("saveMergeSnapshot: InsertMergeSnapshot: %w", err)
+	}
+	return nil
+}
+
+// snapshotTrackMerge records the track being merged away, which of its listens are moved and
+// which are dropped, and the release and artists that are removed along with it if it was
+// their last track.
+func snapshotTrackMerge(ctx context.Context, qtx *repository.Queries, fromId, toId, releaseId int32) error {
+	artists, err := qtx.GetTrackArtists(ctx, fromId)
+	if err != nil {
+		return fmt.Errorf("snapshotTrackMerge: GetTrackArtists: %w", err)
+	}
+	artistIds := make([]int32, len(artists))
+	for i, a := range artists {
+		artistIds[i] = a.ID
+	}
+	trackIds := []int32{fromId}
+	releaseIds := []int32{releaseId}
+
+	s := new(mergeSnapshot)
+	err = runSnapshotQueries(
+		snapshotQuery{&s.Artists, func() ([]byte, error) { return qtx.SnapshotArtists(ctx, artistIds) }},
+		snapshotQuery{&s.ArtistAliases, func() ([]byte, error) { return qtx.SnapshotArtistAliases(ctx, artistIds) }},
+		snapshotQuery{&s.ArtistGenres, func() ([]byte, error) { return qtx.SnapshotArtistGenres(ctx, artistIds) }},
+		snapshotQuery{&s.Releases, func() ([]byte, error) { return qtx.SnapshotReleases(ctx, releaseIds) }},
+		snapshotQuery{&s.ReleaseAliases, func() ([]byte, error) { return qtx.SnapshotReleaseAliases(ctx, releaseIds) }},
+		snapshotQuery{&s.ReleaseGenres, func() ([]byte, error) { return qtx.SnapshotReleaseGenres(ctx, releaseIds) }},
+		snapshotQuery{&s.Tracks, func() ([]byte, error) { return qtx.SnapshotTracks(ctx, trackIds) }},
+		snapshotQuery{&s.TrackAliases, func() ([]byte, error) { return qtx.SnapshotTrackAliases(ctx, trackIds) }},
+		snapshotQuery{&s.ArtistTracks, func() ([]byte, error) {
+			return qtx.SnapshotArtistTracks(ctx, repository.SnapshotArtistTracksParams{TrackIds: trackIds})
+		}},
+		snapshotQuery{&s.ArtistReleases, func() ([]byte, error) {
+			return qtx.SnapshotArtistReleases(ctx, repository.SnapshotArtistReleasesParams{ReleaseIds: releaseIds})
+		}},
+		snapshotQuery{&s.Listens, func() ([]byte, error) {
+			return qtx.SnapshotDroppedListens(ctx, repository.SnapshotDroppedListensParams{FromID: fromId, ToID: toId})
+		}},
+		snapshotQuery{&s.MovedListens, func() ([]byte, error) {
+			return qtx.SnapshotMovableListens(ctx, repository.SnapshotMovableListensParams{FromID: fromId, ToID: toId})
+		}},
+	)
+	if err != nil {
+		return fmt.Errorf("snapshotTrackMerge: %w", err)
+	}
+	return saveMergeSnapshot(ctx, qtx, mergeKindTrack, fromId, toId, s)
+}
+
+// snapshotAlbumMerge records the album being merged away and which tracks are moved off of it.
+func snapshotAlbumMerge(ctx context.Context, qtx *repository.Queries, fromId, toId int32, replaceImage bool) error {
+	releaseIds := []int32{fromId}
+
+	s := new(mergeSnapshot)
+	err := runSnapshotQueries(
+		snapshotQuery{&s.Releases, func() ([]byte, error) { return qtx.SnapshotReleases(ctx, releaseIds) }},
+		snapshotQuery{&s.ReleaseAliases, func() ([]byte, error) { return qtx.SnapshotReleaseAliases(ctx, releaseIds) }},
+		snapshotQuery{&s.ReleaseGenres, func() ([]byte, error) { return qtx.SnapshotReleaseGenres(ctx, releaseIds) }},
+		snapshotQuery{&s.ArtistReleases, func() ([]byte, error) {
+			return qtx.SnapshotArtistReleases(ctx, repository.SnapshotArtistReleasesParams{ReleaseIds: releaseIds})
+		}},
+	)
+	if err != nil {
+		return fmt.Errorf("snapshotAlbumMerge: %w", err)
+	}
+	s.MovedTracks, err = qtx.GetTrackIDsForRelease(ctx, fromId)
+	if err != nil {
+		return fmt.Errorf("snapshotAlbumMerge: GetTrackIDsForRelease: %w", err)
+	}
+	if replaceImage {
+		to, err := qtx.GetRelease(ctx, toId)
+		if err != nil {
+			return fmt.Errorf("snapshotAlbumMerge: GetRelease: %w", err)
+		}
+		s.ReplacedImage = true
+		s.TargetImage = to.Image
+		s.TargetImageSource = to.ImageSource
+	}
+	return saveMergeSnapshot(ctx, qtx, mergeKindAlbum, fromId, toId, s)
+}
+
+// snapshotArtistMerge records the artist being merged away and which of its track and release
+// credits are moved onto the target.
+func snapshotArtistMerge(ctx context.Context, qtx *repository.Queries, fromId, toId int32, replaceImage bool) error {
+	artistIds := []int32{fromId}
+
+	s := new(mergeSnapshot)
+	err := runSnapshotQueries(
+		snapshotQuery{&s.Artists, func() ([]byte, error) { return qtx.SnapshotArtists(ctx, artistIds) }},
+		snapshotQuery{&s.ArtistAliases, func() ([]byte, error) { return qtx.SnapshotArtistAliases(ctx, artistIds) }},
+		snapshotQuery{&s.ArtistGenres, func() ([]byte, error) { return qtx.SnapshotArtistGenres(ctx, artistIds) }},
+		snapshotQuery{&s.ArtistTracks, func() ([]byte, error) {
+			return qtx.SnapshotArtistTracks(ctx, repository.SnapshotArtistTracksParams{ArtistIds: artistIds})
+		}},
+		snapshotQuery{&s.ArtistReleases, func() ([]byte, error) {
+			return qtx.SnapshotArtistReleases(ctx, repository.SnapshotArtistReleasesParams{ArtistIds: artistIds})
+		}},
+		snapshotQuery{&s.MovedArtistTracks, func() ([]byte, error) {
+			return qtx.SnapshotMovableArtistTracks(ctx, repository.SnapshotMovableArtistTracksParams{FromID: fromId, ToID: toId})
+		}},
+		snapshotQuery{&s.MovedArtistReleases, func() ([]byte, error) {
+			return qtx.SnapshotMovableArtistReleases(ctx, repository.SnapshotMovableArtistReleasesParams{FromID: fromId, ToID: toId})
+		}},
+	)
+	if err != nil {
+		return fmt.Errorf("snapshotArtistMerge: %w", err)
+	}
+	if replaceImage {
+		to, err := qtx.GetArtist(ctx, toId)
+		if err != nil {
+			return fmt.Errorf("snapshotArtistMerge: GetArtist: %w", err)
+		}
+		s.ReplacedImage = true
+		s.TargetImage = to.Image
+		s.TargetImageSource = to.ImageSource
+	}
+	return saveMergeSnapshot(ctx, qtx, mergeKindArtist, fromId, toId, s)
+}
+
+// restoreSnapshotRows recreates the artists, releases and tracks in the snapshot that no longer
+// exist, along with their aliases and genres, and then the credits and listens that refer to them.
+func restoreSnapshotRows(ctx context.Context, qtx *repository.Queries, s *mergeSnapshot) error {
+	if len(s.Artists) > 0 {
+		ids, err := qtx.RestoreArtists(ctx, s.Artists)
+		if err != nil {
+			return fmt.Errorf("RestoreArtists: %w", err)
+		}
+		if err := qtx.RestoreArtistAliases(ctx, repository.RestoreArtistAliasesParams{Data: s.ArtistAliases, Ids: ids}); err != nil {
+			return fmt.Errorf("RestoreArtistAliases: %w", err)
+		}
+		if err := qtx.RestoreArtistGenres(ctx, repository.RestoreArtistGenresParams{Data: s.ArtistGenres, Ids: ids}); err != nil {
+			return fmt.Errorf("RestoreArtistGenres: %w", err)
+		}
+	}
+	if len(s.Releases) > 0 {
+		ids, err := qtx.RestoreReleases(ctx, s.Releases)
+		if err != nil {
+			return fmt.Errorf("RestoreReleases: %w", err)
+		}
+		if err := qtx.RestoreReleaseAliases(ctx, repository.RestoreReleaseAliasesParams{Data: s.ReleaseAliases, Ids: ids}); err != nil {
+			return fmt.Errorf("RestoreReleaseAliases: %w", err)
+		}
+		if err := qtx.RestoreReleaseGenres(ctx, repository.RestoreReleaseGenresParams{Data: s.ReleaseGenres, Ids: ids}); err != nil {
+			return fmt.Errorf("RestoreReleaseGenres: %w", err)
+		}
+	}
+	if len(s.Tracks) > 0 {
+		ids, err := qtx.RestoreTracks(ctx, s.Tracks)
+		if err != nil {
+			return fmt.Errorf("RestoreTracks: %w", err)
+		}
+		if err := qtx.RestoreTrackAliases(ctx, repository.RestoreTrackAliasesParams{Data: s.TrackAliases, Ids: ids}); err != nil {
+			return fmt.Errorf("RestoreTrackAliases: %w", err)
+		}
+	}
+	if len(s.ArtistTracks) > 0 {
+		if err := qtx.RestoreArtistTracks(ctx, s.ArtistTracks); err != nil {
+			return fmt.Errorf("RestoreArtistTracks: %w", err)
+		}
+	}
+	if len(s.ArtistReleases) > 0 {
+		if err := qtx.RestoreArtistReleases(ctx, s.ArtistReleases); err != nil {
+			return fmt.Errorf("RestoreArtistReleases: %w", err)
+		}
+	}
+	if len(s.Listens) > 0 {
+		if err := qtx.RestoreListens(ctx, s.Listens); err != nil {
+			return fmt.Errorf("RestoreListens: %w", err)
+		}
+	}
+	return nil
+}
+
+// unmerge is an undo of a merge in progress.
+type unmerge struct {
+	tx       pgx.Tx
+	qtx      *repository.Queries
+	id       int32
+	toId     int32
+	snapshot *mergeSnapshot
+}
+
+// itemExists interprets the error from looking up an item.
+func itemExists(err error) (bool, error) {
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// beginUnmerge starts a transaction for undoing the latest merge of the given item, and checks
+// that it can still be undone: the item must still be gone, and the item it was merged into must
+// still be there to take things back from.
+func (d *Psql) beginUnmerge(ctx context.Context, kind string, fromId int32) (*unmerge, error) {
+	tx, err := d.conn.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return nil, err
+	}
+	u := &unmerge{tx: tx, qtx: d.q.WithTx(tx)}
+	if err := u.load(ctx, kind, fromId); err != nil {
+		tx.Rollback(ctx)
+		return nil, err
+	}
+	return u, nil
+}
+
+func (u *unmerge) load(ctx context.Context, kind string, fromId int32) error {
+	row, err := u.qtx.GetLatestMergeSnapshot(ctx, repository.GetLatestMergeSnapshotParams{
+		Kind:   kind,
+		FromID: fromId,
+	})
+	if err != nil {
+		return fmt.Errorf("GetLatestMergeSnapshot: %w", err)
+	}
+	u.id = row.ID
+	u.toId = row.ToID
+	u.snapshot = new(mergeSnapshot)
+	if err := json.Unmarshal(row.Snapshot, u.snapshot); err != nil {
+		return fmt.Errorf("invalid snapshot %d: %w", row.ID, err)
+	}
+
+	var fromErr, toErr error
+	switch kind {
+	case mergeKindArtist:
+		_, fromErr = u.qtx.GetArtist(ctx, fromId)
+		_, toErr = u.qtx.GetArtist(ctx, u.toId)
+	case mergeKindAlbum:
+		_, fromErr = u.qtx.GetRelease(ctx, fromId)
+		_, toErr = u.qtx.GetRelease(ctx, u.toId)
+	case mergeKindTrack:
+		_, fromErr = u.qtx.GetTrack(ctx, fromId)
+		_, toErr = u.qtx.GetTrack(ctx, u.toId)
+	}
+	fromExists, err := itemExists(fromErr)
+	if err != nil {
+		return err
+	}
+	toExists, err := itemExists(toErr)
+	if err != nil {
+		return err
+	}
+	if fromExists || !toExists {
+		return db.ErrCannotUnmerge
+	}
+	return nil
+}
+
+func (u *unmerge) finish(ctx context.Context) error {
+	if err := u.qtx.CleanOrphanedEntries(ctx); err != nil {
+		return fmt.Errorf("CleanOrphanedEntries: %w", err)
+	}
+	if err := u.qtx.MarkMergeSnapshotUndone(ctx, u.id); err != nil {
+		return fmt.Errorf("MarkMergeSnapshotUndone: %w", err)
+	}
+	return u.tx.Commit(ctx)
+}
+
+// UnmergeTracks undoes the latest merge of the given track, recreating it and moving its
+// listens back to it.
+func (d *Psql) UnmergeTracks(ctx context.Context, fromId int32) error {
+	l := logger.FromContext(ctx)
+	u, err := d.beginUnmerge(ctx, mergeKindTrack, fromId)
+	if err != nil {
+		return fmt.Errorf("UnmergeTracks: %w", err)
+	}
+	defer u.tx.Rollback(ctx)
+	l.Info().Msgf("Undoing merge of track %d into track %d", fromId, u.toId)
+
+	if len(u.snapshot.MovedListens) > 0 {
+		err = u.qtx.UnmoveListens(ctx, repository.UnmoveListensParams{Data: u.snapshot.MovedListens, ToID: u.toId})
+		if err != nil {
+			return fmt.Errorf("UnmergeTracks: UnmoveListens: %w", err)
+		}
+	}
+	if err := restoreSnapshotRows(ctx, u.qtx, u.snapshot); err != nil {
+		return fmt.Errorf("UnmergeTracks: %w", err)
+	}
+	if len(u.snapshot.MovedListens) > 0 {
+		if err := u.qtx.RestoreListens(ctx, u.snapshot.MovedListens); err != nil {
+			return fmt.Errorf("UnmergeTracks: RestoreListens: %w", err)
+		}
+	}
+	if err := u.finish(ctx); err != nil {
+		return fmt.Errorf("UnmergeTracks: %w", err)
+	}
+	return nil
+}
+
+// UnmergeAlbums undoes the latest merge of the given album, recreating it and moving its
+// tracks back to it.
+func (d *Psql) UnmergeAlbums(ctx context.Context, fromId int32) error {
+	l := logger.FromContext(ctx)
+	u, err := d.beginUnmerge(ctx, mergeKindAlbum, fromId)
+	if err != nil {
+		return fmt.Errorf("UnmergeAlbums: %w", err)
+	}
+	defer u.tx.Rollback(ctx)
+	l.Info().Msgf("Undoing merge of album %d into album %d", fromId, u.toId)
+
+	if err := restoreSnapshotRows(ctx, u.qtx, u.snapshot); err != nil {
+		return fmt.Errorf("UnmergeAlbums: %w", err)
+	}
+	err = u.qtx.UnmoveTracks(ctx, repository.UnmoveTracksParams{
+		FromID: fromId,
+		ToID:   u.toId,
+		Ids:    u.snapshot.MovedTracks,
+	})
+	if err != nil {
+		return fmt.Errorf("UnmergeAlbums: UnmoveTracks: %w", err)
+	}
+	if u.snapshot.ReplacedImage {
+		err = u.qtx.UpdateReleaseImage(ctx, repository.UpdateReleaseImageParams{
+			ID:          u.toId,
+			Image:       u.snapshot.TargetImage,
+			ImageSource: u.snapshot.TargetImageSource,
+		})
+		if err != nil {
+			return fmt.Errorf("UnmergeAlbums: UpdateReleaseImage: %w", err)
+		}
+	}
+	if err := u.finish(ctx); err != nil {
+		return fmt.Errorf("UnmergeAlbums: %w", err)
+	}
+	return nil
+}
+
+// UnmergeArtists undoes the latest merge of the given artist, recreating it and moving its
+// track and album credits back to it.
+func (d *Psql) UnmergeArtists(ctx context.Context, fromId int32) error {
+	l := logger.FromContext(ctx)
+	u, err := d.beginUnmerge(ctx, mergeKindArtist, fromId)
+	if err != nil {
+		return fmt.Errorf("UnmergeArtists: %w", err)
+	}
+	defer u.tx.Rollback(ctx)
+	l.Info().Msgf("Undoing merge of artist %d into artist %d", fromId, u.toId)
+
+	if len(u.snapshot.MovedArtistTracks) > 0 {
+		err = u.qtx.UnmoveArtistTracks(ctx, repository.UnmoveArtistTracksParams{Data: u.snapshot.MovedArtistTracks, ToID: u.toId})
+		if err != nil {
+			return fmt.Errorf("UnmergeArtists: UnmoveArtistTracks: %w", err)
+		}
+	}
+	if len(u.snapshot.MovedArtistReleases) > 0 {
+		err = u.qtx.UnmoveArtistReleases(ctx, repository.UnmoveArtistReleasesParams{Data: u.snapshot.MovedArtistReleases, ToID: u.toId})
+		if err != nil {
+			return fmt.Errorf("UnmergeArtists: UnmoveArtistReleases: %w", err)
+		}
+	}
+	if err := restoreSnapshotRows(ctx, u.qtx, u.snapshot); err != nil {
+		return fmt.Errorf("UnmergeArtists: %w", err)
+	}
+	if u.snapshot.ReplacedImage {
+		err = u.qtx.UpdateArtistImage(ctx, repository.UpdateArtistImageParams{
+			ID:          u.toId,
+			Image:       u.snapshot.TargetImage,
+			ImageSource: u.snapshot.TargetImageSource,
+		})
+		if err != nil {
+			return fmt.Errorf("UnmergeArtists: UpdateArtistImage: %w", err)
+		}
+	}
+	if err := u.finish(ctx); err != nil {
+		return fmt.Errorf("UnmergeArtists: %w", err)
+	}
+	return nil
+}
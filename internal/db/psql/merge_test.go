@@ -4,6 +4,8 @@ import (
 	"context"
 	"testing"
 
+	"github.com/gabehf/koito/internal/db"
+	"github.com/jackc/pgx/v5"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -81,7 +83,7 @@ func TestMergeTracks(t *testing.T) {
 	setupTestDataForMerge(t)
 
 	// Merge Track 1 into Track 2
-	err := store.MergeTracks(ctx, 1, 2)
+	_, err := store.MergeTracks(ctx, 1, 2)
 	require.NoError(t, err)
 
 	// Verify listens are updated
@@ -107,7 +109,7 @@ func TestMergeTracks_SameRelease(t *testing.T) {
 	setupTestDataForMerge(t)
 
 	// Merge Track 1 into Track 2
-	err := store.MergeTracks(ctx, 1, 3)
+	_, err := store.MergeTracks(ctx, 1, 3)
 	require.NoError(t, err)
 
 	// Verify listens are updated
@@ -119,6 +121,44 @@ func TestMergeTracks_SameRelease(t *testing.T) {
 	truncateTestData(t)
 }
 
+func TestMergeTracks_OverlappingListens(t *testing.T) {
+	ctx := context.Background()
+	setupTestDataForMerge(t)
+	setupTestDataForUsers(t)
+
+	// user 1 has a listen of both tracks at the same time, user 2 only of Track 1
+	err := store.Exec(ctx,
+		`INSERT INTO listens (user_id, track_id, listened_at)
+			VALUES (1, 1, to_timestamp(1749464138.0)),
+				   (1, 2, to_timestamp(1749464138.0)),
+				   (2, 1, to_timestamp(1749464138.0))`)
+	require.NoError(t, err)
+
+	discarded, err := store.MergeTracks(ctx, 1, 2)
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, discarded)
+
+	count, err := store.Count(ctx, `SELECT COUNT(*) FROM listens WHERE track_id = 2`)
+	require.NoError(t, err)
+	assert.Equal(t, 4, count, "expected overlapping listens to be merged into one")
+
+	// the snapshot keeps exactly the listen that was dropped
+	count, err = store.Count(ctx, `SELECT jsonb_array_length(snapshot->'listens') FROM merge_snapshots WHERE kind = 'track' AND from_id = 1`)
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+
+	count, err = store.Count(ctx, `SELECT COUNT(*) FROM listens WHERE track_id = 2 AND user_id = 2`)
+	require.NoError(t, err)
+	assert.Equal(t, 1, count, "expected listens of other users to be kept")
+
+	count, err = store.Count(ctx, `SELECT COUNT(*) FROM listens WHERE track_id = 1`)
+	require.NoError(t, err)
+	assert.Equal(t, 0, count)
+
+	truncateTestData(t)
+	truncateTestDataForUsers(t)
+}
+
 func TestMergeAlbums(t *testing.T) {
 	ctx := context.Background()
 	setupTestDataForMerge(t)
@@ -189,3 +229,163 @@ func TestMergeArtists(t *testing.T) {
 
 	truncateTestData(t)
 }
+
+func TestUnmergeTracks(t *testing.T) {
+	ctx := context.Background()
+	setupTestDataForMerge(t)
+
+	_, err := store.MergeTracks(ctx, 1, 2)
+	require.NoError(t, err)
+
+	err = store.UnmergeTracks(ctx, 1)
+	require.NoError(t, err)
+
+	// Verify the track is back, on its own release
+	exists, err := store.RowExists(ctx, `
+    SELECT EXISTS (
+      SELECT 1 FROM tracks WHERE id = $1 AND release_id = $2
+    )`, 1, 1)
+	require.NoError(t, err)
+	assert.True(t, exists, "expected Track 1 to be restored")
+
+	count, err := store.Count(ctx, `SELECT COUNT(*) FROM track_aliases WHERE track_id = 1 AND alias = 'Track One'`)
+	require.NoError(t, err)
+	assert.Equal(t, 1, count, "expected track alias to be restored")
+
+	// Verify listens are moved back
+	count, err = store.Count(ctx, `SELECT COUNT(*) FROM listens WHERE track_id = 1`)
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+	count, err = store.Count(ctx, `SELECT COUNT(*) FROM listens WHERE track_id = 2`)
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+
+	// Verify the merge can't be undone twice
+	err = store.UnmergeTracks(ctx, 1)
+	assert.ErrorIs(t, err, pgx.ErrNoRows)
+
+	truncateTestData(t)
+}
+
+func TestUnmergeTracks_OverlappingListens(t *testing.T) {
+	ctx := context.Background()
+	setupTestDataForMerge(t)
+
+	err := store.Exec(ctx,
+		`INSERT INTO listens (user_id, track_id, listened_at)
+			VALUES (1, 1, to_timestamp(1749464138.0)),
+				   (1, 2, to_timestamp(1749464138.0))`)
+	require.NoError(t, err)
+
+	_, err = store.MergeTracks(ctx, 1, 2)
+	require.NoError(t, err)
+	err = store.UnmergeTracks(ctx, 1)
+	require.NoError(t, err)
+
+	// the listen Track 2 already had at the same time is kept
+	count, err := store.Count(ctx, `SELECT COUNT(*) FROM listens WHERE track_id = 1`)
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+	count, err = store.Count(ctx, `SELECT COUNT(*) FROM listens WHERE track_id = 2`)
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+
+	truncateTestData(t)
+}
+
+func TestUnmergeAlbums(t *testing.T) {
+	ctx := context.Background()
+	setupTestDataForMerge(t)
+
+	err := store.MergeAlbums(ctx, 1, 2, true)
+	require.NoError(t, err)
+
+	err = store.UnmergeAlbums(ctx, 1)
+	require.NoError(t, err)
+
+	// Verify the album and its tracks are back
+	count, err := store.Count(ctx, `SELECT COUNT(*) FROM release_aliases WHERE release_id = 1 AND alias = 'Album One'`)
+	require.NoError(t, err)
+	assert.Equal(t, 1, count, "expected release alias to be restored")
+
+	count, err = store.Count(ctx, `SELECT COUNT(*) FROM tracks WHERE release_id = 1`)
+	require.NoError(t, err)
+	assert.Equal(t, 2, count, "expected tracks to be moved back to Album 1")
+
+	count, err = store.Count(ctx, `SELECT COUNT(*) FROM tracks WHERE release_id = 2`)
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+
+	// Verify the images are back where they were
+	count, err = store.Count(ctx, `SELECT COUNT(*) FROM releases WHERE id = 1 AND image = '20000000-0000-0000-0000-000000000000'`)
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+	count, err = store.Count(ctx, `SELECT COUNT(*) FROM releases WHERE id = 2 AND image IS NULL`)
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+
+	// Verify the old album artist is no longer associated with the other album
+	exists, err := store.RowExists(ctx, `
+    SELECT EXISTS (
+      SELECT 1 FROM artist_releases
+      WHERE release_id = $1 AND artist_id = $2
+    )`, 2, 1)
+	require.NoError(t, err)
+	assert.False(t, exists)
+
+	truncateTestData(t)
+}
+
+func TestUnmergeArtists(t *testing.T) {
+	ctx := context.Background()
+	setupTestDataForMerge(t)
+
+	err := store.MergeArtists(ctx, 1, 2, true)
+	require.NoError(t, err)
+
+	err = store.UnmergeArtists(ctx, 1)
+	require.NoError(t, err)
+
+	count, err := store.Count(ctx, `SELECT COUNT(*) FROM artist_aliases WHERE artist_id = 1 AND alias = 'Artist One'`)
+	require.NoError(t, err)
+	assert.Equal(t, 1, count, "expected artist alias to be restored")
+
+	count, err = store.Count(ctx, `SELECT COUNT(*) FROM artist_tracks WHERE artist_id = 1`)
+	require.NoError(t, err)
+	assert.Equal(t, 3, count)
+	count, err = store.Count(ctx, `SELECT COUNT(*) FROM artist_tracks WHERE artist_id = 2`)
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+
+	count, err = store.Count(ctx, `SELECT COUNT(*) FROM artist_releases WHERE artist_id = 1`)
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+	count, err = store.Count(ctx, `SELECT COUNT(*) FROM artist_releases WHERE artist_id = 2`)
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+
+	count, err = store.Count(ctx, `SELECT COUNT(*) FROM artists WHERE id = 2 AND image IS NULL`)
+	require.NoError(t, err)
+	assert.Equal(t, 1, count, "expected image of Artist 2 to be restored")
+
+	truncateTestData(t)
+}
+
+func TestUnmerge_Conflicts(t *testing.T) {
+	ctx := context.Background()
+	setupTestDataForMerge(t)
+
+	err := store.UnmergeArtists(ctx, 1)
+	assert.ErrorIs(t, err, pgx.ErrNoRows, "expected no merge to undo")
+
+	_, err = store.MergeTracks(ctx, 4, 3)
+	require.NoError(t, err)
+
+	// the track merged into has been deleted since
+	err = store.DeleteTrack(ctx, 3)
+	require.NoError(t, err)
+	err = store.UnmergeTracks(ctx, 4)
+	assert.ErrorIs(t, err, db.ErrCannotUnmerge)
+
+	truncateTestData(t)
+}
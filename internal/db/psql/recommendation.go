@@ -17,6 +17,7 @@ func (d *Psql) GetTracksToRevisit(ctx context.Context, opts db.GetRecommendation
 	l.Debug().Msg("Fetching tracks to revisit")
 
 	rows, err := d.q.GetTracksToRevisit(ctx, repository.GetTracksToRevisitParams{
+		UserID:       opts.UserID,
 		ListenedAt:   opts.PastWindowStart,
 		ListenedAt_2: opts.PastWindowEnd,
 		Limit:        int32(opts.Limit),
@@ -0,0 +1,296 @@
+package psql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/gabehf/koito/internal/db"
+	"github.com/gabehf/koito/internal/models"
+	"github.com/gabehf/koito/internal/repository"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+func relayEntryFromRow(row repository.RelayOutbox) *models.RelayEntry {
+	var targetID *int32
+	if row.TargetID.Valid {
+		targetID = &row.TargetID.Int32
+	}
+	return &models.RelayEntry{
+		TargetID:      targetID,
+		ID:            row.ID,
+		UserID:        row.UserID,
+		Payload:       row.Payload,
+		Status:        models.RelayStatus(row.Status),
+		Attempts:      row.Attempts,
+		NextAttemptAt: row.NextAttemptAt,
+		LastError:     row.LastError.String,
+		CreatedAt:     row.CreatedAt,
+		UpdatedAt:     row.UpdatedAt,
+	}
+}
+
+func (d *Psql) SaveRelayEntry(ctx context.Context, opts db.SaveRelayEntryOpts) (*models.RelayEntry, error) {
+	if opts.UserID == 0 {
+		return nil, errors.New("SaveRelayEntry: required parameter UserID missing")
+	}
+	row, err := d.q.InsertRelayEntry(ctx, repository.InsertRelayEntryParams{
+		UserID:   opts.UserID,
+		TargetID: pgtype.Int4{Int32: opts.TargetID, Valid: opts.TargetID != 0},
+		Payload:  opts.Payload,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("SaveRelayEntry: InsertRelayEntry: %w", err)
+	}
+	return relayEntryFromRow(row), nil
+}
+
+// Returns nil, nil when no database entries are found
+func (d *Psql) GetRelayEntry(ctx context.Context, id int32) (*models.RelayEntry, error) {
+	row, err := d.q.GetRelayEntry(ctx, id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("GetRelayEntry: %w", err)
+	}
+	return relayEntryFromRow(row), nil
+}
+
+// GetDueRelayEntries returns up to limit pending entries whose next attempt is due, oldest first.
+func (d *Psql) GetDueRelayEntries(ctx context.Context, limit int32) ([]*models.RelayEntry, error) {
+	rows, err := d.q.GetDueRelayEntries(ctx, limit)
+	if err != nil {
+		return nil, fmt.Errorf("GetDueRelayEntries: %w", err)
+	}
+	entries := make([]*models.RelayEntry, len(rows))
+	for i, row := range rows {
+		entries[i] = relayEntryFromRow(row)
+	}
+	return entries, nil
+}
+
+func (d *Psql) GetRelayEntriesPaginated(ctx context.Context, opts db.GetRelayEntriesOpts) (*db.PaginatedResponse[*models.RelayEntry], error) {
+	if opts.Limit < 0 {
+		return nil, errors.New("GetRelayEntriesPaginated: limit must be greater than or equal to 0")
+	}
+	if opts.Page < 0 {
+		return nil, errors.New("GetRelayEntriesPaginated: page must be greater than or equal to 0")
+	}
+	if opts.Limit == 0 {
+		opts.Limit = DefaultItemsPerPage
+	}
+	if opts.Page == 0 {
+		opts.Page = 1
+	}
+	offset := (opts.Page - 1) * opts.Limit
+
+	rows, err := d.q.GetRelayEntriesPaginated(ctx, repository.GetRelayEntriesPaginatedParams{
+		Status:      string(opts.Status),
+		OffsetCount: int32(offset),
+		LimitCount:  int32(opts.Limit),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("GetRelayEntriesPaginated: GetRelayEntriesPaginated: %w", err)
+	}
+	count, err := d.q.CountRelayEntries(ctx, string(opts.Status))
+	if err != nil {
+		return nil, fmt.Errorf("GetRelayEntriesPaginated: CountRelayEntries: %w", err)
+	}
+
+	entries := make([]*models.RelayEntry, len(rows))
+	for i, row := range rows {
+		entries[i] = relayEntryFromRow(row)
+	}
+	return &db.PaginatedResponse[*models.RelayEntry]{
+		Items:        entries,
+		TotalCount:   count,
+		ItemsPerPage: int32(opts.Limit),
+		HasNextPage:  int64(offset+len(entries)) < count,
+		CurrentPage:  int32(opts.Page),
+	}, nil
+}
+
+func (d *Psql) UpdateRelayEntryAttempt(ctx context.Context, opts db.UpdateRelayEntryAttemptOpts) error {
+	if opts.ID == 0 {
+		return errors.New("UpdateRelayEntryAttempt: required parameter ID missing")
+	}
+	err := d.q.UpdateRelayEntryAttempt(ctx, repository.UpdateRelayEntryAttemptParams{
+		ID:            opts.ID,
+		Status:        string(opts.Status),
+		Attempts:      opts.Attempts,
+		NextAttemptAt: opts.NextAttemptAt,
+		LastError:     pgtype.Text{String: opts.LastError, Valid: opts.LastError != ""},
+	})
+	if err != nil {
+		return fmt.Errorf("UpdateRelayEntryAttempt: %w", err)
+	}
+	return nil
+}
+
+// RetryRelayEntry resets an entry so that it is delivered on the next pass of the relay worker.
+// Returns pgx.ErrNoRows when the entry does not exist.
+func (d *Psql) RetryRelayEntry(ctx context.Context, id int32) error {
+	n, err := d.q.RetryRelayEntry(ctx, id)
+	if err != nil {
+		return fmt.Errorf("RetryRelayEntry: %w", err)
+	}
+	if n == 0 {
+		return fmt.Errorf("RetryRelayEntry: %w", pgx.ErrNoRows)
+	}
+	return nil
+}
+
+// RetryDeadRelayEntries resets all dead entries and returns how many were reset.
+func (d *Psql) RetryDeadRelayEntries(ctx context.Context) (int64, error) {
+	n, err := d.q.RetryDeadRelayEntries(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("RetryDeadRelayEntries: %w", err)
+	}
+	return n, nil
+}
+
+// Returns pgx.ErrNoRows when the entry does not exist.
+func (d *Psql) DeleteRelayEntry(ctx context.Context, id int32) error {
+	n, err := d.q.DeleteRelayEntry(ctx, id)
+	if err != nil {
+		return fmt.Errorf("DeleteRelayEntry: %w", err)
+	}
+	if n == 0 {
+		return fmt.Errorf("DeleteRelayEntry: %w", pgx.ErrNoRows)
+	}
+	return nil
+}
+
+// PurgeRelayEntries deletes all entries with the given status and returns how many were deleted.
+func (d *Psql) PurgeRelayEntries(ctx context.Context, status models.RelayStatus) (int64, error) {
+	if status == "" {
+		return 0, errors.New("PurgeRelayEntries: required parameter status missing")
+	}
+	n, err := d.q.DeleteRelayEntriesByStatus(ctx, string(status))
+	if err != nil {
+		return 0, fmt.Errorf("PurgeRelayEntries: %w", err)
+	}
+	return n, nil
+}
+
+func relayTargetFromRow(row repository.RelayTarget) *models.RelayTarget {
+	return &models.RelayTarget{
+		ID:              row.ID,
+		UserID:          row.UserID,
+		Kind:            models.RelayTargetKind(row.Kind),
+		Label:           row.Label,
+		URL:             row.Url,
+		Token:           row.Token,
+		ApiKey:          row.ApiKey,
+		ApiSecret:       row.ApiSecret,
+		Enabled:         row.Enabled,
+		RelayImports:    row.RelayImports,
+		RelayPlayingNow: row.RelayPlayingNow,
+		IncludeClients:  row.IncludeClients,
+		ExcludeClients:  row.ExcludeClients,
+		CreatedAt:       row.CreatedAt,
+	}
+}
+
+func (d *Psql) SaveRelayTarget(ctx context.Context, opts db.SaveRelayTargetOpts) (*models.RelayTarget, error) {
+	if opts.UserID == 0 {
+		return nil, errors.New("SaveRelayTarget: required parameter UserID missing")
+	}
+	if opts.Kind == "" {
+		return nil, errors.New("SaveRelayTarget: required parameter Kind missing")
+	}
+	row, err := d.q.InsertRelayTarget(ctx, repository.InsertRelayTargetParams{
+		UserID:          opts.UserID,
+		Kind:            string(opts.Kind),
+		Label:           opts.Label,
+		Url:             opts.URL,
+		Token:           opts.Token,
+		ApiKey:          opts.ApiKey,
+		ApiSecret:       opts.ApiSecret,
+		Enabled:         opts.Enabled,
+		RelayImports:    opts.RelayImports,
+		RelayPlayingNow: opts.RelayPlayingNow,
+		IncludeClients:  nonNilStrings(opts.IncludeClients),
+		ExcludeClients:  nonNilStrings(opts.ExcludeClients),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("SaveRelayTarget: InsertRelayTarget: %w", err)
+	}
+	return relayTargetFromRow(row), nil
+}
+
+// Returns nil, nil when no database entries are found
+func (d *Psql) GetRelayTarget(ctx context.Context, id int32) (*models.RelayTarget, error) {
+	row, err := d.q.GetRelayTarget(ctx, id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("GetRelayTarget: %w", err)
+	}
+	return relayTargetFromRow(row), nil
+}
+
+func (d *Psql) GetRelayTargetsByUserID(ctx context.Context, userID int32) ([]*models.RelayTarget, error) {
+	rows, err := d.q.GetRelayTargetsByUserID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("GetRelayTargetsByUserID: %w", err)
+	}
+	targets := make([]*models.RelayTarget, len(rows))
+	for i, row := range rows {
+		targets[i] = relayTargetFromRow(row)
+	}
+	return targets, nil
+}
+
+// Returns pgx.ErrNoRows when the target does not exist or belongs to another user.
+func (d *Psql) UpdateRelayTarget(ctx context.Context, opts db.UpdateRelayTargetOpts) error {
+	if opts.ID == 0 {
+		return errors.New("UpdateRelayTarget: required parameter ID missing")
+	}
+	n, err := d.q.UpdateRelayTarget(ctx, repository.UpdateRelayTargetParams{
+		ID:              opts.ID,
+		UserID:          opts.UserID,
+		Label:           opts.Label,
+		Url:             opts.URL,
+		Token:           opts.Token,
+		ApiKey:          opts.ApiKey,
+		ApiSecret:       opts.ApiSecret,
+		Enabled:         opts.Enabled,
+		RelayImports:    opts.RelayImports,
+		RelayPlayingNow: opts.RelayPlayingNow,
+		IncludeClients:  nonNilStrings(opts.IncludeClients),
+		ExcludeClients:  nonNilStrings(opts.ExcludeClients),
+	})
+	if err != nil {
+		return fmt.Errorf("UpdateRelayTarget: %w", err)
+	}
+	if n == 0 {
+		return fmt.Errorf("UpdateRelayTarget: %w", pgx.ErrNoRows)
+	}
+	return nil
+}
+
+// Deletes the target along with any of its undelivered entries. Returns pgx.ErrNoRows when
+// the target does not exist or belongs to another user.
+func (d *Psql) DeleteRelayTarget(ctx context.Context, id int32, userID int32) error {
+	n, err := d.q.DeleteRelayTarget(ctx, repository.DeleteRelayTargetParams{
+		ID:     id,
+		UserID: userID,
+	})
+	if err != nil {
+		return fmt.Errorf("DeleteRelayTarget: %w", err)
+	}
+	if n == 0 {
+		return fmt.Errorf("DeleteRelayTarget: %w", pgx.ErrNoRows)
+	}
+	return nil
+}
+
+// text[] columns are NOT NULL, so nil slices are stored as empty arrays
+func nonNilStrings(s []string) []string {
+	if s == nil {
+		return []string{}
+	}
+	return s
+}
@@ -0,0 +1,194 @@
+package psql_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gabehf/koito/internal/db"
+	"github.com/gabehf/koito/internal/models"
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func truncateTestDataForRelay(t *testing.T) {
+	err := store.Exec(context.Background(),
+		`TRUNCATE
+			relay_outbox
+			RESTART IDENTITY CASCADE`,
+	)
+	require.NoError(t, err)
+}
+
+func TestSaveAndGetRelayEntry(t *testing.T) {
+	ctx := context.Background()
+	truncateTestDataForRelay(t)
+
+	entry, err := store.SaveRelayEntry(ctx, db.SaveRelayEntryOpts{
+		UserID:  1,
+		Payload: []byte(`{"listen_type":"single"}`),
+	})
+	require.NoError(t, err)
+	require.NotNil(t, entry)
+	assert.Equal(t, models.RelayStatusPending, entry.Status)
+	assert.EqualValues(t, 0, entry.Attempts)
+	assert.JSONEq(t, `{"listen_type":"single"}`, string(entry.Payload))
+
+	got, err := store.GetRelayEntry(ctx, entry.ID)
+	require.NoError(t, err)
+	require.NotNil(t, got)
+	assert.Equal(t, entry.ID, got.ID)
+
+	// Missing entries return nil, nil
+	got, err = store.GetRelayEntry(ctx, 9999)
+	require.NoError(t, err)
+	assert.Nil(t, got)
+
+	truncateTestDataForRelay(t)
+}
+
+func TestRelayEntryLifecycle(t *testing.T) {
+	ctx := context.Background()
+	truncateTestDataForRelay(t)
+
+	e1, err := store.SaveRelayEntry(ctx, db.SaveRelayEntryOpts{UserID: 1, Payload: []byte(`{}`)})
+	require.NoError(t, err)
+	e2, err := store.SaveRelayEntry(ctx, db.SaveRelayEntryOpts{UserID: 1, Payload: []byte(`{}`)})
+	require.NoError(t, err)
+
+	due, err := store.GetDueRelayEntries(ctx, 10)
+	require.NoError(t, err)
+	require.Len(t, due, 2)
+	assert.Equal(t, e1.ID, due[0].ID)
+
+	// Push one entry into the future and mark the other as dead
+	require.NoError(t, store.UpdateRelayEntryAttempt(ctx, db.UpdateRelayEntryAttemptOpts{
+		ID:            e1.ID,
+		Status:        models.RelayStatusPending,
+		Attempts:      1,
+		NextAttemptAt: time.Now().Add(time.Hour),
+		LastError:     "connection refused",
+	}))
+	require.NoError(t, store.UpdateRelayEntryAttempt(ctx, db.UpdateRelayEntryAttemptOpts{
+		ID:            e2.ID,
+		Status:        models.RelayStatusDead,
+		Attempts:      1,
+		NextAttemptAt: time.Now(),
+		LastError:     "400 Bad Request",
+	}))
+
+	due, err = store.GetDueRelayEntries(ctx, 10)
+	require.NoError(t, err)
+	assert.Len(t, due, 0)
+
+	dead, err := store.GetRelayEntriesPaginated(ctx, db.GetRelayEntriesOpts{Status: models.RelayStatusDead})
+	require.NoError(t, err)
+	require.Len(t, dead.Items, 1)
+	assert.EqualValues(t, 1, dead.TotalCount)
+	assert.Equal(t, "400 Bad Request", dead.Items[0].LastError)
+
+	all, err := store.GetRelayEntriesPaginated(ctx, db.GetRelayEntriesOpts{})
+	require.NoError(t, err)
+	assert.EqualValues(t, 2, all.TotalCount)
+
+	// Retrying resets the schedule and attempts
+	require.NoError(t, store.RetryRelayEntry(ctx, e1.ID))
+	n, err := store.RetryDeadRelayEntries(ctx)
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, n)
+	due, err = store.GetDueRelayEntries(ctx, 10)
+	require.NoError(t, err)
+	require.Len(t, due, 2)
+	assert.EqualValues(t, 0, due[0].Attempts)
+
+	err = store.RetryRelayEntry(ctx, 9999)
+	assert.ErrorIs(t, err, pgx.ErrNoRows)
+
+	require.NoError(t, store.DeleteRelayEntry(ctx, e1.ID))
+	err = store.DeleteRelayEntry(ctx, e1.ID)
+	assert.ErrorIs(t, err, pgx.ErrNoRows)
+
+	require.NoError(t, store.UpdateRelayEntryAttempt(ctx, db.UpdateRelayEntryAttemptOpts{
+		ID:            e2.ID,
+		Status:        models.RelayStatusDead,
+		Attempts:      10,
+		NextAttemptAt: time.Now(),
+	}))
+	n, err = store.PurgeRelayEntries(ctx, models.RelayStatusDead)
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, n)
+
+	count, err := store.Count(ctx, `SELECT COUNT(*) FROM relay_outbox`)
+	require.NoError(t, err)
+	assert.Equal(t, 0, count)
+
+	truncateTestDataForRelay(t)
+}
+
+func TestRelayTargets(t *testing.T) {
+	ctx := context.Background()
+	truncateTestDataForRelay(t)
+	err := store.Exec(ctx, `TRUNCATE relay_targets RESTART IDENTITY CASCADE`)
+	require.NoError(t, err)
+
+	target, err := store.SaveRelayTarget(ctx, db.SaveRelayTargetOpts{
+		UserID:          1,
+		Kind:            models.RelayTargetListenBrainz,
+		Label:           "ListenBrainz",
+		Token:           "token",
+		Enabled:         true,
+		RelayImports:    false,
+		RelayPlayingNow: true,
+		ExcludeClients:  []string{"Navidrome"},
+	})
+	require.NoError(t, err)
+	require.NotNil(t, target)
+	assert.Equal(t, models.RelayTargetListenBrainz, target.Kind)
+	assert.False(t, target.RelayImports)
+	assert.Equal(t, []string{}, target.IncludeClients)
+	assert.Equal(t, []string{"Navidrome"}, target.ExcludeClients)
+
+	targets, err := store.GetRelayTargetsByUserID(ctx, 1)
+	require.NoError(t, err)
+	require.Len(t, targets, 1)
+	assert.Equal(t, "token", targets[0].Token)
+
+	require.NoError(t, store.UpdateRelayTarget(ctx, db.UpdateRelayTargetOpts{
+		ID:             target.ID,
+		UserID:         1,
+		Label:          "Renamed",
+		Token:          "token",
+		Enabled:        false,
+		IncludeClients: []string{"Jellyfin"},
+	}))
+	got, err := store.GetRelayTarget(ctx, target.ID)
+	require.NoError(t, err)
+	require.NotNil(t, got)
+	assert.Equal(t, "Renamed", got.Label)
+	assert.False(t, got.Enabled)
+	assert.Equal(t, []string{"Jellyfin"}, got.IncludeClients)
+	assert.Equal(t, []string{}, got.ExcludeClients)
+
+	// Targets can only be changed by their owner
+	err = store.UpdateRelayTarget(ctx, db.UpdateRelayTargetOpts{ID: target.ID, UserID: 2})
+	assert.ErrorIs(t, err, pgx.ErrNoRows)
+	err = store.DeleteRelayTarget(ctx, target.ID, 2)
+	assert.ErrorIs(t, err, pgx.ErrNoRows)
+
+	// Deleting a target removes its outbox entries
+	entry, err := store.SaveRelayEntry(ctx, db.SaveRelayEntryOpts{UserID: 1, TargetID: target.ID, Payload: []byte(`{}`)})
+	require.NoError(t, err)
+	require.NotNil(t, entry.TargetID)
+	assert.Equal(t, target.ID, *entry.TargetID)
+
+	require.NoError(t, store.DeleteRelayTarget(ctx, target.ID, 1))
+	got, err = store.GetRelayTarget(ctx, target.ID)
+	require.NoError(t, err)
+	assert.Nil(t, got)
+	count, err := store.Count(ctx, `SELECT COUNT(*) FROM relay_outbox`)
+	require.NoError(t, err)
+	assert.Equal(t, 0, count)
+
+	truncateTestDataForRelay(t)
+}
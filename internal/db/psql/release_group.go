@@ -0,0 +1,147 @@
+package psql
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/gabehf/koito/internal/db"
+	"github.com/gabehf/koito/internal/logger"
+	"github.com/gabehf/koito/internal/models"
+	"github.com/gabehf/koito/internal/repository"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+func releaseGroupID(id pgtype.Int4) *int32 {
+	if !id.Valid {
+		return nil
+	}
+	return &id.Int32
+}
+
+// GetReleaseGroup returns the release group along with its editions, most listened to first.
+func (d *Psql) GetReleaseGroup(ctx context.Context, opts db.GetReleaseGroupOpts) (*models.ReleaseGroup, error) {
+	l := logger.FromContext(ctx)
+	var row repository.ReleaseGroup
+	var err error
+
+	if opts.MusicBrainzID != uuid.Nil {
+		l.Debug().Msgf("Fetching release group from DB with MusicBrainz Release Group ID %s", opts.MusicBrainzID)
+		row, err = d.q.GetReleaseGroupByMbzID(ctx, &opts.MusicBrainzID)
+	} else {
+		if opts.AlbumID != 0 {
+			l.Debug().Msgf("Fetching release group from DB with album id %d", opts.AlbumID)
+			album, err := d.q.GetRelease(ctx, opts.AlbumID)
+			if err != nil {
+				return nil, fmt.Errorf("GetReleaseGroup: GetRelease: %w", err)
+			}
+			if !album.ReleaseGroupID.Valid {
+				return nil, fmt.Errorf("GetReleaseGroup: album is not part of a release group: %w", pgx.ErrNoRows)
+			}
+			opts.ID = album.ReleaseGroupID.Int32
+		}
+		l.Debug().Msgf("Fetching release group from DB with id %d", opts.ID)
+		row, err = d.q.GetReleaseGroup(ctx, opts.ID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("GetReleaseGroup: %w", err)
+	}
+
+	rows, err := d.q.GetReleaseGroupEditions(ctx, repository.GetReleaseGroupEditionsParams{
+		UserID:         opts.UserID,
+		ReleaseGroupID: row.ID,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("GetReleaseGroup: GetReleaseGroupEditions: %w", err)
+	}
+
+	ret := &models.ReleaseGroup{
+		ID:       row.ID,
+		MbzID:    row.MusicBrainzID,
+		Title:    row.Title,
+		Editions: make([]*models.Album, len(rows)),
+	}
+	for i, v := range rows {
+		edition := &models.Album{
+			ID:             v.ID,
+			MbzID:          v.MusicBrainzID,
+			Title:          v.Title,
+			Image:          v.Image,
+			VariousArtists: v.VariousArtists,
+			ReleaseGroupID: releaseGroupID(v.ReleaseGroupID),
+			ListenCount:    v.ListenCount,
+		}
+		if err := json.Unmarshal(v.Artists, &edition.Artists); err != nil {
+			return nil, fmt.Errorf("GetReleaseGroup: json.Unmarshal: %w", err)
+		}
+		seconds, err := d.CountTimeListenedToItem(ctx, db.TimeListenedOpts{
+			UserID:    opts.UserID,
+			Timeframe: db.PeriodToTimeframe(db.PeriodAllTime),
+			AlbumID:   v.ID,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("GetReleaseGroup: CountTimeListenedToItem: %w", err)
+		}
+		edition.TimeListened = seconds
+		ret.Editions[i] = edition
+		ret.ListenCount += edition.ListenCount
+		ret.TimeListened += edition.TimeListened
+	}
+
+	return ret, nil
+}
+
+// SaveReleaseGroup creates a release group, or returns the existing one with the same
+// MusicBrainz ID.
+func (d *Psql) SaveReleaseGroup(ctx context.Context, opts db.SaveReleaseGroupOpts) (*models.ReleaseGroup, error) {
+	l := logger.FromContext(ctx)
+	if opts.Title == "" {
+		return nil, errors.New("SaveReleaseGroup: required parameter 'Title' missing")
+	}
+	var mbzId *uuid.UUID
+	if opts.MusicBrainzID != uuid.Nil {
+		mbzId = &opts.MusicBrainzID
+	}
+	l.Debug().Msgf("Inserting release group '%s' into DB", opts.Title)
+	row, err := d.q.InsertReleaseGroup(ctx, repository.InsertReleaseGroupParams{
+		MusicBrainzID: mbzId,
+		Title:         opts.Title,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("SaveReleaseGroup: InsertReleaseGroup: %w", err)
+	}
+	return &models.ReleaseGroup{
+		ID:       row.ID,
+		MbzID:    row.MusicBrainzID,
+		Title:    row.Title,
+		Editions: []*models.Album{},
+	}, nil
+}
+
+func (d *Psql) SetAlbumReleaseGroup(ctx context.Context, opts db.SetAlbumReleaseGroupOpts) error {
+	l := logger.FromContext(ctx)
+	if opts.AlbumID == 0 {
+		return errors.New("SetAlbumReleaseGroup: album id not specified")
+	}
+	rgId := pgtype.Int4{Int32: opts.ReleaseGroupID, Valid: opts.ReleaseGroupID != 0}
+	l.Debug().Msgf("Setting release group of album with id %d to %d", opts.AlbumID, opts.ReleaseGroupID)
+	var err error
+	if opts.KeepExisting {
+		err = d.q.UpdateReleaseReleaseGroupIfUnset(ctx, repository.UpdateReleaseReleaseGroupIfUnsetParams{
+			ID:             opts.AlbumID,
+			ReleaseGroupID: rgId,
+		})
+	} else {
+		err = d.q.UpdateReleaseReleaseGroup(ctx, repository.UpdateReleaseReleaseGroupParams{
+			ID:             opts.AlbumID,
+			ReleaseGroupID: rgId,
+		})
+	}
+	if err != nil {
+		return fmt.Errorf("SetAlbumReleaseGroup: %w", err)
+	}
+	return nil
+}
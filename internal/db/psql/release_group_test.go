@@ -0,0 +1,93 @@
+package psql_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/gabehf/koito/internal/db"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReleaseGroups(t *testing.T) {
+	testDataForTopItems(t)
+	ctx := context.Background()
+
+	mbzId := uuid.MustParse("00000000-0000-0000-0000-000000000333")
+	rg, err := store.SaveReleaseGroup(ctx, db.SaveReleaseGroupOpts{Title: "Release Three", MusicBrainzID: mbzId})
+	require.NoError(t, err)
+	assert.Equal(t, "Release Three", rg.Title)
+
+	// saving a group with the same mbid returns the existing group
+	again, err := store.SaveReleaseGroup(ctx, db.SaveReleaseGroupOpts{Title: "Something Else", MusicBrainzID: mbzId})
+	require.NoError(t, err)
+	assert.Equal(t, rg.ID, again.ID)
+
+	_, err = store.SaveReleaseGroup(ctx, db.SaveReleaseGroupOpts{})
+	assert.Error(t, err)
+
+	require.NoError(t, store.SetAlbumReleaseGroup(ctx, db.SetAlbumReleaseGroupOpts{AlbumID: 3, ReleaseGroupID: rg.ID}))
+	require.NoError(t, store.SetAlbumReleaseGroup(ctx, db.SetAlbumReleaseGroupOpts{AlbumID: 4, ReleaseGroupID: rg.ID}))
+
+	// editions are ordered by listen count
+	group, err := store.GetReleaseGroup(ctx, db.GetReleaseGroupOpts{UserID: 1, AlbumID: 4})
+	require.NoError(t, err)
+	assert.Equal(t, rg.ID, group.ID)
+	require.Len(t, group.Editions, 2)
+	assert.EqualValues(t, 3, group.Editions[0].ID)
+	assert.EqualValues(t, 4, group.Editions[1].ID)
+	assert.EqualValues(t, 3, group.ListenCount)
+	assert.EqualValues(t, 300, group.TimeListened)
+
+	group, err = store.GetReleaseGroup(ctx, db.GetReleaseGroupOpts{UserID: 1, MusicBrainzID: mbzId})
+	require.NoError(t, err)
+	assert.Equal(t, rg.ID, group.ID)
+
+	album, err := store.GetAlbum(ctx, db.GetAlbumOpts{ID: 3})
+	require.NoError(t, err)
+	require.NotNil(t, album.ReleaseGroupID)
+	assert.Equal(t, rg.ID, *album.ReleaseGroupID)
+
+	// editions are counted together
+	resp, err := store.GetTopAlbumsPaginated(ctx, db.GetItemsOpts{UserID: 1, Timeframe: db.PeriodToTimeframe(db.PeriodAllTime), ByReleaseGroup: true})
+	require.NoError(t, err)
+	require.Len(t, resp.Items, 3)
+	assert.EqualValues(t, 3, resp.TotalCount)
+	assert.Equal(t, "Release One", resp.Items[0].Item.Title)
+	assert.Equal(t, "Release Two", resp.Items[1].Item.Title)
+	assert.Equal(t, "Release Three", resp.Items[2].Item.Title)
+	assert.EqualValues(t, 3, resp.Items[2].Item.ID)
+	assert.EqualValues(t, 3, resp.Items[2].ListenCount)
+	assert.EqualValues(t, 2, resp.Items[2].Item.EditionCount)
+
+	// only listens in the timeframe are counted
+	resp, err = store.GetTopAlbumsPaginated(ctx, db.GetItemsOpts{UserID: 1, Timeframe: db.PeriodToTimeframe(db.PeriodMonth), ByReleaseGroup: true})
+	require.NoError(t, err)
+	require.Len(t, resp.Items, 1)
+	assert.EqualValues(t, 3, resp.Items[0].ListenCount)
+
+	// albums that already have a release group keep it
+	other, err := store.SaveReleaseGroup(ctx, db.SaveReleaseGroupOpts{Title: "Release Four"})
+	require.NoError(t, err)
+	require.NoError(t, store.SetAlbumReleaseGroup(ctx, db.SetAlbumReleaseGroupOpts{AlbumID: 4, ReleaseGroupID: other.ID, KeepExisting: true}))
+	album, err = store.GetAlbum(ctx, db.GetAlbumOpts{ID: 4})
+	require.NoError(t, err)
+	require.NotNil(t, album.ReleaseGroupID)
+	assert.Equal(t, rg.ID, *album.ReleaseGroupID)
+
+	// removing an album from its group
+	require.NoError(t, store.SetAlbumReleaseGroup(ctx, db.SetAlbumReleaseGroupOpts{AlbumID: 4}))
+	_, err = store.GetReleaseGroup(ctx, db.GetReleaseGroupOpts{UserID: 1, AlbumID: 4})
+	assert.ErrorIs(t, err, pgx.ErrNoRows)
+	resp, err = store.GetTopAlbumsPaginated(ctx, db.GetItemsOpts{UserID: 1, Timeframe: db.PeriodToTimeframe(db.PeriodAllTime), ByReleaseGroup: true})
+	require.NoError(t, err)
+	assert.Len(t, resp.Items, 4)
+
+	// grouped top albums from an artist
+	resp, err = store.GetTopAlbumsPaginated(ctx, db.GetItemsOpts{UserID: 1, Timeframe: db.PeriodToTimeframe(db.PeriodAllTime), ByReleaseGroup: true, ArtistID: 3})
+	require.NoError(t, err)
+	require.Len(t, resp.Items, 1)
+	assert.Equal(t, "Release Three", resp.Items[0].Item.Title)
+}
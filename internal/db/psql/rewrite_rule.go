@@ -0,0 +1,114 @@
+package psql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/gabehf/koito/internal/db"
+	"github.com/gabehf/koito/internal/models"
+	"github.com/gabehf/koito/internal/repository"
+	"github.com/jackc/pgx/v5"
+)
+
+func rewriteRuleFromRow(row repository.RewriteRule) *models.RewriteRule {
+	return &models.RewriteRule{
+		ID:          row.ID,
+		UserID:      row.UserID,
+		Position:    row.Position,
+		Enabled:     row.Enabled,
+		Field:       models.RewriteField(row.Field),
+		MatchType:   models.RewriteMatchType(row.MatchType),
+		Pattern:     row.Pattern,
+		Action:      models.RewriteAction(row.Action),
+		TargetField: models.RewriteField(row.TargetField),
+		Replacement: row.Replacement,
+		CreatedAt:   row.CreatedAt,
+	}
+}
+
+// SaveRewriteRule adds a rule after the user's existing rules.
+func (d *Psql) SaveRewriteRule(ctx context.Context, opts db.SaveRewriteRuleOpts) (*models.RewriteRule, error) {
+	if opts.UserID == 0 {
+		return nil, errors.New("SaveRewriteRule: required parameter UserID missing")
+	}
+	row, err := d.q.InsertRewriteRule(ctx, repository.InsertRewriteRuleParams{
+		UserID:      opts.UserID,
+		Enabled:     opts.Enabled,
+		Field:       string(opts.Field),
+		MatchType:   string(opts.MatchType),
+		Pattern:     opts.Pattern,
+		Action:      string(opts.Action),
+		TargetField: string(opts.TargetField),
+		Replacement: opts.Replacement,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("SaveRewriteRule: InsertRewriteRule: %w", err)
+	}
+	return rewriteRuleFromRow(row), nil
+}
+
+// Returns nil, nil when no database entries are found
+func (d *Psql) GetRewriteRule(ctx context.Context, id int32) (*models.RewriteRule, error) {
+	row, err := d.q.GetRewriteRule(ctx, id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("GetRewriteRule: %w", err)
+	}
+	return rewriteRuleFromRow(row), nil
+}
+
+// GetRewriteRulesByUserID returns the user's rules in the order they are applied.
+func (d *Psql) GetRewriteRulesByUserID(ctx context.Context, userID int32) ([]*models.RewriteRule, error) {
+	rows, err := d.q.GetRewriteRulesByUserID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("GetRewriteRulesByUserID: %w", err)
+	}
+	rules := make([]*models.RewriteRule, len(rows))
+	for i, row := range rows {
+		rules[i] = rewriteRuleFromRow(row)
+	}
+	return rules, nil
+}
+
+// Returns pgx.ErrNoRows when the rule does not exist or belongs to another user.
+func (d *Psql) UpdateRewriteRule(ctx context.Context, opts db.UpdateRewriteRuleOpts) error {
+	if opts.ID == 0 {
+		return errors.New("UpdateRewriteRule: required parameter ID missing")
+	}
+	n, err := d.q.UpdateRewriteRule(ctx, repository.UpdateRewriteRuleParams{
+		ID:          opts.ID,
+		UserID:      opts.UserID,
+		Position:    opts.Position,
+		Enabled:     opts.Enabled,
+		Field:       string(opts.Field),
+		MatchType:   string(opts.MatchType),
+		Pattern:     opts.Pattern,
+		Action:      string(opts.Action),
+		TargetField: string(opts.TargetField),
+		Replacement: opts.Replacement,
+	})
+	if err != nil {
+		return fmt.Errorf("UpdateRewriteRule: %w", err)
+	}
+	if n == 0 {
+		return fmt.Errorf("UpdateRewriteRule: %w", pgx.ErrNoRows)
+	}
+	return nil
+}
+
+// Returns pgx.ErrNoRows when the rule does not exist or belongs to another user.
+func (d *Psql) DeleteRewriteRule(ctx context.Context, id int32, userID int32) error {
+	n, err := d.q.DeleteRewriteRule(ctx, repository.DeleteRewriteRuleParams{
+		ID:     id,
+		UserID: userID,
+	})
+	if err != nil {
+		return fmt.Errorf("DeleteRewriteRule: %w", err)
+	}
+	if n == 0 {
+		return fmt.Errorf("DeleteRewriteRule: %w", pgx.ErrNoRows)
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package psql_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/gabehf/koito/internal/db"
+	"github.com/gabehf/koito/internal/models"
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func truncateTestDataForRewriteRules(t *testing.T) {
+	err := store.Exec(context.Background(),
+		`TRUNCATE
+			rewrite_rules
+			RESTART IDENTITY CASCADE`,
+	)
+	require.NoError(t, err)
+}
+
+func TestRewriteRules(t *testing.T) {
+	ctx := context.Background()
+	truncateTestDataForRewriteRules(t)
+
+	r1, err := store.SaveRewriteRule(ctx, db.SaveRewriteRuleOpts{
+		UserID:    1,
+		Enabled:   true,
+		Field:     models.RewriteFieldTrack,
+		MatchType: models.RewriteMatchRegex,
+		Pattern:   ` - \d{4} Remaster$`,
+		Action:    models.RewriteActionStripSuffix,
+	})
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, r1.Position)
+
+	r2, err := store.SaveRewriteRule(ctx, db.SaveRewriteRuleOpts{
+		UserID:    1,
+		Enabled:   true,
+		Field:     models.RewriteFieldClient,
+		MatchType: models.RewriteMatchExact,
+		Pattern:   "spotify",
+		Action:    models.RewriteActionDrop,
+	})
+	require.NoError(t, err)
+	assert.EqualValues(t, 2, r2.Position)
+
+	// Move the second rule in front of the first
+	require.NoError(t, store.UpdateRewriteRule(ctx, db.UpdateRewriteRuleOpts{
+		ID:        r2.ID,
+		UserID:    1,
+		Position:  0,
+		Enabled:   false,
+		Field:     r2.Field,
+		MatchType: r2.MatchType,
+		Pattern:   "Spotify",
+		Action:    r2.Action,
+	}))
+
+	rules, err := store.GetRewriteRulesByUserID(ctx, 1)
+	require.NoError(t, err)
+	require.Len(t, rules, 2)
+	assert.Equal(t, r2.ID, rules[0].ID)
+	assert.Equal(t, "Spotify", rules[0].Pattern)
+	assert.False(t, rules[0].Enabled)
+	assert.Equal(t, r1.ID, rules[1].ID)
+
+	got, err := store.GetRewriteRule(ctx, r1.ID)
+	require.NoError(t, err)
+	require.NotNil(t, got)
+	assert.Equal(t, models.RewriteActionStripSuffix, got.Action)
+
+	// Rules can only be changed by their owner
+	err = store.UpdateRewriteRule(ctx, db.UpdateRewriteRuleOpts{ID: r1.ID, UserID: 2})
+	assert.ErrorIs(t, err, pgx.ErrNoRows)
+	err = store.DeleteRewriteRule(ctx, r1.ID, 2)
+	assert.ErrorIs(t, err, pgx.ErrNoRows)
+
+	require.NoError(t, store.DeleteRewriteRule(ctx, r1.ID, 1))
+	got, err = store.GetRewriteRule(ctx, r1.ID)
+	require.NoError(t, err)
+	assert.Nil(t, got)
+
+	truncateTestDataForRewriteRules(t)
+}
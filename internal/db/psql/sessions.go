@@ -6,29 +6,39 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/gabehf/koito/internal/db"
 	"github.com/gabehf/koito/internal/models"
 	"github.com/gabehf/koito/internal/repository"
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
 )
 
-func (d *Psql) SaveSession(ctx context.Context, userID int32, expiresAt time.Time, persistent bool) (*models.Session, error) {
+func sessionFromRow(row repository.Session) *models.Session {
+	return &models.Session{
+		ID:         row.ID,
+		UserID:     row.UserID,
+		CreatedAt:  row.CreatedAt,
+		ExpiresAt:  row.ExpiresAt,
+		Persistent: row.Persistent,
+		UserAgent:  row.UserAgent,
+		IP:         row.Ip,
+		LastSeenAt: row.LastSeenAt,
+	}
+}
+
+func (d *Psql) SaveSession(ctx context.Context, opts db.SaveSessionOpts) (*models.Session, error) {
 	session, err := d.q.InsertSession(ctx, repository.InsertSessionParams{
 		ID:         uuid.New(),
-		UserID:     userID,
-		ExpiresAt:  expiresAt,
-		Persistent: persistent,
+		UserID:     opts.UserID,
+		ExpiresAt:  opts.ExpiresAt,
+		Persistent: opts.Persistent,
+		UserAgent:  opts.UserAgent,
+		Ip:         opts.IP,
 	})
 	if err != nil {
 		return nil, fmt.Errorf("SaveSession: InsertSession: %w", err)
 	}
-	return &models.Session{
-		ID:         session.ID,
-		UserID:     session.UserID,
-		CreatedAt:  session.CreatedAt,
-		ExpiresAt:  session.ExpiresAt,
-		Persistent: session.Persistent,
-	}, nil
+	return sessionFromRow(session), nil
 }
 
 func (d *Psql) GetSession(ctx context.Context, sessionId uuid.UUID) (*models.Session, error) {
@@ -36,19 +46,28 @@ func (d *Psql) GetSession(ctx context.Context, sessionId uuid.UUID) (*models.Ses
 	if err != nil {
 		return nil, fmt.Errorf("GetSession: %w", err)
 	}
-	return &models.Session{
-		ID:         session.ID,
-		UserID:     session.UserID,
-		CreatedAt:  session.CreatedAt,
-		ExpiresAt:  session.ExpiresAt,
-		Persistent: session.Persistent,
-	}, nil
+	return sessionFromRow(session), nil
+}
+
+// GetSessionsByUserID returns the user's sessions that have not expired, most recently used first.
+func (d *Psql) GetSessionsByUserID(ctx context.Context, userID int32) ([]*models.Session, error) {
+	rows, err := d.q.GetSessionsByUserID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("GetSessionsByUserID: %w", err)
+	}
+	sessions := make([]*models.Session, len(rows))
+	for i, row := range rows {
+		sessions[i] = sessionFromRow(row)
+	}
+	return sessions, nil
 }
 
-func (d *Psql) RefreshSession(ctx context.Context, sessionId uuid.UUID, expiresAt time.Time) error {
+func (d *Psql) RefreshSession(ctx context.Context, opts db.RefreshSessionOpts) error {
 	return d.q.UpdateSessionExpiry(ctx, repository.UpdateSessionExpiryParams{
-		ID:        sessionId,
-		ExpiresAt: expiresAt,
+		ID:        opts.ID,
+		ExpiresAt: opts.ExpiresAt,
+		UserAgent: opts.UserAgent,
+		Ip:        opts.IP,
 	})
 }
 
@@ -56,6 +75,38 @@ func (d *Psql) DeleteSession(ctx context.Context, sessionId uuid.UUID) error {
 	return d.q.DeleteSession(ctx, sessionId)
 }
 
+// DeleteSessionForUser deletes the session if it belongs to the user. Returns false when it does not.
+func (d *Psql) DeleteSessionForUser(ctx context.Context, userID int32, sessionId uuid.UUID) (bool, error) {
+	n, err := d.q.DeleteSessionForUser(ctx, repository.DeleteSessionForUserParams{
+		ID:     sessionId,
+		UserID: userID,
+	})
+	if err != nil {
+		return false, fmt.Errorf("DeleteSessionForUser: %w", err)
+	}
+	return n > 0, nil
+}
+
+// DeleteOtherSessionsForUser deletes all of the user's sessions except keep, and returns how many were deleted.
+func (d *Psql) DeleteOtherSessionsForUser(ctx context.Context, userID int32, keep uuid.UUID) (int64, error) {
+	n, err := d.q.DeleteOtherSessionsForUser(ctx, repository.DeleteOtherSessionsForUserParams{
+		UserID: userID,
+		ID:     keep,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("DeleteOtherSessionsForUser: %w", err)
+	}
+	return n, nil
+}
+
+func (d *Psql) DeleteExpiredSessions(ctx context.Context) (int64, error) {
+	n, err := d.q.DeleteExpiredSessions(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("DeleteExpiredSessions: %w", err)
+	}
+	return n, nil
+}
+
 // Returns nil, nil when no database entries are found
 func (d *Psql) GetUserBySession(ctx context.Context, sessionId uuid.UUID) (*models.User, error) {
 	row, err := d.q.GetUserBySession(ctx, sessionId)
@@ -66,9 +117,58 @@ func (d *Psql) GetUserBySession(ctx context.Context, sessionId uuid.UUID) (*mode
 	}
 
 	return &models.User{
-		ID:       row.ID,
-		Username: row.Username,
-		Password: row.Password,
-		Role:     models.UserRole(row.Role),
+		ID:          row.ID,
+		Username:    row.Username,
+		Password:    row.Password,
+		Role:        models.UserRole(row.Role),
+		Disabled:    row.Disabled,
+		Visibility:  models.ProfileVisibility(row.Visibility),
+		CreatedAt:   row.CreatedAt,
+		TOTPEnabled: row.TotpEnabled,
+	}, nil
+}
+
+// audioscrobblerSessionLifetime is how long an Audioscrobbler 1.2 session can be used. Clients
+// do a new handshake once they are told that their session is no longer valid.
+const audioscrobblerSessionLifetime = 30 * 24 * time.Hour
+
+// SaveAudioscrobblerSession starts an Audioscrobbler 1.2 session with the API key. Only the
+// SHA-256 of the session token is stored. Sessions that have run out are removed along the way.
+func (d *Psql) SaveAudioscrobblerSession(ctx context.Context, apiKeyID int32, token string) error {
+	err := d.q.DeleteAudioscrobblerSessionsBefore(ctx, time.Now().Add(-audioscrobblerSessionLifetime))
+	if err != nil {
+		return fmt.Errorf("SaveAudioscrobblerSession: DeleteAudioscrobblerSessionsBefore: %w", err)
+	}
+	err = d.q.InsertAudioscrobblerSession(ctx, repository.InsertAudioscrobblerSessionParams{
+		TokenHash: hashApiKey(token),
+		ApiKeyID:  apiKeyID,
+	})
+	if err != nil {
+		return fmt.Errorf("SaveAudioscrobblerSession: InsertAudioscrobblerSession: %w", err)
+	}
+	return nil
+}
+
+// GetUserByAudioscrobblerSession returns the user an Audioscrobbler 1.2 session token was handed
+// out to, as long as the session has not run out and the API key it was started with can still
+// submit listens. Returns nil, nil when no database entries are found.
+func (d *Psql) GetUserByAudioscrobblerSession(ctx context.Context, token string) (*models.User, error) {
+	row, err := d.q.GetUserByAudioscrobblerSession(ctx, repository.GetUserByAudioscrobblerSessionParams{
+		TokenHash: hashApiKey(token),
+		NotBefore: time.Now().Add(-audioscrobblerSessionLifetime),
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("GetUserByAudioscrobblerSession: %w", err)
+	}
+	return &models.User{
+		ID:         row.ID,
+		Username:   row.Username,
+		Password:   row.Password,
+		Role:       models.UserRole(row.Role),
+		Disabled:   row.Disabled,
+		Visibility: models.ProfileVisibility(row.Visibility),
+		CreatedAt:  row.CreatedAt,
 	}, nil
 }
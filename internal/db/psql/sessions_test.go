@@ -5,6 +5,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/gabehf/koito/internal/db"
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -23,12 +24,20 @@ func TestSaveSession(t *testing.T) {
 
 	// Save a session for the user
 	expiresAt := time.Now().Add(24 * time.Hour).UTC()
-	session, err := store.SaveSession(ctx, 1, expiresAt, true)
+	session, err := store.SaveSession(ctx, db.SaveSessionOpts{
+		UserID:     1,
+		ExpiresAt:  expiresAt,
+		Persistent: true,
+		UserAgent:  "Mozilla/5.0",
+		IP:         "192.0.2.1",
+	})
 	require.NoError(t, err)
 	require.NotNil(t, session)
 	assert.Equal(t, int32(1), session.UserID)
 	assert.Equal(t, true, session.Persistent)
 	assert.WithinDuration(t, expiresAt, session.ExpiresAt, time.Second)
+	assert.Equal(t, "Mozilla/5.0", session.UserAgent)
+	assert.Equal(t, "192.0.2.1", session.IP)
 
 	truncateTestDataForSessions(t)
 }
@@ -38,17 +47,34 @@ func TestRefreshSession(t *testing.T) {
 
 	// Save a session first
 	expiresAt := time.Now().Add(-1 * time.Minute)
-	session, err := store.SaveSession(ctx, 1, expiresAt, true)
+	session, err := store.SaveSession(ctx, db.SaveSessionOpts{UserID: 1, ExpiresAt: expiresAt, Persistent: true})
 	require.NoError(t, err)
 
+	// Can only retrieve a session with an expiresAt > time.Now()
+	user, err := store.GetUserBySession(ctx, session.ID)
+	require.NoError(t, err)
+	assert.Nil(t, user)
+
 	// Refresh the session expiry
 	newExpiresAt := time.Now().Add(48 * time.Hour)
-	err = store.RefreshSession(ctx, session.ID, newExpiresAt)
+	err = store.RefreshSession(ctx, db.RefreshSessionOpts{
+		ID:        session.ID,
+		ExpiresAt: newExpiresAt,
+		UserAgent: "curl/8.0",
+		IP:        "192.0.2.2",
+	})
 	require.NoError(t, err)
 
-	// Can only retrieve a session with an expiresAt > time.Now()
-	_, err = store.GetUserBySession(ctx, session.ID)
+	user, err = store.GetUserBySession(ctx, session.ID)
+	require.NoError(t, err)
+	assert.NotNil(t, user)
+
+	sessions, err := store.GetSessionsByUserID(ctx, 1)
 	require.NoError(t, err)
+	require.Len(t, sessions, 1)
+	assert.Equal(t, "curl/8.0", sessions[0].UserAgent)
+	assert.Equal(t, "192.0.2.2", sessions[0].IP)
+	assert.WithinDuration(t, time.Now(), sessions[0].LastSeenAt, time.Minute)
 
 	truncateTestDataForSessions(t)
 }
@@ -58,7 +84,7 @@ func TestDeleteSession(t *testing.T) {
 
 	// Save a session first
 	expiresAt := time.Now().Add(24 * time.Hour)
-	session, err := store.SaveSession(ctx, 1, expiresAt, true)
+	session, err := store.SaveSession(ctx, db.SaveSessionOpts{UserID: 1, ExpiresAt: expiresAt, Persistent: true})
 	require.NoError(t, err)
 
 	// Delete the session
@@ -79,7 +105,7 @@ func TestGetUserBySession(t *testing.T) {
 
 	// Save a session first
 	expiresAt := time.Now().Add(24 * time.Hour)
-	session, err := store.SaveSession(ctx, 1, expiresAt, true)
+	session, err := store.SaveSession(ctx, db.SaveSessionOpts{UserID: 1, ExpiresAt: expiresAt, Persistent: true})
 	require.NoError(t, err)
 
 	// Get the user by session
@@ -99,3 +125,60 @@ func TestGetUserBySession(t *testing.T) {
 
 	truncateTestDataForSessions(t)
 }
+
+func TestRevokeSessions(t *testing.T) {
+	ctx := context.Background()
+	truncateTestDataForSessions(t)
+
+	expiresAt := time.Now().Add(24 * time.Hour)
+	current, err := store.SaveSession(ctx, db.SaveSessionOpts{UserID: 1, ExpiresAt: expiresAt})
+	require.NoError(t, err)
+	other, err := store.SaveSession(ctx, db.SaveSessionOpts{UserID: 1, ExpiresAt: expiresAt})
+	require.NoError(t, err)
+	_, err = store.SaveSession(ctx, db.SaveSessionOpts{UserID: 1, ExpiresAt: expiresAt})
+	require.NoError(t, err)
+	_, err = store.SaveSession(ctx, db.SaveSessionOpts{UserID: 1, ExpiresAt: time.Now().Add(-time.Minute)})
+	require.NoError(t, err)
+
+	// expired sessions aren't listed
+	sessions, err := store.GetSessionsByUserID(ctx, 1)
+	require.NoError(t, err)
+	assert.Len(t, sessions, 3)
+
+	// sessions can only be revoked by their owner
+	ok, err := store.DeleteSessionForUser(ctx, 2, other.ID)
+	require.NoError(t, err)
+	assert.False(t, ok)
+	ok, err = store.DeleteSessionForUser(ctx, 1, other.ID)
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	n, err := store.DeleteOtherSessionsForUser(ctx, 1, current.ID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), n, "expected the remaining and the expired session to be deleted")
+	sessions, err = store.GetSessionsByUserID(ctx, 1)
+	require.NoError(t, err)
+	require.Len(t, sessions, 1)
+	assert.Equal(t, current.ID, sessions[0].ID)
+
+	truncateTestDataForSessions(t)
+}
+
+func TestDeleteExpiredSessions(t *testing.T) {
+	ctx := context.Background()
+	truncateTestDataForSessions(t)
+
+	_, err := store.SaveSession(ctx, db.SaveSessionOpts{UserID: 1, ExpiresAt: time.Now().Add(-time.Minute)})
+	require.NoError(t, err)
+	active, err := store.SaveSession(ctx, db.SaveSessionOpts{UserID: 1, ExpiresAt: time.Now().Add(time.Hour)})
+	require.NoError(t, err)
+
+	n, err := store.DeleteExpiredSessions(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+	count, err := store.Count(ctx, `SELECT COUNT(*) FROM sessions WHERE id = $1`, active.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+
+	truncateTestDataForSessions(t)
+}
@@ -8,10 +8,11 @@ import (
 	"github.com/gabehf/koito/internal/repository"
 )
 
-func (d *Psql) GetGenreStatsByListenCount(ctx context.Context, timeframe db.Timeframe) ([]db.GenreStat, error) {
+func (d *Psql) GetGenreStatsByListenCount(ctx context.Context, userID int32, timeframe db.Timeframe) ([]db.GenreStat, error) {
 	t1, t2 := db.TimeframeToTimeRange(timeframe)
 
 	rows, err := d.q.GetGenreStatsByListenCount(ctx, repository.GetGenreStatsByListenCountParams{
+		UserID:       userID,
 		ListenedAt:   t1,
 		ListenedAt_2: t2,
 	})
@@ -29,10 +30,11 @@ func (d *Psql) GetGenreStatsByListenCount(ctx context.Context, timeframe db.Time
 	return stats, nil
 }
 
-func (d *Psql) GetGenreStatsByTimeListened(ctx context.Context, timeframe db.Timeframe) ([]db.GenreStat, error) {
+func (d *Psql) GetGenreStatsByTimeListened(ctx context.Context, userID int32, timeframe db.Timeframe) ([]db.GenreStat, error) {
 	t1, t2 := db.TimeframeToTimeRange(timeframe)
 
 	rows, err := d.q.GetGenreStatsByTimeListened(ctx, repository.GetGenreStatsByTimeListenedParams{
+		UserID:       userID,
 		ListenedAt:   t1,
 		ListenedAt_2: t2,
 	})
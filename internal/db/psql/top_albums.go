@@ -24,11 +24,61 @@ func (d *Psql) GetTopAlbumsPaginated(ctx context.Context, opts db.GetItemsOpts)
 	var rgs []db.RankedItem[*models.Album]
 	var count int64
 
-	if opts.ArtistID != 0 {
+	if opts.ByReleaseGroup {
+		l.Debug().Msgf("Fetching top %d release groups on page %d from range %v to %v",
+			opts.Limit, opts.Page, t1.Format("Jan 02, 2006"), t2.Format("Jan 02, 2006"))
+		rows, err := d.q.GetTopReleaseGroupsPaginated(ctx, repository.GetTopReleaseGroupsPaginatedParams{
+			OffsetCount:    int32(offset),
+			LimitCount:     int32(opts.Limit),
+			ListenedAtFrom: t1,
+			ListenedAtTo:   t2,
+			UserID:         opts.UserID,
+			ArtistID:       int32(opts.ArtistID),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("GetTopAlbumsPaginated: GetTopReleaseGroupsPaginated: %w", err)
+		}
+		rgs = make([]db.RankedItem[*models.Album], len(rows))
+		l.Debug().Msgf("Database responded with %d items", len(rows))
+		for i, row := range rows {
+			artists := make([]models.SimpleArtist, 0)
+			err = json.Unmarshal(row.Artists, &artists)
+			if err != nil {
+				l.Err(err).Msgf("Error unmarshalling artists for release group with id %d", row.ID)
+				return nil, fmt.Errorf("GetTopAlbumsPaginated: Unmarshal: %w", err)
+			}
+			rgs[i] = db.RankedItem[*models.Album]{
+				Item: &models.Album{
+					Title:          row.Title,
+					MbzID:          row.MusicBrainzID,
+					ID:             row.ID,
+					Image:          row.Image,
+					Artists:        artists,
+					VariousArtists: row.VariousArtists,
+					ReleaseGroupID: releaseGroupID(row.ReleaseGroupID),
+					ListenCount:    row.ListenCount,
+					EditionCount:   row.EditionCount,
+				},
+				Rank:        row.Rank,
+				ListenCount: row.ListenCount,
+			}
+		}
+		count, err = d.q.CountTopReleaseGroups(ctx, repository.CountTopReleaseGroupsParams{
+			ListenedAtFrom: t1,
+			ListenedAtTo:   t2,
+			UserID:         opts.UserID,
+			ArtistID:       int32(opts.ArtistID),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("GetTopAlbumsPaginated: CountTopReleaseGroups: %w", err)
+		}
+		l.Debug().Msgf("Database responded with %d release groups out of a total %d", len(rows), count)
+	} else if opts.ArtistID != 0 {
 		l.Debug().Msgf("Fetching top %d albums from artist id %d on page %d from range %v to %v",
 			opts.Limit, opts.ArtistID, opts.Page, t1.Format("Jan 02, 2006"), t2.Format("Jan 02, 2006"))
 
 		rows, err := d.q.GetTopReleasesFromArtist(ctx, repository.GetTopReleasesFromArtistParams{
+			UserID:       opts.UserID,
 			ArtistID:     int32(opts.ArtistID),
 			Limit:        int32(opts.Limit),
 			Offset:       int32(offset),
@@ -57,8 +107,8 @@ func (d *Psql) GetTopAlbumsPaginated(ctx context.Context, opts db.GetItemsOpts)
 					VariousArtists: v.VariousArtists,
 					ListenCount:    v.ListenCount,
 				},
-				Rank:         v.Rank,
-				ListenCount:  v.ListenCount,
+				Rank:        v.Rank,
+				ListenCount: v.ListenCount,
 			}
 		}
 		count, err = d.q.CountReleasesFromArtist(ctx, int32(opts.ArtistID))
@@ -69,6 +119,7 @@ func (d *Psql) GetTopAlbumsPaginated(ctx context.Context, opts db.GetItemsOpts)
 		l.Debug().Msgf("Fetching top %d albums on page %d from range %v to %v",
 			opts.Limit, opts.Page, t1.Format("Jan 02, 2006"), t2.Format("Jan 02, 2006"))
 		rows, err := d.q.GetTopReleasesPaginated(ctx, repository.GetTopReleasesPaginatedParams{
+			UserID:       opts.UserID,
 			ListenedAt:   t1,
 			ListenedAt_2: t2,
 			Limit:        int32(opts.Limit),
@@ -96,11 +147,12 @@ func (d *Psql) GetTopAlbumsPaginated(ctx context.Context, opts db.GetItemsOpts)
 					VariousArtists: row.VariousArtists,
 					ListenCount:    row.ListenCount,
 				},
-				Rank:         row.Rank,
-				ListenCount:  row.ListenCount,
+				Rank:        row.Rank,
+				ListenCount: row.ListenCount,
 			}
 		}
 		count, err = d.q.CountTopReleases(ctx, repository.CountTopReleasesParams{
+			UserID:       opts.UserID,
 			ListenedAt:   t1,
 			ListenedAt_2: t2,
 		})
@@ -10,6 +10,15 @@ import (
 	"github.com/gabehf/koito/internal/repository"
 )
 
+// excludedArtistRole returns the credit role whose listens are left out of artist statistics.
+// No credit has an empty role, so nothing is left out by default.
+func excludedArtistRole(opts db.GetItemsOpts) string {
+	if opts.ExcludeFeatured {
+		return string(models.ArtistRoleFeatured)
+	}
+	return ""
+}
+
 func (d *Psql) GetTopArtistsPaginated(ctx context.Context, opts db.GetItemsOpts) (*db.PaginatedResponse[db.RankedItem[*models.Artist]], error) {
 	l := logger.FromContext(ctx)
 	var err error
@@ -22,10 +31,12 @@ func (d *Psql) GetTopArtistsPaginated(ctx context.Context, opts db.GetItemsOpts)
 	l.Debug().Msgf("Fetching top %d artists on page %d from range %v to %v",
 		opts.Limit, opts.Page, t1.Format("Jan 02, 2006"), t2.Format("Jan 02, 2006"))
 	rows, err := d.q.GetTopArtistsPaginated(ctx, repository.GetTopArtistsPaginatedParams{
+		UserID:       opts.UserID,
 		ListenedAt:   t1,
 		ListenedAt_2: t2,
 		Limit:        int32(opts.Limit),
 		Offset:       int32(offset),
+		Role:         excludedArtistRole(opts),
 	})
 	if err != nil {
 		return nil, fmt.Errorf("GetTopArtistsPaginated: GetTopArtistsPaginated: %w", err)
@@ -40,14 +51,16 @@ func (d *Psql) GetTopArtistsPaginated(ctx context.Context, opts db.GetItemsOpts)
 			ListenCount: row.ListenCount,
 		}
 		rgs[i] = db.RankedItem[*models.Artist]{
-			Item:         t,
-			Rank:         row.Rank,
-			ListenCount:  row.ListenCount,
+			Item:        t,
+			Rank:        row.Rank,
+			ListenCount: row.ListenCount,
 		}
 	}
 	count, err := d.q.CountTopArtists(ctx, repository.CountTopArtistsParams{
+		UserID:       opts.UserID,
 		ListenedAt:   t1,
 		ListenedAt_2: t2,
+		Role:         excludedArtistRole(opts),
 	})
 	if err != nil {
 		return nil, fmt.Errorf("GetTopArtistsPaginated: CountTopArtists: %w", err)
@@ -14,7 +14,7 @@ func TestGetTopArtistsPaginated(t *testing.T) {
 	ctx := context.Background()
 
 	// Test valid
-	resp, err := store.GetTopArtistsPaginated(ctx, db.GetItemsOpts{Timeframe: db.PeriodToTimeframe(db.PeriodAllTime)})
+	resp, err := store.GetTopArtistsPaginated(ctx, db.GetItemsOpts{UserID: 1, Timeframe: db.PeriodToTimeframe(db.PeriodAllTime)})
 	require.NoError(t, err)
 	require.Len(t, resp.Items, 4)
 	assert.Equal(t, int64(4), resp.TotalCount)
@@ -24,49 +24,49 @@ func TestGetTopArtistsPaginated(t *testing.T) {
 	assert.Equal(t, "Artist Four", resp.Items[3].Item.Name)
 
 	// Test pagination
-	resp, err = store.GetTopArtistsPaginated(ctx, db.GetItemsOpts{Limit: 1, Page: 2, Timeframe: db.PeriodToTimeframe(db.PeriodAllTime)})
+	resp, err = store.GetTopArtistsPaginated(ctx, db.GetItemsOpts{UserID: 1, Limit: 1, Page: 2, Timeframe: db.PeriodToTimeframe(db.PeriodAllTime)})
 	require.NoError(t, err)
 	require.Len(t, resp.Items, 1)
 	assert.Equal(t, "Artist Two", resp.Items[0].Item.Name)
 
 	// Test page out of range
-	resp, err = store.GetTopArtistsPaginated(ctx, db.GetItemsOpts{Limit: 1, Page: 10, Timeframe: db.PeriodToTimeframe(db.PeriodAllTime)})
+	resp, err = store.GetTopArtistsPaginated(ctx, db.GetItemsOpts{UserID: 1, Limit: 1, Page: 10, Timeframe: db.PeriodToTimeframe(db.PeriodAllTime)})
 	require.NoError(t, err)
 	assert.Empty(t, resp.Items)
 	assert.False(t, resp.HasNextPage)
 
 	// Test invalid inputs
-	_, err = store.GetTopArtistsPaginated(ctx, db.GetItemsOpts{Limit: -1, Page: 0})
+	_, err = store.GetTopArtistsPaginated(ctx, db.GetItemsOpts{UserID: 1, Limit: -1, Page: 0})
 	assert.Error(t, err)
 
-	_, err = store.GetTopArtistsPaginated(ctx, db.GetItemsOpts{Limit: 1, Page: -1})
+	_, err = store.GetTopArtistsPaginated(ctx, db.GetItemsOpts{UserID: 1, Limit: 1, Page: -1})
 	assert.Error(t, err)
 
 	// Test specify period
-	resp, err = store.GetTopArtistsPaginated(ctx, db.GetItemsOpts{Timeframe: db.PeriodToTimeframe(db.PeriodDay)})
+	resp, err = store.GetTopArtistsPaginated(ctx, db.GetItemsOpts{UserID: 1, Timeframe: db.PeriodToTimeframe(db.PeriodDay)})
 	require.NoError(t, err)
 	require.Len(t, resp.Items, 0) // empty
 	assert.Equal(t, int64(0), resp.TotalCount)
 	// should default to PeriodDay
-	resp, err = store.GetTopArtistsPaginated(ctx, db.GetItemsOpts{})
+	resp, err = store.GetTopArtistsPaginated(ctx, db.GetItemsOpts{UserID: 1})
 	require.NoError(t, err)
 	require.Len(t, resp.Items, 0) // empty
 	assert.Equal(t, int64(0), resp.TotalCount)
 
-	resp, err = store.GetTopArtistsPaginated(ctx, db.GetItemsOpts{Timeframe: db.PeriodToTimeframe(db.PeriodWeek)})
+	resp, err = store.GetTopArtistsPaginated(ctx, db.GetItemsOpts{UserID: 1, Timeframe: db.PeriodToTimeframe(db.PeriodWeek)})
 	require.NoError(t, err)
 	require.Len(t, resp.Items, 1)
 	assert.Equal(t, int64(1), resp.TotalCount)
 	assert.Equal(t, "Artist Four", resp.Items[0].Item.Name)
 
-	resp, err = store.GetTopArtistsPaginated(ctx, db.GetItemsOpts{Timeframe: db.PeriodToTimeframe(db.PeriodMonth)})
+	resp, err = store.GetTopArtistsPaginated(ctx, db.GetItemsOpts{UserID: 1, Timeframe: db.PeriodToTimeframe(db.PeriodMonth)})
 	require.NoError(t, err)
 	require.Len(t, resp.Items, 2)
 	assert.Equal(t, int64(2), resp.TotalCount)
 	assert.Equal(t, "Artist Three", resp.Items[0].Item.Name)
 	assert.Equal(t, "Artist Four", resp.Items[1].Item.Name)
 
-	resp, err = store.GetTopArtistsPaginated(ctx, db.GetItemsOpts{Timeframe: db.PeriodToTimeframe(db.PeriodYear)})
+	resp, err = store.GetTopArtistsPaginated(ctx, db.GetItemsOpts{UserID: 1, Timeframe: db.PeriodToTimeframe(db.PeriodYear)})
 	require.NoError(t, err)
 	require.Len(t, resp.Items, 3)
 	assert.Equal(t, int64(3), resp.TotalCount)
@@ -78,15 +78,49 @@ func TestGetTopArtistsPaginated(t *testing.T) {
 
 	testDataAbsoluteListenTimes(t)
 
-	resp, err = store.GetTopArtistsPaginated(ctx, db.GetItemsOpts{Timeframe: db.Timeframe{Year: 2023}})
+	resp, err = store.GetTopArtistsPaginated(ctx, db.GetItemsOpts{UserID: 1, Timeframe: db.Timeframe{Year: 2023}})
 	require.NoError(t, err)
 	require.Len(t, resp.Items, 1)
 	assert.Equal(t, int64(1), resp.TotalCount)
 	assert.Equal(t, "Artist One", resp.Items[0].Item.Name)
 
-	resp, err = store.GetTopArtistsPaginated(ctx, db.GetItemsOpts{Timeframe: db.Timeframe{Month: 6, Year: 2024}})
+	resp, err = store.GetTopArtistsPaginated(ctx, db.GetItemsOpts{UserID: 1, Timeframe: db.Timeframe{Month: 6, Year: 2024}})
 	require.NoError(t, err)
 	require.Len(t, resp.Items, 1)
 	assert.Equal(t, int64(1), resp.TotalCount)
 	assert.Equal(t, "Artist Two", resp.Items[0].Item.Name)
 }
+
+func TestGetTopArtistsPaginated_ExcludeFeatured(t *testing.T) {
+	testDataForTopItems(t)
+	ctx := context.Background()
+
+	// artist 4 is featured on the track of artist 1
+	err := store.Exec(ctx,
+		`INSERT INTO artist_tracks (artist_id, track_id, role)
+			VALUES (4, 1, 'featured')`)
+	require.NoError(t, err)
+
+	resp, err := store.GetTopArtistsPaginated(ctx, db.GetItemsOpts{UserID: 1, Timeframe: db.PeriodToTimeframe(db.PeriodAllTime)})
+	require.NoError(t, err)
+	require.Len(t, resp.Items, 4)
+	assert.Equal(t, "Artist Four", resp.Items[0].Item.Name)
+	assert.EqualValues(t, 5, resp.Items[0].Item.ListenCount)
+
+	resp, err = store.GetTopArtistsPaginated(ctx, db.GetItemsOpts{UserID: 1, ExcludeFeatured: true, Timeframe: db.PeriodToTimeframe(db.PeriodAllTime)})
+	require.NoError(t, err)
+	require.Len(t, resp.Items, 4)
+	assert.Equal(t, int64(4), resp.TotalCount)
+	assert.Equal(t, "Artist One", resp.Items[0].Item.Name)
+	assert.Equal(t, "Artist Four", resp.Items[3].Item.Name)
+	assert.EqualValues(t, 1, resp.Items[3].Item.ListenCount)
+
+	tracks, err := store.GetTopTracksPaginated(ctx, db.GetItemsOpts{UserID: 1, ArtistID: 4, Timeframe: db.PeriodToTimeframe(db.PeriodAllTime)})
+	require.NoError(t, err)
+	assert.Len(t, tracks.Items, 2)
+	tracks, err = store.GetTopTracksPaginated(ctx, db.GetItemsOpts{UserID: 1, ArtistID: 4, ExcludeFeatured: true, Timeframe: db.PeriodToTimeframe(db.PeriodAllTime)})
+	require.NoError(t, err)
+	require.Len(t, tracks.Items, 1)
+	assert.Equal(t, int64(1), tracks.TotalCount)
+	assert.Equal(t, "Track Four", tracks.Items[0].Item.Title)
+}
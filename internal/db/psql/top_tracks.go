@@ -26,6 +26,7 @@ func (d *Psql) GetTopTracksPaginated(ctx context.Context, opts db.GetItemsOpts)
 		l.Debug().Msgf("Fetching top %d tracks on page %d from range %v to %v",
 			opts.Limit, opts.Page, t1.Format("Jan 02, 2006"), t2.Format("Jan 02, 2006"))
 		rows, err := d.q.GetTopTracksInReleasePaginated(ctx, repository.GetTopTracksInReleasePaginatedParams{
+			UserID:       opts.UserID,
 			ListenedAt:   t1,
 			ListenedAt_2: t2,
 			Limit:        int32(opts.Limit),
@@ -53,12 +54,13 @@ func (d *Psql) GetTopTracksPaginated(ctx context.Context, opts db.GetItemsOpts)
 				Artists:     artists,
 			}
 			tracks[i] = db.RankedItem[*models.Track]{
-				Item:         t,
-				Rank:         row.Rank,
-				ListenCount:  row.ListenCount,
+				Item:        t,
+				Rank:        row.Rank,
+				ListenCount: row.ListenCount,
 			}
 		}
 		count, err = d.q.CountTopTracksByRelease(ctx, repository.CountTopTracksByReleaseParams{
+			UserID:       opts.UserID,
 			ListenedAt:   t1,
 			ListenedAt_2: t2,
 			ReleaseID:    int32(opts.AlbumID),
@@ -70,11 +72,13 @@ func (d *Psql) GetTopTracksPaginated(ctx context.Context, opts db.GetItemsOpts)
 		l.Debug().Msgf("Fetching top %d tracks on page %d from range %v to %v",
 			opts.Limit, opts.Page, t1.Format("Jan 02, 2006"), t2.Format("Jan 02, 2006"))
 		rows, err := d.q.GetTopTracksByArtistPaginated(ctx, repository.GetTopTracksByArtistPaginatedParams{
+			UserID:       opts.UserID,
 			ListenedAt:   t1,
 			ListenedAt_2: t2,
 			Limit:        int32(opts.Limit),
 			Offset:       int32(offset),
 			ArtistID:     int32(opts.ArtistID),
+			Role:         excludedArtistRole(opts),
 		})
 		if err != nil {
 			return nil, fmt.Errorf("GetTopTracksPaginated: GetTopTracksByArtistPaginated: %w", err)
@@ -97,15 +101,17 @@ func (d *Psql) GetTopTracksPaginated(ctx context.Context, opts db.GetItemsOpts)
 				Artists:     artists,
 			}
 			tracks[i] = db.RankedItem[*models.Track]{
-				Item:         t,
-				Rank:         row.Rank,
-				ListenCount:  row.ListenCount,
+				Item:        t,
+				Rank:        row.Rank,
+				ListenCount: row.ListenCount,
 			}
 		}
 		count, err = d.q.CountTopTracksByArtist(ctx, repository.CountTopTracksByArtistParams{
+			UserID:       opts.UserID,
 			ListenedAt:   t1,
 			ListenedAt_2: t2,
 			ArtistID:     int32(opts.ArtistID),
+			Role:         excludedArtistRole(opts),
 		})
 		if err != nil {
 			return nil, fmt.Errorf("GetTopTracksPaginated: CountTopTracksByArtist: %w", err)
@@ -114,6 +120,7 @@ func (d *Psql) GetTopTracksPaginated(ctx context.Context, opts db.GetItemsOpts)
 		l.Debug().Msgf("Fetching top %d tracks on page %d from range %v to %v",
 			opts.Limit, opts.Page, t1.Format("Jan 02, 2006"), t2.Format("Jan 02, 2006"))
 		rows, err := d.q.GetTopTracksPaginated(ctx, repository.GetTopTracksPaginatedParams{
+			UserID:       opts.UserID,
 			ListenedAt:   t1,
 			ListenedAt_2: t2,
 			Limit:        int32(opts.Limit),
@@ -140,12 +147,13 @@ func (d *Psql) GetTopTracksPaginated(ctx context.Context, opts db.GetItemsOpts)
 				Artists:     artists,
 			}
 			tracks[i] = db.RankedItem[*models.Track]{
-				Item:         t,
-				Rank:         row.Rank,
-				ListenCount:  row.ListenCount,
+				Item:        t,
+				Rank:        row.Rank,
+				ListenCount: row.ListenCount,
 			}
 		}
 		count, err = d.q.CountTopTracks(ctx, repository.CountTopTracksParams{
+			UserID:       opts.UserID,
 			ListenedAt:   t1,
 			ListenedAt_2: t2,
 		})
@@ -14,7 +14,7 @@ func TestGetTopTracksPaginated(t *testing.T) {
 	ctx := context.Background()
 
 	// Test valid
-	resp, err := store.GetTopTracksPaginated(ctx, db.GetItemsOpts{Timeframe: db.PeriodToTimeframe(db.PeriodAllTime)})
+	resp, err := store.GetTopTracksPaginated(ctx, db.GetItemsOpts{UserID: 1, Timeframe: db.PeriodToTimeframe(db.PeriodAllTime)})
 	require.NoError(t, err)
 	require.Len(t, resp.Items, 4)
 	assert.Equal(t, int64(4), resp.TotalCount)
@@ -27,49 +27,49 @@ func TestGetTopTracksPaginated(t *testing.T) {
 	assert.Equal(t, "Artist One", resp.Items[0].Item.Artists[0].Name)
 
 	// Test pagination
-	resp, err = store.GetTopTracksPaginated(ctx, db.GetItemsOpts{Limit: 1, Page: 2, Timeframe: db.PeriodToTimeframe(db.PeriodAllTime)})
+	resp, err = store.GetTopTracksPaginated(ctx, db.GetItemsOpts{UserID: 1, Limit: 1, Page: 2, Timeframe: db.PeriodToTimeframe(db.PeriodAllTime)})
 	require.NoError(t, err)
 	require.Len(t, resp.Items, 1)
 	assert.Equal(t, "Track Two", resp.Items[0].Item.Title)
 
 	// Test page out of range
-	resp, err = store.GetTopTracksPaginated(ctx, db.GetItemsOpts{Limit: 1, Page: 10, Timeframe: db.PeriodToTimeframe(db.PeriodAllTime)})
+	resp, err = store.GetTopTracksPaginated(ctx, db.GetItemsOpts{UserID: 1, Limit: 1, Page: 10, Timeframe: db.PeriodToTimeframe(db.PeriodAllTime)})
 	require.NoError(t, err)
 	assert.Empty(t, resp.Items)
 	assert.False(t, resp.HasNextPage)
 
 	// Test invalid inputs
-	_, err = store.GetTopTracksPaginated(ctx, db.GetItemsOpts{Limit: -1, Page: 0})
+	_, err = store.GetTopTracksPaginated(ctx, db.GetItemsOpts{UserID: 1, Limit: -1, Page: 0})
 	assert.Error(t, err)
 
-	_, err = store.GetTopTracksPaginated(ctx, db.GetItemsOpts{Limit: 1, Page: -1})
+	_, err = store.GetTopTracksPaginated(ctx, db.GetItemsOpts{UserID: 1, Limit: 1, Page: -1})
 	assert.Error(t, err)
 
 	// Test specify period
-	resp, err = store.GetTopTracksPaginated(ctx, db.GetItemsOpts{Timeframe: db.PeriodToTimeframe(db.PeriodDay)})
+	resp, err = store.GetTopTracksPaginated(ctx, db.GetItemsOpts{UserID: 1, Timeframe: db.PeriodToTimeframe(db.PeriodDay)})
 	require.NoError(t, err)
 	require.Len(t, resp.Items, 0) // empty
 	assert.Equal(t, int64(0), resp.TotalCount)
 	// should default to PeriodDay
-	resp, err = store.GetTopTracksPaginated(ctx, db.GetItemsOpts{})
+	resp, err = store.GetTopTracksPaginated(ctx, db.GetItemsOpts{UserID: 1})
 	require.NoError(t, err)
 	require.Len(t, resp.Items, 0) // empty
 	assert.Equal(t, int64(0), resp.TotalCount)
 
-	resp, err = store.GetTopTracksPaginated(ctx, db.GetItemsOpts{Timeframe: db.PeriodToTimeframe(db.PeriodWeek)})
+	resp, err = store.GetTopTracksPaginated(ctx, db.GetItemsOpts{UserID: 1, Timeframe: db.PeriodToTimeframe(db.PeriodWeek)})
 	require.NoError(t, err)
 	require.Len(t, resp.Items, 1)
 	assert.Equal(t, int64(1), resp.TotalCount)
 	assert.Equal(t, "Track Four", resp.Items[0].Item.Title)
 
-	resp, err = store.GetTopTracksPaginated(ctx, db.GetItemsOpts{Timeframe: db.PeriodToTimeframe(db.PeriodMonth)})
+	resp, err = store.GetTopTracksPaginated(ctx, db.GetItemsOpts{UserID: 1, Timeframe: db.PeriodToTimeframe(db.PeriodMonth)})
 	require.NoError(t, err)
 	require.Len(t, resp.Items, 2)
 	assert.Equal(t, int64(2), resp.TotalCount)
 	assert.Equal(t, "Track Three", resp.Items[0].Item.Title)
 	assert.Equal(t, "Track Four", resp.Items[1].Item.Title)
 
-	resp, err = store.GetTopTracksPaginated(ctx, db.GetItemsOpts{Timeframe: db.PeriodToTimeframe(db.PeriodYear)})
+	resp, err = store.GetTopTracksPaginated(ctx, db.GetItemsOpts{UserID: 1, Timeframe: db.PeriodToTimeframe(db.PeriodYear)})
 	require.NoError(t, err)
 	require.Len(t, resp.Items, 3)
 	assert.Equal(t, int64(3), resp.TotalCount)
@@ -78,19 +78,19 @@ func TestGetTopTracksPaginated(t *testing.T) {
 	assert.Equal(t, "Track Four", resp.Items[2].Item.Title)
 
 	// Test filter by artists and releases
-	resp, err = store.GetTopTracksPaginated(ctx, db.GetItemsOpts{Timeframe: db.PeriodToTimeframe(db.PeriodAllTime), ArtistID: 1})
+	resp, err = store.GetTopTracksPaginated(ctx, db.GetItemsOpts{UserID: 1, Timeframe: db.PeriodToTimeframe(db.PeriodAllTime), ArtistID: 1})
 	require.NoError(t, err)
 	require.Len(t, resp.Items, 1)
 	assert.Equal(t, int64(1), resp.TotalCount)
 	assert.Equal(t, "Track One", resp.Items[0].Item.Title)
 
-	resp, err = store.GetTopTracksPaginated(ctx, db.GetItemsOpts{Timeframe: db.PeriodToTimeframe(db.PeriodAllTime), AlbumID: 2})
+	resp, err = store.GetTopTracksPaginated(ctx, db.GetItemsOpts{UserID: 1, Timeframe: db.PeriodToTimeframe(db.PeriodAllTime), AlbumID: 2})
 	require.NoError(t, err)
 	require.Len(t, resp.Items, 1)
 	assert.Equal(t, int64(1), resp.TotalCount)
 	assert.Equal(t, "Track Two", resp.Items[0].Item.Title)
 	// when both artistID and albumID are specified, artist id is ignored
-	resp, err = store.GetTopTracksPaginated(ctx, db.GetItemsOpts{Timeframe: db.PeriodToTimeframe(db.PeriodAllTime), AlbumID: 2, ArtistID: 1})
+	resp, err = store.GetTopTracksPaginated(ctx, db.GetItemsOpts{UserID: 1, Timeframe: db.PeriodToTimeframe(db.PeriodAllTime), AlbumID: 2, ArtistID: 1})
 	require.NoError(t, err)
 	require.Len(t, resp.Items, 1)
 	assert.Equal(t, int64(1), resp.TotalCount)
@@ -100,13 +100,13 @@ func TestGetTopTracksPaginated(t *testing.T) {
 
 	testDataAbsoluteListenTimes(t)
 
-	resp, err = store.GetTopTracksPaginated(ctx, db.GetItemsOpts{Timeframe: db.Timeframe{Year: 2023}})
+	resp, err = store.GetTopTracksPaginated(ctx, db.GetItemsOpts{UserID: 1, Timeframe: db.Timeframe{Year: 2023}})
 	require.NoError(t, err)
 	require.Len(t, resp.Items, 1)
 	assert.Equal(t, int64(1), resp.TotalCount)
 	assert.Equal(t, "Track One", resp.Items[0].Item.Title)
 
-	resp, err = store.GetTopTracksPaginated(ctx, db.GetItemsOpts{Timeframe: db.Timeframe{Month: 6, Year: 2024}})
+	resp, err = store.GetTopTracksPaginated(ctx, db.GetItemsOpts{UserID: 1, Timeframe: db.Timeframe{Month: 6, Year: 2024}})
 	require.NoError(t, err)
 	require.Len(t, resp.Items, 1)
 	assert.Equal(t, int64(1), resp.TotalCount)
@@ -48,6 +48,7 @@ func (d *Psql) GetTrack(ctx context.Context, opts db.GetTrackOpts) (*models.Trac
 	}
 
 	count, err := d.q.CountListensFromTrack(ctx, repository.CountListensFromTrackParams{
+		UserID:       opts.UserID,
 		ListenedAt:   time.Unix(0, 0),
 		ListenedAt_2: time.Now(),
 		TrackID:      opts.ID,
@@ -57,6 +58,7 @@ func (d *Psql) GetTrack(ctx context.Context, opts db.GetTrackOpts) (*models.Trac
 	}
 
 	seconds, err := d.CountTimeListenedToItem(ctx, db.TimeListenedOpts{
+		UserID:    opts.UserID,
 		Timeframe: db.PeriodToTimeframe(db.PeriodAllTime),
 		TrackID:   opts.ID,
 	})
@@ -64,11 +66,11 @@ func (d *Psql) GetTrack(ctx context.Context, opts db.GetTrackOpts) (*models.Trac
 		return nil, fmt.Errorf("GetTrack: CountTimeListenedToItem: %w", err)
 	}
 
-	firstListen, err := d.q.GetFirstListenFromTrack(ctx, opts.ID)
+	firstListen, err := d.q.GetFirstListenFromTrack(ctx, repository.GetFirstListenFromTrackParams{ID: opts.ID, UserID: opts.UserID})
 	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
 		return nil, fmt.Errorf("GetAlbum: GetFirstListenFromRelease: %w", err)
 	}
-	rank, err := d.q.GetTrackAllTimeRank(ctx, opts.ID)
+	rank, err := d.q.GetTrackAllTimeRank(ctx, repository.GetTrackAllTimeRankParams{ID: opts.ID, UserID: opts.UserID})
 	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
 		return nil, fmt.Errorf("GetAlbum: GetTrackAllTimeRank: %w", err)
 	}
@@ -134,6 +136,7 @@ func (d *Psql) SaveTrack(ctx context.Context, opts db.SaveTrackOpts) (*models.Tr
 			ArtistID:  aid,
 			TrackID:   trackRow.ID,
 			IsPrimary: opts.ArtistIDs[0] == aid,
+			Role:      artistRole(opts.ArtistRoles, aid),
 		})
 		if err != nil {
 			return nil, fmt.Errorf("SaveTrack: AssociateArtistToTrack: %w", err)
@@ -170,6 +173,45 @@ func (d *Psql) SaveTrack(ctx context.Context, opts db.SaveTrackOpts) (*models.Tr
 	}, nil
 }
 
+// artistRole returns how the artist is credited according to roles, defaulting to main.
+func artistRole(roles map[int32]models.ArtistRole, artistId int32) string {
+	if role, ok := roles[artistId]; ok && role.Valid() {
+		return string(role)
+	}
+	return string(models.ArtistRoleMain)
+}
+
+// AddArtistsToTrack credits the artists on the track, leaving any existing credits as they are.
+func (d *Psql) AddArtistsToTrack(ctx context.Context, opts db.AddArtistsToTrackOpts) error {
+	l := logger.FromContext(ctx)
+	tx, qtx, ownsTx, err := d.withTx(ctx)
+	if err != nil {
+		l.Err(err).Msg("Failed to begin transaction")
+		return fmt.Errorf("AddArtistsToTrack: BeginTx: %w", err)
+	}
+	if ownsTx {
+		defer tx.Rollback(ctx)
+	}
+	for _, id := range opts.ArtistIDs {
+		err := qtx.AssociateArtistToTrack(ctx, repository.AssociateArtistToTrackParams{
+			ArtistID: id,
+			TrackID:  opts.TrackID,
+			Role:     artistRole(opts.ArtistRoles, id),
+		})
+		if err != nil {
+			l.Error().Err(err).Msgf("Failed to associate track %d with artist %d", opts.TrackID, id)
+			return fmt.Errorf("AddArtistsToTrack: AssociateArtistToTrack: %w", err)
+		}
+	}
+	if ownsTx {
+		if err := tx.Commit(ctx); err != nil {
+			return fmt.Errorf("AddArtistsToTrack: Commit: %w", err)
+		}
+	}
+
+	return nil
+}
+
 func (d *Psql) UpdateTrack(ctx context.Context, opts db.UpdateTrackOpts) error {
 	l := logger.FromContext(ctx)
 	if opts.ID == 0 {
@@ -217,13 +259,14 @@ func (d *Psql) SaveTrackAliases(ctx context.Context, id int32, aliases []string,
 	if id == 0 {
 		return errors.New("SaveTrackAliases: track id not specified")
 	}
-	tx, err := d.conn.BeginTx(ctx, pgx.TxOptions{})
+	tx, qtx, ownsTx, err := d.withTx(ctx)
 	if err != nil {
 		l.Err(err).Msg("Failed to begin transaction")
 		return fmt.Errorf("SaveTrackAliases: BeginTx: %w", err)
 	}
-	defer tx.Rollback(ctx)
-	qtx := d.q.WithTx(tx)
+	if ownsTx {
+		defer tx.Rollback(ctx)
+	}
 	existing, err := qtx.GetAllTrackAliases(ctx, id)
 	if err != nil {
 		return fmt.Errorf("SaveTrackAliases: GetAllTrackAliases: %w", err)
@@ -246,7 +289,12 @@ func (d *Psql) SaveTrackAliases(ctx context.Context, id int32, aliases []string,
 			return fmt.Errorf("SaveTrackAliases: InsertTrackAlias: %w", err)
 		}
 	}
-	return tx.Commit(ctx)
+	if ownsTx {
+		if err := tx.Commit(ctx); err != nil {
+			return fmt.Errorf("SaveTrackAliases: Commit: %w", err)
+		}
+	}
+	return nil
 }
 
 func (d *Psql) DeleteTrack(ctx context.Context, id int32) error {
@@ -430,3 +478,25 @@ func (d *Psql) GetTracksWithNoDurationButHaveMbzID(ctx context.Context, from int
 
 	return ret, nil
 }
+
+// SetTrackArtistRole changes how an artist is credited on a track. Returns pgx.ErrNoRows when the
+// artist is not credited on the track.
+func (d *Psql) SetTrackArtistRole(ctx context.Context, id int32, artistId int32, role models.ArtistRole) error {
+	l := logger.FromContext(ctx)
+	if !role.Valid() {
+		return fmt.Errorf("SetTrackArtistRole: invalid role '%s'", role)
+	}
+	l.Debug().Msgf("Marking artist with id %d as '%s' on track with id %d", artistId, role, id)
+	n, err := d.q.UpdateTrackArtistRole(ctx, repository.UpdateTrackArtistRoleParams{
+		TrackID:  id,
+		ArtistID: artistId,
+		Role:     string(role),
+	})
+	if err != nil {
+		return fmt.Errorf("SetTrackArtistRole: UpdateTrackArtistRole: %w", err)
+	}
+	if n == 0 {
+		return fmt.Errorf("SetTrackArtistRole: %w", pgx.ErrNoRows)
+	}
+	return nil
+}
@@ -74,7 +74,7 @@ func TestGetTrack(t *testing.T) {
 	ctx := context.Background()
 
 	// Test GetTrack by ID
-	track, err := store.GetTrack(ctx, db.GetTrackOpts{ID: 1})
+	track, err := store.GetTrack(ctx, db.GetTrackOpts{UserID: 1, ID: 1})
 	require.NoError(t, err)
 	assert.Equal(t, int32(1), track.ID)
 	assert.Equal(t, "Track One", track.Title)
@@ -82,7 +82,7 @@ func TestGetTrack(t *testing.T) {
 	assert.EqualValues(t, 100, track.TimeListened)
 
 	// Test GetTrack by MusicBrainzID
-	track, err = store.GetTrack(ctx, db.GetTrackOpts{MusicBrainzID: uuid.MustParse("22222222-2222-2222-2222-222222222222")})
+	track, err = store.GetTrack(ctx, db.GetTrackOpts{UserID: 1, MusicBrainzID: uuid.MustParse("22222222-2222-2222-2222-222222222222")})
 	require.NoError(t, err)
 	assert.Equal(t, int32(2), track.ID)
 	assert.Equal(t, "Track Two", track.Title)
@@ -90,6 +90,7 @@ func TestGetTrack(t *testing.T) {
 
 	// Test GetTrack by Title, Release and ArtistIDs
 	track, err = store.GetTrack(ctx, db.GetTrackOpts{
+		UserID:    1,
 		Title:     "Track One",
 		ReleaseID: 1,
 		ArtistIDs: []int32{1},
@@ -100,7 +101,7 @@ func TestGetTrack(t *testing.T) {
 	assert.EqualValues(t, 100, track.TimeListened)
 
 	// Test GetTrack with insufficient information
-	_, err = store.GetTrack(ctx, db.GetTrackOpts{Title: "Track One"})
+	_, err = store.GetTrack(ctx, db.GetTrackOpts{UserID: 1, Title: "Track One"})
 	assert.Error(t, err)
 }
 func TestSaveTrack(t *testing.T) {
@@ -167,7 +168,7 @@ func TestUpdateTrack(t *testing.T) {
 	require.NoError(t, err)
 
 	// Verify the update
-	track, err := store.GetTrack(ctx, db.GetTrackOpts{ID: 1})
+	track, err := store.GetTrack(ctx, db.GetTrackOpts{UserID: 1, ID: 1})
 	require.NoError(t, err)
 	require.Equal(t, newMbzID, *track.MbzID)
 	require.EqualValues(t, newDuration, track.Duration)
@@ -201,7 +202,7 @@ func TestTrackAliases(t *testing.T) {
 
 	err = store.SetPrimaryTrackAlias(ctx, 1, "Alias One")
 	require.NoError(t, err)
-	track, err := store.GetTrack(ctx, db.GetTrackOpts{ID: 1})
+	track, err := store.GetTrack(ctx, db.GetTrackOpts{UserID: 1, ID: 1})
 	require.NoError(t, err)
 	assert.Equal(t, "Alias One", track.Title)
 
@@ -211,7 +212,7 @@ func TestTrackAliases(t *testing.T) {
 	// Ensure primary alias cannot be deleted
 	err = store.DeleteTrackAlias(ctx, track.ID, "Alias One")
 	require.NoError(t, err) // shouldn't error when nothing is deleted
-	track, err = store.GetTrack(ctx, db.GetTrackOpts{ID: 1})
+	track, err = store.GetTrack(ctx, db.GetTrackOpts{UserID: 1, ID: 1})
 	require.NoError(t, err)
 	assert.Equal(t, "Alias One", track.Title)
 
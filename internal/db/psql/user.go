@@ -2,6 +2,9 @@ package psql
 
 import (
 	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"regexp"
@@ -12,7 +15,9 @@ import (
 	"github.com/gabehf/koito/internal/logger"
 	"github.com/gabehf/koito/internal/models"
 	"github.com/gabehf/koito/internal/repository"
+	"github.com/gabehf/koito/internal/totp"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -25,26 +30,118 @@ func (d *Psql) GetUserByUsername(ctx context.Context, username string) (*models.
 		return nil, fmt.Errorf("GetUserByUsername: %w", err)
 	}
 	return &models.User{
-		ID:       row.ID,
-		Username: row.Username,
-		Password: row.Password,
-		Role:     models.UserRole(row.Role),
+		ID:          row.ID,
+		Username:    row.Username,
+		Password:    row.Password,
+		Role:        models.UserRole(row.Role),
+		Disabled:    row.Disabled,
+		Visibility:  models.ProfileVisibility(row.Visibility),
+		CreatedAt:   row.CreatedAt,
+		OIDCSubject: row.OidcSubject.String,
+		TOTPEnabled: row.TotpEnabled,
+		TOTPSecret:  row.TotpSecret.String,
 	}, nil
 }
 
+// Returns nil, nil when no database entries are found
+func (d *Psql) GetUserByID(ctx context.Context, id int32) (*models.User, error) {
+	row, err := d.q.GetUserByID(ctx, id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("GetUserByID: %w", err)
+	}
+	return &models.User{
+		ID:          row.ID,
+		Username:    row.Username,
+		Password:    row.Password,
+		Role:        models.UserRole(row.Role),
+		Disabled:    row.Disabled,
+		Visibility:  models.ProfileVisibility(row.Visibility),
+		CreatedAt:   row.CreatedAt,
+		OIDCSubject: row.OidcSubject.String,
+		TOTPEnabled: row.TotpEnabled,
+		TOTPSecret:  row.TotpSecret.String,
+	}, nil
+}
+
+// GetUserByOIDCSubject returns the user linked to the OpenID Connect subject.
+// Returns nil, nil when no database entries are found.
+func (d *Psql) GetUserByOIDCSubject(ctx context.Context, subject string) (*models.User, error) {
+	row, err := d.q.GetUserByOIDCSubject(ctx, pgtype.Text{String: subject, Valid: true})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("GetUserByOIDCSubject: %w", err)
+	}
+	return &models.User{
+		ID:          row.ID,
+		Username:    row.Username,
+		Password:    row.Password,
+		Role:        models.UserRole(row.Role),
+		Disabled:    row.Disabled,
+		Visibility:  models.ProfileVisibility(row.Visibility),
+		CreatedAt:   row.CreatedAt,
+		OIDCSubject: row.OidcSubject.String,
+		TOTPEnabled: row.TotpEnabled,
+		TOTPSecret:  row.TotpSecret.String,
+	}, nil
+}
+
+// GetPublicUsers returns the users that have made their profile public, ordered by username.
+func (d *Psql) GetPublicUsers(ctx context.Context) ([]*models.User, error) {
+	rows, err := d.q.GetPublicUsers(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("GetPublicUsers: %w", err)
+	}
+	users := make([]*models.User, len(rows))
+	for i, row := range rows {
+		users[i] = &models.User{
+			ID:         row.ID,
+			Username:   row.Username,
+			Role:       models.UserRole(row.Role),
+			Visibility: models.ProfileVisibility(row.Visibility),
+			CreatedAt:  row.CreatedAt,
+		}
+	}
+	return users, nil
+}
+
+func (d *Psql) GetUsers(ctx context.Context) ([]*models.User, error) {
+	rows, err := d.q.GetUsers(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("GetUsers: %w", err)
+	}
+	users := make([]*models.User, len(rows))
+	for i, row := range rows {
+		users[i] = &models.User{
+			ID:         row.ID,
+			Username:   row.Username,
+			Role:       models.UserRole(row.Role),
+			Disabled:   row.Disabled,
+			Visibility: models.ProfileVisibility(row.Visibility),
+			CreatedAt:  row.CreatedAt,
+		}
+	}
+	return users, nil
+}
+
 // Returns nil, nil when no database entries are found
 func (d *Psql) GetUserByApiKey(ctx context.Context, key string) (*models.User, error) {
-	row, err := d.q.GetUserByApiKey(ctx, key)
+	row, err := d.q.GetUserByApiKey(ctx, hashApiKey(key))
 	if errors.Is(err, pgx.ErrNoRows) {
 		return nil, nil
 	} else if err != nil {
 		return nil, fmt.Errorf("GetUserByApiKey: %w", err)
 	}
 	return &models.User{
-		ID:       row.ID,
-		Username: row.Username,
-		Password: row.Password,
-		Role:     models.UserRole(row.Role),
+		ID:         row.ID,
+		Username:   row.Username,
+		Password:   row.Password,
+		Role:       models.UserRole(row.Role),
+		Disabled:   row.Disabled,
+		Visibility: models.ProfileVisibility(row.Visibility),
+		CreatedAt:  row.CreatedAt,
 	}, nil
 }
 
@@ -77,27 +174,92 @@ func (d *Psql) SaveUser(ctx context.Context, opts db.SaveUserOpts) (*models.User
 		return nil, fmt.Errorf("SaveUser: InsertUser: %w", err)
 	}
 	return &models.User{
-		ID:       u.ID,
-		Username: u.Username,
-		Role:     models.UserRole(u.Role),
+		ID:         u.ID,
+		Username:   u.Username,
+		Role:       models.UserRole(u.Role),
+		Visibility: models.ProfileVisibility(u.Visibility),
+		CreatedAt:  u.CreatedAt,
 	}, nil
 }
+
+const apiKeyPrefixLength = 8
+
+// hashApiKey returns the hex encoded SHA-256 of key, which is what is stored in the database.
+func hashApiKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+func apiKeyFromRow(row repository.ApiKey) models.ApiKey {
+	key := models.ApiKey{
+		ID:           row.ID,
+		UserID:       row.UserID,
+		Prefix:       row.Prefix,
+		KeyHash:      row.KeyHash,
+		LegacyDigest: row.LegacyDigest.String,
+		Legacy:       row.LegacyDigest.Valid,
+		Label:        row.Label,
+		Scope:        models.ApiKeyScope(row.Scope),
+		CreatedAt:    row.CreatedAt.Time,
+		LastUsedIP:   row.LastUsedIp.String,
+	}
+	if row.ExpiresAt.Valid {
+		key.ExpiresAt = &row.ExpiresAt.Time
+	}
+	if row.LastUsedAt.Valid {
+		key.LastUsedAt = &row.LastUsedAt.Time
+	}
+	return key
+}
+
 func (d *Psql) SaveApiKey(ctx context.Context, opts db.SaveApiKeyOpts) (*models.ApiKey, error) {
+	if opts.Scope == "" {
+		opts.Scope = models.ApiKeyScopeFull
+	}
+	switch opts.Scope {
+	case models.ApiKeyScopeSubmit, models.ApiKeyScopeRead, models.ApiKeyScopeFull:
+	default:
+		return nil, fmt.Errorf("SaveApiKey: invalid scope '%s'", opts.Scope)
+	}
+	if utf8.RuneCountInString(opts.Key) <= apiKeyPrefixLength {
+		return nil, errors.New("SaveApiKey: key is too short")
+	}
+	// the Audioscrobbler 1.2 handshake needs the unsalted MD5 of the key, so it is only kept
+	// for keys that are meant for legacy clients
+	var legacyDigest pgtype.Text
+	if opts.Legacy {
+		sum := md5.Sum([]byte(opts.Key))
+		legacyDigest = pgtype.Text{String: hex.EncodeToString(sum[:]), Valid: true}
+	}
 	row, err := d.q.InsertApiKey(ctx, repository.InsertApiKeyParams{
-		Key:    opts.Key,
-		Label:  opts.Label,
-		UserID: opts.UserID,
+		KeyHash:      hashApiKey(opts.Key),
+		Prefix:       string([]rune(opts.Key)[:apiKeyPrefixLength]),
+		LegacyDigest: legacyDigest,
+		Label:        opts.Label,
+		UserID:       opts.UserID,
+		Scope:        string(opts.Scope),
+		ExpiresAt:    pgtype.Timestamptz{Time: opts.ExpiresAt, Valid: !opts.ExpiresAt.IsZero()},
 	})
 	if err != nil {
 		return nil, fmt.Errorf("SaveApiKey: InsertApiKey: %w", err)
 	}
-	return &models.ApiKey{
-		ID:        row.ID,
-		UserID:    row.UserID,
-		Key:       row.Key,
-		Label:     row.Label,
-		CreatedAt: row.CreatedAt.Time,
-	}, nil
+	key := apiKeyFromRow(row)
+	// this is the only time the key itself is available
+	key.Key = opts.Key
+	return &key, nil
+}
+
+// GetApiKey returns the API key if it has not expired and its owner is not disabled.
+// Returns nil, nil when no database entries are found.
+func (d *Psql) GetApiKey(ctx context.Context, key string) (*models.ApiKey, error) {
+	row, err := d.q.GetApiKeyByHash(ctx, hashApiKey(key))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("GetApiKey: %w", err)
+	}
+	k := apiKeyFromRow(row)
+	return &k, nil
 }
 
 func (d *Psql) UpdateUser(ctx context.Context, opts db.UpdateUserOpts) error {
@@ -145,6 +307,57 @@ func (d *Psql) UpdateUser(ctx context.Context, opts db.UpdateUserOpts) error {
 			return fmt.Errorf("UpdateUser: UpdateUserPassword: %w", err)
 		}
 	}
+	if opts.Role != "" {
+		if opts.Role != models.UserRoleAdmin && opts.Role != models.UserRoleUser {
+			return fmt.Errorf("UpdateUser: invalid role '%s'", opts.Role)
+		}
+		err = qtx.UpdateUserRole(ctx, repository.UpdateUserRoleParams{
+			ID:   opts.ID,
+			Role: repository.Role(opts.Role),
+		})
+		if err != nil {
+			return fmt.Errorf("UpdateUser: UpdateUserRole: %w", err)
+		}
+	}
+	if opts.Visibility != "" {
+		switch opts.Visibility {
+		case models.ProfileVisibilityPublic, models.ProfileVisibilityUnlisted, models.ProfileVisibilityPrivate:
+		default:
+			return fmt.Errorf("UpdateUser: invalid visibility '%s'", opts.Visibility)
+		}
+		err = qtx.UpdateUserVisibility(ctx, repository.UpdateUserVisibilityParams{
+			ID:         opts.ID,
+			Visibility: string(opts.Visibility),
+		})
+		if err != nil {
+			return fmt.Errorf("UpdateUser: UpdateUserVisibility: %w", err)
+		}
+	}
+	if opts.OIDCSubject != "" {
+		err = qtx.UpdateUserOIDCSubject(ctx, repository.UpdateUserOIDCSubjectParams{
+			ID:          opts.ID,
+			OidcSubject: pgtype.Text{String: opts.OIDCSubject, Valid: true},
+		})
+		if err != nil {
+			return fmt.Errorf("UpdateUser: UpdateUserOIDCSubject: %w", err)
+		}
+	}
+	if opts.Disabled != nil {
+		err = qtx.UpdateUserDisabled(ctx, repository.UpdateUserDisabledParams{
+			ID:       opts.ID,
+			Disabled: *opts.Disabled,
+		})
+		if err != nil {
+			return fmt.Errorf("UpdateUser: UpdateUserDisabled: %w", err)
+		}
+		if *opts.Disabled {
+			// log the user out everywhere
+			err = qtx.DeleteSessionsForUser(ctx, opts.ID)
+			if err != nil {
+				return fmt.Errorf("UpdateUser: DeleteSessionsForUser: %w", err)
+			}
+		}
+	}
 	return tx.Commit(ctx)
 }
 
@@ -155,12 +368,7 @@ func (d *Psql) GetApiKeysByUserID(ctx context.Context, id int32) ([]models.ApiKe
 	}
 	keys := make([]models.ApiKey, len(rows))
 	for i, row := range rows {
-		keys[i] = models.ApiKey{
-			ID:     row.ID,
-			Key:    row.Key,
-			Label:  row.Label,
-			UserID: row.UserID,
-		}
+		keys[i] = apiKeyFromRow(row)
 	}
 	return keys, nil
 }
@@ -173,10 +381,84 @@ func (d *Psql) UpdateApiKeyLabel(ctx context.Context, opts db.UpdateApiKeyLabelO
 	})
 }
 
+func (d *Psql) UpdateApiKeyLastUsed(ctx context.Context, id int32, ip string) error {
+	return d.q.UpdateApiKeyLastUsed(ctx, repository.UpdateApiKeyLastUsedParams{
+		ID:         id,
+		LastUsedIp: pgtype.Text{String: ip, Valid: ip != ""},
+	})
+}
+
+// SetUserTOTPSecret stores a new secret for the user to enroll with. Two-factor authentication
+// stays disabled until EnableUserTOTP is called, and any previous recovery codes are removed.
+func (d *Psql) SetUserTOTPSecret(ctx context.Context, userID int32, secret string) error {
+	err := d.q.SetUserTOTPSecret(ctx, repository.SetUserTOTPSecretParams{
+		ID:         userID,
+		TotpSecret: pgtype.Text{String: secret, Valid: true},
+	})
+	if err != nil {
+		return fmt.Errorf("SetUserTOTPSecret: %w", err)
+	}
+	return nil
+}
+
+// EnableUserTOTP turns on two-factor authentication for a user with a stored secret. Only
+// hashes of the recovery codes are stored.
+func (d *Psql) EnableUserTOTP(ctx context.Context, userID int32, recoveryCodes []string) error {
+	hashes := make([]string, len(recoveryCodes))
+	for i, code := range recoveryCodes {
+		hashes[i] = totp.HashRecoveryCode(code)
+	}
+	err := d.q.EnableUserTOTP(ctx, repository.EnableUserTOTPParams{
+		ID:            userID,
+		RecoveryCodes: hashes,
+	})
+	if err != nil {
+		return fmt.Errorf("EnableUserTOTP: %w", err)
+	}
+	return nil
+}
+
+func (d *Psql) DisableUserTOTP(ctx context.Context, userID int32) error {
+	if err := d.q.DisableUserTOTP(ctx, userID); err != nil {
+		return fmt.Errorf("DisableUserTOTP: %w", err)
+	}
+	return nil
+}
+
+// UseTOTPStep records that a code from the given time step was used. Returns false when a code
+// from the same or a later step has already been used, in which case the code must be rejected.
+func (d *Psql) UseTOTPStep(ctx context.Context, userID int32, step int64) (bool, error) {
+	n, err := d.q.UpdateUserTOTPStep(ctx, repository.UpdateUserTOTPStepParams{
+		ID:   userID,
+		Step: step,
+	})
+	if err != nil {
+		return false, fmt.Errorf("UseTOTPStep: %w", err)
+	}
+	return n > 0, nil
+}
+
+// UseTOTPRecoveryCode removes the recovery code from the user's remaining codes. Returns false
+// when the code is not one of them.
+func (d *Psql) UseTOTPRecoveryCode(ctx context.Context, userID int32, code string) (bool, error) {
+	n, err := d.q.UseTOTPRecoveryCode(ctx, repository.UseTOTPRecoveryCodeParams{
+		ID:       userID,
+		CodeHash: totp.HashRecoveryCode(code),
+	})
+	if err != nil {
+		return false, fmt.Errorf("UseTOTPRecoveryCode: %w", err)
+	}
+	return n > 0, nil
+}
+
 func (d *Psql) DeleteApiKey(ctx context.Context, id int32) error {
 	return d.q.DeleteApiKey(ctx, id)
 }
 
+func (d *Psql) DeleteUser(ctx context.Context, id int32) error {
+	return d.q.DeleteUser(ctx, id)
+}
+
 func (d *Psql) CountUsers(ctx context.Context) (int64, error) {
 	return d.q.CountUsers(ctx)
 }
@@ -0,0 +1,129 @@
+package psql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/gabehf/koito/internal/db"
+	"github.com/gabehf/koito/internal/logger"
+	"github.com/gabehf/koito/internal/models"
+	"github.com/gabehf/koito/internal/repository"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func userInviteFromRow(row repository.UserInvite) *models.UserInvite {
+	invite := &models.UserInvite{
+		ID:        row.ID,
+		Token:     row.Token,
+		Role:      models.UserRole(row.Role),
+		CreatedBy: row.CreatedBy.Int32,
+		CreatedAt: row.CreatedAt,
+		ExpiresAt: row.ExpiresAt,
+		UsedBy:    row.UsedBy.Int32,
+	}
+	if row.UsedAt.Valid {
+		invite.UsedAt = &row.UsedAt.Time
+	}
+	return invite
+}
+
+func (d *Psql) SaveUserInvite(ctx context.Context, opts db.SaveUserInviteOpts) (*models.UserInvite, error) {
+	if opts.Token == "" {
+		return nil, errors.New("SaveUserInvite: token is required")
+	}
+	if opts.Role == "" {
+		opts.Role = models.UserRoleUser
+	}
+	row, err := d.q.InsertUserInvite(ctx, repository.InsertUserInviteParams{
+		Token:     opts.Token,
+		Role:      repository.Role(opts.Role),
+		CreatedBy: pgtype.Int4{Int32: opts.CreatedBy, Valid: opts.CreatedBy != 0},
+		ExpiresAt: opts.ExpiresAt,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("SaveUserInvite: InsertUserInvite: %w", err)
+	}
+	return userInviteFromRow(row), nil
+}
+
+func (d *Psql) GetUserInvites(ctx context.Context) ([]*models.UserInvite, error) {
+	rows, err := d.q.GetUserInvites(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("GetUserInvites: %w", err)
+	}
+	invites := make([]*models.UserInvite, len(rows))
+	for i, row := range rows {
+		invites[i] = userInviteFromRow(row)
+	}
+	return invites, nil
+}
+
+// RedeemUserInvite creates a user with the role of the invite, and marks the invite as used.
+// Returns nil, nil when the token does not belong to an unused, unexpired invite.
+func (d *Psql) RedeemUserInvite(ctx context.Context, opts db.RedeemUserInviteOpts) (*models.User, error) {
+	l := logger.FromContext(ctx)
+	err := ValidateUsername(opts.Username)
+	if err != nil {
+		l.Debug().AnErr("validator_notice", err).Msgf("Username failed validation: %s", opts.Username)
+		return nil, fmt.Errorf("RedeemUserInvite: ValidateUsername: %w", err)
+	}
+	pw, err := ValidateAndNormalizePassword(opts.Password)
+	if err != nil {
+		l.Debug().AnErr("validator_notice", err).Msgf("Password failed validation")
+		return nil, fmt.Errorf("RedeemUserInvite: ValidateAndNormalizePassword: %w", err)
+	}
+	hashPw, err := bcrypt.GenerateFromPassword([]byte(pw), bcrypt.DefaultCost)
+	if err != nil {
+		l.Err(err).Msg("Failed to generate hashed password")
+		return nil, fmt.Errorf("RedeemUserInvite: bcrypt.GenerateFromPassword: %w", err)
+	}
+
+	tx, err := d.conn.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		l.Err(err).Msg("Failed to begin transaction")
+		return nil, fmt.Errorf("RedeemUserInvite: BeginTx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+	qtx := d.q.WithTx(tx)
+
+	// claiming the invite first means two requests with the same token can't both succeed
+	invite, err := qtx.ClaimUserInvite(ctx, opts.Token)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("RedeemUserInvite: ClaimUserInvite: %w", err)
+	}
+	u, err := qtx.InsertUser(ctx, repository.InsertUserParams{
+		Username: strings.ToLower(opts.Username),
+		Password: hashPw,
+		Role:     invite.Role,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("RedeemUserInvite: InsertUser: %w", err)
+	}
+	err = qtx.UpdateUserInviteUsedBy(ctx, repository.UpdateUserInviteUsedByParams{
+		ID:     invite.ID,
+		UsedBy: pgtype.Int4{Int32: u.ID, Valid: true},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("RedeemUserInvite: UpdateUserInviteUsedBy: %w", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("RedeemUserInvite: Commit: %w", err)
+	}
+	return &models.User{
+		ID:         u.ID,
+		Username:   u.Username,
+		Role:       models.UserRole(u.Role),
+		Visibility: models.ProfileVisibility(u.Visibility),
+		CreatedAt:  u.CreatedAt,
+	}, nil
+}
+
+func (d *Psql) DeleteUserInvite(ctx context.Context, id int32) error {
+	return d.q.DeleteUserInvite(ctx, id)
+}
@@ -2,9 +2,13 @@ package psql_test
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"testing"
+	"time"
 
 	"github.com/gabehf/koito/internal/db"
+	"github.com/gabehf/koito/internal/models"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"golang.org/x/crypto/bcrypt"
@@ -57,7 +61,7 @@ func TestGetUserByApiKey(t *testing.T) {
 	setupTestDataForUsers(t)
 
 	// Insert an API key for the test user
-	err := store.Exec(ctx, `INSERT INTO api_keys (key, label, user_id) VALUES ('test_key', 'Test Key', 2)`)
+	err := store.Exec(ctx, `INSERT INTO api_keys (key_hash, prefix, label, user_id) VALUES (encode(sha256('test_key'), 'hex'), 'test_key', 'Test Key', 2)`)
 	require.NoError(t, err)
 
 	// Test fetching a user by API key
@@ -73,6 +77,46 @@ func TestGetUserByApiKey(t *testing.T) {
 	assert.Nil(t, user)
 }
 
+func TestGetUserByAudioscrobblerSession(t *testing.T) {
+	ctx := context.Background()
+	setupTestDataForUsers(t)
+
+	key, err := store.SaveApiKey(ctx, db.SaveApiKeyOpts{Key: "legacy_api_key", Label: "Legacy", UserID: 2, Legacy: true})
+	require.NoError(t, err)
+	read, err := store.SaveApiKey(ctx, db.SaveApiKeyOpts{Key: "read_api_key", Label: "Read", UserID: 2, Scope: models.ApiKeyScopeRead})
+	require.NoError(t, err)
+
+	require.NoError(t, store.SaveAudioscrobblerSession(ctx, key.ID, "session_token"))
+	require.NoError(t, store.SaveAudioscrobblerSession(ctx, read.ID, "read_session_token"))
+
+	user, err := store.GetUserByAudioscrobblerSession(ctx, "session_token")
+	require.NoError(t, err)
+	require.NotNil(t, user)
+	assert.Equal(t, int32(2), user.ID)
+
+	// only the hash of the token is stored, and neither it nor the key's hash are sessions
+	count, err := store.Count(ctx, `SELECT COUNT(*) FROM audioscrobbler_sessions WHERE token_hash = $1`, sha256Hex("session_token"))
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+	for _, token := range []string{sha256Hex("session_token"), sha256Hex("legacy_api_key"), "legacy_api_key"} {
+		user, err = store.GetUserByAudioscrobblerSession(ctx, token)
+		require.NoError(t, err)
+		assert.Nil(t, user)
+	}
+
+	// sessions of keys that can't submit listens can't be used
+	user, err = store.GetUserByAudioscrobblerSession(ctx, "read_session_token")
+	require.NoError(t, err)
+	assert.Nil(t, user)
+
+	// sessions run out
+	err = store.Exec(ctx, `UPDATE audioscrobbler_sessions SET created_at = NOW() - INTERVAL '31 days'`)
+	require.NoError(t, err)
+	user, err = store.GetUserByAudioscrobblerSession(ctx, "session_token")
+	require.NoError(t, err)
+	assert.Nil(t, user)
+}
+
 func TestSaveUser(t *testing.T) {
 	ctx := context.Background()
 	setupTestDataForUsers(t)
@@ -119,13 +163,82 @@ func TestSaveApiKey(t *testing.T) {
 		Label:  label,
 		UserID: 2,
 	}
-	_, err := store.SaveApiKey(ctx, opts)
+	key, err := store.SaveApiKey(ctx, opts)
 	require.NoError(t, err)
+	assert.Equal(t, opts.Key, key.Key)
+	assert.Equal(t, "new_api_", key.Prefix)
 
-	// Verify the API key was saved
-	count, err := store.Count(ctx, `SELECT COUNT(*) FROM api_keys WHERE key = $1 AND user_id = $2`, opts.Key, opts.UserID)
+	// Verify the API key was saved, and that only its hash is stored
+	count, err := store.Count(ctx, `SELECT COUNT(*) FROM api_keys WHERE key_hash = $1 AND user_id = $2`, sha256Hex(opts.Key), opts.UserID)
 	require.NoError(t, err)
 	assert.Equal(t, 1, count)
+	count, err = store.Count(ctx, `SELECT COUNT(*) FROM api_keys WHERE key_hash = $1`, opts.Key)
+	require.NoError(t, err)
+	assert.Equal(t, 0, count)
+
+	user, err := store.GetUserByApiKey(ctx, opts.Key)
+	require.NoError(t, err)
+	require.NotNil(t, user)
+	assert.Equal(t, int32(2), user.ID)
+
+	// the MD5 digest needed by the Audioscrobbler 1.2 handshake is only kept for legacy keys
+	assert.False(t, key.Legacy)
+	assert.Empty(t, key.LegacyDigest)
+	legacy, err := store.SaveApiKey(ctx, db.SaveApiKeyOpts{Key: "legacy_api_key", Label: label, UserID: 2, Legacy: true})
+	require.NoError(t, err)
+	assert.True(t, legacy.Legacy)
+	assert.Equal(t, "a70bf967b361bc3845d801da3b6903ab", legacy.LegacyDigest)
+
+	_, err = store.SaveApiKey(ctx, db.SaveApiKeyOpts{Key: "short", Label: label, UserID: 2})
+	assert.Error(t, err)
+}
+
+func TestApiKeyScopesAndExpiry(t *testing.T) {
+	ctx := context.Background()
+	setupTestDataForUsers(t)
+
+	key, err := store.SaveApiKey(ctx, db.SaveApiKeyOpts{Key: "full_api_key", Label: "Full", UserID: 2})
+	require.NoError(t, err)
+	assert.Equal(t, models.ApiKeyScopeFull, key.Scope, "keys should default to the full scope")
+	assert.Nil(t, key.ExpiresAt)
+
+	_, err = store.SaveApiKey(ctx, db.SaveApiKeyOpts{Key: "read_api_key", Label: "Read", UserID: 2, Scope: models.ApiKeyScopeRead})
+	require.NoError(t, err)
+	_, err = store.SaveApiKey(ctx, db.SaveApiKeyOpts{Key: "bad_scope_key", Label: "Bad", UserID: 2, Scope: "admin"})
+	assert.Error(t, err)
+
+	expired, err := store.SaveApiKey(ctx, db.SaveApiKeyOpts{
+		Key:       "expired_key",
+		Label:     "Expired",
+		UserID:    2,
+		Scope:     models.ApiKeyScopeSubmit,
+		ExpiresAt: time.Now().Add(-time.Minute),
+	})
+	require.NoError(t, err)
+	require.NotNil(t, expired.ExpiresAt)
+
+	got, err := store.GetApiKey(ctx, "read_api_key")
+	require.NoError(t, err)
+	require.NotNil(t, got)
+	assert.Equal(t, models.ApiKeyScopeRead, got.Scope)
+	assert.Nil(t, got.LastUsedAt)
+
+	// expired keys can't be used to authenticate
+	got, err = store.GetApiKey(ctx, "expired_key")
+	require.NoError(t, err)
+	assert.Nil(t, got)
+	user, err := store.GetUserByApiKey(ctx, "expired_key")
+	require.NoError(t, err)
+	assert.Nil(t, user)
+
+	err = store.UpdateApiKeyLastUsed(ctx, key.ID, "192.0.2.1")
+	require.NoError(t, err)
+	got, err = store.GetApiKey(ctx, "full_api_key")
+	require.NoError(t, err)
+	require.NotNil(t, got)
+	require.NotNil(t, got.LastUsedAt)
+	assert.WithinDuration(t, time.Now(), *got.LastUsedAt, time.Minute)
+	assert.Equal(t, "192.0.2.1", got.LastUsedIP)
 }
 
 func TestGetApiKeysByUserID(t *testing.T) {
@@ -133,17 +246,18 @@ func TestGetApiKeysByUserID(t *testing.T) {
 	setupTestDataForUsers(t)
 
 	// Insert API keys for the test user
-	err := store.Exec(ctx, `INSERT INTO api_keys (key, label, user_id) VALUES 
-        ('key1', 'Key 1', 2), 
-        ('key2', 'Key 2', 2)`)
+	err := store.Exec(ctx, `INSERT INTO api_keys (key_hash, prefix, label, user_id) VALUES
+        (encode(sha256('key1'), 'hex'), 'key1', 'Key 1', 2),
+        (encode(sha256('key2'), 'hex'), 'key2', 'Key 2', 2)`)
 	require.NoError(t, err)
 
 	// Fetch API keys for the test user
 	keys, err := store.GetApiKeysByUserID(ctx, 2)
 	require.NoError(t, err)
 	require.Len(t, keys, 2)
-	assert.Equal(t, "key1", keys[0].Key)
-	assert.Equal(t, "key2", keys[1].Key)
+	assert.Equal(t, "key1", keys[0].Prefix)
+	assert.Equal(t, "key2", keys[1].Prefix)
+	assert.Empty(t, keys[0].Key, "keys can't be recovered once saved")
 }
 
 func TestUpdateApiKeyLabel(t *testing.T) {
@@ -151,7 +265,7 @@ func TestUpdateApiKeyLabel(t *testing.T) {
 	setupTestDataForUsers(t)
 
 	// Insert an API key for the test user
-	err := store.Exec(ctx, `INSERT INTO api_keys (key, label, user_id) VALUES ('key_to_update', 'Old Label', 2)`)
+	err := store.Exec(ctx, `INSERT INTO api_keys (key_hash, prefix, label, user_id) VALUES (encode(sha256('key_to_update'), 'hex'), 'key_to_u', 'Old Label', 2)`)
 	require.NoError(t, err)
 
 	// Update the API key label
@@ -175,7 +289,7 @@ func TestDeleteApiKey(t *testing.T) {
 	setupTestDataForUsers(t)
 
 	// Insert an API key for the test user
-	err := store.Exec(ctx, `INSERT INTO api_keys (key, label, user_id) VALUES ('key_to_delete', 'Label', 2)`)
+	err := store.Exec(ctx, `INSERT INTO api_keys (key_hash, prefix, label, user_id) VALUES (encode(sha256('key_to_delete'), 'hex'), 'key_to_d', 'Label', 2)`)
 	require.NoError(t, err)
 
 	// Delete the API key
@@ -197,3 +311,257 @@ func TestCountUsers(t *testing.T) {
 	require.NoError(t, err)
 	assert.GreaterOrEqual(t, count, 3) // Special user + test users
 }
+
+func TestGetUsers(t *testing.T) {
+	ctx := context.Background()
+	setupTestDataForUsers(t)
+
+	users, err := store.GetUsers(ctx)
+	require.NoError(t, err)
+	require.Len(t, users, 3)
+	assert.Equal(t, int32(1), users[0].ID)
+	assert.Equal(t, "test_user", users[1].Username)
+	assert.Equal(t, "admin", string(users[2].Role))
+	assert.False(t, users[1].Disabled)
+
+	user, err := store.GetUserByID(ctx, 2)
+	require.NoError(t, err)
+	require.NotNil(t, user)
+	assert.Equal(t, "test_user", user.Username)
+
+	user, err = store.GetUserByID(ctx, 999)
+	require.NoError(t, err)
+	assert.Nil(t, user)
+}
+
+func TestUpdateUserRoleAndDisabled(t *testing.T) {
+	ctx := context.Background()
+	setupTestDataForUsers(t)
+
+	err := store.Exec(ctx, `INSERT INTO api_keys (key_hash, prefix, label, user_id) VALUES (encode(sha256('test_key'), 'hex'), 'test_key', 'Test Key', 2)`)
+	require.NoError(t, err)
+	session, err := store.SaveSession(ctx, db.SaveSessionOpts{UserID: 2, ExpiresAt: time.Now().Add(time.Hour)})
+	require.NoError(t, err)
+
+	disabled := true
+	err = store.UpdateUser(ctx, db.UpdateUserOpts{ID: 2, Role: models.UserRoleAdmin, Disabled: &disabled})
+	require.NoError(t, err)
+
+	user, err := store.GetUserByID(ctx, 2)
+	require.NoError(t, err)
+	require.NotNil(t, user)
+	assert.Equal(t, models.UserRoleAdmin, user.Role)
+	assert.True(t, user.Disabled)
+
+	// disabled users can't authenticate
+	user, err = store.GetUserByApiKey(ctx, "test_key")
+	require.NoError(t, err)
+	assert.Nil(t, user)
+	user, err = store.GetUserBySession(ctx, session.ID)
+	require.NoError(t, err)
+	assert.Nil(t, user)
+	count, err := store.Count(ctx, `SELECT COUNT(*) FROM sessions WHERE user_id = 2`)
+	require.NoError(t, err)
+	assert.Equal(t, 0, count, "expected sessions to be removed when disabling a user")
+
+	disabled = false
+	err = store.UpdateUser(ctx, db.UpdateUserOpts{ID: 2, Disabled: &disabled})
+	require.NoError(t, err)
+	user, err = store.GetUserByApiKey(ctx, "test_key")
+	require.NoError(t, err)
+	require.NotNil(t, user)
+	assert.Equal(t, models.UserRoleAdmin, user.Role)
+
+	err = store.UpdateUser(ctx, db.UpdateUserOpts{ID: 2, Role: "superuser"})
+	assert.Error(t, err)
+}
+
+func TestDeleteUser(t *testing.T) {
+	ctx := context.Background()
+	setupTestDataForUsers(t)
+
+	err := store.DeleteUser(ctx, 2)
+	require.NoError(t, err)
+
+	user, err := store.GetUserByID(ctx, 2)
+	require.NoError(t, err)
+	assert.Nil(t, user)
+}
+
+func TestUserInvites(t *testing.T) {
+	ctx := context.Background()
+	setupTestDataForUsers(t)
+	err := store.Exec(ctx, `TRUNCATE user_invites RESTART IDENTITY CASCADE`)
+	require.NoError(t, err)
+
+	invite, err := store.SaveUserInvite(ctx, db.SaveUserInviteOpts{
+		Token:     "invite_token",
+		Role:      models.UserRoleAdmin,
+		CreatedBy: 1,
+		ExpiresAt: time.Now().Add(time.Hour),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, models.UserRoleAdmin, invite.Role)
+	assert.Nil(t, invite.UsedAt)
+
+	_, err = store.SaveUserInvite(ctx, db.SaveUserInviteOpts{
+		Token:     "expired_token",
+		ExpiresAt: time.Now().Add(-time.Hour),
+	})
+	require.NoError(t, err)
+
+	// invalid usernames don't use up the invite
+	_, err = store.RedeemUserInvite(ctx, db.RedeemUserInviteOpts{Token: "invite_token", Username: "not valid!", Password: "password123"})
+	assert.Error(t, err)
+
+	user, err := store.RedeemUserInvite(ctx, db.RedeemUserInviteOpts{Token: "invite_token", Username: "Invited", Password: "password123"})
+	require.NoError(t, err)
+	require.NotNil(t, user)
+	assert.Equal(t, "invited", user.Username)
+	assert.Equal(t, models.UserRoleAdmin, user.Role)
+
+	// invites can only be used once
+	user, err = store.RedeemUserInvite(ctx, db.RedeemUserInviteOpts{Token: "invite_token", Username: "invited2", Password: "password123"})
+	require.NoError(t, err)
+	assert.Nil(t, user)
+
+	user, err = store.RedeemUserInvite(ctx, db.RedeemUserInviteOpts{Token: "expired_token", Username: "invited3", Password: "password123"})
+	require.NoError(t, err)
+	assert.Nil(t, user)
+
+	invites, err := store.GetUserInvites(ctx)
+	require.NoError(t, err)
+	require.Len(t, invites, 2)
+	for _, i := range invites {
+		if i.Token == "invite_token" {
+			require.NotNil(t, i.UsedAt)
+			assert.NotZero(t, i.UsedBy)
+		}
+	}
+
+	err = store.DeleteUserInvite(ctx, invite.ID)
+	require.NoError(t, err)
+	count, err := store.Count(ctx, `SELECT COUNT(*) FROM user_invites`)
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+}
+
+func TestProfileVisibility(t *testing.T) {
+	ctx := context.Background()
+	setupTestDataForUsers(t)
+
+	user, err := store.GetUserByID(ctx, 2)
+	require.NoError(t, err)
+	require.NotNil(t, user)
+	assert.Equal(t, models.ProfileVisibilityPrivate, user.Visibility, "profiles should be private by default")
+
+	users, err := store.GetPublicUsers(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, users)
+
+	err = store.UpdateUser(ctx, db.UpdateUserOpts{ID: 2, Visibility: models.ProfileVisibilityPublic})
+	require.NoError(t, err)
+	err = store.UpdateUser(ctx, db.UpdateUserOpts{ID: 3, Visibility: models.ProfileVisibilityUnlisted})
+	require.NoError(t, err)
+
+	users, err = store.GetPublicUsers(ctx)
+	require.NoError(t, err)
+	require.Len(t, users, 1)
+	assert.Equal(t, "test_user", users[0].Username)
+	assert.Equal(t, models.ProfileVisibilityPublic, users[0].Visibility)
+
+	user, err = store.GetUserByUsername(ctx, "admin_user")
+	require.NoError(t, err)
+	require.NotNil(t, user)
+	assert.Equal(t, models.ProfileVisibilityUnlisted, user.Visibility)
+
+	// disabled users are never listed
+	disabled := true
+	err = store.UpdateUser(ctx, db.UpdateUserOpts{ID: 2, Disabled: &disabled})
+	require.NoError(t, err)
+	users, err = store.GetPublicUsers(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, users)
+
+	err = store.UpdateUser(ctx, db.UpdateUserOpts{ID: 2, Visibility: "friends"})
+	assert.Error(t, err)
+}
+
+func TestOIDCSubject(t *testing.T) {
+	ctx := context.Background()
+	setupTestDataForUsers(t)
+
+	user, err := store.GetUserByOIDCSubject(ctx, "subject-1")
+	require.NoError(t, err)
+	assert.Nil(t, user)
+
+	err = store.UpdateUser(ctx, db.UpdateUserOpts{ID: 2, OIDCSubject: "subject-1"})
+	require.NoError(t, err)
+
+	user, err = store.GetUserByOIDCSubject(ctx, "subject-1")
+	require.NoError(t, err)
+	require.NotNil(t, user)
+	assert.Equal(t, "test_user", user.Username)
+	assert.Equal(t, "subject-1", user.OIDCSubject)
+
+	// a subject can only be linked to one user
+	err = store.UpdateUser(ctx, db.UpdateUserOpts{ID: 3, OIDCSubject: "subject-1"})
+	assert.Error(t, err)
+}
+
+func TestUserTOTP(t *testing.T) {
+	ctx := context.Background()
+	setupTestDataForUsers(t)
+
+	require.NoError(t, store.SetUserTOTPSecret(ctx, 2, "JBSWY3DPEHPK3PXP"))
+	user, err := store.GetUserByID(ctx, 2)
+	require.NoError(t, err)
+	assert.Equal(t, "JBSWY3DPEHPK3PXP", user.TOTPSecret)
+	assert.False(t, user.TOTPEnabled, "expected totp to stay disabled until verified")
+
+	require.NoError(t, store.EnableUserTOTP(ctx, 2, []string{"aaaaa-bbbbb", "ccccc-ddddd"}))
+	user, err = store.GetUserByUsername(ctx, "test_user")
+	require.NoError(t, err)
+	assert.True(t, user.TOTPEnabled)
+	count, err := store.Count(ctx, `SELECT COUNT(*) FROM users WHERE id = 2 AND 'aaaaa-bbbbb' = ANY(totp_recovery_codes)`)
+	require.NoError(t, err)
+	assert.Equal(t, 0, count, "expected recovery codes to be stored hashed")
+
+	// codes can't be reused, and neither can codes from earlier steps
+	ok, err := store.UseTOTPStep(ctx, 2, 100)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	ok, err = store.UseTOTPStep(ctx, 2, 100)
+	require.NoError(t, err)
+	assert.False(t, ok)
+	ok, err = store.UseTOTPStep(ctx, 2, 99)
+	require.NoError(t, err)
+	assert.False(t, ok)
+	ok, err = store.UseTOTPStep(ctx, 2, 101)
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	ok, err = store.UseTOTPRecoveryCode(ctx, 2, "AAAAABBBBB")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	ok, err = store.UseTOTPRecoveryCode(ctx, 2, "aaaaa-bbbbb")
+	require.NoError(t, err)
+	assert.False(t, ok, "expected recovery codes to be single use")
+	ok, err = store.UseTOTPRecoveryCode(ctx, 3, "ccccc-ddddd")
+	require.NoError(t, err)
+	assert.False(t, ok, "expected recovery codes to belong to one user")
+
+	require.NoError(t, store.DisableUserTOTP(ctx, 2))
+	user, err = store.GetUserByID(ctx, 2)
+	require.NoError(t, err)
+	assert.False(t, user.TOTPEnabled)
+	assert.Empty(t, user.TOTPSecret)
+	ok, err = store.UseTOTPRecoveryCode(ctx, 2, "ccccc-ddddd")
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func sha256Hex(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
@@ -1,12 +1,17 @@
 package db
 
 import (
+	"errors"
 	"time"
 
 	"github.com/gabehf/koito/internal/models"
 	"github.com/google/uuid"
 )
 
+// ErrCannotUnmerge is returned when undoing a merge would conflict with the current catalog,
+// because the merged item has been recreated since or the item it was merged into is gone.
+var ErrCannotUnmerge = errors.New("merge can no longer be undone")
+
 type InformationSource string
 
 const (
@@ -99,6 +104,7 @@ type TrackRecommendation struct {
 }
 
 type GetRecommendationsOpts struct {
+	UserID          int32
 	PastWindowStart time.Time
 	PastWindowEnd   time.Time
 	MinPastListens  int
@@ -110,3 +116,18 @@ type InterestBucket struct {
 	BucketEnd   time.Time `json:"bucket_end"`
 	ListenCount int64     `json:"listen_count"`
 }
+
+type ListenRecord struct {
+	UserID     int32     `json:"user_id"`
+	TrackID    int32     `json:"track_id"`
+	TrackTitle string    `json:"track_title,omitempty"`
+	ListenedAt time.Time `json:"listened_at"`
+	Client     string    `json:"client,omitempty"`
+}
+
+// A DuplicateCandidate is a listen that has another listen of the same track by the
+// same user within Window of it.
+type DuplicateCandidate struct {
+	ListenRecord
+	Window time.Duration
+}
@@ -37,10 +37,11 @@ type KoitoAlbum struct {
 	VariousArtists bool           `json:"various_artists"`
 }
 type KoitoArtist struct {
-	ImageUrl  string         `json:"image_url"`
-	MBID      *uuid.UUID     `json:"mbid"`
-	IsPrimary bool           `json:"is_primary"`
-	Aliases   []models.Alias `json:"aliases"`
+	ImageUrl  string            `json:"image_url"`
+	MBID      *uuid.UUID        `json:"mbid"`
+	IsPrimary bool              `json:"is_primary"`
+	Role      models.ArtistRole `json:"role,omitempty"`
+	Aliases   []models.Alias    `json:"aliases"`
 }
 
 func ExportData(ctx context.Context, user *models.User, store db.DB, out io.Writer) error {
@@ -141,6 +142,7 @@ func convertToExportFormat(item *db.ExportItem) *KoitoListen {
 	for i := range item.Artists {
 		ret.Artists = append(ret.Artists, KoitoArtist{
 			IsPrimary: item.Artists[i].IsPrimary,
+			Role:      item.Artists[i].Role,
 			MBID:      item.Artists[i].MbzID,
 			Aliases:   item.Artists[i].Aliases,
 			ImageUrl:  item.Artists[i].ImageSource,
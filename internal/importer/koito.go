@@ -50,6 +50,7 @@ func ImportKoitoFile(ctx context.Context, store db.DB, filename string) error {
 		var mbid uuid.UUID
 
 		artistIds := make([]int32, 0)
+		artistRoles := make(map[int32]models.ArtistRole)
 		for _, ia := range data.Listens[i].Artists {
 			mbid = uuid.Nil
 			if ia.MBID != nil {
@@ -78,10 +79,12 @@ func ImportKoitoFile(ctx context.Context, store db.DB, filename string) error {
 					return fmt.Errorf("ImportKoitoFile: %w", err)
 				}
 				artistIds = append(artistIds, artist.ID)
+				artistRoles[artist.ID] = importedArtistRole(ia.Role)
 			} else if err != nil {
 				return fmt.Errorf("ImportKoitoFile: %w", err)
 			} else {
 				artistIds = append(artistIds, artist.ID)
+				artistRoles[artist.ID] = importedArtistRole(ia.Role)
 			}
 		}
 		if len(artistIds) == 0 {
@@ -135,7 +138,7 @@ func ImportKoitoFile(ctx context.Context, store db.DB, filename string) error {
 			MusicBrainzID: mbid,
 			Title:         getPrimaryAliasFromAliasSlice(data.Listens[i].Track.Aliases),
 			ReleaseID:     albumId,
-			ArtistIDs:     artistIds,
+			ArtistIDs:     mainArtistIds(artistIds, artistRoles),
 		})
 		if errors.Is(err, pgx.ErrNoRows) {
 			// save track
@@ -144,6 +147,7 @@ func ImportKoitoFile(ctx context.Context, store db.DB, filename string) error {
 				RecordingMbzID: mbid,
 				Duration:       int32(data.Listens[i].Track.Duration),
 				ArtistIDs:      artistIds,
+				ArtistRoles:    artistRoles,
 				AlbumID:        albumId,
 			})
 			if err != nil {
@@ -182,3 +186,26 @@ func getPrimaryAliasFromAliasSlice(aliases []models.Alias) string {
 	}
 	return ""
 }
+
+// importedArtistRole returns the role an artist was credited with in an export, which is
+// missing from exports made before artist roles existed.
+func importedArtistRole(role models.ArtistRole) models.ArtistRole {
+	if role.Valid() {
+		return role
+	}
+	return models.ArtistRoleMain
+}
+
+// mainArtistIds returns the artists credited as main artists, or all of them when none are.
+func mainArtistIds(ids []int32, roles map[int32]models.ArtistRole) []int32 {
+	var main []int32
+	for _, id := range ids {
+		if roles[id] == models.ArtistRoleMain {
+			main = append(main, id)
+		}
+	}
+	if len(main) == 0 {
+		return ids
+	}
+	return main
+}
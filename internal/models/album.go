@@ -10,8 +10,21 @@ type Album struct {
 	Artists        []SimpleArtist `json:"artists"`
 	Genres         []string       `json:"genres"`
 	VariousArtists bool           `json:"is_various_artists"`
+	ReleaseGroupID *int32         `json:"release_group_id"`
+	EditionCount   int64          `json:"edition_count,omitempty"` // set when aggregated by release group
 	ListenCount    int64          `json:"listen_count"`
 	TimeListened   int64          `json:"time_listened"`
 	FirstListen    int64          `json:"first_listen"`
 	AllTimeRank    int64          `json:"all_time_rank"`
 }
+
+// ReleaseGroup groups the editions of an album, e.g. the original release, a deluxe edition
+// and a remaster.
+type ReleaseGroup struct {
+	ID           int32      `json:"id"`
+	MbzID        *uuid.UUID `json:"musicbrainz_id"`
+	Title        string     `json:"title"`
+	ListenCount  int64      `json:"listen_count"`
+	TimeListened int64      `json:"time_listened"`
+	Editions     []*Album   `json:"editions"`
+}
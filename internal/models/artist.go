@@ -2,6 +2,23 @@ package models
 
 import "github.com/google/uuid"
 
+// ArtistRole is how an artist is credited on a track.
+type ArtistRole string
+
+const (
+	ArtistRoleMain     ArtistRole = "main"
+	ArtistRoleFeatured ArtistRole = "featured"
+	ArtistRoleRemixer  ArtistRole = "remixer"
+)
+
+func (r ArtistRole) Valid() bool {
+	switch r {
+	case ArtistRoleMain, ArtistRoleFeatured, ArtistRoleRemixer:
+		return true
+	}
+	return false
+}
+
 type Artist struct {
 	ID           int32      `json:"id"`
 	MbzID        *uuid.UUID `json:"musicbrainz_id"`
@@ -13,12 +30,14 @@ type Artist struct {
 	TimeListened int64      `json:"time_listened"`
 	FirstListen  int64      `json:"first_listen"`
 	IsPrimary    bool       `json:"is_primary,omitempty"`
+	Role         ArtistRole `json:"role,omitempty"`
 	AllTimeRank  int64      `json:"all_time_rank"`
 }
 
 type SimpleArtist struct {
-	ID   int32  `json:"id"`
-	Name string `json:"name"`
+	ID   int32      `json:"id"`
+	Name string     `json:"name"`
+	Role ArtistRole `json:"role,omitempty"`
 }
 
 type ArtistWithFullAliases struct {
@@ -32,4 +51,5 @@ type ArtistWithFullAliases struct {
 	TimeListened int64      `json:"time_listened"`
 	FirstListen  int64      `json:"first_listen"`
 	IsPrimary    bool       `json:"is_primary,omitempty"`
+	Role         ArtistRole `json:"role,omitempty"`
 }
@@ -0,0 +1,48 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+type AuditAction string
+
+const (
+	AuditActionMerge            AuditAction = "merge"
+	AuditActionUnmerge          AuditAction = "unmerge"
+	AuditActionDelete           AuditAction = "delete"
+	AuditActionUpdate           AuditAction = "update"
+	AuditActionCreateAlias      AuditAction = "create_alias"
+	AuditActionDeleteAlias      AuditAction = "delete_alias"
+	AuditActionSetPrimaryAlias  AuditAction = "set_primary_alias"
+	AuditActionUpdateMbzID      AuditAction = "update_mbzid"
+	AuditActionReplaceImage     AuditAction = "replace_image"
+	AuditActionSetPrimaryArtist AuditAction = "set_primary_artist"
+	AuditActionSetArtistRole    AuditAction = "set_artist_role"
+	AuditActionSetReleaseGroup  AuditAction = "set_release_group"
+)
+
+type AuditTarget string
+
+const (
+	AuditTargetArtist AuditTarget = "artist"
+	AuditTargetAlbum  AuditTarget = "album"
+	AuditTargetTrack  AuditTarget = "track"
+)
+
+// An AuditEntry records a change made to the catalog.
+type AuditEntry struct {
+	ID int64 `json:"id"`
+	// nil when the change was not made by a user
+	UserID *int32 `json:"user_id"`
+	// the username at the time of the change
+	Username   string      `json:"username"`
+	Action     AuditAction `json:"action"`
+	TargetType AuditTarget `json:"target_type"`
+	// for merges, the source followed by the target; otherwise the changed item, followed by
+	// the related artist where there is one
+	TargetIDs []int32         `json:"target_ids"`
+	Before    json.RawMessage `json:"before"`
+	After     json.RawMessage `json:"after"`
+	CreatedAt time.Time       `json:"created_at"`
+}
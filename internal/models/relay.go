@@ -0,0 +1,59 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+type RelayStatus string
+
+const (
+	// waiting to be delivered, possibly after a failed attempt
+	RelayStatusPending RelayStatus = "pending"
+	// delivery failed permanently or exhausted all retries
+	RelayStatusDead RelayStatus = "dead"
+)
+
+// A RelayEntry is a ListenBrainz submission waiting to be relayed to another server.
+type RelayEntry struct {
+	ID            int32           `json:"id"`
+	UserID        int32           `json:"user_id"`
+	TargetID      *int32          `json:"target_id"` // nil for the globally configured relay
+	Payload       json.RawMessage `json:"payload"`
+	Status        RelayStatus     `json:"status"`
+	Attempts      int32           `json:"attempts"`
+	NextAttemptAt time.Time       `json:"next_attempt_at"`
+	LastError     string          `json:"last_error,omitempty"`
+	CreatedAt     time.Time       `json:"created_at"`
+	UpdatedAt     time.Time       `json:"updated_at"`
+}
+
+type RelayTargetKind string
+
+const (
+	RelayTargetListenBrainz RelayTargetKind = "listenbrainz"
+	RelayTargetLastFm       RelayTargetKind = "lastfm"
+	RelayTargetMaloja       RelayTargetKind = "maloja"
+)
+
+// A RelayTarget is a user-configured server that submitted listens are relayed to.
+type RelayTarget struct {
+	ID     int32           `json:"id"`
+	UserID int32           `json:"user_id"`
+	Kind   RelayTargetKind `json:"kind"`
+	Label  string          `json:"label"`
+	// base URL of the target API, e.g. https://api.listenbrainz.org/1
+	URL string `json:"url"`
+	// ListenBrainz user token, Last.fm session key or Maloja API key
+	Token string `json:"-"`
+	// Last.fm application credentials
+	ApiKey          string `json:"api_key,omitempty"`
+	ApiSecret       string `json:"-"`
+	Enabled         bool   `json:"enabled"`
+	RelayImports    bool   `json:"relay_imports"`
+	RelayPlayingNow bool   `json:"relay_playing_now"`
+	// when not empty, only listens from these clients are relayed
+	IncludeClients []string  `json:"include_clients"`
+	ExcludeClients []string  `json:"exclude_clients"`
+	CreatedAt      time.Time `json:"created_at"`
+}
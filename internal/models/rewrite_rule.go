@@ -0,0 +1,50 @@
+package models
+
+import "time"
+
+type RewriteField string
+
+const (
+	RewriteFieldArtist  RewriteField = "artist"
+	RewriteFieldTrack   RewriteField = "track"
+	RewriteFieldRelease RewriteField = "release"
+	RewriteFieldClient  RewriteField = "client"
+)
+
+type RewriteMatchType string
+
+const (
+	// case-insensitive comparison of the whole field
+	RewriteMatchExact RewriteMatchType = "exact"
+	// Go regular expression, matched anywhere in the field unless anchored
+	RewriteMatchRegex RewriteMatchType = "regex"
+)
+
+type RewriteAction string
+
+const (
+	// set TargetField (or Field) to Replacement
+	RewriteActionRewrite RewriteAction = "rewrite"
+	// remove the matched suffix from Field
+	RewriteActionStripSuffix RewriteAction = "strip_suffix"
+	// split the artist field into multiple artists, using the pattern as the separator
+	RewriteActionSplitArtists RewriteAction = "split_artists"
+	// discard the listen
+	RewriteActionDrop RewriteAction = "drop"
+)
+
+// A RewriteRule changes incoming listens before they are added to the catalog. Rules are applied
+// in order of Position.
+type RewriteRule struct {
+	ID          int32            `json:"id"`
+	UserID      int32            `json:"user_id"`
+	Position    int32            `json:"position"`
+	Enabled     bool             `json:"enabled"`
+	Field       RewriteField     `json:"field"`
+	MatchType   RewriteMatchType `json:"match_type"`
+	Pattern     string           `json:"pattern"`
+	Action      RewriteAction    `json:"action"`
+	TargetField RewriteField     `json:"target_field,omitempty"`
+	Replacement string           `json:"replacement,omitempty"`
+	CreatedAt   time.Time        `json:"created_at"`
+}
@@ -13,25 +13,93 @@ const (
 	UserRoleAdmin UserRole = "admin"
 )
 
+// ProfileVisibility controls who can see a user's listening history.
+type ProfileVisibility string
+
+const (
+	// Anyone can view the profile, and it is listed on the instance
+	ProfileVisibilityPublic ProfileVisibility = "public"
+	// Anyone who knows the username can view the profile, but it is not listed
+	ProfileVisibilityUnlisted ProfileVisibility = "unlisted"
+	// Only the user and admins can view the profile
+	ProfileVisibilityPrivate ProfileVisibility = "private"
+)
+
 type User struct {
-	ID       int32    `json:"id"`
-	Username string   `json:"username"`
-	Role     UserRole `json:"role"` // 'admin' | 'user'
-	Password []byte   `json:"-"`
+	ID         int32             `json:"id"`
+	Username   string            `json:"username"`
+	Role       UserRole          `json:"role"` // 'admin' | 'user'
+	Password   []byte            `json:"-"`
+	Disabled   bool              `json:"disabled"`
+	Visibility ProfileVisibility `json:"visibility"`
+	CreatedAt  time.Time         `json:"created_at"`
+	// The subject of the OpenID Connect identity linked to the user, if any
+	OIDCSubject string `json:"-"`
+	// Whether logging in with a password also requires a one-time code
+	TOTPEnabled bool   `json:"totp_enabled"`
+	TOTPSecret  string `json:"-"`
+}
+
+// A UserInvite lets someone create their own account once, with the role chosen by the
+// admin who issued it.
+type UserInvite struct {
+	ID        int32      `json:"id"`
+	Token     string     `json:"token"`
+	Role      UserRole   `json:"role"`
+	CreatedBy int32      `json:"created_by,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	UsedBy    int32      `json:"used_by,omitempty"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+}
+
+// ApiKeyScope limits what an API key can be used for. Sessions are not scoped.
+type ApiKeyScope string
+
+const (
+	// The key can only submit listens and now playing updates
+	ApiKeyScopeSubmit ApiKeyScope = "submit"
+	// The key can only read statistics and listening history
+	ApiKeyScopeRead ApiKeyScope = "read"
+	// The key can do anything its owner can
+	ApiKeyScopeFull ApiKeyScope = "full"
+)
+
+// Allows reports whether a key with this scope may be used where required is needed.
+func (s ApiKeyScope) Allows(required ApiKeyScope) bool {
+	return s == ApiKeyScopeFull || s == required
 }
 
 type ApiKey struct {
-	ID        int32     `json:"id"`
-	Key       string    `json:"key"`
-	Label     string    `json:"label"`
-	UserID    int32     `json:"user_id"`
-	CreatedAt time.Time `json:"created_at"`
+	ID int32 `json:"id"`
+	// Only known when the key is created, since keys are stored hashed
+	Key string `json:"key,omitempty"`
+	// The first characters of the key, so that keys can be told apart
+	Prefix string `json:"prefix"`
+	// The hex encoded SHA-256 of the key
+	KeyHash string `json:"-"`
+	// The hex encoded MD5 of the key, used by the Audioscrobbler 1.2 handshake. Only kept
+	// for keys created for legacy clients.
+	LegacyDigest string `json:"-"`
+	// Whether the key can be used with the Audioscrobbler 1.2 handshake
+	Legacy     bool        `json:"legacy"`
+	Label      string      `json:"label"`
+	UserID     int32       `json:"user_id"`
+	Scope      ApiKeyScope `json:"scope"`
+	CreatedAt  time.Time   `json:"created_at"`
+	ExpiresAt  *time.Time  `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time  `json:"last_used_at,omitempty"`
+	LastUsedIP string      `json:"last_used_ip,omitempty"`
 }
 
 type Session struct {
-	ID         uuid.UUID
-	UserID     int32
-	CreatedAt  time.Time
-	ExpiresAt  time.Time
-	Persistent bool
+	// The value of the session cookie, so it is never included in responses
+	ID         uuid.UUID `json:"-"`
+	UserID     int32     `json:"-"`
+	CreatedAt  time.Time `json:"created_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	Persistent bool      `json:"persistent"`
+	UserAgent  string    `json:"user_agent"`
+	IP         string    `json:"ip"`
+	LastSeenAt time.Time `json:"last_seen_at"`
 }
@@ -0,0 +1,202 @@
+// Package nowplaying keeps track of what each user is currently listening to, and notifies
+// subscribers when that changes. Entries expire once the track should have finished playing.
+package nowplaying
+
+import (
+	"sync"
+	"time"
+)
+
+// how long an entry lasts when the duration of the track is unknown
+const defaultExpiration = 10 * time.Minute
+
+type Entry struct {
+	UserID    int32     `json:"-"`
+	TrackID   int32     `json:"track_id"`
+	Client    string    `json:"client,omitempty"`
+	StartedAt time.Time `json:"started_at"`
+	// zero when the duration of the track is unknown
+	Duration  time.Duration `json:"-"`
+	ExpiresAt time.Time     `json:"expires_at"`
+}
+
+// Progress returns how far into the track playback is at time t.
+func (e Entry) Progress(t time.Time) time.Duration {
+	p := t.Sub(e.StartedAt)
+	if p < 0 {
+		return 0
+	}
+	if e.Duration > 0 && p > e.Duration {
+		return e.Duration
+	}
+	return p
+}
+
+// An Event is sent to subscribers whenever a user starts playing a track, or when the
+// track they were playing expires. Entry is nil when nothing is playing.
+type Event struct {
+	UserID int32
+	Entry  *Entry
+}
+
+type tracker struct {
+	mu          sync.Mutex
+	entries     map[int32]Entry
+	timers      map[int32]*time.Timer
+	subscribers map[int32]map[chan Event]struct{}
+}
+
+var global = newTracker()
+
+func newTracker() *tracker {
+	return &tracker{
+		entries:     make(map[int32]Entry),
+		timers:      make(map[int32]*time.Timer),
+		subscribers: make(map[int32]map[chan Event]struct{}),
+	}
+}
+
+// Set records that a user has started playing a track. The entry expires once the track
+// has played through from startedAt, or after ten minutes when the duration is unknown.
+func Set(userID, trackID int32, client string, startedAt time.Time, duration time.Duration) {
+	global.set(userID, trackID, client, startedAt, duration)
+}
+
+// Get returns what a user is currently playing.
+func Get(userID int32) (Entry, bool) {
+	return global.get(userID)
+}
+
+// Clear removes the now playing entry of a user.
+func Clear(userID int32) {
+	global.clear(userID)
+}
+
+// Subscribe returns a channel that receives every now playing change for a user, along
+// with a function that must be called to unsubscribe. The channel is closed when the
+// subscription ends.
+func Subscribe(userID int32) (<-chan Event, func()) {
+	return global.subscribe(userID)
+}
+
+// CloseSubscribers ends every subscription, e.g. so that streaming requests return when
+// the server is shutting down.
+func CloseSubscribers() {
+	global.closeSubscribers()
+}
+
+func (t *tracker) set(userID, trackID int32, client string, startedAt time.Time, duration time.Duration) {
+	now := time.Now()
+	if startedAt.IsZero() || startedAt.After(now) {
+		startedAt = now
+	}
+	e := Entry{
+		UserID:    userID,
+		TrackID:   trackID,
+		Client:    client,
+		StartedAt: startedAt,
+		Duration:  duration,
+	}
+	if duration > 0 {
+		e.ExpiresAt = startedAt.Add(duration)
+		// a start time too far in the past means the client's clock can't be trusted
+		if !e.ExpiresAt.After(now) {
+			e.StartedAt = now
+			e.ExpiresAt = now.Add(duration)
+		}
+	} else {
+		e.ExpiresAt = now.Add(defaultExpiration)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if timer, ok := t.timers[userID]; ok {
+		timer.Stop()
+	}
+	t.entries[userID] = e
+	t.timers[userID] = time.AfterFunc(e.ExpiresAt.Sub(now), func() {
+		t.expire(userID, e)
+	})
+	t.publish(Event{UserID: userID, Entry: &e})
+}
+
+func (t *tracker) get(userID int32) (Entry, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	e, ok := t.entries[userID]
+	if !ok || !time.Now().Before(e.ExpiresAt) {
+		return Entry{}, false
+	}
+	return e, true
+}
+
+func (t *tracker) clear(userID int32) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if _, ok := t.entries[userID]; !ok {
+		return
+	}
+	t.timers[userID].Stop()
+	delete(t.timers, userID)
+	delete(t.entries, userID)
+	t.publish(Event{UserID: userID})
+}
+
+// expire removes an entry when its timer fires, unless it has since been replaced.
+func (t *tracker) expire(userID int32, e Entry) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if current, ok := t.entries[userID]; !ok || current != e {
+		return
+	}
+	delete(t.timers, userID)
+	delete(t.entries, userID)
+	t.publish(Event{UserID: userID})
+}
+
+// publish must be called with t.mu held. Subscribers that are not keeping up miss events
+// rather than blocking the caller.
+func (t *tracker) publish(ev Event) {
+	for ch := range t.subscribers[ev.UserID] {
+		select {
+		case ch <- ev:
+		default:
+		}
+	}
+}
+
+func (t *tracker) subscribe(userID int32) (<-chan Event, func()) {
+	ch := make(chan Event, 8)
+	t.mu.Lock()
+	if t.subscribers[userID] == nil {
+		t.subscribers[userID] = make(map[chan Event]struct{})
+	}
+	t.subscribers[userID][ch] = struct{}{}
+	t.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			if _, ok := t.subscribers[userID][ch]; ok {
+				delete(t.subscribers[userID], ch)
+				close(ch)
+			}
+			if len(t.subscribers[userID]) == 0 {
+				delete(t.subscribers, userID)
+			}
+		})
+	}
+}
+
+func (t *tracker) closeSubscribers() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for userID, subs := range t.subscribers {
+		for ch := range subs {
+			close(ch)
+		}
+		delete(t.subscribers, userID)
+	}
+}
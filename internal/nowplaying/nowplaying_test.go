@@ -0,0 +1,76 @@
+package nowplaying_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gabehf/koito/internal/nowplaying"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetAndGet(t *testing.T) {
+	start := time.Now().Add(-30 * time.Second)
+	nowplaying.Set(101, 7, "navidrome", start, 200*time.Second)
+
+	e, ok := nowplaying.Get(101)
+	require.True(t, ok)
+	assert.EqualValues(t, 7, e.TrackID)
+	assert.Equal(t, "navidrome", e.Client)
+	assert.True(t, e.StartedAt.Equal(start))
+	assert.True(t, e.ExpiresAt.Equal(start.Add(200*time.Second)))
+	assert.InDelta(t, 30, e.Progress(time.Now()).Seconds(), 1)
+
+	// other users are unaffected
+	_, ok = nowplaying.Get(102)
+	assert.False(t, ok)
+
+	nowplaying.Clear(101)
+	_, ok = nowplaying.Get(101)
+	assert.False(t, ok)
+}
+
+func TestSetUnknownDuration(t *testing.T) {
+	nowplaying.Set(103, 7, "", time.Time{}, 0)
+	e, ok := nowplaying.Get(103)
+	require.True(t, ok)
+	assert.InDelta(t, 10*time.Minute, time.Until(e.ExpiresAt), float64(time.Second))
+	nowplaying.Clear(103)
+}
+
+func TestSetStaleStartTime(t *testing.T) {
+	// a start time longer ago than the track is long is replaced with the current time
+	nowplaying.Set(104, 7, "", time.Now().Add(-time.Hour), 200*time.Second)
+	e, ok := nowplaying.Get(104)
+	require.True(t, ok)
+	assert.InDelta(t, 200*time.Second, time.Until(e.ExpiresAt), float64(time.Second))
+	nowplaying.Clear(104)
+}
+
+func TestSubscribe(t *testing.T) {
+	events, unsubscribe := nowplaying.Subscribe(105)
+
+	nowplaying.Set(105, 7, "", time.Now(), time.Second)
+	select {
+	case ev := <-events:
+		require.NotNil(t, ev.Entry)
+		assert.EqualValues(t, 7, ev.Entry.TrackID)
+	case <-time.After(time.Second):
+		t.Fatal("expected an event when playback starts")
+	}
+
+	select {
+	case ev := <-events:
+		assert.Nil(t, ev.Entry)
+	case <-time.After(3 * time.Second):
+		t.Fatal("expected an event when playback expires")
+	}
+	_, ok := nowplaying.Get(105)
+	assert.False(t, ok)
+
+	unsubscribe()
+	_, open := <-events
+	assert.False(t, open)
+	// unsubscribing twice is harmless
+	unsubscribe()
+}
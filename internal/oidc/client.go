@@ -0,0 +1,311 @@
+// package oidc implements the OpenID Connect authorization code flow used for single sign-on
+package oidc
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const discoveryPath = "/.well-known/openid-configuration"
+
+// allowed difference between our clock and the provider's when checking token expiry
+const clockSkew = time.Minute
+
+// Provider talks to a single OpenID Connect provider.
+type Provider struct {
+	issuer       string
+	clientID     string
+	clientSecret string
+	scopes       []string
+	httpClient   *http.Client
+
+	mu        sync.Mutex
+	discovery *discoveryDocument
+	keys      []jsonWebKey
+}
+
+type ProviderOpts struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	Scopes       []string
+	// Defaults to a client with a 10 second timeout
+	HTTPClient *http.Client
+}
+
+type discoveryDocument struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	UserinfoEndpoint      string `json:"userinfo_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+type tokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	IDToken     string `json:"id_token"`
+	Error       string `json:"error"`
+	ErrorDesc   string `json:"error_description"`
+}
+
+// Claims are the claims of a verified ID token, merged with the provider's userinfo response.
+type Claims map[string]any
+
+func NewProvider(opts ProviderOpts) *Provider {
+	p := &Provider{
+		issuer:       strings.TrimSuffix(opts.Issuer, "/"),
+		clientID:     opts.ClientID,
+		clientSecret: opts.ClientSecret,
+		scopes:       opts.Scopes,
+		httpClient:   opts.HTTPClient,
+	}
+	if p.httpClient == nil {
+		p.httpClient = &http.Client{Timeout: 10 * time.Second}
+	}
+	if len(p.scopes) == 0 {
+		p.scopes = []string{"openid"}
+	}
+	return p
+}
+
+// AuthCodeURL returns the URL of the provider's login page. The verifier is the PKCE code
+// verifier that must be passed to Exchange along with the returned code.
+func (p *Provider) AuthCodeURL(ctx context.Context, redirectURL, state, nonce, verifier string) (string, error) {
+	d, err := p.getDiscovery(ctx)
+	if err != nil {
+		return "", fmt.Errorf("AuthCodeURL: %w", err)
+	}
+	challenge := sha256.Sum256([]byte(verifier))
+	params := url.Values{}
+	params.Set("response_type", "code")
+	params.Set("client_id", p.clientID)
+	params.Set("redirect_uri", redirectURL)
+	params.Set("scope", strings.Join(p.scopes, " "))
+	params.Set("state", state)
+	params.Set("nonce", nonce)
+	params.Set("code_challenge", base64.RawURLEncoding.EncodeToString(challenge[:]))
+	params.Set("code_challenge_method", "S256")
+
+	sep := "?"
+	if strings.Contains(d.AuthorizationEndpoint, "?") {
+		sep = "&"
+	}
+	return d.AuthorizationEndpoint + sep + params.Encode(), nil
+}
+
+// Exchange redeems an authorization code, verifies the returned ID token and returns its claims,
+// along with any claims the userinfo endpoint adds.
+func (p *Provider) Exchange(ctx context.Context, redirectURL, code, verifier, nonce string) (Claims, error) {
+	d, err := p.getDiscovery(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("Exchange: %w", err)
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", redirectURL)
+	form.Set("code_verifier", verifier)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("Exchange: failed to build request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(url.QueryEscape(p.clientID), url.QueryEscape(p.clientSecret))
+
+	var token tokenResponse
+	status, err := p.doJSON(req, &token)
+	if err != nil {
+		return nil, fmt.Errorf("Exchange: %w", err)
+	}
+	if status != http.StatusOK || token.Error != "" {
+		return nil, fmt.Errorf("Exchange: token endpoint returned %d: %s %s", status, token.Error, token.ErrorDesc)
+	}
+	if token.IDToken == "" {
+		return nil, errors.New("Exchange: token response did not include an id_token")
+	}
+
+	claims, err := p.verifyIDToken(ctx, d, token.IDToken, nonce)
+	if err != nil {
+		return nil, fmt.Errorf("Exchange: %w", err)
+	}
+
+	if d.UserinfoEndpoint != "" && token.AccessToken != "" {
+		info, err := p.userinfo(ctx, d, token.AccessToken)
+		if err != nil {
+			return nil, fmt.Errorf("Exchange: %w", err)
+		}
+		// the userinfo response must describe the same user as the ID token
+		if sub, _ := info["sub"].(string); sub != claims.Subject() {
+			return nil, errors.New("Exchange: userinfo subject does not match the id_token")
+		}
+		for k, v := range info {
+			if _, ok := claims[k]; !ok {
+				claims[k] = v
+			}
+		}
+	}
+
+	return claims, nil
+}
+
+// verifyIDToken checks the signature of an ID token against the provider's published keys, and
+// then its claims. The signature is always checked, so that the token can be trusted even when
+// the connection to the provider is not using TLS.
+func (p *Provider) verifyIDToken(ctx context.Context, d *discoveryDocument, idToken, nonce string) (Claims, error) {
+	parts := strings.Split(idToken, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("verifyIDToken: id_token is not a JWT")
+	}
+	if err := p.verifySignature(ctx, d, parts); err != nil {
+		return nil, fmt.Errorf("verifyIDToken: %w", err)
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return nil, fmt.Errorf("verifyIDToken: failed to decode payload: %w", err)
+	}
+	var claims Claims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, fmt.Errorf("verifyIDToken: failed to unmarshal payload: %w", err)
+	}
+
+	if iss := claims.String("iss"); iss != d.Issuer {
+		return nil, fmt.Errorf("verifyIDToken: unexpected issuer '%s'", iss)
+	}
+	if !claims.audienceContains(p.clientID) {
+		return nil, errors.New("verifyIDToken: id_token was not issued to this client")
+	}
+	if azp := claims.String("azp"); azp != "" && azp != p.clientID {
+		return nil, errors.New("verifyIDToken: id_token was issued to another party")
+	}
+	exp, ok := claims["exp"].(float64)
+	if !ok {
+		return nil, errors.New("verifyIDToken: id_token has no expiry")
+	}
+	if time.Now().After(time.Unix(int64(exp), 0).Add(clockSkew)) {
+		return nil, errors.New("verifyIDToken: id_token has expired")
+	}
+	if claims.String("nonce") != nonce {
+		return nil, errors.New("verifyIDToken: nonce does not match")
+	}
+	if claims.Subject() == "" {
+		return nil, errors.New("verifyIDToken: id_token has no subject")
+	}
+	return claims, nil
+}
+
+func (p *Provider) userinfo(ctx context.Context, d *discoveryDocument, accessToken string) (Claims, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, d.UserinfoEndpoint, nil)
+	if err != nil {
+		return nil, fmt.Errorf("userinfo: failed to build request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	req.Header.Set("Accept", "application/json")
+
+	var info Claims
+	status, err := p.doJSON(req, &info)
+	if err != nil {
+		return nil, fmt.Errorf("userinfo: %w", err)
+	}
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("userinfo: endpoint returned %d", status)
+	}
+	return info, nil
+}
+
+// getDiscovery fetches the provider metadata, which is cached once it has been fetched successfully.
+func (p *Provider) getDiscovery(ctx context.Context) (*discoveryDocument, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.discovery != nil {
+		return p.discovery, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.issuer+discoveryPath, nil)
+	if err != nil {
+		return nil, fmt.Errorf("getDiscovery: failed to build request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+
+	var d discoveryDocument
+	status, err := p.doJSON(req, &d)
+	if err != nil {
+		return nil, fmt.Errorf("getDiscovery: %w", err)
+	}
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("getDiscovery: discovery endpoint returned %d", status)
+	}
+	if d.Issuer != p.issuer {
+		return nil, fmt.Errorf("getDiscovery: provider reports issuer '%s', expected '%s'", d.Issuer, p.issuer)
+	}
+	if d.AuthorizationEndpoint == "" || d.TokenEndpoint == "" || d.JWKSURI == "" {
+		return nil, errors.New("getDiscovery: provider metadata is missing required endpoints")
+	}
+	p.discovery = &d
+	return p.discovery, nil
+}
+
+func (p *Provider) doJSON(req *http.Request, v any) (int, error) {
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return resp.StatusCode, fmt.Errorf("failed to read response: %w", err)
+	}
+	if err := json.Unmarshal(body, v); err != nil && resp.StatusCode == http.StatusOK {
+		return resp.StatusCode, fmt.Errorf("failed to unmarshal response: %w", err)
+	}
+	return resp.StatusCode, nil
+}
+
+// Subject returns the identifier of the user at the provider.
+func (c Claims) Subject() string {
+	return c.String("sub")
+}
+
+// String returns the claim as a string, or an empty string if it is missing or not a string.
+func (c Claims) String(name string) string {
+	s, _ := c[name].(string)
+	return s
+}
+
+// Strings returns a claim that may be either a single string or a list of strings.
+func (c Claims) Strings(name string) []string {
+	switch v := c[name].(type) {
+	case string:
+		return []string{v}
+	case []any:
+		ret := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				ret = append(ret, s)
+			}
+		}
+		return ret
+	}
+	return nil
+}
+
+func (c Claims) audienceContains(clientID string) bool {
+	for _, aud := range c.Strings("aud") {
+		if aud == clientID {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,136 @@
+package oidc_test
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/gabehf/koito/internal/oidc"
+	"github.com/gabehf/koito/internal/oidc/oidctest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const redirectURL = "http://koito.test/apis/web/v1/oidc/callback"
+
+// login follows the provider's redirect back to Koito and returns the code and state.
+func login(t *testing.T, authURL string) (code, state string) {
+	client := &http.Client{CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	resp, err := client.Get(authURL)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusFound, resp.StatusCode)
+	loc, err := url.Parse(resp.Header.Get("Location"))
+	require.NoError(t, err)
+	return loc.Query().Get("code"), loc.Query().Get("state")
+}
+
+func TestExchange(t *testing.T) {
+	issuer := oidctest.NewIssuer("koito", "secret")
+	defer issuer.Close()
+	issuer.SetUser(map[string]any{
+		"sub":                "user-1",
+		"preferred_username": "alice",
+		"groups":             []string{"music", "koito-admins"},
+	})
+
+	p := oidc.NewProvider(oidc.ProviderOpts{
+		Issuer:       issuer.URL + "/",
+		ClientID:     "koito",
+		ClientSecret: "secret",
+		Scopes:       []string{"openid", "profile", "groups"},
+	})
+	ctx := context.Background()
+
+	authURL, err := p.AuthCodeURL(ctx, redirectURL, "the-state", "the-nonce", "the-verifier")
+	require.NoError(t, err)
+	u, err := url.Parse(authURL)
+	require.NoError(t, err)
+	assert.Equal(t, "openid profile groups", u.Query().Get("scope"))
+	assert.Empty(t, u.Query().Get("code_verifier"), "the verifier must not be sent to the browser")
+
+	code, state := login(t, authURL)
+	assert.Equal(t, "the-state", state)
+
+	claims, err := p.Exchange(ctx, redirectURL, code, "the-verifier", "the-nonce")
+	require.NoError(t, err)
+	assert.Equal(t, "user-1", claims.Subject())
+	assert.Equal(t, "alice", claims.String("preferred_username"))
+	assert.Equal(t, []string{"music", "koito-admins"}, claims.Strings("groups"))
+
+	// codes can only be used once
+	_, err = p.Exchange(ctx, redirectURL, code, "the-verifier", "the-nonce")
+	assert.Error(t, err)
+}
+
+func TestExchangeRejectsInvalidResponses(t *testing.T) {
+	issuer := oidctest.NewIssuer("koito", "secret")
+	defer issuer.Close()
+	ctx := context.Background()
+
+	p := oidc.NewProvider(oidc.ProviderOpts{Issuer: issuer.URL, ClientID: "koito", ClientSecret: "secret"})
+
+	authURL, err := p.AuthCodeURL(ctx, redirectURL, "state", "nonce", "verifier")
+	require.NoError(t, err)
+	code, _ := login(t, authURL)
+	_, err = p.Exchange(ctx, redirectURL, code, "verifier", "another-nonce")
+	assert.Error(t, err, "nonce mismatch should be rejected")
+
+	authURL, err = p.AuthCodeURL(ctx, redirectURL, "state", "nonce", "verifier")
+	require.NoError(t, err)
+	code, _ = login(t, authURL)
+	_, err = p.Exchange(ctx, redirectURL, code, "wrong-verifier", "nonce")
+	assert.Error(t, err, "PKCE verifier mismatch should be rejected")
+
+	wrongSecret := oidc.NewProvider(oidc.ProviderOpts{Issuer: issuer.URL, ClientID: "koito", ClientSecret: "not-the-secret"})
+	authURL, err = wrongSecret.AuthCodeURL(ctx, redirectURL, "state", "nonce", "verifier")
+	require.NoError(t, err)
+	code, _ = login(t, authURL)
+	_, err = wrongSecret.Exchange(ctx, redirectURL, code, "verifier", "nonce")
+	assert.Error(t, err)
+
+	// tokens that are not signed by the provider are rejected
+	issuer.ForgeTokens(true)
+	authURL, err = p.AuthCodeURL(ctx, redirectURL, "state", "nonce", "verifier")
+	require.NoError(t, err)
+	code, _ = login(t, authURL)
+	_, err = p.Exchange(ctx, redirectURL, code, "verifier", "nonce")
+	assert.Error(t, err, "forged id_token should be rejected")
+	issuer.ForgeTokens(false)
+
+	wrongIssuer := oidc.NewProvider(oidc.ProviderOpts{Issuer: issuer.URL + "/realms/other", ClientID: "koito", ClientSecret: "secret"})
+	_, err = wrongIssuer.AuthCodeURL(ctx, redirectURL, "state", "nonce", "verifier")
+	assert.Error(t, err)
+}
+
+func TestExchangeAfterKeyRotation(t *testing.T) {
+	issuer := oidctest.NewIssuer("koito", "secret")
+	defer issuer.Close()
+	ctx := context.Background()
+
+	p := oidc.NewProvider(oidc.ProviderOpts{Issuer: issuer.URL, ClientID: "koito", ClientSecret: "secret"})
+	for range 2 {
+		authURL, err := p.AuthCodeURL(ctx, redirectURL, "state", "nonce", "verifier")
+		require.NoError(t, err)
+		code, _ := login(t, authURL)
+		_, err = p.Exchange(ctx, redirectURL, code, "verifier", "nonce")
+		require.NoError(t, err)
+		// the provider's new key is fetched when it is first used
+		issuer.RotateKey()
+	}
+}
+
+func TestClaimsStrings(t *testing.T) {
+	claims := oidc.Claims{
+		"single": "admins",
+		"list":   []any{"a", 1, "b"},
+		"number": 5.0,
+	}
+	assert.Equal(t, []string{"admins"}, claims.Strings("single"))
+	assert.Equal(t, []string{"a", "b"}, claims.Strings("list"))
+	assert.Nil(t, claims.Strings("number"))
+	assert.Nil(t, claims.Strings("missing"))
+	assert.Empty(t, claims.String("number"))
+}
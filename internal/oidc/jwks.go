@@ -0,0 +1,179 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	_ "crypto/sha512" // for the 384 and 512 bit algorithms
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+)
+
+// jsonWebKey is a public key from the provider's JWKS document. Only RSA and EC keys are used.
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	// RSA
+	N string `json:"n"`
+	E string `json:"e"`
+	// EC
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+type jsonWebKeySet struct {
+	Keys []jsonWebKey `json:"keys"`
+}
+
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+// signingAlgorithm describes a supported JWS algorithm. Unsigned tokens and HMAC algorithms,
+// which would need the client secret to be shared with the token's readers, are not supported.
+type signingAlgorithm struct {
+	kty  string
+	hash crypto.Hash
+	// size of each of r and s in EC signatures
+	keySize int
+}
+
+var signingAlgorithms = map[string]signingAlgorithm{
+	"RS256": {kty: "RSA", hash: crypto.SHA256},
+	"RS384": {kty: "RSA", hash: crypto.SHA384},
+	"RS512": {kty: "RSA", hash: crypto.SHA512},
+	"ES256": {kty: "EC", hash: crypto.SHA256, keySize: 32},
+	"ES384": {kty: "EC", hash: crypto.SHA384, keySize: 48},
+	"ES512": {kty: "EC", hash: crypto.SHA512, keySize: 66},
+}
+
+// verifySignature checks the signature of a JWT against the provider's published keys. When no
+// key matches, the keys are fetched again once, in case the provider has rotated them.
+func (p *Provider) verifySignature(ctx context.Context, d *discoveryDocument, parts []string) error {
+	rawHeader, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[0], "="))
+	if err != nil {
+		return fmt.Errorf("verifySignature: failed to decode header: %w", err)
+	}
+	var header jwtHeader
+	if err := json.Unmarshal(rawHeader, &header); err != nil {
+		return fmt.Errorf("verifySignature: failed to unmarshal header: %w", err)
+	}
+	alg, ok := signingAlgorithms[header.Alg]
+	if !ok {
+		return fmt.Errorf("verifySignature: unsupported signing algorithm '%s'", header.Alg)
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[2], "="))
+	if err != nil {
+		return fmt.Errorf("verifySignature: failed to decode signature: %w", err)
+	}
+	h := alg.hash.New()
+	h.Write([]byte(parts[0] + "." + parts[1]))
+	digest := h.Sum(nil)
+
+	for _, refresh := range []bool{false, true} {
+		keys, err := p.getKeys(ctx, d, refresh)
+		if err != nil {
+			return fmt.Errorf("verifySignature: %w", err)
+		}
+		for _, key := range keys {
+			if key.Kty != alg.kty || (key.Use != "" && key.Use != "sig") ||
+				(key.Alg != "" && key.Alg != header.Alg) || (header.Kid != "" && key.Kid != header.Kid) {
+				continue
+			}
+			if verifyWithKey(key, alg, digest, sig) {
+				return nil
+			}
+		}
+	}
+	return errors.New("verifySignature: id_token signature does not match any of the provider's keys")
+}
+
+func verifyWithKey(key jsonWebKey, alg signingAlgorithm, digest, sig []byte) bool {
+	switch key.Kty {
+	case "RSA":
+		pub, err := key.rsaPublicKey()
+		if err != nil {
+			return false
+		}
+		return rsa.VerifyPKCS1v15(pub, alg.hash, digest, sig) == nil
+	case "EC":
+		pub, err := key.ecdsaPublicKey()
+		if err != nil || len(sig) != 2*alg.keySize || (pub.Curve.Params().BitSize+7)/8 != alg.keySize {
+			return false
+		}
+		r := new(big.Int).SetBytes(sig[:alg.keySize])
+		s := new(big.Int).SetBytes(sig[alg.keySize:])
+		return ecdsa.Verify(pub, digest, r, s)
+	}
+	return false
+}
+
+func (k jsonWebKey) rsaPublicKey() (*rsa.PublicKey, error) {
+	n, err1 := base64.RawURLEncoding.DecodeString(k.N)
+	e, err2 := base64.RawURLEncoding.DecodeString(k.E)
+	if err := errors.Join(err1, err2); err != nil {
+		return nil, err
+	}
+	exp := new(big.Int).SetBytes(e)
+	if !exp.IsInt64() || exp.Int64() < 3 || exp.Int64() > 1<<31-1 {
+		return nil, errors.New("invalid RSA exponent")
+	}
+	return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exp.Int64())}, nil
+}
+
+func (k jsonWebKey) ecdsaPublicKey() (*ecdsa.PublicKey, error) {
+	var curve elliptic.Curve
+	switch k.Crv {
+	case "P-256":
+		curve = elliptic.P256()
+	case "P-384":
+		curve = elliptic.P384()
+	case "P-521":
+		curve = elliptic.P521()
+	default:
+		return nil, fmt.Errorf("unsupported curve '%s'", k.Crv)
+	}
+	x, err1 := base64.RawURLEncoding.DecodeString(k.X)
+	y, err2 := base64.RawURLEncoding.DecodeString(k.Y)
+	if err := errors.Join(err1, err2); err != nil {
+		return nil, err
+	}
+	return &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+}
+
+// getKeys returns the provider's signing keys, which are cached until refresh is set.
+func (p *Provider) getKeys(ctx context.Context, d *discoveryDocument, refresh bool) ([]jsonWebKey, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.keys != nil && !refresh {
+		return p.keys, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, d.JWKSURI, nil)
+	if err != nil {
+		return nil, fmt.Errorf("getKeys: failed to build request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+
+	var set jsonWebKeySet
+	status, err := p.doJSON(req, &set)
+	if err != nil {
+		return nil, fmt.Errorf("getKeys: %w", err)
+	}
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("getKeys: jwks endpoint returned %d", status)
+	}
+	p.keys = set.Keys
+	return p.keys, nil
+}
@@ -0,0 +1,231 @@
+// package oidctest provides a mock OpenID Connect provider for tests
+package oidctest
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Issuer is an OpenID Connect provider that signs in whoever was last passed to SetUser,
+// without showing a login page. ID tokens are signed with RS256.
+type Issuer struct {
+	*httptest.Server
+	ClientID     string
+	ClientSecret string
+
+	mu      sync.Mutex
+	claims  map[string]any
+	codes   map[string]pendingCode
+	tokens  map[string]map[string]any
+	counter int
+	key     *rsa.PrivateKey
+	keyID   int
+	// signs tokens with a key that is not published
+	forge bool
+}
+
+type pendingCode struct {
+	claims      map[string]any
+	redirectURI string
+	challenge   string
+}
+
+func NewIssuer(clientID, clientSecret string) *Issuer {
+	i := &Issuer{
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		claims:       map[string]any{"sub": "mock-subject"},
+		codes:        make(map[string]pendingCode),
+		tokens:       make(map[string]map[string]any),
+	}
+	i.RotateKey()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", i.discovery)
+	mux.HandleFunc("/authorize", i.authorize)
+	mux.HandleFunc("/token", i.token)
+	mux.HandleFunc("/userinfo", i.userinfo)
+	mux.HandleFunc("/jwks", i.jwks)
+	i.Server = httptest.NewServer(mux)
+	return i
+}
+
+// SetUser sets the claims of the user who will be signed in next. The claims must include "sub".
+func (i *Issuer) SetUser(claims map[string]any) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.claims = claims
+}
+
+// RotateKey replaces the key ID tokens are signed with.
+func (i *Issuer) RotateKey() {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		panic(err)
+	}
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.key = key
+	i.keyID++
+}
+
+// ForgeTokens makes the issuer sign ID tokens with a key it does not publish, as someone
+// impersonating it would.
+func (i *Issuer) ForgeTokens(forge bool) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.forge = forge
+}
+
+func (i *Issuer) discovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{
+		"issuer":                 i.URL,
+		"authorization_endpoint": i.URL + "/authorize",
+		"token_endpoint":         i.URL + "/token",
+		"userinfo_endpoint":      i.URL + "/userinfo",
+		"jwks_uri":               i.URL + "/jwks",
+	})
+}
+
+func (i *Issuer) jwks(w http.ResponseWriter, r *http.Request) {
+	i.mu.Lock()
+	pub := i.key.PublicKey
+	kid := fmt.Sprintf("key-%d", i.keyID)
+	i.mu.Unlock()
+	writeJSON(w, http.StatusOK, map[string]any{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"use": "sig",
+			"alg": "RS256",
+			"kid": kid,
+			"n":   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+		}},
+	})
+}
+
+// sign returns the claims as a JWT signed with RS256.
+func (i *Issuer) sign(claims map[string]any) string {
+	i.mu.Lock()
+	key, kid := i.key, fmt.Sprintf("key-%d", i.keyID)
+	if i.forge {
+		key, _ = rsa.GenerateKey(rand.Reader, 2048)
+	}
+	i.mu.Unlock()
+
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT", "kid": kid})
+	payload, _ := json.Marshal(claims)
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signed))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		panic(err)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func (i *Issuer) authorize(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	redirect, err := url.Parse(q.Get("redirect_uri"))
+	if err != nil || q.Get("client_id") != i.ClientID || q.Get("response_type") != "code" || q.Get("code_challenge_method") != "S256" {
+		http.Error(w, "invalid authorization request", http.StatusBadRequest)
+		return
+	}
+
+	i.mu.Lock()
+	i.counter++
+	code := fmt.Sprintf("code-%d", i.counter)
+	claims := make(map[string]any, len(i.claims)+5)
+	for k, v := range i.claims {
+		claims[k] = v
+	}
+	claims["iss"] = i.URL
+	claims["aud"] = i.ClientID
+	claims["exp"] = time.Now().Add(5 * time.Minute).Unix()
+	claims["iat"] = time.Now().Unix()
+	claims["nonce"] = q.Get("nonce")
+	i.codes[code] = pendingCode{claims: claims, redirectURI: q.Get("redirect_uri"), challenge: q.Get("code_challenge")}
+	i.mu.Unlock()
+
+	rq := redirect.Query()
+	rq.Set("code", code)
+	rq.Set("state", q.Get("state"))
+	redirect.RawQuery = rq.Encode()
+	http.Redirect(w, r, redirect.String(), http.StatusFound)
+}
+
+func (i *Issuer) token(w http.ResponseWriter, r *http.Request) {
+	id, secret, ok := r.BasicAuth()
+	if !ok || id != i.ClientID || subtle.ConstantTimeCompare([]byte(secret), []byte(i.ClientSecret)) != 1 {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+		return
+	}
+	if err := r.ParseForm(); err != nil || r.PostForm.Get("grant_type") != "authorization_code" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+
+	i.mu.Lock()
+	pending, found := i.codes[r.PostForm.Get("code")]
+	delete(i.codes, r.PostForm.Get("code"))
+	i.mu.Unlock()
+
+	challenge := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if !found ||
+		pending.redirectURI != r.PostForm.Get("redirect_uri") ||
+		base64.RawURLEncoding.EncodeToString(challenge[:]) != pending.challenge {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	idToken := i.sign(pending.claims)
+	accessToken := "access-" + strings.TrimPrefix(r.PostForm.Get("code"), "code-")
+
+	i.mu.Lock()
+	i.tokens[accessToken] = pending.claims
+	i.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"access_token": accessToken,
+		"token_type":   "Bearer",
+		"expires_in":   300,
+		"id_token":     idToken,
+	})
+}
+
+func (i *Issuer) userinfo(w http.ResponseWriter, r *http.Request) {
+	i.mu.Lock()
+	claims, ok := i.tokens[strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")]
+	i.mu.Unlock()
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_token"})
+		return
+	}
+	info := make(map[string]any)
+	for k, v := range claims {
+		switch k {
+		case "iss", "aud", "exp", "iat", "nonce":
+		default:
+			info[k] = v
+		}
+	}
+	writeJSON(w, http.StatusOK, info)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,90 @@
+package relay
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/gabehf/koito/internal/cfg"
+)
+
+var errPrivateAddress = errors.New("relay targets cannot point at private, loopback or link-local addresses")
+
+// carrier-grade NAT, which netip does not consider private
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+// targetClient is used for everything sent to relay targets set up by users. Unless
+// KOITO_RELAY_ALLOW_PRIVATE_TARGETS is set, it refuses to connect to addresses that are not
+// publicly routable, so that a target cannot be used to reach services on Koito's own network.
+// The check runs on the resolved address of every connection, including redirects.
+var targetClient = &http.Client{
+	Timeout: 30 * time.Second,
+	Transport: &http.Transport{
+		DialContext: (&net.Dialer{
+			Timeout: 10 * time.Second,
+			Control: checkDialAddress,
+		}).DialContext,
+		TLSHandshakeTimeout: 10 * time.Second,
+		MaxIdleConns:        10,
+		IdleConnTimeout:     90 * time.Second,
+	},
+}
+
+func checkDialAddress(network, address string, _ syscall.RawConn) error {
+	if cfg.RelayAllowPrivateTargets() {
+		return nil
+	}
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return fmt.Errorf("invalid address '%s': %w", address, err)
+	}
+	if isPrivateAddr(addrPort.Addr()) {
+		return errPrivateAddress
+	}
+	return nil
+}
+
+func isPrivateAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	return !addr.IsValid() ||
+		addr.IsLoopback() ||
+		addr.IsPrivate() ||
+		addr.IsLinkLocalUnicast() ||
+		addr.IsLinkLocalMulticast() ||
+		addr.IsInterfaceLocalMulticast() ||
+		addr.IsMulticast() ||
+		addr.IsUnspecified() ||
+		sharedAddressSpace.Contains(addr)
+}
+
+// CheckTargetURL reports whether a relay target URL can be used: it must be an http or https
+// URL, and may not point at a private, loopback or link-local address unless
+// KOITO_RELAY_ALLOW_PRIVATE_TARGETS is set. Host names are checked again when connecting,
+// once they are resolved.
+func CheckTargetURL(raw string) error {
+	return checkTargetURL(raw, cfg.RelayAllowPrivateTargets())
+}
+
+func checkTargetURL(raw string, allowPrivate bool) error {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return errors.New("url must be an http or https URL")
+	}
+	if allowPrivate {
+		return nil
+	}
+	host := strings.ToLower(u.Hostname())
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return errPrivateAddress
+	}
+	if addr, err := netip.ParseAddr(host); err == nil && isPrivateAddr(addr) {
+		return errPrivateAddress
+	}
+	return nil
+}
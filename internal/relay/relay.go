@@ -0,0 +1,349 @@
+// Package relay delivers submitted listens to other scrobbling services: the ListenBrainz server
+// configured with KOITO_LBZ_RELAY_URL, and any relay targets set up by users. Submissions are
+// written to the relay_outbox table before delivery is attempted, so they survive restarts and
+// outages of the relay target. Playing now submissions are the exception: they are sent once,
+// as soon as they are received.
+package relay
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gabehf/koito/internal/cfg"
+	"github.com/gabehf/koito/internal/db"
+	"github.com/gabehf/koito/internal/logger"
+	"github.com/gabehf/koito/internal/models"
+)
+
+const (
+	// how often the outbox is checked for due entries when nothing wakes the worker
+	pollInterval = 30 * time.Second
+	// how many due entries are delivered per pass
+	batchSize = 50
+	// the longest an error message from the relay target is kept
+	maxErrorLength = 512
+)
+
+// Backoff is the delay before each retry of a failed delivery. An entry is marked
+// dead once every delay has been used up.
+var Backoff = []time.Duration{
+	30 * time.Second,
+	1 * time.Minute,
+	5 * time.Minute,
+	15 * time.Minute,
+	30 * time.Minute,
+	1 * time.Hour,
+	3 * time.Hour,
+	6 * time.Hour,
+	12 * time.Hour,
+	24 * time.Hour,
+}
+
+// client is used for the ListenBrainz relay configured by the admin. Relay targets set up by
+// users go through targetClient instead.
+var client = &http.Client{
+	Timeout: 30 * time.Second,
+}
+
+// wake is signalled when new entries are enqueued so they are delivered without
+// waiting for the next poll.
+var wake = make(chan struct{}, 1)
+
+// EnqueueDefault stores a raw submit-listens request body in the outbox for the globally configured
+// ListenBrainz relay. Does nothing when the relay is not enabled.
+func EnqueueDefault(ctx context.Context, store db.DB, userID int32, payload []byte) error {
+	if !cfg.LbzRelayEnabled() {
+		return nil
+	}
+
+	var sub submission
+	if err := json.Unmarshal(payload, &sub); err != nil {
+		return fmt.Errorf("EnqueueDefault: failed to decode payload: %w", err)
+	}
+	if sub.ListenType == "playing_now" {
+		sendPlayingNow(ctx, "the ListenBrainz relay", func(ctx context.Context) (bool, error) {
+			return deliverListenBrainz(ctx, client, cfg.LbzRelayUrl(), cfg.LbzRelayToken(), payload)
+		})
+		return nil
+	}
+
+	body, err := stampListens(sub, time.Now())
+	if err != nil {
+		return fmt.Errorf("EnqueueDefault: %w", err)
+	}
+	_, err = store.SaveRelayEntry(ctx, db.SaveRelayEntryOpts{
+		UserID:  userID,
+		Payload: body,
+	})
+	if err != nil {
+		return fmt.Errorf("EnqueueDefault: %w", err)
+	}
+	Wake()
+	return nil
+}
+
+// Enqueue stores a ListenBrainz submit-listens request body in the outbox once for each of the
+// user's enabled relay targets, leaving out listens that the target's filters do not allow.
+// Playing now submissions are sent right away instead.
+func Enqueue(ctx context.Context, store db.DB, userID int32, payload []byte) error {
+	targets, err := store.GetRelayTargetsByUserID(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("Enqueue: %w", err)
+	}
+	if len(targets) == 0 {
+		return nil
+	}
+
+	var sub submission
+	if err := json.Unmarshal(payload, &sub); err != nil {
+		return fmt.Errorf("Enqueue: failed to decode payload: %w", err)
+	}
+	now := time.Now()
+
+	queued := 0
+	for _, target := range targets {
+		if !target.Enabled {
+			continue
+		}
+		var allowed []json.RawMessage
+		for _, raw := range sub.Payload {
+			var li listen
+			if err := json.Unmarshal(raw, &li); err != nil {
+				return fmt.Errorf("Enqueue: failed to decode listen: %w", err)
+			}
+			if allows(target, sub.ListenType, li.client()) {
+				allowed = append(allowed, raw)
+			}
+		}
+
+		if sub.ListenType == "playing_now" {
+			if len(allowed) > 0 {
+				body, err := json.Marshal(submission{ListenType: sub.ListenType, Payload: allowed})
+				if err != nil {
+					return fmt.Errorf("Enqueue: %w", err)
+				}
+				sendPlayingNow(ctx, fmt.Sprintf("relay target %d", target.ID), func(ctx context.Context) (bool, error) {
+					return deliver(ctx, target, body)
+				})
+			}
+			continue
+		}
+
+		for _, chunk := range chunkListens(allowed, maxListensPerEntry(target.Kind)) {
+			body, err := stampListens(submission{ListenType: sub.ListenType, Payload: chunk}, now)
+			if err != nil {
+				return fmt.Errorf("Enqueue: %w", err)
+			}
+			_, err = store.SaveRelayEntry(ctx, db.SaveRelayEntryOpts{
+				UserID:   userID,
+				TargetID: target.ID,
+				Payload:  body,
+			})
+			if err != nil {
+				return fmt.Errorf("Enqueue: %w", err)
+			}
+			queued++
+		}
+	}
+
+	if queued > 0 {
+		Wake()
+	}
+	return nil
+}
+
+// sendPlayingNow delivers a playing now submission once, in the background. It is only worth
+// anything while the track is playing, so it is never stored in the outbox or retried.
+func sendPlayingNow(ctx context.Context, name string, send func(context.Context) (bool, error)) {
+	l := logger.FromContext(ctx)
+	ctx = context.WithoutCancel(ctx)
+	go func() {
+		if _, err := send(ctx); err != nil {
+			l.Warn().Err(err).Msgf("Relay: Failed to relay playing now to %s", name)
+		}
+	}()
+}
+
+// stampListens encodes the submission, setting the listen time of listens without one to now.
+// Otherwise the listen would be recorded at the time of delivery, which can be much later
+// when the relay target is unavailable.
+func stampListens(sub submission, now time.Time) ([]byte, error) {
+	stamped := make([]json.RawMessage, len(sub.Payload))
+	for i, raw := range sub.Payload {
+		var li listen
+		if err := json.Unmarshal(raw, &li); err != nil {
+			return nil, fmt.Errorf("failed to decode listen: %w", err)
+		}
+		if li.ListenedAt != 0 {
+			stamped[i] = raw
+			continue
+		}
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(raw, &fields); err != nil {
+			return nil, fmt.Errorf("failed to decode listen: %w", err)
+		}
+		fields["listened_at"] = json.RawMessage(strconv.FormatInt(now.Unix(), 10))
+		b, err := json.Marshal(fields)
+		if err != nil {
+			return nil, err
+		}
+		stamped[i] = b
+	}
+	return json.Marshal(submission{ListenType: sub.ListenType, Payload: stamped})
+}
+
+// Wake makes the worker check the outbox immediately.
+func Wake() {
+	select {
+	case wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run delivers outbox entries until ctx is cancelled. Entries left pending from a previous
+// run are replayed as soon as the worker starts.
+func Run(ctx context.Context, store db.DB) {
+	l := logger.FromContext(ctx)
+	l.Info().Msg("Relay: Starting relay worker")
+
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		processDue(ctx, store)
+
+		select {
+		case <-ctx.Done():
+			l.Info().Msg("Relay: Stopping relay worker")
+			return
+		case <-ticker.C:
+		case <-wake:
+		}
+	}
+}
+
+// processDue delivers due entries in batches until none are left.
+func processDue(ctx context.Context, store db.DB) {
+	l := logger.FromContext(ctx)
+
+	for {
+		entries, err := store.GetDueRelayEntries(ctx, batchSize)
+		if err != nil {
+			if ctx.Err() == nil {
+				l.Err(err).Msg("Relay: Failed to get due relay entries")
+			}
+			return
+		}
+		for _, entry := range entries {
+			if ctx.Err() != nil {
+				return
+			}
+			process(ctx, store, entry)
+		}
+		if len(entries) < batchSize {
+			return
+		}
+	}
+}
+
+func process(ctx context.Context, store db.DB, entry *models.RelayEntry) {
+	l := logger.FromContext(ctx)
+
+	var retryable bool
+	var err error
+	if entry.TargetID == nil {
+		retryable, err = deliverListenBrainz(ctx, client, cfg.LbzRelayUrl(), cfg.LbzRelayToken(), entry.Payload)
+	} else {
+		var target *models.RelayTarget
+		target, err = store.GetRelayTarget(ctx, *entry.TargetID)
+		if err != nil {
+			l.Err(err).Msgf("Relay: Failed to get relay target for entry %d", entry.ID)
+			retryable = true
+		} else if target == nil {
+			// targets cascade to their entries, so this only happens if the target was deleted mid-pass
+			l.Debug().Msgf("Relay: Relay target for entry %d no longer exists", entry.ID)
+			return
+		} else {
+			retryable, err = deliver(ctx, target, entry.Payload)
+		}
+	}
+	if err == nil {
+		l.Info().Msgf("Relay: Successfully relayed entry %d", entry.ID)
+		if err := store.DeleteRelayEntry(ctx, entry.ID); err != nil {
+			l.Err(err).Msgf("Relay: Failed to delete delivered entry %d", entry.ID)
+		}
+		return
+	}
+	if ctx.Err() != nil {
+		// shutting down; leave the entry as is so it is replayed on the next start
+		return
+	}
+
+	opts := db.UpdateRelayEntryAttemptOpts{
+		ID:        entry.ID,
+		Status:    models.RelayStatusPending,
+		Attempts:  entry.Attempts + 1,
+		LastError: truncate(err.Error(), maxErrorLength),
+	}
+	if !retryable || int(entry.Attempts) >= len(Backoff) {
+		opts.Status = models.RelayStatusDead
+		opts.NextAttemptAt = time.Now()
+		l.Warn().Err(err).Msgf("Relay: Giving up on entry %d after %d attempts", entry.ID, opts.Attempts)
+	} else {
+		opts.NextAttemptAt = time.Now().Add(Backoff[entry.Attempts])
+		l.Warn().Err(err).Msgf("Relay: Failed to relay entry %d, retrying in %s", entry.ID, Backoff[entry.Attempts])
+	}
+
+	if err := store.UpdateRelayEntryAttempt(ctx, opts); err != nil {
+		l.Err(err).Msgf("Relay: Failed to update entry %d", entry.ID)
+	}
+}
+
+// deliverListenBrainz sends a submit-listens request body to a ListenBrainz-compatible API. The
+// returned bool reports whether a failed delivery is worth retrying.
+func deliverListenBrainz(ctx context.Context, c *http.Client, baseUrl, token string, payload []byte) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(baseUrl, "/")+"/submit-listens", bytes.NewReader(payload))
+	if err != nil {
+		return false, fmt.Errorf("failed to build request: %w", err)
+	}
+	req.Header.Add("Authorization", "Token "+token)
+	req.Header.Add("Content-Type", "application/json")
+
+	return send(c, req)
+}
+
+// send performs a request and turns non-2XX responses into errors.
+func send(c *http.Client, req *http.Request) (bool, error) {
+	resp, err := c.Do(req)
+	if err != nil {
+		return true, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorLength))
+	err = fmt.Errorf("%s: %s", resp.Status, bytes.TrimSpace(body))
+	return isRetryableStatus(resp.StatusCode), err
+}
+
+// isRetryableStatus reports whether a non-2XX response may succeed if sent again later.
+func isRetryableStatus(status int) bool {
+	return status >= 500 || status == http.StatusRequestTimeout || status == http.StatusTooManyRequests
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n]
+}
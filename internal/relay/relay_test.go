@@ -0,0 +1,128 @@
+package relay
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/gabehf/koito/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsRetryableStatus(t *testing.T) {
+	assert.True(t, isRetryableStatus(http.StatusInternalServerError))
+	assert.True(t, isRetryableStatus(http.StatusBadGateway))
+	assert.True(t, isRetryableStatus(http.StatusTooManyRequests))
+	assert.True(t, isRetryableStatus(http.StatusRequestTimeout))
+	assert.False(t, isRetryableStatus(http.StatusBadRequest))
+	assert.False(t, isRetryableStatus(http.StatusUnauthorized))
+	assert.False(t, isRetryableStatus(http.StatusNotFound))
+}
+
+func TestTruncate(t *testing.T) {
+	assert.Equal(t, "abc", truncate("abc", 5))
+	assert.Equal(t, "ab", truncate("abc", 2))
+}
+
+func TestAllows(t *testing.T) {
+	target := &models.RelayTarget{
+		Kind:            models.RelayTargetListenBrainz,
+		RelayImports:    false,
+		RelayPlayingNow: true,
+		ExcludeClients:  []string{"Navidrome"},
+	}
+	assert.True(t, allows(target, "single", "Jellyfin"))
+	assert.True(t, allows(target, "single", ""))
+	assert.True(t, allows(target, "playing_now", "Jellyfin"))
+	assert.False(t, allows(target, "import", "Jellyfin"))
+	assert.False(t, allows(target, "single", "navidrome"))
+
+	target.IncludeClients = []string{"Jellyfin"}
+	assert.True(t, allows(target, "single", "jellyfin"))
+	assert.False(t, allows(target, "single", "Plex"))
+	assert.False(t, allows(target, "single", ""))
+
+	// Maloja has no now playing endpoint
+	target.Kind = models.RelayTargetMaloja
+	assert.False(t, allows(target, "playing_now", "Jellyfin"))
+}
+
+func TestChunkListens(t *testing.T) {
+	listens := make([]json.RawMessage, 5)
+	assert.Len(t, chunkListens(listens, 0), 1)
+	assert.Len(t, chunkListens(listens, 5), 1)
+	chunks := chunkListens(listens, 2)
+	require.Len(t, chunks, 3)
+	assert.Len(t, chunks[2], 1)
+	assert.Nil(t, chunkListens(nil, 2))
+}
+
+func TestLastFmSignature(t *testing.T) {
+	params := url.Values{}
+	params.Set("method", "track.scrobble")
+	params.Set("api_key", "key")
+	params.Set("format", "json")
+	// md5("api_keykeymethodtrack.scrobblesecret")
+	assert.Equal(t, "d7a2d80e182cf1fea315ddc2d0bbfe44", lastFmSignature(params, "secret"))
+}
+
+func TestDecodeSubmission(t *testing.T) {
+	sub, listens, err := decodeSubmission([]byte(`{
+		"listen_type": "single",
+		"payload": [{
+			"listened_at": 1700000000,
+			"track_metadata": {
+				"artist_name": "さユり",
+				"track_name": "花の塔",
+				"additional_info": {"media_player": "Jellyfin", "duration_ms": 240000}
+			}
+		}]
+	}`))
+	require.NoError(t, err)
+	assert.Equal(t, "single", sub.ListenType)
+	require.Len(t, listens, 1)
+	assert.Equal(t, "Jellyfin", listens[0].client())
+	assert.EqualValues(t, 240, listens[0].duration())
+	assert.Equal(t, []string{"さユり"}, listens[0].artists())
+}
+
+func TestStampListens(t *testing.T) {
+	now := time.Unix(1700000500, 0)
+	body, err := stampListens(submission{
+		ListenType: "single",
+		Payload: []json.RawMessage{
+			json.RawMessage(`{"listened_at":1700000000,"track_metadata":{"track_name":"花の塔"}}`),
+			json.RawMessage(`{"track_metadata":{"track_name":"Where Our Blue Is"}}`),
+		},
+	}, now)
+	require.NoError(t, err)
+
+	sub, listens, err := decodeSubmission(body)
+	require.NoError(t, err)
+	assert.Equal(t, "single", sub.ListenType)
+	require.Len(t, listens, 2)
+	assert.EqualValues(t, 1700000000, listens[0].ListenedAt)
+	assert.EqualValues(t, now.Unix(), listens[1].ListenedAt)
+	assert.Equal(t, "Where Our Blue Is", listens[1].TrackMeta.TrackName)
+}
+
+func TestCheckTargetURL(t *testing.T) {
+	assert.NoError(t, checkTargetURL("https://api.listenbrainz.org/1", false))
+	assert.NoError(t, checkTargetURL("http://maloja.example.com/apis/mlj_1", false))
+	assert.NoError(t, checkTargetURL("https://8.8.8.8/", false))
+	assert.Error(t, checkTargetURL("ftp://maloja.example.com", false))
+	assert.Error(t, checkTargetURL("not a url", false))
+	assert.Error(t, checkTargetURL("http://localhost:42010", false))
+	assert.Error(t, checkTargetURL("http://127.0.0.1:42010", false))
+	assert.Error(t, checkTargetURL("http://192.168.1.10/", false))
+	assert.Error(t, checkTargetURL("http://169.254.169.254/latest/meta-data", false))
+	assert.Error(t, checkTargetURL("http://[::1]:8080/", false))
+	assert.Error(t, checkTargetURL("http://[::ffff:10.0.0.1]/", false))
+	assert.Error(t, checkTargetURL("http://100.64.0.1/", false))
+
+	assert.NoError(t, checkTargetURL("http://192.168.1.10/", true))
+	assert.Error(t, checkTargetURL("ftp://192.168.1.10/", true))
+}
@@ -0,0 +1,355 @@
+package relay
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gabehf/koito/internal/models"
+)
+
+const (
+	DefaultListenBrainzUrl = "https://api.listenbrainz.org/1"
+	DefaultLastFmUrl       = "https://ws.audioscrobbler.com/2.0/"
+
+	// Last.fm accepts at most 50 scrobbles per request
+	lastFmMaxScrobbles = 50
+)
+
+// submission mirrors the parts of a ListenBrainz submit-listens request that relaying needs.
+// Listens are kept as raw JSON so that entries sent to ListenBrainz targets are not altered.
+type submission struct {
+	ListenType string            `json:"listen_type,omitempty"`
+	Payload    []json.RawMessage `json:"payload"`
+}
+
+type listen struct {
+	ListenedAt int64 `json:"listened_at"`
+	TrackMeta  struct {
+		ArtistName     string `json:"artist_name"`
+		TrackName      string `json:"track_name"`
+		ReleaseName    string `json:"release_name"`
+		AdditionalInfo struct {
+			MediaPlayer      string   `json:"media_player"`
+			SubmissionClient string   `json:"submission_client"`
+			ArtistNames      []string `json:"artist_names"`
+			RecordingMBID    string   `json:"recording_mbid"`
+			Duration         int32    `json:"duration"`
+			DurationMs       int32    `json:"duration_ms"`
+			AlbumArtist      string   `json:"albumartist"`
+		} `json:"additional_info"`
+	} `json:"track_metadata"`
+}
+
+func (li listen) client() string {
+	if li.TrackMeta.AdditionalInfo.MediaPlayer != "" {
+		return li.TrackMeta.AdditionalInfo.MediaPlayer
+	}
+	return li.TrackMeta.AdditionalInfo.SubmissionClient
+}
+
+func (li listen) duration() int32 {
+	if li.TrackMeta.AdditionalInfo.Duration != 0 {
+		return li.TrackMeta.AdditionalInfo.Duration
+	}
+	return li.TrackMeta.AdditionalInfo.DurationMs / 1000
+}
+
+func (li listen) artists() []string {
+	if len(li.TrackMeta.AdditionalInfo.ArtistNames) > 0 {
+		return li.TrackMeta.AdditionalInfo.ArtistNames
+	}
+	return []string{li.TrackMeta.ArtistName}
+}
+
+func (li listen) time() time.Time {
+	if li.ListenedAt == 0 {
+		return time.Now()
+	}
+	return time.Unix(li.ListenedAt, 0)
+}
+
+// allows reports whether a listen of the given type and client passes the target's filters.
+// Client names are compared case-insensitively.
+func allows(target *models.RelayTarget, listenType, client string) bool {
+	switch listenType {
+	case "import":
+		if !target.RelayImports {
+			return false
+		}
+	case "playing_now":
+		// Maloja has no way to submit now playing information
+		if !target.RelayPlayingNow || target.Kind == models.RelayTargetMaloja {
+			return false
+		}
+	}
+	for _, c := range target.ExcludeClients {
+		if strings.EqualFold(c, client) {
+			return false
+		}
+	}
+	if len(target.IncludeClients) == 0 {
+		return true
+	}
+	for _, c := range target.IncludeClients {
+		if strings.EqualFold(c, client) {
+			return true
+		}
+	}
+	return false
+}
+
+// maxListensPerEntry is the number of listens that fit in a single request to the target,
+// so that every outbox entry is delivered (and retried) as one request. 0 means no limit.
+func maxListensPerEntry(kind models.RelayTargetKind) int {
+	switch kind {
+	case models.RelayTargetLastFm:
+		return lastFmMaxScrobbles
+	case models.RelayTargetMaloja:
+		return 1
+	}
+	return 0
+}
+
+func chunkListens(listens []json.RawMessage, size int) [][]json.RawMessage {
+	if len(listens) == 0 {
+		return nil
+	}
+	if size <= 0 {
+		return [][]json.RawMessage{listens}
+	}
+	var chunks [][]json.RawMessage
+	for len(listens) > size {
+		chunks = append(chunks, listens[:size])
+		listens = listens[size:]
+	}
+	return append(chunks, listens)
+}
+
+// deliver sends an outbox entry to a user's relay target. The returned bool reports whether a
+// failed delivery is worth retrying.
+func deliver(ctx context.Context, target *models.RelayTarget, payload []byte) (bool, error) {
+	if target.URL != "" {
+		if err := CheckTargetURL(target.URL); err != nil {
+			return false, err
+		}
+	}
+	switch target.Kind {
+	case models.RelayTargetListenBrainz:
+		return deliverListenBrainz(ctx, targetClient, urlOrDefault(target.URL, DefaultListenBrainzUrl), target.Token, payload)
+	case models.RelayTargetLastFm:
+		return deliverLastFm(ctx, target, payload)
+	case models.RelayTargetMaloja:
+		return deliverMaloja(ctx, target, payload)
+	}
+	return false, fmt.Errorf("unknown relay target kind '%s'", target.Kind)
+}
+
+func deliverMaloja(ctx context.Context, target *models.RelayTarget, payload []byte) (bool, error) {
+	sub, listens, err := decodeSubmission(payload)
+	if err != nil {
+		return false, err
+	}
+	if sub.ListenType == "playing_now" {
+		return false, nil
+	}
+
+	for _, li := range listens {
+		body, err := json.Marshal(map[string]any{
+			"artists": li.artists(),
+			"title":   li.TrackMeta.TrackName,
+			"album":   li.TrackMeta.ReleaseName,
+			"length":  li.duration(),
+			"time":    li.time().Unix(),
+			"key":     target.Token,
+		})
+		if err != nil {
+			return false, err
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(target.URL, "/")+"/newscrobble", bytes.NewReader(body))
+		if err != nil {
+			return false, fmt.Errorf("failed to build request: %w", err)
+		}
+		req.Header.Add("Content-Type", "application/json")
+		if retryable, err := send(targetClient, req); err != nil {
+			return retryable, err
+		}
+	}
+	return false, nil
+}
+
+func deliverLastFm(ctx context.Context, target *models.RelayTarget, payload []byte) (bool, error) {
+	sub, listens, err := decodeSubmission(payload)
+	if err != nil {
+		return false, err
+	}
+	if len(listens) == 0 {
+		return false, nil
+	}
+
+	params := url.Values{}
+	params.Set("sk", target.Token)
+	if sub.ListenType == "playing_now" {
+		li := listens[0]
+		params.Set("method", "track.updateNowPlaying")
+		params.Set("artist", li.TrackMeta.ArtistName)
+		params.Set("track", li.TrackMeta.TrackName)
+		setIfNotEmpty(params, "album", li.TrackMeta.ReleaseName)
+		setIfNotEmpty(params, "albumArtist", li.TrackMeta.AdditionalInfo.AlbumArtist)
+		setIfNotEmpty(params, "mbid", li.TrackMeta.AdditionalInfo.RecordingMBID)
+		if d := li.duration(); d > 0 {
+			params.Set("duration", strconv.Itoa(int(d)))
+		}
+	} else {
+		params.Set("method", "track.scrobble")
+		for i, li := range listens {
+			key := func(name string) string { return fmt.Sprintf("%s[%d]", name, i) }
+			params.Set(key("artist"), li.TrackMeta.ArtistName)
+			params.Set(key("track"), li.TrackMeta.TrackName)
+			params.Set(key("timestamp"), strconv.FormatInt(li.time().Unix(), 10))
+			setIfNotEmpty(params, key("album"), li.TrackMeta.ReleaseName)
+			setIfNotEmpty(params, key("albumArtist"), li.TrackMeta.AdditionalInfo.AlbumArtist)
+			setIfNotEmpty(params, key("mbid"), li.TrackMeta.AdditionalInfo.RecordingMBID)
+			if d := li.duration(); d > 0 {
+				params.Set(key("duration"), strconv.Itoa(int(d)))
+			}
+		}
+	}
+
+	_, retryable, err := callLastFm(ctx, urlOrDefault(target.URL, DefaultLastFmUrl), target.ApiKey, target.ApiSecret, params)
+	return retryable, err
+}
+
+// GetLastFmSession exchanges a Last.fm username and password for a session key using
+// auth.getMobileSession, so that the password itself never has to be stored.
+func GetLastFmSession(ctx context.Context, apiUrl, apiKey, apiSecret, username, password string) (string, error) {
+	if apiUrl != "" {
+		if err := CheckTargetURL(apiUrl); err != nil {
+			return "", fmt.Errorf("GetLastFmSession: %w", err)
+		}
+	}
+	params := url.Values{}
+	params.Set("method", "auth.getMobileSession")
+	params.Set("username", username)
+	params.Set("password", password)
+
+	body, _, err := callLastFm(ctx, urlOrDefault(apiUrl, DefaultLastFmUrl), apiKey, apiSecret, params)
+	if err != nil {
+		return "", fmt.Errorf("GetLastFmSession: %w", err)
+	}
+	var resp struct {
+		Session struct {
+			Key string `json:"key"`
+		} `json:"session"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return "", fmt.Errorf("GetLastFmSession: failed to decode response: %w", err)
+	}
+	if resp.Session.Key == "" {
+		return "", fmt.Errorf("GetLastFmSession: response did not contain a session key")
+	}
+	return resp.Session.Key, nil
+}
+
+// callLastFm signs and sends a write request to the Last.fm API, returning the response body.
+func callLastFm(ctx context.Context, apiUrl, apiKey, apiSecret string, params url.Values) ([]byte, bool, error) {
+	params.Set("api_key", apiKey)
+	params.Set("api_sig", lastFmSignature(params, apiSecret))
+	params.Set("format", "json")
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, apiUrl, strings.NewReader(params.Encode()))
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to build request: %w", err)
+	}
+	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := targetClient.Do(req)
+	if err != nil {
+		return nil, true, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, true, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	var lfmErr struct {
+		Error   int    `json:"error"`
+		Message string `json:"message"`
+	}
+	if json.Unmarshal(body, &lfmErr) == nil && lfmErr.Error != 0 {
+		return nil, isRetryableLastFmError(lfmErr.Error), fmt.Errorf("Last.fm error %d: %s", lfmErr.Error, lfmErr.Message)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, isRetryableStatus(resp.StatusCode), fmt.Errorf("%s: %s", resp.Status, bytes.TrimSpace(body[:min(len(body), maxErrorLength)]))
+	}
+	return body, false, nil
+}
+
+// isRetryableLastFmError reports whether a Last.fm error code is temporary: operation failed,
+// service offline, temporarily unavailable or rate limited.
+func isRetryableLastFmError(code int) bool {
+	switch code {
+	case 8, 11, 16, 29:
+		return true
+	}
+	return false
+}
+
+// lastFmSignature computes the md5 api_sig over the sorted parameters followed by the secret.
+func lastFmSignature(params url.Values, secret string) string {
+	keys := make([]string, 0, len(params))
+	for k := range params {
+		if k == "format" || k == "callback" || k == "api_sig" {
+			continue
+		}
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var sb strings.Builder
+	for _, k := range keys {
+		sb.WriteString(k)
+		sb.WriteString(params.Get(k))
+	}
+	sb.WriteString(secret)
+	sum := md5.Sum([]byte(sb.String()))
+	return hex.EncodeToString(sum[:])
+}
+
+func decodeSubmission(payload []byte) (submission, []listen, error) {
+	var sub submission
+	if err := json.Unmarshal(payload, &sub); err != nil {
+		return sub, nil, fmt.Errorf("failed to decode payload: %w", err)
+	}
+	listens := make([]listen, len(sub.Payload))
+	for i, raw := range sub.Payload {
+		if err := json.Unmarshal(raw, &listens[i]); err != nil {
+			return sub, nil, fmt.Errorf("failed to decode listen: %w", err)
+		}
+	}
+	return sub, listens, nil
+}
+
+func setIfNotEmpty(params url.Values, key, value string) {
+	if value != "" {
+		params.Set(key, value)
+	}
+}
+
+func urlOrDefault(u, def string) string {
+	if u == "" {
+		return def
+	}
+	return u
+}
@@ -20,6 +20,7 @@ FROM (
   FROM listens l
   JOIN tracks t ON l.track_id = t.id
   JOIN artist_tracks at ON t.id = at.track_id
+  WHERE l.user_id = $3
   GROUP BY at.artist_id
   HAVING MIN(l.listened_at) BETWEEN $1 AND $2
 ) first_appearances
@@ -28,10 +29,11 @@ FROM (
 type CountNewArtistsParams struct {
 	ListenedAt   time.Time
 	ListenedAt_2 time.Time
+	UserID       int32
 }
 
 func (q *Queries) CountNewArtists(ctx context.Context, arg CountNewArtistsParams) (int64, error) {
-	row := q.db.QueryRow(ctx, countNewArtists, arg.ListenedAt, arg.ListenedAt_2)
+	row := q.db.QueryRow(ctx, countNewArtists, arg.ListenedAt, arg.ListenedAt_2, arg.UserID)
 	var total_count int64
 	err := row.Scan(&total_count)
 	return total_count, err
@@ -42,15 +44,24 @@ SELECT COUNT(DISTINCT at.artist_id) AS total_count
 FROM listens l
 JOIN artist_tracks at ON l.track_id = at.track_id
 WHERE l.listened_at BETWEEN $1 AND $2
+  AND l.user_id = $3
+  AND at.role <> $4
 `
 
 type CountTopArtistsParams struct {
 	ListenedAt   time.Time
 	ListenedAt_2 time.Time
+	UserID       int32
+	Role         string
 }
 
 func (q *Queries) CountTopArtists(ctx context.Context, arg CountTopArtistsParams) (int64, error) {
-	row := q.db.QueryRow(ctx, countTopArtists, arg.ListenedAt, arg.ListenedAt_2)
+	row := q.db.QueryRow(ctx, countTopArtists,
+		arg.ListenedAt,
+		arg.ListenedAt_2,
+		arg.UserID,
+		arg.Role,
+	)
 	var total_count int64
 	err := row.Scan(&total_count)
 	return total_count, err
@@ -149,19 +160,25 @@ FROM (
         FROM listens l
         JOIN tracks t ON l.track_id = t.id
         JOIN artist_tracks at ON t.id = at.track_id
+        WHERE l.user_id = $2
         GROUP BY at.artist_id
         ) x
     )
 WHERE artist_id = $1
 `
 
+type GetArtistAllTimeRankParams struct {
+	ArtistID int32
+	UserID   int32
+}
+
 type GetArtistAllTimeRankRow struct {
 	ArtistID int32
 	Rank     int64
 }
 
-func (q *Queries) GetArtistAllTimeRank(ctx context.Context, artistID int32) (GetArtistAllTimeRankRow, error) {
-	row := q.db.QueryRow(ctx, getArtistAllTimeRank, artistID)
+func (q *Queries) GetArtistAllTimeRank(ctx context.Context, arg GetArtistAllTimeRankParams) (GetArtistAllTimeRankRow, error) {
+	row := q.db.QueryRow(ctx, getArtistAllTimeRank, arg.ArtistID, arg.UserID)
 	var i GetArtistAllTimeRankRow
 	err := row.Scan(&i.ArtistID, &i.Rank)
 	return i, err
@@ -361,6 +378,8 @@ FROM (
   JOIN artist_tracks at ON at.track_id = t.id
   JOIN artists_with_name a ON a.id = at.artist_id
   WHERE l.listened_at BETWEEN $1 AND $2
+    AND l.user_id = $5
+    AND at.role <> $6
   GROUP BY a.id, a.name, a.musicbrainz_id, a.image
 ) x
 ORDER BY x.listen_count DESC, x.id
@@ -372,6 +391,8 @@ type GetTopArtistsPaginatedParams struct {
 	ListenedAt_2 time.Time
 	Limit        int32
 	Offset       int32
+	UserID       int32
+	Role         string
 }
 
 type GetTopArtistsPaginatedRow struct {
@@ -389,6 +410,8 @@ func (q *Queries) GetTopArtistsPaginated(ctx context.Context, arg GetTopArtistsP
 		arg.ListenedAt_2,
 		arg.Limit,
 		arg.Offset,
+		arg.UserID,
+		arg.Role,
 	)
 	if err != nil {
 		return nil, err
@@ -418,11 +441,12 @@ func (q *Queries) GetTopArtistsPaginated(ctx context.Context, arg GetTopArtistsP
 const getTrackArtists = `-- name: GetTrackArtists :many
 SELECT
   a.id, a.musicbrainz_id, a.image, a.image_source, a.name,
-  at.is_primary as is_primary
+  at.is_primary as is_primary,
+  at.role as role
 FROM artists_with_name a
 LEFT JOIN artist_tracks at ON a.id = at.artist_id
 WHERE at.track_id = $1
-GROUP BY a.id, a.musicbrainz_id, a.image, a.image_source, a.name, at.is_primary
+GROUP BY a.id, a.musicbrainz_id, a.image, a.image_source, a.name, at.is_primary, at.role
 `
 
 type GetTrackArtistsRow struct {
@@ -432,6 +456,7 @@ type GetTrackArtistsRow struct {
 	ImageSource   pgtype.Text
 	Name          string
 	IsPrimary     pgtype.Bool
+	Role          pgtype.Text
 }
 
 func (q *Queries) GetTrackArtists(ctx context.Context, trackID int32) ([]GetTrackArtistsRow, error) {
@@ -450,6 +475,7 @@ func (q *Queries) GetTrackArtists(ctx context.Context, trackID int32) ([]GetTrac
 			&i.ImageSource,
 			&i.Name,
 			&i.IsPrimary,
+			&i.Role,
 		); err != nil {
 			return nil, err
 		}
@@ -0,0 +1,136 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: audit_log.sql
+
+package repository
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const countAuditEntries = `-- name: CountAuditEntries :one
+SELECT COUNT(*) FROM audit_log
+WHERE ($1::text = '' OR action = $1::text)
+  AND ($2::text = '' OR target_type = $2::text)
+  AND ($3::int = 0 OR target_ids @> ARRAY[$3::int])
+  AND ($4::int = 0 OR user_id = $4::int)
+`
+
+type CountAuditEntriesParams struct {
+	Action     string
+	TargetType string
+	TargetID   int32
+	UserID     int32
+}
+
+func (q *Queries) CountAuditEntries(ctx context.Context, arg CountAuditEntriesParams) (int64, error) {
+	row := q.db.QueryRow(ctx, countAuditEntries,
+		arg.Action,
+		arg.TargetType,
+		arg.TargetID,
+		arg.UserID,
+	)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const getAuditEntriesPaginated = `-- name: GetAuditEntriesPaginated :many
+SELECT id, user_id, username, action, target_type, target_ids, before, after, created_at FROM audit_log
+WHERE ($1::text = '' OR action = $1::text)
+  AND ($2::text = '' OR target_type = $2::text)
+  AND ($3::int = 0 OR target_ids @> ARRAY[$3::int])
+  AND ($4::int = 0 OR user_id = $4::int)
+ORDER BY id DESC
+LIMIT $6::int OFFSET $5::int
+`
+
+type GetAuditEntriesPaginatedParams struct {
+	Action      string
+	TargetType  string
+	TargetID    int32
+	UserID      int32
+	OffsetCount int32
+	LimitCount  int32
+}
+
+func (q *Queries) GetAuditEntriesPaginated(ctx context.Context, arg GetAuditEntriesPaginatedParams) ([]AuditLog, error) {
+	rows, err := q.db.Query(ctx, getAuditEntriesPaginated,
+		arg.Action,
+		arg.TargetType,
+		arg.TargetID,
+		arg.UserID,
+		arg.OffsetCount,
+		arg.LimitCount,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []AuditLog
+	for rows.Next() {
+		var i AuditLog
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.Username,
+			&i.Action,
+			&i.TargetType,
+			&i.TargetIds,
+			&i.Before,
+			&i.After,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const insertAuditEntry = `-- name: InsertAuditEntry :one
+INSERT INTO audit_log (user_id, username, action, target_type, target_ids, before, after)
+VALUES ($1, $2, $3, $4, $5, $6, $7)
+RETURNING id, user_id, username, action, target_type, target_ids, before, after, created_at
+`
+
+type InsertAuditEntryParams struct {
+	UserID     pgtype.Int4
+	Username   string
+	Action     string
+	TargetType string
+	TargetIds  []int32
+	Before     []byte
+	After      []byte
+}
+
+func (q *Queries) InsertAuditEntry(ctx context.Context, arg InsertAuditEntryParams) (AuditLog, error) {
+	row := q.db.QueryRow(ctx, insertAuditEntry,
+		arg.UserID,
+		arg.Username,
+		arg.Action,
+		arg.TargetType,
+		arg.TargetIds,
+		arg.Before,
+		arg.After,
+	)
+	var i AuditLog
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.Username,
+		&i.Action,
+		&i.TargetType,
+		&i.TargetIds,
+		&i.Before,
+		&i.After,
+		&i.CreatedAt,
+	)
+	return i, err
+}
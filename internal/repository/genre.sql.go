@@ -125,6 +125,7 @@ JOIN releases r ON t.release_id = r.id
 JOIN release_genres rg ON r.id = rg.release_id
 JOIN genres g ON rg.genre_id = g.id
 WHERE l.listened_at BETWEEN $1 AND $2
+  AND l.user_id = $3
 GROUP BY g.name
 ORDER BY listen_count DESC
 `
@@ -132,6 +133,7 @@ ORDER BY listen_count DESC
 type GetGenreStatsByListenCountParams struct {
 	ListenedAt   time.Time
 	ListenedAt_2 time.Time
+	UserID       int32
 }
 
 type GetGenreStatsByListenCountRow struct {
@@ -140,7 +142,7 @@ type GetGenreStatsByListenCountRow struct {
 }
 
 func (q *Queries) GetGenreStatsByListenCount(ctx context.Context, arg GetGenreStatsByListenCountParams) ([]GetGenreStatsByListenCountRow, error) {
-	rows, err := q.db.Query(ctx, getGenreStatsByListenCount, arg.ListenedAt, arg.ListenedAt_2)
+	rows, err := q.db.Query(ctx, getGenreStatsByListenCount, arg.ListenedAt, arg.ListenedAt_2, arg.UserID)
 	if err != nil {
 		return nil, err
 	}
@@ -169,6 +171,7 @@ JOIN releases r ON t.release_id = r.id
 JOIN release_genres rg ON r.id = rg.release_id
 JOIN genres g ON rg.genre_id = g.id
 WHERE l.listened_at BETWEEN $1 AND $2
+  AND l.user_id = $3
 GROUP BY g.name
 ORDER BY seconds_listened DESC
 `
@@ -176,6 +179,7 @@ ORDER BY seconds_listened DESC
 type GetGenreStatsByTimeListenedParams struct {
 	ListenedAt   time.Time
 	ListenedAt_2 time.Time
+	UserID       int32
 }
 
 type GetGenreStatsByTimeListenedRow struct {
@@ -184,7 +188,7 @@ type GetGenreStatsByTimeListenedRow struct {
 }
 
 func (q *Queries) GetGenreStatsByTimeListened(ctx context.Context, arg GetGenreStatsByTimeListenedParams) ([]GetGenreStatsByTimeListenedRow, error) {
-	rows, err := q.db.Query(ctx, getGenreStatsByTimeListened, arg.ListenedAt, arg.ListenedAt_2)
+	rows, err := q.db.Query(ctx, getGenreStatsByTimeListened, arg.ListenedAt, arg.ListenedAt_2, arg.UserID)
 	if err != nil {
 		return nil, err
 	}
@@ -19,25 +19,26 @@ WITH bounds AS (
     JOIN tracks t ON t.id = l.track_id
     JOIN artist_tracks at ON at.track_id = t.id
     WHERE at.artist_id = $1
+      AND l.user_id = $2::int
 ),
 stats AS (
     SELECT
         start_time,
         end_time,
         EXTRACT(EPOCH FROM (end_time - start_time)) AS total_seconds,
-        ((end_time - start_time) / $2::int) AS bucket_interval
+        ((end_time - start_time) / $3::int) AS bucket_interval
     FROM bounds
 ),
 bucket_series AS (
-    SELECT generate_series(0, $2::int - 1) AS idx
+    SELECT generate_series(0, $3::int - 1) AS idx
 ),
 listen_indices AS (
     SELECT
         LEAST(
-            $2::int - 1,
+            $3::int - 1,
             FLOOR(
                 (EXTRACT(EPOCH FROM (l.listened_at - s.start_time)) / NULLIF(s.total_seconds, 0))
-                * $2::int
+                * $3::int
             )::int
         ) AS bucket_idx
     FROM listens l
@@ -45,6 +46,7 @@ listen_indices AS (
     JOIN artist_tracks at ON at.track_id = t.id
     CROSS JOIN stats s
     WHERE at.artist_id = $1
+      AND l.user_id = $2::int
       AND s.start_time IS NOT NULL
 )
 SELECT
@@ -61,6 +63,7 @@ ORDER BY bs.idx
 
 type GetGroupedListensFromArtistParams struct {
 	ArtistID    int32
+	UserID      int32
 	BucketCount int32
 }
 
@@ -71,7 +74,7 @@ type GetGroupedListensFromArtistRow struct {
 }
 
 func (q *Queries) GetGroupedListensFromArtist(ctx context.Context, arg GetGroupedListensFromArtistParams) ([]GetGroupedListensFromArtistRow, error) {
-	rows, err := q.db.Query(ctx, getGroupedListensFromArtist, arg.ArtistID, arg.BucketCount)
+	rows, err := q.db.Query(ctx, getGroupedListensFromArtist, arg.ArtistID, arg.UserID, arg.BucketCount)
 	if err != nil {
 		return nil, err
 	}
@@ -98,31 +101,33 @@ WITH bounds AS (
     FROM listens l
     JOIN tracks t ON t.id = l.track_id
     WHERE t.release_id = $1
+      AND l.user_id = $2::int
 ),
 stats AS (
     SELECT
         start_time,
         end_time,
         EXTRACT(EPOCH FROM (end_time - start_time)) AS total_seconds,
-        ((end_time - start_time) / $2::int) AS bucket_interval
+        ((end_time - start_time) / $3::int) AS bucket_interval
     FROM bounds
 ),
 bucket_series AS (
-    SELECT generate_series(0, $2::int - 1) AS idx
+    SELECT generate_series(0, $3::int - 1) AS idx
 ),
 listen_indices AS (
     SELECT
         LEAST(
-            $2::int - 1,
+            $3::int - 1,
             FLOOR(
                 (EXTRACT(EPOCH FROM (l.listened_at - s.start_time)) / NULLIF(s.total_seconds, 0))
-                * $2::int
+                * $3::int
             )::int
         ) AS bucket_idx
     FROM listens l
     JOIN tracks t ON t.id = l.track_id
     CROSS JOIN stats s
     WHERE t.release_id = $1
+      AND l.user_id = $2::int
       AND s.start_time IS NOT NULL
 )
 SELECT
@@ -139,6 +144,7 @@ ORDER BY bs.idx
 
 type GetGroupedListensFromReleaseParams struct {
 	ReleaseID   int32
+	UserID      int32
 	BucketCount int32
 }
 
@@ -149,7 +155,7 @@ type GetGroupedListensFromReleaseRow struct {
 }
 
 func (q *Queries) GetGroupedListensFromRelease(ctx context.Context, arg GetGroupedListensFromReleaseParams) ([]GetGroupedListensFromReleaseRow, error) {
-	rows, err := q.db.Query(ctx, getGroupedListensFromRelease, arg.ReleaseID, arg.BucketCount)
+	rows, err := q.db.Query(ctx, getGroupedListensFromRelease, arg.ReleaseID, arg.UserID, arg.BucketCount)
 	if err != nil {
 		return nil, err
 	}
@@ -176,31 +182,33 @@ WITH bounds AS (
     FROM listens l
     JOIN tracks t ON t.id = l.track_id
     WHERE t.id = $1
+      AND l.user_id = $2::int
 ),
 stats AS (
     SELECT
         start_time,
         end_time,
         EXTRACT(EPOCH FROM (end_time - start_time)) AS total_seconds,
-        ((end_time - start_time) / $2::int) AS bucket_interval
+        ((end_time - start_time) / $3::int) AS bucket_interval
     FROM bounds
 ),
 bucket_series AS (
-    SELECT generate_series(0, $2::int - 1) AS idx
+    SELECT generate_series(0, $3::int - 1) AS idx
 ),
 listen_indices AS (
     SELECT
         LEAST(
-            $2::int - 1,
+            $3::int - 1,
             FLOOR(
                 (EXTRACT(EPOCH FROM (l.listened_at - s.start_time)) / NULLIF(s.total_seconds, 0))
-                * $2::int
+                * $3::int
             )::int
         ) AS bucket_idx
     FROM listens l
     JOIN tracks t ON t.id = l.track_id
     CROSS JOIN stats s
     WHERE t.id = $1
+      AND l.user_id = $2::int
       AND s.start_time IS NOT NULL
 )
 SELECT
@@ -217,6 +225,7 @@ ORDER BY bs.idx
 
 type GetGroupedListensFromTrackParams struct {
 	ID          int32
+	UserID      int32
 	BucketCount int32
 }
 
@@ -227,7 +236,7 @@ type GetGroupedListensFromTrackRow struct {
 }
 
 func (q *Queries) GetGroupedListensFromTrack(ctx context.Context, arg GetGroupedListensFromTrackParams) ([]GetGroupedListensFromTrackRow, error) {
-	rows, err := q.db.Query(ctx, getGroupedListensFromTrack, arg.ID, arg.BucketCount)
+	rows, err := q.db.Query(ctx, getGroupedListensFromTrack, arg.ID, arg.UserID, arg.BucketCount)
 	if err != nil {
 		return nil, err
 	}
@@ -13,19 +13,44 @@ import (
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
+const copyListensToTrack = `-- name: CopyListensToTrack :execrows
+INSERT INTO listens (track_id, listened_at, user_id, client)
+SELECT $1::int, l.listened_at, l.user_id, l.client
+FROM listens l
+WHERE l.track_id = $2::int
+ON CONFLICT DO NOTHING
+`
+
+type CopyListensToTrackParams struct {
+	ToID   int32
+	FromID int32
+}
+
+// copies the listens of a track onto another, skipping those the user already has of the
+// other track at the same time
+func (q *Queries) CopyListensToTrack(ctx context.Context, arg CopyListensToTrackParams) (int64, error) {
+	result, err := q.db.Exec(ctx, copyListensToTrack, arg.ToID, arg.FromID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
 const countListens = `-- name: CountListens :one
 SELECT COUNT(*) AS total_count
 FROM listens l
 WHERE l.listened_at BETWEEN $1 AND $2
+  AND l.user_id = $3
 `
 
 type CountListensParams struct {
 	ListenedAt   time.Time
 	ListenedAt_2 time.Time
+	UserID       int32
 }
 
 func (q *Queries) CountListens(ctx context.Context, arg CountListensParams) (int64, error) {
-	row := q.db.QueryRow(ctx, countListens, arg.ListenedAt, arg.ListenedAt_2)
+	row := q.db.QueryRow(ctx, countListens, arg.ListenedAt, arg.ListenedAt_2, arg.UserID)
 	var total_count int64
 	err := row.Scan(&total_count)
 	return total_count, err
@@ -37,16 +62,23 @@ FROM listens l
 JOIN artist_tracks at ON l.track_id = at.track_id
 WHERE l.listened_at BETWEEN $1 AND $2
   AND at.artist_id = $3
+  AND l.user_id = $4
 `
 
 type CountListensFromArtistParams struct {
 	ListenedAt   time.Time
 	ListenedAt_2 time.Time
 	ArtistID     int32
+	UserID       int32
 }
 
 func (q *Queries) CountListensFromArtist(ctx context.Context, arg CountListensFromArtistParams) (int64, error) {
-	row := q.db.QueryRow(ctx, countListensFromArtist, arg.ListenedAt, arg.ListenedAt_2, arg.ArtistID)
+	row := q.db.QueryRow(ctx, countListensFromArtist,
+		arg.ListenedAt,
+		arg.ListenedAt_2,
+		arg.ArtistID,
+		arg.UserID,
+	)
 	var total_count int64
 	err := row.Scan(&total_count)
 	return total_count, err
@@ -58,16 +90,23 @@ FROM listens l
 JOIN tracks t ON l.track_id = t.id
 WHERE l.listened_at BETWEEN $1 AND $2
   AND t.release_id = $3
+  AND l.user_id = $4
 `
 
 type CountListensFromReleaseParams struct {
 	ListenedAt   time.Time
 	ListenedAt_2 time.Time
 	ReleaseID    int32
+	UserID       int32
 }
 
 func (q *Queries) CountListensFromRelease(ctx context.Context, arg CountListensFromReleaseParams) (int64, error) {
-	row := q.db.QueryRow(ctx, countListensFromRelease, arg.ListenedAt, arg.ListenedAt_2, arg.ReleaseID)
+	row := q.db.QueryRow(ctx, countListensFromRelease,
+		arg.ListenedAt,
+		arg.ListenedAt_2,
+		arg.ReleaseID,
+		arg.UserID,
+	)
 	var total_count int64
 	err := row.Scan(&total_count)
 	return total_count, err
@@ -78,16 +117,23 @@ SELECT COUNT(*) AS total_count
 FROM listens l
 WHERE l.listened_at BETWEEN $1 AND $2
   AND l.track_id = $3
+  AND l.user_id = $4
 `
 
 type CountListensFromTrackParams struct {
 	ListenedAt   time.Time
 	ListenedAt_2 time.Time
 	TrackID      int32
+	UserID       int32
 }
 
 func (q *Queries) CountListensFromTrack(ctx context.Context, arg CountListensFromTrackParams) (int64, error) {
-	row := q.db.QueryRow(ctx, countListensFromTrack, arg.ListenedAt, arg.ListenedAt_2, arg.TrackID)
+	row := q.db.QueryRow(ctx, countListensFromTrack,
+		arg.ListenedAt,
+		arg.ListenedAt_2,
+		arg.TrackID,
+		arg.UserID,
+	)
 	var total_count int64
 	err := row.Scan(&total_count)
 	return total_count, err
@@ -98,15 +144,17 @@ SELECT COALESCE(SUM(t.duration), 0)::BIGINT AS seconds_listened
 FROM listens l
 JOIN tracks t ON l.track_id = t.id
 WHERE l.listened_at BETWEEN $1 AND $2
+  AND l.user_id = $3
 `
 
 type CountTimeListenedParams struct {
 	ListenedAt   time.Time
 	ListenedAt_2 time.Time
+	UserID       int32
 }
 
 func (q *Queries) CountTimeListened(ctx context.Context, arg CountTimeListenedParams) (int64, error) {
-	row := q.db.QueryRow(ctx, countTimeListened, arg.ListenedAt, arg.ListenedAt_2)
+	row := q.db.QueryRow(ctx, countTimeListened, arg.ListenedAt, arg.ListenedAt_2, arg.UserID)
 	var seconds_listened int64
 	err := row.Scan(&seconds_listened)
 	return seconds_listened, err
@@ -119,16 +167,23 @@ JOIN tracks t ON l.track_id = t.id
 JOIN artist_tracks at ON t.id = at.track_id
 WHERE l.listened_at BETWEEN $1 AND $2
   AND at.artist_id = $3
+  AND l.user_id = $4
 `
 
 type CountTimeListenedToArtistParams struct {
 	ListenedAt   time.Time
 	ListenedAt_2 time.Time
 	ArtistID     int32
+	UserID       int32
 }
 
 func (q *Queries) CountTimeListenedToArtist(ctx context.Context, arg CountTimeListenedToArtistParams) (int64, error) {
-	row := q.db.QueryRow(ctx, countTimeListenedToArtist, arg.ListenedAt, arg.ListenedAt_2, arg.ArtistID)
+	row := q.db.QueryRow(ctx, countTimeListenedToArtist,
+		arg.ListenedAt,
+		arg.ListenedAt_2,
+		arg.ArtistID,
+		arg.UserID,
+	)
 	var seconds_listened int64
 	err := row.Scan(&seconds_listened)
 	return seconds_listened, err
@@ -140,16 +195,23 @@ FROM listens l
 JOIN tracks t ON l.track_id = t.id
 WHERE l.listened_at BETWEEN $1 AND $2
   AND t.release_id = $3
+  AND l.user_id = $4
 `
 
 type CountTimeListenedToReleaseParams struct {
 	ListenedAt   time.Time
 	ListenedAt_2 time.Time
 	ReleaseID    int32
+	UserID       int32
 }
 
 func (q *Queries) CountTimeListenedToRelease(ctx context.Context, arg CountTimeListenedToReleaseParams) (int64, error) {
-	row := q.db.QueryRow(ctx, countTimeListenedToRelease, arg.ListenedAt, arg.ListenedAt_2, arg.ReleaseID)
+	row := q.db.QueryRow(ctx, countTimeListenedToRelease,
+		arg.ListenedAt,
+		arg.ListenedAt_2,
+		arg.ReleaseID,
+		arg.UserID,
+	)
 	var seconds_listened int64
 	err := row.Scan(&seconds_listened)
 	return seconds_listened, err
@@ -161,45 +223,149 @@ FROM listens l
 JOIN tracks t ON l.track_id = t.id
 WHERE l.listened_at BETWEEN $1 AND $2
   AND t.id = $3
+  AND l.user_id = $4
 `
 
 type CountTimeListenedToTrackParams struct {
 	ListenedAt   time.Time
 	ListenedAt_2 time.Time
 	ID           int32
+	UserID       int32
 }
 
 func (q *Queries) CountTimeListenedToTrack(ctx context.Context, arg CountTimeListenedToTrackParams) (int64, error) {
-	row := q.db.QueryRow(ctx, countTimeListenedToTrack, arg.ListenedAt, arg.ListenedAt_2, arg.ID)
+	row := q.db.QueryRow(ctx, countTimeListenedToTrack,
+		arg.ListenedAt,
+		arg.ListenedAt_2,
+		arg.ID,
+		arg.UserID,
+	)
 	var seconds_listened int64
 	err := row.Scan(&seconds_listened)
 	return seconds_listened, err
 }
 
+const countUserListens = `-- name: CountUserListens :one
+SELECT COUNT(*) AS total_count
+FROM listens
+WHERE user_id = $1
+`
+
+func (q *Queries) CountUserListens(ctx context.Context, userID int32) (int64, error) {
+	row := q.db.QueryRow(ctx, countUserListens, userID)
+	var total_count int64
+	err := row.Scan(&total_count)
+	return total_count, err
+}
+
 const deleteListen = `-- name: DeleteListen :exec
-DELETE FROM listens WHERE track_id = $1 AND listened_at = $2
+DELETE FROM listens WHERE user_id = $1 AND track_id = $2 AND listened_at = $3
 `
 
 type DeleteListenParams struct {
+	UserID     int32
 	TrackID    int32
 	ListenedAt time.Time
 }
 
 func (q *Queries) DeleteListen(ctx context.Context, arg DeleteListenParams) error {
-	_, err := q.db.Exec(ctx, deleteListen, arg.TrackID, arg.ListenedAt)
+	_, err := q.db.Exec(ctx, deleteListen, arg.UserID, arg.TrackID, arg.ListenedAt)
 	return err
 }
 
+const deleteListensForTrack = `-- name: DeleteListensForTrack :execrows
+DELETE FROM listens WHERE track_id = $1
+`
+
+func (q *Queries) DeleteListensForTrack(ctx context.Context, trackID int32) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteListensForTrack, trackID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const getDuplicateListenCandidates = `-- name: GetDuplicateListenCandidates :many
+SELECT
+    x.user_id,
+    x.track_id,
+    x.title,
+    x.listened_at,
+    x.client,
+    x.window_seconds
+FROM (
+    SELECT
+        l.user_id,
+        l.track_id,
+        t.title,
+        l.listened_at,
+        l.client,
+        (CASE WHEN $1::boolean AND t.duration > 0
+            THEN t.duration / 2.0
+            ELSE $2::float8
+        END)::float8 AS window_seconds,
+        LAG(l.listened_at) OVER (PARTITION BY l.user_id, l.track_id ORDER BY l.listened_at) AS prev_at,
+        LEAD(l.listened_at) OVER (PARTITION BY l.user_id, l.track_id ORDER BY l.listened_at) AS next_at
+    FROM listens l
+    JOIN tracks_with_title t ON t.id = l.track_id
+) x
+WHERE EXTRACT(EPOCH FROM x.listened_at - x.prev_at) < x.window_seconds
+   OR EXTRACT(EPOCH FROM x.next_at - x.listened_at) < x.window_seconds
+ORDER BY x.user_id, x.track_id, x.listened_at
+`
+
+type GetDuplicateListenCandidatesParams struct {
+	ByDuration    bool
+	WindowSeconds float64
+}
+
+type GetDuplicateListenCandidatesRow struct {
+	UserID        int32
+	TrackID       int32
+	Title         string
+	ListenedAt    time.Time
+	Client        *string
+	WindowSeconds float64
+}
+
+func (q *Queries) GetDuplicateListenCandidates(ctx context.Context, arg GetDuplicateListenCandidatesParams) ([]GetDuplicateListenCandidatesRow, error) {
+	rows, err := q.db.Query(ctx, getDuplicateListenCandidates, arg.ByDuration, arg.WindowSeconds)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetDuplicateListenCandidatesRow
+	for rows.Next() {
+		var i GetDuplicateListenCandidatesRow
+		if err := rows.Scan(
+			&i.UserID,
+			&i.TrackID,
+			&i.Title,
+			&i.ListenedAt,
+			&i.Client,
+			&i.WindowSeconds,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const getFirstListen = `-- name: GetFirstListen :one
 SELECT
   track_id, listened_at, client, user_id
 FROM listens
+WHERE user_id = $1
 ORDER BY listened_at ASC
 LIMIT 1
 `
 
-func (q *Queries) GetFirstListen(ctx context.Context) (Listen, error) {
-	row := q.db.QueryRow(ctx, getFirstListen)
+func (q *Queries) GetFirstListen(ctx context.Context, userID int32) (Listen, error) {
+	row := q.db.QueryRow(ctx, getFirstListen, userID)
 	var i Listen
 	err := row.Scan(
 		&i.TrackID,
@@ -217,12 +383,18 @@ FROM listens l
 JOIN tracks_with_title t ON l.track_id = t.id
 JOIN artist_tracks at ON t.id = at.track_id
 WHERE at.artist_id = $1
+  AND l.user_id = $2
 ORDER BY l.listened_at ASC
 LIMIT 1
 `
 
-func (q *Queries) GetFirstListenFromArtist(ctx context.Context, artistID int32) (Listen, error) {
-	row := q.db.QueryRow(ctx, getFirstListenFromArtist, artistID)
+type GetFirstListenFromArtistParams struct {
+	ArtistID int32
+	UserID   int32
+}
+
+func (q *Queries) GetFirstListenFromArtist(ctx context.Context, arg GetFirstListenFromArtistParams) (Listen, error) {
+	row := q.db.QueryRow(ctx, getFirstListenFromArtist, arg.ArtistID, arg.UserID)
 	var i Listen
 	err := row.Scan(
 		&i.TrackID,
@@ -239,12 +411,18 @@ SELECT
 FROM listens l
 JOIN tracks t ON l.track_id = t.id
 WHERE t.release_id = $1
+  AND l.user_id = $2
 ORDER BY l.listened_at ASC
 LIMIT 1
 `
 
-func (q *Queries) GetFirstListenFromRelease(ctx context.Context, releaseID int32) (Listen, error) {
-	row := q.db.QueryRow(ctx, getFirstListenFromRelease, releaseID)
+type GetFirstListenFromReleaseParams struct {
+	ReleaseID int32
+	UserID    int32
+}
+
+func (q *Queries) GetFirstListenFromRelease(ctx context.Context, arg GetFirstListenFromReleaseParams) (Listen, error) {
+	row := q.db.QueryRow(ctx, getFirstListenFromRelease, arg.ReleaseID, arg.UserID)
 	var i Listen
 	err := row.Scan(
 		&i.TrackID,
@@ -261,12 +439,18 @@ SELECT
 FROM listens l
 JOIN tracks t ON l.track_id = t.id
 WHERE t.id = $1
+  AND l.user_id = $2
 ORDER BY l.listened_at ASC
 LIMIT 1
 `
 
-func (q *Queries) GetFirstListenFromTrack(ctx context.Context, id int32) (Listen, error) {
-	row := q.db.QueryRow(ctx, getFirstListenFromTrack, id)
+type GetFirstListenFromTrackParams struct {
+	ID     int32
+	UserID int32
+}
+
+func (q *Queries) GetFirstListenFromTrack(ctx context.Context, arg GetFirstListenFromTrackParams) (Listen, error) {
+	row := q.db.QueryRow(ctx, getFirstListenFromTrack, arg.ID, arg.UserID)
 	var i Listen
 	err := row.Scan(
 		&i.TrackID,
@@ -288,6 +472,7 @@ JOIN tracks_with_title t ON l.track_id = t.id
 JOIN artist_tracks at ON t.id = at.track_id
 WHERE at.artist_id = $5
   AND l.listened_at BETWEEN $1 AND $2
+  AND l.user_id = $6
 ORDER BY l.listened_at DESC
 LIMIT $3 OFFSET $4
 `
@@ -298,6 +483,7 @@ type GetLastListensFromArtistPaginatedParams struct {
 	Limit        int32
 	Offset       int32
 	ArtistID     int32
+	UserID       int32
 }
 
 type GetLastListensFromArtistPaginatedRow struct {
@@ -317,6 +503,7 @@ func (q *Queries) GetLastListensFromArtistPaginated(ctx context.Context, arg Get
 		arg.Limit,
 		arg.Offset,
 		arg.ArtistID,
+		arg.UserID,
 	)
 	if err != nil {
 		return nil, err
@@ -354,6 +541,7 @@ FROM listens l
 JOIN tracks_with_title t ON l.track_id = t.id
 WHERE l.listened_at BETWEEN $1 AND $2
   AND t.release_id = $5
+  AND l.user_id = $6
 ORDER BY l.listened_at DESC
 LIMIT $3 OFFSET $4
 `
@@ -364,6 +552,7 @@ type GetLastListensFromReleasePaginatedParams struct {
 	Limit        int32
 	Offset       int32
 	ReleaseID    int32
+	UserID       int32
 }
 
 type GetLastListensFromReleasePaginatedRow struct {
@@ -383,6 +572,7 @@ func (q *Queries) GetLastListensFromReleasePaginated(ctx context.Context, arg Ge
 		arg.Limit,
 		arg.Offset,
 		arg.ReleaseID,
+		arg.UserID,
 	)
 	if err != nil {
 		return nil, err
@@ -429,6 +619,7 @@ CROSS JOIN LATERAL (
 ) artists
 WHERE l.listened_at BETWEEN $1 AND $2
   AND t.id = $5
+  AND l.user_id = $6
 ORDER BY l.listened_at DESC
 LIMIT $3 OFFSET $4
 `
@@ -439,6 +630,7 @@ type GetLastListensFromTrackPaginatedParams struct {
 	Limit        int32
 	Offset       int32
 	ID           int32
+	UserID       int32
 }
 
 type GetLastListensFromTrackPaginatedRow struct {
@@ -458,6 +650,7 @@ func (q *Queries) GetLastListensFromTrackPaginated(ctx context.Context, arg GetL
 		arg.Limit,
 		arg.Offset,
 		arg.ID,
+		arg.UserID,
 	)
 	if err != nil {
 		return nil, err
@@ -503,6 +696,7 @@ CROSS JOIN LATERAL (
     WHERE at.track_id = t.id
 ) artists
 WHERE l.listened_at BETWEEN $1 AND $2
+  AND l.user_id = $5
 ORDER BY l.listened_at DESC
 LIMIT $3 OFFSET $4
 `
@@ -512,6 +706,7 @@ type GetLastListensPaginatedParams struct {
 	ListenedAt_2 time.Time
 	Limit        int32
 	Offset       int32
+	UserID       int32
 }
 
 type GetLastListensPaginatedRow struct {
@@ -530,6 +725,7 @@ func (q *Queries) GetLastListensPaginated(ctx context.Context, arg GetLastListen
 		arg.ListenedAt_2,
 		arg.Limit,
 		arg.Offset,
+		arg.UserID,
 	)
 	if err != nil {
 		return nil, err
@@ -600,6 +796,8 @@ SELECT
             'musicbrainz_id', a.musicbrainz_id,
             'image', a.image,
             'image_source', a.image_source,
+            'is_primary', at.is_primary,
+            'role', at.role,
             'aliases', (
                 SELECT json_agg(json_build_object(
                     'alias', aa.alias,
@@ -689,6 +887,194 @@ func (q *Queries) GetListensExportPage(ctx context.Context, arg GetListensExport
 	return items, nil
 }
 
+const getUserListensAfter = `-- name: GetUserListensAfter :many
+SELECT
+  l.track_id, l.listened_at, l.client, l.user_id,
+  t.title AS track_title,
+  t.release_id AS release_id,
+  artists.artists
+FROM listens l
+JOIN tracks_with_title t ON l.track_id = t.id
+CROSS JOIN LATERAL (
+    SELECT json_agg(
+        jsonb_build_object('id', a.id, 'name', a.name)
+        ORDER BY at.is_primary DESC, a.name
+    ) AS artists
+    FROM artist_tracks at
+    JOIN artists_with_name a ON a.id = at.artist_id
+    WHERE at.track_id = t.id
+) artists
+WHERE l.user_id = $1
+  AND l.listened_at > $2
+ORDER BY l.listened_at ASC
+LIMIT $3
+`
+
+type GetUserListensAfterParams struct {
+	UserID     int32
+	MinTs      time.Time
+	LimitCount int32
+}
+
+type GetUserListensAfterRow struct {
+	TrackID    int32
+	ListenedAt time.Time
+	Client     *string
+	UserID     int32
+	TrackTitle string
+	ReleaseID  int32
+	Artists    []byte
+}
+
+// The oldest listens of a user after a time, for paging forwards through their listens.
+func (q *Queries) GetUserListensAfter(ctx context.Context, arg GetUserListensAfterParams) ([]GetUserListensAfterRow, error) {
+	rows, err := q.db.Query(ctx, getUserListensAfter, arg.UserID, arg.MinTs, arg.LimitCount)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetUserListensAfterRow
+	for rows.Next() {
+		var i GetUserListensAfterRow
+		if err := rows.Scan(
+			&i.TrackID,
+			&i.ListenedAt,
+			&i.Client,
+			&i.UserID,
+			&i.TrackTitle,
+			&i.ReleaseID,
+			&i.Artists,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getUserListensBefore = `-- name: GetUserListensBefore :many
+SELECT
+  l.track_id, l.listened_at, l.client, l.user_id,
+  t.title AS track_title,
+  t.release_id AS release_id,
+  artists.artists
+FROM listens l
+JOIN tracks_with_title t ON l.track_id = t.id
+CROSS JOIN LATERAL (
+    SELECT json_agg(
+        jsonb_build_object('id', a.id, 'name', a.name)
+        ORDER BY at.is_primary DESC, a.name
+    ) AS artists
+    FROM artist_tracks at
+    JOIN artists_with_name a ON a.id = at.artist_id
+    WHERE at.track_id = t.id
+) artists
+WHERE l.user_id = $1
+  AND l.listened_at < $2
+ORDER BY l.listened_at DESC
+LIMIT $3
+`
+
+type GetUserListensBeforeParams struct {
+	UserID     int32
+	MaxTs      time.Time
+	LimitCount int32
+}
+
+type GetUserListensBeforeRow struct {
+	TrackID    int32
+	ListenedAt time.Time
+	Client     *string
+	UserID     int32
+	TrackTitle string
+	ReleaseID  int32
+	Artists    []byte
+}
+
+// The newest listens of a user before a time, for paging backwards through their listens.
+func (q *Queries) GetUserListensBefore(ctx context.Context, arg GetUserListensBeforeParams) ([]GetUserListensBeforeRow, error) {
+	rows, err := q.db.Query(ctx, getUserListensBefore, arg.UserID, arg.MaxTs, arg.LimitCount)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetUserListensBeforeRow
+	for rows.Next() {
+		var i GetUserListensBeforeRow
+		if err := rows.Scan(
+			&i.TrackID,
+			&i.ListenedAt,
+			&i.Client,
+			&i.UserID,
+			&i.TrackTitle,
+			&i.ReleaseID,
+			&i.Artists,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getUserListensToTrackInRange = `-- name: GetUserListensToTrackInRange :many
+SELECT track_id, listened_at, user_id, client FROM listens
+WHERE user_id = $1
+  AND track_id = $2
+  AND listened_at BETWEEN $3 AND $4
+ORDER BY listened_at
+`
+
+type GetUserListensToTrackInRangeParams struct {
+	UserID       int32
+	TrackID      int32
+	ListenedAt   time.Time
+	ListenedAt_2 time.Time
+}
+
+type GetUserListensToTrackInRangeRow struct {
+	TrackID    int32
+	ListenedAt time.Time
+	UserID     int32
+	Client     *string
+}
+
+func (q *Queries) GetUserListensToTrackInRange(ctx context.Context, arg GetUserListensToTrackInRangeParams) ([]GetUserListensToTrackInRangeRow, error) {
+	rows, err := q.db.Query(ctx, getUserListensToTrackInRange,
+		arg.UserID,
+		arg.TrackID,
+		arg.ListenedAt,
+		arg.ListenedAt_2,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetUserListensToTrackInRangeRow
+	for rows.Next() {
+		var i GetUserListensToTrackInRangeRow
+		if err := rows.Scan(
+			&i.TrackID,
+			&i.ListenedAt,
+			&i.UserID,
+			&i.Client,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const insertListen = `-- name: InsertListen :exec
 INSERT INTO listens (track_id, listened_at, user_id, client)
 VALUES ($1, $2, $3, $4)
@@ -719,6 +1105,7 @@ SELECT
 FROM listens
 WHERE listened_at >= $2
 AND listened_at < $3
+AND user_id = $4
 GROUP BY day
 ORDER BY day
 `
@@ -727,6 +1114,7 @@ type ListenActivityParams struct {
 	Column1      string
 	ListenedAt   time.Time
 	ListenedAt_2 time.Time
+	UserID       int32
 }
 
 type ListenActivityRow struct {
@@ -735,7 +1123,12 @@ type ListenActivityRow struct {
 }
 
 func (q *Queries) ListenActivity(ctx context.Context, arg ListenActivityParams) ([]ListenActivityRow, error) {
-	rows, err := q.db.Query(ctx, listenActivity, arg.Column1, arg.ListenedAt, arg.ListenedAt_2)
+	rows, err := q.db.Query(ctx, listenActivity,
+		arg.Column1,
+		arg.ListenedAt,
+		arg.ListenedAt_2,
+		arg.UserID,
+	)
 	if err != nil {
 		return nil, err
 	}
@@ -764,6 +1157,7 @@ JOIN artist_tracks at ON t.id = at.track_id
 WHERE l.listened_at >= $2
 AND l.listened_at < $3
 AND at.artist_id = $4
+AND l.user_id = $5
 GROUP BY day
 ORDER BY day
 `
@@ -773,6 +1167,7 @@ type ListenActivityForArtistParams struct {
 	ListenedAt   time.Time
 	ListenedAt_2 time.Time
 	ArtistID     int32
+	UserID       int32
 }
 
 type ListenActivityForArtistRow struct {
@@ -786,6 +1181,7 @@ func (q *Queries) ListenActivityForArtist(ctx context.Context, arg ListenActivit
 		arg.ListenedAt,
 		arg.ListenedAt_2,
 		arg.ArtistID,
+		arg.UserID,
 	)
 	if err != nil {
 		return nil, err
@@ -814,6 +1210,7 @@ JOIN tracks t ON l.track_id = t.id
 WHERE l.listened_at >= $2
 AND l.listened_at < $3
 AND t.release_id = $4
+AND l.user_id = $5
 GROUP BY day
 ORDER BY day
 `
@@ -823,6 +1220,7 @@ type ListenActivityForReleaseParams struct {
 	ListenedAt   time.Time
 	ListenedAt_2 time.Time
 	ReleaseID    int32
+	UserID       int32
 }
 
 type ListenActivityForReleaseRow struct {
@@ -836,6 +1234,7 @@ func (q *Queries) ListenActivityForRelease(ctx context.Context, arg ListenActivi
 		arg.ListenedAt,
 		arg.ListenedAt_2,
 		arg.ReleaseID,
+		arg.UserID,
 	)
 	if err != nil {
 		return nil, err
@@ -864,6 +1263,7 @@ JOIN tracks t ON l.track_id = t.id
 WHERE l.listened_at >= $2
 AND l.listened_at < $3
 AND t.id = $4
+AND l.user_id = $5
 GROUP BY day
 ORDER BY day
 `
@@ -873,6 +1273,7 @@ type ListenActivityForTrackParams struct {
 	ListenedAt   time.Time
 	ListenedAt_2 time.Time
 	ID           int32
+	UserID       int32
 }
 
 type ListenActivityForTrackRow struct {
@@ -886,6 +1287,7 @@ func (q *Queries) ListenActivityForTrack(ctx context.Context, arg ListenActivity
 		arg.ListenedAt,
 		arg.ListenedAt_2,
 		arg.ID,
+		arg.UserID,
 	)
 	if err != nil {
 		return nil, err
@@ -905,17 +1307,17 @@ func (q *Queries) ListenActivityForTrack(ctx context.Context, arg ListenActivity
 	return items, nil
 }
 
-const updateTrackIdForListens = `-- name: UpdateTrackIdForListens :exec
-UPDATE listens SET track_id = $2
-WHERE track_id = $1
+const lockUserTrackListens = `-- name: LockUserTrackListens :exec
+SELECT pg_advisory_xact_lock($1::int, $2::int)
 `
 
-type UpdateTrackIdForListensParams struct {
-	TrackID   int32
-	TrackID_2 int32
+type LockUserTrackListensParams struct {
+	UserID  int32
+	TrackID int32
 }
 
-func (q *Queries) UpdateTrackIdForListens(ctx context.Context, arg UpdateTrackIdForListensParams) error {
-	_, err := q.db.Exec(ctx, updateTrackIdForListens, arg.TrackID, arg.TrackID_2)
+// Serializes submissions of listens to a track by a user until the end of the transaction.
+func (q *Queries) LockUserTrackListens(ctx context.Context, arg LockUserTrackListensParams) error {
+	_, err := q.db.Exec(ctx, lockUserTrackListens, arg.UserID, arg.TrackID)
 	return err
 }
@@ -0,0 +1,581 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: merge_snapshot.sql
+
+package repository
+
+import (
+	"context"
+)
+
+const getLatestMergeSnapshot = `-- name: GetLatestMergeSnapshot :one
+SELECT id, kind, from_id, to_id, snapshot, created_at, undone_at FROM merge_snapshots
+WHERE kind = $1 AND from_id = $2 AND undone_at IS NULL
+ORDER BY id DESC
+LIMIT 1
+`
+
+type GetLatestMergeSnapshotParams struct {
+	Kind   string
+	FromID int32
+}
+
+func (q *Queries) GetLatestMergeSnapshot(ctx context.Context, arg GetLatestMergeSnapshotParams) (MergeSnapshot, error) {
+	row := q.db.QueryRow(ctx, getLatestMergeSnapshot, arg.Kind, arg.FromID)
+	var i MergeSnapshot
+	err := row.Scan(
+		&i.ID,
+		&i.Kind,
+		&i.FromID,
+		&i.ToID,
+		&i.Snapshot,
+		&i.CreatedAt,
+		&i.UndoneAt,
+	)
+	return i, err
+}
+
+const getTrackIDsForRelease = `-- name: GetTrackIDsForRelease :many
+SELECT id FROM tracks WHERE release_id = $1 ORDER BY id
+`
+
+func (q *Queries) GetTrackIDsForRelease(ctx context.Context, releaseID int32) ([]int32, error) {
+	rows, err := q.db.Query(ctx, getTrackIDsForRelease, releaseID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []int32
+	for rows.Next() {
+		var id int32
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		items = append(items, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const insertMergeSnapshot = `-- name: InsertMergeSnapshot :exec
+INSERT INTO merge_snapshots (kind, from_id, to_id, snapshot)
+VALUES ($1, $2, $3, $4)
+`
+
+type InsertMergeSnapshotParams struct {
+	Kind     string
+	FromID   int32
+	ToID     int32
+	Snapshot []byte
+}
+
+func (q *Queries) InsertMergeSnapshot(ctx context.Context, arg InsertMergeSnapshotParams) error {
+	_, err := q.db.Exec(ctx, insertMergeSnapshot,
+		arg.Kind,
+		arg.FromID,
+		arg.ToID,
+		arg.Snapshot,
+	)
+	return err
+}
+
+const markMergeSnapshotUndone = `-- name: MarkMergeSnapshotUndone :exec
+UPDATE merge_snapshots SET undone_at = NOW() WHERE id = $1
+`
+
+func (q *Queries) MarkMergeSnapshotUndone(ctx context.Context, id int32) error {
+	_, err := q.db.Exec(ctx, markMergeSnapshotUndone, id)
+	return err
+}
+
+const restoreArtistAliases = `-- name: RestoreArtistAliases :exec
+INSERT INTO artist_aliases
+SELECT (r).* FROM jsonb_populate_recordset(NULL::artist_aliases, $1::jsonb) r
+WHERE r.artist_id = ANY($2::int[])
+ON CONFLICT DO NOTHING
+`
+
+type RestoreArtistAliasesParams struct {
+	Data []byte
+	Ids  []int32
+}
+
+func (q *Queries) RestoreArtistAliases(ctx context.Context, arg RestoreArtistAliasesParams) error {
+	_, err := q.db.Exec(ctx, restoreArtistAliases, arg.Data, arg.Ids)
+	return err
+}
+
+const restoreArtistGenres = `-- name: RestoreArtistGenres :exec
+INSERT INTO artist_genres
+SELECT (r).* FROM jsonb_populate_recordset(NULL::artist_genres, $1::jsonb) r
+WHERE r.artist_id = ANY($2::int[])
+  AND r.genre_id IN (SELECT id FROM genres)
+ON CONFLICT DO NOTHING
+`
+
+type RestoreArtistGenresParams struct {
+	Data []byte
+	Ids  []int32
+}
+
+func (q *Queries) RestoreArtistGenres(ctx context.Context, arg RestoreArtistGenresParams) error {
+	_, err := q.db.Exec(ctx, restoreArtistGenres, arg.Data, arg.Ids)
+	return err
+}
+
+const restoreArtistReleases = `-- name: RestoreArtistReleases :exec
+INSERT INTO artist_releases
+SELECT (r).* FROM jsonb_populate_recordset(NULL::artist_releases, $1::jsonb) r
+WHERE r.artist_id IN (SELECT id FROM artists)
+  AND r.release_id IN (SELECT id FROM releases)
+ON CONFLICT DO NOTHING
+`
+
+func (q *Queries) RestoreArtistReleases(ctx context.Context, data []byte) error {
+	_, err := q.db.Exec(ctx, restoreArtistReleases, data)
+	return err
+}
+
+const restoreArtistTracks = `-- name: RestoreArtistTracks :exec
+INSERT INTO artist_tracks
+SELECT (r).* FROM jsonb_populate_recordset(NULL::artist_tracks, $1::jsonb) r
+WHERE r.artist_id IN (SELECT id FROM artists)
+  AND r.track_id IN (SELECT id FROM tracks)
+ON CONFLICT DO NOTHING
+`
+
+func (q *Queries) RestoreArtistTracks(ctx context.Context, data []byte) error {
+	_, err := q.db.Exec(ctx, restoreArtistTracks, data)
+	return err
+}
+
+const restoreArtists = `-- name: RestoreArtists :many
+INSERT INTO artists OVERRIDING SYSTEM VALUE
+SELECT (r).* FROM jsonb_populate_recordset(NULL::artists, $1::jsonb) r
+ON CONFLICT DO NOTHING
+RETURNING id
+`
+
+func (q *Queries) RestoreArtists(ctx context.Context, data []byte) ([]int32, error) {
+	rows, err := q.db.Query(ctx, restoreArtists, data)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []int32
+	for rows.Next() {
+		var id int32
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		items = append(items, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const restoreListens = `-- name: RestoreListens :exec
+INSERT INTO listens
+SELECT (r).* FROM jsonb_populate_recordset(NULL::listens, $1::jsonb) r
+WHERE r.track_id IN (SELECT id FROM tracks)
+  AND r.user_id IN (SELECT id FROM users)
+ON CONFLICT DO NOTHING
+`
+
+func (q *Queries) RestoreListens(ctx context.Context, data []byte) error {
+	_, err := q.db.Exec(ctx, restoreListens, data)
+	return err
+}
+
+const restoreReleaseAliases = `-- name: RestoreReleaseAliases :exec
+INSERT INTO release_aliases
+SELECT (r).* FROM jsonb_populate_recordset(NULL::release_aliases, $1::jsonb) r
+WHERE r.release_id = ANY($2::int[])
+ON CONFLICT DO NOTHING
+`
+
+type RestoreReleaseAliasesParams struct {
+	Data []byte
+	Ids  []int32
+}
+
+func (q *Queries) RestoreReleaseAliases(ctx context.Context, arg RestoreReleaseAliasesParams) error {
+	_, err := q.db.Exec(ctx, restoreReleaseAliases, arg.Data, arg.Ids)
+	return err
+}
+
+const restoreReleaseGenres = `-- name: RestoreReleaseGenres :exec
+INSERT INTO release_genres
+SELECT (r).* FROM jsonb_populate_recordset(NULL::release_genres, $1::jsonb) r
+WHERE r.release_id = ANY($2::int[])
+  AND r.genre_id IN (SELECT id FROM genres)
+ON CONFLICT DO NOTHING
+`
+
+type RestoreReleaseGenresParams struct {
+	Data []byte
+	Ids  []int32
+}
+
+func (q *Queries) RestoreReleaseGenres(ctx context.Context, arg RestoreReleaseGenresParams) error {
+	_, err := q.db.Exec(ctx, restoreReleaseGenres, arg.Data, arg.Ids)
+	return err
+}
+
+const restoreReleases = `-- name: RestoreReleases :many
+INSERT INTO releases OVERRIDING SYSTEM VALUE
+SELECT (r).* FROM jsonb_populate_recordset(NULL::releases, $1::jsonb) r
+ON CONFLICT DO NOTHING
+RETURNING id
+`
+
+func (q *Queries) RestoreReleases(ctx context.Context, data []byte) ([]int32, error) {
+	rows, err := q.db.Query(ctx, restoreReleases, data)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []int32
+	for rows.Next() {
+		var id int32
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		items = append(items, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const restoreTrackAliases = `-- name: RestoreTrackAliases :exec
+INSERT INTO track_aliases
+SELECT (r).* FROM jsonb_populate_recordset(NULL::track_aliases, $1::jsonb) r
+WHERE r.track_id = ANY($2::int[])
+ON CONFLICT DO NOTHING
+`
+
+type RestoreTrackAliasesParams struct {
+	Data []byte
+	Ids  []int32
+}
+
+func (q *Queries) RestoreTrackAliases(ctx context.Context, arg RestoreTrackAliasesParams) error {
+	_, err := q.db.Exec(ctx, restoreTrackAliases, arg.Data, arg.Ids)
+	return err
+}
+
+const restoreTracks = `-- name: RestoreTracks :many
+INSERT INTO tracks OVERRIDING SYSTEM VALUE
+SELECT (r).* FROM jsonb_populate_recordset(NULL::tracks, $1::jsonb) r
+WHERE r.release_id IN (SELECT id FROM releases)
+ON CONFLICT DO NOTHING
+RETURNING id
+`
+
+func (q *Queries) RestoreTracks(ctx context.Context, data []byte) ([]int32, error) {
+	rows, err := q.db.Query(ctx, restoreTracks, data)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []int32
+	for rows.Next() {
+		var id int32
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		items = append(items, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const snapshotArtistAliases = `-- name: SnapshotArtistAliases :one
+SELECT COALESCE(jsonb_agg(a), '[]')::jsonb FROM artist_aliases a WHERE a.artist_id = ANY($1::int[])
+`
+
+func (q *Queries) SnapshotArtistAliases(ctx context.Context, ids []int32) ([]byte, error) {
+	row := q.db.QueryRow(ctx, snapshotArtistAliases, ids)
+	var column_1 []byte
+	err := row.Scan(&column_1)
+	return column_1, err
+}
+
+const snapshotArtistGenres = `-- name: SnapshotArtistGenres :one
+SELECT COALESCE(jsonb_agg(g), '[]')::jsonb FROM artist_genres g WHERE g.artist_id = ANY($1::int[])
+`
+
+func (q *Queries) SnapshotArtistGenres(ctx context.Context, ids []int32) ([]byte, error) {
+	row := q.db.QueryRow(ctx, snapshotArtistGenres, ids)
+	var column_1 []byte
+	err := row.Scan(&column_1)
+	return column_1, err
+}
+
+const snapshotArtistReleases = `-- name: SnapshotArtistReleases :one
+SELECT COALESCE(jsonb_agg(ar), '[]')::jsonb FROM artist_releases ar
+WHERE ar.artist_id = ANY($1::int[]) OR ar.release_id = ANY($2::int[])
+`
+
+type SnapshotArtistReleasesParams struct {
+	ArtistIds  []int32
+	ReleaseIds []int32
+}
+
+func (q *Queries) SnapshotArtistReleases(ctx context.Context, arg SnapshotArtistReleasesParams) ([]byte, error) {
+	row := q.db.QueryRow(ctx, snapshotArtistReleases, arg.ArtistIds, arg.ReleaseIds)
+	var column_1 []byte
+	err := row.Scan(&column_1)
+	return column_1, err
+}
+
+const snapshotArtistTracks = `-- name: SnapshotArtistTracks :one
+SELECT COALESCE(jsonb_agg(at), '[]')::jsonb FROM artist_tracks at
+WHERE at.artist_id = ANY($1::int[]) OR at.track_id = ANY($2::int[])
+`
+
+type SnapshotArtistTracksParams struct {
+	ArtistIds []int32
+	TrackIds  []int32
+}
+
+func (q *Queries) SnapshotArtistTracks(ctx context.Context, arg SnapshotArtistTracksParams) ([]byte, error) {
+	row := q.db.QueryRow(ctx, snapshotArtistTracks, arg.ArtistIds, arg.TrackIds)
+	var column_1 []byte
+	err := row.Scan(&column_1)
+	return column_1, err
+}
+
+const snapshotArtists = `-- name: SnapshotArtists :one
+SELECT COALESCE(jsonb_agg(a), '[]')::jsonb FROM artists a WHERE a.id = ANY($1::int[])
+`
+
+func (q *Queries) SnapshotArtists(ctx context.Context, ids []int32) ([]byte, error) {
+	row := q.db.QueryRow(ctx, snapshotArtists, ids)
+	var column_1 []byte
+	err := row.Scan(&column_1)
+	return column_1, err
+}
+
+const snapshotDroppedListens = `-- name: SnapshotDroppedListens :one
+SELECT COALESCE(jsonb_agg(l), '[]')::jsonb FROM listens l
+WHERE l.track_id = $1::int
+  AND EXISTS (
+    SELECT 1 FROM listens x
+    WHERE x.user_id = l.user_id
+      AND x.listened_at = l.listened_at
+      AND x.track_id = $2::int
+  )
+`
+
+type SnapshotDroppedListensParams struct {
+	FromID int32
+	ToID   int32
+}
+
+// the listens of a track that MergeTracks will drop, as the user already has a listen of the
+// target track at the same time
+func (q *Queries) SnapshotDroppedListens(ctx context.Context, arg SnapshotDroppedListensParams) ([]byte, error) {
+	row := q.db.QueryRow(ctx, snapshotDroppedListens, arg.FromID, arg.ToID)
+	var column_1 []byte
+	err := row.Scan(&column_1)
+	return column_1, err
+}
+
+const snapshotMovableArtistReleases = `-- name: SnapshotMovableArtistReleases :one
+SELECT COALESCE(jsonb_agg(ar), '[]')::jsonb FROM artist_releases ar
+WHERE ar.artist_id = $1::int
+  AND NOT EXISTS (
+    SELECT 1 FROM artist_releases x WHERE x.artist_id = $2::int AND x.release_id = ar.release_id
+  )
+`
+
+type SnapshotMovableArtistReleasesParams struct {
+	FromID int32
+	ToID   int32
+}
+
+func (q *Queries) SnapshotMovableArtistReleases(ctx context.Context, arg SnapshotMovableArtistReleasesParams) ([]byte, error) {
+	row := q.db.QueryRow(ctx, snapshotMovableArtistReleases, arg.FromID, arg.ToID)
+	var column_1 []byte
+	err := row.Scan(&column_1)
+	return column_1, err
+}
+
+const snapshotMovableArtistTracks = `-- name: SnapshotMovableArtistTracks :one
+SELECT COALESCE(jsonb_agg(at), '[]')::jsonb FROM artist_tracks at
+WHERE at.artist_id = $1::int
+  AND NOT EXISTS (
+    SELECT 1 FROM artist_tracks x WHERE x.artist_id = $2::int AND x.track_id = at.track_id
+  )
+`
+
+type SnapshotMovableArtistTracksParams struct {
+	FromID int32
+	ToID   int32
+}
+
+// the tracks of an artist that MergeArtists will move, rather than drop as duplicates
+func (q *Queries) SnapshotMovableArtistTracks(ctx context.Context, arg SnapshotMovableArtistTracksParams) ([]byte, error) {
+	row := q.db.QueryRow(ctx, snapshotMovableArtistTracks, arg.FromID, arg.ToID)
+	var column_1 []byte
+	err := row.Scan(&column_1)
+	return column_1, err
+}
+
+const snapshotMovableListens = `-- name: SnapshotMovableListens :one
+SELECT COALESCE(jsonb_agg(l), '[]')::jsonb FROM listens l
+WHERE l.track_id = $1::int
+  AND NOT EXISTS (
+    SELECT 1 FROM listens x
+    WHERE x.user_id = l.user_id
+      AND x.listened_at = l.listened_at
+      AND x.track_id = $2::int
+  )
+`
+
+type SnapshotMovableListensParams struct {
+	FromID int32
+	ToID   int32
+}
+
+// the listens of a track that MergeTracks will move, rather than drop as duplicates
+func (q *Queries) SnapshotMovableListens(ctx context.Context, arg SnapshotMovableListensParams) ([]byte, error) {
+	row := q.db.QueryRow(ctx, snapshotMovableListens, arg.FromID, arg.ToID)
+	var column_1 []byte
+	err := row.Scan(&column_1)
+	return column_1, err
+}
+
+const snapshotReleaseAliases = `-- name: SnapshotReleaseAliases :one
+SELECT COALESCE(jsonb_agg(a), '[]')::jsonb FROM release_aliases a WHERE a.release_id = ANY($1::int[])
+`
+
+func (q *Queries) SnapshotReleaseAliases(ctx context.Context, ids []int32) ([]byte, error) {
+	row := q.db.QueryRow(ctx, snapshotReleaseAliases, ids)
+	var column_1 []byte
+	err := row.Scan(&column_1)
+	return column_1, err
+}
+
+const snapshotReleaseGenres = `-- name: SnapshotReleaseGenres :one
+SELECT COALESCE(jsonb_agg(g), '[]')::jsonb FROM release_genres g WHERE g.release_id = ANY($1::int[])
+`
+
+func (q *Queries) SnapshotReleaseGenres(ctx context.Context, ids []int32) ([]byte, error) {
+	row := q.db.QueryRow(ctx, snapshotReleaseGenres, ids)
+	var column_1 []byte
+	err := row.Scan(&column_1)
+	return column_1, err
+}
+
+const snapshotReleases = `-- name: SnapshotReleases :one
+SELECT COALESCE(jsonb_agg(r), '[]')::jsonb FROM releases r WHERE r.id = ANY($1::int[])
+`
+
+func (q *Queries) SnapshotReleases(ctx context.Context, ids []int32) ([]byte, error) {
+	row := q.db.QueryRow(ctx, snapshotReleases, ids)
+	var column_1 []byte
+	err := row.Scan(&column_1)
+	return column_1, err
+}
+
+const snapshotTrackAliases = `-- name: SnapshotTrackAliases :one
+SELECT COALESCE(jsonb_agg(a), '[]')::jsonb FROM track_aliases a WHERE a.track_id = ANY($1::int[])
+`
+
+func (q *Queries) SnapshotTrackAliases(ctx context.Context, ids []int32) ([]byte, error) {
+	row := q.db.QueryRow(ctx, snapshotTrackAliases, ids)
+	var column_1 []byte
+	err := row.Scan(&column_1)
+	return column_1, err
+}
+
+const snapshotTracks = `-- name: SnapshotTracks :one
+SELECT COALESCE(jsonb_agg(t), '[]')::jsonb FROM tracks t WHERE t.id = ANY($1::int[])
+`
+
+func (q *Queries) SnapshotTracks(ctx context.Context, ids []int32) ([]byte, error) {
+	row := q.db.QueryRow(ctx, snapshotTracks, ids)
+	var column_1 []byte
+	err := row.Scan(&column_1)
+	return column_1, err
+}
+
+const unmoveArtistReleases = `-- name: UnmoveArtistReleases :exec
+DELETE FROM artist_releases ar
+USING jsonb_populate_recordset(NULL::artist_releases, $1::jsonb) r
+WHERE ar.artist_id = $2::int
+  AND ar.release_id = r.release_id
+`
+
+type UnmoveArtistReleasesParams struct {
+	Data []byte
+	ToID int32
+}
+
+func (q *Queries) UnmoveArtistReleases(ctx context.Context, arg UnmoveArtistReleasesParams) error {
+	_, err := q.db.Exec(ctx, unmoveArtistReleases, arg.Data, arg.ToID)
+	return err
+}
+
+const unmoveArtistTracks = `-- name: UnmoveArtistTracks :exec
+DELETE FROM artist_tracks at
+USING jsonb_populate_recordset(NULL::artist_tracks, $1::jsonb) r
+WHERE at.artist_id = $2::int
+  AND at.track_id = r.track_id
+`
+
+type UnmoveArtistTracksParams struct {
+	Data []byte
+	ToID int32
+}
+
+func (q *Queries) UnmoveArtistTracks(ctx context.Context, arg UnmoveArtistTracksParams) error {
+	_, err := q.db.Exec(ctx, unmoveArtistTracks, arg.Data, arg.ToID)
+	return err
+}
+
+const unmoveListens = `-- name: UnmoveListens :exec
+DELETE FROM listens l
+USING jsonb_populate_recordset(NULL::listens, $1::jsonb) r
+WHERE l.track_id = $2::int
+  AND l.user_id = r.user_id
+  AND l.listened_at = r.listened_at
+`
+
+type UnmoveListensParams struct {
+	Data []byte
+	ToID int32
+}
+
+// removes listens that a merge moved onto to_id, so that they can be restored to their track
+func (q *Queries) UnmoveListens(ctx context.Context, arg UnmoveListensParams) error {
+	_, err := q.db.Exec(ctx, unmoveListens, arg.Data, arg.ToID)
+	return err
+}
+
+const unmoveTracks = `-- name: UnmoveTracks :exec
+UPDATE tracks SET release_id = $1::int
+WHERE id = ANY($2::int[]) AND release_id = $3::int
+`
+
+type UnmoveTracksParams struct {
+	FromID int32
+	Ids    []int32
+	ToID   int32
+}
+
+func (q *Queries) UnmoveTracks(ctx context.Context, arg UnmoveTracksParams) error {
+	_, err := q.db.Exec(ctx, unmoveTracks, arg.FromID, arg.Ids, arg.ToID)
+	return err
+}
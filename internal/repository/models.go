@@ -56,11 +56,17 @@ func (ns NullRole) Value() (driver.Value, error) {
 }
 
 type ApiKey struct {
-	ID        int32
-	Key       string
-	UserID    int32
-	CreatedAt pgtype.Timestamp
-	Label     string
+	ID           int32
+	KeyHash      string
+	UserID       int32
+	CreatedAt    pgtype.Timestamp
+	Label        string
+	Scope        string
+	ExpiresAt    pgtype.Timestamptz
+	LastUsedAt   pgtype.Timestamptz
+	LastUsedIp   pgtype.Text
+	Prefix       string
+	LegacyDigest pgtype.Text
 }
 
 type Artist struct {
@@ -92,6 +98,7 @@ type ArtistTrack struct {
 	ArtistID  int32
 	TrackID   int32
 	IsPrimary bool
+	Role      string
 }
 
 type ArtistsWithName struct {
@@ -102,6 +109,24 @@ type ArtistsWithName struct {
 	Name          string
 }
 
+type AudioscrobblerSession struct {
+	TokenHash string
+	ApiKeyID  int32
+	CreatedAt time.Time
+}
+
+type AuditLog struct {
+	ID         int64
+	UserID     pgtype.Int4
+	Username   string
+	Action     string
+	TargetType string
+	TargetIds  []int32
+	Before     []byte
+	After      []byte
+	CreatedAt  time.Time
+}
+
 type Genre struct {
 	ID   int32
 	Name string
@@ -114,6 +139,46 @@ type Listen struct {
 	UserID     int32
 }
 
+type MergeSnapshot struct {
+	ID        int32
+	Kind      string
+	FromID    int32
+	ToID      int32
+	Snapshot  []byte
+	CreatedAt time.Time
+	UndoneAt  pgtype.Timestamptz
+}
+
+type RelayOutbox struct {
+	ID            int32
+	UserID        int32
+	Payload       []byte
+	Status        string
+	Attempts      int32
+	NextAttemptAt time.Time
+	LastError     pgtype.Text
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+	TargetID      pgtype.Int4
+}
+
+type RelayTarget struct {
+	ID              int32
+	UserID          int32
+	Kind            string
+	Label           string
+	Url             string
+	Token           string
+	ApiKey          string
+	ApiSecret       string
+	Enabled         bool
+	RelayImports    bool
+	RelayPlayingNow bool
+	IncludeClients  []string
+	ExcludeClients  []string
+	CreatedAt       time.Time
+}
+
 type Release struct {
 	ID                    int32
 	MusicBrainzID         *uuid.UUID
@@ -121,6 +186,7 @@ type Release struct {
 	VariousArtists        bool
 	ImageSource           pgtype.Text
 	MusicbrainzSearchedAt pgtype.Timestamptz
+	ReleaseGroupID        pgtype.Int4
 }
 
 type ReleaseAlias struct {
@@ -135,13 +201,35 @@ type ReleaseGenre struct {
 	GenreID   int32
 }
 
+type ReleaseGroup struct {
+	ID            int32
+	MusicBrainzID *uuid.UUID
+	Title         string
+}
+
 type ReleasesWithTitle struct {
-	ID             int32
-	MusicBrainzID  *uuid.UUID
-	Image          *uuid.UUID
-	VariousArtists bool
-	ImageSource    pgtype.Text
-	Title          string
+	ID                    int32
+	MusicBrainzID         *uuid.UUID
+	Image                 *uuid.UUID
+	VariousArtists        bool
+	ImageSource           pgtype.Text
+	MusicbrainzSearchedAt pgtype.Timestamptz
+	ReleaseGroupID        pgtype.Int4
+	Title                 string
+}
+
+type RewriteRule struct {
+	ID          int32
+	UserID      int32
+	Position    int32
+	Enabled     bool
+	Field       string
+	MatchType   string
+	Pattern     string
+	Action      string
+	TargetField string
+	Replacement string
+	CreatedAt   time.Time
 }
 
 type Session struct {
@@ -150,6 +238,9 @@ type Session struct {
 	CreatedAt  time.Time
 	ExpiresAt  time.Time
 	Persistent bool
+	UserAgent  string
+	Ip         string
+	LastSeenAt time.Time
 }
 
 type Track struct {
@@ -175,8 +266,27 @@ type TracksWithTitle struct {
 }
 
 type User struct {
-	ID       int32
-	Username string
-	Role     Role
-	Password []byte
+	ID                int32
+	Username          string
+	Role              Role
+	Password          []byte
+	Disabled          bool
+	CreatedAt         time.Time
+	Visibility        string
+	OidcSubject       pgtype.Text
+	TotpSecret        pgtype.Text
+	TotpEnabled       bool
+	TotpLastStep      pgtype.Int8
+	TotpRecoveryCodes []string
+}
+
+type UserInvite struct {
+	ID        int32
+	Token     string
+	Role      Role
+	CreatedBy pgtype.Int4
+	CreatedAt time.Time
+	ExpiresAt time.Time
+	UsedBy    pgtype.Int4
+	UsedAt    pgtype.Timestamptz
 }
@@ -20,6 +20,7 @@ WITH past_stats AS (
         MAX(listened_at) AS last_listened_at
     FROM listens l
     WHERE l.listened_at BETWEEN $1 AND $2
+      AND l.user_id = $4
     GROUP BY track_id
     HAVING COUNT(*) >= 5
 ),
@@ -27,6 +28,7 @@ recent_listens AS (
     SELECT DISTINCT track_id
     FROM listens
     WHERE listened_at > $2
+      AND user_id = $4
 )
 SELECT 
     t.id AS track_id,
@@ -49,6 +51,7 @@ type GetTracksToRevisitParams struct {
 	ListenedAt   time.Time
 	ListenedAt_2 time.Time
 	Limit        int32
+	UserID       int32
 }
 
 type GetTracksToRevisitRow struct {
@@ -62,7 +65,12 @@ type GetTracksToRevisitRow struct {
 }
 
 func (q *Queries) GetTracksToRevisit(ctx context.Context, arg GetTracksToRevisitParams) ([]GetTracksToRevisitRow, error) {
-	rows, err := q.db.Query(ctx, getTracksToRevisit, arg.ListenedAt, arg.ListenedAt_2, arg.Limit)
+	rows, err := q.db.Query(ctx, getTracksToRevisit,
+		arg.ListenedAt,
+		arg.ListenedAt_2,
+		arg.Limit,
+		arg.UserID,
+	)
 	if err != nil {
 		return nil, err
 	}
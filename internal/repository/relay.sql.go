@@ -0,0 +1,430 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: relay.sql
+
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const countRelayEntries = `-- name: CountRelayEntries :one
+SELECT COUNT(*) FROM relay_outbox
+WHERE ($1::text = '' OR status = $1::text)
+`
+
+func (q *Queries) CountRelayEntries(ctx context.Context, status string) (int64, error) {
+	row := q.db.QueryRow(ctx, countRelayEntries, status)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const deleteRelayEntriesByStatus = `-- name: DeleteRelayEntriesByStatus :execrows
+DELETE FROM relay_outbox WHERE status = $1
+`
+
+func (q *Queries) DeleteRelayEntriesByStatus(ctx context.Context, status string) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteRelayEntriesByStatus, status)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const deleteRelayEntry = `-- name: DeleteRelayEntry :execrows
+DELETE FROM relay_outbox WHERE id = $1
+`
+
+func (q *Queries) DeleteRelayEntry(ctx context.Context, id int32) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteRelayEntry, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const deleteRelayTarget = `-- name: DeleteRelayTarget :execrows
+DELETE FROM relay_targets WHERE id = $1 AND user_id = $2
+`
+
+type DeleteRelayTargetParams struct {
+	ID     int32
+	UserID int32
+}
+
+func (q *Queries) DeleteRelayTarget(ctx context.Context, arg DeleteRelayTargetParams) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteRelayTarget, arg.ID, arg.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const getDueRelayEntries = `-- name: GetDueRelayEntries :many
+SELECT id, user_id, payload, status, attempts, next_attempt_at, last_error, created_at, updated_at, target_id FROM relay_outbox
+WHERE status = 'pending' AND next_attempt_at <= NOW()
+ORDER BY id
+LIMIT $1
+`
+
+func (q *Queries) GetDueRelayEntries(ctx context.Context, limit int32) ([]RelayOutbox, error) {
+	rows, err := q.db.Query(ctx, getDueRelayEntries, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []RelayOutbox
+	for rows.Next() {
+		var i RelayOutbox
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.Payload,
+			&i.Status,
+			&i.Attempts,
+			&i.NextAttemptAt,
+			&i.LastError,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.TargetID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getRelayEntriesPaginated = `-- name: GetRelayEntriesPaginated :many
+SELECT id, user_id, payload, status, attempts, next_attempt_at, last_error, created_at, updated_at, target_id FROM relay_outbox
+WHERE ($1::text = '' OR status = $1::text)
+ORDER BY id DESC
+LIMIT $3::int OFFSET $2::int
+`
+
+type GetRelayEntriesPaginatedParams struct {
+	Status      string
+	OffsetCount int32
+	LimitCount  int32
+}
+
+func (q *Queries) GetRelayEntriesPaginated(ctx context.Context, arg GetRelayEntriesPaginatedParams) ([]RelayOutbox, error) {
+	rows, err := q.db.Query(ctx, getRelayEntriesPaginated, arg.Status, arg.OffsetCount, arg.LimitCount)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []RelayOutbox
+	for rows.Next() {
+		var i RelayOutbox
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.Payload,
+			&i.Status,
+			&i.Attempts,
+			&i.NextAttemptAt,
+			&i.LastError,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.TargetID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getRelayEntry = `-- name: GetRelayEntry :one
+SELECT id, user_id, payload, status, attempts, next_attempt_at, last_error, created_at, updated_at, target_id FROM relay_outbox WHERE id = $1
+`
+
+func (q *Queries) GetRelayEntry(ctx context.Context, id int32) (RelayOutbox, error) {
+	row := q.db.QueryRow(ctx, getRelayEntry, id)
+	var i RelayOutbox
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.Payload,
+		&i.Status,
+		&i.Attempts,
+		&i.NextAttemptAt,
+		&i.LastError,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.TargetID,
+	)
+	return i, err
+}
+
+const getRelayTarget = `-- name: GetRelayTarget :one
+SELECT id, user_id, kind, label, url, token, api_key, api_secret, enabled, relay_imports, relay_playing_now, include_clients, exclude_clients, created_at FROM relay_targets WHERE id = $1
+`
+
+func (q *Queries) GetRelayTarget(ctx context.Context, id int32) (RelayTarget, error) {
+	row := q.db.QueryRow(ctx, getRelayTarget, id)
+	var i RelayTarget
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.Kind,
+		&i.Label,
+		&i.Url,
+		&i.Token,
+		&i.ApiKey,
+		&i.ApiSecret,
+		&i.Enabled,
+		&i.RelayImports,
+		&i.RelayPlayingNow,
+		&i.IncludeClients,
+		&i.ExcludeClients,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const getRelayTargetsByUserID = `-- name: GetRelayTargetsByUserID :many
+SELECT id, user_id, kind, label, url, token, api_key, api_secret, enabled, relay_imports, relay_playing_now, include_clients, exclude_clients, created_at FROM relay_targets
+WHERE user_id = $1
+ORDER BY id
+`
+
+func (q *Queries) GetRelayTargetsByUserID(ctx context.Context, userID int32) ([]RelayTarget, error) {
+	rows, err := q.db.Query(ctx, getRelayTargetsByUserID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []RelayTarget
+	for rows.Next() {
+		var i RelayTarget
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.Kind,
+			&i.Label,
+			&i.Url,
+			&i.Token,
+			&i.ApiKey,
+			&i.ApiSecret,
+			&i.Enabled,
+			&i.RelayImports,
+			&i.RelayPlayingNow,
+			&i.IncludeClients,
+			&i.ExcludeClients,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const insertRelayEntry = `-- name: InsertRelayEntry :one
+INSERT INTO relay_outbox (user_id, target_id, payload)
+VALUES ($1, $2, $3)
+RETURNING id, user_id, payload, status, attempts, next_attempt_at, last_error, created_at, updated_at, target_id
+`
+
+type InsertRelayEntryParams struct {
+	UserID   int32
+	TargetID pgtype.Int4
+	Payload  []byte
+}
+
+func (q *Queries) InsertRelayEntry(ctx context.Context, arg InsertRelayEntryParams) (RelayOutbox, error) {
+	row := q.db.QueryRow(ctx, insertRelayEntry, arg.UserID, arg.TargetID, arg.Payload)
+	var i RelayOutbox
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.Payload,
+		&i.Status,
+		&i.Attempts,
+		&i.NextAttemptAt,
+		&i.LastError,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.TargetID,
+	)
+	return i, err
+}
+
+const insertRelayTarget = `-- name: InsertRelayTarget :one
+INSERT INTO relay_targets (
+    user_id, kind, label, url, token, api_key, api_secret,
+    enabled, relay_imports, relay_playing_now, include_clients, exclude_clients
+)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+RETURNING id, user_id, kind, label, url, token, api_key, api_secret, enabled, relay_imports, relay_playing_now, include_clients, exclude_clients, created_at
+`
+
+type InsertRelayTargetParams struct {
+	UserID          int32
+	Kind            string
+	Label           string
+	Url             string
+	Token           string
+	ApiKey          string
+	ApiSecret       string
+	Enabled         bool
+	RelayImports    bool
+	RelayPlayingNow bool
+	IncludeClients  []string
+	ExcludeClients  []string
+}
+
+func (q *Queries) InsertRelayTarget(ctx context.Context, arg InsertRelayTargetParams) (RelayTarget, error) {
+	row := q.db.QueryRow(ctx, insertRelayTarget,
+		arg.UserID,
+		arg.Kind,
+		arg.Label,
+		arg.Url,
+		arg.Token,
+		arg.ApiKey,
+		arg.ApiSecret,
+		arg.Enabled,
+		arg.RelayImports,
+		arg.RelayPlayingNow,
+		arg.IncludeClients,
+		arg.ExcludeClients,
+	)
+	var i RelayTarget
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.Kind,
+		&i.Label,
+		&i.Url,
+		&i.Token,
+		&i.ApiKey,
+		&i.ApiSecret,
+		&i.Enabled,
+		&i.RelayImports,
+		&i.RelayPlayingNow,
+		&i.IncludeClients,
+		&i.ExcludeClients,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const retryDeadRelayEntries = `-- name: RetryDeadRelayEntries :execrows
+UPDATE relay_outbox
+SET status = 'pending', attempts = 0, next_attempt_at = NOW(), updated_at = NOW()
+WHERE status = 'dead'
+`
+
+func (q *Queries) RetryDeadRelayEntries(ctx context.Context) (int64, error) {
+	result, err := q.db.Exec(ctx, retryDeadRelayEntries)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const retryRelayEntry = `-- name: RetryRelayEntry :execrows
+UPDATE relay_outbox
+SET status = 'pending', attempts = 0, next_attempt_at = NOW(), updated_at = NOW()
+WHERE id = $1
+`
+
+func (q *Queries) RetryRelayEntry(ctx context.Context, id int32) (int64, error) {
+	result, err := q.db.Exec(ctx, retryRelayEntry, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const updateRelayEntryAttempt = `-- name: UpdateRelayEntryAttempt :exec
+UPDATE relay_outbox
+SET status = $2, attempts = $3, next_attempt_at = $4, last_error = $5, updated_at = NOW()
+WHERE id = $1
+`
+
+type UpdateRelayEntryAttemptParams struct {
+	ID            int32
+	Status        string
+	Attempts      int32
+	NextAttemptAt time.Time
+	LastError     pgtype.Text
+}
+
+func (q *Queries) UpdateRelayEntryAttempt(ctx context.Context, arg UpdateRelayEntryAttemptParams) error {
+	_, err := q.db.Exec(ctx, updateRelayEntryAttempt,
+		arg.ID,
+		arg.Status,
+		arg.Attempts,
+		arg.NextAttemptAt,
+		arg.LastError,
+	)
+	return err
+}
+
+const updateRelayTarget = `-- name: UpdateRelayTarget :execrows
+UPDATE relay_targets SET
+    label = $3,
+    url = $4,
+    token = $5,
+    api_key = $6,
+    api_secret = $7,
+    enabled = $8,
+    relay_imports = $9,
+    relay_playing_now = $10,
+    include_clients = $11,
+    exclude_clients = $12
+WHERE id = $1 AND user_id = $2
+`
+
+type UpdateRelayTargetParams struct {
+	ID              int32
+	UserID          int32
+	Label           string
+	Url             string
+	Token           string
+	ApiKey          string
+	ApiSecret       string
+	Enabled         bool
+	RelayImports    bool
+	RelayPlayingNow bool
+	IncludeClients  []string
+	ExcludeClients  []string
+}
+
+func (q *Queries) UpdateRelayTarget(ctx context.Context, arg UpdateRelayTargetParams) (int64, error) {
+	result, err := q.db.Exec(ctx, updateRelayTarget,
+		arg.ID,
+		arg.UserID,
+		arg.Label,
+		arg.Url,
+		arg.Token,
+		arg.ApiKey,
+		arg.ApiSecret,
+		arg.Enabled,
+		arg.RelayImports,
+		arg.RelayPlayingNow,
+		arg.IncludeClients,
+		arg.ExcludeClients,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
@@ -36,6 +36,7 @@ FROM (
   SELECT t.release_id
   FROM listens l
   JOIN tracks t ON l.track_id = t.id
+  WHERE l.user_id = $3
   GROUP BY t.release_id
   HAVING MIN(l.listened_at) BETWEEN $1 AND $2
 ) first_appearances
@@ -44,10 +45,11 @@ FROM (
 type CountNewReleasesParams struct {
 	ListenedAt   time.Time
 	ListenedAt_2 time.Time
+	UserID       int32
 }
 
 func (q *Queries) CountNewReleases(ctx context.Context, arg CountNewReleasesParams) (int64, error) {
-	row := q.db.QueryRow(ctx, countNewReleases, arg.ListenedAt, arg.ListenedAt_2)
+	row := q.db.QueryRow(ctx, countNewReleases, arg.ListenedAt, arg.ListenedAt_2, arg.UserID)
 	var total_count int64
 	err := row.Scan(&total_count)
 	return total_count, err
@@ -67,21 +69,55 @@ func (q *Queries) CountReleasesFromArtist(ctx context.Context, artistID int32) (
 	return count, err
 }
 
+const countTopReleaseGroups = `-- name: CountTopReleaseGroups :one
+SELECT COUNT(DISTINCT COALESCE(r.release_group_id, -r.id)) AS total_count
+FROM listens l
+JOIN tracks t ON l.track_id = t.id
+JOIN releases r ON t.release_id = r.id
+WHERE l.listened_at BETWEEN $1 AND $2
+  AND l.user_id = $3
+  AND ($4::int = 0 OR EXISTS (
+    SELECT 1 FROM artist_releases ar
+    WHERE ar.release_id = r.id AND ar.artist_id = $4::int
+  ))
+`
+
+type CountTopReleaseGroupsParams struct {
+	ListenedAtFrom time.Time
+	ListenedAtTo   time.Time
+	UserID         int32
+	ArtistID       int32
+}
+
+func (q *Queries) CountTopReleaseGroups(ctx context.Context, arg CountTopReleaseGroupsParams) (int64, error) {
+	row := q.db.QueryRow(ctx, countTopReleaseGroups,
+		arg.ListenedAtFrom,
+		arg.ListenedAtTo,
+		arg.UserID,
+		arg.ArtistID,
+	)
+	var total_count int64
+	err := row.Scan(&total_count)
+	return total_count, err
+}
+
 const countTopReleases = `-- name: CountTopReleases :one
 SELECT COUNT(DISTINCT r.id) AS total_count
 FROM listens l
 JOIN tracks t ON l.track_id = t.id
 JOIN releases r ON t.release_id = r.id
 WHERE l.listened_at BETWEEN $1 AND $2
+  AND l.user_id = $3
 `
 
 type CountTopReleasesParams struct {
 	ListenedAt   time.Time
 	ListenedAt_2 time.Time
+	UserID       int32
 }
 
 func (q *Queries) CountTopReleases(ctx context.Context, arg CountTopReleasesParams) (int64, error) {
-	row := q.db.QueryRow(ctx, countTopReleases, arg.ListenedAt, arg.ListenedAt_2)
+	row := q.db.QueryRow(ctx, countTopReleases, arg.ListenedAt, arg.ListenedAt_2, arg.UserID)
 	var total_count int64
 	err := row.Scan(&total_count)
 	return total_count, err
@@ -110,20 +146,22 @@ func (q *Queries) DeleteReleasesFromArtist(ctx context.Context, artistID int32)
 
 const getRelease = `-- name: GetRelease :one
 SELECT
-  id, musicbrainz_id, image, various_artists, image_source, title,
+  id, musicbrainz_id, image, various_artists, image_source, musicbrainz_searched_at, release_group_id, title,
   get_artists_for_release(id) AS artists
 FROM releases_with_title
 WHERE id = $1 LIMIT 1
 `
 
 type GetReleaseRow struct {
-	ID             int32
-	MusicBrainzID  *uuid.UUID
-	Image          *uuid.UUID
-	VariousArtists bool
-	ImageSource    pgtype.Text
-	Title          string
-	Artists        []byte
+	ID                    int32
+	MusicBrainzID         *uuid.UUID
+	Image                 *uuid.UUID
+	VariousArtists        bool
+	ImageSource           pgtype.Text
+	MusicbrainzSearchedAt pgtype.Timestamptz
+	ReleaseGroupID        pgtype.Int4
+	Title                 string
+	Artists               []byte
 }
 
 func (q *Queries) GetRelease(ctx context.Context, id int32) (GetReleaseRow, error) {
@@ -135,6 +173,8 @@ func (q *Queries) GetRelease(ctx context.Context, id int32) (GetReleaseRow, erro
 		&i.Image,
 		&i.VariousArtists,
 		&i.ImageSource,
+		&i.MusicbrainzSearchedAt,
+		&i.ReleaseGroupID,
 		&i.Title,
 		&i.Artists,
 	)
@@ -155,26 +195,32 @@ FROM (
             COUNT(*) AS listen_count
         FROM listens l
         JOIN tracks t ON l.track_id = t.id
+        WHERE l.user_id = $2
         GROUP BY t.release_id
         ) x
     )
 WHERE release_id = $1
 `
 
+type GetReleaseAllTimeRankParams struct {
+	ReleaseID int32
+	UserID    int32
+}
+
 type GetReleaseAllTimeRankRow struct {
 	ReleaseID int32
 	Rank      int64
 }
 
-func (q *Queries) GetReleaseAllTimeRank(ctx context.Context, releaseID int32) (GetReleaseAllTimeRankRow, error) {
-	row := q.db.QueryRow(ctx, getReleaseAllTimeRank, releaseID)
+func (q *Queries) GetReleaseAllTimeRank(ctx context.Context, arg GetReleaseAllTimeRankParams) (GetReleaseAllTimeRankRow, error) {
+	row := q.db.QueryRow(ctx, getReleaseAllTimeRank, arg.ReleaseID, arg.UserID)
 	var i GetReleaseAllTimeRankRow
 	err := row.Scan(&i.ReleaseID, &i.Rank)
 	return i, err
 }
 
 const getReleaseByArtistAndTitle = `-- name: GetReleaseByArtistAndTitle :one
-SELECT r.id, r.musicbrainz_id, r.image, r.various_artists, r.image_source, r.title
+SELECT r.id, r.musicbrainz_id, r.image, r.various_artists, r.image_source, r.musicbrainz_searched_at, r.release_group_id, r.title
 FROM releases_with_title r
 JOIN artist_releases ar ON r.id = ar.release_id
 WHERE r.title = $1 AND ar.artist_id = $2
@@ -195,13 +241,15 @@ func (q *Queries) GetReleaseByArtistAndTitle(ctx context.Context, arg GetRelease
 		&i.Image,
 		&i.VariousArtists,
 		&i.ImageSource,
+		&i.MusicbrainzSearchedAt,
+		&i.ReleaseGroupID,
 		&i.Title,
 	)
 	return i, err
 }
 
 const getReleaseByArtistAndTitles = `-- name: GetReleaseByArtistAndTitles :one
-SELECT r.id, r.musicbrainz_id, r.image, r.various_artists, r.image_source, r.title
+SELECT r.id, r.musicbrainz_id, r.image, r.various_artists, r.image_source, r.musicbrainz_searched_at, r.release_group_id, r.title
 FROM releases_with_title r
 JOIN artist_releases ar ON r.id = ar.release_id
 WHERE r.title = ANY ($1::TEXT[]) AND ar.artist_id = $2
@@ -222,13 +270,15 @@ func (q *Queries) GetReleaseByArtistAndTitles(ctx context.Context, arg GetReleas
 		&i.Image,
 		&i.VariousArtists,
 		&i.ImageSource,
+		&i.MusicbrainzSearchedAt,
+		&i.ReleaseGroupID,
 		&i.Title,
 	)
 	return i, err
 }
 
 const getReleaseByArtistAndTitlesNoMbzID = `-- name: GetReleaseByArtistAndTitlesNoMbzID :one
-SELECT r.id, r.musicbrainz_id, r.image, r.various_artists, r.image_source, r.title
+SELECT r.id, r.musicbrainz_id, r.image, r.various_artists, r.image_source, r.musicbrainz_searched_at, r.release_group_id, r.title
 FROM releases_with_title r
 JOIN artist_releases ar ON r.id = ar.release_id
 WHERE r.title = ANY ($1::TEXT[])
@@ -255,13 +305,15 @@ func (q *Queries) GetReleaseByArtistAndTitlesNoMbzID(ctx context.Context, arg Ge
 		&i.Image,
 		&i.VariousArtists,
 		&i.ImageSource,
+		&i.MusicbrainzSearchedAt,
+		&i.ReleaseGroupID,
 		&i.Title,
 	)
 	return i, err
 }
 
 const getReleaseByImageID = `-- name: GetReleaseByImageID :one
-SELECT id, musicbrainz_id, image, various_artists, image_source, musicbrainz_searched_at FROM releases
+SELECT id, musicbrainz_id, image, various_artists, image_source, musicbrainz_searched_at, release_group_id FROM releases
 WHERE image = $1 LIMIT 1
 `
 
@@ -275,12 +327,13 @@ func (q *Queries) GetReleaseByImageID(ctx context.Context, image *uuid.UUID) (Re
 		&i.VariousArtists,
 		&i.ImageSource,
 		&i.MusicbrainzSearchedAt,
+		&i.ReleaseGroupID,
 	)
 	return i, err
 }
 
 const getReleaseByMbzID = `-- name: GetReleaseByMbzID :one
-SELECT id, musicbrainz_id, image, various_artists, image_source, title FROM releases_with_title
+SELECT id, musicbrainz_id, image, various_artists, image_source, musicbrainz_searched_at, release_group_id, title FROM releases_with_title
 WHERE musicbrainz_id = $1 LIMIT 1
 `
 
@@ -293,14 +346,106 @@ func (q *Queries) GetReleaseByMbzID(ctx context.Context, musicbrainzID *uuid.UUI
 		&i.Image,
 		&i.VariousArtists,
 		&i.ImageSource,
+		&i.MusicbrainzSearchedAt,
+		&i.ReleaseGroupID,
 		&i.Title,
 	)
 	return i, err
 }
 
+const getReleaseGroup = `-- name: GetReleaseGroup :one
+SELECT id, musicbrainz_id, title FROM release_groups
+WHERE id = $1 LIMIT 1
+`
+
+func (q *Queries) GetReleaseGroup(ctx context.Context, id int32) (ReleaseGroup, error) {
+	row := q.db.QueryRow(ctx, getReleaseGroup, id)
+	var i ReleaseGroup
+	err := row.Scan(&i.ID, &i.MusicBrainzID, &i.Title)
+	return i, err
+}
+
+const getReleaseGroupByMbzID = `-- name: GetReleaseGroupByMbzID :one
+SELECT id, musicbrainz_id, title FROM release_groups
+WHERE musicbrainz_id = $1 LIMIT 1
+`
+
+func (q *Queries) GetReleaseGroupByMbzID(ctx context.Context, musicbrainzID *uuid.UUID) (ReleaseGroup, error) {
+	row := q.db.QueryRow(ctx, getReleaseGroupByMbzID, musicbrainzID)
+	var i ReleaseGroup
+	err := row.Scan(&i.ID, &i.MusicBrainzID, &i.Title)
+	return i, err
+}
+
+const getReleaseGroupEditions = `-- name: GetReleaseGroupEditions :many
+SELECT
+  r.id,
+  r.musicbrainz_id,
+  r.title,
+  r.image,
+  r.various_artists,
+  r.release_group_id,
+  get_artists_for_release(r.id) AS artists,
+  (
+    SELECT COUNT(*)
+    FROM listens l
+    JOIN tracks t ON l.track_id = t.id
+    WHERE t.release_id = r.id
+      AND l.user_id = $1
+  ) AS listen_count
+FROM releases_with_title r
+WHERE r.release_group_id = $2::int
+ORDER BY listen_count DESC, r.id
+`
+
+type GetReleaseGroupEditionsParams struct {
+	UserID         int32
+	ReleaseGroupID int32
+}
+
+type GetReleaseGroupEditionsRow struct {
+	ID             int32
+	MusicBrainzID  *uuid.UUID
+	Title          string
+	Image          *uuid.UUID
+	VariousArtists bool
+	ReleaseGroupID pgtype.Int4
+	Artists        []byte
+	ListenCount    int64
+}
+
+func (q *Queries) GetReleaseGroupEditions(ctx context.Context, arg GetReleaseGroupEditionsParams) ([]GetReleaseGroupEditionsRow, error) {
+	rows, err := q.db.Query(ctx, getReleaseGroupEditions, arg.UserID, arg.ReleaseGroupID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetReleaseGroupEditionsRow
+	for rows.Next() {
+		var i GetReleaseGroupEditionsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.MusicBrainzID,
+			&i.Title,
+			&i.Image,
+			&i.VariousArtists,
+			&i.ReleaseGroupID,
+			&i.Artists,
+			&i.ListenCount,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const getReleasesWithoutImages = `-- name: GetReleasesWithoutImages :many
 SELECT
-  r.id, r.musicbrainz_id, r.image, r.various_artists, r.image_source, r.title,
+  r.id, r.musicbrainz_id, r.image, r.various_artists, r.image_source, r.musicbrainz_searched_at, r.release_group_id, r.title,
   get_artists_for_release(r.id) AS artists
 FROM releases_with_title r
 WHERE r.image IS NULL
@@ -315,13 +460,15 @@ type GetReleasesWithoutImagesParams struct {
 }
 
 type GetReleasesWithoutImagesRow struct {
-	ID             int32
-	MusicBrainzID  *uuid.UUID
-	Image          *uuid.UUID
-	VariousArtists bool
-	ImageSource    pgtype.Text
-	Title          string
-	Artists        []byte
+	ID                    int32
+	MusicBrainzID         *uuid.UUID
+	Image                 *uuid.UUID
+	VariousArtists        bool
+	ImageSource           pgtype.Text
+	MusicbrainzSearchedAt pgtype.Timestamptz
+	ReleaseGroupID        pgtype.Int4
+	Title                 string
+	Artists               []byte
 }
 
 func (q *Queries) GetReleasesWithoutImages(ctx context.Context, arg GetReleasesWithoutImagesParams) ([]GetReleasesWithoutImagesRow, error) {
@@ -339,6 +486,8 @@ func (q *Queries) GetReleasesWithoutImages(ctx context.Context, arg GetReleasesW
 			&i.Image,
 			&i.VariousArtists,
 			&i.ImageSource,
+			&i.MusicbrainzSearchedAt,
+			&i.ReleaseGroupID,
 			&i.Title,
 			&i.Artists,
 		); err != nil {
@@ -393,14 +542,120 @@ func (q *Queries) GetReleasesWithoutMbzID(ctx context.Context, arg GetReleasesWi
 	return items, nil
 }
 
+const getTopReleaseGroupsPaginated = `-- name: GetTopReleaseGroupsPaginated :many
+WITH editions AS (
+  SELECT
+    r.id,
+    r.release_group_id,
+    COUNT(*) AS listen_count
+  FROM listens l
+  JOIN tracks t ON l.track_id = t.id
+  JOIN releases r ON t.release_id = r.id
+  WHERE l.listened_at BETWEEN $3 AND $4
+    AND l.user_id = $5
+    AND ($6::int = 0 OR EXISTS (
+      SELECT 1 FROM artist_releases ar
+      WHERE ar.release_id = r.id AND ar.artist_id = $6::int
+    ))
+  GROUP BY r.id
+), groups AS (
+  SELECT
+    (ARRAY_AGG(e.id ORDER BY e.listen_count DESC, e.id))[1]::int AS release_id,
+    SUM(e.listen_count)::bigint AS listen_count
+  FROM editions e
+  GROUP BY COALESCE(e.release_group_id, -e.id)
+)
+SELECT
+  r.id,
+  r.musicbrainz_id,
+  COALESCE(rg.title, r.title)::text AS title,
+  r.image,
+  r.various_artists,
+  r.release_group_id,
+  g.listen_count,
+  (
+    SELECT COUNT(*) FROM releases e
+    WHERE e.release_group_id = r.release_group_id
+  ) AS edition_count,
+  get_artists_for_release(r.id) AS artists,
+  RANK() OVER (ORDER BY g.listen_count DESC) AS rank
+FROM groups g
+JOIN releases_with_title r ON r.id = g.release_id
+LEFT JOIN release_groups rg ON rg.id = r.release_group_id
+ORDER BY g.listen_count DESC, r.id
+LIMIT $2 OFFSET $1
+`
+
+type GetTopReleaseGroupsPaginatedParams struct {
+	OffsetCount    int32
+	LimitCount     int32
+	ListenedAtFrom time.Time
+	ListenedAtTo   time.Time
+	UserID         int32
+	ArtistID       int32
+}
+
+type GetTopReleaseGroupsPaginatedRow struct {
+	ID             int32
+	MusicBrainzID  *uuid.UUID
+	Title          string
+	Image          *uuid.UUID
+	VariousArtists bool
+	ReleaseGroupID pgtype.Int4
+	ListenCount    int64
+	EditionCount   int64
+	Artists        []byte
+	Rank           int64
+}
+
+// Releases that are not part of a release group are their own group. Each group is
+// represented by its most listened to edition.
+func (q *Queries) GetTopReleaseGroupsPaginated(ctx context.Context, arg GetTopReleaseGroupsPaginatedParams) ([]GetTopReleaseGroupsPaginatedRow, error) {
+	rows, err := q.db.Query(ctx, getTopReleaseGroupsPaginated,
+		arg.OffsetCount,
+		arg.LimitCount,
+		arg.ListenedAtFrom,
+		arg.ListenedAtTo,
+		arg.UserID,
+		arg.ArtistID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetTopReleaseGroupsPaginatedRow
+	for rows.Next() {
+		var i GetTopReleaseGroupsPaginatedRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.MusicBrainzID,
+			&i.Title,
+			&i.Image,
+			&i.VariousArtists,
+			&i.ReleaseGroupID,
+			&i.ListenCount,
+			&i.EditionCount,
+			&i.Artists,
+			&i.Rank,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const getTopReleasesFromArtist = `-- name: GetTopReleasesFromArtist :many
 SELECT
-  x.id, x.musicbrainz_id, x.image, x.various_artists, x.image_source, x.title, x.listen_count,
+  x.id, x.musicbrainz_id, x.image, x.various_artists, x.image_source, x.musicbrainz_searched_at, x.release_group_id, x.title, x.listen_count,
   get_artists_for_release(x.id) AS artists,
   RANK() OVER (ORDER BY x.listen_count DESC) AS rank
 FROM (
     SELECT
-        r.id, r.musicbrainz_id, r.image, r.various_artists, r.image_source, r.title,
+        r.id, r.musicbrainz_id, r.image, r.various_artists, r.image_source, r.musicbrainz_searched_at, r.release_group_id, r.title,
         COUNT(*) AS listen_count
     FROM listens l
     JOIN tracks t ON l.track_id = t.id
@@ -408,6 +663,7 @@ FROM (
     JOIN artist_releases ar ON r.id = ar.release_id
     WHERE ar.artist_id = $5
     AND l.listened_at BETWEEN $1 AND $2
+    AND l.user_id = $6
     GROUP BY r.id, r.title, r.musicbrainz_id, r.various_artists, r.image, r.image_source
 ) x
 ORDER BY listen_count DESC, x.id
@@ -420,18 +676,21 @@ type GetTopReleasesFromArtistParams struct {
 	Limit        int32
 	Offset       int32
 	ArtistID     int32
+	UserID       int32
 }
 
 type GetTopReleasesFromArtistRow struct {
-	ID             int32
-	MusicBrainzID  *uuid.UUID
-	Image          *uuid.UUID
-	VariousArtists bool
-	ImageSource    pgtype.Text
-	Title          string
-	ListenCount    int64
-	Artists        []byte
-	Rank           int64
+	ID                    int32
+	MusicBrainzID         *uuid.UUID
+	Image                 *uuid.UUID
+	VariousArtists        bool
+	ImageSource           pgtype.Text
+	MusicbrainzSearchedAt pgtype.Timestamptz
+	ReleaseGroupID        pgtype.Int4
+	Title                 string
+	ListenCount           int64
+	Artists               []byte
+	Rank                  int64
 }
 
 func (q *Queries) GetTopReleasesFromArtist(ctx context.Context, arg GetTopReleasesFromArtistParams) ([]GetTopReleasesFromArtistRow, error) {
@@ -441,6 +700,7 @@ func (q *Queries) GetTopReleasesFromArtist(ctx context.Context, arg GetTopReleas
 		arg.Limit,
 		arg.Offset,
 		arg.ArtistID,
+		arg.UserID,
 	)
 	if err != nil {
 		return nil, err
@@ -455,6 +715,8 @@ func (q *Queries) GetTopReleasesFromArtist(ctx context.Context, arg GetTopReleas
 			&i.Image,
 			&i.VariousArtists,
 			&i.ImageSource,
+			&i.MusicbrainzSearchedAt,
+			&i.ReleaseGroupID,
 			&i.Title,
 			&i.ListenCount,
 			&i.Artists,
@@ -472,17 +734,18 @@ func (q *Queries) GetTopReleasesFromArtist(ctx context.Context, arg GetTopReleas
 
 const getTopReleasesPaginated = `-- name: GetTopReleasesPaginated :many
 SELECT
-  x.id, x.musicbrainz_id, x.image, x.various_artists, x.image_source, x.title, x.listen_count,
+  x.id, x.musicbrainz_id, x.image, x.various_artists, x.image_source, x.musicbrainz_searched_at, x.release_group_id, x.title, x.listen_count,
   get_artists_for_release(x.id) AS artists,
   RANK() OVER (ORDER BY x.listen_count DESC) AS rank
 FROM (
     SELECT
-        r.id, r.musicbrainz_id, r.image, r.various_artists, r.image_source, r.title,
+        r.id, r.musicbrainz_id, r.image, r.various_artists, r.image_source, r.musicbrainz_searched_at, r.release_group_id, r.title,
         COUNT(*) AS listen_count
     FROM listens l
     JOIN tracks t ON l.track_id = t.id
     JOIN releases_with_title r ON t.release_id = r.id
     WHERE l.listened_at BETWEEN $1 AND $2
+    AND l.user_id = $5
     GROUP BY r.id, r.title, r.musicbrainz_id, r.various_artists, r.image, r.image_source
 ) x
 ORDER BY listen_count DESC, x.id
@@ -494,18 +757,21 @@ type GetTopReleasesPaginatedParams struct {
 	ListenedAt_2 time.Time
 	Limit        int32
 	Offset       int32
+	UserID       int32
 }
 
 type GetTopReleasesPaginatedRow struct {
-	ID             int32
-	MusicBrainzID  *uuid.UUID
-	Image          *uuid.UUID
-	VariousArtists bool
-	ImageSource    pgtype.Text
-	Title          string
-	ListenCount    int64
-	Artists        []byte
-	Rank           int64
+	ID                    int32
+	MusicBrainzID         *uuid.UUID
+	Image                 *uuid.UUID
+	VariousArtists        bool
+	ImageSource           pgtype.Text
+	MusicbrainzSearchedAt pgtype.Timestamptz
+	ReleaseGroupID        pgtype.Int4
+	Title                 string
+	ListenCount           int64
+	Artists               []byte
+	Rank                  int64
 }
 
 func (q *Queries) GetTopReleasesPaginated(ctx context.Context, arg GetTopReleasesPaginatedParams) ([]GetTopReleasesPaginatedRow, error) {
@@ -514,6 +780,7 @@ func (q *Queries) GetTopReleasesPaginated(ctx context.Context, arg GetTopRelease
 		arg.ListenedAt_2,
 		arg.Limit,
 		arg.Offset,
+		arg.UserID,
 	)
 	if err != nil {
 		return nil, err
@@ -528,6 +795,8 @@ func (q *Queries) GetTopReleasesPaginated(ctx context.Context, arg GetTopRelease
 			&i.Image,
 			&i.VariousArtists,
 			&i.ImageSource,
+			&i.MusicbrainzSearchedAt,
+			&i.ReleaseGroupID,
 			&i.Title,
 			&i.ListenCount,
 			&i.Artists,
@@ -546,7 +815,7 @@ func (q *Queries) GetTopReleasesPaginated(ctx context.Context, arg GetTopRelease
 const insertRelease = `-- name: InsertRelease :one
 INSERT INTO releases (musicbrainz_id, various_artists, image, image_source)
 VALUES ($1, $2, $3, $4)
-RETURNING id, musicbrainz_id, image, various_artists, image_source, musicbrainz_searched_at
+RETURNING id, musicbrainz_id, image, various_artists, image_source, musicbrainz_searched_at, release_group_id
 `
 
 type InsertReleaseParams struct {
@@ -571,10 +840,30 @@ func (q *Queries) InsertRelease(ctx context.Context, arg InsertReleaseParams) (R
 		&i.VariousArtists,
 		&i.ImageSource,
 		&i.MusicbrainzSearchedAt,
+		&i.ReleaseGroupID,
 	)
 	return i, err
 }
 
+const insertReleaseGroup = `-- name: InsertReleaseGroup :one
+INSERT INTO release_groups (musicbrainz_id, title)
+VALUES ($1, $2)
+ON CONFLICT (musicbrainz_id) DO UPDATE SET musicbrainz_id = EXCLUDED.musicbrainz_id
+RETURNING id, musicbrainz_id, title
+`
+
+type InsertReleaseGroupParams struct {
+	MusicBrainzID *uuid.UUID
+	Title         string
+}
+
+func (q *Queries) InsertReleaseGroup(ctx context.Context, arg InsertReleaseGroupParams) (ReleaseGroup, error) {
+	row := q.db.QueryRow(ctx, insertReleaseGroup, arg.MusicBrainzID, arg.Title)
+	var i ReleaseGroup
+	err := row.Scan(&i.ID, &i.MusicBrainzID, &i.Title)
+	return i, err
+}
+
 const markMbzSearched = `-- name: MarkMbzSearched :exec
 UPDATE releases SET musicbrainz_searched_at = NOW() WHERE id = $1
 `
@@ -631,6 +920,36 @@ func (q *Queries) UpdateReleasePrimaryArtist(ctx context.Context, arg UpdateRele
 	return err
 }
 
+const updateReleaseReleaseGroup = `-- name: UpdateReleaseReleaseGroup :exec
+UPDATE releases SET release_group_id = $2
+WHERE id = $1
+`
+
+type UpdateReleaseReleaseGroupParams struct {
+	ID             int32
+	ReleaseGroupID pgtype.Int4
+}
+
+func (q *Queries) UpdateReleaseReleaseGroup(ctx context.Context, arg UpdateReleaseReleaseGroupParams) error {
+	_, err := q.db.Exec(ctx, updateReleaseReleaseGroup, arg.ID, arg.ReleaseGroupID)
+	return err
+}
+
+const updateReleaseReleaseGroupIfUnset = `-- name: UpdateReleaseReleaseGroupIfUnset :exec
+UPDATE releases SET release_group_id = $2
+WHERE id = $1 AND release_group_id IS NULL
+`
+
+type UpdateReleaseReleaseGroupIfUnsetParams struct {
+	ID             int32
+	ReleaseGroupID pgtype.Int4
+}
+
+func (q *Queries) UpdateReleaseReleaseGroupIfUnset(ctx context.Context, arg UpdateReleaseReleaseGroupIfUnsetParams) error {
+	_, err := q.db.Exec(ctx, updateReleaseReleaseGroupIfUnset, arg.ID, arg.ReleaseGroupID)
+	return err
+}
+
 const updateReleaseVariousArtists = `-- name: UpdateReleaseVariousArtists :exec
 UPDATE releases SET various_artists = $2
 WHERE id = $1
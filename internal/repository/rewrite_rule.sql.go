@@ -0,0 +1,182 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: rewrite_rule.sql
+
+package repository
+
+import (
+	"context"
+)
+
+const deleteRewriteRule = `-- name: DeleteRewriteRule :execrows
+DELETE FROM rewrite_rules WHERE id = $1 AND user_id = $2
+`
+
+type DeleteRewriteRuleParams struct {
+	ID     int32
+	UserID int32
+}
+
+func (q *Queries) DeleteRewriteRule(ctx context.Context, arg DeleteRewriteRuleParams) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteRewriteRule, arg.ID, arg.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const getRewriteRule = `-- name: GetRewriteRule :one
+SELECT id, user_id, position, enabled, field, match_type, pattern, action, target_field, replacement, created_at FROM rewrite_rules WHERE id = $1
+`
+
+func (q *Queries) GetRewriteRule(ctx context.Context, id int32) (RewriteRule, error) {
+	row := q.db.QueryRow(ctx, getRewriteRule, id)
+	var i RewriteRule
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.Position,
+		&i.Enabled,
+		&i.Field,
+		&i.MatchType,
+		&i.Pattern,
+		&i.Action,
+		&i.TargetField,
+		&i.Replacement,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const getRewriteRulesByUserID = `-- name: GetRewriteRulesByUserID :many
+SELECT id, user_id, position, enabled, field, match_type, pattern, action, target_field, replacement, created_at FROM rewrite_rules
+WHERE user_id = $1
+ORDER BY position, id
+`
+
+func (q *Queries) GetRewriteRulesByUserID(ctx context.Context, userID int32) ([]RewriteRule, error) {
+	rows, err := q.db.Query(ctx, getRewriteRulesByUserID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []RewriteRule
+	for rows.Next() {
+		var i RewriteRule
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.Position,
+			&i.Enabled,
+			&i.Field,
+			&i.MatchType,
+			&i.Pattern,
+			&i.Action,
+			&i.TargetField,
+			&i.Replacement,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const insertRewriteRule = `-- name: InsertRewriteRule :one
+INSERT INTO rewrite_rules (user_id, position, enabled, field, match_type, pattern, action, target_field, replacement)
+VALUES (
+    $1,
+    (SELECT COALESCE(MAX(position), 0) + 1 FROM rewrite_rules WHERE user_id = $1),
+    $2, $3, $4, $5, $6, $7, $8
+)
+RETURNING id, user_id, position, enabled, field, match_type, pattern, action, target_field, replacement, created_at
+`
+
+type InsertRewriteRuleParams struct {
+	UserID      int32
+	Enabled     bool
+	Field       string
+	MatchType   string
+	Pattern     string
+	Action      string
+	TargetField string
+	Replacement string
+}
+
+func (q *Queries) InsertRewriteRule(ctx context.Context, arg InsertRewriteRuleParams) (RewriteRule, error) {
+	row := q.db.QueryRow(ctx, insertRewriteRule,
+		arg.UserID,
+		arg.Enabled,
+		arg.Field,
+		arg.MatchType,
+		arg.Pattern,
+		arg.Action,
+		arg.TargetField,
+		arg.Replacement,
+	)
+	var i RewriteRule
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.Position,
+		&i.Enabled,
+		&i.Field,
+		&i.MatchType,
+		&i.Pattern,
+		&i.Action,
+		&i.TargetField,
+		&i.Replacement,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const updateRewriteRule = `-- name: UpdateRewriteRule :execrows
+UPDATE rewrite_rules SET
+    position = $3,
+    enabled = $4,
+    field = $5,
+    match_type = $6,
+    pattern = $7,
+    action = $8,
+    target_field = $9,
+    replacement = $10
+WHERE id = $1 AND user_id = $2
+`
+
+type UpdateRewriteRuleParams struct {
+	ID          int32
+	UserID      int32
+	Position    int32
+	Enabled     bool
+	Field       string
+	MatchType   string
+	Pattern     string
+	Action      string
+	TargetField string
+	Replacement string
+}
+
+func (q *Queries) UpdateRewriteRule(ctx context.Context, arg UpdateRewriteRuleParams) (int64, error) {
+	result, err := q.db.Exec(ctx, updateRewriteRule,
+		arg.ID,
+		arg.UserID,
+		arg.Position,
+		arg.Enabled,
+		arg.Field,
+		arg.MatchType,
+		arg.Pattern,
+		arg.Action,
+		arg.TargetField,
+		arg.Replacement,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
@@ -10,8 +10,47 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
 )
 
+const deleteAudioscrobblerSessionsBefore = `-- name: DeleteAudioscrobblerSessionsBefore :exec
+DELETE FROM audioscrobbler_sessions WHERE created_at <= $1::timestamptz
+`
+
+func (q *Queries) DeleteAudioscrobblerSessionsBefore(ctx context.Context, before time.Time) error {
+	_, err := q.db.Exec(ctx, deleteAudioscrobblerSessionsBefore, before)
+	return err
+}
+
+const deleteExpiredSessions = `-- name: DeleteExpiredSessions :execrows
+DELETE FROM sessions WHERE expires_at <= NOW()
+`
+
+func (q *Queries) DeleteExpiredSessions(ctx context.Context) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteExpiredSessions)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const deleteOtherSessionsForUser = `-- name: DeleteOtherSessionsForUser :execrows
+DELETE FROM sessions WHERE user_id = $1 AND id <> $2
+`
+
+type DeleteOtherSessionsForUserParams struct {
+	UserID int32
+	ID     uuid.UUID
+}
+
+func (q *Queries) DeleteOtherSessionsForUser(ctx context.Context, arg DeleteOtherSessionsForUserParams) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteOtherSessionsForUser, arg.UserID, arg.ID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
 const deleteSession = `-- name: DeleteSession :exec
 DELETE FROM sessions WHERE id = $1
 `
@@ -21,8 +60,34 @@ func (q *Queries) DeleteSession(ctx context.Context, id uuid.UUID) error {
 	return err
 }
 
+const deleteSessionForUser = `-- name: DeleteSessionForUser :execrows
+DELETE FROM sessions WHERE id = $1 AND user_id = $2
+`
+
+type DeleteSessionForUserParams struct {
+	ID     uuid.UUID
+	UserID int32
+}
+
+func (q *Queries) DeleteSessionForUser(ctx context.Context, arg DeleteSessionForUserParams) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteSessionForUser, arg.ID, arg.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const deleteSessionsForUser = `-- name: DeleteSessionsForUser :exec
+DELETE FROM sessions WHERE user_id = $1
+`
+
+func (q *Queries) DeleteSessionsForUser(ctx context.Context, userID int32) error {
+	_, err := q.db.Exec(ctx, deleteSessionsForUser, userID)
+	return err
+}
+
 const getSession = `-- name: GetSession :one
-SELECT id, user_id, created_at, expires_at, persistent FROM sessions WHERE id = $1 AND expires_at > NOW()
+SELECT id, user_id, created_at, expires_at, persistent, user_agent, ip, last_seen_at FROM sessions WHERE id = $1 AND expires_at > NOW()
 `
 
 func (q *Queries) GetSession(ctx context.Context, id uuid.UUID) (Session, error) {
@@ -34,27 +99,113 @@ func (q *Queries) GetSession(ctx context.Context, id uuid.UUID) (Session, error)
 		&i.CreatedAt,
 		&i.ExpiresAt,
 		&i.Persistent,
+		&i.UserAgent,
+		&i.Ip,
+		&i.LastSeenAt,
+	)
+	return i, err
+}
+
+const getSessionsByUserID = `-- name: GetSessionsByUserID :many
+SELECT id, user_id, created_at, expires_at, persistent, user_agent, ip, last_seen_at FROM sessions
+WHERE user_id = $1 AND expires_at > NOW()
+ORDER BY last_seen_at DESC
+`
+
+func (q *Queries) GetSessionsByUserID(ctx context.Context, userID int32) ([]Session, error) {
+	rows, err := q.db.Query(ctx, getSessionsByUserID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Session
+	for rows.Next() {
+		var i Session
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.CreatedAt,
+			&i.ExpiresAt,
+			&i.Persistent,
+			&i.UserAgent,
+			&i.Ip,
+			&i.LastSeenAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getUserByAudioscrobblerSession = `-- name: GetUserByAudioscrobblerSession :one
+SELECT u.id, u.username, u.role, u.password, u.disabled, u.created_at, u.visibility, u.oidc_subject, u.totp_secret, u.totp_enabled, u.totp_last_step, u.totp_recovery_codes
+FROM users u
+JOIN api_keys ak ON u.id = ak.user_id
+JOIN audioscrobbler_sessions s ON ak.id = s.api_key_id
+WHERE s.token_hash = $1::text
+  AND s.created_at > $2::timestamptz
+  AND NOT u.disabled
+  AND ak.scope <> 'read'
+  AND (ak.expires_at IS NULL OR ak.expires_at > NOW())
+`
+
+type GetUserByAudioscrobblerSessionParams struct {
+	TokenHash string
+	NotBefore time.Time
+}
+
+func (q *Queries) GetUserByAudioscrobblerSession(ctx context.Context, arg GetUserByAudioscrobblerSessionParams) (User, error) {
+	row := q.db.QueryRow(ctx, getUserByAudioscrobblerSession, arg.TokenHash, arg.NotBefore)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Username,
+		&i.Role,
+		&i.Password,
+		&i.Disabled,
+		&i.CreatedAt,
+		&i.Visibility,
+		&i.OidcSubject,
+		&i.TotpSecret,
+		&i.TotpEnabled,
+		&i.TotpLastStep,
+		&i.TotpRecoveryCodes,
 	)
 	return i, err
 }
 
 const getUserBySession = `-- name: GetUserBySession :one
-SELECT u.id, username, role, password, s.id, user_id, created_at, expires_at, persistent 
+SELECT u.id, username, role, password, disabled, u.created_at, visibility, oidc_subject, totp_secret, totp_enabled, totp_last_step, totp_recovery_codes, s.id, user_id, s.created_at, expires_at, persistent, user_agent, ip, last_seen_at 
 FROM users u
 JOIN sessions s ON u.id = s.user_id 
-WHERE s.id = $1
+WHERE s.id = $1 AND s.expires_at > NOW() AND NOT u.disabled
 `
 
 type GetUserBySessionRow struct {
-	ID         int32
-	Username   string
-	Role       Role
-	Password   []byte
-	ID_2       uuid.UUID
-	UserID     int32
-	CreatedAt  time.Time
-	ExpiresAt  time.Time
-	Persistent bool
+	ID                int32
+	Username          string
+	Role              Role
+	Password          []byte
+	Disabled          bool
+	CreatedAt         time.Time
+	Visibility        string
+	OidcSubject       pgtype.Text
+	TotpSecret        pgtype.Text
+	TotpEnabled       bool
+	TotpLastStep      pgtype.Int8
+	TotpRecoveryCodes []string
+	ID_2              uuid.UUID
+	UserID            int32
+	CreatedAt_2       time.Time
+	ExpiresAt         time.Time
+	Persistent        bool
+	UserAgent         string
+	Ip                string
+	LastSeenAt        time.Time
 }
 
 func (q *Queries) GetUserBySession(ctx context.Context, id uuid.UUID) (GetUserBySessionRow, error) {
@@ -65,19 +216,45 @@ func (q *Queries) GetUserBySession(ctx context.Context, id uuid.UUID) (GetUserBy
 		&i.Username,
 		&i.Role,
 		&i.Password,
+		&i.Disabled,
+		&i.CreatedAt,
+		&i.Visibility,
+		&i.OidcSubject,
+		&i.TotpSecret,
+		&i.TotpEnabled,
+		&i.TotpLastStep,
+		&i.TotpRecoveryCodes,
 		&i.ID_2,
 		&i.UserID,
-		&i.CreatedAt,
+		&i.CreatedAt_2,
 		&i.ExpiresAt,
 		&i.Persistent,
+		&i.UserAgent,
+		&i.Ip,
+		&i.LastSeenAt,
 	)
 	return i, err
 }
 
+const insertAudioscrobblerSession = `-- name: InsertAudioscrobblerSession :exec
+INSERT INTO audioscrobbler_sessions (token_hash, api_key_id)
+VALUES ($1, $2)
+`
+
+type InsertAudioscrobblerSessionParams struct {
+	TokenHash string
+	ApiKeyID  int32
+}
+
+func (q *Queries) InsertAudioscrobblerSession(ctx context.Context, arg InsertAudioscrobblerSessionParams) error {
+	_, err := q.db.Exec(ctx, insertAudioscrobblerSession, arg.TokenHash, arg.ApiKeyID)
+	return err
+}
+
 const insertSession = `-- name: InsertSession :one
-INSERT INTO sessions (id, user_id, expires_at, persistent)
-VALUES ($1, $2, $3, $4)
-RETURNING id, user_id, created_at, expires_at, persistent
+INSERT INTO sessions (id, user_id, expires_at, persistent, user_agent, ip)
+VALUES ($1, $2, $3, $4, $5, $6)
+RETURNING id, user_id, created_at, expires_at, persistent, user_agent, ip, last_seen_at
 `
 
 type InsertSessionParams struct {
@@ -85,6 +262,8 @@ type InsertSessionParams struct {
 	UserID     int32
 	ExpiresAt  time.Time
 	Persistent bool
+	UserAgent  string
+	Ip         string
 }
 
 func (q *Queries) InsertSession(ctx context.Context, arg InsertSessionParams) (Session, error) {
@@ -93,6 +272,8 @@ func (q *Queries) InsertSession(ctx context.Context, arg InsertSessionParams) (S
 		arg.UserID,
 		arg.ExpiresAt,
 		arg.Persistent,
+		arg.UserAgent,
+		arg.Ip,
 	)
 	var i Session
 	err := row.Scan(
@@ -101,20 +282,32 @@ func (q *Queries) InsertSession(ctx context.Context, arg InsertSessionParams) (S
 		&i.CreatedAt,
 		&i.ExpiresAt,
 		&i.Persistent,
+		&i.UserAgent,
+		&i.Ip,
+		&i.LastSeenAt,
 	)
 	return i, err
 }
 
 const updateSessionExpiry = `-- name: UpdateSessionExpiry :exec
-UPDATE sessions SET expires_at = $2 WHERE id = $1
+UPDATE sessions
+SET expires_at = $2, last_seen_at = NOW(), user_agent = $3, ip = $4
+WHERE id = $1
 `
 
 type UpdateSessionExpiryParams struct {
 	ID        uuid.UUID
 	ExpiresAt time.Time
+	UserAgent string
+	Ip        string
 }
 
 func (q *Queries) UpdateSessionExpiry(ctx context.Context, arg UpdateSessionExpiryParams) error {
-	_, err := q.db.Exec(ctx, updateSessionExpiry, arg.ID, arg.ExpiresAt)
+	_, err := q.db.Exec(ctx, updateSessionExpiry,
+		arg.ID,
+		arg.ExpiresAt,
+		arg.UserAgent,
+		arg.Ip,
+	)
 	return err
 }
@@ -13,8 +13,8 @@ import (
 )
 
 const associateArtistToTrack = `-- name: AssociateArtistToTrack :exec
-INSERT INTO artist_tracks (artist_id, track_id, is_primary)
-VALUES ($1, $2, $3)
+INSERT INTO artist_tracks (artist_id, track_id, is_primary, role)
+VALUES ($1, $2, $3, $4)
 ON CONFLICT DO NOTHING
 `
 
@@ -22,10 +22,16 @@ type AssociateArtistToTrackParams struct {
 	ArtistID  int32
 	TrackID   int32
 	IsPrimary bool
+	Role      string
 }
 
 func (q *Queries) AssociateArtistToTrack(ctx context.Context, arg AssociateArtistToTrackParams) error {
-	_, err := q.db.Exec(ctx, associateArtistToTrack, arg.ArtistID, arg.TrackID, arg.IsPrimary)
+	_, err := q.db.Exec(ctx, associateArtistToTrack,
+		arg.ArtistID,
+		arg.TrackID,
+		arg.IsPrimary,
+		arg.Role,
+	)
 	return err
 }
 
@@ -34,6 +40,7 @@ SELECT COUNT(*) AS total_count
 FROM (
   SELECT track_id
   FROM listens
+  WHERE user_id = $3
   GROUP BY track_id
   HAVING MIN(listened_at) BETWEEN $1 AND $2
 ) first_appearances
@@ -42,10 +49,11 @@ FROM (
 type CountNewTracksParams struct {
 	ListenedAt   time.Time
 	ListenedAt_2 time.Time
+	UserID       int32
 }
 
 func (q *Queries) CountNewTracks(ctx context.Context, arg CountNewTracksParams) (int64, error) {
-	row := q.db.QueryRow(ctx, countNewTracks, arg.ListenedAt, arg.ListenedAt_2)
+	row := q.db.QueryRow(ctx, countNewTracks, arg.ListenedAt, arg.ListenedAt_2, arg.UserID)
 	var total_count int64
 	err := row.Scan(&total_count)
 	return total_count, err
@@ -55,15 +63,17 @@ const countTopTracks = `-- name: CountTopTracks :one
 SELECT COUNT(DISTINCT l.track_id) AS total_count
 FROM listens l
 WHERE l.listened_at BETWEEN $1 AND $2
+  AND l.user_id = $3
 `
 
 type CountTopTracksParams struct {
 	ListenedAt   time.Time
 	ListenedAt_2 time.Time
+	UserID       int32
 }
 
 func (q *Queries) CountTopTracks(ctx context.Context, arg CountTopTracksParams) (int64, error) {
-	row := q.db.QueryRow(ctx, countTopTracks, arg.ListenedAt, arg.ListenedAt_2)
+	row := q.db.QueryRow(ctx, countTopTracks, arg.ListenedAt, arg.ListenedAt_2, arg.UserID)
 	var total_count int64
 	err := row.Scan(&total_count)
 	return total_count, err
@@ -75,16 +85,26 @@ FROM listens l
 JOIN artist_tracks at ON l.track_id = at.track_id
 WHERE l.listened_at BETWEEN $1 AND $2
 AND at.artist_id = $3
+AND l.user_id = $4
+AND at.role <> $5
 `
 
 type CountTopTracksByArtistParams struct {
 	ListenedAt   time.Time
 	ListenedAt_2 time.Time
 	ArtistID     int32
+	UserID       int32
+	Role         string
 }
 
 func (q *Queries) CountTopTracksByArtist(ctx context.Context, arg CountTopTracksByArtistParams) (int64, error) {
-	row := q.db.QueryRow(ctx, countTopTracksByArtist, arg.ListenedAt, arg.ListenedAt_2, arg.ArtistID)
+	row := q.db.QueryRow(ctx, countTopTracksByArtist,
+		arg.ListenedAt,
+		arg.ListenedAt_2,
+		arg.ArtistID,
+		arg.UserID,
+		arg.Role,
+	)
 	var total_count int64
 	err := row.Scan(&total_count)
 	return total_count, err
@@ -96,16 +116,23 @@ FROM listens l
 JOIN tracks t ON l.track_id = t.id
 WHERE l.listened_at BETWEEN $1 AND $2
 AND t.release_id = $3
+AND l.user_id = $4
 `
 
 type CountTopTracksByReleaseParams struct {
 	ListenedAt   time.Time
 	ListenedAt_2 time.Time
 	ReleaseID    int32
+	UserID       int32
 }
 
 func (q *Queries) CountTopTracksByRelease(ctx context.Context, arg CountTopTracksByReleaseParams) (int64, error) {
-	row := q.db.QueryRow(ctx, countTopTracksByRelease, arg.ListenedAt, arg.ListenedAt_2, arg.ReleaseID)
+	row := q.db.QueryRow(ctx, countTopTracksByRelease,
+		arg.ListenedAt,
+		arg.ListenedAt_2,
+		arg.ReleaseID,
+		arg.UserID,
+	)
 	var total_count int64
 	err := row.Scan(&total_count)
 	return total_count, err
@@ -172,6 +199,8 @@ FROM (
     JOIN artist_tracks at ON l.track_id = at.track_id
     WHERE l.listened_at BETWEEN $1 AND $2
         AND at.artist_id = $5
+        AND l.user_id = $6
+        AND at.role <> $7
     GROUP BY l.track_id
     ORDER BY listen_count DESC
     LIMIT $3 OFFSET $4
@@ -187,6 +216,8 @@ type GetTopTracksByArtistPaginatedParams struct {
 	Limit        int32
 	Offset       int32
 	ArtistID     int32
+	UserID       int32
+	Role         string
 }
 
 type GetTopTracksByArtistPaginatedRow struct {
@@ -207,6 +238,8 @@ func (q *Queries) GetTopTracksByArtistPaginated(ctx context.Context, arg GetTopT
 		arg.Limit,
 		arg.Offset,
 		arg.ArtistID,
+		arg.UserID,
+		arg.Role,
 	)
 	if err != nil {
 		return nil, err
@@ -254,6 +287,7 @@ FROM (
     JOIN tracks t ON l.track_id = t.id
     WHERE l.listened_at BETWEEN $1 AND $2
         AND t.release_id = $5
+        AND l.user_id = $6
     GROUP BY l.track_id
     ORDER BY listen_count DESC
     LIMIT $3 OFFSET $4
@@ -269,6 +303,7 @@ type GetTopTracksInReleasePaginatedParams struct {
 	Limit        int32
 	Offset       int32
 	ReleaseID    int32
+	UserID       int32
 }
 
 type GetTopTracksInReleasePaginatedRow struct {
@@ -289,6 +324,7 @@ func (q *Queries) GetTopTracksInReleasePaginated(ctx context.Context, arg GetTop
 		arg.Limit,
 		arg.Offset,
 		arg.ReleaseID,
+		arg.UserID,
 	)
 	if err != nil {
 		return nil, err
@@ -334,6 +370,7 @@ FROM (
         RANK() OVER (ORDER BY COUNT(*) DESC) as rank
     FROM listens
     WHERE listened_at BETWEEN $1 AND $2
+        AND user_id = $5
     GROUP BY track_id
     ORDER BY listen_count DESC
     LIMIT $3 OFFSET $4
@@ -348,6 +385,7 @@ type GetTopTracksPaginatedParams struct {
 	ListenedAt_2 time.Time
 	Limit        int32
 	Offset       int32
+	UserID       int32
 }
 
 type GetTopTracksPaginatedRow struct {
@@ -367,6 +405,7 @@ func (q *Queries) GetTopTracksPaginated(ctx context.Context, arg GetTopTracksPag
 		arg.ListenedAt_2,
 		arg.Limit,
 		arg.Offset,
+		arg.UserID,
 	)
 	if err != nil {
 		return nil, err
@@ -444,18 +483,24 @@ FROM (
             COUNT(*) AS listen_count
         FROM listens l
         JOIN tracks_with_title t ON l.track_id = t.id
+        WHERE l.user_id = $2
         GROUP BY t.id) x
     ) y
 WHERE id = $1
 `
 
+type GetTrackAllTimeRankParams struct {
+	ID     int32
+	UserID int32
+}
+
 type GetTrackAllTimeRankRow struct {
 	ID   int32
 	Rank int64
 }
 
-func (q *Queries) GetTrackAllTimeRank(ctx context.Context, id int32) (GetTrackAllTimeRankRow, error) {
-	row := q.db.QueryRow(ctx, getTrackAllTimeRank, id)
+func (q *Queries) GetTrackAllTimeRank(ctx context.Context, arg GetTrackAllTimeRankParams) (GetTrackAllTimeRankRow, error) {
+	row := q.db.QueryRow(ctx, getTrackAllTimeRank, arg.ID, arg.UserID)
 	var i GetTrackAllTimeRankRow
 	err := row.Scan(&i.ID, &i.Rank)
 	return i, err
@@ -485,6 +530,7 @@ FROM tracks_with_title t
 JOIN artist_tracks at ON at.track_id = t.id
 WHERE t.title = $1
   AND at.artist_id = ANY($3::int[])
+  AND at.role = 'main'
   AND t.release_id = $2
 GROUP BY t.id, t.title, t.musicbrainz_id, t.duration, t.release_id
 HAVING COUNT(DISTINCT at.artist_id) = cardinality($3::int[])
@@ -626,6 +672,25 @@ func (q *Queries) UpdateReleaseForAll(ctx context.Context, arg UpdateReleaseForA
 	return err
 }
 
+const updateTrackArtistRole = `-- name: UpdateTrackArtistRole :execrows
+UPDATE artist_tracks SET role = $3
+WHERE artist_id = $1 AND track_id = $2
+`
+
+type UpdateTrackArtistRoleParams struct {
+	ArtistID int32
+	TrackID  int32
+	Role     string
+}
+
+func (q *Queries) UpdateTrackArtistRole(ctx context.Context, arg UpdateTrackArtistRoleParams) (int64, error) {
+	result, err := q.db.Exec(ctx, updateTrackArtistRole, arg.ArtistID, arg.TrackID, arg.Role)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
 const updateTrackDuration = `-- name: UpdateTrackDuration :exec
 UPDATE tracks SET duration = $2
 WHERE id = $1
@@ -7,8 +7,33 @@ package repository
 
 import (
 	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
 )
 
+const claimUserInvite = `-- name: ClaimUserInvite :one
+UPDATE user_invites SET used_at = NOW()
+WHERE token = $1 AND used_at IS NULL AND expires_at > NOW()
+RETURNING id, token, role, created_by, created_at, expires_at, used_by, used_at
+`
+
+func (q *Queries) ClaimUserInvite(ctx context.Context, token string) (UserInvite, error) {
+	row := q.db.QueryRow(ctx, claimUserInvite, token)
+	var i UserInvite
+	err := row.Scan(
+		&i.ID,
+		&i.Token,
+		&i.Role,
+		&i.CreatedBy,
+		&i.CreatedAt,
+		&i.ExpiresAt,
+		&i.UsedBy,
+		&i.UsedAt,
+	)
+	return i, err
+}
+
 const countApiKeys = `-- name: CountApiKeys :one
 SELECT COUNT(*) FROM api_keys WHERE user_id = $1
 `
@@ -49,8 +74,44 @@ func (q *Queries) DeleteUser(ctx context.Context, id int32) error {
 	return err
 }
 
+const deleteUserInvite = `-- name: DeleteUserInvite :exec
+DELETE FROM user_invites WHERE id = $1
+`
+
+func (q *Queries) DeleteUserInvite(ctx context.Context, id int32) error {
+	_, err := q.db.Exec(ctx, deleteUserInvite, id)
+	return err
+}
+
+const disableUserTOTP = `-- name: DisableUserTOTP :exec
+UPDATE users
+SET totp_secret = NULL, totp_enabled = false, totp_last_step = NULL, totp_recovery_codes = '{}'
+WHERE id = $1
+`
+
+func (q *Queries) DisableUserTOTP(ctx context.Context, id int32) error {
+	_, err := q.db.Exec(ctx, disableUserTOTP, id)
+	return err
+}
+
+const enableUserTOTP = `-- name: EnableUserTOTP :exec
+UPDATE users
+SET totp_enabled = true, totp_recovery_codes = $2::text[]
+WHERE id = $1 AND totp_secret IS NOT NULL
+`
+
+type EnableUserTOTPParams struct {
+	ID            int32
+	RecoveryCodes []string
+}
+
+func (q *Queries) EnableUserTOTP(ctx context.Context, arg EnableUserTOTPParams) error {
+	_, err := q.db.Exec(ctx, enableUserTOTP, arg.ID, arg.RecoveryCodes)
+	return err
+}
+
 const getAllApiKeysByUserID = `-- name: GetAllApiKeysByUserID :many
-SELECT ak.id, ak.key, ak.user_id, ak.created_at, ak.label
+SELECT ak.id, ak.key_hash, ak.user_id, ak.created_at, ak.label, ak.scope, ak.expires_at, ak.last_used_at, ak.last_used_ip, ak.prefix, ak.legacy_digest
 FROM api_keys ak 
 JOIN users u ON ak.user_id = u.id 
 WHERE u.id = $1
@@ -67,10 +128,82 @@ func (q *Queries) GetAllApiKeysByUserID(ctx context.Context, id int32) ([]ApiKey
 		var i ApiKey
 		if err := rows.Scan(
 			&i.ID,
-			&i.Key,
+			&i.KeyHash,
 			&i.UserID,
 			&i.CreatedAt,
 			&i.Label,
+			&i.Scope,
+			&i.ExpiresAt,
+			&i.LastUsedAt,
+			&i.LastUsedIp,
+			&i.Prefix,
+			&i.LegacyDigest,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getApiKeyByHash = `-- name: GetApiKeyByHash :one
+SELECT ak.id, ak.key_hash, ak.user_id, ak.created_at, ak.label, ak.scope, ak.expires_at, ak.last_used_at, ak.last_used_ip, ak.prefix, ak.legacy_digest
+FROM api_keys ak
+JOIN users u ON ak.user_id = u.id
+WHERE ak.key_hash = $1 AND NOT u.disabled
+  AND (ak.expires_at IS NULL OR ak.expires_at > NOW())
+`
+
+func (q *Queries) GetApiKeyByHash(ctx context.Context, keyHash string) (ApiKey, error) {
+	row := q.db.QueryRow(ctx, getApiKeyByHash, keyHash)
+	var i ApiKey
+	err := row.Scan(
+		&i.ID,
+		&i.KeyHash,
+		&i.UserID,
+		&i.CreatedAt,
+		&i.Label,
+		&i.Scope,
+		&i.ExpiresAt,
+		&i.LastUsedAt,
+		&i.LastUsedIp,
+		&i.Prefix,
+		&i.LegacyDigest,
+	)
+	return i, err
+}
+
+const getPublicUsers = `-- name: GetPublicUsers :many
+SELECT id, username, role, password, disabled, created_at, visibility, oidc_subject, totp_secret, totp_enabled, totp_last_step, totp_recovery_codes FROM users
+WHERE visibility = 'public' AND NOT disabled
+ORDER BY username
+`
+
+func (q *Queries) GetPublicUsers(ctx context.Context) ([]User, error) {
+	rows, err := q.db.Query(ctx, getPublicUsers)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []User
+	for rows.Next() {
+		var i User
+		if err := rows.Scan(
+			&i.ID,
+			&i.Username,
+			&i.Role,
+			&i.Password,
+			&i.Disabled,
+			&i.CreatedAt,
+			&i.Visibility,
+			&i.OidcSubject,
+			&i.TotpSecret,
+			&i.TotpEnabled,
+			&i.TotpLastStep,
+			&i.TotpRecoveryCodes,
 		); err != nil {
 			return nil, err
 		}
@@ -83,26 +216,83 @@ func (q *Queries) GetAllApiKeysByUserID(ctx context.Context, id int32) ([]ApiKey
 }
 
 const getUserByApiKey = `-- name: GetUserByApiKey :one
-SELECT u.id, u.username, u.role, u.password 
+SELECT u.id, u.username, u.role, u.password, u.disabled, u.created_at, u.visibility, u.oidc_subject, u.totp_secret, u.totp_enabled, u.totp_last_step, u.totp_recovery_codes 
 FROM users u
 JOIN api_keys ak ON u.id = ak.user_id 
-WHERE ak.key = $1
+WHERE ak.key_hash = $1 AND NOT u.disabled
+  AND (ak.expires_at IS NULL OR ak.expires_at > NOW())
+`
+
+func (q *Queries) GetUserByApiKey(ctx context.Context, keyHash string) (User, error) {
+	row := q.db.QueryRow(ctx, getUserByApiKey, keyHash)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Username,
+		&i.Role,
+		&i.Password,
+		&i.Disabled,
+		&i.CreatedAt,
+		&i.Visibility,
+		&i.OidcSubject,
+		&i.TotpSecret,
+		&i.TotpEnabled,
+		&i.TotpLastStep,
+		&i.TotpRecoveryCodes,
+	)
+	return i, err
+}
+
+const getUserByID = `-- name: GetUserByID :one
+SELECT id, username, role, password, disabled, created_at, visibility, oidc_subject, totp_secret, totp_enabled, totp_last_step, totp_recovery_codes FROM users WHERE id = $1
+`
+
+func (q *Queries) GetUserByID(ctx context.Context, id int32) (User, error) {
+	row := q.db.QueryRow(ctx, getUserByID, id)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Username,
+		&i.Role,
+		&i.Password,
+		&i.Disabled,
+		&i.CreatedAt,
+		&i.Visibility,
+		&i.OidcSubject,
+		&i.TotpSecret,
+		&i.TotpEnabled,
+		&i.TotpLastStep,
+		&i.TotpRecoveryCodes,
+	)
+	return i, err
+}
+
+const getUserByOIDCSubject = `-- name: GetUserByOIDCSubject :one
+SELECT id, username, role, password, disabled, created_at, visibility, oidc_subject, totp_secret, totp_enabled, totp_last_step, totp_recovery_codes FROM users WHERE oidc_subject = $1
 `
 
-func (q *Queries) GetUserByApiKey(ctx context.Context, key string) (User, error) {
-	row := q.db.QueryRow(ctx, getUserByApiKey, key)
+func (q *Queries) GetUserByOIDCSubject(ctx context.Context, oidcSubject pgtype.Text) (User, error) {
+	row := q.db.QueryRow(ctx, getUserByOIDCSubject, oidcSubject)
 	var i User
 	err := row.Scan(
 		&i.ID,
 		&i.Username,
 		&i.Role,
 		&i.Password,
+		&i.Disabled,
+		&i.CreatedAt,
+		&i.Visibility,
+		&i.OidcSubject,
+		&i.TotpSecret,
+		&i.TotpEnabled,
+		&i.TotpLastStep,
+		&i.TotpRecoveryCodes,
 	)
 	return i, err
 }
 
 const getUserByUsername = `-- name: GetUserByUsername :one
-SELECT id, username, role, password FROM users WHERE username = $1
+SELECT id, username, role, password, disabled, created_at, visibility, oidc_subject, totp_secret, totp_enabled, totp_last_step, totp_recovery_codes FROM users WHERE username = $1
 `
 
 func (q *Queries) GetUserByUsername(ctx context.Context, username string) (User, error) {
@@ -113,31 +303,127 @@ func (q *Queries) GetUserByUsername(ctx context.Context, username string) (User,
 		&i.Username,
 		&i.Role,
 		&i.Password,
+		&i.Disabled,
+		&i.CreatedAt,
+		&i.Visibility,
+		&i.OidcSubject,
+		&i.TotpSecret,
+		&i.TotpEnabled,
+		&i.TotpLastStep,
+		&i.TotpRecoveryCodes,
 	)
 	return i, err
 }
 
+const getUserInvites = `-- name: GetUserInvites :many
+SELECT id, token, role, created_by, created_at, expires_at, used_by, used_at FROM user_invites ORDER BY created_at DESC
+`
+
+func (q *Queries) GetUserInvites(ctx context.Context) ([]UserInvite, error) {
+	rows, err := q.db.Query(ctx, getUserInvites)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []UserInvite
+	for rows.Next() {
+		var i UserInvite
+		if err := rows.Scan(
+			&i.ID,
+			&i.Token,
+			&i.Role,
+			&i.CreatedBy,
+			&i.CreatedAt,
+			&i.ExpiresAt,
+			&i.UsedBy,
+			&i.UsedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getUsers = `-- name: GetUsers :many
+SELECT id, username, role, password, disabled, created_at, visibility, oidc_subject, totp_secret, totp_enabled, totp_last_step, totp_recovery_codes FROM users ORDER BY id
+`
+
+func (q *Queries) GetUsers(ctx context.Context) ([]User, error) {
+	rows, err := q.db.Query(ctx, getUsers)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []User
+	for rows.Next() {
+		var i User
+		if err := rows.Scan(
+			&i.ID,
+			&i.Username,
+			&i.Role,
+			&i.Password,
+			&i.Disabled,
+			&i.CreatedAt,
+			&i.Visibility,
+			&i.OidcSubject,
+			&i.TotpSecret,
+			&i.TotpEnabled,
+			&i.TotpLastStep,
+			&i.TotpRecoveryCodes,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const insertApiKey = `-- name: InsertApiKey :one
-INSERT INTO api_keys (user_id, key, label)
-VALUES ($1, $2, $3)
-RETURNING id, key, user_id, created_at, label
+INSERT INTO api_keys (user_id, key_hash, prefix, legacy_digest, label, scope, expires_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7)
+RETURNING id, key_hash, user_id, created_at, label, scope, expires_at, last_used_at, last_used_ip, prefix, legacy_digest
 `
 
 type InsertApiKeyParams struct {
-	UserID int32
-	Key    string
-	Label  string
+	UserID       int32
+	KeyHash      string
+	Prefix       string
+	LegacyDigest pgtype.Text
+	Label        string
+	Scope        string
+	ExpiresAt    pgtype.Timestamptz
 }
 
 func (q *Queries) InsertApiKey(ctx context.Context, arg InsertApiKeyParams) (ApiKey, error) {
-	row := q.db.QueryRow(ctx, insertApiKey, arg.UserID, arg.Key, arg.Label)
+	row := q.db.QueryRow(ctx, insertApiKey,
+		arg.UserID,
+		arg.KeyHash,
+		arg.Prefix,
+		arg.LegacyDigest,
+		arg.Label,
+		arg.Scope,
+		arg.ExpiresAt,
+	)
 	var i ApiKey
 	err := row.Scan(
 		&i.ID,
-		&i.Key,
+		&i.KeyHash,
 		&i.UserID,
 		&i.CreatedAt,
 		&i.Label,
+		&i.Scope,
+		&i.ExpiresAt,
+		&i.LastUsedAt,
+		&i.LastUsedIp,
+		&i.Prefix,
+		&i.LegacyDigest,
 	)
 	return i, err
 }
@@ -145,7 +431,7 @@ func (q *Queries) InsertApiKey(ctx context.Context, arg InsertApiKeyParams) (Api
 const insertUser = `-- name: InsertUser :one
 INSERT INTO users (username, password, role)
 VALUES ($1, $2, $3)
-RETURNING id, username, role, password
+RETURNING id, username, role, password, disabled, created_at, visibility, oidc_subject, totp_secret, totp_enabled, totp_last_step, totp_recovery_codes
 `
 
 type InsertUserParams struct {
@@ -162,10 +448,68 @@ func (q *Queries) InsertUser(ctx context.Context, arg InsertUserParams) (User, e
 		&i.Username,
 		&i.Role,
 		&i.Password,
+		&i.Disabled,
+		&i.CreatedAt,
+		&i.Visibility,
+		&i.OidcSubject,
+		&i.TotpSecret,
+		&i.TotpEnabled,
+		&i.TotpLastStep,
+		&i.TotpRecoveryCodes,
 	)
 	return i, err
 }
 
+const insertUserInvite = `-- name: InsertUserInvite :one
+INSERT INTO user_invites (token, role, created_by, expires_at)
+VALUES ($1, $2, $3, $4)
+RETURNING id, token, role, created_by, created_at, expires_at, used_by, used_at
+`
+
+type InsertUserInviteParams struct {
+	Token     string
+	Role      Role
+	CreatedBy pgtype.Int4
+	ExpiresAt time.Time
+}
+
+func (q *Queries) InsertUserInvite(ctx context.Context, arg InsertUserInviteParams) (UserInvite, error) {
+	row := q.db.QueryRow(ctx, insertUserInvite,
+		arg.Token,
+		arg.Role,
+		arg.CreatedBy,
+		arg.ExpiresAt,
+	)
+	var i UserInvite
+	err := row.Scan(
+		&i.ID,
+		&i.Token,
+		&i.Role,
+		&i.CreatedBy,
+		&i.CreatedAt,
+		&i.ExpiresAt,
+		&i.UsedBy,
+		&i.UsedAt,
+	)
+	return i, err
+}
+
+const setUserTOTPSecret = `-- name: SetUserTOTPSecret :exec
+UPDATE users
+SET totp_secret = $2, totp_enabled = false, totp_last_step = NULL, totp_recovery_codes = '{}'
+WHERE id = $1
+`
+
+type SetUserTOTPSecretParams struct {
+	ID         int32
+	TotpSecret pgtype.Text
+}
+
+func (q *Queries) SetUserTOTPSecret(ctx context.Context, arg SetUserTOTPSecretParams) error {
+	_, err := q.db.Exec(ctx, setUserTOTPSecret, arg.ID, arg.TotpSecret)
+	return err
+}
+
 const updateApiKeyLabel = `-- name: UpdateApiKeyLabel :exec
 UPDATE api_keys SET label = $3 WHERE id = $1 AND user_id = $2
 `
@@ -181,6 +525,62 @@ func (q *Queries) UpdateApiKeyLabel(ctx context.Context, arg UpdateApiKeyLabelPa
 	return err
 }
 
+const updateApiKeyLastUsed = `-- name: UpdateApiKeyLastUsed :exec
+UPDATE api_keys SET last_used_at = NOW(), last_used_ip = $2 WHERE id = $1
+`
+
+type UpdateApiKeyLastUsedParams struct {
+	ID         int32
+	LastUsedIp pgtype.Text
+}
+
+func (q *Queries) UpdateApiKeyLastUsed(ctx context.Context, arg UpdateApiKeyLastUsedParams) error {
+	_, err := q.db.Exec(ctx, updateApiKeyLastUsed, arg.ID, arg.LastUsedIp)
+	return err
+}
+
+const updateUserDisabled = `-- name: UpdateUserDisabled :exec
+UPDATE users SET disabled = $2 WHERE id = $1
+`
+
+type UpdateUserDisabledParams struct {
+	ID       int32
+	Disabled bool
+}
+
+func (q *Queries) UpdateUserDisabled(ctx context.Context, arg UpdateUserDisabledParams) error {
+	_, err := q.db.Exec(ctx, updateUserDisabled, arg.ID, arg.Disabled)
+	return err
+}
+
+const updateUserInviteUsedBy = `-- name: UpdateUserInviteUsedBy :exec
+UPDATE user_invites SET used_by = $2 WHERE id = $1
+`
+
+type UpdateUserInviteUsedByParams struct {
+	ID     int32
+	UsedBy pgtype.Int4
+}
+
+func (q *Queries) UpdateUserInviteUsedBy(ctx context.Context, arg UpdateUserInviteUsedByParams) error {
+	_, err := q.db.Exec(ctx, updateUserInviteUsedBy, arg.ID, arg.UsedBy)
+	return err
+}
+
+const updateUserOIDCSubject = `-- name: UpdateUserOIDCSubject :exec
+UPDATE users SET oidc_subject = $2 WHERE id = $1
+`
+
+type UpdateUserOIDCSubjectParams struct {
+	ID          int32
+	OidcSubject pgtype.Text
+}
+
+func (q *Queries) UpdateUserOIDCSubject(ctx context.Context, arg UpdateUserOIDCSubjectParams) error {
+	_, err := q.db.Exec(ctx, updateUserOIDCSubject, arg.ID, arg.OidcSubject)
+	return err
+}
+
 const updateUserPassword = `-- name: UpdateUserPassword :exec
 UPDATE users SET password = $2 WHERE id = $1
 `
@@ -195,6 +595,38 @@ func (q *Queries) UpdateUserPassword(ctx context.Context, arg UpdateUserPassword
 	return err
 }
 
+const updateUserRole = `-- name: UpdateUserRole :exec
+UPDATE users SET role = $2 WHERE id = $1
+`
+
+type UpdateUserRoleParams struct {
+	ID   int32
+	Role Role
+}
+
+func (q *Queries) UpdateUserRole(ctx context.Context, arg UpdateUserRoleParams) error {
+	_, err := q.db.Exec(ctx, updateUserRole, arg.ID, arg.Role)
+	return err
+}
+
+const updateUserTOTPStep = `-- name: UpdateUserTOTPStep :execrows
+UPDATE users SET totp_last_step = $2::bigint
+WHERE id = $1 AND (totp_last_step IS NULL OR totp_last_step < $2::bigint)
+`
+
+type UpdateUserTOTPStepParams struct {
+	ID   int32
+	Step int64
+}
+
+func (q *Queries) UpdateUserTOTPStep(ctx context.Context, arg UpdateUserTOTPStepParams) (int64, error) {
+	result, err := q.db.Exec(ctx, updateUserTOTPStep, arg.ID, arg.Step)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
 const updateUserUsername = `-- name: UpdateUserUsername :exec
 UPDATE users SET username = $2 WHERE id = $1
 `
@@ -208,3 +640,35 @@ func (q *Queries) UpdateUserUsername(ctx context.Context, arg UpdateUserUsername
 	_, err := q.db.Exec(ctx, updateUserUsername, arg.ID, arg.Username)
 	return err
 }
+
+const updateUserVisibility = `-- name: UpdateUserVisibility :exec
+UPDATE users SET visibility = $2 WHERE id = $1
+`
+
+type UpdateUserVisibilityParams struct {
+	ID         int32
+	Visibility string
+}
+
+func (q *Queries) UpdateUserVisibility(ctx context.Context, arg UpdateUserVisibilityParams) error {
+	_, err := q.db.Exec(ctx, updateUserVisibility, arg.ID, arg.Visibility)
+	return err
+}
+
+const useTOTPRecoveryCode = `-- name: UseTOTPRecoveryCode :execrows
+UPDATE users SET totp_recovery_codes = array_remove(totp_recovery_codes, $2::text)
+WHERE id = $1 AND $2::text = ANY(totp_recovery_codes)
+`
+
+type UseTOTPRecoveryCodeParams struct {
+	ID       int32
+	CodeHash string
+}
+
+func (q *Queries) UseTOTPRecoveryCode(ctx context.Context, arg UseTOTPRecoveryCodeParams) (int64, error) {
+	result, err := q.db.Exec(ctx, useTOTPRecoveryCode, arg.ID, arg.CodeHash)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
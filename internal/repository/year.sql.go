@@ -107,7 +107,7 @@ WITH first_listens AS (
         MIN(l.listened_at::date) AS first_listen_of_year
     FROM listens l
     JOIN artist_tracks at ON at.track_id = l.track_id
-    WHERE EXTRACT(YEAR FROM l.listened_at) = $1::int
+    WHERE EXTRACT(YEAR FROM l.listened_at) = $2::int
     GROUP BY l.user_id, at.artist_id
 ),
 last_listens AS (
@@ -117,7 +117,7 @@ last_listens AS (
         MAX(l.listened_at::date) AS last_listen
     FROM listens l
     JOIN artist_tracks at ON at.track_id = l.track_id
-    WHERE l.listened_at < $2::date
+    WHERE l.listened_at < $3::date
     GROUP BY l.user_id, at.artist_id
 ),
 comebacks AS (
@@ -146,9 +146,11 @@ SELECT
 FROM ranked c
 JOIN artists_with_name awn ON awn.id = c.artist_id
 WHERE r = 1
+  AND c.user_id = $1::int
 `
 
 type GetArtistWithLongestGapInYearParams struct {
+	UserID         int32
 	Year           int32
 	FirstDayOfYear pgtype.Date
 }
@@ -163,7 +165,7 @@ type GetArtistWithLongestGapInYearRow struct {
 }
 
 func (q *Queries) GetArtistWithLongestGapInYear(ctx context.Context, arg GetArtistWithLongestGapInYearParams) (GetArtistWithLongestGapInYearRow, error) {
-	row := q.db.QueryRow(ctx, getArtistWithLongestGapInYear, arg.Year, arg.FirstDayOfYear)
+	row := q.db.QueryRow(ctx, getArtistWithLongestGapInYear, arg.UserID, arg.Year, arg.FirstDayOfYear)
 	var i GetArtistWithLongestGapInYearRow
 	err := row.Scan(
 		&i.UserID,
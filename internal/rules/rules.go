@@ -0,0 +1,235 @@
+// Package rules implements user-defined rewrite rules, which change or drop incoming listens
+// before they are added to the catalog.
+package rules
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/gabehf/koito/internal/models"
+)
+
+// Listen holds the fields of a submitted listen that rules can match and change.
+type Listen struct {
+	Artist      string
+	ArtistNames []string
+	Track       string
+	Release     string
+	Client      string
+}
+
+// Result describes what happened when rules were applied to a listen.
+type Result struct {
+	Dropped bool `json:"dropped"`
+	// IDs of the rules that matched, in the order they were applied
+	Applied []int32 `json:"applied_rules"`
+}
+
+type compiledRule struct {
+	rule *models.RewriteRule
+	re   *regexp.Regexp
+}
+
+// A Set is a list of compiled rules, ready to be applied to listens.
+type Set []compiledRule
+
+// Compile validates and compiles rules, skipping those that are disabled. Rules are applied in
+// the order they are given.
+func Compile(rules []*models.RewriteRule) (Set, error) {
+	set := make(Set, 0, len(rules))
+	for _, rule := range rules {
+		if !rule.Enabled {
+			continue
+		}
+		re, err := compile(rule)
+		if err != nil {
+			return nil, fmt.Errorf("Compile: rule %d: %w", rule.ID, err)
+		}
+		set = append(set, compiledRule{rule: rule, re: re})
+	}
+	return set, nil
+}
+
+// Validate reports whether a rule is well formed and its pattern compiles.
+func Validate(rule *models.RewriteRule) error {
+	switch rule.Field {
+	case models.RewriteFieldArtist, models.RewriteFieldTrack, models.RewriteFieldRelease, models.RewriteFieldClient:
+	default:
+		return fmt.Errorf("field must be one of 'artist', 'track', 'release' or 'client'")
+	}
+	switch rule.TargetField {
+	case "", models.RewriteFieldArtist, models.RewriteFieldTrack, models.RewriteFieldRelease, models.RewriteFieldClient:
+	default:
+		return fmt.Errorf("target_field must be one of 'artist', 'track', 'release' or 'client'")
+	}
+	switch rule.MatchType {
+	case models.RewriteMatchExact, models.RewriteMatchRegex:
+	default:
+		return fmt.Errorf("match_type must be 'exact' or 'regex'")
+	}
+	if rule.Pattern == "" {
+		return fmt.Errorf("pattern is required")
+	}
+	switch rule.Action {
+	case models.RewriteActionRewrite, models.RewriteActionStripSuffix, models.RewriteActionDrop:
+	case models.RewriteActionSplitArtists:
+		if rule.Field != models.RewriteFieldArtist {
+			return fmt.Errorf("split_artists can only be used on the artist field")
+		}
+	default:
+		return fmt.Errorf("action must be one of 'rewrite', 'strip_suffix', 'split_artists' or 'drop'")
+	}
+	if rule.Action != models.RewriteActionRewrite && rule.TargetField != "" && rule.TargetField != rule.Field {
+		return fmt.Errorf("target_field can only be used with the rewrite action")
+	}
+	if _, err := compile(rule); err != nil {
+		return fmt.Errorf("pattern is not a valid regular expression: %w", err)
+	}
+	return nil
+}
+
+// compile turns the rule's pattern into a regular expression suited to its action. Exact
+// patterns are matched case-insensitively against the whole field, or against the end of
+// the field when stripping suffixes.
+func compile(rule *models.RewriteRule) (*regexp.Regexp, error) {
+	pattern := rule.Pattern
+	if rule.MatchType == models.RewriteMatchExact {
+		pattern = "(?i)" + regexp.QuoteMeta(pattern)
+		if rule.Action != models.RewriteActionSplitArtists && rule.Action != models.RewriteActionStripSuffix {
+			pattern = "^" + pattern + "$"
+		}
+	}
+	if rule.Action == models.RewriteActionStripSuffix {
+		pattern = "(?:" + pattern + ")$"
+	}
+	return regexp.Compile(pattern)
+}
+
+// Apply runs every rule in the set against the listen, changing it in place. Applying stops
+// as soon as a rule drops the listen.
+func (s Set) Apply(li *Listen) Result {
+	res := Result{Applied: []int32{}}
+	for _, c := range s {
+		if !c.apply(li) {
+			continue
+		}
+		res.Applied = append(res.Applied, c.rule.ID)
+		if c.rule.Action == models.RewriteActionDrop {
+			res.Dropped = true
+			break
+		}
+	}
+	return res
+}
+
+// apply runs a single rule and reports whether it matched.
+func (c compiledRule) apply(li *Listen) bool {
+	rule := c.rule
+
+	// the artist field matches the artist credit and each individual artist name
+	var values []*string
+	switch rule.Field {
+	case models.RewriteFieldArtist:
+		values = append(values, &li.Artist)
+		for i := range li.ArtistNames {
+			values = append(values, &li.ArtistNames[i])
+		}
+	case models.RewriteFieldTrack:
+		values = append(values, &li.Track)
+	case models.RewriteFieldRelease:
+		values = append(values, &li.Release)
+	case models.RewriteFieldClient:
+		values = append(values, &li.Client)
+	}
+
+	var matched []*string
+	for _, v := range values {
+		if c.re.MatchString(*v) {
+			matched = append(matched, v)
+		}
+	}
+	if len(matched) == 0 {
+		return false
+	}
+
+	switch rule.Action {
+	case models.RewriteActionDrop:
+		// nothing to change
+
+	case models.RewriteActionStripSuffix:
+		for _, v := range matched {
+			*v = strings.TrimSpace(c.re.ReplaceAllString(*v, ""))
+		}
+
+	case models.RewriteActionSplitArtists:
+		var names []string
+		if len(li.ArtistNames) == 0 {
+			names = splitAndTrim(c.re, li.Artist)
+		} else {
+			for _, name := range li.ArtistNames {
+				names = append(names, splitAndTrim(c.re, name)...)
+			}
+		}
+		li.ArtistNames = names
+
+	case models.RewriteActionRewrite:
+		target := rule.TargetField
+		if target == "" || target == rule.Field {
+			for _, v := range matched {
+				if rule.MatchType == models.RewriteMatchRegex {
+					*v = c.re.ReplaceAllString(*v, rule.Replacement)
+				} else {
+					*v = rule.Replacement
+				}
+			}
+		} else {
+			value := rule.Replacement
+			if rule.MatchType == models.RewriteMatchRegex {
+				value = string(c.re.ExpandString(nil, rule.Replacement, *matched[0], c.re.FindStringSubmatchIndex(*matched[0])))
+			}
+			li.set(target, value)
+		}
+	}
+
+	li.ArtistNames = removeEmpty(li.ArtistNames)
+	return true
+}
+
+func (li *Listen) set(field models.RewriteField, value string) {
+	switch field {
+	case models.RewriteFieldArtist:
+		// the old artist names no longer describe the new artist
+		li.Artist = value
+		li.ArtistNames = nil
+	case models.RewriteFieldTrack:
+		li.Track = value
+	case models.RewriteFieldRelease:
+		li.Release = value
+	case models.RewriteFieldClient:
+		li.Client = value
+	}
+}
+
+func splitAndTrim(re *regexp.Regexp, s string) []string {
+	var out []string
+	for _, part := range re.Split(s, -1) {
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
+
+func removeEmpty(names []string) []string {
+	if names == nil {
+		return nil
+	}
+	out := names[:0]
+	for _, name := range names {
+		if name != "" {
+			out = append(out, name)
+		}
+	}
+	return out
+}
@@ -0,0 +1,132 @@
+package rules_test
+
+import (
+	"testing"
+
+	"github.com/gabehf/koito/internal/models"
+	"github.com/gabehf/koito/internal/rules"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func rule(id int32, field models.RewriteField, match models.RewriteMatchType, pattern string, action models.RewriteAction) *models.RewriteRule {
+	return &models.RewriteRule{
+		ID:        id,
+		Enabled:   true,
+		Field:     field,
+		MatchType: match,
+		Pattern:   pattern,
+		Action:    action,
+	}
+}
+
+func TestStripSuffix(t *testing.T) {
+	set, err := rules.Compile([]*models.RewriteRule{
+		rule(1, models.RewriteFieldTrack, models.RewriteMatchRegex, ` - \d{4} Remaster(ed)?`, models.RewriteActionStripSuffix),
+		rule(2, models.RewriteFieldRelease, models.RewriteMatchExact, " (deluxe edition)", models.RewriteActionStripSuffix),
+	})
+	require.NoError(t, err)
+
+	li := &rules.Listen{Artist: "The Beatles", Track: "Let It Be - 2009 Remaster", Release: "Let It Be (Deluxe Edition)"}
+	res := set.Apply(li)
+	assert.False(t, res.Dropped)
+	assert.Equal(t, []int32{1, 2}, res.Applied)
+	assert.Equal(t, "Let It Be", li.Track)
+	assert.Equal(t, "Let It Be", li.Release)
+
+	// suffixes in the middle of a field are left alone
+	li = &rules.Listen{Track: "Song - 2009 Remaster (Live)"}
+	res = set.Apply(li)
+	assert.Empty(t, res.Applied)
+	assert.Equal(t, "Song - 2009 Remaster (Live)", li.Track)
+}
+
+func TestRewrite(t *testing.T) {
+	r1 := rule(1, models.RewriteFieldArtist, models.RewriteMatchExact, "sayuri", models.RewriteActionRewrite)
+	r1.Replacement = "さユり"
+	r2 := rule(2, models.RewriteFieldTrack, models.RewriteMatchRegex, `^(.+) \(feat\. (.+)\)$`, models.RewriteActionRewrite)
+	r2.Replacement = "$1"
+	r3 := rule(3, models.RewriteFieldClient, models.RewriteMatchRegex, `^Spotify`, models.RewriteActionRewrite)
+	r3.TargetField = models.RewriteFieldRelease
+	r3.Replacement = "Unknown"
+	set, err := rules.Compile([]*models.RewriteRule{r1, r2, r3})
+	require.NoError(t, err)
+
+	li := &rules.Listen{
+		Artist:      "Sayuri",
+		ArtistNames: []string{"Sayuri", "MY FIRST STORY"},
+		Track:       "レイメイ (feat. MY FIRST STORY)",
+		Client:      "Spotify Connect",
+	}
+	res := set.Apply(li)
+	assert.Equal(t, []int32{1, 2, 3}, res.Applied)
+	assert.Equal(t, "さユり", li.Artist)
+	assert.Equal(t, []string{"さユり", "MY FIRST STORY"}, li.ArtistNames)
+	assert.Equal(t, "レイメイ", li.Track)
+	assert.Equal(t, "Unknown", li.Release)
+}
+
+func TestRewriteArtistFromOtherField(t *testing.T) {
+	r := rule(1, models.RewriteFieldTrack, models.RewriteMatchRegex, `^(.+) - (.+)$`, models.RewriteActionRewrite)
+	r.TargetField = models.RewriteFieldArtist
+	r.Replacement = "$1"
+	set, err := rules.Compile([]*models.RewriteRule{r})
+	require.NoError(t, err)
+
+	li := &rules.Listen{Artist: "Various Artists", ArtistNames: []string{"Various Artists"}, Track: "ヨルシカ - ただ君に晴れ"}
+	set.Apply(li)
+	assert.Equal(t, "ヨルシカ", li.Artist)
+	assert.Nil(t, li.ArtistNames)
+}
+
+func TestSplitArtists(t *testing.T) {
+	set, err := rules.Compile([]*models.RewriteRule{
+		rule(1, models.RewriteFieldArtist, models.RewriteMatchExact, " x ", models.RewriteActionSplitArtists),
+	})
+	require.NoError(t, err)
+
+	li := &rules.Listen{Artist: "Porter Robinson X Madeon"}
+	res := set.Apply(li)
+	assert.Equal(t, []int32{1}, res.Applied)
+	assert.Equal(t, "Porter Robinson X Madeon", li.Artist)
+	assert.Equal(t, []string{"Porter Robinson", "Madeon"}, li.ArtistNames)
+}
+
+func TestDrop(t *testing.T) {
+	r2 := rule(2, models.RewriteFieldTrack, models.RewriteMatchExact, "x", models.RewriteActionRewrite)
+	r2.Replacement = "y"
+	set, err := rules.Compile([]*models.RewriteRule{
+		rule(1, models.RewriteFieldClient, models.RewriteMatchRegex, `(?i)podcast`, models.RewriteActionDrop),
+		r2,
+	})
+	require.NoError(t, err)
+
+	li := &rules.Listen{Track: "x", Client: "Podcast Addict"}
+	res := set.Apply(li)
+	assert.True(t, res.Dropped)
+	assert.Equal(t, []int32{1}, res.Applied)
+	// no rules run after a drop
+	assert.Equal(t, "x", li.Track)
+}
+
+func TestCompileSkipsDisabled(t *testing.T) {
+	r := rule(1, models.RewriteFieldTrack, models.RewriteMatchExact, "x", models.RewriteActionDrop)
+	r.Enabled = false
+	set, err := rules.Compile([]*models.RewriteRule{r})
+	require.NoError(t, err)
+	assert.False(t, set.Apply(&rules.Listen{Track: "x"}).Dropped)
+}
+
+func TestValidate(t *testing.T) {
+	assert.NoError(t, rules.Validate(rule(1, models.RewriteFieldTrack, models.RewriteMatchRegex, `\(Live\)$`, models.RewriteActionDrop)))
+	assert.Error(t, rules.Validate(rule(1, models.RewriteFieldTrack, models.RewriteMatchRegex, `(unclosed`, models.RewriteActionDrop)))
+	assert.Error(t, rules.Validate(rule(1, models.RewriteFieldTrack, models.RewriteMatchExact, "", models.RewriteActionDrop)))
+	assert.Error(t, rules.Validate(rule(1, "genre", models.RewriteMatchExact, "x", models.RewriteActionDrop)))
+	assert.Error(t, rules.Validate(rule(1, models.RewriteFieldTrack, "glob", "x", models.RewriteActionDrop)))
+	assert.Error(t, rules.Validate(rule(1, models.RewriteFieldTrack, models.RewriteMatchExact, "x", "delete")))
+	assert.Error(t, rules.Validate(rule(1, models.RewriteFieldTrack, models.RewriteMatchExact, ",", models.RewriteActionSplitArtists)))
+
+	r := rule(1, models.RewriteFieldTrack, models.RewriteMatchExact, "x", models.RewriteActionDrop)
+	r.TargetField = models.RewriteFieldRelease
+	assert.Error(t, rules.Validate(r))
+}
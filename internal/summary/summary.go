@@ -26,21 +26,19 @@ type Summary struct {
 }
 
 func GenerateSummary(ctx context.Context, store db.DB, userId int32, timeframe db.Timeframe, title string) (summary *Summary, err error) {
-	_ = userId
-
 	summary = &Summary{Title: title}
 
-	topArtists, err := store.GetTopArtistsPaginated(ctx, db.GetItemsOpts{Page: 1, Limit: 5, Timeframe: timeframe})
+	topArtists, err := store.GetTopArtistsPaginated(ctx, db.GetItemsOpts{UserID: userId, Page: 1, Limit: 5, Timeframe: timeframe})
 	if err != nil {
 		return nil, fmt.Errorf("GenerateSummary: %w", err)
 	}
 	summary.TopArtists = topArtists.Items
 	for i, artist := range summary.TopArtists {
-		timeListened, err := store.CountTimeListenedToItem(ctx, db.TimeListenedOpts{ArtistID: artist.Item.ID, Timeframe: timeframe})
+		timeListened, err := store.CountTimeListenedToItem(ctx, db.TimeListenedOpts{UserID: userId, ArtistID: artist.Item.ID, Timeframe: timeframe})
 		if err != nil {
 			return nil, fmt.Errorf("GenerateSummary: %w", err)
 		}
-		listens, err := store.CountListensToItem(ctx, db.TimeListenedOpts{ArtistID: artist.Item.ID, Timeframe: timeframe})
+		listens, err := store.CountListensToItem(ctx, db.TimeListenedOpts{UserID: userId, ArtistID: artist.Item.ID, Timeframe: timeframe})
 		if err != nil {
 			return nil, fmt.Errorf("GenerateSummary: %w", err)
 		}
@@ -48,17 +46,17 @@ func GenerateSummary(ctx context.Context, store db.DB, userId int32, timeframe d
 		summary.TopArtists[i].Item.ListenCount = listens
 	}
 
-	topAlbums, err := store.GetTopAlbumsPaginated(ctx, db.GetItemsOpts{Page: 1, Limit: 5, Timeframe: timeframe})
+	topAlbums, err := store.GetTopAlbumsPaginated(ctx, db.GetItemsOpts{UserID: userId, Page: 1, Limit: 5, Timeframe: timeframe})
 	if err != nil {
 		return nil, fmt.Errorf("GenerateSummary: %w", err)
 	}
 	summary.TopAlbums = topAlbums.Items
 	for i, album := range summary.TopAlbums {
-		timeListened, err := store.CountTimeListenedToItem(ctx, db.TimeListenedOpts{AlbumID: album.Item.ID, Timeframe: timeframe})
+		timeListened, err := store.CountTimeListenedToItem(ctx, db.TimeListenedOpts{UserID: userId, AlbumID: album.Item.ID, Timeframe: timeframe})
 		if err != nil {
 			return nil, fmt.Errorf("GenerateSummary: %w", err)
 		}
-		listens, err := store.CountListensToItem(ctx, db.TimeListenedOpts{AlbumID: album.Item.ID, Timeframe: timeframe})
+		listens, err := store.CountListensToItem(ctx, db.TimeListenedOpts{UserID: userId, AlbumID: album.Item.ID, Timeframe: timeframe})
 		if err != nil {
 			return nil, fmt.Errorf("GenerateSummary: %w", err)
 		}
@@ -66,17 +64,17 @@ func GenerateSummary(ctx context.Context, store db.DB, userId int32, timeframe d
 		summary.TopAlbums[i].Item.ListenCount = listens
 	}
 
-	topTracks, err := store.GetTopTracksPaginated(ctx, db.GetItemsOpts{Page: 1, Limit: 5, Timeframe: timeframe})
+	topTracks, err := store.GetTopTracksPaginated(ctx, db.GetItemsOpts{UserID: userId, Page: 1, Limit: 5, Timeframe: timeframe})
 	if err != nil {
 		return nil, fmt.Errorf("GenerateSummary: %w", err)
 	}
 	summary.TopTracks = topTracks.Items
 	for i, track := range summary.TopTracks {
-		timeListened, err := store.CountTimeListenedToItem(ctx, db.TimeListenedOpts{TrackID: track.Item.ID, Timeframe: timeframe})
+		timeListened, err := store.CountTimeListenedToItem(ctx, db.TimeListenedOpts{UserID: userId, TrackID: track.Item.ID, Timeframe: timeframe})
 		if err != nil {
 			return nil, fmt.Errorf("GenerateSummary: %w", err)
 		}
-		listens, err := store.CountListensToItem(ctx, db.TimeListenedOpts{TrackID: track.Item.ID, Timeframe: timeframe})
+		listens, err := store.CountListensToItem(ctx, db.TimeListenedOpts{UserID: userId, TrackID: track.Item.ID, Timeframe: timeframe})
 		if err != nil {
 			return nil, fmt.Errorf("GenerateSummary: %w", err)
 		}
@@ -90,44 +88,44 @@ func GenerateSummary(ctx context.Context, store db.DB, userId int32, timeframe d
 		dayCount = 1
 	}
 
-	tmp, err := store.CountTimeListened(ctx, timeframe)
+	tmp, err := store.CountTimeListened(ctx, userId, timeframe)
 	if err != nil {
 		return nil, fmt.Errorf("GenerateSummary: %w", err)
 	}
 	summary.MinutesListened = int(tmp) / 60
 	summary.AvgMinutesPerDay = summary.MinutesListened / dayCount
-	tmp, err = store.CountListens(ctx, timeframe)
+	tmp, err = store.CountListens(ctx, userId, timeframe)
 	if err != nil {
 		return nil, fmt.Errorf("GenerateSummary: %w", err)
 	}
 	summary.Plays = int(tmp)
 	summary.AvgPlaysPerDay = float32(summary.Plays) / float32(dayCount)
-	tmp, err = store.CountTracks(ctx, timeframe)
+	tmp, err = store.CountTracks(ctx, userId, timeframe)
 	if err != nil {
 		return nil, fmt.Errorf("GenerateSummary: %w", err)
 	}
 	summary.UniqueTracks = int(tmp)
-	tmp, err = store.CountAlbums(ctx, timeframe)
+	tmp, err = store.CountAlbums(ctx, userId, timeframe)
 	if err != nil {
 		return nil, fmt.Errorf("GenerateSummary: %w", err)
 	}
 	summary.UniqueAlbums = int(tmp)
-	tmp, err = store.CountArtists(ctx, timeframe)
+	tmp, err = store.CountArtists(ctx, userId, timeframe)
 	if err != nil {
 		return nil, fmt.Errorf("GenerateSummary: %w", err)
 	}
 	summary.UniqueArtists = int(tmp)
-	tmp, err = store.CountNewTracks(ctx, timeframe)
+	tmp, err = store.CountNewTracks(ctx, userId, timeframe)
 	if err != nil {
 		return nil, fmt.Errorf("GenerateSummary: %w", err)
 	}
 	summary.NewTracks = int(tmp)
-	tmp, err = store.CountNewAlbums(ctx, timeframe)
+	tmp, err = store.CountNewAlbums(ctx, userId, timeframe)
 	if err != nil {
 		return nil, fmt.Errorf("GenerateSummary: %w", err)
 	}
 	summary.NewAlbums = int(tmp)
-	tmp, err = store.CountNewArtists(ctx, timeframe)
+	tmp, err = store.CountNewArtists(ctx, userId, timeframe)
 	if err != nil {
 		return nil, fmt.Errorf("GenerateSummary: %w", err)
 	}
@@ -53,7 +53,7 @@ func TestGenerateSummary(t *testing.T) {
 		newArtists:        3,
 	}
 
-	got, err := summary.GenerateSummary(context.Background(), store, 1, timeframe, "2024 Rewind")
+	got, err := summary.GenerateSummary(context.Background(), store, 2, timeframe, "2024 Rewind")
 	require.NoError(t, err)
 
 	assert.Equal(t, "2024 Rewind", got.Title)
@@ -83,18 +83,21 @@ func TestGenerateSummary(t *testing.T) {
 	assert.Equal(t, int64(3), got.TopTracks[0].Item.ListenCount)
 
 	assert.Equal(t, []db.GetItemsOpts{
-		{Page: 1, Limit: 5, Timeframe: timeframe},
-		{Page: 1, Limit: 5, Timeframe: timeframe},
-		{Page: 1, Limit: 5, Timeframe: timeframe},
+		{UserID: 2, Page: 1, Limit: 5, Timeframe: timeframe},
+		{UserID: 2, Page: 1, Limit: 5, Timeframe: timeframe},
+		{UserID: 2, Page: 1, Limit: 5, Timeframe: timeframe},
 	}, store.itemRequests)
 	assert.Len(t, store.timeListenedRequests, 4)
 	assert.Len(t, store.listenCountRequests, 4)
 	for _, req := range store.timeListenedRequests {
+		assert.Equal(t, int32(2), req.UserID)
 		assert.Equal(t, timeframe, req.Timeframe)
 	}
 	for _, req := range store.listenCountRequests {
+		assert.Equal(t, int32(2), req.UserID)
 		assert.Equal(t, timeframe, req.Timeframe)
 	}
+	assert.Equal(t, []int32{2, 2, 2, 2, 2, 2, 2, 2}, store.aggregateUserIDs)
 	assert.Equal(t, []db.Timeframe{timeframe, timeframe, timeframe, timeframe, timeframe, timeframe, timeframe, timeframe}, store.aggregateTimeframes)
 }
 
@@ -135,6 +138,7 @@ type mockSummaryStore struct {
 	itemRequests         []db.GetItemsOpts
 	timeListenedRequests []db.TimeListenedOpts
 	listenCountRequests  []db.TimeListenedOpts
+	aggregateUserIDs     []int32
 	aggregateTimeframes  []db.Timeframe
 }
 
@@ -166,42 +170,50 @@ func (m *mockSummaryStore) CountListensToItem(ctx context.Context, opts db.TimeL
 	return m.itemListens[itemKey(opts)], nil
 }
 
-func (m *mockSummaryStore) CountTimeListened(ctx context.Context, timeframe db.Timeframe) (int64, error) {
+func (m *mockSummaryStore) CountTimeListened(ctx context.Context, userID int32, timeframe db.Timeframe) (int64, error) {
+	m.aggregateUserIDs = append(m.aggregateUserIDs, userID)
 	m.aggregateTimeframes = append(m.aggregateTimeframes, timeframe)
 	return m.totalTimeListened, nil
 }
 
-func (m *mockSummaryStore) CountListens(ctx context.Context, timeframe db.Timeframe) (int64, error) {
+func (m *mockSummaryStore) CountListens(ctx context.Context, userID int32, timeframe db.Timeframe) (int64, error) {
+	m.aggregateUserIDs = append(m.aggregateUserIDs, userID)
 	m.aggregateTimeframes = append(m.aggregateTimeframes, timeframe)
 	return m.totalListens, nil
 }
 
-func (m *mockSummaryStore) CountTracks(ctx context.Context, timeframe db.Timeframe) (int64, error) {
+func (m *mockSummaryStore) CountTracks(ctx context.Context, userID int32, timeframe db.Timeframe) (int64, error) {
+	m.aggregateUserIDs = append(m.aggregateUserIDs, userID)
 	m.aggregateTimeframes = append(m.aggregateTimeframes, timeframe)
 	return m.totalTracks, nil
 }
 
-func (m *mockSummaryStore) CountAlbums(ctx context.Context, timeframe db.Timeframe) (int64, error) {
+func (m *mockSummaryStore) CountAlbums(ctx context.Context, userID int32, timeframe db.Timeframe) (int64, error) {
+	m.aggregateUserIDs = append(m.aggregateUserIDs, userID)
 	m.aggregateTimeframes = append(m.aggregateTimeframes, timeframe)
 	return m.totalAlbums, nil
 }
 
-func (m *mockSummaryStore) CountArtists(ctx context.Context, timeframe db.Timeframe) (int64, error) {
+func (m *mockSummaryStore) CountArtists(ctx context.Context, userID int32, timeframe db.Timeframe) (int64, error) {
+	m.aggregateUserIDs = append(m.aggregateUserIDs, userID)
 	m.aggregateTimeframes = append(m.aggregateTimeframes, timeframe)
 	return m.totalArtists, nil
 }
 
-func (m *mockSummaryStore) CountNewTracks(ctx context.Context, timeframe db.Timeframe) (int64, error) {
+func (m *mockSummaryStore) CountNewTracks(ctx context.Context, userID int32, timeframe db.Timeframe) (int64, error) {
+	m.aggregateUserIDs = append(m.aggregateUserIDs, userID)
 	m.aggregateTimeframes = append(m.aggregateTimeframes, timeframe)
 	return m.newTracks, nil
 }
 
-func (m *mockSummaryStore) CountNewAlbums(ctx context.Context, timeframe db.Timeframe) (int64, error) {
+func (m *mockSummaryStore) CountNewAlbums(ctx context.Context, userID int32, timeframe db.Timeframe) (int64, error) {
+	m.aggregateUserIDs = append(m.aggregateUserIDs, userID)
 	m.aggregateTimeframes = append(m.aggregateTimeframes, timeframe)
 	return m.newAlbums, nil
 }
 
-func (m *mockSummaryStore) CountNewArtists(ctx context.Context, timeframe db.Timeframe) (int64, error) {
+func (m *mockSummaryStore) CountNewArtists(ctx context.Context, userID int32, timeframe db.Timeframe) (int64, error) {
+	m.aggregateUserIDs = append(m.aggregateUserIDs, userID)
 	m.aggregateTimeframes = append(m.aggregateTimeframes, timeframe)
 	return m.newArtists, nil
 }
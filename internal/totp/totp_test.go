@@ -0,0 +1,85 @@
+package totp_test
+
+import (
+	"encoding/base32"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gabehf/koito/internal/totp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// the SHA-1 test vectors from RFC 6238, truncated to six digits
+func TestCode(t *testing.T) {
+	secret := base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+	cases := map[int64]string{
+		59:         "287082",
+		1111111109: "081804",
+		1111111111: "050471",
+		1234567890: "005924",
+		2000000000: "279037",
+	}
+	for unix, expected := range cases {
+		code, err := totp.Code(secret, totp.Step(time.Unix(unix, 0)))
+		require.NoError(t, err)
+		assert.Equal(t, expected, code, "at %d", unix)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	secret, err := totp.GenerateSecret()
+	require.NoError(t, err)
+	now := time.Unix(1700000000, 0)
+
+	code, err := totp.Code(secret, totp.Step(now))
+	require.NoError(t, err)
+	step, ok := totp.Validate(secret, code, now)
+	assert.True(t, ok)
+	assert.Equal(t, totp.Step(now), step)
+
+	// a slightly slow clock is tolerated
+	previous, err := totp.Code(secret, totp.Step(now)-1)
+	require.NoError(t, err)
+	step, ok = totp.Validate(secret, previous[:3]+" "+previous[3:], now)
+	assert.True(t, ok)
+	assert.Equal(t, totp.Step(now)-1, step)
+
+	old, err := totp.Code(secret, totp.Step(now)-3)
+	require.NoError(t, err)
+	if old != code && old != previous {
+		_, ok = totp.Validate(secret, old, now)
+		assert.False(t, ok)
+	}
+
+	_, ok = totp.Validate(secret, "12345", now)
+	assert.False(t, ok)
+	_, ok = totp.Validate("not base32!", "123456", now)
+	assert.False(t, ok)
+}
+
+func TestProvisioningURI(t *testing.T) {
+	uri := totp.ProvisioningURI("Koito", "some user", "JBSWY3DPEHPK3PXP")
+	assert.True(t, strings.HasPrefix(uri, "otpauth://totp/Koito:some%20user?"))
+	assert.Contains(t, uri, "secret=JBSWY3DPEHPK3PXP")
+	assert.Contains(t, uri, "issuer=Koito")
+	assert.Contains(t, uri, "digits=6")
+	assert.Contains(t, uri, "period=30")
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	codes, err := totp.GenerateRecoveryCodes(10)
+	require.NoError(t, err)
+	require.Len(t, codes, 10)
+	seen := make(map[string]bool)
+	for _, c := range codes {
+		assert.Len(t, c, 11)
+		assert.Equal(t, "-", c[5:6])
+		assert.False(t, seen[c])
+		seen[c] = true
+	}
+
+	assert.Equal(t, totp.HashRecoveryCode(codes[0]), totp.HashRecoveryCode(" "+strings.ToUpper(strings.ReplaceAll(codes[0], "-", ""))))
+	assert.NotEqual(t, totp.HashRecoveryCode(codes[0]), totp.HashRecoveryCode(codes[1]))
+}